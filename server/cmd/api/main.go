@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
 	"strings"
 
 	_ "github.com/joho/godotenv/autoload"
 
 	"github.com/fdg312/health-hub/internal/config"
 	"github.com/fdg312/health-hub/internal/dbmigrate"
+	"github.com/fdg312/health-hub/internal/fieldcrypt"
 	"github.com/fdg312/health-hub/internal/httpserver"
+	"github.com/fdg312/health-hub/internal/logging"
+	"github.com/fdg312/health-hub/internal/telemetry"
 )
 
 func main() {
@@ -32,9 +38,19 @@ func main() {
 
 	validateProductionConfig(cfg)
 
+	// From here on everything, including the standard log package, writes JSON.
+	slog.SetDefault(logging.New(os.Stdout, cfg.LogLevel))
+
+	shutdownTracing, err := telemetry.SetupTracing(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("FATAL tracing: %v", err)
+	}
+
 	server := httpserver.New(cfg)
 
-	log.Fatal(server.Start())
+	err = server.Start()
+	_ = shutdownTracing(context.Background())
+	log.Fatal(err)
 }
 
 // printStartupBanner logs a one-time summary of the resolved configuration.
@@ -49,6 +65,7 @@ func printStartupBanner(cfg *config.Config) {
 	log.Printf("  runtime_url      = %s", describeDBURL(cfg.DatabaseURL, cfg.DatabaseURLPooled))
 	log.Printf("  pooled           = %s", setOrNot(cfg.DatabaseURLPooled))
 	log.Printf("  direct           = %s", setOrNot(cfg.DatabaseURLDirect))
+	log.Printf("  field_encryption = %s", fieldEncryptionStatus(cfg.FieldEncryptionKeys))
 	log.Printf("  migrations_on_startup = %t", cfg.RunMigrationsOnStartup)
 	if cfg.RunMigrationsOnStartup {
 		if cfg.DatabaseURLDirect != "" {
@@ -65,6 +82,10 @@ func printStartupBanner(cfg *config.Config) {
 	log.Printf("  email_auth       = %t", cfg.EmailAuthEnabled)
 	log.Printf("  jwt_secret       = %s", secretStatus(cfg.JWTSecret, "change_me"))
 	log.Printf("  otp_secret       = %s", setOrNot(cfg.OTPSecret))
+	log.Printf("  otp_lockout      = after %d failures, %ds..%ds", cfg.OTPLockoutAfterFailures, cfg.OTPLockoutBaseSeconds, cfg.OTPLockoutMaxSeconds)
+	log.Printf("  otp_challenge    = after %d requests / %d failures, difficulty=%d", cfg.OTPChallengeAfterRequests, cfg.OTPChallengeAfterFailures, cfg.OTPChallengeDifficulty)
+	log.Printf("  otp_block_disposable = %t (+%d custom domains)", cfg.OTPBlockDisposableEmails, len(cfg.OTPBlockedEmailDomains))
+	log.Printf("  admin_users      = %d", len(cfg.AdminUserIDs))
 	if cfg.AuthMode == "siwa" {
 		log.Printf("  apple_bundle_id  = %s", nonEmptyOrDash(cfg.AppleBundleID))
 	}
@@ -76,6 +97,8 @@ func printStartupBanner(cfg *config.Config) {
 	if cfg.Blob.Mode != config.BlobModeLocal || cfg.Blob.EffectiveReportsMode() != config.BlobModeLocal {
 		log.Printf("  s3: %s", cfg.Blob.S3.DiagnosticsSummary())
 	}
+	log.Printf("  heic_converter   = %s", nonEmptyOrDash(cfg.ImageHEICConverter))
+	log.Printf("  lab_extractor    = %s", cfg.LabExtractor)
 
 	// ---- Mailer ----
 	log.Println("---- mailer ----")
@@ -98,9 +121,47 @@ func printStartupBanner(cfg *config.Config) {
 	// ---- AI ----
 	log.Println("---- ai ----")
 	log.Printf("  ai_mode          = %s", cfg.AIMode)
-	if cfg.AIMode == "openai" {
-		log.Printf("  openai_model     = %s", cfg.OpenAIModel)
-		log.Printf("  openai_api_key   = %s", setOrNot(cfg.OpenAIAPIKey))
+	if len(cfg.AIFallback) > 0 {
+		log.Printf("  ai_fallback      = %s", strings.Join(cfg.AIFallback, ", "))
+	}
+	for _, mode := range append([]string{cfg.AIMode}, cfg.AIFallback...) {
+		switch mode {
+		case config.AIModeOpenAI:
+			log.Printf("  openai_model     = %s", cfg.OpenAIModel)
+			log.Printf("  openai_api_key   = %s", setOrNot(cfg.OpenAIAPIKey))
+		case config.AIModeAnthropic:
+			log.Printf("  anthropic_model  = %s", cfg.Anthropic.Model)
+			log.Printf("  anthropic_key    = %s", setOrNot(cfg.Anthropic.APIKey))
+		case config.AIModeOllama:
+			log.Printf("  ollama           = %s (%s)", cfg.Ollama.BaseURL, cfg.Ollama.Model)
+		case config.AIModeOpenAICompatible:
+			log.Printf("  compatible       = %s (%s)", cfg.OpenAICompatible.BaseURL, cfg.OpenAICompatible.Model)
+			log.Printf("  compatible_key   = %s", setOrNot(cfg.OpenAICompatible.APIKey))
+		}
+	}
+	if cfg.AIMode != config.AIModeMock || len(cfg.AIFallback) > 0 {
+		log.Printf("  ai_retries       = %d (backoff %dms)", cfg.AIMaxRetries, cfg.AIRetryBackoffMs)
+	}
+	if len(cfg.AIRedact) > 0 {
+		log.Printf("  ai_redact        = %s", strings.Join(cfg.AIRedact, ", "))
+	} else {
+		log.Printf("  ai_redact        = none")
+	}
+	log.Printf("  ai_consent       = %s", cfg.AIConsentVersion)
+	log.Printf("  ai_token_quota   = %d/day, %d/month (0 = unlimited)", cfg.AIDailyTokenQuota, cfg.AIMonthlyTokenQuota)
+	log.Printf("  proposal_undo    = %d days", cfg.ProposalUndoDays)
+	log.Printf("  proposal_ttl     = %d days", cfg.ProposalTTLDays)
+
+	// ---- Audit ----
+	log.Println("---- audit ----")
+	log.Printf("  retention_days   = %d", cfg.AuditRetentionDays)
+
+	log.Println("---- observability ----")
+	log.Printf("  metrics          = %s", metricsStatus(cfg))
+	if cfg.OTelExporterEndpoint != "" {
+		log.Printf("  tracing          = otlp (%s, service=%s, ratio=%g)", cfg.OTelExporterEndpoint, cfg.OTelServiceName, cfg.OTelSampleRatio)
+	} else {
+		log.Printf("  tracing          = off")
 	}
 
 	log.Println("====================================")
@@ -151,6 +212,15 @@ func validateProductionConfig(cfg *config.Config) {
 	if isProd && cfg.DatabaseURL == "" {
 		log.Fatalf("FATAL db: no DATABASE_URL configured in %s", cfg.Env)
 	}
+
+	// Field encryption keys must parse; production data must not be stored in plaintext
+	if len(cfg.FieldEncryptionKeys) > 0 {
+		if _, err := fieldcrypt.ParseKeyring(cfg.FieldEncryptionKeys); err != nil {
+			log.Fatalf("FATAL db: FIELD_ENCRYPTION_KEYS is invalid: %v", err)
+		}
+	} else if isProd && cfg.DatabaseURL != "" {
+		log.Fatalf("FATAL db: FIELD_ENCRYPTION_KEYS must be set in %s", cfg.Env)
+	}
 }
 
 // ---- helpers (no secrets) ----
@@ -162,6 +232,30 @@ func setOrNot(v string) string {
 	return "set"
 }
 
+// metricsStatus describes where /metrics is exposed.
+func metricsStatus(cfg *config.Config) string {
+	switch {
+	case cfg.MetricsAddr != "":
+		return fmt.Sprintf("%s/metrics (token %s)", cfg.MetricsAddr, setOrNot(cfg.MetricsToken))
+	case cfg.MetricsToken != "":
+		return "main port /metrics (token set)"
+	default:
+		return "off"
+	}
+}
+
+// fieldEncryptionStatus reports the active key ID only, never key material.
+func fieldEncryptionStatus(entries []string) string {
+	if len(entries) == 0 {
+		return "off"
+	}
+	keys, err := fieldcrypt.ParseKeyring(entries)
+	if err != nil {
+		return "invalid"
+	}
+	return fmt.Sprintf("on (active=%s, keys=%d)", keys.ActiveKeyID(), len(entries))
+}
+
 func nonEmptyOrDash(v string) string {
 	if strings.TrimSpace(v) == "" {
 		return "-"
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	_ "github.com/joho/godotenv/autoload"
+
+	"github.com/fdg312/health-hub/internal/config"
+	"github.com/fdg312/health-hub/internal/dbmigrate"
+	"github.com/fdg312/health-hub/internal/fieldcrypt"
+	"github.com/fdg312/health-hub/internal/storage/postgres"
+)
+
+// reencrypt moves every encrypted column onto the active master key
+// (the first entry of FIELD_ENCRYPTION_KEYS):
+//   - plaintext rows written before encryption was enabled are encrypted;
+//   - rows under an older key get their data key re-wrapped.
+//
+// Key rotation: prepend the new key to FIELD_ENCRYPTION_KEYS, deploy, run
+// this command, then drop the old key once -dry-run reports nothing pending.
+func main() {
+	batchSize := flag.Int("batch", 500, "rows per transaction")
+	dryRun := flag.Bool("dry-run", false, "only report how many rows need work")
+	flag.Parse()
+
+	cfg := config.Load()
+	keys, err := fieldcrypt.ParseKeyring(cfg.FieldEncryptionKeys)
+	if err != nil {
+		log.Fatalf("reencrypt: FIELD_ENCRYPTION_KEYS: %v", err)
+	}
+
+	dbURL, source, warning, err := dbmigrate.SelectDatabaseURL(cfg, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if warning != "" {
+		log.Printf("WARN reencrypt: %s", warning)
+	}
+	log.Printf("reencrypt: active_key=%s using=%s dry_run=%t", keys.ActiveKeyID(), source, *dryRun)
+
+	ctx := context.Background()
+	pg, err := postgres.New(ctx, dbURL)
+	if err != nil {
+		log.Fatalf("reencrypt: connect: %v", err)
+	}
+	defer pg.Close()
+	pg.WithFieldEncryption(keys)
+
+	if *dryRun {
+		pending, err := pg.PendingReencryption(ctx)
+		if err != nil {
+			log.Fatalf("reencrypt: %v", err)
+		}
+		for _, st := range pending {
+			log.Printf("reencrypt: %-14s plaintext=%d old_key=%d", st.Table, st.Encrypted, st.Rewrapped)
+		}
+		return
+	}
+
+	stats, err := pg.ReencryptFields(ctx, *batchSize)
+	for _, st := range stats {
+		log.Printf("reencrypt: %-14s encrypted=%d rewrapped=%d", st.Table, st.Encrypted, st.Rewrapped)
+	}
+	if err != nil {
+		log.Fatalf("reencrypt: %v", err)
+	}
+	log.Printf("reencrypt: completed successfully")
+}
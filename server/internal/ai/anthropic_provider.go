@@ -0,0 +1,427 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/config"
+)
+
+const anthropicVersion = "2023-06-01"
+
+// AnthropicProvider talks to the Anthropic Messages API. It has no
+// response_format, so the reply schema is spelled out in the system prompt
+// and the result goes through the same validation as every other provider.
+type AnthropicProvider struct {
+	baseURL     string
+	apiKey      string
+	model       string
+	maxTokens   int
+	temperature float64
+	httpClient  *http.Client
+	// streamClient sends streamed replies, which may outlast httpClient's
+	// total timeout.
+	streamClient *streamClient
+}
+
+func NewAnthropicProvider(cfg *config.Config) *AnthropicProvider {
+	timeoutSeconds := cfg.Anthropic.TimeoutSeconds
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = 20
+	}
+	return &AnthropicProvider{
+		baseURL:     strings.TrimRight(cfg.Anthropic.BaseURL, "/"),
+		apiKey:      cfg.Anthropic.APIKey,
+		model:       cfg.Anthropic.Model,
+		maxTokens:   cfg.AIMaxOutputTokens,
+		temperature: cfg.AITemperature,
+		httpClient: &http.Client{
+			Timeout: time.Duration(timeoutSeconds) * time.Second,
+		},
+		streamClient: newStreamClient(time.Duration(timeoutSeconds) * time.Second),
+	}
+}
+
+func (p *AnthropicProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyResponse, error) {
+	turn := &anthropicTurn{p: p, req: req, messages: anthropicMessages(req.Messages)}
+	if err := runToolLoop(ctx, req.Tools, turn); err != nil {
+		return ReplyResponse{}, err
+	}
+	return parseStructuredReply(turn.content), nil
+}
+
+func (p *AnthropicProvider) ReplyStream(ctx context.Context, req ReplyRequest, onDelta StreamFunc) (ReplyResponse, error) {
+	turn := &anthropicTurn{p: p, req: req, messages: anthropicMessages(req.Messages), stream: newTextStream(onDelta)}
+	if err := runToolLoop(ctx, req.Tools, turn); err != nil {
+		return ReplyResponse{}, err
+	}
+	return turn.stream.finish()
+}
+
+func (p *AnthropicProvider) Summarize(ctx context.Context, req SummaryRequest) (string, error) {
+	resp, err := p.post(ctx, anthropicRequest{
+		Model:       p.model,
+		MaxTokens:   p.maxTokens,
+		Temperature: p.temperature,
+		System:      summaryPrompt,
+		Messages: []anthropicMessage{{
+			Role:    "user",
+			Content: []anthropicBlock{{Type: "text", Text: summaryInput(req)}},
+		}},
+	}, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	blocks, usage, err := readAnthropicMessage(resp.Body)
+	reportUsage(ctx, ModeAnthropic, p.model, usage)
+	if err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for _, block := range blocks {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// ReadLabReport sends the image as a base64 image block. The Messages API
+// has no response_format, so the schema is spelled out in the prompt.
+func (p *AnthropicProvider) ReadLabReport(ctx context.Context, req LabReportRequest) ([]LabValue, error) {
+	resp, err := p.post(ctx, anthropicRequest{
+		Model:       p.model,
+		MaxTokens:   p.maxTokens,
+		Temperature: 0,
+		System:      labReportInstructions(),
+		Messages: []anthropicMessage{{
+			Role: "user",
+			Content: []anthropicBlock{{
+				Type: "image",
+				Source: &anthropicImageSource{
+					Type:      "base64",
+					MediaType: req.ContentType,
+					Data:      base64.StdEncoding.EncodeToString(req.Image),
+				},
+			}},
+		}},
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	blocks, usage, err := readAnthropicMessage(resp.Body)
+	reportUsage(ctx, ModeAnthropic, p.model, usage)
+	if err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	for _, block := range blocks {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return parseLabReport(text.String())
+}
+
+type anthropicTurn struct {
+	p        *AnthropicProvider
+	req      ReplyRequest
+	messages []anthropicMessage
+	stream   *textStream // nil for non-streaming replies
+	content  string
+}
+
+func (t *anthropicTurn) next(ctx context.Context, final bool) ([]ToolCall, error) {
+	payload := anthropicRequest{
+		Model:       t.p.model,
+		MaxTokens:   t.p.maxTokens,
+		Temperature: t.p.temperature,
+		System:      systemPrompt(t.req, true),
+		Messages:    t.messages,
+		Stream:      t.stream != nil,
+	}
+	if t.req.Tools != nil {
+		for _, spec := range t.req.Tools.Specs() {
+			payload.Tools = append(payload.Tools, anthropicTool{
+				Name:        spec.Name,
+				Description: spec.Description,
+				InputSchema: spec.Parameters,
+			})
+		}
+		if final {
+			payload.ToolChoice = &anthropicToolChoice{Type: "none"}
+		}
+	}
+
+	resp, err := t.p.post(ctx, payload, t.stream != nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var blocks []anthropicBlock
+	var usage Usage
+	if t.stream != nil {
+		blocks, usage, err = readAnthropicStream(resp.Body, t.stream)
+	} else {
+		blocks, usage, err = readAnthropicMessage(resp.Body)
+	}
+	reportUsage(ctx, ModeAnthropic, t.p.model, usage)
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	var calls []ToolCall
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			calls = append(calls, ToolCall{ID: block.ID, Name: block.Name, Arguments: block.Input})
+		}
+	}
+	t.content = text.String()
+	if len(calls) > 0 {
+		t.messages = append(t.messages, anthropicMessage{Role: "assistant", Content: nonEmptyBlocks(blocks)})
+	}
+	return calls, nil
+}
+
+func (t *anthropicTurn) addResults(_ []ToolCall, results []ToolResult) {
+	blocks := make([]anthropicBlock, 0, len(results))
+	for _, result := range results {
+		blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: result.CallID, Content: result.Content})
+	}
+	t.messages = append(t.messages, anthropicMessage{Role: "user", Content: blocks})
+}
+
+// anthropicMessages converts chat history to the strictly alternating
+// user/assistant turns the Messages API requires, starting with the user.
+func anthropicMessages(history []ChatMessage) []anthropicMessage {
+	messages := make([]anthropicMessage, 0, len(history))
+	for _, msg := range history {
+		role := strings.TrimSpace(msg.Role)
+		if (role != "user" && role != "assistant") || strings.TrimSpace(msg.Content) == "" {
+			continue
+		}
+		if len(messages) == 0 && role != "user" {
+			continue
+		}
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			last := &messages[n-1].Content[0]
+			last.Text += "\n\n" + msg.Content
+			continue
+		}
+		messages = append(messages, anthropicMessage{
+			Role:    role,
+			Content: []anthropicBlock{{Type: "text", Text: msg.Content}},
+		})
+	}
+	return messages
+}
+
+// nonEmptyBlocks drops empty text blocks, which the API rejects when the
+// assistant turn is sent back.
+func nonEmptyBlocks(blocks []anthropicBlock) []anthropicBlock {
+	kept := make([]anthropicBlock, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type == "text" && strings.TrimSpace(block.Text) == "" {
+			continue
+		}
+		if block.Type == "tool_use" && len(block.Input) == 0 {
+			block.Input = json.RawMessage(`{}`)
+		}
+		kept = append(kept, block)
+	}
+	return kept
+}
+
+func readAnthropicMessage(body io.Reader) ([]anthropicBlock, Usage, error) {
+	var parsed struct {
+		Content []anthropicBlock `json:"content"`
+		Usage   anthropicUsage   `json:"usage"`
+	}
+	if err := json.NewDecoder(body).Decode(&parsed); err != nil {
+		return nil, Usage{}, err
+	}
+	return parsed.Content, parsed.Usage.usage(), nil
+}
+
+// readAnthropicStream consumes the Messages API event stream: text deltas
+// go to stream, tool input JSON is assembled per content block. Input
+// tokens come with message_start, output tokens with message_delta.
+func readAnthropicStream(body io.Reader, stream *textStream) ([]anthropicBlock, Usage, error) {
+	var blocks []anthropicBlock
+	var inputs []string
+	var usage Usage
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return nil, usage, fmt.Errorf("anthropic stream: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			usage.InputTokens = event.Message.Usage.InputTokens
+		case "message_delta":
+			usage.OutputTokens = event.Usage.OutputTokens
+		case "content_block_start":
+			for len(blocks) <= event.Index {
+				blocks = append(blocks, anthropicBlock{})
+				inputs = append(inputs, "")
+			}
+			blocks[event.Index] = event.ContentBlock
+			if event.ContentBlock.Type == "tool_use" {
+				// The start event carries an empty input; deltas hold the real one.
+				blocks[event.Index].Input = json.RawMessage(`{}`)
+			}
+		case "content_block_delta":
+			if event.Index >= len(blocks) {
+				continue
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				blocks[event.Index].Text += event.Delta.Text
+				if err := stream.write(event.Delta.Text); err != nil {
+					return nil, usage, err
+				}
+			case "input_json_delta":
+				inputs[event.Index] += event.Delta.PartialJSON
+			}
+		case "message_stop":
+			for i := range blocks {
+				if blocks[i].Type == "tool_use" && inputs[i] != "" {
+					blocks[i].Input = json.RawMessage(inputs[i])
+				}
+			}
+			return blocks, usage, nil
+		case "error":
+			return nil, usage, fmt.Errorf("anthropic stream error: %s", event.Error.Message)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, usage, err
+	}
+	return nil, usage, fmt.Errorf("anthropic stream ended without message_stop")
+}
+
+func (p *AnthropicProvider) post(ctx context.Context, payload anthropicRequest, stream bool) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	do := p.httpClient.Do
+	if stream {
+		do = p.streamClient.Do
+	}
+	resp, err := do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, &StatusError{Provider: ModeAnthropic, StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+type anthropicRequest struct {
+	Model       string               `json:"model"`
+	MaxTokens   int                  `json:"max_tokens"`
+	Temperature float64              `json:"temperature"`
+	System      string               `json:"system"`
+	Messages    []anthropicMessage   `json:"messages"`
+	Stream      bool                 `json:"stream,omitempty"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock covers the text, image, tool_use and tool_result content
+// blocks.
+type anthropicBlock struct {
+	Type      string                `json:"type"`
+	Text      string                `json:"text,omitempty"`
+	Source    *anthropicImageSource `json:"source,omitempty"`
+	ID        string                `json:"id,omitempty"`
+	Name      string                `json:"name,omitempty"`
+	Input     json.RawMessage       `json:"input,omitempty"`
+	ToolUseID string                `json:"tool_use_id,omitempty"`
+	Content   string                `json:"content,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+}
+
+type anthropicStreamEvent struct {
+	Type         string         `json:"type"`
+	Index        int            `json:"index"`
+	ContentBlock anthropicBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Usage anthropicUsage `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (u anthropicUsage) usage() Usage {
+	return Usage{InputTokens: u.InputTokens, OutputTokens: u.OutputTokens}
+}
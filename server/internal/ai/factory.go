@@ -1,25 +1,50 @@
 package ai
 
 import (
-	"strings"
+	"time"
 
 	"github.com/fdg312/health-hub/internal/config"
+	"github.com/fdg312/health-hub/internal/telemetry"
 )
 
 const (
-	ModeMock   = "mock"
-	ModeOpenAI = "openai"
+	ModeMock             = config.AIModeMock
+	ModeOpenAI           = config.AIModeOpenAI
+	ModeAnthropic        = config.AIModeAnthropic
+	ModeOllama           = config.AIModeOllama
+	ModeOpenAICompatible = config.AIModeOpenAICompatible
 )
 
-func NewProvider(cfg *config.Config) Provider {
-	mode := strings.ToLower(strings.TrimSpace(cfg.AIMode))
-	if mode == "" {
-		mode = ModeMock
+// NewProvider builds AI_MODE followed by the AI_FALLBACK providers. Every
+// provider is instrumented under its mode name and, except the mock,
+// retried on transient failures before the chain moves on. Personal data
+// is masked per AI_REDACT before any of them is called, and replies pass
+// the safety filter.
+func NewProvider(cfg *config.Config, m *telemetry.Metrics) Provider {
+	backoff := time.Duration(cfg.AIRetryBackoffMs) * time.Millisecond
+
+	modes := append([]string{cfg.AIMode}, cfg.AIFallback...)
+	chain := make([]ChainEntry, 0, len(modes))
+	for _, mode := range modes {
+		p := Instrument(newModeProvider(cfg, mode), mode, m)
+		if mode != ModeMock {
+			p = WithRetry(p, cfg.AIMaxRetries, backoff)
+		}
+		chain = append(chain, ChainEntry{Name: mode, Provider: p})
 	}
+	return WithSafetyFilter(WithRedaction(Fallback(chain...), NewRedactionPolicy(cfg.AIRedact)))
+}
 
+func newModeProvider(cfg *config.Config, mode string) Provider {
 	switch mode {
 	case ModeOpenAI:
 		return NewOpenAIProvider(cfg)
+	case ModeAnthropic:
+		return NewAnthropicProvider(cfg)
+	case ModeOllama:
+		return NewOllamaProvider(cfg)
+	case ModeOpenAICompatible:
+		return NewOpenAICompatibleProvider(cfg)
 	default:
 		return NewMockProvider()
 	}
@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fdg312/health-hub/internal/logging"
+)
+
+// ChainEntry is one provider of a fallback chain.
+type ChainEntry struct {
+	Name     string
+	Provider Provider
+}
+
+type fallbackProvider struct {
+	chain []ChainEntry
+}
+
+// Fallback tries the providers in order until one succeeds. Cancellation
+// stops the chain, and so does a failure after a stream has started: the
+// client has already seen part of that reply.
+func Fallback(chain ...ChainEntry) Provider {
+	if len(chain) == 1 {
+		return chain[0].Provider
+	}
+	return &fallbackProvider{chain: chain}
+}
+
+func (p *fallbackProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyResponse, error) {
+	errs := make([]error, 0, len(p.chain))
+	for i, entry := range p.chain {
+		resp, err := entry.Provider.Reply(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return ReplyResponse{}, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", entry.Name, err))
+		p.logFailure(ctx, i, err)
+	}
+	return ReplyResponse{}, errors.Join(errs...)
+}
+
+func (p *fallbackProvider) ReplyStream(ctx context.Context, req ReplyRequest, onDelta StreamFunc) (ReplyResponse, error) {
+	started := false
+	tracked := func(delta string) error {
+		started = true
+		return onDelta(delta)
+	}
+
+	errs := make([]error, 0, len(p.chain))
+	for i, entry := range p.chain {
+		resp, err := entry.Provider.ReplyStream(ctx, req, tracked)
+		if err == nil {
+			return resp, nil
+		}
+		if started || ctx.Err() != nil {
+			return ReplyResponse{}, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", entry.Name, err))
+		p.logFailure(ctx, i, err)
+	}
+	return ReplyResponse{}, errors.Join(errs...)
+}
+
+func (p *fallbackProvider) Summarize(ctx context.Context, req SummaryRequest) (string, error) {
+	errs := make([]error, 0, len(p.chain))
+	for i, entry := range p.chain {
+		summary, err := entry.Provider.Summarize(ctx, req)
+		if err == nil {
+			return summary, nil
+		}
+		if ctx.Err() != nil {
+			return "", err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", entry.Name, err))
+		p.logFailure(ctx, i, err)
+	}
+	return "", errors.Join(errs...)
+}
+
+func (p *fallbackProvider) ReadLabReport(ctx context.Context, req LabReportRequest) ([]LabValue, error) {
+	errs := make([]error, 0, len(p.chain))
+	for i, entry := range p.chain {
+		values, err := entry.Provider.ReadLabReport(ctx, req)
+		if err == nil {
+			return values, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", entry.Name, err))
+		p.logFailure(ctx, i, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (p *fallbackProvider) logFailure(ctx context.Context, i int, err error) {
+	if i+1 < len(p.chain) {
+		logging.FromContext(ctx).Warn("ai provider failed, falling back",
+			"provider", p.chain[i].Name, "next", p.chain[i+1].Name, "error", err)
+	}
+}
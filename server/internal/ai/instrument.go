@@ -0,0 +1,74 @@
+package ai
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/fdg312/health-hub/internal/telemetry"
+)
+
+// instrumentedProvider records a span plus latency and error metrics for
+// every call of the wrapped provider.
+type instrumentedProvider struct {
+	next    Provider
+	name    string
+	metrics *telemetry.Metrics
+}
+
+// Instrument wraps p; name labels spans and metrics (mock, openai, ...).
+func Instrument(p Provider, name string, m *telemetry.Metrics) Provider {
+	return &instrumentedProvider{next: p, name: name, metrics: m}
+}
+
+func (p *instrumentedProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyResponse, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ai.Reply",
+		attribute.String("ai.provider", p.name),
+		attribute.Int("ai.messages", len(req.Messages)),
+	)
+	start := time.Now()
+	resp, err := p.next.Reply(ctx, req)
+	p.metrics.ObserveAIRequest(p.name, time.Since(start), err)
+	span.SetAttributes(attribute.Int("ai.proposals", len(resp.Proposals)))
+	telemetry.EndSpan(span, err)
+	return resp, err
+}
+
+func (p *instrumentedProvider) ReplyStream(ctx context.Context, req ReplyRequest, onDelta StreamFunc) (ReplyResponse, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ai.ReplyStream",
+		attribute.String("ai.provider", p.name),
+		attribute.Int("ai.messages", len(req.Messages)),
+	)
+	start := time.Now()
+	resp, err := p.next.ReplyStream(ctx, req, onDelta)
+	p.metrics.ObserveAIRequest(p.name, time.Since(start), err)
+	span.SetAttributes(attribute.Int("ai.proposals", len(resp.Proposals)))
+	telemetry.EndSpan(span, err)
+	return resp, err
+}
+
+func (p *instrumentedProvider) Summarize(ctx context.Context, req SummaryRequest) (string, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ai.Summarize",
+		attribute.String("ai.provider", p.name),
+		attribute.Int("ai.messages", len(req.Messages)),
+	)
+	start := time.Now()
+	summary, err := p.next.Summarize(ctx, req)
+	p.metrics.ObserveAIRequest(p.name, time.Since(start), err)
+	telemetry.EndSpan(span, err)
+	return summary, err
+}
+
+func (p *instrumentedProvider) ReadLabReport(ctx context.Context, req LabReportRequest) ([]LabValue, error) {
+	ctx, span := telemetry.StartSpan(ctx, "ai.ReadLabReport",
+		attribute.String("ai.provider", p.name),
+		attribute.Int("ai.image_bytes", len(req.Image)),
+	)
+	start := time.Now()
+	values, err := p.next.ReadLabReport(ctx, req)
+	p.metrics.ObserveAIRequest(p.name, time.Since(start), err)
+	span.SetAttributes(attribute.Int("ai.lab_values", len(values)))
+	telemetry.EndSpan(span, err)
+	return values, err
+}
@@ -0,0 +1,94 @@
+package ai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// LabReportRequest is a photo or scan of a lab report (JPEG or PNG).
+// AllowUnredacted records that the user agreed to send the photo with the
+// personal data printed on it; WithRedaction refuses it otherwise.
+type LabReportRequest struct {
+	Image           []byte
+	ContentType     string
+	AllowUnredacted bool
+}
+
+// LabValue is one analyte read from a lab report, as printed on it.
+type LabValue struct {
+	Name    string   `json:"name"`
+	Value   float64  `json:"value"`
+	Unit    string   `json:"unit"`
+	RefLow  *float64 `json:"ref_low"`
+	RefHigh *float64 `json:"ref_high"`
+	// Date is the sampling date (YYYY-MM-DD) when the report shows one.
+	Date string `json:"date"`
+}
+
+const labReportPrompt = "Ты извлекаешь результаты анализов с фото бланка лаборатории. " +
+	"Перепиши каждый показатель с числовым результатом: name — название как на бланке, value — число " +
+	"(десятичный разделитель — точка), unit — единицы как на бланке (пустая строка, если их нет), " +
+	"ref_low и ref_high — границы референсного интервала (null, если границы нет; для «< 5» ref_low = null, ref_high = 5), " +
+	"date — дата взятия материала в формате YYYY-MM-DD или пустая строка. " +
+	"Не придумывай показатели и значения, которых нет на изображении; качественные результаты («не обнаружено») пропускай. " +
+	"Если на изображении нет результатов анализов, верни пустой массив values."
+
+// labReportSchema is the response_format schema of ReadLabReport.
+func labReportSchema() map[string]any {
+	return strictObject(map[string]any{
+		"values": map[string]any{
+			"type":     "array",
+			"maxItems": 200,
+			"items": strictObject(map[string]any{
+				"name":     textUpTo(200),
+				"value":    map[string]any{"type": "number"},
+				"unit":     map[string]any{"type": "string"},
+				"ref_low":  map[string]any{"type": []string{"number", "null"}},
+				"ref_high": map[string]any{"type": []string{"number", "null"}},
+				"date":     map[string]any{"type": "string", "pattern": `^(\d{4}-\d{2}-\d{2})?$`},
+			}, "name", "value", "unit", "ref_low", "ref_high", "date"),
+		},
+	}, "values")
+}
+
+// labReportInstructions is labReportPrompt with the schema spelled out, for
+// providers without native structured outputs.
+func labReportInstructions() string {
+	schema, _ := json.Marshal(labReportSchema())
+	return labReportPrompt + " Верни только JSON-объект без markdown и пояснений вокруг, строго по JSON Schema: " + string(schema)
+}
+
+// parseLabReport validates a ReadLabReport reply against labReportSchema.
+func parseLabReport(content string) ([]LabValue, error) {
+	content = strings.TrimSpace(content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimSuffix(strings.TrimPrefix(content, "```"), "```")
+
+	var decoded map[string]any
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("lab report reply is not JSON: %w", err)
+	}
+	if err := validateSchema(labReportSchema(), decoded, "lab_report"); err != nil {
+		return nil, fmt.Errorf("lab report reply: %w", err)
+	}
+
+	var parsed struct {
+		Values []LabValue `json:"values"`
+	}
+	if err := json.Unmarshal([]byte(content), &parsed); err != nil {
+		return nil, err
+	}
+	for i := range parsed.Values {
+		parsed.Values[i].Name = strings.TrimSpace(parsed.Values[i].Name)
+		parsed.Values[i].Unit = strings.TrimSpace(parsed.Values[i].Unit)
+	}
+	return parsed.Values, nil
+}
+
+func imageDataURL(req LabReportRequest) string {
+	return "data:" + req.ContentType + ";base64," + base64.StdEncoding.EncodeToString(req.Image)
+}
@@ -13,8 +13,6 @@ func NewMockProvider() *MockProvider {
 }
 
 func (p *MockProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyResponse, error) {
-	_ = ctx
-
 	lastUserMessage := ""
 	for i := len(req.Messages) - 1; i >= 0; i-- {
 		if req.Messages[i].Role == "user" {
@@ -31,9 +29,39 @@ func (p *MockProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyRespon
 		"Это демо-режим, рекомендации не являются медицинским заключением.",
 	)
 
+	history, err := mockHistory(ctx, req, lastUserMessage)
+	if err != nil {
+		return ReplyResponse{}, err
+	}
+	if history != "" {
+		text += " " + history
+	}
+
 	proposals := make([]ProposalDraft, 0, 1)
 	lowered := strings.ToLower(lastUserMessage)
-	if strings.Contains(lowered, "витамин") ||
+	if fact := mockFact(lastUserMessage); fact != "" {
+		proposals = append(proposals, ProposalDraft{
+			Kind:    KindMemory,
+			Title:   "Запомнить о вас",
+			Summary: "Сохраню это в памяти ассистента, если подтвердите.",
+			Payload: map[string]any{"fact": fact},
+		})
+	} else if strings.Contains(lowered, "похуд") ||
+		strings.Contains(lowered, "сбросить") ||
+		strings.Contains(lowered, "цель") {
+		proposals = append(proposals, ProposalDraft{
+			Kind:    KindCoachingProgram,
+			Title:   "Программа: минус 5 кг",
+			Summary: "Составлю программу с еженедельными вехами и проверкой прогресса. Применение вручную.",
+			Payload: map[string]any{
+				"title":          "Минус 5 кг за 10 недель",
+				"metric":         "weight_kg",
+				"target_value":   nil,
+				"target_change":  -5,
+				"duration_weeks": 10,
+			},
+		})
+	} else if strings.Contains(lowered, "витамин") ||
 		strings.Contains(lowered, "добавк") ||
 		strings.Contains(lowered, "расписани") {
 		proposals = append(proposals, ProposalDraft{
@@ -67,9 +95,18 @@ func (p *MockProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyRespon
 			Kind:    "settings_update",
 			Title:   "Подкорректировать пороги активности",
 			Summary: "Предлагаю обновить пороги шагов и сна. Изменения применяются только вручную.",
+			// Same shape as a strict structured output: unchanged fields are null.
 			Payload: map[string]any{
-				"min_steps":         8000,
-				"min_sleep_minutes": 450,
+				"time_zone":                    nil,
+				"quiet_start_minutes":          nil,
+				"quiet_end_minutes":            nil,
+				"notifications_max_per_day":    nil,
+				"min_sleep_minutes":            450,
+				"min_steps":                    8000,
+				"min_active_energy_kcal":       nil,
+				"morning_checkin_time_minutes": nil,
+				"evening_checkin_time_minutes": nil,
+				"vitamins_time_minutes":        nil,
 			},
 		})
 	} else if strings.Contains(lowered, "тренир") ||
@@ -94,7 +131,6 @@ func (p *MockProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyRespon
 						"duration_min": 30,
 						"intensity":    "medium",
 						"note":         "лёгкий темп",
-						"details":      map[string]any{},
 					},
 					{
 						"kind":         "strength",
@@ -103,7 +139,6 @@ func (p *MockProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyRespon
 						"duration_min": 40,
 						"intensity":    "high",
 						"note":         "верх тела",
-						"details":      map[string]any{},
 					},
 					{
 						"kind":         "core",
@@ -112,7 +147,6 @@ func (p *MockProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyRespon
 						"duration_min": 15,
 						"intensity":    "medium",
 						"note":         "планка и пресс",
-						"details":      map[string]any{},
 					},
 				},
 			},
@@ -206,8 +240,89 @@ func (p *MockProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyRespon
 		})
 	}
 
+	reportUsage(ctx, ModeMock, "mock", Usage{
+		InputTokens:  estimateTokens(lastUserMessage),
+		OutputTokens: estimateTokens(text),
+	})
 	return ReplyResponse{
 		AssistantText: text,
 		Proposals:     proposals,
 	}, nil
 }
+
+// mockFact extracts the text after "запомни" (any case) as a memory fact.
+func mockFact(message string) string {
+	lowered := strings.ToLower(message)
+	i := strings.Index(lowered, "запомни")
+	if i < 0 || i+len("запомни") > len(message) {
+		return ""
+	}
+	// strings.ToLower keeps byte offsets for Cyrillic, so i indexes message too.
+	fact := strings.Trim(message[i+len("запомни"):], " :,.-—")
+	runes := []rune(fact)
+	if len(runes) > 200 {
+		fact = string(runes[:200])
+	}
+	return fact
+}
+
+// ReplyStream emits the canned reply word by word.
+func (p *MockProvider) ReplyStream(ctx context.Context, req ReplyRequest, onDelta StreamFunc) (ReplyResponse, error) {
+	reply, err := p.Reply(ctx, req)
+	if err != nil {
+		return ReplyResponse{}, err
+	}
+	for _, chunk := range strings.SplitAfter(reply.AssistantText, " ") {
+		if err := ctx.Err(); err != nil {
+			return ReplyResponse{}, err
+		}
+		if err := onDelta(chunk); err != nil {
+			return ReplyResponse{}, err
+		}
+	}
+	return reply, nil
+}
+
+// mockSummaryRunes caps the mock summary so it stays within the history budget.
+const mockSummaryRunes = 1500
+
+// Summarize keeps the previous summary and the start of each message.
+func (p *MockProvider) Summarize(ctx context.Context, req SummaryRequest) (string, error) {
+	parts := make([]string, 0, len(req.Messages)+1)
+	if req.Previous != "" {
+		parts = append(parts, req.Previous)
+	}
+	for _, msg := range req.Messages {
+		content := []rune(strings.TrimSpace(msg.Content))
+		if len(content) > 80 {
+			content = append(content[:80], '…')
+		}
+		parts = append(parts, msg.Role+": "+string(content))
+	}
+
+	summary := []rune(strings.Join(parts, "\n"))
+	if len(summary) > mockSummaryRunes {
+		summary = summary[len(summary)-mockSummaryRunes:]
+	}
+	reportUsage(ctx, ModeMock, "mock", Usage{
+		InputTokens:  estimateTokens(summaryInput(req)),
+		OutputTokens: estimateTokens(string(summary)),
+	})
+	return string(summary), nil
+}
+
+// ReadLabReport returns the same two values for any image, so the lab
+// flow can be tried without a vision model.
+func (p *MockProvider) ReadLabReport(ctx context.Context, req LabReportRequest) ([]LabValue, error) {
+	ferritinLow, ferritinHigh := 20.0, 250.0
+	vitaminDLow, vitaminDHigh := 30.0, 100.0
+	values := []LabValue{
+		{Name: "Ферритин", Value: 45, Unit: "нг/мл", RefLow: &ferritinLow, RefHigh: &ferritinHigh},
+		{Name: "Витамин D (25-OH)", Value: 28, Unit: "нг/мл", RefLow: &vitaminDLow, RefHigh: &vitaminDHigh},
+	}
+	reportUsage(ctx, ModeMock, "mock", Usage{
+		InputTokens:  len(req.Image)/750 + 1,
+		OutputTokens: 40,
+	})
+	return values, nil
+}
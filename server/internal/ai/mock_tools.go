@@ -0,0 +1,140 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// historyKeywords mark questions about the past; only these make the mock
+// call tools.
+var historyKeywords = []string{"месяц", "недел", "тренд", "динамик", "последн", "истори"}
+
+// mockToolKeywords maps message keywords to the tool the mock calls.
+var mockToolKeywords = []struct {
+	tool     string
+	keywords []string
+}{
+	{ToolGetDailyMetrics, []string{"сон", "спал", "шаг", "активн"}},
+	{ToolListCheckins, []string{"самочувств", "чекин", "настроени"}},
+	{ToolGetSupplementAdherence, []string{"витамин", "добавк"}},
+	{ToolListWorkoutCompletions, []string{"тренир"}},
+	{ToolGetMealPlan, []string{"рацион", "меню", "план питания"}},
+	{ToolGetNutritionTargets, []string{"калори", "белк", "кбжу"}},
+	{ToolGetCycle, []string{"цикл", "месячн"}},
+}
+
+var rangeTools = map[string]bool{
+	ToolGetDailyMetrics:        true,
+	ToolListCheckins:           true,
+	ToolGetSupplementAdherence: true,
+	ToolListWorkoutCompletions: true,
+	ToolGetCycle:               true,
+}
+
+// mockToolTurn issues one round of tool calls and then answers.
+type mockToolTurn struct {
+	calls   []ToolCall
+	results []ToolResult
+	called  bool
+}
+
+func (t *mockToolTurn) next(_ context.Context, final bool) ([]ToolCall, error) {
+	if t.called || final {
+		return nil, nil
+	}
+	t.called = true
+	return t.calls, nil
+}
+
+func (t *mockToolTurn) addResults(_ []ToolCall, results []ToolResult) {
+	t.results = results
+}
+
+// mockHistory runs the tools matching the last user message through the
+// regular tool loop and returns a deterministic summary ("" when no tool
+// applies).
+func mockHistory(ctx context.Context, req ReplyRequest, lastUserMessage string) (string, error) {
+	if req.Tools == nil {
+		return "", nil
+	}
+	lowered := strings.ToLower(lastUserMessage)
+	if !containsAny(lowered, historyKeywords) {
+		return "", nil
+	}
+
+	available := make(map[string]bool)
+	for _, spec := range req.Tools.Specs() {
+		available[spec.Name] = true
+	}
+
+	to := req.Snapshot.Date
+	if _, err := time.Parse("2006-01-02", to); err != nil {
+		to = time.Now().UTC().Format("2006-01-02")
+	}
+	days := 30
+	if strings.Contains(lowered, "недел") {
+		days = 7
+	}
+	toDate, _ := time.Parse("2006-01-02", to)
+	from := toDate.AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+	rangeArgs, _ := json.Marshal(map[string]string{"from": from, "to": to})
+
+	turn := &mockToolTurn{}
+	for _, entry := range mockToolKeywords {
+		if !available[entry.tool] || !containsAny(lowered, entry.keywords) {
+			continue
+		}
+		args := json.RawMessage(`{}`)
+		if rangeTools[entry.tool] {
+			args = rangeArgs
+		}
+		turn.calls = append(turn.calls, ToolCall{
+			ID:        fmt.Sprintf("mock_call_%d", len(turn.calls)+1),
+			Name:      entry.tool,
+			Arguments: args,
+		})
+	}
+	if len(turn.calls) == 0 {
+		return "", nil
+	}
+
+	if err := runToolLoop(ctx, req.Tools, turn); err != nil {
+		return "", err
+	}
+
+	parts := make([]string, 0, len(turn.results))
+	for _, result := range turn.results {
+		parts = append(parts, result.Name+" — "+summarizeToolResult(result.Content))
+	}
+	return fmt.Sprintf("История %s…%s: %s.", from, to, strings.Join(parts, "; ")), nil
+}
+
+// summarizeToolResult describes a tool result by the size of its first
+// list, e.g. "12 записей".
+func summarizeToolResult(content string) string {
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(content), &obj); err != nil {
+		return "данные получены"
+	}
+	if msg, ok := obj["error"].(string); ok {
+		return "ошибка: " + msg
+	}
+	for _, v := range obj {
+		if list, ok := v.([]any); ok {
+			return fmt.Sprintf("%d записей", len(list))
+		}
+	}
+	return "данные получены"
+}
+
+func containsAny(s string, needles []string) bool {
+	for _, needle := range needles {
+		if strings.Contains(s, needle) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,313 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/config"
+)
+
+// OllamaProvider talks to a local Ollama server through its native
+// /api/chat endpoint, so health data never leaves the deployment.
+// llama.cpp and other local servers with an OpenAI-style API use
+// NewOpenAICompatibleProvider instead.
+type OllamaProvider struct {
+	baseURL     string
+	model       string
+	maxTokens   int
+	temperature float64
+	httpClient  *http.Client
+	// streamClient sends streamed replies, which may outlast httpClient's
+	// total timeout.
+	streamClient *streamClient
+}
+
+func NewOllamaProvider(cfg *config.Config) *OllamaProvider {
+	timeoutSeconds := cfg.Ollama.TimeoutSeconds
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = 60
+	}
+	return &OllamaProvider{
+		baseURL:     strings.TrimRight(cfg.Ollama.BaseURL, "/"),
+		model:       cfg.Ollama.Model,
+		maxTokens:   cfg.AIMaxOutputTokens,
+		temperature: cfg.AITemperature,
+		httpClient: &http.Client{
+			Timeout: time.Duration(timeoutSeconds) * time.Second,
+		},
+		streamClient: newStreamClient(time.Duration(timeoutSeconds) * time.Second),
+	}
+}
+
+func (p *OllamaProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyResponse, error) {
+	turn := &ollamaTurn{p: p, req: req, messages: p.buildMessages(req)}
+	if err := runToolLoop(ctx, req.Tools, turn); err != nil {
+		return ReplyResponse{}, err
+	}
+	return parseStructuredReply(turn.content), nil
+}
+
+// ReplyStream reads Ollama's newline-delimited JSON stream.
+func (p *OllamaProvider) ReplyStream(ctx context.Context, req ReplyRequest, onDelta StreamFunc) (ReplyResponse, error) {
+	turn := &ollamaTurn{p: p, req: req, messages: p.buildMessages(req), stream: newTextStream(onDelta)}
+	if err := runToolLoop(ctx, req.Tools, turn); err != nil {
+		return ReplyResponse{}, err
+	}
+	return turn.stream.finish()
+}
+
+func (p *OllamaProvider) Summarize(ctx context.Context, req SummaryRequest) (string, error) {
+	resp, err := p.post(ctx, ollamaRequest{
+		Model: p.model,
+		Messages: []ollamaMessage{
+			{Role: "system", Content: summaryPrompt},
+			{Role: "user", Content: summaryInput(req)},
+		},
+		Options: ollamaOptions{Temperature: p.temperature, NumPredict: p.maxTokens},
+	}, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	message, usage, err := readOllamaMessage(resp.Body)
+	reportUsage(ctx, ModeOllama, p.model, usage)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(message.Content), nil
+}
+
+// ReadLabReport needs a vision model (llava, llama3.2-vision, ...); the
+// image goes in the message's images field.
+func (p *OllamaProvider) ReadLabReport(ctx context.Context, req LabReportRequest) ([]LabValue, error) {
+	resp, err := p.post(ctx, ollamaRequest{
+		Model: p.model,
+		Messages: []ollamaMessage{
+			{Role: "system", Content: labReportPrompt},
+			{Role: "user", Content: "Извлеки показатели с изображения.", Images: []string{base64.StdEncoding.EncodeToString(req.Image)}},
+		},
+		Format:  labReportSchema(),
+		Options: ollamaOptions{Temperature: 0, NumPredict: p.maxTokens},
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	message, usage, err := readOllamaMessage(resp.Body)
+	reportUsage(ctx, ModeOllama, p.model, usage)
+	if err != nil {
+		return nil, err
+	}
+	return parseLabReport(message.Content)
+}
+
+type ollamaTurn struct {
+	p        *OllamaProvider
+	req      ReplyRequest
+	messages []ollamaMessage
+	stream   *textStream // nil for non-streaming replies
+	content  string
+	round    int
+}
+
+// next constrains the output with format only when tools are off: Ollama
+// cannot call tools under a format constraint, so tool rounds rely on the
+// schema in the system prompt.
+func (t *ollamaTurn) next(ctx context.Context, final bool) ([]ToolCall, error) {
+	t.round++
+	payload := ollamaRequest{
+		Model:    t.p.model,
+		Messages: t.messages,
+		Stream:   t.stream != nil,
+		Options:  ollamaOptions{Temperature: t.p.temperature, NumPredict: t.p.maxTokens},
+	}
+	if t.req.Tools != nil && !final {
+		payload.Tools = openAITools(t.req.Tools.Specs())
+	} else {
+		payload.Format = replySchema()
+	}
+
+	resp, err := t.p.post(ctx, payload, t.stream != nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var message ollamaMessage
+	var usage Usage
+	if t.stream != nil {
+		message, usage, err = readOllamaStream(resp.Body, t.stream)
+	} else {
+		message, usage, err = readOllamaMessage(resp.Body)
+	}
+	reportUsage(ctx, ModeOllama, t.p.model, usage)
+	if err != nil {
+		return nil, err
+	}
+	t.content = message.Content
+
+	calls := make([]ToolCall, 0, len(message.ToolCalls))
+	for i, tc := range message.ToolCalls {
+		args := tc.Function.Arguments
+		if len(args) == 0 {
+			args = json.RawMessage(`{}`)
+		}
+		calls = append(calls, ToolCall{
+			// Ollama has no call IDs; results are matched by tool name.
+			ID:        fmt.Sprintf("call_%d_%d", t.round, i),
+			Name:      tc.Function.Name,
+			Arguments: args,
+		})
+	}
+	if len(calls) > 0 {
+		t.messages = append(t.messages, message)
+	}
+	return calls, nil
+}
+
+func (t *ollamaTurn) addResults(_ []ToolCall, results []ToolResult) {
+	for _, result := range results {
+		t.messages = append(t.messages, ollamaMessage{Role: "tool", Content: result.Content, ToolName: result.Name})
+	}
+}
+
+func (p *OllamaProvider) buildMessages(req ReplyRequest) []ollamaMessage {
+	messages := make([]ollamaMessage, 0, len(req.Messages)+1)
+	messages = append(messages, ollamaMessage{Role: "system", Content: systemPrompt(req, true)})
+	for _, msg := range req.Messages {
+		role := strings.TrimSpace(msg.Role)
+		if role == "" {
+			continue
+		}
+		messages = append(messages, ollamaMessage{Role: role, Content: msg.Content})
+	}
+	return messages
+}
+
+func readOllamaMessage(body io.Reader) (ollamaMessage, Usage, error) {
+	var parsed ollamaResponse
+	if err := json.NewDecoder(body).Decode(&parsed); err != nil {
+		return ollamaMessage{}, Usage{}, err
+	}
+	if parsed.Error != "" {
+		return ollamaMessage{}, Usage{}, fmt.Errorf("ollama error: %s", parsed.Error)
+	}
+	return parsed.Message, parsed.usage(), nil
+}
+
+// readOllamaStream consumes newline-delimited chunks; token counts come
+// with the final done chunk.
+func readOllamaStream(body io.Reader, stream *textStream) (ollamaMessage, Usage, error) {
+	message := ollamaMessage{Role: "assistant"}
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var chunk ollamaResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return ollamaMessage{}, Usage{}, fmt.Errorf("ollama stream: %w", err)
+		}
+		if chunk.Error != "" {
+			return ollamaMessage{}, Usage{}, fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+		message.ToolCalls = append(message.ToolCalls, chunk.Message.ToolCalls...)
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if err := stream.write(chunk.Message.Content); err != nil {
+				return ollamaMessage{}, Usage{}, err
+			}
+		}
+		if chunk.Done {
+			message.Content = content.String()
+			return message, chunk.usage(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return ollamaMessage{}, Usage{}, err
+	}
+	return ollamaMessage{}, Usage{}, fmt.Errorf("ollama stream ended without done")
+}
+
+func (p *OllamaProvider) post(ctx context.Context, payload ollamaRequest, stream bool) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	do := p.httpClient.Do
+	if stream {
+		do = p.streamClient.Do
+	}
+	resp, err := do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, &StatusError{Provider: ModeOllama, StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Tools    []openAITool    `json:"tools,omitempty"`
+	Format   map[string]any  `json:"format,omitempty"`
+	Options  ollamaOptions   `json:"options"`
+}
+
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+	// Images are base64-encoded, for vision models.
+	Images []string `json:"images,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	Error           string        `json:"error"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+func (r ollamaResponse) usage() Usage {
+	return Usage{InputTokens: r.PromptEvalCount, OutputTokens: r.EvalCount}
+}
@@ -1,6 +1,7 @@
 package ai
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -14,86 +15,327 @@ import (
 )
 
 type OpenAIProvider struct {
+	name        string // openai or openai_compatible, used in errors
+	baseURL     string
 	apiKey      string
 	model       string
 	maxTokens   int
 	temperature float64
 	httpClient  *http.Client
+	// streamClient sends streamed replies, which may outlast httpClient's
+	// total timeout.
+	streamClient *streamClient
 }
 
 func NewOpenAIProvider(cfg *config.Config) *OpenAIProvider {
-	timeoutSeconds := cfg.AITimeoutSeconds
+	return newOpenAIProvider(ModeOpenAI, "https://api.openai.com/v1", cfg.OpenAIAPIKey, cfg.OpenAIModel, cfg.OpenAITimeoutSeconds, cfg)
+}
+
+// NewOpenAICompatibleProvider talks the same chat completions protocol to
+// OPENAI_COMPATIBLE_BASE_URL (vLLM, llama.cpp server, LM Studio, ...).
+// The API key is optional for local servers.
+func NewOpenAICompatibleProvider(cfg *config.Config) *OpenAIProvider {
+	c := cfg.OpenAICompatible
+	return newOpenAIProvider(ModeOpenAICompatible, c.BaseURL, c.APIKey, c.Model, c.TimeoutSeconds, cfg)
+}
+
+func newOpenAIProvider(name, baseURL, apiKey, model string, timeoutSeconds int, cfg *config.Config) *OpenAIProvider {
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = cfg.AITimeoutSeconds
+	}
 	if timeoutSeconds <= 0 {
 		timeoutSeconds = 20
 	}
 
 	return &OpenAIProvider{
-		apiKey:      cfg.OpenAIAPIKey,
-		model:       cfg.OpenAIModel,
+		name:        name,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		apiKey:      apiKey,
+		model:       model,
 		maxTokens:   cfg.AIMaxOutputTokens,
 		temperature: cfg.AITemperature,
 		httpClient: &http.Client{
 			Timeout: time.Duration(timeoutSeconds) * time.Second,
 		},
+		streamClient: newStreamClient(time.Duration(timeoutSeconds) * time.Second),
 	}
 }
 
 func (p *OpenAIProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyResponse, error) {
-	requestPayload := chatCompletionsRequest{
+	turn := &openAITurn{p: p, req: req, messages: p.buildMessages(req)}
+	if err := runToolLoop(ctx, req.Tools, turn); err != nil {
+		return ReplyResponse{}, err
+	}
+
+	return parseStructuredReply(turn.content), nil
+}
+
+// ReplyStream requests stream=true and forwards content deltas from the
+// server-sent events as they arrive. Tool rounds are streamed too; their
+// tool call fragments are assembled before the tools run.
+func (p *OpenAIProvider) ReplyStream(ctx context.Context, req ReplyRequest, onDelta StreamFunc) (ReplyResponse, error) {
+	turn := &openAITurn{p: p, req: req, messages: p.buildMessages(req), stream: newTextStream(onDelta)}
+	if err := runToolLoop(ctx, req.Tools, turn); err != nil {
+		return ReplyResponse{}, err
+	}
+	return turn.stream.finish()
+}
+
+// Summarize is a plain completion: no tools and no response_format.
+func (p *OpenAIProvider) Summarize(ctx context.Context, req SummaryRequest) (string, error) {
+	resp, err := p.post(ctx, chatCompletionsRequest{
 		Model:       p.model,
 		Temperature: p.temperature,
 		MaxTokens:   p.maxTokens,
-		Messages:    p.buildMessages(req),
+		Messages: []chatMessageRequest{
+			{Role: "system", Content: summaryPrompt},
+			{Role: "user", Content: summaryInput(req)},
+		},
+	}, false)
+	if err != nil {
+		return "", err
 	}
+	defer resp.Body.Close()
 
-	body, err := json.Marshal(requestPayload)
+	message, usage, err := readCompletion(resp.Body)
+	reportUsage(ctx, p.name, p.model, usage)
 	if err != nil {
-		return ReplyResponse{}, err
+		return "", err
 	}
+	return strings.TrimSpace(message.Content), nil
+}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.openai.com/v1/chat/completions", bytes.NewReader(body))
+// ReadLabReport sends the image as a data URL in a user message and asks
+// for a reply matching labReportSchema.
+func (p *OpenAIProvider) ReadLabReport(ctx context.Context, req LabReportRequest) ([]LabValue, error) {
+	resp, err := p.post(ctx, visionCompletionsRequest{
+		Model:       p.model,
+		Temperature: 0,
+		MaxTokens:   p.maxTokens,
+		Messages: []visionMessage{
+			{Role: "system", Content: labReportPrompt},
+			{Role: "user", Content: []visionPart{
+				{Type: "image_url", ImageURL: &visionImageURL{URL: imageDataURL(req)}},
+			}},
+		},
+		ResponseFormat: &responseFormat{
+			Type:       "json_schema",
+			JSONSchema: jsonSchemaFormat{Name: "lab_report", Strict: true, Schema: labReportSchema()},
+		},
+	}, false)
 	if err != nil {
-		return ReplyResponse{}, err
+		return nil, err
 	}
-	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
-	httpReq.Header.Set("Content-Type", "application/json")
+	defer resp.Body.Close()
 
-	resp, err := p.httpClient.Do(httpReq)
+	message, usage, err := readCompletion(resp.Body)
+	reportUsage(ctx, p.name, p.model, usage)
 	if err != nil {
-		return ReplyResponse{}, err
+		return nil, err
+	}
+	return parseLabReport(message.Content)
+}
+
+// openAITurn keeps the message list across tool rounds.
+type openAITurn struct {
+	p        *OpenAIProvider
+	req      ReplyRequest
+	messages []chatMessageRequest
+	stream   *textStream // nil for non-streaming replies
+	content  string
+}
+
+func (t *openAITurn) next(ctx context.Context, final bool) ([]ToolCall, error) {
+	payload := chatCompletionsRequest{
+		Model:       t.p.model,
+		Temperature: t.p.temperature,
+		MaxTokens:   t.p.maxTokens,
+		Messages:    t.messages,
+		Stream:      t.stream != nil,
+		ResponseFormat: &responseFormat{
+			Type: "json_schema",
+			JSONSchema: jsonSchemaFormat{
+				Name:   "assistant_reply",
+				Strict: true,
+				Schema: replySchema(),
+			},
+		},
+	}
+	if t.stream != nil {
+		payload.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+	if t.req.Tools != nil {
+		payload.Tools = openAITools(t.req.Tools.Specs())
+		if final {
+			payload.ToolChoice = "none"
+		}
+	}
+
+	resp, err := t.p.post(ctx, payload, t.stream != nil)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	responseBody, err := io.ReadAll(resp.Body)
+	var message chatMessageRequest
+	var usage Usage
+	if t.stream != nil {
+		message, usage, err = readStream(resp.Body, t.stream)
+	} else {
+		message, usage, err = readCompletion(resp.Body)
+	}
+	reportUsage(ctx, t.p.name, t.p.model, usage)
 	if err != nil {
-		return ReplyResponse{}, err
+		return nil, err
 	}
+	t.content = message.Content
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return ReplyResponse{}, fmt.Errorf("openai request failed with status %d", resp.StatusCode)
+	calls := make([]ToolCall, 0, len(message.ToolCalls))
+	for _, tc := range message.ToolCalls {
+		calls = append(calls, ToolCall{
+			ID:        tc.ID,
+			Name:      tc.Function.Name,
+			Arguments: json.RawMessage(tc.Function.Arguments),
+		})
+	}
+	if len(calls) > 0 {
+		t.messages = append(t.messages, message)
+	}
+	return calls, nil
+}
+
+func (t *openAITurn) addResults(_ []ToolCall, results []ToolResult) {
+	for _, result := range results {
+		t.messages = append(t.messages, chatMessageRequest{
+			Role:       "tool",
+			Content:    result.Content,
+			ToolCallID: result.CallID,
+		})
+	}
+}
+
+func readCompletion(body io.Reader) (chatMessageRequest, Usage, error) {
+	responseBody, err := io.ReadAll(body)
+	if err != nil {
+		return chatMessageRequest{}, Usage{}, err
 	}
 
 	var parsed chatCompletionsResponse
 	if err := json.Unmarshal(responseBody, &parsed); err != nil {
-		return ReplyResponse{}, err
+		return chatMessageRequest{}, Usage{}, err
 	}
+	usage := parsed.Usage.usage()
 	if len(parsed.Choices) == 0 {
-		return ReplyResponse{}, fmt.Errorf("openai response does not contain choices")
+		return chatMessageRequest{}, usage, fmt.Errorf("openai response does not contain choices")
 	}
+	return parsed.Choices[0].Message, usage, nil
+}
+
+// readStream consumes one streamed completion: content goes to stream,
+// tool call fragments are merged by index. Usage arrives in a last chunk
+// without choices when stream_options.include_usage is set.
+func readStream(body io.Reader, stream *textStream) (chatMessageRequest, Usage, error) {
+	message := chatMessageRequest{Role: "assistant"}
+	var content strings.Builder
+	var usage Usage
 
-	content := strings.TrimSpace(parsed.Choices[0].Message.Content)
-	text, proposals := extractProposalsFromText(content)
-	return ReplyResponse{
-		AssistantText: text,
-		Proposals:     proposals,
-	}, nil
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			message.Content = content.String()
+			return message, usage, nil
+		}
+
+		var chunk chatCompletionsChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return chatMessageRequest{}, usage, fmt.Errorf("openai stream: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.usage()
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+		for _, tc := range delta.ToolCalls {
+			for len(message.ToolCalls) <= tc.Index {
+				message.ToolCalls = append(message.ToolCalls, openAIToolCall{Type: "function"})
+			}
+			merged := &message.ToolCalls[tc.Index]
+			if tc.ID != "" {
+				merged.ID = tc.ID
+			}
+			merged.Function.Name += tc.Function.Name
+			merged.Function.Arguments += tc.Function.Arguments
+		}
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			if err := stream.write(delta.Content); err != nil {
+				return chatMessageRequest{}, usage, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return chatMessageRequest{}, usage, err
+	}
+	return chatMessageRequest{}, usage, fmt.Errorf("openai stream ended without [DONE]")
+}
+
+// post sends a chat completions request and checks the status code. The
+// caller owns the response body.
+func (p *OpenAIProvider) post(ctx context.Context, payload any, stream bool) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	do := p.httpClient.Do
+	if stream {
+		do = p.streamClient.Do
+	}
+	resp, err := do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, &StatusError{Provider: p.name, StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+func openAITools(specs []ToolSpec) []openAITool {
+	tools := make([]openAITool, 0, len(specs))
+	for _, spec := range specs {
+		tool := openAITool{Type: "function"}
+		tool.Function.Name = spec.Name
+		tool.Function.Description = spec.Description
+		tool.Function.Parameters = spec.Parameters
+		tools = append(tools, tool)
+	}
+	return tools
 }
 
 func (p *OpenAIProvider) buildMessages(req ReplyRequest) []chatMessageRequest {
 	messages := make([]chatMessageRequest, 0, len(req.Messages)+2)
 	messages = append(messages, chatMessageRequest{
 		Role:    "system",
-		Content: p.systemPrompt(req),
+		Content: systemPrompt(req, false),
 	})
 	for _, msg := range req.Messages {
 		role := strings.TrimSpace(msg.Role)
@@ -108,75 +350,118 @@ func (p *OpenAIProvider) buildMessages(req ReplyRequest) []chatMessageRequest {
 	return messages
 }
 
-func (p *OpenAIProvider) systemPrompt(req ReplyRequest) string {
-	return fmt.Sprintf(
-		"Ты помощник HealthHub. Не ставь диагнозы и не заменяй врача. "+
-			"Если риск или ухудшение состояния — рекомендуй обратиться к врачу. "+
-			"Отвечай кратко и объяснимо, с опорой на метрики пользователя. "+
-			"Снимок дня: date=%s, steps=%d, active_energy_kcal=%d, sleep_minutes=%d, nutrition_kcal=%d. "+
-			"Если есть полезные структурированные предложения, добавь в конце отдельный блок строго в формате "+
-			"<proposals>[{\"kind\":\"settings_update\",\"title\":\"...\",\"summary\":\"...\",\"payload\":{...}}]</proposals>. "+
-			"Для kind=settings_update payload должен быть JSON object только с разрешёнными ключами: "+
-			"time_zone, quiet_start_minutes, quiet_end_minutes, notifications_max_per_day, "+
-			"min_sleep_minutes, min_steps, min_active_energy_kcal, morning_checkin_time_minutes, "+
-			"evening_checkin_time_minutes, vitamins_time_minutes. "+
-			"Для kind=vitamins_schedule payload должен быть JSON object строго вида: "+
-			"{\"replace\":true,\"items\":[{\"supplement_name\":\"...\",\"time_minutes\":720,\"days_mask\":127,\"is_enabled\":true}]}. "+
-			"В items не более 20 элементов; supplement_name от 1 до 80 символов; time_minutes 0..1439; days_mask 0..127. "+
-			"Для kind=workout_plan payload должен быть JSON object строго вида: "+
-			"{\"replace\":true,\"title\":\"...\",\"goal\":\"...\",\"items\":[{\"kind\":\"run\",\"time_minutes\":420,\"days_mask\":62,\"duration_min\":30,\"intensity\":\"medium\",\"note\":\"\",\"details\":{}}]}. "+
-			"Для kind=nutrition_plan payload должен быть JSON object строго вида: "+
-			"{\"calories_kcal\":2200,\"protein_g\":120,\"fat_g\":70,\"carbs_g\":250,\"calcium_mg\":800}. "+
-			"Для kind=meal_plan payload должен быть JSON object строго вида: "+
-			"{\"title\":\"План питания на неделю\",\"items\":[{\"day_index\":0,\"meal_slot\":\"breakfast\",\"title\":\"Овсянка\",\"notes\":\"\",\"approx_kcal\":450,\"approx_protein_g\":15,\"approx_fat_g\":12,\"approx_carbs_g\":70}]}. "+
-			"В items не более 28 элементов (7 дней × 4 слота); day_index 0..6; meal_slot: breakfast, lunch, dinner, snack. "+
-			"Никогда не добавляй profile_id, owner_user_id или любые другие ключи в payload. "+
-			"Если предложений нет — не добавляй блок proposals.",
-		req.Snapshot.Date,
-		req.Snapshot.Steps,
-		req.Snapshot.ActiveEnergyKcal,
-		req.Snapshot.SleepMinutes,
-		req.Snapshot.NutritionKcal,
-	)
-}
-
-func extractProposalsFromText(content string) (string, []ProposalDraft) {
-	startTag := "<proposals>"
-	endTag := "</proposals>"
-	start := strings.Index(content, startTag)
-	end := strings.Index(content, endTag)
-	if start == -1 || end == -1 || end <= start {
-		return strings.TrimSpace(content), nil
-	}
-
-	jsonChunk := strings.TrimSpace(content[start+len(startTag) : end])
-	mainText := strings.TrimSpace(content[:start] + content[end+len(endTag):])
-
-	var drafts []ProposalDraft
-	if err := json.Unmarshal([]byte(jsonChunk), &drafts); err != nil {
-		return mainText, nil
-	}
-
-	return mainText, drafts
-}
-
 type chatCompletionsRequest struct {
 	Model       string               `json:"model"`
 	Messages    []chatMessageRequest `json:"messages"`
 	Temperature float64              `json:"temperature"`
 	MaxTokens   int                  `json:"max_tokens"`
+	Stream      bool                 `json:"stream,omitempty"`
+	// StreamOptions asks for a final usage chunk when streaming.
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+	Tools         []openAITool   `json:"tools,omitempty"`
+	ToolChoice    string         `json:"tool_choice,omitempty"`
+	// ResponseFormat asks for a structured reply matching replySchema.
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
 }
 
-type chatMessageRequest struct {
+// visionCompletionsRequest is a chat completions request whose messages may
+// carry images.
+type visionCompletionsRequest struct {
+	Model          string          `json:"model"`
+	Messages       []visionMessage `json:"messages"`
+	Temperature    float64         `json:"temperature"`
+	MaxTokens      int             `json:"max_tokens"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+}
+
+// visionMessage content is a string or a list of visionPart.
+type visionMessage struct {
 	Role    string `json:"role"`
-	Content string `json:"content"`
+	Content any    `json:"content"`
+}
+
+type visionPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *visionImageURL `json:"image_url,omitempty"`
+}
+
+type visionImageURL struct {
+	URL string `json:"url"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type responseFormat struct {
+	Type       string           `json:"type"`
+	JSONSchema jsonSchemaFormat `json:"json_schema"`
+}
+
+type jsonSchemaFormat struct {
+	Name   string         `json:"name"`
+	Strict bool           `json:"strict"`
+	Schema map[string]any `json:"schema"`
+}
+
+type chatMessageRequest struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+	} `json:"function"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 type chatCompletionsResponse struct {
 	Choices []struct {
-		Message struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		} `json:"message"`
+		Message chatMessageRequest `json:"message"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func (u *openAIUsage) usage() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	return Usage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+}
+
+type chatCompletionsChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
 	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
 }
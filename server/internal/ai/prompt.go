@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// systemPrompt is shared by all providers. Providers without native
+// structured outputs pass embedSchema to spell the reply schema out.
+func systemPrompt(req ReplyRequest, embedSchema bool) string {
+	prompt := fmt.Sprintf(
+		"Ты помощник HealthHub. Не ставь диагнозы и не заменяй врача. "+
+			"Если риск или ухудшение состояния — рекомендуй обратиться к врачу. "+
+			"Отвечай кратко и объяснимо, с опорой на метрики пользователя. "+
+			"Снимок дня: date=%s, steps=%d, active_energy_kcal=%d, sleep_minutes=%d, nutrition_kcal=%d. "+
+			"Ответ возвращай JSON-объектом по заданной схеме: text — ответ пользователю, "+
+			"proposals — структурированные предложения (пустой массив, если их нет). "+
+			"Предлагай изменения только когда они действительно полезны: пользователь применяет их вручную. "+
+			"Для settings_update заполняй только меняемые поля, остальные — null. "+
+			"В meal_plan пара day_index и meal_slot не должна повторяться; в workout_plan не более 4 тренировок в один день. "+
+			"Если пользователь сообщил о себе устойчивый факт (аллергия, диагноз врача, цель, режим) или просит что-то запомнить, "+
+			"предложи memory с одним коротким фактом; не предлагай то, что уже есть среди известных фактов. "+
+			"Если пользователь ставит цель со сроком по весу, бегу, сну или шагам, предложи coaching_program: "+
+			"target_change — изменение от текущего значения («сбросить 5 кг» — -5), target_value — абсолютная цель "+
+			"(«спать 8 часов» — 480 минут); distance_km — лучшая дистанция за день, sleep_minutes и steps — среднее за неделю.",
+		req.Snapshot.Date,
+		req.Snapshot.Steps,
+		req.Snapshot.ActiveEnergyKcal,
+		req.Snapshot.SleepMinutes,
+		req.Snapshot.NutritionKcal,
+	)
+	if len(req.Facts) > 0 {
+		prompt += " Известные факты о пользователе (подтверждены им): " + strings.Join(req.Facts, "; ") + "."
+	}
+	if req.Summary != "" {
+		prompt += " Краткое содержание более ранней части разговора: " + req.Summary
+	}
+	if req.Tools != nil {
+		prompt += " Для вопросов о прошлых днях, неделях и трендах вызывай инструменты и опирайся только на их данные; " +
+			"даты передавай в формате YYYY-MM-DD, сегодня " + req.Snapshot.Date + ". Не выдумывай значения, которых нет в данных."
+	}
+	if embedSchema {
+		schema, _ := json.Marshal(replySchema())
+		prompt += " Верни только JSON-объект без markdown и пояснений вокруг, строго по JSON Schema: " + string(schema)
+	}
+	return prompt
+}
+
+const summaryPrompt = "Ты сжимаешь переписку пользователя с ассистентом HealthHub. " +
+	"Составь краткую сводку на русском (не более 10 пунктов): цели и жалобы пользователя, " +
+	"важные цифры, договорённости и открытые вопросы. Если дана предыдущая сводка, обнови её " +
+	"с учётом новых сообщений и убери устаревшее. Не добавляй того, чего не было в переписке. " +
+	"Верни только текст сводки."
+
+// summaryInput renders the previous summary and the messages to fold in.
+func summaryInput(req SummaryRequest) string {
+	var b strings.Builder
+	if req.Previous != "" {
+		b.WriteString("Предыдущая сводка:\n")
+		b.WriteString(req.Previous)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Новые сообщения:\n")
+	for _, msg := range req.Messages {
+		b.WriteString(msg.Role)
+		b.WriteString(": ")
+		b.WriteString(msg.Content)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,253 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Proposal kinds the assistant may draft. Each has a payload schema below
+// that matches what proposals.Service.Apply accepts.
+const (
+	KindSettingsUpdate   = "settings_update"
+	KindVitaminsSchedule = "vitamins_schedule"
+	KindWorkoutPlan      = "workout_plan"
+	KindNutritionPlan    = "nutrition_plan"
+	KindMealPlan         = "meal_plan"
+	KindMemory           = "memory"
+	KindCoachingProgram  = "coaching_program"
+)
+
+// ProposalKinds lists the kinds in the order they appear in the schema.
+var ProposalKinds = []string{KindSettingsUpdate, KindVitaminsSchedule, KindWorkoutPlan, KindNutritionPlan, KindMealPlan, KindMemory, KindCoachingProgram}
+
+func intRange(min, max int) map[string]any {
+	return map[string]any{"type": "integer", "minimum": min, "maximum": max}
+}
+
+func nullableIntRange(min, max int) map[string]any {
+	return map[string]any{"type": []string{"integer", "null"}, "minimum": min, "maximum": max}
+}
+
+func nullableNumberRange(min, max float64) map[string]any {
+	return map[string]any{"type": []string{"number", "null"}, "minimum": min, "maximum": max}
+}
+
+// textUpTo is a non-blank single-line string of at most n characters.
+func textUpTo(n int) map[string]any {
+	return map[string]any{"type": "string", "pattern": fmt.Sprintf(`^\S.{0,%d}$`, n-1)}
+}
+
+func enumOf(values ...any) map[string]any {
+	return map[string]any{"type": "string", "enum": values}
+}
+
+// strictObject builds an object schema in the form strict structured
+// outputs require: every property listed in required, nothing else allowed.
+func strictObject(properties map[string]any, order ...string) map[string]any {
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             order,
+		"additionalProperties": false,
+	}
+}
+
+func arrayOf(items map[string]any, minItems, maxItems int) map[string]any {
+	return map[string]any{"type": "array", "items": items, "minItems": minItems, "maxItems": maxItems}
+}
+
+// proposalPayloadSchemas are the per-kind payload schemas. Settings fields
+// are nullable because strict mode has no optional properties; null means
+// "leave unchanged". Length limits are in characters and leave room for
+// two-byte Cyrillic under the byte limits enforced on apply.
+var proposalPayloadSchemas = map[string]map[string]any{
+	KindSettingsUpdate: strictObject(map[string]any{
+		"time_zone":                    map[string]any{"type": []string{"string", "null"}},
+		"quiet_start_minutes":          nullableIntRange(0, 1439),
+		"quiet_end_minutes":            nullableIntRange(0, 1439),
+		"notifications_max_per_day":    nullableIntRange(0, 10),
+		"min_sleep_minutes":            nullableIntRange(0, 1200),
+		"min_steps":                    nullableIntRange(0, 50000),
+		"min_active_energy_kcal":       nullableIntRange(0, 5000),
+		"morning_checkin_time_minutes": nullableIntRange(0, 1439),
+		"evening_checkin_time_minutes": nullableIntRange(0, 1439),
+		"vitamins_time_minutes":        nullableIntRange(0, 1439),
+	},
+		"time_zone", "quiet_start_minutes", "quiet_end_minutes", "notifications_max_per_day",
+		"min_sleep_minutes", "min_steps", "min_active_energy_kcal",
+		"morning_checkin_time_minutes", "evening_checkin_time_minutes", "vitamins_time_minutes",
+	),
+	KindVitaminsSchedule: strictObject(map[string]any{
+		"replace": map[string]any{"type": "boolean", "enum": []any{true}},
+		"items": arrayOf(strictObject(map[string]any{
+			"supplement_name": textUpTo(40),
+			"time_minutes":    intRange(0, 1439),
+			"days_mask":       intRange(0, 127),
+			"is_enabled":      map[string]any{"type": []string{"boolean", "null"}},
+		}, "supplement_name", "time_minutes", "days_mask", "is_enabled"), 1, 20),
+	}, "replace", "items"),
+	KindWorkoutPlan: strictObject(map[string]any{
+		"replace": map[string]any{"type": "boolean", "enum": []any{true}},
+		"title":   textUpTo(100),
+		"goal":    map[string]any{"type": "string"},
+		"items": arrayOf(strictObject(map[string]any{
+			"kind":         enumOf("run", "walk", "strength", "morning", "core", "other"),
+			"time_minutes": intRange(0, 1439),
+			"days_mask":    intRange(0, 127),
+			"duration_min": intRange(5, 240),
+			"intensity":    enumOf("low", "medium", "high"),
+			"note":         map[string]any{"type": "string"},
+		}, "kind", "time_minutes", "days_mask", "duration_min", "intensity", "note"), 1, 30),
+	}, "replace", "title", "goal", "items"),
+	KindNutritionPlan: strictObject(map[string]any{
+		"calories_kcal": intRange(800, 6000),
+		"protein_g":     intRange(0, 400),
+		"fat_g":         intRange(0, 400),
+		"carbs_g":       intRange(0, 400),
+		"calcium_mg":    intRange(0, 5000),
+	}, "calories_kcal", "protein_g", "fat_g", "carbs_g", "calcium_mg"),
+	KindMealPlan: strictObject(map[string]any{
+		"title": textUpTo(100),
+		"items": arrayOf(strictObject(map[string]any{
+			"day_index":        intRange(0, 6),
+			"meal_slot":        enumOf("breakfast", "lunch", "dinner", "snack"),
+			"title":            textUpTo(100),
+			"notes":            map[string]any{"type": "string"},
+			"approx_kcal":      intRange(0, 10000),
+			"approx_protein_g": intRange(0, 1000),
+			"approx_fat_g":     intRange(0, 1000),
+			"approx_carbs_g":   intRange(0, 1000),
+		}, "day_index", "meal_slot", "title", "notes", "approx_kcal", "approx_protein_g", "approx_fat_g", "approx_carbs_g"), 1, 28),
+	}, "title", "items"),
+	KindMemory: strictObject(map[string]any{
+		"fact": textUpTo(200),
+	}, "fact"),
+	// Exactly one of target_value (absolute) and target_change (from the
+	// current value, negative to decrease) is set.
+	KindCoachingProgram: strictObject(map[string]any{
+		"title":          textUpTo(100),
+		"metric":         enumOf("weight_kg", "distance_km", "sleep_minutes", "steps"),
+		"target_value":   nullableNumberRange(0, 100000),
+		"target_change":  nullableNumberRange(-100000, 100000),
+		"duration_weeks": intRange(1, 52),
+	}, "title", "metric", "target_value", "target_change", "duration_weeks"),
+}
+
+// proposalSchema is the schema of one draft of the given kind.
+func proposalSchema(kind string) map[string]any {
+	return strictObject(map[string]any{
+		"kind":    enumOf(kind),
+		"title":   textUpTo(120),
+		"summary": map[string]any{"type": "string"},
+		"payload": proposalPayloadSchemas[kind],
+	}, "kind", "title", "summary", "payload")
+}
+
+// replySchema is the response_format schema of an assistant turn: the text
+// shown to the user first (so it can be streamed) and zero or more drafts.
+func replySchema() map[string]any {
+	variants := make([]map[string]any, 0, len(ProposalKinds))
+	for _, kind := range ProposalKinds {
+		variants = append(variants, proposalSchema(kind))
+	}
+	return strictObject(map[string]any{
+		"text":      map[string]any{"type": "string"},
+		"proposals": map[string]any{"type": "array", "items": map[string]any{"anyOf": variants}},
+	}, "text", "proposals")
+}
+
+// ValidateProposal checks a draft against the schema of its kind and
+// returns it with null payload fields removed. A settings_update without
+// any non-null field and a coaching_program without exactly one target
+// are rejected as well: they would fail on apply.
+func ValidateProposal(draft ProposalDraft) (ProposalDraft, error) {
+	kind := strings.TrimSpace(draft.Kind)
+	if _, ok := proposalPayloadSchemas[kind]; !ok {
+		return ProposalDraft{}, fmt.Errorf("unknown proposal kind %q", draft.Kind)
+	}
+
+	raw, err := json.Marshal(map[string]any{
+		"kind":    kind,
+		"title":   strings.TrimSpace(draft.Title),
+		"summary": strings.TrimSpace(draft.Summary),
+		"payload": draft.Payload,
+	})
+	if err != nil {
+		return ProposalDraft{}, err
+	}
+	var decoded map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return ProposalDraft{}, err
+	}
+	if err := validateSchema(proposalSchema(kind), decoded, kind); err != nil {
+		return ProposalDraft{}, err
+	}
+
+	payload := dropNulls(decoded["payload"].(map[string]any))
+	if kind == KindSettingsUpdate && len(payload) == 0 {
+		return ProposalDraft{}, fmt.Errorf("%s: payload has no changes", kind)
+	}
+	if kind == KindCoachingProgram {
+		_, hasValue := payload["target_value"]
+		_, hasChange := payload["target_change"]
+		if hasValue == hasChange {
+			return ProposalDraft{}, fmt.Errorf("%s: set exactly one of target_value and target_change", kind)
+		}
+	}
+	return ProposalDraft{
+		Kind:    kind,
+		Title:   decoded["title"].(string),
+		Summary: decoded["summary"].(string),
+		Payload: payload,
+	}, nil
+}
+
+func dropNulls(obj map[string]any) map[string]any {
+	cleaned := make(map[string]any, len(obj))
+	for key, value := range obj {
+		switch v := value.(type) {
+		case nil:
+			continue
+		case map[string]any:
+			cleaned[key] = dropNulls(v)
+		case []any:
+			items := make([]any, 0, len(v))
+			for _, item := range v {
+				if m, ok := item.(map[string]any); ok {
+					items = append(items, dropNulls(m))
+				} else {
+					items = append(items, item)
+				}
+			}
+			cleaned[key] = items
+		default:
+			cleaned[key] = value
+		}
+	}
+	return cleaned
+}
+
+// structuredReply is the JSON object the model returns under replySchema.
+type structuredReply struct {
+	Text      string          `json:"text"`
+	Proposals []ProposalDraft `json:"proposals"`
+}
+
+// parseStructuredReply decodes a structured reply. Content that is not a
+// JSON object (a provider that ignored response_format) is returned as
+// plain text without proposals.
+func parseStructuredReply(content string) ReplyResponse {
+	content = strings.TrimSpace(content)
+	var parsed structuredReply
+	if !strings.HasPrefix(content, "{") || json.Unmarshal([]byte(content), &parsed) != nil {
+		return ReplyResponse{AssistantText: content}
+	}
+	return ReplyResponse{
+		AssistantText: strings.TrimSpace(parsed.Text),
+		Proposals:     parsed.Proposals,
+	}
+}
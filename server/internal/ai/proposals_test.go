@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMockProposalsMatchSchemas(t *testing.T) {
+	provider := NewMockProvider()
+	for _, message := range []string{"витамины", "подними порог шагов", "план тренировок", "сколько калорий", "составь меню", "хочу похудеть"} {
+		reply, err := provider.Reply(context.Background(), ReplyRequest{Messages: []ChatMessage{{Role: "user", Content: message}}})
+		if err != nil {
+			t.Fatalf("reply failed: %v", err)
+		}
+		if len(reply.Proposals) != 1 {
+			t.Fatalf("%q: expected one proposal, got %d", message, len(reply.Proposals))
+		}
+		if _, err := ValidateProposal(reply.Proposals[0]); err != nil {
+			t.Fatalf("%q: mock %s proposal does not match its schema: %v", message, reply.Proposals[0].Kind, err)
+		}
+	}
+}
+
+func TestValidateProposalRejectsInvalidDrafts(t *testing.T) {
+	cases := map[string]ProposalDraft{
+		"unknown kind": {Kind: "generic", Title: "t", Payload: map[string]any{}},
+		"out of range": {Kind: KindNutritionPlan, Title: "t", Payload: map[string]any{
+			"calories_kcal": 200, "protein_g": 100, "fat_g": 60, "carbs_g": 200, "calcium_mg": 800,
+		}},
+		"missing field": {Kind: KindNutritionPlan, Title: "t", Payload: map[string]any{
+			"calories_kcal": 2000, "protein_g": 100, "fat_g": 60, "carbs_g": 200,
+		}},
+		"unknown field": {Kind: KindNutritionPlan, Title: "t", Payload: map[string]any{
+			"calories_kcal": 2000, "protein_g": 100, "fat_g": 60, "carbs_g": 200, "calcium_mg": 800, "profile_id": "x",
+		}},
+		"fractional integer": {Kind: KindNutritionPlan, Title: "t", Payload: map[string]any{
+			"calories_kcal": 2000.5, "protein_g": 100, "fat_g": 60, "carbs_g": 200, "calcium_mg": 800,
+		}},
+		"bad enum": {Kind: KindMealPlan, Title: "t", Payload: map[string]any{
+			"title": "План",
+			"items": []any{map[string]any{
+				"day_index": 0, "meal_slot": "brunch", "title": "Омлет", "notes": "",
+				"approx_kcal": 400, "approx_protein_g": 20, "approx_fat_g": 20, "approx_carbs_g": 10,
+			}},
+		}},
+		"blank title": {Kind: KindNutritionPlan, Title: "  ", Payload: map[string]any{
+			"calories_kcal": 2000, "protein_g": 100, "fat_g": 60, "carbs_g": 200, "calcium_mg": 800,
+		}},
+		"empty settings patch": {Kind: KindSettingsUpdate, Title: "t", Payload: map[string]any{
+			"time_zone": nil, "quiet_start_minutes": nil, "quiet_end_minutes": nil, "notifications_max_per_day": nil,
+			"min_sleep_minutes": nil, "min_steps": nil, "min_active_energy_kcal": nil,
+			"morning_checkin_time_minutes": nil, "evening_checkin_time_minutes": nil, "vitamins_time_minutes": nil,
+		}},
+		"coaching target and change": {Kind: KindCoachingProgram, Title: "t", Payload: map[string]any{
+			"title": "Минус 5 кг", "metric": "weight_kg", "target_value": 70, "target_change": -5, "duration_weeks": 10,
+		}},
+		"coaching without target": {Kind: KindCoachingProgram, Title: "t", Payload: map[string]any{
+			"title": "Минус 5 кг", "metric": "weight_kg", "target_value": nil, "target_change": nil, "duration_weeks": 10,
+		}},
+	}
+	for name, draft := range cases {
+		if _, err := ValidateProposal(draft); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestValidateProposalDropsNullSettings(t *testing.T) {
+	reply, _ := NewMockProvider().Reply(context.Background(), ReplyRequest{Messages: []ChatMessage{{Role: "user", Content: "порог шагов"}}})
+	valid, err := ValidateProposal(reply.Proposals[0])
+	if err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	data, _ := json.Marshal(valid.Payload)
+	if string(data) != `{"min_sleep_minutes":450,"min_steps":8000}` {
+		t.Fatalf("expected only non-null settings to remain, got %s", data)
+	}
+}
+
+func TestOpenAIRequestsStructuredOutput(t *testing.T) {
+	provider, requests := fakeOpenAI(t, 0, false)
+	if _, err := provider.Reply(context.Background(), ReplyRequest{}); err != nil {
+		t.Fatalf("reply failed: %v", err)
+	}
+
+	format := (*requests)[0].ResponseFormat
+	if format == nil || format.Type != "json_schema" || !format.JSONSchema.Strict {
+		t.Fatalf("expected strict json_schema response format, got %+v", format)
+	}
+	schema, _ := json.Marshal(format.JSONSchema.Schema)
+	for _, kind := range ProposalKinds {
+		if !strings.Contains(string(schema), `"enum":["`+kind+`"]`) {
+			t.Fatalf("expected a schema variant for %s", kind)
+		}
+	}
+}
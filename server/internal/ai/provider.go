@@ -10,8 +10,21 @@ import (
 
 type Provider interface {
 	Reply(ctx context.Context, req ReplyRequest) (ReplyResponse, error)
+	// ReplyStream is Reply with incremental delivery of the assistant text.
+	// The proposals block is never passed to onDelta; it is parsed into the
+	// returned response once the stream completes.
+	ReplyStream(ctx context.Context, req ReplyRequest, onDelta StreamFunc) (ReplyResponse, error)
+	// Summarize folds older messages into a plain-text summary of the
+	// conversation, updating req.Previous when it is set.
+	Summarize(ctx context.Context, req SummaryRequest) (string, error)
+	// ReadLabReport transcribes the lab values on a photo of a lab report.
+	ReadLabReport(ctx context.Context, req LabReportRequest) ([]LabValue, error)
 }
 
+// StreamFunc receives assistant text deltas. Returning an error aborts the
+// stream and ReplyStream returns that error.
+type StreamFunc func(delta string) error
+
 type ChatMessage struct {
 	Role      string
 	Content   string
@@ -38,11 +51,28 @@ type ReplyRequest struct {
 	Snapshot  DaySnapshot
 	Settings  settings.SettingsDTO
 	TimeZone  string
+	// Summary condenses thread messages older than Messages.
+	Summary string
+	// Facts are things the user asked the assistant to remember.
+	Facts []string
+	// Names are people's names (profile names) masked by WithRedaction.
+	// Providers never receive them.
+	Names []string
+	// Tools gives the model read access to the profile's history. Nil
+	// disables tool calling.
+	Tools Toolbox
+}
+
+type SummaryRequest struct {
+	Previous string
+	Messages []ChatMessage
 }
 
 type ReplyResponse struct {
 	AssistantText string
 	Proposals     []ProposalDraft
+	// SafetyFlags lists the SafetyFlag* checks the reply tripped.
+	SafetyFlags []string
 }
 
 type ProposalDraft struct {
@@ -0,0 +1,353 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/config"
+)
+
+const structuredAnswer = `{"text":"Спите больше.","proposals":[{"kind":"nutrition_plan","title":"Цели","summary":"s","payload":{"calories_kcal":2000,"protein_g":100,"fat_g":60,"carbs_g":200,"calcium_mg":800}}]}`
+
+func testConfig(baseURL string) *config.Config {
+	provider := config.AIProviderConfig{BaseURL: baseURL, APIKey: "test-key", Model: "test-model", TimeoutSeconds: 5}
+	return &config.Config{
+		AIMaxOutputTokens: 300,
+		AITemperature:     0.2,
+		AITimeoutSeconds:  5,
+		Anthropic:         provider,
+		Ollama:            provider,
+		OpenAICompatible:  provider,
+	}
+}
+
+func userRequest(tools Toolbox) ReplyRequest {
+	return ReplyRequest{
+		Messages: []ChatMessage{{Role: "user", Content: "Как я спал?"}},
+		Snapshot: DaySnapshot{Date: "2026-02-01"},
+		Tools:    tools,
+	}
+}
+
+func TestAnthropicToolLoopAndStructuredReply(t *testing.T) {
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("unexpected request %s headers=%v", r.URL.Path, r.Header)
+		}
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+
+		if len(bodies) == 1 {
+			fmt.Fprint(w, `{"content":[{"type":"text","text":"Смотрю данные."},{"type":"tool_use","id":"toolu_1","name":"get_daily_metrics","input":{"from":"2026-01-01","to":"2026-01-31"}}],"stop_reason":"tool_use"}`)
+			return
+		}
+		data, _ := json.Marshal(map[string]any{"content": []map[string]any{{"type": "text", "text": structuredAnswer}}})
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	tools := &fakeToolbox{}
+	reply, err := NewAnthropicProvider(testConfig(srv.URL)).Reply(context.Background(), userRequest(tools))
+	if err != nil {
+		t.Fatalf("reply failed: %v", err)
+	}
+	if reply.AssistantText != "Спите больше." || len(reply.Proposals) != 1 {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+	if len(tools.calls) != 1 {
+		t.Fatalf("expected the tool to run once, got %v", tools.calls)
+	}
+	if !strings.Contains(bodies[0]["system"].(string), `"proposals"`) {
+		t.Fatalf("expected the reply schema in the system prompt")
+	}
+
+	messages := bodies[1]["messages"].([]any)
+	last := messages[len(messages)-1].(map[string]any)
+	block := last["content"].([]any)[0].(map[string]any)
+	if last["role"] != "user" || block["type"] != "tool_result" || block["tool_use_id"] != "toolu_1" {
+		t.Fatalf("expected a tool_result turn, got %v", last)
+	}
+}
+
+func TestAnthropicStreamsTextDeltas(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"message_start","message":{}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"{\"text\":\"Спите "}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"больше.\",\"proposals\":[]}"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, event := range events {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", event)
+		}
+	}))
+	defer srv.Close()
+
+	var streamed strings.Builder
+	reply, err := NewAnthropicProvider(testConfig(srv.URL)).ReplyStream(context.Background(), userRequest(nil), func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if streamed.String() != "Спите больше." || reply.AssistantText != "Спите больше." {
+		t.Fatalf("unexpected streamed=%q reply=%q", streamed.String(), reply.AssistantText)
+	}
+}
+
+func TestStreamOutlastsTotalTimeoutButNotIdleGaps(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	stall := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for i, part := range []string{`{\"text\":\"Спите `, `больше`, `.\",`, `\"proposals\":`, `[]}`} {
+			// Each gap is below the timeout, the whole reply is well above it.
+			gap := 80 * time.Millisecond
+			if stall && i == 2 {
+				gap = 3 * timeout
+			}
+			select {
+			case <-time.After(gap):
+			case <-r.Context().Done():
+				return
+			}
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"%s\"}}]}\n\n", part)
+			flusher.Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	provider := NewOpenAICompatibleProvider(testConfig(srv.URL))
+	provider.httpClient.Timeout = timeout
+	provider.streamClient = newStreamClient(timeout)
+	noop := func(string) error { return nil }
+
+	reply, err := provider.ReplyStream(context.Background(), userRequest(nil), noop)
+	if err != nil {
+		t.Fatalf("expected a slow stream to finish, got %v", err)
+	}
+	if reply.AssistantText != "Спите больше." {
+		t.Fatalf("unexpected reply %q", reply.AssistantText)
+	}
+
+	stall = true
+	if _, err := provider.ReplyStream(context.Background(), userRequest(nil), noop); !errors.Is(err, errStreamIdle) {
+		t.Fatalf("expected errStreamIdle for a stalled stream, got %v", err)
+	}
+}
+
+func TestOllamaToolLoopAndStream(t *testing.T) {
+	var bodies []ollamaRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var body ollamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+
+		if len(bodies) == 1 {
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_daily_metrics","arguments":{"from":"2026-01-01","to":"2026-01-31"}}}]},"done":false}`)
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true}`)
+			return
+		}
+		half := len(structuredAnswer) / 2
+		for _, part := range []string{structuredAnswer[:half], structuredAnswer[half:]} {
+			data, _ := json.Marshal(map[string]any{"message": map[string]any{"role": "assistant", "content": part}, "done": false})
+			fmt.Fprintln(w, string(data))
+		}
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true}`)
+	}))
+	defer srv.Close()
+
+	tools := &fakeToolbox{}
+	var streamed strings.Builder
+	reply, err := NewOllamaProvider(testConfig(srv.URL)).ReplyStream(context.Background(), userRequest(tools), func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if streamed.String() != "Спите больше." || len(reply.Proposals) != 1 {
+		t.Fatalf("unexpected streamed=%q reply=%+v", streamed.String(), reply)
+	}
+	if len(tools.calls) != 1 || len(bodies) != 2 {
+		t.Fatalf("expected one tool round, got calls=%v requests=%d", tools.calls, len(bodies))
+	}
+	last := bodies[1].Messages[len(bodies[1].Messages)-1]
+	if last.Role != "tool" || last.ToolName != ToolGetDailyMetrics {
+		t.Fatalf("expected tool result message, got %+v", last)
+	}
+	if bodies[0].Format != nil || len(bodies[0].Tools) == 0 {
+		t.Fatalf("expected tools without format while tools are offered")
+	}
+}
+
+func TestOpenAICompatibleUsesBaseURLWithoutKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected request %s auth=%q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		data, _ := json.Marshal(map[string]any{"choices": []map[string]any{{"message": map[string]any{"role": "assistant", "content": structuredAnswer}}}})
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(srv.URL + "/v1/")
+	cfg.OpenAICompatible.APIKey = ""
+	reply, err := NewOpenAICompatibleProvider(cfg).Reply(context.Background(), userRequest(nil))
+	if err != nil {
+		t.Fatalf("reply failed: %v", err)
+	}
+	if reply.AssistantText != "Спите больше." {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+}
+
+// flakyServer fails with status for the first failures requests.
+func flakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if calls.Add(1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		data, _ := json.Marshal(map[string]any{"choices": []map[string]any{{"message": map[string]any{"role": "assistant", "content": structuredAnswer}}}})
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRetryOnServerErrors(t *testing.T) {
+	srv, calls := flakyServer(t, 2, http.StatusServiceUnavailable)
+	provider := WithRetry(NewOpenAICompatibleProvider(testConfig(srv.URL)), 2, 0)
+
+	if _, err := provider.Reply(context.Background(), userRequest(nil)); err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestNoRetryOnClientErrors(t *testing.T) {
+	srv, calls := flakyServer(t, 5, http.StatusBadRequest)
+	provider := WithRetry(NewOpenAICompatibleProvider(testConfig(srv.URL)), 2, 0)
+
+	_, err := provider.Reply(context.Background(), userRequest(nil))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 StatusError, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected a single attempt, got %d", calls.Load())
+	}
+}
+
+func TestFallbackToNextProvider(t *testing.T) {
+	down, _ := flakyServer(t, 100, http.StatusBadGateway)
+	up, upCalls := flakyServer(t, 0, 0)
+
+	provider := Fallback(
+		ChainEntry{Name: "primary", Provider: NewOpenAICompatibleProvider(testConfig(down.URL))},
+		ChainEntry{Name: "secondary", Provider: NewOpenAICompatibleProvider(testConfig(up.URL))},
+	)
+	reply, err := provider.Reply(context.Background(), userRequest(nil))
+	if err != nil {
+		t.Fatalf("expected fallback to succeed, got %v", err)
+	}
+	if reply.AssistantText != "Спите больше." || upCalls.Load() != 1 {
+		t.Fatalf("expected the secondary provider to answer")
+	}
+}
+
+// failingAfterDelta streams one delta and then fails.
+type failingAfterDelta struct{ *MockProvider }
+
+func (failingAfterDelta) ReplyStream(ctx context.Context, req ReplyRequest, onDelta StreamFunc) (ReplyResponse, error) {
+	if err := onDelta("Нача"); err != nil {
+		return ReplyResponse{}, err
+	}
+	return ReplyResponse{}, &StatusError{Provider: "test", StatusCode: http.StatusBadGateway}
+}
+
+func TestFallbackStopsOnceStreamStarted(t *testing.T) {
+	provider := Fallback(
+		ChainEntry{Name: "primary", Provider: WithRetry(failingAfterDelta{NewMockProvider()}, 3, 0)},
+		ChainEntry{Name: "mock", Provider: NewMockProvider()},
+	)
+
+	var streamed strings.Builder
+	_, err := provider.ReplyStream(context.Background(), userRequest(nil), func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("expected the partial stream error to be returned")
+	}
+	if streamed.String() != "Нача" {
+		t.Fatalf("expected no retry or fallback after the first delta, got %q", streamed.String())
+	}
+}
+
+const labReportAnswer = `{"values":[{"name":"Ферритин","value":12.5,"unit":"нг/мл","ref_low":20,"ref_high":250,"date":"2026-09-30"}]}`
+
+func TestOpenAICompatibleReadLabReportSendsImage(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		data, _ := json.Marshal(map[string]any{"choices": []map[string]any{{"message": map[string]any{"role": "assistant", "content": labReportAnswer}}}})
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	values, err := NewOpenAICompatibleProvider(testConfig(srv.URL+"/v1")).ReadLabReport(context.Background(), LabReportRequest{Image: []byte("jpeg"), ContentType: "image/jpeg"})
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if len(values) != 1 || values[0].Name != "Ферритин" || values[0].Value != 12.5 || values[0].RefHigh == nil || *values[0].RefHigh != 250 || values[0].Date != "2026-09-30" {
+		t.Fatalf("unexpected values %+v", values)
+	}
+
+	messages := body["messages"].([]any)
+	part := messages[1].(map[string]any)["content"].([]any)[0].(map[string]any)
+	if part["type"] != "image_url" || part["image_url"].(map[string]any)["url"] != "data:image/jpeg;base64,anBlZw==" {
+		t.Fatalf("expected the image as a data URL, got %v", part)
+	}
+	if body["response_format"].(map[string]any)["json_schema"].(map[string]any)["name"] != "lab_report" {
+		t.Fatalf("expected the lab_report schema")
+	}
+}
+
+func TestAnthropicReadLabReportRejectsOffSchemaReply(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := json.Marshal(map[string]any{"content": []map[string]any{{"type": "text", "text": `{"values":[{"name":"Ферритин","value":"low"}]}`}}})
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	if _, err := NewAnthropicProvider(testConfig(srv.URL)).ReadLabReport(context.Background(), LabReportRequest{Image: []byte("png"), ContentType: "image/png"}); err == nil {
+		t.Fatal("expected an off-schema reply to fail")
+	}
+}
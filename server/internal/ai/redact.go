@@ -0,0 +1,377 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fdg312/health-hub/internal/config"
+)
+
+// RedactionPolicy selects the data classes masked before a request leaves
+// the server.
+type RedactionPolicy struct {
+	Emails bool
+	Phones bool
+	// Names masks ReplyRequest.Names wherever they appear.
+	Names bool
+	// Notes masks free-text note fields in tool results entirely.
+	Notes bool
+}
+
+// NewRedactionPolicy builds a policy from AI_REDACT classes.
+func NewRedactionPolicy(classes []string) RedactionPolicy {
+	var policy RedactionPolicy
+	for _, class := range classes {
+		switch class {
+		case config.AIRedactEmails:
+			policy.Emails = true
+		case config.AIRedactPhones:
+			policy.Phones = true
+		case config.AIRedactNames:
+			policy.Names = true
+		case config.AIRedactNotes:
+			policy.Notes = true
+		}
+	}
+	return policy
+}
+
+func (p RedactionPolicy) enabled() bool {
+	return p.Emails || p.Phones || p.Names || p.Notes
+}
+
+// ErrUnredactedImage is returned by ReadLabReport under a redaction policy
+// when the user has not agreed to send the photo unredacted.
+var ErrUnredactedImage = errors.New("lab report photo cannot be redacted")
+
+var (
+	emailPattern       = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	phonePattern       = regexp.MustCompile(`\+?\d[\d \-()]{7,}\d`)
+	wordPattern        = regexp.MustCompile(`\p{L}+`)
+	placeholderPattern = regexp.MustCompile(`\[(?:EMAIL|PHONE|NAME|NOTE)_\d+\]`)
+)
+
+// noteKeys are JSON fields of tool results that hold free text typed by
+// the user.
+var noteKeys = map[string]bool{"note": true, "notes": true, "comment": true}
+
+// maxPlaceholderLen bounds how much streamed text is held back while a
+// placeholder may still be incomplete.
+const maxPlaceholderLen = 16
+
+type redactingProvider struct {
+	next   Provider
+	policy RedactionPolicy
+}
+
+// WithRedaction masks personal data in everything sent to p and restores
+// the placeholders in what comes back, so the provider only ever sees
+// tokens like [EMAIL_1] or [NAME_2]. Placeholders are numbered per call.
+func WithRedaction(p Provider, policy RedactionPolicy) Provider {
+	if !policy.enabled() {
+		return p
+	}
+	return &redactingProvider{next: p, policy: policy}
+}
+
+func (p *redactingProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyResponse, error) {
+	r := newRedactor(p.policy, req.Names)
+	resp, err := p.next.Reply(ctx, r.request(req))
+	if err != nil {
+		return resp, err
+	}
+	return r.response(resp), nil
+}
+
+func (p *redactingProvider) ReplyStream(ctx context.Context, req ReplyRequest, onDelta StreamFunc) (ReplyResponse, error) {
+	r := newRedactor(p.policy, req.Names)
+	// Hold back a possibly split placeholder until its closing bracket
+	// arrives, so onDelta only sees restored text.
+	var pending string
+	resp, err := p.next.ReplyStream(ctx, r.request(req), func(delta string) error {
+		pending += delta
+		cut := len(pending)
+		if open := strings.LastIndexByte(pending, '['); open >= 0 &&
+			!strings.Contains(pending[open:], "]") && len(pending)-open < maxPlaceholderLen {
+			cut = open
+		}
+		if cut == 0 {
+			return nil
+		}
+		out := r.restore(pending[:cut])
+		pending = pending[cut:]
+		return onDelta(out)
+	})
+	if err != nil {
+		return resp, err
+	}
+	if pending != "" {
+		if err := onDelta(r.restore(pending)); err != nil {
+			return ReplyResponse{}, err
+		}
+	}
+	return r.response(resp), nil
+}
+
+func (p *redactingProvider) Summarize(ctx context.Context, req SummaryRequest) (string, error) {
+	r := newRedactor(p.policy, nil)
+	masked := SummaryRequest{
+		Previous: r.text(req.Previous),
+		Messages: r.messages(req.Messages),
+	}
+	summary, err := p.next.Summarize(ctx, masked)
+	if err != nil {
+		return "", err
+	}
+	return r.restore(summary), nil
+}
+
+// ReadLabReport is the exception to the policy: a photo cannot be masked,
+// and printed reports show the patient's name, birth date and often the
+// clinic and patient ID. The image is only sent when the user agreed to
+// send it as is (AllowUnredacted); otherwise ErrUnredactedImage.
+func (p *redactingProvider) ReadLabReport(ctx context.Context, req LabReportRequest) ([]LabValue, error) {
+	if !req.AllowUnredacted {
+		return nil, ErrUnredactedImage
+	}
+	return p.next.ReadLabReport(ctx, req)
+}
+
+type namePart struct {
+	display string
+	lower   string
+	runes   int
+}
+
+// redactor holds the placeholders of one provider call.
+type redactor struct {
+	policy       RedactionPolicy
+	names        []namePart
+	placeholders map[string]string // placeholder -> original
+	byValue      map[string]string // class + normalized original -> placeholder
+	counts       map[string]int
+}
+
+func newRedactor(policy RedactionPolicy, names []string) *redactor {
+	r := &redactor{
+		policy:       policy,
+		placeholders: make(map[string]string),
+		byValue:      make(map[string]string),
+		counts:       make(map[string]int),
+	}
+	if policy.Names {
+		for _, name := range names {
+			// Each word of a name is masked on its own: people are
+			// mentioned by first name or surname as often as in full.
+			for _, word := range wordPattern.FindAllString(name, -1) {
+				if n := utf8.RuneCountInString(word); n >= 3 {
+					r.names = append(r.names, namePart{display: word, lower: strings.ToLower(word), runes: n})
+				}
+			}
+		}
+	}
+	return r
+}
+
+// request returns a copy of req with personal data masked. Names are not
+// forwarded: they are only needed here.
+func (r *redactor) request(req ReplyRequest) ReplyRequest {
+	req.Messages = r.messages(req.Messages)
+	req.Summary = r.text(req.Summary)
+	facts := make([]string, 0, len(req.Facts))
+	for _, fact := range req.Facts {
+		facts = append(facts, r.text(fact))
+	}
+	req.Facts = facts
+	req.Names = nil
+	if req.Tools != nil {
+		req.Tools = &redactingToolbox{next: req.Tools, r: r}
+	}
+	return req
+}
+
+func (r *redactor) messages(in []ChatMessage) []ChatMessage {
+	out := make([]ChatMessage, 0, len(in))
+	for _, msg := range in {
+		msg.Content = r.text(msg.Content)
+		out = append(out, msg)
+	}
+	return out
+}
+
+func (r *redactor) response(resp ReplyResponse) ReplyResponse {
+	resp.AssistantText = r.restore(resp.AssistantText)
+	proposals := make([]ProposalDraft, 0, len(resp.Proposals))
+	for _, draft := range resp.Proposals {
+		draft.Title = r.restore(draft.Title)
+		draft.Summary = r.restore(draft.Summary)
+		if payload, ok := r.restoreValue(draft.Payload).(map[string]any); ok {
+			draft.Payload = payload
+		}
+		proposals = append(proposals, draft)
+	}
+	resp.Proposals = proposals
+	return resp
+}
+
+// text masks emails, phone numbers and known names in s.
+func (r *redactor) text(s string) string {
+	if s == "" {
+		return s
+	}
+	if r.policy.Emails {
+		s = emailPattern.ReplaceAllStringFunc(s, func(m string) string {
+			return r.mask("EMAIL", m, strings.ToLower(m))
+		})
+	}
+	if r.policy.Phones {
+		s = phonePattern.ReplaceAllStringFunc(s, func(m string) string {
+			digits := strings.Map(func(c rune) rune {
+				if c >= '0' && c <= '9' {
+					return c
+				}
+				return -1
+			}, m)
+			if len(digits) < 10 || len(digits) > 15 {
+				return m
+			}
+			return r.mask("PHONE", m, digits)
+		})
+	}
+	if len(r.names) > 0 {
+		s = wordPattern.ReplaceAllStringFunc(s, func(w string) string {
+			if part, ok := r.matchName(w); ok {
+				return r.mask("NAME", part.display, part.lower)
+			}
+			return w
+		})
+	}
+	return s
+}
+
+// matchName matches a word against the name parts, allowing the short
+// case endings of Russian names (Анна -> Анны, Иванов -> Иванову) for
+// capitalized words.
+func (r *redactor) matchName(word string) (namePart, bool) {
+	lower := strings.ToLower(word)
+	first, _ := utf8.DecodeRuneInString(word)
+	n := utf8.RuneCountInString(word)
+	for _, part := range r.names {
+		if lower == part.lower {
+			return part, true
+		}
+		if part.runes < 4 || !unicode.IsUpper(first) || n > part.runes+2 || n < part.runes-1 {
+			continue
+		}
+		stem := string([]rune(part.lower)[:part.runes-1])
+		if strings.HasPrefix(lower, stem) {
+			return part, true
+		}
+	}
+	return namePart{}, false
+}
+
+func (r *redactor) mask(class, original, key string) string {
+	key = class + "\x00" + key
+	if placeholder, ok := r.byValue[key]; ok {
+		return placeholder
+	}
+	r.counts[class]++
+	placeholder := fmt.Sprintf("[%s_%d]", class, r.counts[class])
+	r.byValue[key] = placeholder
+	r.placeholders[placeholder] = original
+	return placeholder
+}
+
+// value masks every string in a decoded JSON value. Note fields are
+// replaced as a whole when the policy masks notes.
+func (r *redactor) value(key string, v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = r.value(k, item)
+		}
+		return out
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, item := range v {
+			out = append(out, r.value(key, item))
+		}
+		return out
+	case string:
+		if r.policy.Notes && noteKeys[key] && strings.TrimSpace(v) != "" {
+			return r.mask("NOTE", v, v)
+		}
+		return r.text(v)
+	default:
+		return v
+	}
+}
+
+// restore puts the originals back in place of this call's placeholders.
+// Unknown placeholders are left as they are.
+func (r *redactor) restore(s string) string {
+	if len(r.placeholders) == 0 || !strings.Contains(s, "[") {
+		return s
+	}
+	return placeholderPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if original, ok := r.placeholders[m]; ok {
+			return original
+		}
+		return m
+	})
+}
+
+func (r *redactor) restoreValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = r.restoreValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, item := range v {
+			out = append(out, r.restoreValue(item))
+		}
+		return out
+	case string:
+		return r.restore(v)
+	default:
+		return v
+	}
+}
+
+// redactingToolbox masks tool results before they are fed to the model.
+type redactingToolbox struct {
+	next Toolbox
+	r    *redactor
+}
+
+func (t *redactingToolbox) Specs() []ToolSpec {
+	return t.next.Specs()
+}
+
+func (t *redactingToolbox) Call(ctx context.Context, name string, args json.RawMessage) (any, error) {
+	out, err := t.next.Call(ctx, name, args)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return t.r.value("", decoded), nil
+}
@@ -0,0 +1,152 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// echoProvider records what it was sent and answers with chunks built from
+// the request, the way a model repeats placeholders back.
+type echoProvider struct {
+	*MockProvider
+	got    ReplyRequest
+	tool   any
+	answer func(req ReplyRequest) []string
+}
+
+func (p *echoProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyResponse, error) {
+	return p.ReplyStream(ctx, req, func(string) error { return nil })
+}
+
+func (p *echoProvider) ReplyStream(ctx context.Context, req ReplyRequest, onDelta StreamFunc) (ReplyResponse, error) {
+	p.got = req
+	if req.Tools != nil {
+		out, err := req.Tools.Call(ctx, ToolListCheckins, json.RawMessage(`{}`))
+		if err != nil {
+			return ReplyResponse{}, err
+		}
+		p.tool = out
+	}
+	chunks := p.answer(req)
+	for _, chunk := range chunks {
+		if err := onDelta(chunk); err != nil {
+			return ReplyResponse{}, err
+		}
+	}
+	text := strings.Join(chunks, "")
+	return ReplyResponse{
+		AssistantText: text,
+		Proposals: []ProposalDraft{{
+			Kind:    KindMemory,
+			Title:   "Запомнить",
+			Summary: text,
+			Payload: map[string]any{"fact": text},
+		}},
+	}, nil
+}
+
+func TestRedactionMasksRequestAndRestoresReply(t *testing.T) {
+	next := &echoProvider{
+		MockProvider: NewMockProvider(),
+		answer: func(req ReplyRequest) []string {
+			return []string{req.Messages[0].Content}
+		},
+	}
+	p := WithRedaction(next, NewRedactionPolicy([]string{"emails", "phones", "names", "notes"}))
+
+	original := "Я Анна Смирнова, пишите anna@example.com или +7 (912) 345-67-89. У Анны болит спина, шагов 10000"
+	resp, err := p.Reply(context.Background(), ReplyRequest{
+		Messages: []ChatMessage{{Role: "user", Content: original}},
+		Facts:    []string{"Анна не ест орехи"},
+		Names:    []string{"Анна Смирнова"},
+	})
+	if err != nil {
+		t.Fatalf("reply failed: %v", err)
+	}
+
+	sent := next.got.Messages[0].Content
+	for _, secret := range []string{"Анна", "Анны", "Смирнова", "anna@example.com", "345-67-89"} {
+		if strings.Contains(sent, secret) || strings.Contains(next.got.Facts[0], secret) {
+			t.Fatalf("%q leaked to the provider: %s", secret, sent)
+		}
+	}
+	if !strings.Contains(sent, "[EMAIL_1]") || !strings.Contains(sent, "[PHONE_1]") || !strings.Contains(sent, "10000") {
+		t.Fatalf("unexpected masked message: %s", sent)
+	}
+	if next.got.Names != nil {
+		t.Fatalf("names must not be forwarded")
+	}
+
+	// Case forms collapse to the name they match.
+	want := strings.Replace(original, "Анны", "Анна", 1)
+	if resp.AssistantText != want {
+		t.Fatalf("reply not restored:\n got %s\nwant %s", resp.AssistantText, want)
+	}
+	if resp.Proposals[0].Payload["fact"] != want || resp.Proposals[0].Summary != want {
+		t.Fatalf("proposal not restored: %+v", resp.Proposals[0])
+	}
+}
+
+func TestRedactionRestoresPlaceholdersSplitAcrossDeltas(t *testing.T) {
+	next := &echoProvider{
+		MockProvider: NewMockProvider(),
+		answer: func(req ReplyRequest) []string {
+			return []string{"Напишу на [EMA", "IL_1", "] сегодня [", "x]"}
+		},
+	}
+	p := WithRedaction(next, NewRedactionPolicy([]string{"emails"}))
+
+	var streamed strings.Builder
+	resp, err := p.ReplyStream(context.Background(), ReplyRequest{
+		Messages: []ChatMessage{{Role: "user", Content: "мой адрес a.b@mail.ru"}},
+	}, func(delta string) error {
+		if strings.Contains(delta, "[EMA") {
+			t.Fatalf("partial placeholder streamed: %q", delta)
+		}
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	want := "Напишу на a.b@mail.ru сегодня [x]"
+	if streamed.String() != want || resp.AssistantText != want {
+		t.Fatalf("got streamed %q, final %q", streamed.String(), resp.AssistantText)
+	}
+}
+
+type notesToolbox struct{}
+
+func (notesToolbox) Specs() []ToolSpec { return nil }
+
+func (notesToolbox) Call(context.Context, string, json.RawMessage) (any, error) {
+	return map[string]any{"checkins": []map[string]any{
+		{"score": 4, "note": "поругалась с Олегом, звонить 89123456789", "tags": []string{"стресс"}},
+	}}, nil
+}
+
+func TestRedactionMasksToolNotes(t *testing.T) {
+	next := &echoProvider{
+		MockProvider: NewMockProvider(),
+		answer:       func(ReplyRequest) []string { return []string{"ok"} },
+	}
+
+	WithRedaction(next, NewRedactionPolicy([]string{"notes"})).Reply(context.Background(), ReplyRequest{Tools: notesToolbox{}})
+	data, _ := json.Marshal(next.tool)
+	if got := string(data); !strings.Contains(got, `"note":"[NOTE_1]"`) || !strings.Contains(got, `"score":4`) || !strings.Contains(got, "стресс") {
+		t.Fatalf("unexpected tool result: %s", got)
+	}
+
+	// Without the notes class only phones inside the note are masked.
+	WithRedaction(next, NewRedactionPolicy([]string{"phones"})).Reply(context.Background(), ReplyRequest{Tools: notesToolbox{}})
+	data, _ = json.Marshal(next.tool)
+	if got := string(data); !strings.Contains(got, "поругалась с Олегом, звонить [PHONE_1]") {
+		t.Fatalf("unexpected tool result: %s", got)
+	}
+
+	if p := WithRedaction(next, NewRedactionPolicy(nil)); p != Provider(next) {
+		t.Fatalf("empty policy must not wrap the provider")
+	}
+}
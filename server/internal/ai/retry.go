@@ -0,0 +1,116 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// StatusError is a non-2xx response from a provider API.
+type StatusError struct {
+	Provider   string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s request failed with status %d", e.Provider, e.StatusCode)
+}
+
+// Retryable reports whether err is worth another attempt: rate limits,
+// server errors and transport failures. Cancellation, client errors and
+// malformed replies are not.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrToolLoopLimit) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+type retryingProvider struct {
+	next    Provider
+	retries int
+	backoff time.Duration
+}
+
+// WithRetry retries retryable failures up to retries times, waiting
+// backoff before the first retry and doubling it each time. A stream is
+// only retried while nothing has been delivered to onDelta.
+func WithRetry(p Provider, retries int, backoff time.Duration) Provider {
+	if retries <= 0 {
+		return p
+	}
+	return &retryingProvider{next: p, retries: retries, backoff: backoff}
+}
+
+func (p *retryingProvider) Reply(ctx context.Context, req ReplyRequest) (ReplyResponse, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := p.next.Reply(ctx, req)
+		if err == nil || attempt >= p.retries || !Retryable(err) {
+			return resp, err
+		}
+		if err := p.wait(ctx, attempt); err != nil {
+			return ReplyResponse{}, err
+		}
+	}
+}
+
+func (p *retryingProvider) ReplyStream(ctx context.Context, req ReplyRequest, onDelta StreamFunc) (ReplyResponse, error) {
+	started := false
+	tracked := func(delta string) error {
+		started = true
+		return onDelta(delta)
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := p.next.ReplyStream(ctx, req, tracked)
+		if err == nil || started || attempt >= p.retries || !Retryable(err) {
+			return resp, err
+		}
+		if err := p.wait(ctx, attempt); err != nil {
+			return ReplyResponse{}, err
+		}
+	}
+}
+
+func (p *retryingProvider) Summarize(ctx context.Context, req SummaryRequest) (string, error) {
+	for attempt := 0; ; attempt++ {
+		summary, err := p.next.Summarize(ctx, req)
+		if err == nil || attempt >= p.retries || !Retryable(err) {
+			return summary, err
+		}
+		if err := p.wait(ctx, attempt); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (p *retryingProvider) ReadLabReport(ctx context.Context, req LabReportRequest) ([]LabValue, error) {
+	for attempt := 0; ; attempt++ {
+		values, err := p.next.ReadLabReport(ctx, req)
+		if err == nil || attempt >= p.retries || !Retryable(err) {
+			return values, err
+		}
+		if err := p.wait(ctx, attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *retryingProvider) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(p.backoff << attempt)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,109 @@
+package ai
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// Safety flags reported in ReplyResponse.SafetyFlags.
+const (
+	SafetyFlagDosage    = "dosage"
+	SafetyFlagDiagnosis = "diagnosis"
+)
+
+// SafetyDisclaimer is appended to replies that mention medication doses or
+// read like a diagnosis.
+const SafetyDisclaimer = "Важно: это справочная информация, а не назначение врача. " +
+	"Дозировки лекарств и возможные диагнозы обсудите с врачом."
+
+// conditions are diagnoses the assistant must not assert; stems cover
+// Russian case endings.
+const conditions = `(?:диабет|гипертони|гипотони|анеми|депресси|тревожн\p{L}* расстройств|гипотиреоз|гипертиреоз|апноэ|аритми|астм|мигрен|инсульт|инфаркт|` +
+	`diabetes|hypertension|anemia|depression|hypothyroidism|hyperthyroidism|apnea|arrhythmia|asthma|migraine)`
+
+var (
+	dosagePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\d+(?:[.,]\d+)?\s?(?:мг|мкг|mg|mcg|µg|ме|iu|ед)(?:[^\p{L}]|$)`),
+		regexp.MustCompile(`(?i)\d+\s?(?:таблет|капсул|tablet|capsule|pill)`),
+	}
+	diagnosisPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(?:у вас|у тебя|you have)\s+(?:\p{L}+\s+){0,2}` + conditions),
+		regexp.MustCompile(`(?i)(?:признак|симптом|sign of|symptom of)\p{L}*\s+(?:\p{L}+\s+){0,1}` + conditions),
+		regexp.MustCompile(`(?i)(?:ваш|твой|your)\s+диагноз|диагноз\s*[:—-]`),
+	}
+)
+
+// SafetyFlags returns the checks text trips, in a fixed order.
+func SafetyFlags(text string) []string {
+	var flags []string
+	if matchAny(dosagePatterns, text) {
+		flags = append(flags, SafetyFlagDosage)
+	}
+	if matchAny(diagnosisPatterns, text) {
+		flags = append(flags, SafetyFlagDiagnosis)
+	}
+	return flags
+}
+
+func matchAny(patterns []*regexp.Regexp, text string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+type safetyFilter struct {
+	next Provider
+}
+
+// WithSafetyFilter checks every reply of p for medication doses and
+// diagnostic claims, flags it and appends SafetyDisclaimer. Streamed
+// replies get the disclaimer as a final delta.
+func WithSafetyFilter(p Provider) Provider {
+	return &safetyFilter{next: p}
+}
+
+func (p *safetyFilter) Reply(ctx context.Context, req ReplyRequest) (ReplyResponse, error) {
+	resp, err := p.next.Reply(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	resp, _ = applySafety(resp)
+	return resp, nil
+}
+
+func (p *safetyFilter) ReplyStream(ctx context.Context, req ReplyRequest, onDelta StreamFunc) (ReplyResponse, error) {
+	resp, err := p.next.ReplyStream(ctx, req, onDelta)
+	if err != nil {
+		return resp, err
+	}
+	resp, appended := applySafety(resp)
+	if appended != "" {
+		if err := onDelta(appended); err != nil {
+			return ReplyResponse{}, err
+		}
+	}
+	return resp, nil
+}
+
+func (p *safetyFilter) Summarize(ctx context.Context, req SummaryRequest) (string, error) {
+	return p.next.Summarize(ctx, req)
+}
+
+func (p *safetyFilter) ReadLabReport(ctx context.Context, req LabReportRequest) ([]LabValue, error) {
+	return p.next.ReadLabReport(ctx, req)
+}
+
+// applySafety flags resp and returns the text appended to it, if any.
+func applySafety(resp ReplyResponse) (ReplyResponse, string) {
+	resp.SafetyFlags = SafetyFlags(resp.AssistantText)
+	if len(resp.SafetyFlags) == 0 || strings.Contains(resp.AssistantText, SafetyDisclaimer) {
+		return resp, ""
+	}
+	appended := "\n\n" + SafetyDisclaimer
+	resp.AssistantText += appended
+	return resp, appended
+}
@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSafetyFlags(t *testing.T) {
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"Выпейте 500 мл воды и пройдите 8000 шагов.", nil},
+		{"Белка нужно около 120 г в день.", nil},
+		{"Принимайте магний 400 мг вечером.", []string{SafetyFlagDosage}},
+		{"Можно 2 таблетки ибупрофена.", []string{SafetyFlagDosage}},
+		{"Похоже, у вас сахарный диабет.", []string{SafetyFlagDiagnosis}},
+		{"Это признак анемии, а витамин D 2000 МЕ поможет.", []string{SafetyFlagDosage, SafetyFlagDiagnosis}},
+		{"При сомнениях обсудите диабет с врачом.", nil},
+	}
+	for _, tc := range cases {
+		if got := SafetyFlags(tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("SafetyFlags(%q) = %v, want %v", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestSafetyFilterAppendsDisclaimerToStream(t *testing.T) {
+	next := &echoProvider{
+		MockProvider: NewMockProvider(),
+		answer:       func(ReplyRequest) []string { return []string{"Принимайте ", "магний 400 мг."} },
+	}
+	p := WithSafetyFilter(next)
+
+	var streamed strings.Builder
+	resp, err := p.ReplyStream(context.Background(), ReplyRequest{}, func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if !strings.HasSuffix(resp.AssistantText, SafetyDisclaimer) || streamed.String() != resp.AssistantText {
+		t.Fatalf("disclaimer missing: streamed %q, final %q", streamed.String(), resp.AssistantText)
+	}
+	if !reflect.DeepEqual(resp.SafetyFlags, []string{SafetyFlagDosage}) {
+		t.Fatalf("unexpected flags %v", resp.SafetyFlags)
+	}
+
+	next.answer = func(ReplyRequest) []string { return []string{"Хороший сон сегодня."} }
+	resp, _ = p.Reply(context.Background(), ReplyRequest{})
+	if resp.AssistantText != "Хороший сон сегодня." || resp.SafetyFlags != nil {
+		t.Fatalf("clean reply changed: %+v", resp)
+	}
+}
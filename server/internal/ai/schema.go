@@ -0,0 +1,177 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// validateSchema checks a decoded JSON value (numbers as json.Number)
+// against the subset of JSON Schema that OpenAI strict structured outputs
+// accept: type, enum, properties, required, additionalProperties, items,
+// minItems, maxItems, minimum, maximum, pattern and anyOf.
+func validateSchema(schema map[string]any, value any, path string) error {
+	if variants, ok := schema["anyOf"].([]map[string]any); ok {
+		for _, variant := range variants {
+			if validateSchema(variant, value, path) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: does not match any allowed shape", path)
+	}
+
+	if err := checkType(schema["type"], value, path); err != nil {
+		return err
+	}
+	if value == nil {
+		return nil
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && !inEnum(enum, value) {
+		return fmt.Errorf("%s: value %v is not allowed", path, value)
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		if min, ok := schemaNumber(schema["minimum"]); ok && f < min {
+			return fmt.Errorf("%s: must be >= %v", path, min)
+		}
+		if max, ok := schemaNumber(schema["maximum"]); ok && f > max {
+			return fmt.Errorf("%s: must be <= %v", path, max)
+		}
+	case string:
+		if pattern, ok := schema["pattern"].(string); ok && !compiledPattern(pattern).MatchString(v) {
+			return fmt.Errorf("%s: does not match %s", path, pattern)
+		}
+	case []any:
+		if min, ok := schema["minItems"].(int); ok && len(v) < min {
+			return fmt.Errorf("%s: needs at least %d items", path, min)
+		}
+		if max, ok := schema["maxItems"].(int); ok && len(v) > max {
+			return fmt.Errorf("%s: allows at most %d items", path, max)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		if required, ok := schema["required"].([]string); ok {
+			for _, key := range required {
+				if _, present := v[key]; !present {
+					return fmt.Errorf("%s.%s: is required", path, key)
+				}
+			}
+		}
+		for key, item := range v {
+			propSchema, known := properties[key].(map[string]any)
+			if !known {
+				if schema["additionalProperties"] == false {
+					return fmt.Errorf("%s.%s: unknown field", path, key)
+				}
+				continue
+			}
+			if err := validateSchema(propSchema, item, path+"."+key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkType(schemaType any, value any, path string) error {
+	var allowed []string
+	switch t := schemaType.(type) {
+	case string:
+		allowed = []string{t}
+	case []string:
+		allowed = t
+	default:
+		return nil
+	}
+	for _, name := range allowed {
+		if jsonTypeMatches(name, value) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: expected %s", path, strings.Join(allowed, " or "))
+}
+
+func jsonTypeMatches(name string, value any) bool {
+	switch name {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		if _, err := n.Int64(); err == nil {
+			return true
+		}
+		f, err := n.Float64()
+		return err == nil && f == float64(int64(f))
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	default:
+		return false
+	}
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, allowed := range enum {
+		if n, ok := value.(json.Number); ok {
+			if f, err := n.Float64(); err == nil {
+				if want, ok := schemaNumber(allowed); ok && want == f {
+					return true
+				}
+			}
+			continue
+		}
+		if allowed == value {
+			return true
+		}
+	}
+	return false
+}
+
+func schemaNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+var patternCache sync.Map
+
+func compiledPattern(pattern string) *regexp.Regexp {
+	if re, ok := patternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	patternCache.Store(pattern, re)
+	return re
+}
@@ -0,0 +1,123 @@
+package ai
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// textFieldStart matches the opening of a structured reply up to the first
+// character of the "text" value. replySchema lists text first, so strict
+// outputs always start this way.
+var textFieldStart = regexp.MustCompile(`^\s*\{\s*"text"\s*:\s*"`)
+
+// textStream accumulates raw model output and forwards only the visible
+// part to onDelta: the decoded "text" field of the structured reply. Raw
+// output that is not a JSON object is forwarded as is.
+type textStream struct {
+	onDelta StreamFunc
+	raw     strings.Builder
+	// start is the offset of the text value in raw, -1 until found.
+	start int
+	// sent is the raw offset up to which text has been forwarded.
+	sent  int
+	done  bool
+	plain bool
+}
+
+func newTextStream(onDelta StreamFunc) *textStream {
+	return &textStream{onDelta: onDelta, start: -1}
+}
+
+func (s *textStream) write(chunk string) error {
+	s.raw.WriteString(chunk)
+	if s.done {
+		return nil
+	}
+	full := s.raw.String()
+
+	if s.plain {
+		return s.emit(chunk)
+	}
+	if s.start < 0 {
+		loc := textFieldStart.FindStringIndex(full)
+		if loc == nil {
+			trimmed := strings.TrimLeft(full, " \t\r\n")
+			if trimmed != "" && trimmed[0] != '{' {
+				s.plain = true
+				return s.emit(full)
+			}
+			return nil
+		}
+		s.start, s.sent = loc[1], loc[1]
+	}
+
+	end, closed := scanJSONString(full, s.sent)
+	if end > s.sent {
+		var decoded string
+		if err := json.Unmarshal([]byte(`"`+full[s.sent:end]+`"`), &decoded); err == nil {
+			s.sent = end
+			if err := s.emit(decoded); err != nil {
+				return err
+			}
+		}
+	}
+	s.done = closed
+	return nil
+}
+
+func (s *textStream) emit(delta string) error {
+	if delta == "" {
+		return nil
+	}
+	return s.onDelta(delta)
+}
+
+// finish returns the parsed reply once the model output is complete. A
+// JSON reply whose text field did not come first is forwarded in one piece.
+func (s *textStream) finish() (ReplyResponse, error) {
+	reply := parseStructuredReply(s.raw.String())
+	if !s.plain && s.start < 0 {
+		if err := s.emit(reply.AssistantText); err != nil {
+			return ReplyResponse{}, err
+		}
+	}
+	return reply, nil
+}
+
+// scanJSONString walks a JSON string body from offset from and returns the
+// end of the longest prefix made of complete characters and escapes, and
+// whether the closing quote was reached. A high surrogate escape is held
+// back until its pair arrives.
+func scanJSONString(s string, from int) (int, bool) {
+	safe := from
+	for i := from; i < len(s); {
+		switch s[i] {
+		case '"':
+			return i, true
+		case '\\':
+			if i+1 >= len(s) {
+				return safe, false
+			}
+			if s[i+1] != 'u' {
+				i += 2
+				break
+			}
+			if i+6 > len(s) {
+				return safe, false
+			}
+			if hex := strings.ToLower(s[i+2 : i+4]); hex >= "d8" && hex <= "db" {
+				if i+12 > len(s) {
+					return safe, false
+				}
+				i += 12
+				break
+			}
+			i += 6
+		default:
+			i++
+		}
+		safe = i
+	}
+	return safe, false
+}
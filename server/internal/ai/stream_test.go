@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTextStreamForwardsOnlyTextField(t *testing.T) {
+	var got strings.Builder
+	stream := newTextStream(func(delta string) error {
+		got.WriteString(delta)
+		return nil
+	})
+
+	// Escapes are split across chunks, including a surrogate pair.
+	chunks := []string{
+		`{"te`, `xt":"Спите `, `больше.\`, `n\u00`, `e9 \ud83d`, `\ude34\"ok\"",`,
+		`"proposals":[{"kind":"nutrition_plan","title":"t","summary":"s",`,
+		`"payload":{"calories_kcal":2000,"protein_g":100,"fat_g":60,"carbs_g":200,"calcium_mg":800}}]}`,
+	}
+	for _, chunk := range chunks {
+		if err := stream.write(chunk); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	reply, err := stream.finish()
+	if err != nil {
+		t.Fatalf("finish failed: %v", err)
+	}
+
+	want := "Спите больше.\né 😴\"ok\""
+	if got.String() != want {
+		t.Fatalf("expected decoded text deltas %q, got %q", want, got.String())
+	}
+	if reply.AssistantText != want {
+		t.Fatalf("unexpected assistant text %q", reply.AssistantText)
+	}
+	if len(reply.Proposals) != 1 || reply.Proposals[0].Kind != KindNutritionPlan {
+		t.Fatalf("expected one parsed proposal, got %+v", reply.Proposals)
+	}
+}
+
+func TestTextStreamFallsBackToPlainText(t *testing.T) {
+	var got strings.Builder
+	stream := newTextStream(func(delta string) error {
+		got.WriteString(delta)
+		return nil
+	})
+
+	for _, chunk := range []string{"Просто ", "текст {без схемы}"} {
+		if err := stream.write(chunk); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	reply, err := stream.finish()
+	if err != nil {
+		t.Fatalf("finish failed: %v", err)
+	}
+	if got.String() != "Просто текст {без схемы}" || reply.AssistantText != got.String() {
+		t.Fatalf("expected plain passthrough, got deltas=%q text=%q", got.String(), reply.AssistantText)
+	}
+	if len(reply.Proposals) != 0 {
+		t.Fatalf("expected no proposals from plain text")
+	}
+}
+
+func TestMockReplyStreamMatchesReply(t *testing.T) {
+	provider := NewMockProvider()
+	req := ReplyRequest{Messages: []ChatMessage{{Role: "user", Content: "витамины"}}}
+
+	var deltas []string
+	reply, err := provider.ReplyStream(context.Background(), req, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if len(deltas) < 2 {
+		t.Fatalf("expected the reply to be chunked, got %d deltas", len(deltas))
+	}
+	if strings.Join(deltas, "") != reply.AssistantText {
+		t.Fatalf("deltas do not add up to the assistant text")
+	}
+	if len(reply.Proposals) != 1 {
+		t.Fatalf("expected proposals in the final reply, got %d", len(reply.Proposals))
+	}
+}
@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// errStreamIdle ends a streamed reply when the provider sent nothing for
+// the idle timeout.
+var errStreamIdle = errors.New("ai stream idle timeout")
+
+// streamClient sends streamed requests. A total client timeout would cut a
+// long reply off mid-stream, so timeout bounds connecting, the wait for the
+// response headers and then every gap between chunks instead.
+type streamClient struct {
+	client *http.Client
+	idle   time.Duration
+}
+
+func newStreamClient(timeout time.Duration) *streamClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.ResponseHeaderTimeout = timeout
+	return &streamClient{client: &http.Client{Transport: transport}, idle: timeout}
+}
+
+// Do sends req and cancels it once the body has been idle for the timeout.
+// The idle timer starts with the request, so a provider that answers with
+// headers but never sends the first chunk is cut off too.
+func (c *streamClient) Do(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(req.Context())
+	timer := time.AfterFunc(c.idle, func() { cancel(errStreamIdle) })
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		timer.Stop()
+		cancel(nil)
+		return nil, err
+	}
+	resp.Body = &idleBody{ReadCloser: resp.Body, ctx: ctx, cancel: cancel, timer: timer, idle: c.idle}
+	return resp, nil
+}
+
+// idleBody pushes the idle deadline back on every read that returns data.
+type idleBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	timer  *time.Timer
+	idle   time.Duration
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.idle)
+	}
+	if err != nil && errors.Is(context.Cause(b.ctx), errStreamIdle) {
+		return n, errStreamIdle
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel(nil)
+	return err
+}
@@ -0,0 +1,111 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// MaxToolRounds bounds how many times the model may call tools before it
+// must answer.
+const MaxToolRounds = 4
+
+// maxToolCallsPerRound caps tool calls executed from a single model turn.
+const maxToolCallsPerRound = 8
+
+// Tool names shared by the chat toolbox and the mock provider.
+const (
+	ToolGetDailyMetrics        = "get_daily_metrics"
+	ToolListCheckins           = "list_checkins"
+	ToolGetSupplementAdherence = "get_supplement_adherence"
+	ToolListWorkoutCompletions = "list_workout_completions"
+	ToolGetMealPlan            = "get_meal_plan"
+	ToolGetNutritionTargets    = "get_nutrition_targets"
+	ToolGetCycle               = "get_cycle"
+)
+
+var ErrToolLoopLimit = errors.New("ai: model kept calling tools after the round limit")
+
+// ToolSpec describes a tool offered to the model. Parameters is a JSON
+// Schema object.
+type ToolSpec struct {
+	Name        string
+	Description string
+	Parameters  map[string]any
+}
+
+// ToolCall is the model's request to run a tool.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments json.RawMessage
+}
+
+// ToolResult is the JSON-encoded output of a tool call.
+type ToolResult struct {
+	CallID  string
+	Name    string
+	Content string
+}
+
+// Toolbox executes read-only tools on behalf of the user of one request.
+type Toolbox interface {
+	Specs() []ToolSpec
+	Call(ctx context.Context, name string, args json.RawMessage) (any, error)
+}
+
+// ExecuteToolCalls runs calls sequentially. Failures are returned to the
+// model as {"error": "..."} so it can recover instead of failing the reply.
+func ExecuteToolCalls(ctx context.Context, tools Toolbox, calls []ToolCall) []ToolResult {
+	results := make([]ToolResult, 0, len(calls))
+	for i, call := range calls {
+		var out any
+		var err error
+		if i >= maxToolCallsPerRound {
+			err = errors.New("too many tool calls in one turn")
+		} else {
+			out, err = tools.Call(ctx, call.Name, call.Arguments)
+		}
+		if err != nil {
+			out = map[string]string{"error": err.Error()}
+		}
+		content, marshalErr := json.Marshal(out)
+		if marshalErr != nil {
+			content = []byte(`{"error":"tool result is not serializable"}`)
+		}
+		results = append(results, ToolResult{CallID: call.ID, Name: call.Name, Content: string(content)})
+	}
+	return results
+}
+
+// toolTurn is one provider conversation driven by runToolLoop.
+type toolTurn interface {
+	// next runs one model call. Tools are offered unless final is set.
+	next(ctx context.Context, final bool) ([]ToolCall, error)
+	// addResults feeds tool output back for the following call.
+	addResults(calls []ToolCall, results []ToolResult)
+}
+
+// runToolLoop alternates model calls and tool execution until the model
+// answers without calling tools. After MaxToolRounds the model is asked to
+// answer with tools disabled. A nil toolbox means a single plain call.
+func runToolLoop(ctx context.Context, tools Toolbox, turn toolTurn) error {
+	maxRounds := MaxToolRounds
+	if tools == nil {
+		maxRounds = 0
+	}
+	for round := 0; ; round++ {
+		final := round >= maxRounds
+		calls, err := turn.next(ctx, final)
+		if err != nil {
+			return err
+		}
+		if len(calls) == 0 {
+			return nil
+		}
+		if final {
+			return ErrToolLoopLimit
+		}
+		turn.addResults(calls, ExecuteToolCalls(ctx, tools, calls))
+	}
+}
@@ -0,0 +1,148 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeToolbox struct {
+	calls []string
+}
+
+func (f *fakeToolbox) Specs() []ToolSpec {
+	return []ToolSpec{{Name: ToolGetDailyMetrics, Parameters: map[string]any{"type": "object"}}}
+}
+
+func (f *fakeToolbox) Call(_ context.Context, name string, args json.RawMessage) (any, error) {
+	f.calls = append(f.calls, name+string(args))
+	if name != ToolGetDailyMetrics {
+		return nil, errors.New("unknown tool")
+	}
+	return map[string]any{"daily": []int{1, 2, 3}}, nil
+}
+
+// fakeOpenAI answers with tool calls for the first toolRounds requests and
+// with text afterwards, recording every request body.
+func fakeOpenAI(t *testing.T, toolRounds int, stream bool) (*OpenAIProvider, *[]chatCompletionsRequest) {
+	t.Helper()
+	var requests []chatCompletionsRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req chatCompletionsRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		requests = append(requests, req)
+
+		callTools := len(requests) <= toolRounds
+		if stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			if callTools {
+				fmt.Fprint(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_daily_metrics","arguments":"{\"from\":"}}]}}]}`+"\n\n")
+				fmt.Fprint(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"2026-01-01\",\"to\":\"2026-01-31\"}"}}]}}]}`+"\n\n")
+			} else {
+				fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"Спали "}}]}`+"\n\n")
+				fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"хорошо."}}]}`+"\n\n")
+			}
+			if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+				fmt.Fprint(w, `data: {"choices":[],"usage":{"prompt_tokens":100,"completion_tokens":20}}`+"\n\n")
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		if callTools {
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_daily_metrics","arguments":"{\"from\":\"2026-01-01\",\"to\":\"2026-01-31\"}"}}]}}],"usage":{"prompt_tokens":100,"completion_tokens":20}}`)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Спали хорошо."}}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	return &OpenAIProvider{name: ModeOpenAI, baseURL: srv.URL, model: "test", httpClient: srv.Client(), streamClient: newStreamClient(5 * time.Second)}, &requests
+}
+
+func TestOpenAIToolLoopFeedsResultsBack(t *testing.T) {
+	provider, requests := fakeOpenAI(t, 1, false)
+	tools := &fakeToolbox{}
+
+	reply, err := provider.Reply(context.Background(), ReplyRequest{Tools: tools})
+	if err != nil {
+		t.Fatalf("reply failed: %v", err)
+	}
+	if reply.AssistantText != "Спали хорошо." {
+		t.Fatalf("unexpected text %q", reply.AssistantText)
+	}
+	if len(tools.calls) != 1 || tools.calls[0] != `get_daily_metrics{"from":"2026-01-01","to":"2026-01-31"}` {
+		t.Fatalf("unexpected tool calls %v", tools.calls)
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("expected 2 model calls, got %d", len(*requests))
+	}
+	if len((*requests)[0].Tools) != 1 {
+		t.Fatalf("expected tools to be offered")
+	}
+	msgs := (*requests)[1].Messages
+	last := msgs[len(msgs)-1]
+	if last.Role != "tool" || last.ToolCallID != "call_1" || !strings.Contains(last.Content, `"daily":[1,2,3]`) {
+		t.Fatalf("expected tool result to be sent back, got %+v", last)
+	}
+	if prev := msgs[len(msgs)-2]; prev.Role != "assistant" || len(prev.ToolCalls) != 1 {
+		t.Fatalf("expected assistant tool_calls message before the result, got %+v", prev)
+	}
+}
+
+func TestOpenAIToolLoopIsBounded(t *testing.T) {
+	provider, requests := fakeOpenAI(t, 100, false)
+
+	_, err := provider.Reply(context.Background(), ReplyRequest{Tools: &fakeToolbox{}})
+	if !errors.Is(err, ErrToolLoopLimit) {
+		t.Fatalf("expected ErrToolLoopLimit, got %v", err)
+	}
+	if len(*requests) != MaxToolRounds+1 {
+		t.Fatalf("expected %d model calls, got %d", MaxToolRounds+1, len(*requests))
+	}
+	if got := (*requests)[MaxToolRounds].ToolChoice; got != "none" {
+		t.Fatalf("expected final round to disable tools, got tool_choice=%q", got)
+	}
+}
+
+func TestOpenAIStreamAssemblesToolCalls(t *testing.T) {
+	provider, requests := fakeOpenAI(t, 1, true)
+	tools := &fakeToolbox{}
+
+	var streamed strings.Builder
+	reply, err := provider.ReplyStream(context.Background(), ReplyRequest{Tools: tools}, func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if len(tools.calls) != 1 || tools.calls[0] != `get_daily_metrics{"from":"2026-01-01","to":"2026-01-31"}` {
+		t.Fatalf("expected fragmented arguments to be merged, got %v", tools.calls)
+	}
+	if streamed.String() != "Спали хорошо." || reply.AssistantText != "Спали хорошо." {
+		t.Fatalf("unexpected streamed=%q reply=%q", streamed.String(), reply.AssistantText)
+	}
+	if !(*requests)[1].Stream {
+		t.Fatalf("expected follow-up round to stream as well")
+	}
+}
+
+func TestExecuteToolCallsReportsErrorsToModel(t *testing.T) {
+	results := ExecuteToolCalls(context.Background(), &fakeToolbox{}, []ToolCall{
+		{ID: "a", Name: "drop_tables", Arguments: json.RawMessage(`{}`)},
+	})
+	if len(results) != 1 || results[0].Content != `{"error":"unknown tool"}` {
+		t.Fatalf("expected error payload, got %+v", results)
+	}
+}
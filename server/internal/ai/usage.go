@@ -0,0 +1,44 @@
+package ai
+
+import (
+	"context"
+	"unicode/utf8"
+)
+
+// Usage is the token count of one provider API call.
+type Usage struct {
+	Provider     string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+}
+
+// UsageFunc receives the usage of every API call made under a context:
+// each tool round, retry and summary is billed separately.
+type UsageFunc func(Usage)
+
+type usageKey struct{}
+
+// WithUsage returns a context whose provider calls report their token
+// usage to fn.
+func WithUsage(ctx context.Context, fn UsageFunc) context.Context {
+	return context.WithValue(ctx, usageKey{}, fn)
+}
+
+// reportUsage passes usage to the context's UsageFunc, if any. Calls the
+// server did not report usage for are skipped.
+func reportUsage(ctx context.Context, provider, model string, usage Usage) {
+	fn, ok := ctx.Value(usageKey{}).(UsageFunc)
+	if !ok || fn == nil || usage.InputTokens+usage.OutputTokens == 0 {
+		return
+	}
+	usage.Provider = provider
+	usage.Model = model
+	fn(usage)
+}
+
+// estimateTokens approximates a token count for the mock provider, which
+// has no real usage to report.
+func estimateTokens(text string) int {
+	return utf8.RuneCountInString(text)/3 + 1
+}
@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func collectUsage(ctx context.Context) (context.Context, *[]Usage) {
+	var got []Usage
+	return WithUsage(ctx, func(u Usage) { got = append(got, u) }), &got
+}
+
+func TestOpenAIReportsUsagePerRound(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		provider, requests := fakeOpenAI(t, 1, stream)
+		ctx, got := collectUsage(context.Background())
+
+		var err error
+		if stream {
+			_, err = provider.ReplyStream(ctx, ReplyRequest{Tools: &fakeToolbox{}}, func(string) error { return nil })
+		} else {
+			_, err = provider.Reply(ctx, ReplyRequest{Tools: &fakeToolbox{}})
+		}
+		if err != nil {
+			t.Fatalf("stream=%v: reply failed: %v", stream, err)
+		}
+		if stream && (*requests)[0].StreamOptions == nil {
+			t.Fatalf("expected stream_options.include_usage on streamed requests")
+		}
+		// The tool round and the final answer are billed separately; the
+		// non-streamed fixture only reports usage for the tool round.
+		want := Usage{Provider: ModeOpenAI, Model: "test", InputTokens: 100, OutputTokens: 20}
+		wantCalls := 1
+		if stream {
+			wantCalls = 2
+		}
+		if len(*got) != wantCalls || (*got)[0] != want {
+			t.Fatalf("stream=%v: unexpected usage %+v", stream, *got)
+		}
+	}
+}
+
+func TestAnthropicAndOllamaReportUsage(t *testing.T) {
+	anthropic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"usage":{"input_tokens":50,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Ок"}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+			`{"type":"message_stop"}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}))
+	defer anthropic.Close()
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"Сводка"},"done":true,"prompt_eval_count":30,"eval_count":4}`)
+	}))
+	defer ollama.Close()
+
+	ctx, got := collectUsage(context.Background())
+	if _, err := NewAnthropicProvider(testConfig(anthropic.URL)).ReplyStream(ctx, userRequest(nil), func(string) error { return nil }); err != nil {
+		t.Fatalf("anthropic stream failed: %v", err)
+	}
+	if _, err := NewOllamaProvider(testConfig(ollama.URL)).Summarize(ctx, SummaryRequest{}); err != nil {
+		t.Fatalf("ollama summarize failed: %v", err)
+	}
+
+	want := []Usage{
+		{Provider: ModeAnthropic, Model: "test-model", InputTokens: 50, OutputTokens: 7},
+		{Provider: ModeOllama, Model: "test-model", InputTokens: 30, OutputTokens: 4},
+	}
+	if len(*got) != len(want) || (*got)[0] != want[0] || (*got)[1] != want[1] {
+		t.Fatalf("unexpected usage %+v", *got)
+	}
+}
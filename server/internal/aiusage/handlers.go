@@ -0,0 +1,98 @@
+package aiusage
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/fdg312/health-hub/internal/auth"
+	"github.com/fdg312/health-hub/internal/config"
+	"github.com/fdg312/health-hub/internal/logging"
+)
+
+type Handler struct {
+	service *Service
+	config  *config.Config
+}
+
+// NewHandler builds the usage handlers; cfg supplies ADMIN_USER_IDS for the
+// admin aggregate.
+func NewHandler(service *Service, cfg *config.Config) *Handler {
+	return &Handler{service: service, config: cfg}
+}
+
+// HandleGet handles GET /v1/ai/usage?days=N.
+func (h *Handler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	days := 0
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "days must be between 1 and 90")
+			return
+		}
+		days = parsed
+	}
+
+	resp, err := h.service.Get(r.Context(), days)
+	if err != nil {
+		if errors.Is(err, ErrInvalidRequest) {
+			writeError(w, http.StatusBadRequest, "invalid_request", "days must be between 1 and 90")
+			return
+		}
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleAdminAggregate handles GET /v1/admin/ai/usage?from=&to=.
+func (h *Handler) HandleAdminAggregate(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r.Context())
+	if userID == "" {
+		h.handleError(w, r, ErrUnauthorized)
+		return
+	}
+	if !auth.IsAdmin(h.config, userID) {
+		writeError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		return
+	}
+
+	query := r.URL.Query()
+	resp, err := h.service.Aggregate(r.Context(), query.Get("from"), query.Get("to"))
+	if err != nil {
+		if errors.Is(err, ErrInvalidRequest) {
+			writeError(w, http.StatusBadRequest, "invalid_request", "from and to must be YYYY-MM-DD, from <= to, at most a year apart")
+			return
+		}
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+	default:
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{
+		Error: ErrorDetail{
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
+		},
+	})
+}
@@ -0,0 +1,128 @@
+package aiusage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/ai"
+	"github.com/fdg312/health-hub/internal/config"
+	"github.com/fdg312/health-hub/internal/storage/memory"
+	"github.com/fdg312/health-hub/internal/userctx"
+)
+
+func doGet(t *testing.T, handle http.HandlerFunc, target, userID string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req = req.WithContext(userctx.WithUserID(context.Background(), userID))
+	w := httptest.NewRecorder()
+	handle(w, req)
+	return w
+}
+
+func newTestService(daily, monthly int, now time.Time) *Service {
+	service := NewService(memory.New().GetAIUsageStorage(), daily, monthly)
+	service.now = func() time.Time { return now }
+	return service
+}
+
+func TestQuotasAndUsageReport(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)
+	service := newTestService(1000, 2000, now)
+	handler := NewHandler(service, &config.Config{})
+
+	// Earlier this month and in the previous month.
+	service.now = func() time.Time { return now.AddDate(0, 0, -3) }
+	service.Record(ctx, "userA", ai.Usage{Provider: "openai", Model: "gpt-4o-mini", InputTokens: 500, OutputTokens: 100})
+	service.now = func() time.Time { return time.Date(2026, 9, 30, 23, 0, 0, 0, time.UTC) }
+	service.Record(ctx, "userA", ai.Usage{Provider: "openai", Model: "gpt-4o-mini", InputTokens: 5000})
+	service.now = func() time.Time { return now }
+
+	if ok, _ := service.WithinQuota(ctx, "userA"); !ok {
+		t.Fatalf("600 tokens this month must be within quota")
+	}
+	service.Record(ctx, "userA", ai.Usage{Provider: "openai", Model: "gpt-4o-mini", InputTokens: 700, OutputTokens: 200})
+	if ok, _ := service.WithinQuota(ctx, "userA"); !ok {
+		t.Fatalf("900 tokens today must be within the daily quota")
+	}
+	service.Record(ctx, "userA", ai.Usage{Provider: "anthropic", Model: "claude", InputTokens: 50, OutputTokens: 50})
+	if ok, _ := service.WithinQuota(ctx, "userA"); ok {
+		t.Fatalf("1000 tokens today must exhaust the daily quota")
+	}
+	if ok, _ := service.WithinQuota(ctx, "userB"); !ok {
+		t.Fatalf("quotas must be per owner")
+	}
+
+	w := doGet(t, handler.HandleGet, "/v1/ai/usage?days=7", "userA")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var resp UsageResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if resp.Daily.TotalTokens != 1000 || *resp.Daily.Remaining != 0 || resp.Daily.Requests != 2 {
+		t.Fatalf("unexpected daily quota %+v", resp.Daily)
+	}
+	if resp.Monthly.TotalTokens != 1600 || *resp.Monthly.Remaining != 400 || !resp.Monthly.ResetsAt.Equal(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected monthly quota %+v", resp.Monthly)
+	}
+	if len(resp.Days) != 2 || resp.Days[0].Date != "2026-10-15" || len(resp.Days[1].Models) != 2 {
+		t.Fatalf("unexpected days %+v", resp.Days)
+	}
+
+	if w := doGet(t, handler.HandleGet, "/v1/ai/usage?days=365", "userA"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for too many days, got %d", w.Code)
+	}
+	if w := doGet(t, handler.HandleGet, "/v1/ai/usage", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+
+	monthlyOnly := newTestService(0, 1600, now)
+	monthlyOnly.storage = service.storage
+	if ok, _ := monthlyOnly.WithinQuota(ctx, "userA"); ok {
+		t.Fatalf("1600 tokens this month must exhaust the monthly quota")
+	}
+	unlimited := newTestService(0, 0, now)
+	unlimited.storage = service.storage
+	if ok, _ := unlimited.WithinQuota(ctx, "userA"); !ok {
+		t.Fatalf("zero limits must disable quotas")
+	}
+}
+
+func TestAdminAggregateByModel(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	service := newTestService(0, 0, now)
+	handler := NewHandler(service, &config.Config{AdminUserIDs: []string{"admin"}})
+
+	service.Record(ctx, "userA", ai.Usage{Provider: "openai", Model: "gpt-4o-mini", InputTokens: 100, OutputTokens: 10})
+	service.Record(ctx, "userB", ai.Usage{Provider: "openai", Model: "gpt-4o-mini", InputTokens: 200, OutputTokens: 20})
+	service.Record(ctx, "userA", ai.Usage{Provider: "anthropic", Model: "claude", InputTokens: 1000, OutputTokens: 100})
+
+	if w := doGet(t, handler.HandleAdminAggregate, "/v1/admin/ai/usage", "userA"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for non-admin, got %d", w.Code)
+	}
+	if w := doGet(t, handler.HandleAdminAggregate, "/v1/admin/ai/usage?from=2026-10-20&to=2026-10-01", "admin"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for reversed range, got %d", w.Code)
+	}
+
+	w := doGet(t, handler.HandleAdminAggregate, "/v1/admin/ai/usage", "admin")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var resp AdminUsageResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if resp.From != "2026-10-01" || resp.To != "2026-10-18" || resp.Total.TotalTokens != 1430 || resp.Total.Requests != 3 {
+		t.Fatalf("unexpected totals %+v", resp)
+	}
+	if len(resp.Models) != 2 || resp.Models[0].Model != "claude" || resp.Models[1].Owners != 2 || resp.Models[1].TotalTokens != 330 {
+		t.Fatalf("unexpected models %+v", resp.Models)
+	}
+}
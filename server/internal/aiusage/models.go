@@ -0,0 +1,72 @@
+package aiusage
+
+import "time"
+
+// TotalsDTO sums token usage over a period. Requests counts provider
+// calls: a chat message with tool rounds makes several.
+type TotalsDTO struct {
+	Requests     int64 `json:"requests"`
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	TotalTokens  int64 `json:"total_tokens"`
+}
+
+// QuotaDTO describes one quota window. Limit 0 means unlimited, in which
+// case Remaining is omitted.
+type QuotaDTO struct {
+	TotalsDTO
+	Limit     int64     `json:"limit"`
+	Remaining *int64    `json:"remaining,omitempty"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+type ModelUsageDTO struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	TotalsDTO
+}
+
+type DayUsageDTO struct {
+	Date string `json:"date"` // YYYY-MM-DD, UTC
+	TotalsDTO
+	Models []ModelUsageDTO `json:"models"`
+}
+
+// UsageResponse is returned by GET /v1/ai/usage.
+type UsageResponse struct {
+	Daily   QuotaDTO      `json:"daily"`
+	Monthly QuotaDTO      `json:"monthly"`
+	Days    []DayUsageDTO `json:"days"`
+}
+
+type AdminModelUsageDTO struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Owners   int64  `json:"owners"`
+	TotalsDTO
+}
+
+// AdminUsageResponse is returned by GET /v1/admin/ai/usage.
+type AdminUsageResponse struct {
+	From   string               `json:"from"`
+	To     string               `json:"to"`
+	Total  TotalsDTO            `json:"total"`
+	Models []AdminModelUsageDTO `json:"models"`
+}
+
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func (t *TotalsDTO) add(requests, input, output int64) {
+	t.Requests += requests
+	t.InputTokens += input
+	t.OutputTokens += output
+	t.TotalTokens += input + output
+}
@@ -0,0 +1,208 @@
+package aiusage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/ai"
+	"github.com/fdg312/health-hub/internal/logging"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/userctx"
+)
+
+var (
+	ErrUnauthorized   = errors.New("unauthorized")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+const (
+	defaultDays = 30
+	maxDays     = 90
+	// maxAdminRange bounds the admin aggregate to about a year.
+	maxAdminRange = 366 * 24 * time.Hour
+)
+
+const dateLayout = "2006-01-02"
+
+// Service accounts AI token usage per owner and UTC day and enforces the
+// daily and calendar-month quotas. Limits of 0 disable a quota.
+type Service struct {
+	storage      storage.AIUsageStorage
+	dailyLimit   int64
+	monthlyLimit int64
+	now          func() time.Time
+}
+
+func NewService(usageStorage storage.AIUsageStorage, dailyLimit, monthlyLimit int) *Service {
+	return &Service{
+		storage:      usageStorage,
+		dailyLimit:   int64(dailyLimit),
+		monthlyLimit: int64(monthlyLimit),
+		now:          time.Now,
+	}
+}
+
+// Record adds one provider call to the owner's usage. Failures are only
+// logged: a reply already paid for must not be lost over accounting.
+func (s *Service) Record(ctx context.Context, ownerUserID string, usage ai.Usage) {
+	err := s.storage.AddAIUsage(
+		context.WithoutCancel(ctx),
+		strings.TrimSpace(ownerUserID),
+		s.now(),
+		usage.Provider,
+		usage.Model,
+		usage.InputTokens,
+		usage.OutputTokens,
+	)
+	if err != nil {
+		logging.FromContext(ctx).Error("ai usage not recorded", "error", err, "provider", usage.Provider, "model", usage.Model)
+	}
+}
+
+// WithinQuota reports whether the owner may make another AI call. The call
+// that crosses a limit is allowed to finish; the next one is refused.
+func (s *Service) WithinQuota(ctx context.Context, ownerUserID string) (bool, error) {
+	if s.dailyLimit == 0 && s.monthlyLimit == 0 {
+		return true, nil
+	}
+	today, monthStart := s.window()
+	from := today
+	if s.monthlyLimit > 0 {
+		from = monthStart
+	}
+	rows, err := s.storage.ListAIUsage(ctx, strings.TrimSpace(ownerUserID), from, today)
+	if err != nil {
+		return false, err
+	}
+	daily, monthly := sumWindows(rows, today, monthStart)
+	if s.dailyLimit > 0 && daily.TotalTokens >= s.dailyLimit {
+		return false, nil
+	}
+	if s.monthlyLimit > 0 && monthly.TotalTokens >= s.monthlyLimit {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Get returns the caller's quotas and per-day usage for the last days days.
+func (s *Service) Get(ctx context.Context, days int) (*UsageResponse, error) {
+	userID := userIDFromContext(ctx)
+	if userID == "" {
+		return nil, ErrUnauthorized
+	}
+	if days == 0 {
+		days = defaultDays
+	}
+	if days < 1 || days > maxDays {
+		return nil, ErrInvalidRequest
+	}
+
+	today, monthStart := s.window()
+	from := today.AddDate(0, 0, -(days - 1))
+	queryFrom := from
+	if monthStart.Before(queryFrom) {
+		queryFrom = monthStart
+	}
+	rows, err := s.storage.ListAIUsage(ctx, userID, queryFrom, today)
+	if err != nil {
+		return nil, err
+	}
+
+	daily, monthly := sumWindows(rows, today, monthStart)
+	resp := &UsageResponse{
+		Daily:   quota(daily, s.dailyLimit, today.AddDate(0, 0, 1)),
+		Monthly: quota(monthly, s.monthlyLimit, monthStart.AddDate(0, 1, 0)),
+		Days:    make([]DayUsageDTO, 0),
+	}
+	for _, row := range rows {
+		if row.Day.Before(from) {
+			continue
+		}
+		date := row.Day.Format(dateLayout)
+		if n := len(resp.Days); n == 0 || resp.Days[n-1].Date != date {
+			resp.Days = append(resp.Days, DayUsageDTO{Date: date, Models: make([]ModelUsageDTO, 0, 1)})
+		}
+		day := &resp.Days[len(resp.Days)-1]
+		day.add(row.Requests, row.InputTokens, row.OutputTokens)
+		model := ModelUsageDTO{Provider: row.Provider, Model: row.Model}
+		model.add(row.Requests, row.InputTokens, row.OutputTokens)
+		day.Models = append(day.Models, model)
+	}
+	return resp, nil
+}
+
+// Aggregate sums usage of all owners per model for the admin report.
+// Dates are inclusive YYYY-MM-DD; the current month is the default.
+func (s *Service) Aggregate(ctx context.Context, fromRaw, toRaw string) (*AdminUsageResponse, error) {
+	today, monthStart := s.window()
+	from, to := monthStart, today
+	var err error
+	if fromRaw != "" {
+		if from, err = time.Parse(dateLayout, fromRaw); err != nil {
+			return nil, ErrInvalidRequest
+		}
+	}
+	if toRaw != "" {
+		if to, err = time.Parse(dateLayout, toRaw); err != nil {
+			return nil, ErrInvalidRequest
+		}
+	}
+	if to.Before(from) || to.Sub(from) > maxAdminRange {
+		return nil, ErrInvalidRequest
+	}
+
+	rows, err := s.storage.AggregateAIUsage(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	resp := &AdminUsageResponse{
+		From:   from.Format(dateLayout),
+		To:     to.Format(dateLayout),
+		Models: make([]AdminModelUsageDTO, 0, len(rows)),
+	}
+	for _, row := range rows {
+		model := AdminModelUsageDTO{Provider: row.Provider, Model: row.Model, Owners: row.Owners}
+		model.add(row.Requests, row.InputTokens, row.OutputTokens)
+		resp.Models = append(resp.Models, model)
+		resp.Total.add(row.Requests, row.InputTokens, row.OutputTokens)
+	}
+	return resp, nil
+}
+
+// window returns today and the first day of the month, both UTC midnight.
+func (s *Service) window() (time.Time, time.Time) {
+	y, m, d := s.now().UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func sumWindows(rows []storage.AIUsageDay, today, monthStart time.Time) (TotalsDTO, TotalsDTO) {
+	var daily, monthly TotalsDTO
+	for _, row := range rows {
+		if !row.Day.Before(monthStart) {
+			monthly.add(row.Requests, row.InputTokens, row.OutputTokens)
+		}
+		if row.Day.Equal(today) {
+			daily.add(row.Requests, row.InputTokens, row.OutputTokens)
+		}
+	}
+	return daily, monthly
+}
+
+func quota(used TotalsDTO, limit int64, resetsAt time.Time) QuotaDTO {
+	q := QuotaDTO{TotalsDTO: used, Limit: limit, ResetsAt: resetsAt}
+	if limit > 0 {
+		remaining := max(limit-used.TotalTokens, 0)
+		q.Remaining = &remaining
+	}
+	return q
+}
+
+func userIDFromContext(ctx context.Context) string {
+	userID, ok := userctx.GetUserID(ctx)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(userID)
+}
@@ -0,0 +1,229 @@
+// Package audioprobe reads the duration of uploaded voice notes from their
+// container headers, without decoding the audio: MPEG-4 (m4a), Ogg (Opus
+// and Vorbis) and WAV.
+package audioprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidAudio is returned for data that does not parse as the declared
+// audio type.
+var ErrInvalidAudio = errors.New("invalid audio")
+
+// ErrUnsupportedType is returned for content types the package cannot read.
+var ErrUnsupportedType = errors.New("unsupported audio type")
+
+// Duration returns the playing time of data declared as contentType.
+func Duration(data []byte, contentType string) (time.Duration, error) {
+	switch Normalize(contentType) {
+	case "audio/mp4":
+		return mp4Duration(data)
+	case "audio/ogg":
+		return oggDuration(data)
+	case "audio/wav":
+		return wavDuration(data)
+	default:
+		return 0, ErrUnsupportedType
+	}
+}
+
+// Normalize maps the aliases clients send for the same container to one
+// content type: audio/mp4, audio/ogg or audio/wav. Other types are returned
+// lower-cased without parameters.
+func Normalize(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch mediaType {
+	case "audio/mp4", "audio/m4a", "audio/x-m4a", "audio/aac", "audio/mp4a-latm":
+		return "audio/mp4"
+	case "audio/ogg", "audio/opus", "application/ogg":
+		return "audio/ogg"
+	case "audio/wav", "audio/x-wav", "audio/wave", "audio/vnd.wave":
+		return "audio/wav"
+	default:
+		return mediaType
+	}
+}
+
+// Extension returns the file extension of a normalized content type.
+func Extension(contentType string) string {
+	switch Normalize(contentType) {
+	case "audio/mp4":
+		return ".m4a"
+	case "audio/ogg":
+		return ".ogg"
+	case "audio/wav":
+		return ".wav"
+	default:
+		return ".bin"
+	}
+}
+
+// mp4Duration reads the movie header: moov/mvhd holds the timescale and the
+// duration in its units.
+func mp4Duration(data []byte) (time.Duration, error) {
+	moov, ok := findBox(data, "moov")
+	if !ok {
+		return 0, ErrInvalidAudio
+	}
+	mvhd, ok := findBox(moov, "mvhd")
+	if !ok || len(mvhd) < 4 {
+		return 0, ErrInvalidAudio
+	}
+
+	var timescale uint32
+	var units uint64
+	switch mvhd[0] {
+	case 0:
+		if len(mvhd) < 20 {
+			return 0, ErrInvalidAudio
+		}
+		timescale = binary.BigEndian.Uint32(mvhd[12:16])
+		units = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+	case 1:
+		if len(mvhd) < 32 {
+			return 0, ErrInvalidAudio
+		}
+		timescale = binary.BigEndian.Uint32(mvhd[20:24])
+		units = binary.BigEndian.Uint64(mvhd[24:32])
+	default:
+		return 0, ErrInvalidAudio
+	}
+	if timescale == 0 {
+		return 0, ErrInvalidAudio
+	}
+	return scale(units, uint64(timescale)), nil
+}
+
+// findBox returns the payload of the first top-level box of the given type.
+func findBox(data []byte, boxType string) ([]byte, bool) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[:4]))
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, false
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return nil, false
+		}
+		if string(data[4:8]) == boxType {
+			return data[header:size], true
+		}
+		data = data[size:]
+	}
+	return nil, false
+}
+
+// oggDuration takes the granule position of the last page of the first
+// logical stream and the sample rate from its identification header.
+func oggDuration(data []byte) (time.Duration, error) {
+	var serial uint32
+	var rate, preSkip uint64
+	var last int64 = -1
+	first := true
+
+	for len(data) > 0 {
+		if len(data) < 27 || !bytes.HasPrefix(data, []byte("OggS")) {
+			return 0, ErrInvalidAudio
+		}
+		granule := int64(binary.LittleEndian.Uint64(data[6:14]))
+		pageSerial := binary.LittleEndian.Uint32(data[14:18])
+		segments := int(data[26])
+		if len(data) < 27+segments {
+			return 0, ErrInvalidAudio
+		}
+		bodyLen := 0
+		for _, lacing := range data[27 : 27+segments] {
+			bodyLen += int(lacing)
+		}
+		headerLen := 27 + segments
+		if len(data) < headerLen+bodyLen {
+			return 0, ErrInvalidAudio
+		}
+		body := data[headerLen : headerLen+bodyLen]
+
+		if first {
+			serial = pageSerial
+			switch {
+			case len(body) >= 19 && bytes.HasPrefix(body, []byte("OpusHead")):
+				// Opus granules always count 48 kHz samples.
+				rate = 48000
+				preSkip = uint64(binary.LittleEndian.Uint16(body[10:12]))
+			case len(body) >= 16 && bytes.HasPrefix(body, []byte("\x01vorbis")):
+				rate = uint64(binary.LittleEndian.Uint32(body[12:16]))
+			default:
+				return 0, ErrInvalidAudio
+			}
+			first = false
+		}
+		// -1 marks pages where no packet ends.
+		if pageSerial == serial && granule >= 0 {
+			last = granule
+		}
+		data = data[headerLen+bodyLen:]
+	}
+
+	if rate == 0 || last < 0 {
+		return 0, ErrInvalidAudio
+	}
+	samples := uint64(last)
+	if samples < preSkip {
+		return 0, nil
+	}
+	return scale(samples-preSkip, rate), nil
+}
+
+// wavDuration divides the size of the data chunk by the byte rate from the
+// fmt chunk.
+func wavDuration(data []byte) (time.Duration, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return 0, ErrInvalidAudio
+	}
+	var byteRate, dataSize uint64
+	for chunks := data[12:]; len(chunks) >= 8; {
+		id := string(chunks[:4])
+		size := uint64(binary.LittleEndian.Uint32(chunks[4:8]))
+		body := chunks[8:]
+		switch id {
+		case "fmt ":
+			if size < 16 || uint64(len(body)) < 16 {
+				return 0, ErrInvalidAudio
+			}
+			byteRate = uint64(binary.LittleEndian.Uint32(body[8:12]))
+		case "data":
+			// Streaming writers leave the size unset; count what is there.
+			dataSize = min(size, uint64(len(body)))
+		}
+		if byteRate > 0 && dataSize > 0 {
+			break
+		}
+		next := size + size%2
+		if next > uint64(len(body)) {
+			break
+		}
+		chunks = body[next:]
+	}
+	if byteRate == 0 {
+		return 0, ErrInvalidAudio
+	}
+	return scale(dataSize, byteRate), nil
+}
+
+// scale converts units counted at rate per second to a duration.
+func scale(units, rate uint64) time.Duration {
+	seconds := units / rate
+	rest := units % rate
+	return time.Duration(seconds)*time.Second + time.Duration(rest*uint64(time.Second)/rate)
+}
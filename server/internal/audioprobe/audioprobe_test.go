@@ -0,0 +1,169 @@
+package audioprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+		want        time.Duration
+	}{
+		{"wav", "audio/x-wav", testWAV(8000, 16000), 2 * time.Second},
+		{"m4a", "audio/x-m4a", testM4A(0, 1000, 3500), 3500 * time.Millisecond},
+		{"m4a 64-bit header", "audio/mp4", testM4A(1, 44100, 44100*90), 90 * time.Second},
+		{"opus", "audio/ogg; codecs=opus", testOgg(opusHead(312), 48000*2+312), 2 * time.Second},
+		{"vorbis", "audio/ogg", testOgg(vorbisHead(44100), 44100*5/2), 2500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Duration(tt.data, tt.contentType)
+			if err != nil {
+				t.Fatalf("Duration failed: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Duration = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationRejectsInvalidData(t *testing.T) {
+	wav := testWAV(8000, 16000)
+	for name, tt := range map[string]struct {
+		contentType string
+		data        []byte
+		want        error
+	}{
+		"wav without header":  {"audio/wav", []byte("RIFF"), ErrInvalidAudio},
+		"wav as m4a":          {"audio/mp4", wav, ErrInvalidAudio},
+		"truncated ogg":       {"audio/ogg", testOgg(opusHead(0), 48000)[:40], ErrInvalidAudio},
+		"ogg of another kind": {"audio/ogg", testOgg([]byte("\x80theora-header"), 10), ErrInvalidAudio},
+		"mp3":                 {"audio/mpeg", wav, ErrUnsupportedType},
+	} {
+		if _, err := Duration(tt.data, tt.contentType); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", name, tt.want, err)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{
+		"audio/x-m4a":            "audio/mp4",
+		"Audio/MP4":              "audio/mp4",
+		"audio/ogg; codecs=opus": "audio/ogg",
+		"audio/wave":             "audio/wav",
+		"audio/mpeg":             "audio/mpeg",
+	} {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// testWAV is a PCM WAV with dataSize bytes of silence.
+func testWAV(byteRate, dataSize uint32) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+dataSize))
+	b.WriteString("WAVEfmt ")
+	for _, v := range []any{uint32(16), uint16(1), uint16(1), byteRate / 2, byteRate, uint16(2), uint16(16)} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, dataSize)
+	b.Write(make([]byte, dataSize))
+	return b.Bytes()
+}
+
+func testM4A(version byte, timescale uint32, units uint64) []byte {
+	var mvhd bytes.Buffer
+	mvhd.Write([]byte{version, 0, 0, 0})
+	if version == 1 {
+		mvhd.Write(make([]byte, 16))
+		binary.Write(&mvhd, binary.BigEndian, timescale)
+		binary.Write(&mvhd, binary.BigEndian, units)
+	} else {
+		mvhd.Write(make([]byte, 8))
+		binary.Write(&mvhd, binary.BigEndian, timescale)
+		binary.Write(&mvhd, binary.BigEndian, uint32(units))
+	}
+	mvhd.Write(make([]byte, 80))
+
+	moov := box("mvhd", mvhd.Bytes())
+	out := box("ftyp", []byte("M4A \x00\x00\x00\x00isom"))
+	if version == 1 {
+		// A 64-bit size header, as some muxers write for large boxes.
+		var large bytes.Buffer
+		binary.Write(&large, binary.BigEndian, uint32(1))
+		large.WriteString("moov")
+		binary.Write(&large, binary.BigEndian, uint64(16+len(moov)))
+		large.Write(moov)
+		return append(out, large.Bytes()...)
+	}
+	return append(out, box("moov", moov)...)
+}
+
+func box(boxType string, payload []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, uint32(8+len(payload)))
+	b.WriteString(boxType)
+	b.Write(payload)
+	return b.Bytes()
+}
+
+func opusHead(preSkip uint16) []byte {
+	var b bytes.Buffer
+	b.WriteString("OpusHead")
+	b.Write([]byte{1, 1})
+	binary.Write(&b, binary.LittleEndian, preSkip)
+	binary.Write(&b, binary.LittleEndian, uint32(16000))
+	b.Write([]byte{0, 0, 0})
+	return b.Bytes()
+}
+
+func vorbisHead(rate uint32) []byte {
+	var b bytes.Buffer
+	b.WriteString("\x01vorbis")
+	binary.Write(&b, binary.LittleEndian, uint32(0))
+	b.WriteByte(1)
+	binary.Write(&b, binary.LittleEndian, rate)
+	b.Write(make([]byte, 14))
+	return b.Bytes()
+}
+
+// testOgg is a stream of a header page, an audio page and a last page
+// ending at granule.
+func testOgg(head []byte, granule int64) []byte {
+	var out []byte
+	out = append(out, oggPage(0, head)...)
+	out = append(out, oggPage(-1, make([]byte, 300))...)
+	return append(out, oggPage(granule, make([]byte, 50))...)
+}
+
+func oggPage(granule int64, body []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("OggS")
+	b.Write([]byte{0, 0})
+	binary.Write(&b, binary.LittleEndian, granule)
+	binary.Write(&b, binary.LittleEndian, uint32(7))
+	b.Write(make([]byte, 8))
+	var lacing []byte
+	for n := len(body); ; n -= 255 {
+		if n < 255 {
+			lacing = append(lacing, byte(n))
+			break
+		}
+		lacing = append(lacing, 255)
+	}
+	b.WriteByte(byte(len(lacing)))
+	b.Write(lacing)
+	b.Write(body)
+	return b.Bytes()
+}
@@ -0,0 +1,25 @@
+package audit
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type requestMetaKey struct{}
+
+// requestMeta is attached to the request context by Middleware.
+type requestMeta struct {
+	ip string
+	// recorded is set once a service hook records an event, so the
+	// middleware does not add a second, less specific one.
+	recorded atomic.Bool
+}
+
+func withRequestMeta(ctx context.Context, meta *requestMeta) context.Context {
+	return context.WithValue(ctx, requestMetaKey{}, meta)
+}
+
+func requestMetaFrom(ctx context.Context) *requestMeta {
+	meta, _ := ctx.Value(requestMetaKey{}).(*requestMeta)
+	return meta
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// HandleList handles GET /v1/audit.
+func (h *Handler) HandleList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := ListParams{
+		Action:       strings.TrimSpace(query.Get("action")),
+		ResourceType: strings.TrimSpace(query.Get("resource_type")),
+	}
+
+	if raw := strings.TrimSpace(query.Get("profile_id")); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "profile_id must be a valid UUID")
+			return
+		}
+		params.ProfileID = &id
+	}
+	if raw := strings.TrimSpace(query.Get("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid_request", "limit must be a positive integer")
+			return
+		}
+		params.Limit = limit
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"from", &params.From},
+		{"to", &params.To},
+		{"before", &params.Before},
+	} {
+		raw := strings.TrimSpace(query.Get(p.name))
+		if raw == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", p.name+" must be RFC3339 timestamp")
+			return
+		}
+		*p.dst = &ts
+	}
+
+	resp, err := h.service.List(r.Context(), params)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnauthorized):
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		case errors.Is(err, ErrInvalidRequest):
+			writeError(w, http.StatusBadRequest, "invalid_request", "from must be before to")
+		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{
+		Error: ErrorDetail{
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
+		},
+	})
+}
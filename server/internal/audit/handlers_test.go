@@ -0,0 +1,196 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/logging"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/storage/memory"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+func setupAudit(t *testing.T) (*Service, *memory.MemoryStorage, uuid.UUID) {
+	t.Helper()
+
+	mem := memory.New()
+	profileA := uuid.New()
+	if err := mem.CreateProfile(context.Background(), &storage.Profile{
+		ID:          profileA,
+		OwnerUserID: "userA",
+		Type:        "owner",
+		Name:        "User A",
+	}); err != nil {
+		t.Fatalf("create profile failed: %v", err)
+	}
+
+	return NewService(mem.GetAuditStorage(), mem, 30), mem, profileA
+}
+
+func fixedIP(*http.Request) string { return "203.0.113.1" }
+
+// withUser mimics the auth middleware, which runs outside the audit middleware.
+func withUser(userID string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(userctx.WithUserID(r.Context(), userID)))
+	})
+}
+
+func listEvents(t *testing.T, svc *Service, userID, query string) ListResponse {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/audit"+query, nil)
+	req = req.WithContext(userctx.WithUserID(context.Background(), userID))
+	w := httptest.NewRecorder()
+	NewHandler(svc).HandleList(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	var resp ListResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	return resp
+}
+
+func TestMiddlewareRecordsMutationWithProfileFromBody(t *testing.T) {
+	svc, _, profileA := setupAudit(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/checkins", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["profile_id"] == nil {
+			t.Errorf("handler should still see the JSON body, got %v (err=%v)", body, err)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("DELETE /v1/checkins/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	handler := logging.Middleware(logger, fixedIP, withUser("userA", svc.Middleware(fixedIP, mux)))
+
+	body, _ := json.Marshal(map[string]any{"profile_id": profileA, "date": "2026-02-13"})
+	req := httptest.NewRequest(http.MethodPost, "/v1/checkins", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", "req-123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Failed mutations are not recorded.
+	req = httptest.NewRequest(http.MethodDelete, "/v1/checkins/"+uuid.NewString(), nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	resp := listEvents(t, svc, "userA", "")
+	if len(resp.Events) != 1 {
+		t.Fatalf("expected 1 event, got %+v", resp.Events)
+	}
+	event := resp.Events[0]
+	if event.Action != ActionCreate || event.ResourceType != "checkins" {
+		t.Fatalf("unexpected action/resource: %+v", event)
+	}
+	if event.ProfileID == nil || *event.ProfileID != profileA {
+		t.Fatalf("expected profile_id %s, got %v", profileA, event.ProfileID)
+	}
+	if event.ActorUserID != "userA" || event.RequestID != "req-123" || event.IP != "203.0.113.1" {
+		t.Fatalf("unexpected actor/request metadata: %+v", event)
+	}
+
+	if other := listEvents(t, svc, "userB", ""); len(other.Events) != 0 {
+		t.Fatalf("userB must not see userA events, got %+v", other.Events)
+	}
+}
+
+func TestMiddlewareSkipsWhenHookRecorded(t *testing.T) {
+	svc, _, profileA := setupAudit(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/messages", func(w http.ResponseWriter, r *http.Request) {
+		svc.Record(r.Context(), Event{
+			ProfileID:    &profileA,
+			Action:       ActionChatSend,
+			ResourceType: "chat/messages",
+			ResourceID:   "msg-1",
+		})
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("POST /v1/ai/proposals/{id}/apply", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := withUser("userA", svc.Middleware(fixedIP, mux))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/chat/messages", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/ai/proposals/p-42/apply", nil))
+
+	resp := listEvents(t, svc, "userA", "")
+	if len(resp.Events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", resp.Events)
+	}
+	byAction := map[string]EventDTO{}
+	for _, e := range resp.Events {
+		byAction[e.Action] = e
+	}
+	if e, ok := byAction[ActionChatSend]; !ok || e.ResourceID != "msg-1" {
+		t.Fatalf("expected hook event for chat send, got %+v", resp.Events)
+	}
+	if e, ok := byAction[ActionCreate]; !ok || e.ResourceType != "ai/proposals/apply" || e.ResourceID != "p-42" {
+		t.Fatalf("expected generic event for proposal apply, got %+v", resp.Events)
+	}
+
+	filtered := listEvents(t, svc, "userA", "?action="+ActionChatSend+"&profile_id="+profileA.String())
+	if len(filtered.Events) != 1 {
+		t.Fatalf("expected 1 filtered event, got %+v", filtered.Events)
+	}
+}
+
+func TestHandleListRequiresUser(t *testing.T) {
+	svc, _, _ := setupAudit(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/audit", nil)
+	w := httptest.NewRecorder()
+	NewHandler(svc).HandleList(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/audit?from=yesterday", nil)
+	req = req.WithContext(userctx.WithUserID(context.Background(), "userA"))
+	w = httptest.NewRecorder()
+	NewHandler(svc).HandleList(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestPurgeExpiredHonoursRetention(t *testing.T) {
+	svc, _, profileA := setupAudit(t)
+	ctx := userctx.WithUserID(context.Background(), "userA")
+
+	now := time.Date(2026, 2, 13, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now.AddDate(0, 0, -31) }
+	svc.Record(ctx, Event{ProfileID: &profileA, Action: ActionReportDownload, ResourceType: "reports"})
+	svc.now = func() time.Time { return now.AddDate(0, 0, -1) }
+	svc.Record(ctx, Event{ProfileID: &profileA, Action: ActionSourceDownload, ResourceType: "sources"})
+
+	svc.now = func() time.Time { return now }
+	deleted, err := svc.PurgeExpired(context.Background())
+	if err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected 1 purged event, got %d", deleted)
+	}
+
+	resp := listEvents(t, svc, "userA", "")
+	if len(resp.Events) != 1 || resp.Events[0].Action != ActionSourceDownload {
+		t.Fatalf("expected only recent event to remain, got %+v", resp.Events)
+	}
+}
@@ -0,0 +1,153 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/fdg312/health-hub/internal/logging"
+	"github.com/google/uuid"
+)
+
+// maxPeekBytes bounds how much of a JSON body is read to find profile_id.
+const maxPeekBytes = 1 << 20
+
+// Middleware attaches request metadata to the context and records every
+// successful mutation that no service hook has already recorded.
+// It must run inside the auth middleware so the actor is known.
+func (s *Service) Middleware(clientIP func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		meta := &requestMeta{ip: clientIP(r)}
+		r = r.WithContext(withRequestMeta(r.Context(), meta))
+
+		if !isMutation(r.Method) || isExcludedPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bodyProfileID := peekProfileID(r)
+		if bodyProfileID != nil {
+			logging.SetProfileID(r.Context(), bodyProfileID.String())
+		}
+		rec := logging.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		if rec.Status() >= 400 || meta.recorded.Load() {
+			return
+		}
+
+		// r.Pattern is set by ServeMux on the request we passed in.
+		resourceType, resourceID := resourceFromRequest(r)
+		if resourceType == "" {
+			return
+		}
+		event := Event{
+			Action:       actionForMethod(r.Method),
+			ResourceType: resourceType,
+			ResourceID:   resourceID,
+		}
+		if resourceType == "profiles" {
+			if id, err := uuid.Parse(resourceID); err == nil {
+				event.ProfileID = &id
+			}
+		}
+		if event.ProfileID == nil {
+			event.ProfileID = profileIDFromQuery(r)
+		}
+		if event.ProfileID == nil {
+			event.ProfileID = bodyProfileID
+		}
+		s.Record(r.Context(), event)
+	})
+}
+
+func isMutation(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// isExcludedPath skips auth endpoints: there is no actor yet and OTP abuse
+// has its own ledger.
+func isExcludedPath(path string) bool {
+	return path == "/healthz" || strings.HasPrefix(path, "/v1/auth/")
+}
+
+func actionForMethod(method string) string {
+	switch method {
+	case http.MethodPost:
+		return ActionCreate
+	case http.MethodDelete:
+		return ActionDelete
+	default:
+		return ActionUpdate
+	}
+}
+
+// resourceFromRequest derives the resource type from the matched route
+// ("POST /v1/ai/proposals/{id}/apply" -> "ai/proposals/apply") and the
+// resource ID from the {id} wildcard or a trailing-slash route suffix.
+func resourceFromRequest(r *http.Request) (string, string) {
+	pattern := r.Pattern
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		pattern = path
+	}
+	if pattern == "" {
+		return "", ""
+	}
+
+	segments := make([]string, 0, 4)
+	for _, seg := range strings.Split(strings.TrimPrefix(pattern, "/v1/"), "/") {
+		if seg == "" || strings.HasPrefix(seg, "{") {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	resourceType := strings.Join(segments, "/")
+
+	resourceID := r.PathValue("id")
+	if resourceID == "" && strings.HasSuffix(pattern, "/") {
+		resourceID = strings.Trim(strings.TrimPrefix(r.URL.Path, pattern), "/")
+	}
+	return resourceType, resourceID
+}
+
+func profileIDFromQuery(r *http.Request) *uuid.UUID {
+	if id, err := uuid.Parse(r.URL.Query().Get("profile_id")); err == nil {
+		return &id
+	}
+	return nil
+}
+
+// peekProfileID reads a top-level "profile_id" from a JSON body and restores
+// the body for the handler.
+func peekProfileID(r *http.Request) *uuid.UUID {
+	if r.Body == nil || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return nil
+	}
+	if r.ContentLength > maxPeekBytes {
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBytes+1))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+	if err != nil || len(data) > maxPeekBytes {
+		return nil
+	}
+
+	var probe struct {
+		ProfileID string `json:"profile_id"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil
+	}
+	if id, err := uuid.Parse(probe.ProfileID); err == nil {
+		return &id
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions recorded by service hooks. Generic mutations captured by the
+// middleware use ActionCreate/ActionUpdate/ActionDelete.
+const (
+	ActionCreate         = "create"
+	ActionUpdate         = "update"
+	ActionDelete         = "delete"
+	ActionReportDownload = "report.download"
+	ActionSourceDownload = "source.download"
+	ActionChatRead       = "chat.read"
+	ActionChatSend       = "chat.send"
+)
+
+// Event is what callers pass to Recorder.Record. Actor, request ID, IP and
+// timestamp are filled from the request context.
+type Event struct {
+	ProfileID    *uuid.UUID
+	Action       string
+	ResourceType string
+	ResourceID   string
+}
+
+type EventDTO struct {
+	ID           uuid.UUID  `json:"id"`
+	ActorUserID  string     `json:"actor_user_id"`
+	ProfileID    *uuid.UUID `json:"profile_id,omitempty"`
+	Action       string     `json:"action"`
+	ResourceType string     `json:"resource_type"`
+	ResourceID   string     `json:"resource_id,omitempty"`
+	RequestID    string     `json:"request_id,omitempty"`
+	IP           string     `json:"ip,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type ListParams struct {
+	ProfileID    *uuid.UUID
+	Action       string
+	ResourceType string
+	From         *time.Time
+	To           *time.Time
+	Before       *time.Time
+	Limit        int
+}
+
+type ListResponse struct {
+	Events     []EventDTO `json:"events"`
+	NextCursor *string    `json:"next_cursor,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
@@ -0,0 +1,183 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/logging"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnauthorized   = errors.New("unauthorized")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// Recorder is the hook services use to append audit events. Recording never
+// fails the caller's request; storage errors are logged.
+type Recorder interface {
+	Record(ctx context.Context, event Event)
+}
+
+type profileGetter interface {
+	GetProfile(ctx context.Context, id uuid.UUID) (*storage.Profile, error)
+}
+
+type Service struct {
+	storage       storage.AuditStorage
+	profiles      profileGetter
+	retentionDays int
+	now           func() time.Time
+}
+
+func NewService(auditStorage storage.AuditStorage, profiles profileGetter, retentionDays int) *Service {
+	return &Service{
+		storage:       auditStorage,
+		profiles:      profiles,
+		retentionDays: retentionDays,
+		now:           time.Now,
+	}
+}
+
+// Record appends an event on behalf of the current request's user.
+func (s *Service) Record(ctx context.Context, event Event) {
+	actor := strings.TrimSpace(userIDFromContext(ctx))
+	if actor == "" {
+		actor = "anonymous"
+	}
+
+	// The owner is whoever owns the profile; events are listed per owner so
+	// they stay visible to the owner when someone else acts on a shared profile.
+	owner := actor
+	if event.ProfileID != nil && s.profiles != nil {
+		if profile, err := s.profiles.GetProfile(ctx, *event.ProfileID); err == nil && profile != nil && profile.OwnerUserID != "" {
+			owner = profile.OwnerUserID
+		}
+	}
+
+	row := storage.AuditEvent{
+		ID:           uuid.New(),
+		OwnerUserID:  owner,
+		ActorUserID:  actor,
+		ProfileID:    event.ProfileID,
+		Action:       event.Action,
+		ResourceType: event.ResourceType,
+		ResourceID:   event.ResourceID,
+		RequestID:    logging.RequestID(ctx),
+		CreatedAt:    s.now().UTC(),
+	}
+	if meta := requestMetaFrom(ctx); meta != nil {
+		row.IP = meta.ip
+		meta.recorded.Store(true)
+	}
+
+	// Detach from request cancellation so a client disconnect does not drop the event.
+	if err := s.storage.InsertAuditEvent(context.WithoutCancel(ctx), row); err != nil {
+		logging.FromContext(ctx).Error("audit: failed to record event",
+			"action", row.Action, "resource_type", row.ResourceType, "resource_id", row.ResourceID, "error", err)
+	}
+}
+
+// List returns the current user's audit events, newest first.
+func (s *Service) List(ctx context.Context, params ListParams) (*ListResponse, error) {
+	userID := strings.TrimSpace(userIDFromContext(ctx))
+	if userID == "" {
+		return nil, ErrUnauthorized
+	}
+	if params.From != nil && params.To != nil && !params.From.Before(*params.To) {
+		return nil, ErrInvalidRequest
+	}
+
+	limit := normalizeLimit(params.Limit)
+	rows, err := s.storage.ListAuditEvents(ctx, storage.AuditFilter{
+		OwnerUserID:  userID,
+		ProfileID:    params.ProfileID,
+		Action:       params.Action,
+		ResourceType: params.ResourceType,
+		From:         params.From,
+		To:           params.To,
+		Before:       params.Before,
+		Limit:        limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]EventDTO, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, EventDTO{
+			ID:           row.ID,
+			ActorUserID:  row.ActorUserID,
+			ProfileID:    row.ProfileID,
+			Action:       row.Action,
+			ResourceType: row.ResourceType,
+			ResourceID:   row.ResourceID,
+			RequestID:    row.RequestID,
+			IP:           row.IP,
+			CreatedAt:    row.CreatedAt,
+		})
+	}
+
+	var nextCursor *string
+	if len(rows) == limit {
+		cursor := rows[len(rows)-1].CreatedAt.UTC().Format(time.RFC3339Nano)
+		nextCursor = &cursor
+	}
+	return &ListResponse{Events: events, NextCursor: nextCursor}, nil
+}
+
+// PurgeExpired deletes events older than the retention period.
+// A non-positive retention keeps events forever.
+func (s *Service) PurgeExpired(ctx context.Context) (int64, error) {
+	if s.retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := s.now().UTC().AddDate(0, 0, -s.retentionDays)
+	return s.storage.DeleteAuditEventsBefore(ctx, cutoff)
+}
+
+// RunRetention purges expired events every interval until ctx is cancelled.
+func (s *Service) RunRetention(ctx context.Context, interval time.Duration) {
+	if s.retentionDays <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := s.PurgeExpired(ctx); err != nil {
+			slog.Error("audit: retention purge failed", "error", err)
+		} else if deleted > 0 {
+			slog.Info("audit: retention purged events", "deleted", deleted, "retention_days", s.retentionDays)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func userIDFromContext(ctx context.Context) string {
+	userID, ok := userctx.GetUserID(ctx)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(userID)
+}
+
+func normalizeLimit(limit int) int {
+	if limit <= 0 {
+		return 50
+	}
+	if limit > 200 {
+		return 200
+	}
+	return limit
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/fdg312/health-hub/internal/config"
+)
+
+// IsAdmin reports whether the user is listed in ADMIN_USER_IDS.
+func IsAdmin(cfg *config.Config, userID string) bool {
+	if cfg == nil || userID == "" {
+		return false
+	}
+	for _, id := range cfg.AdminUserIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAdmin writes 401/403 and returns false when the caller is not an admin.
+func (h *Handlers) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	userID, ok := GetUserID(r.Context())
+	if !ok || userID == "" {
+		writeErrorResponse(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return false
+	}
+	if !IsAdmin(h.service.config, userID) {
+		writeErrorResponse(w, http.StatusForbidden, "forbidden", "Admin access required")
+		return false
+	}
+	return true
+}
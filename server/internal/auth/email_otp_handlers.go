@@ -4,13 +4,18 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"strings"
 
 	"github.com/fdg312/health-hub/internal/auth/emailotp"
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 type EmailOTPRequest struct {
-	Email string `json:"email"`
+	Email             string `json:"email"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+	ChallengeSolution string `json:"challenge_solution,omitempty"`
 }
 
 type EmailOTPVerifyRequest struct {
@@ -18,6 +23,12 @@ type EmailOTPVerifyRequest struct {
 	Code  string `json:"code"`
 }
 
+// EmailOTPChallengeResponse is the error body returned when a proof-of-work challenge is required.
+type EmailOTPChallengeResponse struct {
+	Error     ErrorDetail         `json:"error"`
+	Challenge *emailotp.Challenge `json:"challenge"`
+}
+
 // HandleEmailOTPRequest handles POST /v1/auth/email/request.
 func (h *Handlers) HandleEmailOTPRequest(w http.ResponseWriter, r *http.Request) {
 	if h.emailOTPService == nil {
@@ -35,9 +46,13 @@ func (h *Handlers) HandleEmailOTPRequest(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	resp, err := h.emailOTPService.Request(r.Context(), req.Email)
+	resp, err := h.emailOTPService.Request(r.Context(), req.Email, emailotp.ClientMeta{
+		IP:                h.clientIP(r),
+		ChallengeToken:    req.ChallengeToken,
+		ChallengeSolution: req.ChallengeSolution,
+	})
 	if err != nil {
-		h.writeEmailOTPError(w, err)
+		h.writeEmailOTPError(w, r, err)
 		return
 	}
 
@@ -63,9 +78,9 @@ func (h *Handlers) HandleEmailOTPVerify(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	resp, err := h.emailOTPService.Verify(r.Context(), req.Email, req.Code)
+	resp, err := h.emailOTPService.Verify(r.Context(), req.Email, req.Code, emailotp.ClientMeta{IP: h.clientIP(r)})
 	if err != nil {
-		h.writeEmailOTPError(w, err)
+		h.writeEmailOTPError(w, r, err)
 		return
 	}
 
@@ -74,12 +89,112 @@ func (h *Handlers) HandleEmailOTPVerify(w http.ResponseWriter, r *http.Request)
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
-func (h *Handlers) writeEmailOTPError(w http.ResponseWriter, err error) {
+func (h *Handlers) writeEmailOTPError(w http.ResponseWriter, r *http.Request, err error) {
 	var serviceErr *emailotp.ServiceError
 	if errors.As(err, &serviceErr) {
+		if serviceErr.RetryAfterSeconds > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(serviceErr.RetryAfterSeconds))
+		}
+		if serviceErr.Challenge != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(serviceErr.Status)
+			_ = json.NewEncoder(w).Encode(EmailOTPChallengeResponse{
+				Error:     ErrorDetail{Code: serviceErr.Code, Message: serviceErr.Message, RequestID: logging.ResponseRequestID(w)},
+				Challenge: serviceErr.Challenge,
+			})
+			return
+		}
 		writeErrorResponse(w, serviceErr.Status, serviceErr.Code, serviceErr.Message)
 		return
 	}
 
+	logging.FromContext(r.Context()).Error("request failed", "error", err)
 	writeErrorResponse(w, http.StatusInternalServerError, "internal_error", "Internal server error")
 }
+
+// HandleAdminOTPAbuseList handles GET /v1/admin/otp-abuse.
+func (h *Handlers) HandleAdminOTPAbuseList(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	if h.emailOTPService == nil {
+		writeErrorResponse(w, http.StatusNotFound, "email_auth_disabled", "Email auth is disabled")
+		return
+	}
+
+	query := r.URL.Query()
+	limit := 0
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			writeErrorResponse(w, http.StatusBadRequest, "invalid_request", "limit must be a positive integer")
+			return
+		}
+		limit = parsed
+	}
+	onlyLocked := query.Get("locked") == "1" || strings.EqualFold(query.Get("locked"), "true")
+
+	records, err := h.emailOTPService.ListAbuse(r.Context(), strings.TrimSpace(query.Get("scope")), onlyLocked, limit)
+	if err != nil {
+		h.writeEmailOTPError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(emailotp.AbuseListResponse{Records: records})
+}
+
+// HandleAdminOTPAbuseReset handles DELETE /v1/admin/otp-abuse/{scope}/{key}.
+func (h *Handlers) HandleAdminOTPAbuseReset(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	if h.emailOTPService == nil {
+		writeErrorResponse(w, http.StatusNotFound, "email_auth_disabled", "Email auth is disabled")
+		return
+	}
+
+	if err := h.emailOTPService.ResetAbuse(r.Context(), r.PathValue("scope"), r.PathValue("key")); err != nil {
+		h.writeEmailOTPError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientIP returns the address the abuse ledger is keyed by. X-Forwarded-For
+// is set by the client, so only hops appended by trusted proxies count: the
+// list is walked from the right and the first untrusted hop is the client.
+// Without trusted proxies the peer address is used as is.
+func (h *Handlers) clientIP(r *http.Request) string {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	client := peer.Addr().Unmap()
+	if !h.isTrustedProxy(client) {
+		return client.String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = hop.Unmap()
+		if !h.isTrustedProxy(client) {
+			break
+		}
+	}
+	return client.String()
+}
+
+func (h *Handlers) isTrustedProxy(addr netip.Addr) bool {
+	for _, prefix := range h.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
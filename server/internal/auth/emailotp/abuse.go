@@ -0,0 +1,310 @@
+package emailotp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+)
+
+// abusePolicy is the effective set of ledger thresholds, with defaults
+// applied for zero config values.
+type abusePolicy struct {
+	window                 time.Duration
+	ipMaxRequests          int
+	challengeAfterRequests int
+	challengeAfterFailures int
+	lockoutAfterFailures   int
+	lockoutBase            time.Duration
+	lockoutMax             time.Duration
+}
+
+func (s *Service) abusePolicy() abusePolicy {
+	p := abusePolicy{
+		window:                 time.Duration(positiveOr(s.cfg.OTPAbuseWindowSeconds, 3600)) * time.Second,
+		ipMaxRequests:          positiveOr(s.cfg.OTPIPMaxRequestsPerWindow, 30),
+		challengeAfterRequests: positiveOr(s.cfg.OTPChallengeAfterRequests, 5),
+		challengeAfterFailures: positiveOr(s.cfg.OTPChallengeAfterFailures, 3),
+		lockoutAfterFailures:   positiveOr(s.cfg.OTPLockoutAfterFailures, 10),
+		lockoutBase:            time.Duration(positiveOr(s.cfg.OTPLockoutBaseSeconds, 60)) * time.Second,
+		lockoutMax:             time.Duration(positiveOr(s.cfg.OTPLockoutMaxSeconds, 86400)) * time.Second,
+	}
+	if p.lockoutMax < p.lockoutBase {
+		p.lockoutMax = p.lockoutBase
+	}
+	return p
+}
+
+// loadAbuseRecords returns the email entry followed by the IP entry (when the
+// IP is known), with expired counting windows and stale lockouts rolled over.
+func (s *Service) loadAbuseRecords(ctx context.Context, email, ip string, now time.Time, p abusePolicy) ([]storage.EmailOTPAbuseRecord, error) {
+	keys := [][2]string{{storage.OTPAbuseScopeEmail, email}}
+	if ip != "" {
+		keys = append(keys, [2]string{storage.OTPAbuseScopeIP, ip})
+	}
+
+	records := make([]storage.EmailOTPAbuseRecord, 0, len(keys))
+	for _, k := range keys {
+		rec, err := s.abuse.GetAbuseRecord(ctx, k[0], k[1])
+		if err != nil {
+			return nil, err
+		}
+		if rec == nil {
+			records = append(records, storage.EmailOTPAbuseRecord{
+				Scope:       k[0],
+				Key:         k[1],
+				WindowStart: now,
+				LastEventAt: now,
+			})
+			continue
+		}
+		if now.Sub(rec.WindowStart) >= p.window {
+			rec.WindowStart = now
+			rec.Requests = 0
+			rec.Failures = 0
+		}
+		// Forget the escalation level once a lockout has been over for a full max period.
+		if rec.LockedUntil != nil && now.Sub(*rec.LockedUntil) >= p.lockoutMax {
+			rec.LockedUntil = nil
+			rec.LockoutLevel = 0
+		}
+		records = append(records, *rec)
+	}
+	return records, nil
+}
+
+func (s *Service) saveAbuseRecords(ctx context.Context, records []storage.EmailOTPAbuseRecord) error {
+	for _, rec := range records {
+		if err := s.abuse.UpsertAbuseRecord(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockOut escalates the record's lockout: base * 2^(level-1), capped at max.
+func lockOut(rec *storage.EmailOTPAbuseRecord, now time.Time, p abusePolicy) {
+	rec.LockoutLevel++
+	duration := p.lockoutMax
+	if shift := rec.LockoutLevel - 1; shift < 32 {
+		if d := p.lockoutBase * time.Duration(1<<shift); d > 0 && d < p.lockoutMax {
+			duration = d
+		}
+	}
+	lockedUntil := now.Add(duration)
+	rec.LockedUntil = &lockedUntil
+	rec.WindowStart = now
+	rec.Requests = 0
+	rec.Failures = 0
+	rec.LastEventAt = now
+}
+
+func lockedOutError(records []storage.EmailOTPAbuseRecord, now time.Time) *ServiceError {
+	var until time.Time
+	for _, rec := range records {
+		if rec.LockedUntil != nil && rec.LockedUntil.After(now) && rec.LockedUntil.After(until) {
+			until = *rec.LockedUntil
+		}
+	}
+	if until.IsZero() {
+		return nil
+	}
+	return &ServiceError{
+		Status:            http.StatusTooManyRequests,
+		Code:              "otp_locked_out",
+		Message:           "Too many attempts, try again later",
+		RetryAfterSeconds: int(math.Ceil(until.Sub(now).Seconds())),
+	}
+}
+
+// guardRequest counts an OTP send request against the ledger and decides
+// whether it may proceed, must solve a challenge, or is locked out.
+func (s *Service) guardRequest(ctx context.Context, email string, meta ClientMeta, now time.Time) error {
+	p := s.abusePolicy()
+	records, err := s.loadAbuseRecords(ctx, email, meta.IP, now, p)
+	if err != nil {
+		return err
+	}
+	if lockedErr := lockedOutError(records, now); lockedErr != nil {
+		return lockedErr
+	}
+
+	// tripped holds the entries over a challenge threshold; the challenge is
+	// bound to one of them, so an IP-triggered challenge covers that IP and
+	// not just the email it was first issued for.
+	var tripped []storage.EmailOTPAbuseRecord
+	for i := range records {
+		rec := &records[i]
+		rec.Requests++
+		rec.LastEventAt = now
+		if rec.Scope == storage.OTPAbuseScopeIP && rec.Requests > p.ipMaxRequests {
+			lockOut(rec, now, p)
+		}
+		if rec.Requests > p.challengeAfterRequests || rec.Failures >= p.challengeAfterFailures {
+			tripped = append(tripped, *rec)
+		}
+	}
+	if err := s.saveAbuseRecords(ctx, records); err != nil {
+		return err
+	}
+	if lockedErr := lockedOutError(records, now); lockedErr != nil {
+		return lockedErr
+	}
+	if len(tripped) == 0 {
+		return nil
+	}
+
+	if strings.TrimSpace(meta.ChallengeToken) == "" {
+		return s.challengeError(tripped[0], now, "otp_challenge_required", "Solve the challenge to request another code")
+	}
+	nonce, expiresAt, ok := s.verifyChallenge(meta.ChallengeToken, meta.ChallengeSolution, tripped, now)
+	if ok {
+		// Every solved challenge is single-use until it expires.
+		if ok, err = s.abuse.UseChallengeNonce(ctx, nonce, expiresAt, now); err != nil {
+			return err
+		}
+	}
+	if !ok {
+		return s.challengeError(tripped[0], now, "otp_challenge_invalid", "Challenge solution is invalid or expired")
+	}
+	return nil
+}
+
+func (s *Service) challengeError(rec storage.EmailOTPAbuseRecord, now time.Time, code, message string) error {
+	challenge, err := s.issueChallenge(rec.Scope, rec.Key, now)
+	if err != nil {
+		return err
+	}
+	return &ServiceError{
+		Status:    http.StatusForbidden,
+		Code:      code,
+		Message:   message,
+		Challenge: challenge,
+	}
+}
+
+// guardVerify rejects verification while the email or IP is locked out.
+func (s *Service) guardVerify(ctx context.Context, email, ip string, now time.Time) ([]storage.EmailOTPAbuseRecord, error) {
+	records, err := s.loadAbuseRecords(ctx, email, ip, now, s.abusePolicy())
+	if err != nil {
+		return nil, err
+	}
+	if lockedErr := lockedOutError(records, now); lockedErr != nil {
+		return nil, lockedErr
+	}
+	return records, nil
+}
+
+// recordFailure counts a failed verification and locks out keys that cross the threshold.
+func (s *Service) recordFailure(ctx context.Context, records []storage.EmailOTPAbuseRecord, now time.Time) error {
+	p := s.abusePolicy()
+	for i := range records {
+		rec := &records[i]
+		rec.Failures++
+		rec.LastEventAt = now
+		if rec.Failures >= p.lockoutAfterFailures {
+			lockOut(rec, now, p)
+		}
+	}
+	return s.saveAbuseRecords(ctx, records)
+}
+
+// recordSuccess decays the email's failure counter after a successful login.
+// The entry is kept rather than deleted: owning the mailbox must not be a way
+// to wipe the lockout escalation level or skip a pending challenge.
+func (s *Service) recordSuccess(ctx context.Context, records []storage.EmailOTPAbuseRecord, now time.Time) error {
+	for _, rec := range records {
+		if rec.Scope != storage.OTPAbuseScopeEmail {
+			continue
+		}
+		rec.Failures /= 2
+		rec.LastEventAt = now
+		return s.abuse.UpsertAbuseRecord(ctx, rec)
+	}
+	return nil
+}
+
+// ListAbuse returns ledger entries for admin review.
+func (s *Service) ListAbuse(ctx context.Context, scope string, onlyLocked bool, limit int) ([]AbuseRecordDTO, error) {
+	if s.abuse == nil {
+		return []AbuseRecordDTO{}, nil
+	}
+	if scope != "" && scope != storage.OTPAbuseScopeEmail && scope != storage.OTPAbuseScopeIP {
+		return nil, &ServiceError{
+			Status:  http.StatusBadRequest,
+			Code:    "invalid_request",
+			Message: "scope must be email or ip",
+		}
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	now := s.now()
+	filter := storage.EmailOTPAbuseFilter{Scope: scope, Limit: limit}
+	if onlyLocked {
+		filter.LockedAt = now
+	}
+	records, err := s.abuse.ListAbuseRecords(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]AbuseRecordDTO, 0, len(records))
+	for _, rec := range records {
+		result = append(result, AbuseRecordDTO{
+			Scope:        rec.Scope,
+			Key:          rec.Key,
+			WindowStart:  rec.WindowStart,
+			Requests:     rec.Requests,
+			Failures:     rec.Failures,
+			LockoutLevel: rec.LockoutLevel,
+			Locked:       rec.LockedUntil != nil && rec.LockedUntil.After(now),
+			LockedUntil:  rec.LockedUntil,
+			LastEventAt:  rec.LastEventAt,
+		})
+	}
+	return result, nil
+}
+
+// ResetAbuse clears a ledger entry, lifting any lockout.
+func (s *Service) ResetAbuse(ctx context.Context, scope, key string) error {
+	if scope != storage.OTPAbuseScopeEmail && scope != storage.OTPAbuseScopeIP {
+		return &ServiceError{
+			Status:  http.StatusBadRequest,
+			Code:    "invalid_request",
+			Message: "scope must be email or ip",
+		}
+	}
+	if scope == storage.OTPAbuseScopeEmail {
+		key = normalizeEmail(key)
+	}
+
+	deleted := false
+	if s.abuse != nil {
+		var err error
+		deleted, err = s.abuse.DeleteAbuseRecord(ctx, scope, strings.TrimSpace(key))
+		if err != nil {
+			return err
+		}
+	}
+	if !deleted {
+		return &ServiceError{
+			Status:  http.StatusNotFound,
+			Code:    "not_found",
+			Message: fmt.Sprintf("no abuse record for %s %q", scope, key),
+		}
+	}
+	return nil
+}
+
+func positiveOr(v, fallback int) int {
+	if v <= 0 {
+		return fallback
+	}
+	return v
+}
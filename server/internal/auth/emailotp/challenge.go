@@ -0,0 +1,143 @@
+package emailotp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+)
+
+const challengeAlgorithm = "sha256-leading-zero-bits"
+
+type challengeClaims struct {
+	Scope      string `json:"scp"`
+	Subject    string `json:"sub"`
+	Nonce      string `json:"n"`
+	Difficulty int    `json:"d"`
+	ExpiresAt  int64  `json:"exp"`
+}
+
+// issueChallenge returns a stateless, HMAC-signed proof-of-work challenge
+// bound to the ledger key (email or IP) that required it.
+func (s *Service) issueChallenge(scope, key string, now time.Time) (*Challenge, error) {
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	ttl := s.cfg.OTPChallengeTTLSeconds
+	if ttl <= 0 {
+		ttl = 300
+	}
+	difficulty := s.cfg.OTPChallengeDifficulty
+	if difficulty <= 0 {
+		difficulty = 18
+	}
+
+	expiresAt := now.Add(time.Duration(ttl) * time.Second)
+	claims := challengeClaims{
+		Scope:      scope,
+		Subject:    key,
+		Nonce:      base64.RawURLEncoding.EncodeToString(nonce),
+		Difficulty: difficulty,
+		ExpiresAt:  expiresAt.Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return &Challenge{
+		Token:      encoded + "." + s.signChallenge(encoded),
+		Algorithm:  challengeAlgorithm,
+		Difficulty: difficulty,
+		ExpiresAt:  time.Unix(claims.ExpiresAt, 0).UTC(),
+	}, nil
+}
+
+// verifyChallenge checks signature, expiry, the proof-of-work and that the
+// challenge is bound to one of the tripped ledger keys. It returns the
+// challenge nonce and expiry so callers can reject replays.
+func (s *Service) verifyChallenge(token, solution string, tripped []storage.EmailOTPAbuseRecord, now time.Time) (string, time.Time, bool) {
+	encoded, sig, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || encoded == "" || sig == "" {
+		return "", time.Time{}, false
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.signChallenge(encoded))) {
+		return "", time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	var claims challengeClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", time.Time{}, false
+	}
+	if !challengeBound(claims, tripped) || now.Unix() > claims.ExpiresAt {
+		return "", time.Time{}, false
+	}
+
+	solution = strings.TrimSpace(solution)
+	if solution == "" || len(solution) > 64 {
+		return "", time.Time{}, false
+	}
+	if leadingZeroBits(challengeDigest(token, solution)) < claims.Difficulty {
+		return "", time.Time{}, false
+	}
+	return claims.Nonce, time.Unix(claims.ExpiresAt, 0), true
+}
+
+func challengeBound(claims challengeClaims, tripped []storage.EmailOTPAbuseRecord) bool {
+	for _, rec := range tripped {
+		if claims.Scope == rec.Scope && claims.Subject == rec.Key {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) signChallenge(encoded string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.OTPSecret))
+	mac.Write([]byte("otp-challenge:"))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SolveChallenge brute-forces a solution for the given token. It is what a
+// client is expected to do and is used by tests and the smoke tool.
+func SolveChallenge(token string, difficulty int) string {
+	for i := 0; ; i++ {
+		solution := strconv.Itoa(i)
+		if leadingZeroBits(challengeDigest(token, solution)) >= difficulty {
+			return solution
+		}
+	}
+}
+
+func challengeDigest(token, solution string) []byte {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token) + ":" + solution))
+	return sum[:]
+}
+
+func leadingZeroBits(sum []byte) int {
+	count := 0
+	for _, b := range sum {
+		if b == 0 {
+			count += 8
+			continue
+		}
+		count += bits.LeadingZeros8(b)
+		break
+	}
+	return count
+}
@@ -0,0 +1,59 @@
+package emailotp
+
+import "strings"
+
+// disposableEmailDomains is a built-in list of well-known throwaway mail
+// providers. Operators can extend it with OTP_BLOCKED_EMAIL_DOMAINS.
+var disposableEmailDomains = []string{
+	"10minutemail.com",
+	"dispostable.com",
+	"emailondeck.com",
+	"fakeinbox.com",
+	"getnada.com",
+	"guerrillamail.com",
+	"guerrillamail.net",
+	"mailinator.com",
+	"maildrop.cc",
+	"mintemail.com",
+	"mohmal.com",
+	"sharklasers.com",
+	"temp-mail.org",
+	"tempmail.com",
+	"tempmailo.com",
+	"throwawaymail.com",
+	"trashmail.com",
+	"yopmail.com",
+}
+
+// isBlockedDomain reports whether the email's domain (or any parent domain)
+// is on the configured or built-in blocklist.
+func (s *Service) isBlockedDomain(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.TrimSuffix(email[at+1:], ".")
+
+	for _, blocked := range s.cfg.OTPBlockedEmailDomains {
+		if domainMatches(domain, blocked) {
+			return true
+		}
+	}
+	if !s.cfg.OTPBlockDisposableEmails {
+		return false
+	}
+	for _, blocked := range disposableEmailDomains {
+		if domainMatches(domain, blocked) {
+			return true
+		}
+	}
+	return false
+}
+
+func domainMatches(domain, blocked string) bool {
+	blocked = strings.ToLower(strings.TrimSpace(blocked))
+	if blocked == "" {
+		return false
+	}
+	return domain == blocked || strings.HasSuffix(domain, "."+blocked)
+}
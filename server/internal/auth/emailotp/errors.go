@@ -7,6 +7,11 @@ type ServiceError struct {
 	Status  int
 	Code    string
 	Message string
+
+	// RetryAfterSeconds is set for lockouts so handlers can emit Retry-After.
+	RetryAfterSeconds int
+	// Challenge is set when the client must solve a proof-of-work puzzle.
+	Challenge *Challenge
 }
 
 func (e *ServiceError) Error() string {
@@ -1,5 +1,7 @@
 package emailotp
 
+import "time"
+
 type RequestResponse struct {
 	Status    string  `json:"status"`
 	DebugCode *string `json:"debug_code,omitempty"`
@@ -11,3 +13,35 @@ type VerifyResponse struct {
 	ExpiresIn   int64  `json:"expires_in"`
 	UserID      string `json:"user_id"`
 }
+
+// ClientMeta carries request metadata used by abuse protection.
+type ClientMeta struct {
+	IP                string
+	ChallengeToken    string
+	ChallengeSolution string
+}
+
+// Challenge is a proof-of-work puzzle: find a solution such that
+// sha256(token + ":" + solution) starts with Difficulty zero bits.
+type Challenge struct {
+	Token      string    `json:"token"`
+	Algorithm  string    `json:"algorithm"`
+	Difficulty int       `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type AbuseRecordDTO struct {
+	Scope        string     `json:"scope"`
+	Key          string     `json:"key"`
+	WindowStart  time.Time  `json:"window_start"`
+	Requests     int        `json:"requests"`
+	Failures     int        `json:"failures"`
+	LockoutLevel int        `json:"lockout_level"`
+	Locked       bool       `json:"locked"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+	LastEventAt  time.Time  `json:"last_event_at"`
+}
+
+type AbuseListResponse struct {
+	Records []AbuseRecordDTO `json:"records"`
+}
@@ -28,6 +28,7 @@ type Service struct {
 	cfg     *config.Config
 	storage storage.EmailOTPStorage
 	sender  mailer.Sender
+	abuse   storage.EmailOTPAbuseStorage
 
 	now          func() time.Time
 	generateCode func() (string, error)
@@ -43,7 +44,13 @@ func NewService(cfg *config.Config, otpStorage storage.EmailOTPStorage, sender m
 	}
 }
 
-func (s *Service) Request(ctx context.Context, emailRaw string) (*RequestResponse, error) {
+// WithAbuseLedger enables per-email/per-IP lockouts and proof-of-work challenges.
+func (s *Service) WithAbuseLedger(abuse storage.EmailOTPAbuseStorage) *Service {
+	s.abuse = abuse
+	return s
+}
+
+func (s *Service) Request(ctx context.Context, emailRaw string, meta ClientMeta) (*RequestResponse, error) {
 	if !s.cfg.EmailAuthEnabled {
 		return nil, &ServiceError{
 			Status:  http.StatusNotFound,
@@ -63,8 +70,21 @@ func (s *Service) Request(ctx context.Context, emailRaw string) (*RequestRespons
 			Message: "Invalid email format",
 		}
 	}
+	if s.isBlockedDomain(email) {
+		return nil, &ServiceError{
+			Status:  http.StatusBadRequest,
+			Code:    "email_domain_blocked",
+			Message: "Email domain is not allowed",
+		}
+	}
 
 	now := s.now()
+	if s.abuse != nil {
+		if err := s.guardRequest(ctx, email, meta, now); err != nil {
+			return nil, err
+		}
+	}
+
 	latest, err := s.storage.GetLatestActive(ctx, email, now)
 	if err != nil {
 		return nil, err
@@ -124,7 +144,7 @@ func (s *Service) Request(ctx context.Context, emailRaw string) (*RequestRespons
 	return resp, nil
 }
 
-func (s *Service) Verify(ctx context.Context, emailRaw, codeRaw string) (*VerifyResponse, error) {
+func (s *Service) Verify(ctx context.Context, emailRaw, codeRaw string, meta ClientMeta) (*VerifyResponse, error) {
 	if !s.cfg.EmailAuthEnabled {
 		return nil, &ServiceError{
 			Status:  http.StatusNotFound,
@@ -154,16 +174,34 @@ func (s *Service) Verify(ctx context.Context, emailRaw, codeRaw string) (*Verify
 	}
 
 	now := s.now()
+	var abuseRecords []storage.EmailOTPAbuseRecord
+	if s.abuse != nil {
+		records, err := s.guardVerify(ctx, email, meta.IP, now)
+		if err != nil {
+			return nil, err
+		}
+		abuseRecords = records
+	}
+	// fail records a failed attempt in the abuse ledger before returning the error.
+	fail := func(serviceErr *ServiceError) (*VerifyResponse, error) {
+		if s.abuse != nil {
+			if err := s.recordFailure(ctx, abuseRecords, now); err != nil {
+				return nil, err
+			}
+		}
+		return nil, serviceErr
+	}
+
 	otp, err := s.storage.GetLatestActive(ctx, email, now)
 	if err != nil {
 		return nil, err
 	}
 	if otp == nil {
-		return nil, &ServiceError{
+		return fail(&ServiceError{
 			Status:  http.StatusUnauthorized,
 			Code:    "otp_expired_or_not_found",
 			Message: "OTP not found or expired",
-		}
+		})
 	}
 
 	maxAttempts := otp.MaxAttempts
@@ -171,11 +209,11 @@ func (s *Service) Verify(ctx context.Context, emailRaw, codeRaw string) (*Verify
 		maxAttempts = s.cfg.OTPMaxAttempts
 	}
 	if otp.Attempts >= maxAttempts {
-		return nil, &ServiceError{
+		return fail(&ServiceError{
 			Status:  http.StatusUnauthorized,
 			Code:    "otp_locked",
 			Message: "OTP is locked due to too many failed attempts",
-		}
+		})
 	}
 
 	expectedHash := HashCode(email, code, s.cfg.OTPSecret)
@@ -183,16 +221,21 @@ func (s *Service) Verify(ctx context.Context, emailRaw, codeRaw string) (*Verify
 		if err := s.storage.IncrementAttempts(ctx, otp.ID); err != nil {
 			return nil, err
 		}
-		return nil, &ServiceError{
+		return fail(&ServiceError{
 			Status:  http.StatusUnauthorized,
 			Code:    "otp_invalid_code",
 			Message: "Invalid OTP code",
-		}
+		})
 	}
 
 	if err := s.storage.MarkUsedOrDelete(ctx, otp.ID); err != nil {
 		return nil, err
 	}
+	if s.abuse != nil {
+		if err := s.recordSuccess(ctx, abuseRecords, now); err != nil {
+			return nil, err
+		}
+	}
 
 	userID := "email:" + email
 	token, expiresIn, err := s.generateAccessToken(userID)
@@ -2,6 +2,7 @@ package emailotp
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -67,7 +68,7 @@ func newHarness(t *testing.T, emailEnabled bool) *testHarness {
 func TestRequestCreatesOTPAndSendsEmail(t *testing.T) {
 	h := newHarness(t, true)
 
-	resp, err := h.service.Request(context.Background(), " User@Example.com ")
+	resp, err := h.service.Request(context.Background(), " User@Example.com ", ClientMeta{})
 	if err != nil {
 		t.Fatalf("request otp failed: %v", err)
 	}
@@ -99,11 +100,11 @@ func TestRequestCreatesOTPAndSendsEmail(t *testing.T) {
 func TestVerifyCorrectCodeReturnsAccessToken(t *testing.T) {
 	h := newHarness(t, true)
 
-	if _, err := h.service.Request(context.Background(), "user@example.com"); err != nil {
+	if _, err := h.service.Request(context.Background(), "user@example.com", ClientMeta{}); err != nil {
 		t.Fatalf("request otp failed: %v", err)
 	}
 
-	resp, err := h.service.Verify(context.Background(), "user@example.com", "123456")
+	resp, err := h.service.Verify(context.Background(), "user@example.com", "123456", ClientMeta{})
 	if err != nil {
 		t.Fatalf("verify failed: %v", err)
 	}
@@ -118,11 +119,11 @@ func TestVerifyCorrectCodeReturnsAccessToken(t *testing.T) {
 func TestVerifyWrongCodeIncrementsAttempts(t *testing.T) {
 	h := newHarness(t, true)
 
-	if _, err := h.service.Request(context.Background(), "user@example.com"); err != nil {
+	if _, err := h.service.Request(context.Background(), "user@example.com", ClientMeta{}); err != nil {
 		t.Fatalf("request otp failed: %v", err)
 	}
 
-	_, err := h.service.Verify(context.Background(), "user@example.com", "000000")
+	_, err := h.service.Verify(context.Background(), "user@example.com", "000000", ClientMeta{})
 	if err == nil {
 		t.Fatal("expected error for wrong code")
 	}
@@ -147,13 +148,13 @@ func TestVerifyWrongCodeIncrementsAttempts(t *testing.T) {
 func TestVerifyLocksAfterMaxAttempts(t *testing.T) {
 	h := newHarness(t, true)
 
-	if _, err := h.service.Request(context.Background(), "user@example.com"); err != nil {
+	if _, err := h.service.Request(context.Background(), "user@example.com", ClientMeta{}); err != nil {
 		t.Fatalf("request otp failed: %v", err)
 	}
 
-	_, _ = h.service.Verify(context.Background(), "user@example.com", "000000")
-	_, _ = h.service.Verify(context.Background(), "user@example.com", "000000")
-	_, err := h.service.Verify(context.Background(), "user@example.com", "123456")
+	_, _ = h.service.Verify(context.Background(), "user@example.com", "000000", ClientMeta{})
+	_, _ = h.service.Verify(context.Background(), "user@example.com", "000000", ClientMeta{})
+	_, err := h.service.Verify(context.Background(), "user@example.com", "123456", ClientMeta{})
 	if err == nil {
 		t.Fatal("expected locked error")
 	}
@@ -167,11 +168,11 @@ func TestVerifyLocksAfterMaxAttempts(t *testing.T) {
 func TestRequestResendTooSoon(t *testing.T) {
 	h := newHarness(t, true)
 
-	if _, err := h.service.Request(context.Background(), "user@example.com"); err != nil {
+	if _, err := h.service.Request(context.Background(), "user@example.com", ClientMeta{}); err != nil {
 		t.Fatalf("request otp failed: %v", err)
 	}
 
-	_, err := h.service.Request(context.Background(), "user@example.com")
+	_, err := h.service.Request(context.Background(), "user@example.com", ClientMeta{})
 	if err == nil {
 		t.Fatal("expected resend rate limit")
 	}
@@ -187,17 +188,17 @@ func TestRequestRateLimitedByHourlyQuota(t *testing.T) {
 	h.service.cfg.OTPResendMinSeconds = 1
 	h.service.cfg.OTPMaxSendPerHour = 2
 
-	if _, err := h.service.Request(context.Background(), "user@example.com"); err != nil {
+	if _, err := h.service.Request(context.Background(), "user@example.com", ClientMeta{}); err != nil {
 		t.Fatalf("first request failed: %v", err)
 	}
 
 	*h.now = h.now.Add(2 * time.Second)
-	if _, err := h.service.Request(context.Background(), "user@example.com"); err != nil {
+	if _, err := h.service.Request(context.Background(), "user@example.com", ClientMeta{}); err != nil {
 		t.Fatalf("second request failed: %v", err)
 	}
 
 	*h.now = h.now.Add(2 * time.Second)
-	_, err := h.service.Request(context.Background(), "user@example.com")
+	_, err := h.service.Request(context.Background(), "user@example.com", ClientMeta{})
 	if err == nil {
 		t.Fatal("expected otp_rate_limited")
 	}
@@ -211,7 +212,7 @@ func TestRequestRateLimitedByHourlyQuota(t *testing.T) {
 func TestRequestWhenDisabled(t *testing.T) {
 	h := newHarness(t, false)
 
-	_, err := h.service.Request(context.Background(), "user@example.com")
+	_, err := h.service.Request(context.Background(), "user@example.com", ClientMeta{})
 	if err == nil {
 		t.Fatal("expected disabled error")
 	}
@@ -234,3 +235,270 @@ func TestHashCodeStable(t *testing.T) {
 		t.Fatal("expected different hashes for different code")
 	}
 }
+
+func newAbuseHarness(t *testing.T) *testHarness {
+	t.Helper()
+
+	h := newHarness(t, true)
+	mem := h.store.(*memory.MemoryStorage)
+	h.service.WithAbuseLedger(mem.GetEmailOTPAbuseStorage())
+	h.service.cfg.OTPResendMinSeconds = 1
+	h.service.cfg.OTPMaxSendPerHour = 50
+	h.service.cfg.OTPAbuseWindowSeconds = 3600
+	h.service.cfg.OTPIPMaxRequestsPerWindow = 100
+	h.service.cfg.OTPChallengeAfterRequests = 100
+	h.service.cfg.OTPChallengeAfterFailures = 100
+	h.service.cfg.OTPChallengeDifficulty = 4
+	h.service.cfg.OTPChallengeTTLSeconds = 300
+	h.service.cfg.OTPLockoutAfterFailures = 3
+	h.service.cfg.OTPLockoutBaseSeconds = 60
+	h.service.cfg.OTPLockoutMaxSeconds = 3600
+	return h
+}
+
+func expectServiceErrorCode(t *testing.T, err error, code string) *ServiceError {
+	t.Helper()
+	serviceErr, ok := AsServiceError(err)
+	if !ok || serviceErr.Code != code {
+		t.Fatalf("expected %s, got %v", code, err)
+	}
+	return serviceErr
+}
+
+func TestRequestBlocksDisposableDomains(t *testing.T) {
+	h := newHarness(t, true)
+	h.service.cfg.OTPBlockDisposableEmails = true
+	h.service.cfg.OTPBlockedEmailDomains = []string{"spam.example"}
+
+	_, err := h.service.Request(context.Background(), "user@mailinator.com", ClientMeta{})
+	expectServiceErrorCode(t, err, "email_domain_blocked")
+
+	_, err = h.service.Request(context.Background(), "user@mx.spam.example", ClientMeta{})
+	expectServiceErrorCode(t, err, "email_domain_blocked")
+
+	if h.sender.calls != 0 {
+		t.Fatalf("expected no emails sent, got %d", h.sender.calls)
+	}
+	if _, err := h.service.Request(context.Background(), "user@example.com", ClientMeta{}); err != nil {
+		t.Fatalf("regular domain should pass: %v", err)
+	}
+}
+
+func TestVerifyLockoutAcrossCodesEscalates(t *testing.T) {
+	h := newAbuseHarness(t)
+	ctx := context.Background()
+	meta := ClientMeta{IP: "203.0.113.7"}
+
+	failThree := func() {
+		t.Helper()
+		for i := 0; i < 3; i++ {
+			*h.now = h.now.Add(2 * time.Second)
+			if _, err := h.service.Request(ctx, "user@example.com", meta); err != nil {
+				t.Fatalf("request otp failed: %v", err)
+			}
+			_, err := h.service.Verify(ctx, "user@example.com", "000000", meta)
+			expectServiceErrorCode(t, err, "otp_invalid_code")
+		}
+	}
+
+	failThree()
+	_, err := h.service.Verify(ctx, "user@example.com", "123456", meta)
+	serviceErr := expectServiceErrorCode(t, err, "otp_locked_out")
+	if serviceErr.RetryAfterSeconds != 60 {
+		t.Fatalf("expected retry after 60s, got %d", serviceErr.RetryAfterSeconds)
+	}
+
+	// Different IP is still blocked because the email itself is locked.
+	_, err = h.service.Verify(ctx, "user@example.com", "123456", ClientMeta{IP: "198.51.100.1"})
+	expectServiceErrorCode(t, err, "otp_locked_out")
+
+	*h.now = h.now.Add(61 * time.Second)
+	failThree()
+	_, err = h.service.Request(ctx, "user@example.com", meta)
+	serviceErr = expectServiceErrorCode(t, err, "otp_locked_out")
+	if serviceErr.RetryAfterSeconds != 120 {
+		t.Fatalf("expected escalated retry after 120s, got %d", serviceErr.RetryAfterSeconds)
+	}
+}
+
+func TestVerifySuccessDecaysEmailLedger(t *testing.T) {
+	h := newAbuseHarness(t)
+	ctx := context.Background()
+	meta := ClientMeta{}
+
+	fail := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			*h.now = h.now.Add(2 * time.Second)
+			if _, err := h.service.Request(ctx, "user@example.com", meta); err != nil {
+				t.Fatalf("request otp failed: %v", err)
+			}
+			_, err := h.service.Verify(ctx, "user@example.com", "000000", meta)
+			expectServiceErrorCode(t, err, "otp_invalid_code")
+		}
+	}
+	login := func() {
+		t.Helper()
+		*h.now = h.now.Add(2 * time.Second)
+		if _, err := h.service.Request(ctx, "user@example.com", meta); err != nil {
+			t.Fatalf("request otp failed: %v", err)
+		}
+		if _, err := h.service.Verify(ctx, "user@example.com", "123456", meta); err != nil {
+			t.Fatalf("verify failed: %v", err)
+		}
+	}
+
+	fail(3)
+	*h.now = h.now.Add(61 * time.Second)
+	fail(2)
+	login()
+
+	records, err := h.service.ListAbuse(ctx, "email", false, 0)
+	if err != nil {
+		t.Fatalf("list abuse failed: %v", err)
+	}
+	if len(records) != 1 || records[0].Failures != 1 || records[0].LockoutLevel != 1 {
+		t.Fatalf("expected decayed failures and kept lockout level, got %+v", records)
+	}
+
+	// The login did not reset escalation: the next lockout is still doubled.
+	fail(2)
+	_, err = h.service.Request(ctx, "user@example.com", meta)
+	serviceErr := expectServiceErrorCode(t, err, "otp_locked_out")
+	if serviceErr.RetryAfterSeconds != 120 {
+		t.Fatalf("expected escalated retry after 120s, got %d", serviceErr.RetryAfterSeconds)
+	}
+}
+
+func TestRequestChallengeAfterIPThreshold(t *testing.T) {
+	h := newAbuseHarness(t)
+	h.service.cfg.OTPChallengeAfterRequests = 2
+	ctx := context.Background()
+	ip := "203.0.113.9"
+
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := h.service.Request(ctx, email, ClientMeta{IP: ip}); err != nil {
+			t.Fatalf("request for %s failed: %v", email, err)
+		}
+	}
+
+	_, err := h.service.Request(ctx, "c@example.com", ClientMeta{IP: ip})
+	serviceErr := expectServiceErrorCode(t, err, "otp_challenge_required")
+	if serviceErr.Challenge == nil || serviceErr.Challenge.Difficulty != 4 {
+		t.Fatalf("expected challenge with difficulty 4, got %+v", serviceErr.Challenge)
+	}
+	if h.sender.calls != 2 {
+		t.Fatalf("expected no email for challenged request, got %d calls", h.sender.calls)
+	}
+
+	token := serviceErr.Challenge.Token
+	solution := SolveChallenge(token, serviceErr.Challenge.Difficulty)
+
+	// The IP tripped the threshold, so the challenge is bound to the IP and
+	// covers whichever email it requests next.
+	if _, err := h.service.Request(ctx, "d@example.com", ClientMeta{IP: ip, ChallengeToken: token, ChallengeSolution: solution}); err != nil {
+		t.Fatalf("request with solved challenge failed: %v", err)
+	}
+
+	// Replaying the same solution is rejected.
+	*h.now = h.now.Add(2 * time.Second)
+	_, err = h.service.Request(ctx, "c@example.com", ClientMeta{IP: ip, ChallengeToken: token, ChallengeSolution: solution})
+	expectServiceErrorCode(t, err, "otp_challenge_invalid")
+}
+
+func TestRequestChallengeBoundToTrippedScope(t *testing.T) {
+	h := newAbuseHarness(t)
+	h.service.cfg.OTPChallengeAfterRequests = 1
+	ctx := context.Background()
+
+	// Trip the threshold for an IP and solve its challenge.
+	ip := "203.0.113.11"
+	if _, err := h.service.Request(ctx, "a@example.com", ClientMeta{IP: ip}); err != nil {
+		t.Fatalf("first request failed: %v", err)
+	}
+	*h.now = h.now.Add(time.Second)
+	_, err := h.service.Request(ctx, "b@example.com", ClientMeta{IP: ip})
+	ipChallenge := expectServiceErrorCode(t, err, "otp_challenge_required").Challenge
+	ipSolution := SolveChallenge(ipChallenge.Token, ipChallenge.Difficulty)
+
+	email := "c@example.com"
+	otherIP := "198.51.100.20"
+	if _, err := h.service.Request(ctx, email, ClientMeta{IP: otherIP}); err != nil {
+		t.Fatalf("first request for %s failed: %v", email, err)
+	}
+
+	// The second request trips the email and the other IP; a solution bound
+	// to the first IP satisfies neither.
+	*h.now = h.now.Add(time.Second)
+	_, err = h.service.Request(ctx, email, ClientMeta{IP: otherIP, ChallengeToken: ipChallenge.Token, ChallengeSolution: ipSolution})
+	expectServiceErrorCode(t, err, "otp_challenge_invalid")
+}
+
+func TestRequestRejectsAlternatingChallengeReplays(t *testing.T) {
+	h := newAbuseHarness(t)
+	h.service.cfg.OTPChallengeAfterRequests = 1
+	ctx := context.Background()
+	email := "e@example.com"
+
+	if _, err := h.service.Request(ctx, email, ClientMeta{}); err != nil {
+		t.Fatalf("first request failed: %v", err)
+	}
+
+	// Collect two challenges and solve both before using either.
+	type solved struct{ token, solution string }
+	var tokens []solved
+	for i := 0; i < 2; i++ {
+		*h.now = h.now.Add(time.Second)
+		_, err := h.service.Request(ctx, email, ClientMeta{})
+		challenge := expectServiceErrorCode(t, err, "otp_challenge_required").Challenge
+		tokens = append(tokens, solved{challenge.Token, SolveChallenge(challenge.Token, challenge.Difficulty)})
+	}
+
+	for i, tok := range tokens {
+		*h.now = h.now.Add(time.Second)
+		if _, err := h.service.Request(ctx, email, ClientMeta{ChallengeToken: tok.token, ChallengeSolution: tok.solution}); err != nil {
+			t.Fatalf("request with solved challenge %d failed: %v", i, err)
+		}
+	}
+
+	// Alternating between the two used tokens must not work either.
+	for _, tok := range []solved{tokens[0], tokens[1], tokens[0]} {
+		*h.now = h.now.Add(time.Second)
+		_, err := h.service.Request(ctx, email, ClientMeta{ChallengeToken: tok.token, ChallengeSolution: tok.solution})
+		expectServiceErrorCode(t, err, "otp_challenge_invalid")
+	}
+}
+
+func TestRequestLocksOutIPAndAdminReset(t *testing.T) {
+	h := newAbuseHarness(t)
+	h.service.cfg.OTPIPMaxRequestsPerWindow = 3
+	ctx := context.Background()
+	ip := "203.0.113.10"
+
+	for i := 0; i < 3; i++ {
+		email := fmt.Sprintf("user%d@example.com", i)
+		if _, err := h.service.Request(ctx, email, ClientMeta{IP: ip}); err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+	}
+	_, err := h.service.Request(ctx, "user9@example.com", ClientMeta{IP: ip})
+	expectServiceErrorCode(t, err, "otp_locked_out")
+
+	locked, err := h.service.ListAbuse(ctx, "", true, 0)
+	if err != nil {
+		t.Fatalf("list abuse failed: %v", err)
+	}
+	if len(locked) != 1 || locked[0].Scope != "ip" || locked[0].Key != ip || !locked[0].Locked {
+		t.Fatalf("expected single locked ip record, got %+v", locked)
+	}
+
+	if err := h.service.ResetAbuse(ctx, "ip", ip); err != nil {
+		t.Fatalf("reset abuse failed: %v", err)
+	}
+	if _, err := h.service.Request(ctx, "user9@example.com", ClientMeta{IP: ip}); err != nil {
+		t.Fatalf("request after reset failed: %v", err)
+	}
+
+	err = h.service.ResetAbuse(ctx, "ip", "198.51.100.200")
+	expectServiceErrorCode(t, err, "not_found")
+}
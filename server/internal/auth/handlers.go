@@ -3,14 +3,17 @@ package auth
 import (
 	"encoding/json"
 	"net/http"
+	"net/netip"
 	"strings"
 
 	"github.com/fdg312/health-hub/internal/auth/emailotp"
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 type Handlers struct {
 	service         *Service
 	emailOTPService *emailotp.Service
+	trustedProxies  []netip.Prefix
 }
 
 func NewHandlers(service *Service) *Handlers {
@@ -22,6 +25,13 @@ func (h *Handlers) WithEmailOTP(service *emailotp.Service) *Handlers {
 	return h
 }
 
+// WithTrustedProxies sets the reverse proxies whose X-Forwarded-For hop is
+// used as the client IP of email OTP requests.
+func (h *Handlers) WithTrustedProxies(proxies []netip.Prefix) *Handlers {
+	h.trustedProxies = proxies
+	return h
+}
+
 // HandleSignInApple handles POST /v1/auth/apple
 func (h *Handlers) HandleSignInApple(w http.ResponseWriter, r *http.Request) {
 	var req SignInAppleRequest
@@ -41,6 +51,7 @@ func (h *Handlers) HandleSignInApple(w http.ResponseWriter, r *http.Request) {
 			writeErrorResponse(w, http.StatusUnauthorized, "invalid_token", err.Error())
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeErrorResponse(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -54,6 +65,7 @@ func (h *Handlers) HandleSignInApple(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) HandleDevAuth(w http.ResponseWriter, r *http.Request) {
 	resp, err := h.service.SignInDev(r.Context())
 	if err != nil {
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeErrorResponse(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -68,8 +80,9 @@ func writeErrorResponse(w http.ResponseWriter, status int, code, message string)
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(ErrorResponse{
 		Error: ErrorDetail{
-			Code:    code,
-			Message: message,
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
 		},
 	})
 }
@@ -4,12 +4,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"testing"
 	"time"
 
+	"github.com/fdg312/health-hub/internal/auth/emailotp"
 	"github.com/fdg312/health-hub/internal/config"
+	"github.com/fdg312/health-hub/internal/mailer"
 	"github.com/fdg312/health-hub/internal/storage/memory"
 	"github.com/google/uuid"
 )
@@ -371,3 +377,177 @@ func TestFindOrCreateOwnerProfile(t *testing.T) {
 
 	_ = existingOwnerID // Keep initial owner
 }
+
+func TestHandleAdminOTPAbuseListRequiresAdmin(t *testing.T) {
+	memStorage := memory.New()
+	service, _ := setupTestService(true)
+	service.config.AdminUserIDs = []string{"email:admin@example.com"}
+	service.config.EmailAuthEnabled = true
+	otpService := emailotp.NewService(service.config, memStorage, nil).
+		WithAbuseLedger(memStorage.GetEmailOTPAbuseStorage())
+	handler := NewHandlers(service).WithEmailOTP(otpService)
+
+	tests := []struct {
+		name       string
+		userID     string
+		wantStatus int
+	}{
+		{name: "anonymous", userID: "", wantStatus: http.StatusUnauthorized},
+		{name: "regular user", userID: "email:user@example.com", wantStatus: http.StatusForbidden},
+		{name: "admin", userID: "email:admin@example.com", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/admin/otp-abuse?locked=1", nil)
+			if tt.userID != "" {
+				req = req.WithContext(WithUserID(req.Context(), tt.userID))
+			}
+			w := httptest.NewRecorder()
+
+			handler.HandleAdminOTPAbuseList(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d. Body: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleEmailOTPRequestReturnsChallenge(t *testing.T) {
+	memStorage := memory.New()
+	service, _ := setupTestService(true)
+	cfg := service.config
+	cfg.EmailAuthEnabled = true
+	cfg.OTPSecret = "test-otp-secret"
+	cfg.OTPTTLSeconds = 600
+	cfg.OTPMaxAttempts = 5
+	cfg.OTPResendMinSeconds = 60
+	cfg.OTPMaxSendPerHour = 5
+	cfg.OTPChallengeAfterRequests = 1
+	cfg.OTPChallengeDifficulty = 4
+	otpService := emailotp.NewService(cfg, memStorage, mailer.NewLocalSender(log.New(io.Discard, "", 0))).
+		WithAbuseLedger(memStorage.GetEmailOTPAbuseStorage())
+	handler := NewHandlers(service).WithEmailOTP(otpService)
+
+	send := func(email string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(EmailOTPRequest{Email: email})
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth/email/request", bytes.NewReader(body))
+		req.RemoteAddr = "203.0.113.5:4000"
+		w := httptest.NewRecorder()
+		handler.HandleEmailOTPRequest(w, req)
+		return w
+	}
+
+	if w := send("a@example.com"); w.Code != http.StatusOK {
+		t.Fatalf("expected first request 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	w := send("b@example.com")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var resp EmailOTPChallengeResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error.Code != "otp_challenge_required" || resp.Challenge == nil || resp.Challenge.Token == "" {
+		t.Fatalf("expected challenge in response, got %+v", resp)
+	}
+}
+
+func TestHandleEmailOTPRequestIgnoresSpoofedForwardedFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []netip.Prefix
+		remote  string
+		// forwarded returns the X-Forwarded-For header of the i-th request.
+		forwarded func(i int) string
+	}{
+		{
+			name:   "direct client",
+			remote: "203.0.113.5:4000",
+			forwarded: func(i int) string {
+				return fmt.Sprintf("198.51.100.%d", i)
+			},
+		},
+		{
+			name:    "behind trusted proxy",
+			proxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			remote:  "10.1.2.3:4000",
+			forwarded: func(i int) string {
+				return fmt.Sprintf("198.51.100.%d, 203.0.113.5", i)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memStorage := memory.New()
+			service, _ := setupTestService(true)
+			cfg := service.config
+			cfg.EmailAuthEnabled = true
+			cfg.OTPSecret = "test-otp-secret"
+			cfg.OTPTTLSeconds = 600
+			cfg.OTPMaxAttempts = 5
+			cfg.OTPResendMinSeconds = 60
+			cfg.OTPMaxSendPerHour = 5
+			cfg.OTPChallengeAfterRequests = 2
+			cfg.OTPChallengeDifficulty = 4
+			otpService := emailotp.NewService(cfg, memStorage, mailer.NewLocalSender(log.New(io.Discard, "", 0))).
+				WithAbuseLedger(memStorage.GetEmailOTPAbuseStorage())
+			handler := NewHandlers(service).WithEmailOTP(otpService).WithTrustedProxies(tt.proxies)
+
+			for i := 1; i <= 3; i++ {
+				body, _ := json.Marshal(EmailOTPRequest{Email: fmt.Sprintf("user%d@example.com", i)})
+				req := httptest.NewRequest(http.MethodPost, "/v1/auth/email/request", bytes.NewReader(body))
+				req.RemoteAddr = tt.remote
+				req.Header.Set("X-Forwarded-For", tt.forwarded(i))
+				w := httptest.NewRecorder()
+
+				handler.HandleEmailOTPRequest(w, req)
+
+				want := http.StatusOK
+				if i == 3 {
+					want = http.StatusForbidden
+				}
+				if w.Code != want {
+					t.Fatalf("request %d: expected %d, got %d. Body: %s", i, want, w.Code, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestClientIPUsesTrustedProxyHop(t *testing.T) {
+	handler := NewHandlers(nil).WithTrustedProxies([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.10/32"),
+	})
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		want      string
+	}{
+		{name: "untrusted peer", remote: "203.0.113.5:4000", forwarded: "198.51.100.1", want: "203.0.113.5"},
+		{name: "rightmost untrusted hop", remote: "10.0.0.1:4000", forwarded: "198.51.100.1, 203.0.113.5, 192.0.2.10", want: "203.0.113.5"},
+		{name: "no header", remote: "10.0.0.1:4000", want: "10.0.0.1"},
+		{name: "garbage hop", remote: "10.0.0.1:4000", forwarded: "203.0.113.5, not-an-ip", want: "10.0.0.1"},
+		{name: "ipv6 peer", remote: "[2001:db8::1]:4000", forwarded: "198.51.100.1", want: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/auth/email/request", nil)
+			req.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := handler.clientIP(req); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -1,11 +1,11 @@
 package auth
 
 import (
-	"log"
 	"net/http"
 	"strings"
 
 	"github.com/fdg312/health-hub/internal/config"
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 // Middleware — middleware для проверки авторизации
@@ -61,8 +61,9 @@ func (m *Middleware) OptionalAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		log.Printf("auth token accepted: sub=%s method=%s path=%s", userID, r.Method, r.URL.Path)
-		next.ServeHTTP(w, r.WithContext(WithUserID(r.Context(), userID)))
+		ctx := WithUserID(r.Context(), userID)
+		logging.FromContext(ctx).Debug("auth token accepted", "method", r.Method, "path", r.URL.Path)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
@@ -80,11 +81,10 @@ func (m *Middleware) authenticateHeader(authHeader string) (string, error) {
 }
 
 func writeError(w http.ResponseWriter, status int, code, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	w.Write([]byte(`{"error":{"code":"` + code + `","message":"` + message + `"}}`))
+	writeErrorResponse(w, status, code, message)
 }
 
+// isPublicPath skips JWT auth. /metrics is guarded by METRICS_TOKEN instead.
 func isPublicPath(path string) bool {
-	return path == "/healthz" || strings.HasPrefix(path, "/v1/auth/")
+	return path == "/healthz" || path == "/metrics" || strings.HasPrefix(path, "/v1/auth/")
 }
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"net/http"
 	"strings"
+
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 // HandleSignInSIWA handles POST /v1/auth/siwa.
@@ -25,8 +27,10 @@ func (h *Handlers) HandleSignInSIWA(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, ErrInvalidIdentityToken):
 			writeErrorResponse(w, http.StatusUnauthorized, "invalid_identity_token", "Invalid identity token")
 		case errors.Is(err, ErrJWKSFetchFailed):
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeErrorResponse(w, http.StatusInternalServerError, "jwks_fetch_failed", "Failed to fetch Apple JWKS")
 		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeErrorResponse(w, http.StatusInternalServerError, "internal_error", "Internal server error")
 		}
 		return
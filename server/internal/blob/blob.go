@@ -3,6 +3,7 @@ package blob
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -12,16 +13,36 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// ErrObjectNotFound is returned by HeadObject when the key does not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrObjectTooLarge is returned by GetObjectLimit for objects over the limit.
+var ErrObjectTooLarge = errors.New("object too large")
+
 // Store represents a blob storage interface
 type Store interface {
 	PutObject(ctx context.Context, key string, data []byte, contentType string) (int64, error)
 	GetObject(ctx context.Context, key string) ([]byte, error)
+	// GetObjectLimit downloads an object of at most maxBytes. A larger one
+	// fails with ErrObjectTooLarge after reading maxBytes+1 bytes.
+	GetObjectLimit(ctx context.Context, key string, maxBytes int64) ([]byte, error)
 	PresignGet(ctx context.Context, key string, ttlSeconds int) (string, error)
+	// PresignPut returns a URL the client uploads to directly. Content-Type
+	// and Content-Length are signed, so the upload must send exactly them.
+	PresignPut(ctx context.Context, key, contentType string, sizeBytes int64, ttlSeconds int) (string, error)
+	HeadObject(ctx context.Context, key string) (ObjectInfo, error)
 	DeleteObject(ctx context.Context, key string) error
 }
 
+// ObjectInfo is what HeadObject reports about a stored object.
+type ObjectInfo struct {
+	SizeBytes   int64
+	ContentType string
+}
+
 // S3Store implements Store using AWS S3 SDK v2 (compatible with Yandex Object Storage)
 type S3Store struct {
 	client        *s3.Client
@@ -101,6 +122,42 @@ func (s *S3Store) PresignGet(ctx context.Context, key string, ttlSeconds int) (s
 	return presignResult.URL, nil
 }
 
+// PresignPut generates a presigned PUT URL bound to contentType and sizeBytes
+func (s *S3Store) PresignPut(ctx context.Context, key, contentType string, sizeBytes int64, ttlSeconds int) (string, error) {
+	presignResult, err := s.presignClient.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(sizeBytes),
+	}, s3.WithPresignExpires(time.Duration(ttlSeconds)*time.Second))
+
+	if err != nil {
+		return "", fmt.Errorf("failed to presign PUT: %w", err)
+	}
+
+	return presignResult.URL, nil
+}
+
+// HeadObject reads the size and content type of an object without downloading it
+func (s *S3Store) HeadObject(ctx context.Context, key string) (ObjectInfo, error) {
+	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return ObjectInfo{}, ErrObjectNotFound
+		}
+		return ObjectInfo{}, fmt.Errorf("failed to head object: %w", err)
+	}
+
+	return ObjectInfo{
+		SizeBytes:   aws.ToInt64(result.ContentLength),
+		ContentType: aws.ToString(result.ContentType),
+	}, nil
+}
+
 // DeleteObject deletes an object from S3
 func (s *S3Store) DeleteObject(ctx context.Context, key string) error {
 	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
@@ -132,3 +189,28 @@ func (s *S3Store) GetObject(ctx context.Context, key string) ([]byte, error) {
 
 	return data, nil
 }
+
+// GetObjectLimit downloads an object from S3, reading at most maxBytes+1
+// bytes of it.
+func (s *S3Store) GetObjectLimit(ctx context.Context, key string, maxBytes int64) ([]byte, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer result.Body.Close()
+
+	if result.ContentLength != nil && *result.ContentLength > maxBytes {
+		return nil, ErrObjectTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(result.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object body: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, ErrObjectTooLarge
+	}
+	return data, nil
+}
@@ -42,7 +42,7 @@ func NewBlobStore(cfg appcfg.BlobConfig, logger Logger) (Store, string, error) {
 		}
 
 		logf(logger, "INFO blob: mode=s3 (auto, configured)")
-		return store, appcfg.BlobModeS3, nil
+		return WithTracing(store), appcfg.BlobModeS3, nil
 
 	case appcfg.BlobModeS3:
 		if !cfg.S3.IsConfigured() {
@@ -63,7 +63,7 @@ func NewBlobStore(cfg appcfg.BlobConfig, logger Logger) (Store, string, error) {
 		}
 
 		logf(logger, "INFO blob: mode=s3 (forced)")
-		return store, appcfg.BlobModeS3, nil
+		return WithTracing(store), appcfg.BlobModeS3, nil
 
 	default:
 		return nil, "", fmt.Errorf("unsupported blob mode: %s", mode)
@@ -0,0 +1,82 @@
+package blob
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/fdg312/health-hub/internal/telemetry"
+)
+
+// tracedStore wraps a Store with OpenTelemetry spans. Object keys are
+// recorded; contents are not.
+type tracedStore struct {
+	next Store
+}
+
+// WithTracing decorates store with spans. A nil store stays nil so callers
+// can keep checking for local mode.
+func WithTracing(store Store) Store {
+	if store == nil {
+		return nil
+	}
+	return &tracedStore{next: store}
+}
+
+func (t *tracedStore) PutObject(ctx context.Context, key string, data []byte, contentType string) (int64, error) {
+	ctx, span := telemetry.StartSpan(ctx, "blob.PutObject",
+		attribute.String("blob.key", key),
+		attribute.Int("blob.size", len(data)),
+	)
+	n, err := t.next.PutObject(ctx, key, data, contentType)
+	telemetry.EndSpan(span, err)
+	return n, err
+}
+
+func (t *tracedStore) GetObject(ctx context.Context, key string) ([]byte, error) {
+	ctx, span := telemetry.StartSpan(ctx, "blob.GetObject", attribute.String("blob.key", key))
+	data, err := t.next.GetObject(ctx, key)
+	span.SetAttributes(attribute.Int("blob.size", len(data)))
+	telemetry.EndSpan(span, err)
+	return data, err
+}
+
+func (t *tracedStore) GetObjectLimit(ctx context.Context, key string, maxBytes int64) ([]byte, error) {
+	ctx, span := telemetry.StartSpan(ctx, "blob.GetObjectLimit", attribute.String("blob.key", key))
+	data, err := t.next.GetObjectLimit(ctx, key, maxBytes)
+	span.SetAttributes(attribute.Int("blob.size", len(data)))
+	telemetry.EndSpan(span, err)
+	return data, err
+}
+
+func (t *tracedStore) PresignGet(ctx context.Context, key string, ttlSeconds int) (string, error) {
+	ctx, span := telemetry.StartSpan(ctx, "blob.PresignGet", attribute.String("blob.key", key))
+	url, err := t.next.PresignGet(ctx, key, ttlSeconds)
+	telemetry.EndSpan(span, err)
+	return url, err
+}
+
+func (t *tracedStore) PresignPut(ctx context.Context, key, contentType string, sizeBytes int64, ttlSeconds int) (string, error) {
+	ctx, span := telemetry.StartSpan(ctx, "blob.PresignPut",
+		attribute.String("blob.key", key),
+		attribute.Int64("blob.size", sizeBytes),
+	)
+	url, err := t.next.PresignPut(ctx, key, contentType, sizeBytes, ttlSeconds)
+	telemetry.EndSpan(span, err)
+	return url, err
+}
+
+func (t *tracedStore) HeadObject(ctx context.Context, key string) (ObjectInfo, error) {
+	ctx, span := telemetry.StartSpan(ctx, "blob.HeadObject", attribute.String("blob.key", key))
+	info, err := t.next.HeadObject(ctx, key)
+	span.SetAttributes(attribute.Int64("blob.size", info.SizeBytes))
+	telemetry.EndSpan(span, err)
+	return info, err
+}
+
+func (t *tracedStore) DeleteObject(ctx context.Context, key string) error {
+	ctx, span := telemetry.StartSpan(ctx, "blob.DeleteObject", attribute.String("blob.key", key))
+	err := t.next.DeleteObject(ctx, key)
+	telemetry.EndSpan(span, err)
+	return err
+}
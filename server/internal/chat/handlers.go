@@ -3,12 +3,15 @@ package chat
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 type Handler struct {
@@ -42,6 +45,16 @@ func (h *Handler) HandleListMessages(w http.ResponseWriter, r *http.Request) {
 		limit = parsed
 	}
 
+	var threadID *uuid.UUID
+	if raw := strings.TrimSpace(r.URL.Query().Get("thread_id")); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "invalid thread_id")
+			return
+		}
+		threadID = &parsed
+	}
+
 	var before *time.Time
 	if raw := strings.TrimSpace(r.URL.Query().Get("before")); raw != "" {
 		parsed, err := time.Parse(time.RFC3339Nano, raw)
@@ -52,9 +65,9 @@ func (h *Handler) HandleListMessages(w http.ResponseWriter, r *http.Request) {
 		before = &parsed
 	}
 
-	resp, err := h.service.ListMessages(r.Context(), profileID, limit, before)
+	resp, err := h.service.ListMessages(r.Context(), profileID, threadID, limit, before)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 
@@ -70,26 +83,199 @@ func (h *Handler) HandleSendMessage(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.service.SendMessage(r.Context(), req)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
 
-func (h *Handler) handleError(w http.ResponseWriter, err error) {
+// HandleSendMessageStream is HandleSendMessage over Server-Sent Events:
+//
+//	event: delta  data: {"text":"..."}            (repeated)
+//	event: done   data: SendMessageResponse
+//	event: error  data: ErrorResponse             (failure after the stream started)
+//
+// Errors before the first delta are returned as regular JSON responses.
+func (h *Handler) HandleSendMessageStream(w http.ResponseWriter, r *http.Request) {
+	var req SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON body")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "internal_error", "Streaming is not supported")
+		return
+	}
+
+	started := false
+	send := func(event string, data any) error {
+		if !started {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if err := writeEvent(w, event, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	resp, err := h.service.SendMessageStream(r.Context(), req, func(delta string) error {
+		return send("delta", StreamDeltaEvent{Text: delta})
+	})
+	if err != nil {
+		if r.Context().Err() != nil {
+			logging.FromContext(r.Context()).Debug("chat stream canceled by client")
+			return
+		}
+		if !started {
+			h.handleError(w, r, err)
+			return
+		}
+		status, code, message := classifyError(err)
+		if status >= http.StatusInternalServerError {
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
+		}
+		_ = send("error", ErrorResponse{Error: ErrorDetail{
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
+		}})
+		return
+	}
+
+	_ = send("done", resp)
+}
+
+func (h *Handler) HandleListThreads(w http.ResponseWriter, r *http.Request) {
+	profileID, err := uuid.Parse(strings.TrimSpace(r.URL.Query().Get("profile_id")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "profile_id is required")
+		return
+	}
+	includeArchived := r.URL.Query().Get("include_archived") == "1" || r.URL.Query().Get("include_archived") == "true"
+
+	resp, err := h.service.ListThreads(r.Context(), profileID, includeArchived)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) HandleCreateThread(w http.ResponseWriter, r *http.Request) {
+	var req CreateThreadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.CreateThread(r.Context(), req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (h *Handler) HandleUpdateThread(w http.ResponseWriter, r *http.Request) {
+	threadID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid thread id")
+		return
+	}
+
+	var req UpdateThreadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.UpdateThread(r.Context(), threadID, req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) HandleListFacts(w http.ResponseWriter, r *http.Request) {
+	profileID, err := uuid.Parse(strings.TrimSpace(r.URL.Query().Get("profile_id")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "profile_id is required")
+		return
+	}
+
+	resp, err := h.service.ListFacts(r.Context(), profileID)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) HandleDeleteFact(w http.ResponseWriter, r *http.Request) {
+	factID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid fact id")
+		return
+	}
+
+	if err := h.service.DeleteFact(r.Context(), factID); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code, message := classifyError(err)
+	if status >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+	}
+	writeError(w, status, code, message)
+}
+
+// classifyError maps service errors to HTTP status and error code.
+func classifyError(err error) (int, string, string) {
 	switch {
 	case errors.Is(err, ErrInvalidRequest):
-		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+		return http.StatusBadRequest, "invalid_request", "Invalid request"
 	case errors.Is(err, ErrUnauthorized):
-		writeError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return http.StatusUnauthorized, "unauthorized", "Unauthorized"
 	case errors.Is(err, ErrProfileNotFound):
-		writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
+		return http.StatusNotFound, "profile_not_found", "Profile not found"
+	case errors.Is(err, ErrThreadNotFound):
+		return http.StatusNotFound, "thread_not_found", "Thread not found"
+	case errors.Is(err, ErrThreadArchived):
+		return http.StatusConflict, "thread_archived", "Thread is archived"
+	case errors.Is(err, ErrFactNotFound):
+		return http.StatusNotFound, "fact_not_found", "Fact not found"
+	case errors.Is(err, ErrConsentRequired):
+		return http.StatusForbidden, "ai_consent_required", "Consent to AI processing is required"
+	case errors.Is(err, ErrQuotaExceeded):
+		return http.StatusTooManyRequests, "quota_exceeded", "AI token quota is used up, see GET /v1/ai/usage"
 	case errors.Is(err, ErrAIFailed):
-		writeError(w, http.StatusInternalServerError, "ai_failed", "AI provider failed")
+		return http.StatusInternalServerError, "ai_failed", "AI provider failed"
 	default:
-		writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+		return http.StatusInternalServerError, "internal_error", "Internal server error"
+	}
+}
+
+// writeEvent writes one SSE event with a single-line JSON data field.
+func writeEvent(w http.ResponseWriter, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
 	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) {
@@ -101,8 +287,9 @@ func writeJSON(w http.ResponseWriter, status int, data any) {
 func writeError(w http.ResponseWriter, status int, code, message string) {
 	writeJSON(w, status, ErrorResponse{
 		Error: ErrorDetail{
-			Code:    code,
-			Message: message,
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
 		},
 	})
 }
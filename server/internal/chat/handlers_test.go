@@ -6,10 +6,15 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/fdg312/health-hub/internal/ai"
+	"github.com/fdg312/health-hub/internal/aiusage"
+	"github.com/fdg312/health-hub/internal/checkins"
 	"github.com/fdg312/health-hub/internal/config"
+	"github.com/fdg312/health-hub/internal/cycle"
 	"github.com/fdg312/health-hub/internal/feed"
 	"github.com/fdg312/health-hub/internal/settings"
 	"github.com/fdg312/health-hub/internal/storage"
@@ -152,7 +157,7 @@ func TestProposalsSavedAndReturned(t *testing.T) {
 		t.Fatalf("expected proposals in response")
 	}
 
-	rows, err := mem.List(context.Background(), "userA", profileA, "", 20)
+	rows, err := mem.List(context.Background(), "userA", profileA, nil, 20)
 	if err != nil {
 		t.Fatalf("list proposals failed: %v", err)
 	}
@@ -161,6 +166,420 @@ func TestProposalsSavedAndReturned(t *testing.T) {
 	}
 }
 
+// draftsProvider replies with fixed proposal drafts.
+type draftsProvider struct {
+	drafts []ai.ProposalDraft
+}
+
+func (p draftsProvider) Reply(ctx context.Context, req ai.ReplyRequest) (ai.ReplyResponse, error) {
+	return ai.ReplyResponse{AssistantText: "Готово.", Proposals: p.drafts}, nil
+}
+
+func (p draftsProvider) ReplyStream(ctx context.Context, req ai.ReplyRequest, onDelta ai.StreamFunc) (ai.ReplyResponse, error) {
+	return p.Reply(ctx, req)
+}
+
+func (p draftsProvider) Summarize(ctx context.Context, req ai.SummaryRequest) (string, error) {
+	return "", nil
+}
+
+func (p draftsProvider) ReadLabReport(ctx context.Context, req ai.LabReportRequest) ([]ai.LabValue, error) {
+	return nil, nil
+}
+
+func TestInvalidProposalsAreDropped(t *testing.T) {
+	handler, mem, profileA, _ := setupChatHandler(t)
+	handler.service.provider = draftsProvider{drafts: []ai.ProposalDraft{
+		{Kind: "nutrition_plan", Title: "Цели", Summary: "ok", Payload: map[string]any{
+			"calories_kcal": 2100, "protein_g": 110, "fat_g": 70, "carbs_g": 230, "calcium_mg": 900,
+		}},
+		{Kind: "nutrition_plan", Title: "Слишком мало", Payload: map[string]any{"calories_kcal": 300}},
+		{Kind: "diagnosis", Title: "Неизвестный вид", Payload: map[string]any{}},
+	}}
+
+	data, _ := json.Marshal(SendMessageRequest{ProfileID: profileA, Content: "Цели по питанию"})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/messages", bytes.NewReader(data))
+	req = req.WithContext(userctx.WithUserID(context.Background(), "userA"))
+	w := httptest.NewRecorder()
+	handler.HandleSendMessage(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	rows, err := mem.List(context.Background(), "userA", profileA, nil, 20)
+	if err != nil {
+		t.Fatalf("list proposals failed: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Kind != "nutrition_plan" || rows[0].Title != "Цели" {
+		t.Fatalf("expected only the valid draft to be stored, got %+v", rows)
+	}
+}
+
+func TestNewProposalSupersedesOlderOfSameKind(t *testing.T) {
+	handler, mem, profileA, _ := setupChatHandler(t)
+	nutrition := func(kcal int) ai.ProposalDraft {
+		return ai.ProposalDraft{Kind: "nutrition_plan", Title: "Цели", Summary: "ok", Payload: map[string]any{
+			"calories_kcal": kcal, "protein_g": 110, "fat_g": 70, "carbs_g": 230, "calcium_mg": 900,
+		}}
+	}
+	memory := ai.ProposalDraft{Kind: "memory", Title: "Запомнить", Summary: "ok", Payload: map[string]any{"fact": "Бегает по утрам"}}
+
+	send := func(drafts ...ai.ProposalDraft) {
+		handler.service.provider = draftsProvider{drafts: drafts}
+		data, _ := json.Marshal(SendMessageRequest{ProfileID: profileA, Content: "Цели по питанию"})
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/messages", bytes.NewReader(data))
+		req = req.WithContext(userctx.WithUserID(context.Background(), "userA"))
+		w := httptest.NewRecorder()
+		handler.HandleSendMessage(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+		}
+	}
+	send(nutrition(2100), memory)
+	send(nutrition(1900))
+
+	pending, err := mem.List(context.Background(), "userA", profileA, []string{"pending"}, 20)
+	if err != nil {
+		t.Fatalf("list proposals failed: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("expected the new nutrition plan and the memory proposal pending, got %+v", pending)
+	}
+	for _, proposal := range pending {
+		if proposal.ExpiresAt == nil {
+			t.Fatalf("expected expires_at on new proposals")
+		}
+	}
+
+	superseded, err := mem.List(context.Background(), "userA", profileA, []string{"superseded"}, 20)
+	if err != nil {
+		t.Fatalf("list proposals failed: %v", err)
+	}
+	if len(superseded) != 1 || superseded[0].Kind != "nutrition_plan" {
+		t.Fatalf("expected the older nutrition plan superseded, got %+v", superseded)
+	}
+}
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+func parseSSE(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var ev sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			if v, ok := strings.CutPrefix(line, "event: "); ok {
+				ev.name = v
+			} else if v, ok := strings.CutPrefix(line, "data: "); ok {
+				ev.data = v
+			}
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestSendMessageStreamEmitsDeltasThenDone(t *testing.T) {
+	handler, mem, profileA, _ := setupChatHandler(t)
+
+	data, _ := json.Marshal(SendMessageRequest{ProfileID: profileA, Content: "Составь расписание витаминов"})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/messages/stream", bytes.NewReader(data))
+	req = req.WithContext(userctx.WithUserID(context.Background(), "userA"))
+	w := httptest.NewRecorder()
+	handler.HandleSendMessageStream(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) < 3 {
+		t.Fatalf("expected several deltas and a done event, got %d events", len(events))
+	}
+	var streamed strings.Builder
+	for _, ev := range events[:len(events)-1] {
+		if ev.name != "delta" {
+			t.Fatalf("expected delta event, got %q", ev.name)
+		}
+		var delta StreamDeltaEvent
+		if err := json.Unmarshal([]byte(ev.data), &delta); err != nil {
+			t.Fatalf("decode delta failed: %v", err)
+		}
+		streamed.WriteString(delta.Text)
+	}
+
+	last := events[len(events)-1]
+	if last.name != "done" {
+		t.Fatalf("expected final done event, got %q", last.name)
+	}
+	var resp SendMessageResponse
+	if err := json.Unmarshal([]byte(last.data), &resp); err != nil {
+		t.Fatalf("decode done failed: %v", err)
+	}
+	if resp.AssistantMessage.Content != strings.TrimSpace(streamed.String()) {
+		t.Fatalf("expected persisted message to match streamed text")
+	}
+	if len(resp.Proposals) != 1 {
+		t.Fatalf("expected 1 proposal in done event, got %d", len(resp.Proposals))
+	}
+
+	rows, _, err := mem.ListMessages(context.Background(), "userA", profileA, 50, nil)
+	if err != nil {
+		t.Fatalf("list messages failed: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 stored messages, got %d", len(rows))
+	}
+}
+
+func TestSendMessageStreamErrorBeforeStartIsJSON(t *testing.T) {
+	handler, _, _, profileB := setupChatHandler(t)
+
+	data, _ := json.Marshal(SendMessageRequest{ProfileID: profileB, Content: "Привет"})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/messages/stream", bytes.NewReader(data))
+	req = req.WithContext(userctx.WithUserID(context.Background(), "userA"))
+	w := httptest.NewRecorder()
+	handler.HandleSendMessageStream(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected JSON error, got %q", ct)
+	}
+}
+
+// cancelOnWrite simulates a client that disconnects after the first chunk.
+type cancelOnWrite struct {
+	*httptest.ResponseRecorder
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnWrite) Write(b []byte) (int, error) {
+	c.cancel()
+	return c.ResponseRecorder.Write(b)
+}
+
+func TestSendMessageStreamClientCancelSkipsPersist(t *testing.T) {
+	handler, mem, profileA, _ := setupChatHandler(t)
+
+	ctx, cancel := context.WithCancel(userctx.WithUserID(context.Background(), "userA"))
+	defer cancel()
+
+	data, _ := json.Marshal(SendMessageRequest{ProfileID: profileA, Content: "Привет"})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/messages/stream", bytes.NewReader(data)).WithContext(ctx)
+	w := &cancelOnWrite{ResponseRecorder: httptest.NewRecorder(), cancel: cancel}
+	handler.HandleSendMessageStream(w, req)
+
+	for _, ev := range parseSSE(t, w.Body.String()) {
+		if ev.name == "done" || ev.name == "error" {
+			t.Fatalf("expected no %s event after client cancel", ev.name)
+		}
+	}
+
+	rows, _, err := mem.ListMessages(context.Background(), "userA", profileA, 50, nil)
+	if err != nil {
+		t.Fatalf("list messages failed: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Role != "user" {
+		t.Fatalf("expected only the user message to be stored, got %d rows", len(rows))
+	}
+}
+
+type fakeCheckinsReader struct {
+	calls []string
+}
+
+func (f *fakeCheckinsReader) ListCheckins(ctx context.Context, profileID uuid.UUID, from, to string) ([]checkins.CheckinDTO, error) {
+	f.calls = append(f.calls, profileID.String()+" "+from+".."+to)
+	return []checkins.CheckinDTO{
+		{ProfileID: profileID, Date: to, Type: "morning", Score: 4},
+		{ProfileID: profileID, Date: to, Type: "evening", Score: 3},
+	}, nil
+}
+
+func TestSendMessageUsesHistoryTools(t *testing.T) {
+	handler, _, profileA, _ := setupChatHandler(t)
+	reader := &fakeCheckinsReader{}
+	handler.service.WithTools(ToolDeps{Checkins: reader})
+
+	data, _ := json.Marshal(SendMessageRequest{
+		ProfileID: profileA,
+		Content:   "Как менялось моё самочувствие за последнюю неделю?",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/messages", bytes.NewReader(data))
+	req = req.WithContext(userctx.WithUserID(context.Background(), "userA"))
+	w := httptest.NewRecorder()
+	handler.HandleSendMessage(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var resp SendMessageResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+
+	if len(reader.calls) != 1 || !strings.HasPrefix(reader.calls[0], profileA.String()) {
+		t.Fatalf("expected one checkins lookup for profile A, got %v", reader.calls)
+	}
+	if !strings.Contains(resp.AssistantMessage.Content, "list_checkins — 2 записей") {
+		t.Fatalf("expected tool summary in reply, got %q", resp.AssistantMessage.Content)
+	}
+}
+
+type fakeCycleReader struct {
+	shared bool
+	calls  int
+}
+
+func (f *fakeCycleReader) SharedWithAI(ctx context.Context, profileID uuid.UUID) (bool, error) {
+	return f.shared, nil
+}
+
+func (f *fakeCycleReader) ListPeriods(ctx context.Context, profileID uuid.UUID, from, to string) (*cycle.PeriodsResponse, error) {
+	f.calls++
+	return &cycle.PeriodsResponse{Periods: []cycle.PeriodDTO{{ProfileID: profileID, StartDate: from}}}, nil
+}
+
+func (f *fakeCycleReader) ListDays(ctx context.Context, profileID uuid.UUID, from, to string) (*cycle.DaysResponse, error) {
+	return &cycle.DaysResponse{Days: []cycle.DayDTO{}}, nil
+}
+
+func (f *fakeCycleReader) Predict(ctx context.Context, profileID uuid.UUID, date string) (*cycle.PredictionResponse, error) {
+	return &cycle.PredictionResponse{ProfileID: profileID, Date: date}, nil
+}
+
+type answersCheckinsReader struct{}
+
+func (answersCheckinsReader) ListCheckins(ctx context.Context, profileID uuid.UUID, from, to string) ([]checkins.CheckinDTO, error) {
+	return []checkins.CheckinDTO{{
+		ProfileID: profileID, Date: to, Type: "adhoc",
+		Note:    "болит голова",
+		Answers: map[string]any{"diary": "поссорилась с мужем", "pain": 6.0, "where": []string{"виски"}},
+	}}, nil
+}
+
+func TestCheckinsToolMasksTextAnswersAsNotes(t *testing.T) {
+	tools := &toolbox{deps: &ToolDeps{Checkins: answersCheckinsReader{}}, userID: "userA", profileID: uuid.New()}
+	provider := &recordingProvider{MockProvider: ai.NewMockProvider()}
+	redacted := ai.WithRedaction(provider, ai.NewRedactionPolicy([]string{"notes"}))
+	if _, err := redacted.Reply(context.Background(), ai.ReplyRequest{Tools: tools}); err != nil {
+		t.Fatalf("reply failed: %v", err)
+	}
+
+	out, err := provider.replies[0].Tools.Call(context.Background(), ai.ToolListCheckins,
+		json.RawMessage(`{"from":"2026-04-01","to":"2026-04-07"}`))
+	if err != nil {
+		t.Fatalf("tool call failed: %v", err)
+	}
+	data, _ := json.Marshal(out)
+	for _, text := range []string{"болит голова", "поссорилась"} {
+		if strings.Contains(string(data), text) {
+			t.Fatalf("expected %q masked, got %s", text, data)
+		}
+	}
+	for _, kept := range []string{`"pain":6`, "виски", `"diary":{"note":"[NOTE_`} {
+		if !strings.Contains(string(data), kept) {
+			t.Fatalf("expected %s in the tool result, got %s", kept, data)
+		}
+	}
+}
+
+func TestCycleToolRequiresOptIn(t *testing.T) {
+	handler, _, profileA, _ := setupChatHandler(t)
+	reader := &fakeCycleReader{}
+	handler.service.WithTools(ToolDeps{Cycle: reader})
+
+	send := func() string {
+		data, _ := json.Marshal(SendMessageRequest{
+			ProfileID: profileA,
+			Content:   "Как менялся мой цикл за последний месяц?",
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/messages", bytes.NewReader(data))
+		req = req.WithContext(userctx.WithUserID(context.Background(), "userA"))
+		w := httptest.NewRecorder()
+		handler.HandleSendMessage(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+		}
+		var resp SendMessageResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response failed: %v", err)
+		}
+		return resp.AssistantMessage.Content
+	}
+
+	if reply := send(); reader.calls != 0 || strings.Contains(reply, ai.ToolGetCycle) {
+		t.Fatalf("expected cycle data withheld without opt-in, got %d calls, reply %q", reader.calls, reply)
+	}
+
+	reader.shared = true
+	if reply := send(); reader.calls != 1 || !strings.Contains(reply, ai.ToolGetCycle+" — ") {
+		t.Fatalf("expected the cycle tool after opt-in, got %d calls, reply %q", reader.calls, reply)
+	}
+}
+
+type fakeConsent map[string]bool
+
+func (f fakeConsent) HasAIConsent(_ context.Context, ownerUserID string) (bool, error) {
+	return f[ownerUserID], nil
+}
+
+func TestSendMessageRequiresAIConsent(t *testing.T) {
+	handler, mem, profileA, _ := setupChatHandler(t)
+	provider := &recordingProvider{MockProvider: ai.NewMockProvider()}
+	handler.service.provider = provider
+	consent := fakeConsent{}
+	handler.service.WithConsentChecker(consent)
+
+	w := doJSON(t, handler.HandleSendMessage, http.MethodPost, "/v1/chat/messages", "userA",
+		SendMessageRequest{ProfileID: profileA, Content: "Как мой сон?"})
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "ai_consent_required") {
+		t.Fatalf("expected 403 ai_consent_required, got %d body=%s", w.Code, w.Body.String())
+	}
+	if len(provider.replies) != 0 {
+		t.Fatalf("provider must not be called without consent")
+	}
+	if rows, _, _ := mem.ListMessages(context.Background(), "userA", profileA, 10, nil); len(rows) != 0 {
+		t.Fatalf("message must not be stored without consent")
+	}
+
+	consent["userA"] = true
+	sendTo(t, handler, profileA, nil, "Как мой сон?")
+	if names := provider.replies[0].Names; len(names) != 1 || names[0] != "User A" {
+		t.Fatalf("expected profile name for redaction, got %v", names)
+	}
+}
+
+func TestSendMessageEnforcesTokenQuota(t *testing.T) {
+	handler, mem, profileA, _ := setupChatHandler(t)
+	usage := aiusage.NewService(mem.GetAIUsageStorage(), 1, 0)
+	handler.service.WithUsageMeter(usage)
+
+	// The first message crosses the limit and is still answered.
+	sendTo(t, handler, profileA, nil, "Как мой сон?")
+	rows, err := mem.GetAIUsageStorage().ListAIUsage(context.Background(), "userA", time.Now(), time.Now())
+	if err != nil || len(rows) != 1 || rows[0].Provider != ai.ModeMock || rows[0].InputTokens == 0 {
+		t.Fatalf("expected the reply to be recorded, got %+v err=%v", rows, err)
+	}
+
+	w := doJSON(t, handler.HandleSendMessage, http.MethodPost, "/v1/chat/messages", "userA",
+		SendMessageRequest{ProfileID: profileA, Content: "А шаги?"})
+	if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), "quota_exceeded") {
+		t.Fatalf("expected 429 quota_exceeded, got %d body=%s", w.Code, w.Body.String())
+	}
+	if msgs, _, _ := mem.ListMessages(context.Background(), "userA", profileA, 10, nil); len(msgs) != 2 {
+		t.Fatalf("refused message must not be stored, got %d messages", len(msgs))
+	}
+}
+
 func setupChatHandler(t *testing.T) (*Handler, *memory.MemoryStorage, uuid.UUID, uuid.UUID) {
 	t.Helper()
 
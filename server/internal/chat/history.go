@@ -0,0 +1,99 @@
+package chat
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/fdg312/health-hub/internal/ai"
+	"github.com/fdg312/health-hub/internal/logging"
+	"github.com/fdg312/health-hub/internal/storage"
+)
+
+const (
+	// DefaultHistoryTokenBudget bounds the verbatim history sent per turn.
+	DefaultHistoryTokenBudget = 3000
+	// maxHistoryMessages is how many recent messages are loaded per turn.
+	maxHistoryMessages = 200
+)
+
+// WithHistoryTokenBudget sets how many tokens of thread history are sent to
+// the model verbatim before older messages are summarized.
+func (s *Service) WithHistoryTokenBudget(tokens int) *Service {
+	if tokens > 0 {
+		s.historyBudget = tokens
+	}
+	return s
+}
+
+// buildHistory returns the thread's unsummarized messages and its summary.
+// When those messages exceed the budget, the older ones are folded into the
+// summary and only about half the budget is kept verbatim, so summarizing
+// happens once per half-budget of new messages rather than every turn.
+// A failed summary drops the older messages for this turn only: the summary
+// is retried on the next one.
+func (s *Service) buildHistory(ctx context.Context, userID string, thread storage.ChatThread) ([]ai.ChatMessage, string, error) {
+	rows, _, err := s.chatStorage.ListThreadMessages(ctx, userID, thread.ID, maxHistoryMessages, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if thread.SummarizedUntil != nil {
+		start := 0
+		for start < len(rows) && !rows[start].CreatedAt.After(*thread.SummarizedUntil) {
+			start++
+		}
+		rows = rows[start:]
+	}
+
+	total := 0
+	for _, row := range rows {
+		total += estimateTokens(row.Content)
+	}
+	if total <= s.historyBudget {
+		return toAIMessages(rows), thread.Summary, nil
+	}
+
+	keep, used := len(rows), 0
+	for keep > 0 {
+		cost := estimateTokens(rows[keep-1].Content)
+		if keep < len(rows) && used+cost > s.historyBudget/2 {
+			break
+		}
+		used += cost
+		keep--
+	}
+	older, recent := rows[:keep], rows[keep:]
+	if len(older) == 0 {
+		return toAIMessages(recent), thread.Summary, nil
+	}
+
+	summary, err := s.provider.Summarize(ctx, ai.SummaryRequest{
+		Previous: thread.Summary,
+		Messages: toAIMessages(older),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("chat summary failed", "thread_id", thread.ID, "error", err)
+		return toAIMessages(recent), thread.Summary, nil
+	}
+	if err := s.chatStorage.SaveThreadSummary(ctx, userID, thread.ID, summary, older[len(older)-1].CreatedAt); err != nil {
+		return nil, "", err
+	}
+	return toAIMessages(recent), summary, nil
+}
+
+// estimateTokens is a rough count for budgeting: mostly Cyrillic text runs
+// about three characters per token, plus per-message overhead.
+func estimateTokens(content string) int {
+	return utf8.RuneCountInString(content)/3 + 4
+}
+
+func toAIMessages(rows []storage.ChatMessage) []ai.ChatMessage {
+	messages := make([]ai.ChatMessage, 0, len(rows))
+	for _, row := range rows {
+		messages = append(messages, ai.ChatMessage{
+			Role:      row.Role,
+			Content:   row.Content,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+	return messages
+}
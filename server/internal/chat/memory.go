@@ -0,0 +1,65 @@
+package chat
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fdg312/health-hub/internal/audit"
+	"github.com/google/uuid"
+)
+
+// ListFacts returns what the assistant remembers about the profile. Facts
+// are added only by applying a "memory" proposal.
+func (s *Service) ListFacts(ctx context.Context, profileID uuid.UUID) (*ListFactsResponse, error) {
+	userID := strings.TrimSpace(userIDFromContext(ctx))
+	if userID == "" {
+		return nil, ErrUnauthorized
+	}
+	if _, err := s.ensureProfileOwned(ctx, userID, profileID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.chatStorage.ListFacts(ctx, userID, profileID)
+	if err != nil {
+		return nil, err
+	}
+	s.recordAudit(ctx, audit.ActionChatRead, "chat/memory", &profileID, "")
+
+	facts := make([]FactDTO, 0, len(rows))
+	for _, row := range rows {
+		facts = append(facts, factToDTO(row))
+	}
+	return &ListFactsResponse{Facts: facts}, nil
+}
+
+func (s *Service) DeleteFact(ctx context.Context, factID uuid.UUID) error {
+	userID := strings.TrimSpace(userIDFromContext(ctx))
+	if userID == "" {
+		return ErrUnauthorized
+	}
+	if factID == uuid.Nil {
+		return ErrInvalidRequest
+	}
+
+	deleted, err := s.chatStorage.DeleteFact(ctx, userID, factID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrFactNotFound
+	}
+	return nil
+}
+
+// factContents returns the profile's facts for the system prompt.
+func (s *Service) factContents(ctx context.Context, userID string, profileID uuid.UUID) ([]string, error) {
+	rows, err := s.chatStorage.ListFacts(ctx, userID, profileID)
+	if err != nil {
+		return nil, err
+	}
+	facts := make([]string, 0, len(rows))
+	for _, row := range rows {
+		facts = append(facts, row.Content)
+	}
+	return facts, nil
+}
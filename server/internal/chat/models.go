@@ -10,6 +10,7 @@ import (
 
 type ChatMessageDTO struct {
 	ID        uuid.UUID `json:"id"`
+	ThreadID  uuid.UUID `json:"thread_id"`
 	Role      string    `json:"role"`
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
@@ -28,12 +29,22 @@ type ProposalDTO struct {
 
 type SendMessageRequest struct {
 	ProfileID uuid.UUID `json:"profile_id"`
-	Content   string    `json:"content"`
+	// ThreadID defaults to the most recently active thread, or a new one.
+	ThreadID *uuid.UUID `json:"thread_id,omitempty"`
+	Content  string     `json:"content"`
 }
 
 type SendMessageResponse struct {
 	AssistantMessage ChatMessageDTO `json:"assistant_message"`
 	Proposals        []ProposalDTO  `json:"proposals"`
+	// SafetyFlags are set when the reply mentions doses or reads like a
+	// diagnosis; the message then ends with a disclaimer.
+	SafetyFlags []string `json:"safety_flags,omitempty"`
+}
+
+// StreamDeltaEvent is the payload of a "delta" SSE event.
+type StreamDeltaEvent struct {
+	Text string `json:"text"`
 }
 
 type ListMessagesResponse struct {
@@ -41,24 +52,82 @@ type ListMessagesResponse struct {
 	NextCursor *string          `json:"next_cursor,omitempty"`
 }
 
+type ThreadDTO struct {
+	ID         uuid.UUID  `json:"id"`
+	ProfileID  uuid.UUID  `json:"profile_id"`
+	Title      string     `json:"title"`
+	Summary    string     `json:"summary,omitempty"`
+	Archived   bool       `json:"archived"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type CreateThreadRequest struct {
+	ProfileID uuid.UUID `json:"profile_id"`
+	Title     string    `json:"title"`
+}
+
+type UpdateThreadRequest struct {
+	Title    *string `json:"title,omitempty"`
+	Archived *bool   `json:"archived,omitempty"`
+}
+
+type ListThreadsResponse struct {
+	Threads []ThreadDTO `json:"threads"`
+}
+
+type FactDTO struct {
+	ID        uuid.UUID `json:"id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ListFactsResponse struct {
+	Facts []FactDTO `json:"facts"`
+}
+
 type ErrorResponse struct {
 	Error ErrorDetail `json:"error"`
 }
 
 type ErrorDetail struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 func messageToDTO(msg storage.ChatMessage) ChatMessageDTO {
 	return ChatMessageDTO{
 		ID:        msg.ID,
+		ThreadID:  msg.ThreadID,
 		Role:      msg.Role,
 		Content:   msg.Content,
 		CreatedAt: msg.CreatedAt,
 	}
 }
 
+func threadToDTO(t storage.ChatThread) ThreadDTO {
+	return ThreadDTO{
+		ID:         t.ID,
+		ProfileID:  t.ProfileID,
+		Title:      t.Title,
+		Summary:    t.Summary,
+		Archived:   t.ArchivedAt != nil,
+		ArchivedAt: t.ArchivedAt,
+		CreatedAt:  t.CreatedAt,
+		UpdatedAt:  t.UpdatedAt,
+	}
+}
+
+func factToDTO(f storage.ChatFact) FactDTO {
+	return FactDTO{
+		ID:        f.ID,
+		Content:   f.Content,
+		CreatedAt: f.CreatedAt,
+	}
+}
+
 func proposalToDTO(p storage.AIProposal) ProposalDTO {
 	payload := make(map[string]any)
 	if len(p.Payload) > 0 {
@@ -9,9 +9,12 @@ import (
 	"time"
 
 	"github.com/fdg312/health-hub/internal/ai"
+	"github.com/fdg312/health-hub/internal/audit"
 	"github.com/fdg312/health-hub/internal/feed"
+	"github.com/fdg312/health-hub/internal/logging"
 	"github.com/fdg312/health-hub/internal/settings"
 	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/telemetry"
 	"github.com/fdg312/health-hub/internal/userctx"
 	"github.com/google/uuid"
 )
@@ -21,12 +24,26 @@ var (
 	ErrInvalidRequest  = errors.New("invalid request")
 	ErrProfileNotFound = errors.New("profile not found")
 	ErrAIFailed        = errors.New("ai failed")
+	ErrThreadNotFound  = errors.New("thread not found")
+	ErrThreadArchived  = errors.New("thread archived")
+	ErrFactNotFound    = errors.New("fact not found")
+	ErrConsentRequired = errors.New("ai consent required")
+	ErrQuotaExceeded   = errors.New("ai quota exceeded")
 )
 
 type settingsProvider interface {
 	GetOrDefault(ctx context.Context, ownerUserID string) (settings.SettingsResponse, error)
 }
 
+type consentChecker interface {
+	HasAIConsent(ctx context.Context, ownerUserID string) (bool, error)
+}
+
+type usageMeter interface {
+	WithinQuota(ctx context.Context, ownerUserID string) (bool, error)
+	Record(ctx context.Context, ownerUserID string, usage ai.Usage)
+}
+
 type daySummaryProvider interface {
 	GetDaySummary(ctx context.Context, profileID uuid.UUID, date string) (*feed.FeedDayResponse, error)
 }
@@ -38,6 +55,12 @@ type Service struct {
 	feedService      daySummaryProvider
 	settingsService  settingsProvider
 	provider         ai.Provider
+	audit            audit.Recorder
+	consent          consentChecker
+	usage            usageMeter
+	tools            *ToolDeps
+	historyBudget    int
+	proposalTTL      time.Duration
 	now              func() time.Time
 }
 
@@ -56,11 +79,70 @@ func NewService(
 		feedService:      feedService,
 		settingsService:  settingsService,
 		provider:         provider,
+		historyBudget:    DefaultHistoryTokenBudget,
+		proposalTTL:      DefaultProposalTTL,
 		now:              time.Now,
 	}
 }
 
-func (s *Service) ListMessages(ctx context.Context, profileID uuid.UUID, limit int, before *time.Time) (*ListMessagesResponse, error) {
+// DefaultProposalTTL is how long a proposal stays pending by default.
+const DefaultProposalTTL = 14 * 24 * time.Hour
+
+// WithAuditRecorder enables audit events for chat reads and sends.
+func (s *Service) WithAuditRecorder(recorder audit.Recorder) *Service {
+	s.audit = recorder
+	return s
+}
+
+// WithConsentChecker requires the owner's AI consent before any message is
+// sent to the provider.
+func (s *Service) WithConsentChecker(checker consentChecker) *Service {
+	s.consent = checker
+	return s
+}
+
+// WithUsageMeter records the tokens of every provider call per owner and
+// refuses new messages once the owner's quota is used up.
+func (s *Service) WithUsageMeter(meter usageMeter) *Service {
+	s.usage = meter
+	return s
+}
+
+// WithProposalTTL sets how long new proposals stay pending before they
+// expire.
+func (s *Service) WithProposalTTL(ttl time.Duration) *Service {
+	if ttl > 0 {
+		s.proposalTTL = ttl
+	}
+	return s
+}
+
+// meterUsage returns ctx with provider calls billed to the caller.
+func (s *Service) meterUsage(ctx context.Context) context.Context {
+	userID := strings.TrimSpace(userIDFromContext(ctx))
+	if s.usage == nil || userID == "" {
+		return ctx
+	}
+	return ai.WithUsage(ctx, func(usage ai.Usage) {
+		s.usage.Record(ctx, userID, usage)
+	})
+}
+
+func (s *Service) recordAudit(ctx context.Context, action, resourceType string, profileID *uuid.UUID, resourceID string) {
+	if s.audit == nil {
+		return
+	}
+	s.audit.Record(ctx, audit.Event{
+		ProfileID:    profileID,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+	})
+}
+
+// ListMessages pages through one thread, or through all threads of the
+// profile when threadID is nil.
+func (s *Service) ListMessages(ctx context.Context, profileID uuid.UUID, threadID *uuid.UUID, limit int, before *time.Time) (*ListMessagesResponse, error) {
 	userID := strings.TrimSpace(userIDFromContext(ctx))
 	if userID == "" {
 		return nil, ErrUnauthorized
@@ -71,10 +153,28 @@ func (s *Service) ListMessages(ctx context.Context, profileID uuid.UUID, limit i
 	}
 
 	limit = normalizeLimit(limit)
-	rows, nextCursorTime, err := s.chatStorage.ListMessages(ctx, userID, profileID, limit, before)
-	if err != nil {
-		return nil, err
+	var rows []storage.ChatMessage
+	var nextCursorTime *time.Time
+	var err error
+	if threadID != nil {
+		thread, err := s.ownedThread(ctx, userID, *threadID)
+		if err != nil {
+			return nil, err
+		}
+		if thread.ProfileID != profileID {
+			return nil, ErrThreadNotFound
+		}
+		rows, nextCursorTime, err = s.chatStorage.ListThreadMessages(ctx, userID, thread.ID, limit, before)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		rows, nextCursorTime, err = s.chatStorage.ListMessages(ctx, userID, profileID, limit, before)
+		if err != nil {
+			return nil, err
+		}
 	}
+	s.recordAudit(ctx, audit.ActionChatRead, "chat/messages", &profileID, "")
 
 	messages := make([]ChatMessageDTO, 0, len(rows))
 	for _, row := range rows {
@@ -94,97 +194,216 @@ func (s *Service) ListMessages(ctx context.Context, profileID uuid.UUID, limit i
 }
 
 func (s *Service) SendMessage(ctx context.Context, req SendMessageRequest) (*SendMessageResponse, error) {
+	ctx, span := telemetry.StartSpan(ctx, "chat.SendMessage")
+	resp, err := s.sendMessage(ctx, req)
+	telemetry.EndSpan(span, err)
+	return resp, err
+}
+
+func (s *Service) sendMessage(ctx context.Context, req SendMessageRequest) (*SendMessageResponse, error) {
+	ctx = s.meterUsage(ctx)
+	userID, threadID, replyReq, err := s.prepareReply(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := s.provider.Reply(ctx, replyReq)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAIFailed, err)
+	}
+
+	return s.saveReply(ctx, userID, req.ProfileID, threadID, reply)
+}
+
+// SendMessageStream is SendMessage with the assistant text delivered to
+// onDelta as it is generated. The assistant message and proposals are
+// persisted only after the stream completes; if the client goes away
+// mid-stream the provider call is canceled and nothing is saved.
+func (s *Service) SendMessageStream(ctx context.Context, req SendMessageRequest, onDelta ai.StreamFunc) (*SendMessageResponse, error) {
+	ctx, span := telemetry.StartSpan(ctx, "chat.SendMessageStream")
+	resp, err := s.sendMessageStream(ctx, req, onDelta)
+	telemetry.EndSpan(span, err)
+	return resp, err
+}
+
+func (s *Service) sendMessageStream(ctx context.Context, req SendMessageRequest, onDelta ai.StreamFunc) (*SendMessageResponse, error) {
+	ctx = s.meterUsage(ctx)
+	userID, threadID, replyReq, err := s.prepareReply(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var streamErr error
+	reply, err := s.provider.ReplyStream(ctx, replyReq, func(delta string) error {
+		if err := onDelta(delta); err != nil {
+			streamErr = err
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if streamErr != nil {
+			return nil, streamErr
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("%w: %v", ErrAIFailed, err)
+	}
+
+	// The reply is complete: save it even if the client disconnects now.
+	return s.saveReply(context.WithoutCancel(ctx), userID, req.ProfileID, threadID, reply)
+}
+
+// prepareReply validates the request, stores the user message in its
+// thread and builds the provider request from the thread history, the
+// remembered facts and today's snapshot.
+func (s *Service) prepareReply(ctx context.Context, req SendMessageRequest) (string, uuid.UUID, ai.ReplyRequest, error) {
 	userID := strings.TrimSpace(userIDFromContext(ctx))
 	if userID == "" {
-		return nil, ErrUnauthorized
+		return "", uuid.Nil, ai.ReplyRequest{}, ErrUnauthorized
 	}
 
 	content := strings.TrimSpace(req.Content)
 	if req.ProfileID == uuid.Nil || content == "" {
-		return nil, ErrInvalidRequest
+		return "", uuid.Nil, ai.ReplyRequest{}, ErrInvalidRequest
 	}
 
-	if _, err := s.ensureProfileOwned(ctx, userID, req.ProfileID); err != nil {
-		return nil, err
+	profile, err := s.ensureProfileOwned(ctx, userID, req.ProfileID)
+	if err != nil {
+		return "", uuid.Nil, ai.ReplyRequest{}, err
 	}
 
-	if _, err := s.chatStorage.InsertMessage(ctx, userID, req.ProfileID, "user", content); err != nil {
-		return nil, err
+	if s.consent != nil {
+		granted, err := s.consent.HasAIConsent(ctx, userID)
+		if err != nil {
+			return "", uuid.Nil, ai.ReplyRequest{}, err
+		}
+		if !granted {
+			return "", uuid.Nil, ai.ReplyRequest{}, ErrConsentRequired
+		}
+	}
+
+	if s.usage != nil {
+		within, err := s.usage.WithinQuota(ctx, userID)
+		if err != nil {
+			return "", uuid.Nil, ai.ReplyRequest{}, err
+		}
+		if !within {
+			return "", uuid.Nil, ai.ReplyRequest{}, ErrQuotaExceeded
+		}
 	}
 
-	historyRows, _, err := s.chatStorage.ListMessages(ctx, userID, req.ProfileID, 20, nil)
+	thread, err := s.resolveThread(ctx, userID, req.ProfileID, req.ThreadID, content)
 	if err != nil {
-		return nil, err
+		return "", uuid.Nil, ai.ReplyRequest{}, err
 	}
 
-	settingsResp, err := s.settingsService.GetOrDefault(ctx, userID)
+	userMessage, err := s.chatStorage.InsertMessage(ctx, userID, req.ProfileID, thread.ID, "user", content)
 	if err != nil {
-		return nil, err
+		return "", uuid.Nil, ai.ReplyRequest{}, err
 	}
+	s.recordAudit(ctx, audit.ActionChatSend, "chat/messages", &req.ProfileID, userMessage.ID.String())
 
-	snapshot, tz, err := s.buildSnapshot(ctx, req.ProfileID, settingsResp.Settings)
+	history, summary, err := s.buildHistory(ctx, userID, thread)
 	if err != nil {
-		return nil, err
+		return "", uuid.Nil, ai.ReplyRequest{}, err
 	}
 
-	aiMessages := make([]ai.ChatMessage, 0, len(historyRows))
-	for _, msg := range historyRows {
-		aiMessages = append(aiMessages, ai.ChatMessage{
-			Role:      msg.Role,
-			Content:   msg.Content,
-			CreatedAt: msg.CreatedAt,
-		})
+	facts, err := s.factContents(ctx, userID, req.ProfileID)
+	if err != nil {
+		return "", uuid.Nil, ai.ReplyRequest{}, err
+	}
+
+	settingsResp, err := s.settingsService.GetOrDefault(ctx, userID)
+	if err != nil {
+		return "", uuid.Nil, ai.ReplyRequest{}, err
+	}
+
+	snapshot, tz, err := s.buildSnapshot(ctx, req.ProfileID, settingsResp.Settings)
+	if err != nil {
+		return "", uuid.Nil, ai.ReplyRequest{}, err
 	}
 
-	reply, err := s.provider.Reply(ctx, ai.ReplyRequest{
+	replyReq := ai.ReplyRequest{
 		UserID:    userID,
 		ProfileID: req.ProfileID,
-		Messages:  aiMessages,
+		Messages:  history,
 		Snapshot:  snapshot,
 		Settings:  settingsResp.Settings,
 		TimeZone:  tz,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrAIFailed, err)
+		Summary:   summary,
+		Facts:     facts,
+		Names:     []string{profile.Name},
+	}
+	if s.tools != nil {
+		tools := &toolbox{deps: s.tools, userID: userID, profileID: req.ProfileID}
+		if s.tools.Cycle != nil {
+			// Cycle data reaches the assistant only after an explicit opt-in.
+			if tools.cycleShared, err = s.tools.Cycle.SharedWithAI(ctx, req.ProfileID); err != nil {
+				return "", uuid.Nil, ai.ReplyRequest{}, err
+			}
+		}
+		replyReq.Tools = tools
 	}
+	return userID, thread.ID, replyReq, nil
+}
 
+// saveReply persists the assistant message and its proposals.
+func (s *Service) saveReply(ctx context.Context, userID string, profileID, threadID uuid.UUID, reply ai.ReplyResponse) (*SendMessageResponse, error) {
 	assistantText := strings.TrimSpace(reply.AssistantText)
 	if assistantText == "" {
 		assistantText = "Я не смог сформировать ответ. Попробуйте переформулировать вопрос."
 	}
 
-	assistantMessage, err := s.chatStorage.InsertMessage(ctx, userID, req.ProfileID, "assistant", assistantText)
+	assistantMessage, err := s.chatStorage.InsertMessage(ctx, userID, profileID, threadID, "assistant", assistantText)
 	if err != nil {
 		return nil, err
 	}
 
+	// Drafts that do not match their kind's schema would only fail on apply.
+	expiresAt := s.now().UTC().Add(s.proposalTTL)
 	drafts := make([]storage.ProposalDraft, 0, len(reply.Proposals))
 	for _, draft := range reply.Proposals {
-		payload, err := json.Marshal(draft.Payload)
+		valid, err := ai.ValidateProposal(draft)
+		if err != nil {
+			logging.FromContext(ctx).Warn("ai proposal dropped", "kind", draft.Kind, "error", err)
+			continue
+		}
+		payload, err := json.Marshal(valid.Payload)
 		if err != nil {
-			payload = []byte(`{}`)
+			continue
 		}
 		drafts = append(drafts, storage.ProposalDraft{
-			Kind:    normalizeProposalKind(draft.Kind),
-			Title:   strings.TrimSpace(draft.Title),
-			Summary: strings.TrimSpace(draft.Summary),
-			Payload: payload,
+			Kind:      valid.Kind,
+			Title:     valid.Title,
+			Summary:   valid.Summary,
+			Payload:   payload,
+			ExpiresAt: &expiresAt,
 		})
 	}
 
-	savedProposals, err := s.proposalsStorage.InsertMany(ctx, userID, req.ProfileID, drafts)
+	savedProposals, err := s.proposalsStorage.InsertMany(ctx, userID, profileID, drafts)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.supersedeProposals(ctx, userID, profileID, savedProposals); err != nil {
+		return nil, err
+	}
 
 	proposalDTOs := make([]ProposalDTO, 0, len(savedProposals))
 	for _, proposal := range savedProposals {
 		proposalDTOs = append(proposalDTOs, proposalToDTO(proposal))
 	}
 
+	if len(reply.SafetyFlags) > 0 {
+		logging.FromContext(ctx).Info("ai reply flagged", "profile_id", profileID, "flags", reply.SafetyFlags)
+	}
+
 	return &SendMessageResponse{
 		AssistantMessage: messageToDTO(assistantMessage),
 		Proposals:        proposalDTOs,
+		SafetyFlags:      reply.SafetyFlags,
 	}, nil
 }
 
@@ -284,16 +503,6 @@ func normalizeLimit(limit int) int {
 	return limit
 }
 
-func normalizeProposalKind(kind string) string {
-	normalized := strings.TrimSpace(kind)
-	switch normalized {
-	case "settings_update", "vitamins_schedule", "workout_plan", "nutrition_plan", "generic":
-		return normalized
-	default:
-		return "generic"
-	}
-}
-
 func getNestedInt(payload map[string]any, keys ...string) int {
 	var current any = payload
 	for _, key := range keys {
@@ -322,3 +531,31 @@ func getNestedInt(payload map[string]any, keys ...string) int {
 		return 0
 	}
 }
+
+// supersedeProposals closes older pending proposals that the new ones
+// replace: a newer proposal of the same kind wins. Memory proposals each
+// carry a separate fact and never conflict.
+func (s *Service) supersedeProposals(ctx context.Context, userID string, profileID uuid.UUID, saved []storage.AIProposal) error {
+	keepByKind := make(map[string][]uuid.UUID)
+	kinds := make([]string, 0, len(saved))
+	for _, proposal := range saved {
+		if proposal.Kind == "memory" {
+			continue
+		}
+		if _, seen := keepByKind[proposal.Kind]; !seen {
+			kinds = append(kinds, proposal.Kind)
+		}
+		keepByKind[proposal.Kind] = append(keepByKind[proposal.Kind], proposal.ID)
+	}
+
+	for _, kind := range kinds {
+		superseded, err := s.proposalsStorage.SupersedePending(ctx, userID, profileID, kind, keepByKind[kind])
+		if err != nil {
+			return err
+		}
+		if superseded > 0 {
+			logging.FromContext(ctx).Info("ai proposals superseded", "kind", kind, "count", superseded)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fdg312/health-hub/internal/audit"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	maxThreadTitleRunes = 120
+	// autoTitleRunes is the length of titles taken from a thread's first message.
+	autoTitleRunes     = 60
+	defaultThreadTitle = "Новый чат"
+)
+
+func (s *Service) ListThreads(ctx context.Context, profileID uuid.UUID, includeArchived bool) (*ListThreadsResponse, error) {
+	userID := strings.TrimSpace(userIDFromContext(ctx))
+	if userID == "" {
+		return nil, ErrUnauthorized
+	}
+	if _, err := s.ensureProfileOwned(ctx, userID, profileID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.chatStorage.ListThreads(ctx, userID, profileID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	s.recordAudit(ctx, audit.ActionChatRead, "chat/threads", &profileID, "")
+
+	threads := make([]ThreadDTO, 0, len(rows))
+	for _, row := range rows {
+		threads = append(threads, threadToDTO(row))
+	}
+	return &ListThreadsResponse{Threads: threads}, nil
+}
+
+func (s *Service) CreateThread(ctx context.Context, req CreateThreadRequest) (*ThreadDTO, error) {
+	userID := strings.TrimSpace(userIDFromContext(ctx))
+	if userID == "" {
+		return nil, ErrUnauthorized
+	}
+	if req.ProfileID == uuid.Nil {
+		return nil, ErrInvalidRequest
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = defaultThreadTitle
+	}
+	if utf8.RuneCountInString(title) > maxThreadTitleRunes {
+		return nil, ErrInvalidRequest
+	}
+
+	if _, err := s.ensureProfileOwned(ctx, userID, req.ProfileID); err != nil {
+		return nil, err
+	}
+
+	thread, err := s.chatStorage.CreateThread(ctx, userID, req.ProfileID, title)
+	if err != nil {
+		return nil, err
+	}
+	dto := threadToDTO(thread)
+	return &dto, nil
+}
+
+// UpdateThread renames and/or archives a thread. Archived threads keep
+// their history but accept no new messages until unarchived.
+func (s *Service) UpdateThread(ctx context.Context, threadID uuid.UUID, req UpdateThreadRequest) (*ThreadDTO, error) {
+	userID := strings.TrimSpace(userIDFromContext(ctx))
+	if userID == "" {
+		return nil, ErrUnauthorized
+	}
+	if req.Title == nil && req.Archived == nil {
+		return nil, ErrInvalidRequest
+	}
+
+	var title *string
+	if req.Title != nil {
+		trimmed := strings.TrimSpace(*req.Title)
+		if trimmed == "" || utf8.RuneCountInString(trimmed) > maxThreadTitleRunes {
+			return nil, ErrInvalidRequest
+		}
+		title = &trimmed
+	}
+
+	if _, err := s.ownedThread(ctx, userID, threadID); err != nil {
+		return nil, err
+	}
+
+	thread, found, err := s.chatStorage.UpdateThread(ctx, userID, threadID, title, req.Archived)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrThreadNotFound
+	}
+	dto := threadToDTO(thread)
+	return &dto, nil
+}
+
+// ownedThread loads a thread and checks that its profile still belongs to
+// the user.
+func (s *Service) ownedThread(ctx context.Context, userID string, threadID uuid.UUID) (storage.ChatThread, error) {
+	thread, found, err := s.chatStorage.GetThread(ctx, userID, threadID)
+	if err != nil {
+		return storage.ChatThread{}, err
+	}
+	if !found {
+		return storage.ChatThread{}, ErrThreadNotFound
+	}
+	if _, err := s.ensureProfileOwned(ctx, userID, thread.ProfileID); err != nil {
+		return storage.ChatThread{}, ErrThreadNotFound
+	}
+	return thread, nil
+}
+
+// resolveThread picks the thread a new message goes to: the requested one,
+// else the most recently active thread of the profile, else a new thread
+// titled after the message.
+func (s *Service) resolveThread(ctx context.Context, userID string, profileID uuid.UUID, threadID *uuid.UUID, content string) (storage.ChatThread, error) {
+	if threadID != nil {
+		thread, err := s.ownedThread(ctx, userID, *threadID)
+		if err != nil {
+			return storage.ChatThread{}, err
+		}
+		if thread.ProfileID != profileID {
+			return storage.ChatThread{}, ErrThreadNotFound
+		}
+		if thread.ArchivedAt != nil {
+			return storage.ChatThread{}, ErrThreadArchived
+		}
+		return thread, nil
+	}
+
+	threads, err := s.chatStorage.ListThreads(ctx, userID, profileID, false)
+	if err != nil {
+		return storage.ChatThread{}, err
+	}
+	if len(threads) > 0 {
+		return threads[0], nil
+	}
+	return s.chatStorage.CreateThread(ctx, userID, profileID, autoTitle(content))
+}
+
+// autoTitle is the first line of content, shortened to autoTitleRunes.
+func autoTitle(content string) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) > autoTitleRunes {
+		title = strings.TrimSpace(string([]rune(title)[:autoTitleRunes-1])) + "…"
+	}
+	if title == "" {
+		return defaultThreadTitle
+	}
+	return title
+}
@@ -0,0 +1,178 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fdg312/health-hub/internal/ai"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+// recordingProvider is the mock provider that remembers what it was sent.
+type recordingProvider struct {
+	*ai.MockProvider
+	replies   []ai.ReplyRequest
+	summaries []ai.SummaryRequest
+}
+
+func (p *recordingProvider) Reply(ctx context.Context, req ai.ReplyRequest) (ai.ReplyResponse, error) {
+	p.replies = append(p.replies, req)
+	return p.MockProvider.Reply(ctx, req)
+}
+
+func (p *recordingProvider) Summarize(ctx context.Context, req ai.SummaryRequest) (string, error) {
+	p.summaries = append(p.summaries, req)
+	return p.MockProvider.Summarize(ctx, req)
+}
+
+func doJSON(t *testing.T, handle http.HandlerFunc, method, target, userID string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(data))
+	req = req.WithContext(userctx.WithUserID(context.Background(), userID))
+	w := httptest.NewRecorder()
+	handle(w, req)
+	return w
+}
+
+func sendTo(t *testing.T, handler *Handler, profileID uuid.UUID, threadID *uuid.UUID, content string) SendMessageResponse {
+	t.Helper()
+	w := doJSON(t, handler.HandleSendMessage, http.MethodPost, "/v1/chat/messages", "userA",
+		SendMessageRequest{ProfileID: profileID, ThreadID: threadID, Content: content})
+	if w.Code != http.StatusOK {
+		t.Fatalf("send failed status=%d body=%s", w.Code, w.Body.String())
+	}
+	var resp SendMessageResponse
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	return resp
+}
+
+func TestThreadsKeepSeparateHistory(t *testing.T) {
+	handler, _, profileA, _ := setupChatHandler(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH /v1/chat/threads/{id}", handler.HandleUpdateThread)
+
+	first := sendTo(t, handler, profileA, nil, "Как мой сон?\nподробнее")
+	defaultThread := first.AssistantMessage.ThreadID
+
+	w := doJSON(t, handler.HandleCreateThread, http.MethodPost, "/v1/chat/threads", "userA",
+		CreateThreadRequest{ProfileID: profileA, Title: "Питание"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create thread failed status=%d body=%s", w.Code, w.Body.String())
+	}
+	var created ThreadDTO
+	_ = json.NewDecoder(w.Body).Decode(&created)
+
+	sendTo(t, handler, profileA, &created.ID, "Сколько белка мне нужно?")
+	if again := sendTo(t, handler, profileA, nil, "А вчера?"); again.AssistantMessage.ThreadID != created.ID {
+		t.Fatalf("expected messages without thread_id to go to the most recent thread")
+	}
+
+	w = doJSON(t, handler.HandleListMessages, http.MethodGet,
+		"/v1/chat/messages?profile_id="+profileA.String()+"&thread_id="+defaultThread.String(), "userA", nil)
+	var inThread ListMessagesResponse
+	_ = json.NewDecoder(w.Body).Decode(&inThread)
+	if len(inThread.Messages) != 2 {
+		t.Fatalf("expected 2 messages in the first thread, got %d", len(inThread.Messages))
+	}
+
+	w = doJSON(t, handler.HandleListThreads, http.MethodGet, "/v1/chat/threads?profile_id="+profileA.String(), "userA", nil)
+	var threads ListThreadsResponse
+	_ = json.NewDecoder(w.Body).Decode(&threads)
+	if len(threads.Threads) != 2 || threads.Threads[0].ID != created.ID || threads.Threads[1].Title != "Как мой сон?" {
+		t.Fatalf("unexpected threads %+v", threads.Threads)
+	}
+
+	archived := true
+	w = doJSON(t, mux.ServeHTTP, http.MethodPatch, "/v1/chat/threads/"+created.ID.String(), "userA",
+		UpdateThreadRequest{Archived: &archived})
+	if w.Code != http.StatusOK {
+		t.Fatalf("archive failed status=%d body=%s", w.Code, w.Body.String())
+	}
+
+	w = doJSON(t, handler.HandleSendMessage, http.MethodPost, "/v1/chat/messages", "userA",
+		SendMessageRequest{ProfileID: profileA, ThreadID: &created.ID, Content: "Ещё вопрос"})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for archived thread, got %d", w.Code)
+	}
+
+	w = doJSON(t, mux.ServeHTTP, http.MethodPatch, "/v1/chat/threads/"+created.ID.String(), "userB",
+		UpdateThreadRequest{Archived: &archived})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user's thread, got %d", w.Code)
+	}
+}
+
+func TestLongHistoryIsSummarized(t *testing.T) {
+	handler, mem, profileA, _ := setupChatHandler(t)
+	provider := &recordingProvider{MockProvider: ai.NewMockProvider()}
+	handler.service.provider = provider
+	handler.service.WithHistoryTokenBudget(200)
+
+	long := strings.Repeat("Сегодня плохо спал и мало ходил. ", 10)
+	var threadID uuid.UUID
+	for i := 0; i < 4; i++ {
+		threadID = sendTo(t, handler, profileA, nil, long).AssistantMessage.ThreadID
+	}
+
+	if len(provider.summaries) == 0 {
+		t.Fatalf("expected older messages to be summarized")
+	}
+	last := provider.replies[len(provider.replies)-1]
+	if last.Summary == "" || len(last.Messages) >= 7 {
+		t.Fatalf("expected a summary and a trimmed history, got %d messages", len(last.Messages))
+	}
+	if got := last.Messages[len(last.Messages)-1]; got.Role != "user" || got.Content != strings.TrimSpace(long) {
+		t.Fatalf("expected the latest user message to be kept verbatim")
+	}
+
+	thread, _, _ := mem.GetThread(context.Background(), "userA", threadID)
+	if thread.SummarizedUntil == nil || thread.Summary != last.Summary {
+		t.Fatalf("expected the summary to be persisted, got %+v", thread)
+	}
+}
+
+func TestFactsReachPromptAndCanBeDeleted(t *testing.T) {
+	handler, mem, profileA, _ := setupChatHandler(t)
+	provider := &recordingProvider{MockProvider: ai.NewMockProvider()}
+	handler.service.provider = provider
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /v1/chat/memory/{id}", handler.HandleDeleteFact)
+
+	resp := sendTo(t, handler, profileA, nil, "Запомни: у меня аллергия на орехи")
+	if len(resp.Proposals) != 1 || resp.Proposals[0].Kind != ai.KindMemory {
+		t.Fatalf("expected a memory proposal, got %+v", resp.Proposals)
+	}
+
+	fact, err := mem.InsertFact(context.Background(), "userA", profileA, "аллергия на орехи")
+	if err != nil {
+		t.Fatalf("insert fact failed: %v", err)
+	}
+	sendTo(t, handler, profileA, nil, "Что мне съесть на ужин?")
+	if facts := provider.replies[len(provider.replies)-1].Facts; len(facts) != 1 || facts[0] != "аллергия на орехи" {
+		t.Fatalf("expected the fact in the reply request, got %v", facts)
+	}
+
+	w := doJSON(t, handler.HandleListFacts, http.MethodGet, "/v1/chat/memory?profile_id="+profileA.String(), "userA", nil)
+	var listed ListFactsResponse
+	_ = json.NewDecoder(w.Body).Decode(&listed)
+	if len(listed.Facts) != 1 || listed.Facts[0].ID != fact.ID {
+		t.Fatalf("unexpected facts %+v", listed.Facts)
+	}
+
+	if w := doJSON(t, mux.ServeHTTP, http.MethodDelete, "/v1/chat/memory/"+fact.ID.String(), "userB", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 deleting another user's fact, got %d", w.Code)
+	}
+	if w := doJSON(t, mux.ServeHTTP, http.MethodDelete, "/v1/chat/memory/"+fact.ID.String(), "userA", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+}
@@ -0,0 +1,273 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/ai"
+	"github.com/fdg312/health-hub/internal/checkins"
+	"github.com/fdg312/health-hub/internal/cycle"
+	"github.com/fdg312/health-hub/internal/intakes"
+	"github.com/fdg312/health-hub/internal/mealplans"
+	"github.com/fdg312/health-hub/internal/metrics"
+	"github.com/fdg312/health-hub/internal/nutrition"
+	"github.com/fdg312/health-hub/internal/workouts"
+	"github.com/google/uuid"
+)
+
+// maxToolRangeDays bounds date ranges requested by the model.
+const maxToolRangeDays = 92
+
+type dailyMetricsReader interface {
+	GetDailyMetrics(ctx context.Context, profileID uuid.UUID, from, to string) (*metrics.DailyMetricsResponse, error)
+}
+
+type checkinsReader interface {
+	ListCheckins(ctx context.Context, profileID uuid.UUID, from, to string) ([]checkins.CheckinDTO, error)
+}
+
+type adherenceReader interface {
+	GetSupplementAdherence(ctx context.Context, profileID uuid.UUID, from, to string) (*intakes.SupplementAdherenceResponse, error)
+}
+
+type workoutCompletionsReader interface {
+	ListCompletions(ctx context.Context, profileID uuid.UUID, from, to string) (*workouts.ListCompletionsResponse, error)
+}
+
+type mealPlanReader interface {
+	GetActive(ctx context.Context, ownerUserID string, profileID string) (*mealplans.MealPlanDTO, []mealplans.MealPlanItemDTO, bool, error)
+}
+
+type nutritionTargetsReader interface {
+	GetOrDefault(ctx context.Context, ownerUserID string, profileID uuid.UUID) (nutrition.TargetsDTO, bool, error)
+}
+
+// cycleReader gives the menstrual cycle history; it is offered only to
+// profiles that opted in to sharing it.
+type cycleReader interface {
+	SharedWithAI(ctx context.Context, profileID uuid.UUID) (bool, error)
+	ListPeriods(ctx context.Context, profileID uuid.UUID, from, to string) (*cycle.PeriodsResponse, error)
+	ListDays(ctx context.Context, profileID uuid.UUID, from, to string) (*cycle.DaysResponse, error)
+	Predict(ctx context.Context, profileID uuid.UUID, date string) (*cycle.PredictionResponse, error)
+}
+
+// ToolDeps are the services the assistant may read through tool calls.
+// Nil fields leave the corresponding tool out.
+type ToolDeps struct {
+	Metrics   dailyMetricsReader
+	Checkins  checkinsReader
+	Intakes   adherenceReader
+	Workouts  workoutCompletionsReader
+	MealPlans mealPlanReader
+	Nutrition nutritionTargetsReader
+	Cycle     cycleReader
+}
+
+// WithTools enables tool calling with read access to the profile's history.
+func (s *Service) WithTools(deps ToolDeps) *Service {
+	s.tools = &deps
+	return s
+}
+
+// toolbox implements ai.Toolbox for one user and profile. The services
+// re-check profile ownership through the request context. cycleShared
+// reflects the profile's opt-in to sharing cycle data.
+type toolbox struct {
+	deps        *ToolDeps
+	userID      string
+	profileID   uuid.UUID
+	cycleShared bool
+}
+
+var rangeParameters = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"from": map[string]any{"type": "string", "description": "Start date, YYYY-MM-DD"},
+		"to":   map[string]any{"type": "string", "description": "End date inclusive, YYYY-MM-DD"},
+	},
+	"required": []string{"from", "to"},
+}
+
+var noParameters = map[string]any{
+	"type":       "object",
+	"properties": map[string]any{},
+}
+
+func (t *toolbox) Specs() []ai.ToolSpec {
+	specs := make([]ai.ToolSpec, 0, 7)
+	if t.deps.Metrics != nil {
+		specs = append(specs, ai.ToolSpec{
+			Name:        ai.ToolGetDailyMetrics,
+			Description: "Daily aggregates (steps, active energy, sleep, heart rate, nutrition) for a date range, up to 92 days.",
+			Parameters:  rangeParameters,
+		})
+	}
+	if t.deps.Checkins != nil {
+		specs = append(specs, ai.ToolSpec{
+			Name:        ai.ToolListCheckins,
+			Description: "Check-ins for a date range: morning and evening ones with a score 1-5, adhoc ones at any time (symptoms, diary), each with tags, a note and answers to the template questions keyed by question id; text answers are given as {\"note\": ...}.",
+			Parameters:  rangeParameters,
+		})
+	}
+	if t.deps.Intakes != nil {
+		specs = append(specs, ai.ToolSpec{
+			Name:        ai.ToolGetSupplementAdherence,
+			Description: "Per supplement: expected, taken and skipped days for a date range.",
+			Parameters:  rangeParameters,
+		})
+	}
+	if t.deps.Workouts != nil {
+		specs = append(specs, ai.ToolSpec{
+			Name:        ai.ToolListWorkoutCompletions,
+			Description: "Workout plan completions (done/skipped) for a date range.",
+			Parameters:  rangeParameters,
+		})
+	}
+	if t.deps.MealPlans != nil {
+		specs = append(specs, ai.ToolSpec{
+			Name:        ai.ToolGetMealPlan,
+			Description: "The active weekly meal plan with items per day and slot.",
+			Parameters:  noParameters,
+		})
+	}
+	if t.deps.Nutrition != nil {
+		specs = append(specs, ai.ToolSpec{
+			Name:        ai.ToolGetNutritionTargets,
+			Description: "Daily nutrition targets: calories, protein, fat, carbs, calcium.",
+			Parameters:  noParameters,
+		})
+	}
+	if t.deps.Cycle != nil && t.cycleShared {
+		specs = append(specs, ai.ToolSpec{
+			Name:        ai.ToolGetCycle,
+			Description: "Menstrual periods and logged flow/symptoms for a date range, with the cycle prediction as of the end date.",
+			Parameters:  rangeParameters,
+		})
+	}
+	return specs
+}
+
+func (t *toolbox) Call(ctx context.Context, name string, args json.RawMessage) (any, error) {
+	switch {
+	case name == ai.ToolGetDailyMetrics && t.deps.Metrics != nil:
+		from, to, err := parseToolRange(args)
+		if err != nil {
+			return nil, err
+		}
+		return t.deps.Metrics.GetDailyMetrics(ctx, t.profileID, from, to)
+
+	case name == ai.ToolListCheckins && t.deps.Checkins != nil:
+		from, to, err := parseToolRange(args)
+		if err != nil {
+			return nil, err
+		}
+		items, err := t.deps.Checkins.ListCheckins(ctx, t.profileID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"checkins": toolCheckins(items)}, nil
+
+	case name == ai.ToolGetSupplementAdherence && t.deps.Intakes != nil:
+		from, to, err := parseToolRange(args)
+		if err != nil {
+			return nil, err
+		}
+		return t.deps.Intakes.GetSupplementAdherence(ctx, t.profileID, from, to)
+
+	case name == ai.ToolListWorkoutCompletions && t.deps.Workouts != nil:
+		from, to, err := parseToolRange(args)
+		if err != nil {
+			return nil, err
+		}
+		return t.deps.Workouts.ListCompletions(ctx, t.profileID, from, to)
+
+	case name == ai.ToolGetMealPlan && t.deps.MealPlans != nil:
+		plan, items, found, err := t.deps.MealPlans.GetActive(ctx, t.userID, t.profileID.String())
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return map[string]any{"plan": nil, "items": []mealplans.MealPlanItemDTO{}}, nil
+		}
+		return map[string]any{"plan": plan, "items": items}, nil
+
+	case name == ai.ToolGetNutritionTargets && t.deps.Nutrition != nil:
+		targets, isDefault, err := t.deps.Nutrition.GetOrDefault(ctx, t.userID, t.profileID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"targets": targets, "is_default": isDefault}, nil
+
+	case name == ai.ToolGetCycle && t.deps.Cycle != nil && t.cycleShared:
+		from, to, err := parseToolRange(args)
+		if err != nil {
+			return nil, err
+		}
+		periods, err := t.deps.Cycle.ListPeriods(ctx, t.profileID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		days, err := t.deps.Cycle.ListDays(ctx, t.profileID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		prediction, err := t.deps.Cycle.Predict(ctx, t.profileID, to)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"periods": periods.Periods, "days": days.Days, "prediction": prediction}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown tool %q", name)
+	}
+}
+
+// parseToolRange validates {"from","to"} arguments from the model.
+// toolCheckins wraps text answers as {"note": text}. Question IDs are
+// chosen by the user, so this is how the redaction policy knows a diary
+// answer is free text and masks it like the checkin note.
+func toolCheckins(items []checkins.CheckinDTO) []checkins.CheckinDTO {
+	out := make([]checkins.CheckinDTO, 0, len(items))
+	for _, item := range items {
+		if len(item.Answers) > 0 {
+			answers := make(map[string]any, len(item.Answers))
+			for id, value := range item.Answers {
+				if text, ok := value.(string); ok {
+					value = map[string]string{"note": text}
+				}
+				answers[id] = value
+			}
+			item.Answers = answers
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func parseToolRange(args json.RawMessage) (string, string, error) {
+	var params struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", "", errors.New("arguments must be a JSON object with from and to")
+	}
+	from, err := time.Parse("2006-01-02", params.From)
+	if err != nil {
+		return "", "", errors.New("from must be YYYY-MM-DD")
+	}
+	to, err := time.Parse("2006-01-02", params.To)
+	if err != nil {
+		return "", "", errors.New("to must be YYYY-MM-DD")
+	}
+	if to.Before(from) {
+		return "", "", errors.New("from must not be after to")
+	}
+	if to.Sub(from) > maxToolRangeDays*24*time.Hour {
+		return "", "", fmt.Errorf("range must not exceed %d days", maxToolRangeDays)
+	}
+	return params.From, params.To, nil
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sort"
 	"testing"
 	"time"
 
@@ -15,14 +17,16 @@ import (
 
 // mockStorage implements Storage for testing
 type mockStorage struct {
-	checkins map[uuid.UUID]Checkin
-	byKey    map[string]uuid.UUID
+	checkins  map[uuid.UUID]Checkin
+	byKey     map[string]uuid.UUID
+	templates map[uuid.UUID]Template
 }
 
 func newMockStorage() *mockStorage {
 	return &mockStorage{
-		checkins: make(map[uuid.UUID]Checkin),
-		byKey:    make(map[string]uuid.UUID),
+		checkins:  make(map[uuid.UUID]Checkin),
+		byKey:     make(map[string]uuid.UUID),
+		templates: make(map[uuid.UUID]Template),
 	}
 }
 
@@ -51,12 +55,16 @@ func (m *mockStorage) UpsertCheckin(checkin *Checkin) error {
 		existing.Score = checkin.Score
 		existing.Tags = checkin.Tags
 		existing.Note = checkin.Note
+		existing.TemplateID = checkin.TemplateID
+		existing.Answers = checkin.Answers
 		existing.UpdatedAt = checkin.UpdatedAt
 		m.checkins[existingID] = existing
 		*checkin = existing
 	} else {
 		m.checkins[checkin.ID] = *checkin
-		m.byKey[key] = checkin.ID
+		if checkin.Type != TypeAdhoc {
+			m.byKey[key] = checkin.ID
+		}
 	}
 	return nil
 }
@@ -72,6 +80,65 @@ func (m *mockStorage) DeleteCheckin(id uuid.UUID) error {
 	return nil
 }
 
+func (m *mockStorage) ListTemplates(profileID uuid.UUID) ([]Template, error) {
+	var result []Template
+	for _, t := range m.templates {
+		if t.ProfileID == profileID {
+			result = append(result, t)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].IsDefault && !result[j].IsDefault })
+	return result, nil
+}
+
+func (m *mockStorage) GetTemplate(id uuid.UUID) (*Template, error) {
+	t, exists := m.templates[id]
+	if !exists {
+		return nil, ErrTemplateNotFound
+	}
+	return &t, nil
+}
+
+func (m *mockStorage) CreateTemplate(template *Template) error {
+	m.templates[template.ID] = *template
+	return nil
+}
+
+func (m *mockStorage) UpdateTemplate(template *Template) error {
+	if _, exists := m.templates[template.ID]; !exists {
+		return ErrTemplateNotFound
+	}
+	m.templates[template.ID] = *template
+	return nil
+}
+
+func (m *mockStorage) DeleteTemplate(id uuid.UUID) error {
+	if _, exists := m.templates[id]; !exists {
+		return ErrTemplateNotFound
+	}
+	delete(m.templates, id)
+	return nil
+}
+
+func (m *mockStorage) ListAnswers(profileID uuid.UUID, templateID *uuid.UUID, questionID, from, to string) ([]AnswerPoint, error) {
+	var result []AnswerPoint
+	for _, c := range m.checkins {
+		if c.ProfileID != profileID || c.Date < from || c.Date > to {
+			continue
+		}
+		if templateID != nil && (c.TemplateID == nil || *c.TemplateID != *templateID) {
+			continue
+		}
+		for _, a := range c.Answers {
+			if a.QuestionID == questionID {
+				result = append(result, AnswerPoint{CheckinID: c.ID, TemplateID: c.TemplateID, Date: c.Date, RecordedAt: c.RecordedAt, Answer: a})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].RecordedAt.Before(result[j].RecordedAt) })
+	return result, nil
+}
+
 // mockProfileStorage implements ProfileStorage for testing
 type mockProfileStorage struct {
 	profiles map[uuid.UUID]storage.Profile
@@ -294,5 +361,191 @@ func TestHandleDeleteNotFound(t *testing.T) {
 	}
 }
 
+func TestCheckinTemplates(t *testing.T) {
+	storage := newMockStorage()
+	profileStorage := newMockProfileStorage()
+	service := NewService(storage, profileStorage)
+
+	var ownerID uuid.UUID
+	for id := range profileStorage.profiles {
+		ownerID = id
+	}
+
+	// Default templates are created on first use
+	w := serveCheckins(HandleListTemplates(service), "GET", "/v1/checkins/templates?profile_id="+ownerID.String(), nil)
+	var list TemplatesResponse
+	json.NewDecoder(w.Body).Decode(&list)
+	if w.Code != http.StatusOK || len(list.Templates) != 2 || !list.Templates[0].IsDefault {
+		t.Fatalf("expected two default templates, got %d %+v", w.Code, list.Templates)
+	}
+
+	// A morning checkin sent the old way answers the score question
+	w = serveCheckins(HandleUpsert(service), "POST", "/v1/checkins", UpsertCheckinRequest{
+		ProfileID: ownerID, Date: "2026-03-02", Type: TypeMorning, Score: 3,
+	})
+	var morning CheckinDTO
+	json.NewDecoder(w.Body).Decode(&morning)
+	if w.Code != http.StatusOK || morning.TemplateID == nil || morning.Answers[ScoreQuestionID] != float64(3) {
+		t.Fatalf("expected score answer, got %d %+v", w.Code, morning)
+	}
+
+	// A migraine template with every question type
+	w = serveCheckins(HandleCreateTemplate(service), "POST", "/v1/checkins/templates", map[string]any{
+		"profile_id": ownerID,
+		"name":       " Мигрень ",
+		"questions": []map[string]any{
+			{"id": "pain", "label": "Боль", "type": "scale", "min": 0, "max": 10, "required": true},
+			{"id": "aura", "label": "Аура", "type": "boolean"},
+			{"id": "triggers", "label": "Триггеры", "type": "multi_choice", "options": []string{"вино", "недосып", "стресс"}},
+			{"id": "ibuprofen", "label": "Ибупрофен", "type": "number", "min": 0, "unit": "мг"},
+			{"id": "comment", "label": "Комментарий", "type": "text"},
+		},
+	})
+	var migraine TemplateDTO
+	json.NewDecoder(w.Body).Decode(&migraine)
+	if w.Code != http.StatusCreated || migraine.Name != "Мигрень" || migraine.Type != TypeAdhoc || len(migraine.Questions) != 5 {
+		t.Fatalf("unexpected template %d %+v", w.Code, migraine)
+	}
+
+	// Two adhoc checkins the same day
+	for i, answers := range []string{
+		`{"pain": 7, "aura": true, "triggers": ["стресс", "недосып", "стресс"], "ibuprofen": 400, "comment": " после работы "}`,
+		`{"pain": 3, "aura": false, "triggers": []}`,
+	} {
+		recordedAt := time.Date(2026, 3, 2, 9+i*6, 0, 0, 0, time.UTC)
+		w = serveCheckins(HandleUpsert(service), "POST", "/v1/checkins", UpsertCheckinRequest{
+			ProfileID: ownerID, TemplateID: &migraine.ID, RecordedAt: &recordedAt,
+			Answers: decodeAnswers(t, answers),
+		})
+		var adhoc CheckinDTO
+		json.NewDecoder(w.Body).Decode(&adhoc)
+		if w.Code != http.StatusOK || adhoc.Type != TypeAdhoc || adhoc.Date != "2026-03-02" || adhoc.Score != 0 {
+			t.Fatalf("unexpected adhoc checkin %d %+v", w.Code, adhoc)
+		}
+		if i == 0 {
+			triggers, _ := adhoc.Answers["triggers"].([]any)
+			if len(triggers) != 2 || triggers[0] != "недосып" || adhoc.Answers["comment"] != "после работы" {
+				t.Fatalf("unexpected answers %+v", adhoc.Answers)
+			}
+		}
+	}
+
+	// Answers to one question, for a chart
+	w = serveCheckins(HandleListAnswers(service), "GET", "/v1/checkins/answers?profile_id="+ownerID.String()+"&question_id=pain&from=2026-03-01&to=2026-03-31", nil)
+	var pain AnswersResponse
+	json.NewDecoder(w.Body).Decode(&pain)
+	if w.Code != http.StatusOK || len(pain.Points) != 2 || pain.Points[0].Value != float64(7) || pain.Points[1].Value != float64(3) {
+		t.Fatalf("unexpected answers %d %+v", w.Code, pain)
+	}
+
+	// Invalid answers
+	for _, answers := range []string{
+		`{"aura": true}`,
+		`{"pain": 11}`,
+		`{"pain": 2.5}`,
+		`{"pain": 2, "triggers": ["кофе"]}`,
+		`{"pain": 2, "aura": "yes"}`,
+		`{"pain": 2, "sleep": 7}`,
+	} {
+		w = serveCheckins(HandleUpsert(service), "POST", "/v1/checkins", UpsertCheckinRequest{
+			ProfileID: ownerID, TemplateID: &migraine.ID, Answers: decodeAnswers(t, answers),
+		})
+		if w.Code != http.StatusBadRequest || !bytes.Contains(w.Body.Bytes(), []byte("invalid_answer")) {
+			t.Fatalf("%s: expected invalid_answer, got %d %s", answers, w.Code, w.Body.String())
+		}
+	}
+
+	// The template type decides the checkin type
+	w = serveCheckins(HandleUpsert(service), "POST", "/v1/checkins", UpsertCheckinRequest{
+		ProfileID: ownerID, Date: "2026-03-02", Type: TypeEvening, TemplateID: &migraine.ID, Answers: decodeAnswers(t, `{"pain": 1}`),
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for type mismatch, got %d", w.Code)
+	}
+}
+
+func TestCheckinTemplateRules(t *testing.T) {
+	storage := newMockStorage()
+	profileStorage := newMockProfileStorage()
+	service := NewService(storage, profileStorage)
+
+	var ownerID uuid.UUID
+	for id := range profileStorage.profiles {
+		ownerID = id
+	}
+	templates, err := service.ListTemplates(context.Background(), ownerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	morning := templates[0]
+
+	// Default templates stay and keep the score question
+	if err := service.DeleteTemplate(context.Background(), morning.ID); !errors.Is(err, ErrDefaultTemplate) {
+		t.Fatalf("expected ErrDefaultTemplate, got %v", err)
+	}
+	sleep := []Question{{ID: "sleep", Label: "Сон", Type: QuestionNumber, Unit: "ч"}}
+	if _, err := service.UpdateTemplate(context.Background(), morning.ID, UpdateTemplateRequest{Questions: &sleep}); !errors.Is(err, ErrInvalidTemplate) {
+		t.Fatalf("expected ErrInvalidTemplate, got %v", err)
+	}
+	withSleep := append(morning.Questions, sleep...)
+	updated, err := service.UpdateTemplate(context.Background(), morning.ID, UpdateTemplateRequest{Questions: &withSleep})
+	if err != nil || len(updated.Questions) != 2 {
+		t.Fatalf("expected question added, got %v %+v", err, updated)
+	}
+
+	// Questions are validated
+	for _, questions := range [][]Question{
+		{{ID: "Bad ID", Label: "x", Type: QuestionText}},
+		{{ID: "a", Label: "x", Type: QuestionText}, {ID: "a", Label: "y", Type: QuestionText}},
+		{{ID: "a", Label: "", Type: QuestionText}},
+		{{ID: "a", Label: "x", Type: "slider"}},
+		{{ID: "a", Label: "x", Type: QuestionMultiChoice}},
+		{{ID: "a", Label: "x", Type: QuestionScale, Min: floatPtr(5), Max: floatPtr(1)}},
+	} {
+		_, err := service.CreateTemplate(context.Background(), CreateTemplateRequest{ProfileID: ownerID, Name: "Тест", Questions: questions})
+		if !errors.Is(err, ErrInvalidTemplate) {
+			t.Fatalf("%+v: expected ErrInvalidTemplate, got %v", questions, err)
+		}
+	}
+
+	// Adhoc checkins go without score, morning ones need it
+	if _, err := service.UpsertCheckin(context.Background(), UpsertCheckinRequest{ProfileID: ownerID, Type: TypeAdhoc, Note: "голова"}); err != nil {
+		t.Fatalf("expected adhoc checkin without score, got %v", err)
+	}
+	if _, err := service.UpsertCheckin(context.Background(), UpsertCheckinRequest{ProfileID: ownerID, Date: "2026-03-02", Type: TypeMorning}); !errors.Is(err, ErrInvalidScore) {
+		t.Fatalf("expected ErrInvalidScore, got %v", err)
+	}
+	if _, err := service.UpsertCheckin(context.Background(), UpsertCheckinRequest{ProfileID: ownerID, Type: TypeMorning, Score: 4}); !errors.Is(err, ErrMissingDate) {
+		t.Fatalf("expected ErrMissingDate, got %v", err)
+	}
+}
+
+func serveCheckins(handler http.HandlerFunc, method, target string, body any) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func decodeAnswers(t *testing.T, raw string) map[string]json.RawMessage {
+	t.Helper()
+	var answers map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &answers); err != nil {
+		t.Fatal(err)
+	}
+	return answers
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
 // Suppress unused import warnings
 var _ = context.Background()
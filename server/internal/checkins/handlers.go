@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 // HandleList handles GET /v1/checkins?profile_id=&from=&to=
@@ -36,6 +38,7 @@ func HandleList(service *Service) http.HandlerFunc {
 				writeError(w, http.StatusBadRequest, "invalid_date", err.Error())
 				return
 			}
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 			return
 		}
@@ -69,10 +72,19 @@ func HandleUpsert(service *Service) http.HandlerFunc {
 				writeError(w, http.StatusBadRequest, "invalid_score", err.Error())
 				return
 			}
-			if errors.Is(err, ErrInvalidDate) {
+			if errors.Is(err, ErrInvalidDate) || errors.Is(err, ErrMissingDate) {
 				writeError(w, http.StatusBadRequest, "invalid_date", err.Error())
 				return
 			}
+			if errors.Is(err, ErrTemplateNotFound) {
+				writeError(w, http.StatusNotFound, "template_not_found", err.Error())
+				return
+			}
+			if errors.Is(err, ErrInvalidAnswer) {
+				writeError(w, http.StatusBadRequest, "invalid_answer", err.Error())
+				return
+			}
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 			return
 		}
@@ -103,6 +115,7 @@ func HandleDelete(service *Service) http.HandlerFunc {
 				writeError(w, http.StatusNotFound, "checkin_not_found", err.Error())
 				return
 			}
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 			return
 		}
@@ -111,13 +124,173 @@ func HandleDelete(service *Service) http.HandlerFunc {
 	}
 }
 
+// HandleListTemplates handles GET /v1/checkins/templates?profile_id=
+func HandleListTemplates(service *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		profileID, err := uuid.Parse(r.URL.Query().Get("profile_id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_profile_id", "invalid profile_id format")
+			return
+		}
+
+		templates, err := service.ListTemplates(r.Context(), profileID)
+		if err != nil {
+			if errors.Is(err, ErrProfileNotFound) {
+				writeError(w, http.StatusNotFound, "profile_not_found", err.Error())
+				return
+			}
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(TemplatesResponse{Templates: templates})
+	}
+}
+
+// HandleCreateTemplate handles POST /v1/checkins/templates
+func HandleCreateTemplate(service *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CreateTemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+			return
+		}
+
+		template, err := service.CreateTemplate(r.Context(), req)
+		if err != nil {
+			writeTemplateError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(template)
+	}
+}
+
+// HandleUpdateTemplate handles PATCH /v1/checkins/templates/{id}
+func HandleUpdateTemplate(service *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_id", "invalid template id format")
+			return
+		}
+
+		var req UpdateTemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+			return
+		}
+
+		template, err := service.UpdateTemplate(r.Context(), id, req)
+		if err != nil {
+			writeTemplateError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(template)
+	}
+}
+
+// HandleDeleteTemplate handles DELETE /v1/checkins/templates/{id}
+func HandleDeleteTemplate(service *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_id", "invalid template id format")
+			return
+		}
+
+		if err := service.DeleteTemplate(r.Context(), id); err != nil {
+			writeTemplateError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleListAnswers handles GET /v1/checkins/answers?profile_id=&question_id=&from=&to=&template_id=
+func HandleListAnswers(service *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		questionID := query.Get("question_id")
+		from := query.Get("from")
+		to := query.Get("to")
+
+		if query.Get("profile_id") == "" || questionID == "" || from == "" || to == "" {
+			writeError(w, http.StatusBadRequest, "missing_params", "profile_id, question_id, from, and to are required")
+			return
+		}
+
+		profileID, err := uuid.Parse(query.Get("profile_id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_profile_id", "invalid profile_id format")
+			return
+		}
+		var templateID *uuid.UUID
+		if raw := query.Get("template_id"); raw != "" {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_template_id", "invalid template_id format")
+				return
+			}
+			templateID = &id
+		}
+
+		points, err := service.ListAnswers(r.Context(), profileID, templateID, questionID, from, to)
+		if err != nil {
+			if errors.Is(err, ErrProfileNotFound) {
+				writeError(w, http.StatusNotFound, "profile_not_found", err.Error())
+				return
+			}
+			if errors.Is(err, ErrInvalidDate) {
+				writeError(w, http.StatusBadRequest, "invalid_date", err.Error())
+				return
+			}
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(AnswersResponse{QuestionID: questionID, Points: points})
+	}
+}
+
+func writeTemplateError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrProfileNotFound):
+		writeError(w, http.StatusNotFound, "profile_not_found", err.Error())
+	case errors.Is(err, ErrTemplateNotFound):
+		writeError(w, http.StatusNotFound, "template_not_found", err.Error())
+	case errors.Is(err, ErrInvalidType):
+		writeError(w, http.StatusBadRequest, "invalid_type", err.Error())
+	case errors.Is(err, ErrInvalidTemplate):
+		writeError(w, http.StatusBadRequest, "invalid_template", err.Error())
+	case errors.Is(err, ErrDefaultTemplate):
+		writeError(w, http.StatusConflict, "default_template", err.Error())
+	default:
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+	}
+}
+
 func writeError(w http.ResponseWriter, status int, code, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(ErrorResponse{
 		Error: ErrorDetail{
-			Code:    code,
-			Message: message,
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
 		},
 	})
 }
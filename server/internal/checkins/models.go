@@ -1,6 +1,7 @@
 package checkins
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,10 +11,27 @@ import (
 const (
 	TypeMorning = "morning"
 	TypeEvening = "evening"
+	TypeAdhoc   = "adhoc" // any number per day, at any time
 )
 
 // Valid checkin types
-var ValidTypes = []string{TypeMorning, TypeEvening}
+var ValidTypes = []string{TypeMorning, TypeEvening, TypeAdhoc}
+
+// Question types
+const (
+	QuestionScale       = "scale"
+	QuestionBoolean     = "boolean"
+	QuestionMultiChoice = "multi_choice"
+	QuestionNumber      = "number"
+	QuestionText        = "text"
+)
+
+// Valid question types
+var ValidQuestionTypes = []string{QuestionScale, QuestionBoolean, QuestionMultiChoice, QuestionNumber, QuestionText}
+
+// ScoreQuestionID is the question of the default templates that mirrors
+// Checkin.Score
+const ScoreQuestionID = "score"
 
 // Score range
 const (
@@ -25,13 +43,88 @@ const (
 type Checkin struct {
 	ID        uuid.UUID `json:"id"`
 	ProfileID uuid.UUID `json:"profile_id"`
-	Date      string    `json:"date"` // YYYY-MM-DD
-	Type      string    `json:"type"` // "morning" or "evening"
-	Score     int       `json:"score"`
+	Date      string    `json:"date"`  // YYYY-MM-DD
+	Type      string    `json:"type"`  // "morning", "evening" or "adhoc"
+	Score     int       `json:"score"` // 0 for adhoc checkins without score
 	Tags      []string  `json:"tags"`
 	Note      string    `json:"note"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	// TemplateID is the template the answers belong to
+	TemplateID *uuid.UUID `json:"template_id,omitempty"`
+	RecordedAt time.Time  `json:"recorded_at"`
+	Answers    []Answer   `json:"answers,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Answer is the answer to one template question. Exactly one value is set:
+// Number for scale and number, Bool for boolean, Options for multi_choice
+// and Text for text questions.
+type Answer struct {
+	QuestionID string   `json:"question_id"`
+	Number     *float64 `json:"number,omitempty"`
+	Bool       *bool    `json:"bool,omitempty"`
+	Options    []string `json:"options,omitempty"`
+	Text       *string  `json:"text,omitempty"`
+}
+
+// Value returns the answer as it is rendered in JSON
+func (a Answer) Value() any {
+	switch {
+	case a.Number != nil:
+		return *a.Number
+	case a.Bool != nil:
+		return *a.Bool
+	case a.Options != nil:
+		return a.Options
+	case a.Text != nil:
+		return *a.Text
+	}
+	return nil
+}
+
+// AnswerPoint is an answer with the checkin it was given in, for charts
+type AnswerPoint struct {
+	CheckinID  uuid.UUID
+	TemplateID *uuid.UUID
+	Date       string
+	RecordedAt time.Time
+	Answer     Answer
+}
+
+// Template is a set of questions a checkin is filled with. Every profile
+// has a default morning and evening template asking for the score.
+type Template struct {
+	ID        uuid.UUID  `json:"id"`
+	ProfileID uuid.UUID  `json:"profile_id"`
+	Name      string     `json:"name"`
+	Type      string     `json:"type"` // checkin type the template is filled for
+	IsDefault bool       `json:"is_default"`
+	Questions []Question `json:"questions"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Question is one question of a template. Min and Max bound scale (default
+// 1–5) and number answers; Options lists the choices of multi_choice.
+type Question struct {
+	ID       string   `json:"id"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	Unit     string   `json:"unit,omitempty"`
+	Options  []string `json:"options,omitempty"`
+	Required bool     `json:"required,omitempty"`
+}
+
+// Question returns the question with the given ID
+func (t *Template) Question(id string) (Question, bool) {
+	for _, q := range t.Questions {
+		if q.ID == id {
+			return q, true
+		}
+	}
+	return Question{}, false
 }
 
 // CheckinDTO is the API response format
@@ -40,11 +133,15 @@ type CheckinDTO struct {
 	ProfileID uuid.UUID `json:"profile_id"`
 	Date      string    `json:"date"`
 	Type      string    `json:"type"`
-	Score     int       `json:"score"`
+	Score     int       `json:"score,omitempty"`
 	Tags      []string  `json:"tags,omitempty"`
 	Note      string    `json:"note,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	// Answers maps question IDs to numbers, booleans, option lists or text
+	Answers    map[string]any `json:"answers,omitempty"`
+	TemplateID *uuid.UUID     `json:"template_id,omitempty"`
+	RecordedAt time.Time      `json:"recorded_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
 }
 
 // UpsertCheckinRequest is the request body for creating/updating a check-in
@@ -55,6 +152,11 @@ type UpsertCheckinRequest struct {
 	Score     int       `json:"score"`
 	Tags      []string  `json:"tags,omitempty"`
 	Note      string    `json:"note,omitempty"`
+	// TemplateID defaults to the default template of the type; Type
+	// defaults to the type of the template
+	TemplateID *uuid.UUID                 `json:"template_id,omitempty"`
+	RecordedAt *time.Time                 `json:"recorded_at,omitempty"`
+	Answers    map[string]json.RawMessage `json:"answers,omitempty"`
 }
 
 // CheckinsResponse is the response for listing check-ins
@@ -62,18 +164,93 @@ type CheckinsResponse struct {
 	Checkins []CheckinDTO `json:"checkins"`
 }
 
+// TemplateDTO is the API response format of a template
+type TemplateDTO struct {
+	ID        uuid.UUID  `json:"id"`
+	ProfileID uuid.UUID  `json:"profile_id"`
+	Name      string     `json:"name"`
+	Type      string     `json:"type"`
+	IsDefault bool       `json:"is_default"`
+	Questions []Question `json:"questions"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// TemplatesResponse is the response for listing templates
+type TemplatesResponse struct {
+	Templates []TemplateDTO `json:"templates"`
+}
+
+// CreateTemplateRequest is the request body for creating a template
+type CreateTemplateRequest struct {
+	ProfileID uuid.UUID  `json:"profile_id"`
+	Name      string     `json:"name"`
+	Type      string     `json:"type,omitempty"` // default "adhoc"
+	Questions []Question `json:"questions"`
+}
+
+// UpdateTemplateRequest is the request body for updating a template; nil
+// fields are left unchanged
+type UpdateTemplateRequest struct {
+	Name      *string     `json:"name,omitempty"`
+	Questions *[]Question `json:"questions,omitempty"`
+}
+
+// AnswerPointDTO is one answer on a chart
+type AnswerPointDTO struct {
+	CheckinID  uuid.UUID  `json:"checkin_id"`
+	TemplateID *uuid.UUID `json:"template_id,omitempty"`
+	Date       string     `json:"date"`
+	RecordedAt time.Time  `json:"recorded_at"`
+	Value      any        `json:"value"`
+}
+
+// AnswersResponse is the response for answers to one question
+type AnswersResponse struct {
+	QuestionID string           `json:"question_id"`
+	Points     []AnswerPointDTO `json:"points"`
+}
+
 // ToDTO converts Checkin to CheckinDTO
 func (c *Checkin) ToDTO() CheckinDTO {
+	var answers map[string]any
+	if len(c.Answers) > 0 {
+		answers = make(map[string]any, len(c.Answers))
+		for _, a := range c.Answers {
+			answers[a.QuestionID] = a.Value()
+		}
+	}
 	return CheckinDTO{
-		ID:        c.ID,
-		ProfileID: c.ProfileID,
-		Date:      c.Date,
-		Type:      c.Type,
-		Score:     c.Score,
-		Tags:      c.Tags,
-		Note:      c.Note,
-		CreatedAt: c.CreatedAt,
-		UpdatedAt: c.UpdatedAt,
+		ID:         c.ID,
+		ProfileID:  c.ProfileID,
+		Date:       c.Date,
+		Type:       c.Type,
+		Score:      c.Score,
+		Tags:       c.Tags,
+		Note:       c.Note,
+		TemplateID: c.TemplateID,
+		RecordedAt: c.RecordedAt,
+		Answers:    answers,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+	}
+}
+
+// ToDTO converts Template to TemplateDTO
+func (t *Template) ToDTO() TemplateDTO {
+	questions := t.Questions
+	if questions == nil {
+		questions = []Question{}
+	}
+	return TemplateDTO{
+		ID:        t.ID,
+		ProfileID: t.ProfileID,
+		Name:      t.Name,
+		Type:      t.Type,
+		IsDefault: t.IsDefault,
+		Questions: questions,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
 	}
 }
 
@@ -84,6 +261,7 @@ type ErrorResponse struct {
 
 // ErrorDetail contains error code and message
 type ErrorDetail struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
 }
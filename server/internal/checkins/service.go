@@ -18,6 +18,7 @@ var (
 	ErrInvalidScore    = errors.New("score must be between 1 and 5")
 	ErrInvalidDate     = errors.New("invalid date format")
 	ErrProfileNotFound = errors.New("profile not found")
+	ErrMissingDate     = errors.New("date is required for morning and evening checkins")
 )
 
 // Storage defines the interface for checkin storage operations
@@ -33,6 +34,27 @@ type Storage interface {
 
 	// DeleteCheckin deletes a check-in by ID
 	DeleteCheckin(id uuid.UUID) error
+
+	// ListTemplates returns the templates of a profile, defaults first
+	ListTemplates(profileID uuid.UUID) ([]Template, error)
+
+	// GetTemplate retrieves a template by ID
+	GetTemplate(id uuid.UUID) (*Template, error)
+
+	// CreateTemplate stores a new template. When a default template of the
+	// same profile and type already exists, it is loaded into template
+	// instead.
+	CreateTemplate(template *Template) error
+
+	// UpdateTemplate saves the name and questions of a template
+	UpdateTemplate(template *Template) error
+
+	// DeleteTemplate deletes a template; its checkins keep their answers
+	DeleteTemplate(id uuid.UUID) error
+
+	// ListAnswers returns answers to a question within a date range, oldest
+	// first. A nil templateID matches any template.
+	ListAnswers(profileID uuid.UUID, templateID *uuid.UUID, questionID, from, to string) ([]AnswerPoint, error)
 }
 
 // ProfileStorage defines the interface for profile operations
@@ -81,39 +103,82 @@ func (s *Service) ListCheckins(ctx context.Context, profileID uuid.UUID, from, t
 	return dtos, nil
 }
 
-// UpsertCheckin creates or updates a check-in
+// UpsertCheckin creates or updates a check-in. Morning and evening
+// checkins are updated in place (one per day); adhoc checkins are always
+// created.
 func (s *Service) UpsertCheckin(ctx context.Context, req UpsertCheckinRequest) (*CheckinDTO, error) {
 	if err := s.ensureProfileAccess(ctx, req.ProfileID); err != nil {
 		return nil, ErrProfileNotFound
 	}
 
-	// Validate type
-	if !isValidType(req.Type) {
+	// Resolve template and type
+	template, err := s.checkinTemplate(req)
+	if err != nil {
+		return nil, err
+	}
+	checkinType := req.Type
+	if checkinType == "" && template != nil {
+		checkinType = template.Type
+	}
+	if !isValidType(checkinType) || (template != nil && template.Type != checkinType) {
 		return nil, ErrInvalidType
 	}
 
-	// Validate score
-	if req.Score < MinScore || req.Score > MaxScore {
+	if req.Score != 0 && (req.Score < MinScore || req.Score > MaxScore) {
+		return nil, ErrInvalidScore
+	}
+
+	// Validate answers; the score question and Score mirror each other
+	answers, err := parseAnswers(template, withScoreAnswer(template, req.Answers, req.Score))
+	if err != nil {
+		return nil, err
+	}
+	score, err := scoreFromAnswers(template, answers, req.Score)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRequired(template, answers); err != nil {
+		return nil, err
+	}
+
+	// Validate score; adhoc checkins may go without one
+	if (score != 0 || checkinType != TypeAdhoc) && (score < MinScore || score > MaxScore) {
 		return nil, ErrInvalidScore
 	}
 
 	// Validate date
-	if err := validateDate(req.Date); err != nil {
+	now := time.Now().UTC()
+	recordedAt := now
+	if req.RecordedAt != nil {
+		recordedAt = req.RecordedAt.UTC()
+	}
+	date := req.Date
+	if date == "" {
+		if checkinType != TypeAdhoc {
+			return nil, ErrMissingDate
+		}
+		date = recordedAt.Format("2006-01-02")
+	}
+	if err := validateDate(date); err != nil {
 		return nil, ErrInvalidDate
 	}
 
 	// Create checkin
-	now := time.Now().UTC()
 	checkin := &Checkin{
-		ID:        uuid.New(),
-		ProfileID: req.ProfileID,
-		Date:      req.Date,
-		Type:      req.Type,
-		Score:     req.Score,
-		Tags:      req.Tags,
-		Note:      req.Note,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:         uuid.New(),
+		ProfileID:  req.ProfileID,
+		Date:       date,
+		Type:       checkinType,
+		Score:      score,
+		Tags:       req.Tags,
+		Note:       req.Note,
+		RecordedAt: recordedAt,
+		Answers:    answers,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if template != nil {
+		checkin.TemplateID = &template.ID
 	}
 
 	if err := s.storage.UpsertCheckin(checkin); err != nil {
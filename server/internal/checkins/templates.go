@@ -0,0 +1,537 @@
+package checkins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrInvalidTemplate  = errors.New("invalid template")
+	ErrDefaultTemplate  = errors.New("default templates cannot be deleted")
+	ErrInvalidAnswer    = errors.New("invalid answer")
+)
+
+// Template limits
+const (
+	maxTemplateName = 100
+	maxQuestions    = 30
+	maxLabel        = 200
+	maxUnit         = 20
+	maxOptions      = 20
+	maxOption       = 100
+	maxScaleSteps   = 100
+	maxTextAnswer   = 2000
+)
+
+// questionIDPattern keeps question IDs stable keys usable in URLs
+var questionIDPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+// defaultTemplate returns the template morning and evening checkins are
+// filled with until the user changes it. Migration 00033 creates the same
+// templates for profiles that already had checkins.
+func defaultTemplate(profileID uuid.UUID, checkinType string) Template {
+	name := "Утро"
+	if checkinType == TypeEvening {
+		name = "Вечер"
+	}
+	return Template{
+		ID:        uuid.New(),
+		ProfileID: profileID,
+		Name:      name,
+		Type:      checkinType,
+		IsDefault: true,
+		Questions: []Question{scoreQuestion()},
+	}
+}
+
+func scoreQuestion() Question {
+	low, high := float64(MinScore), float64(MaxScore)
+	return Question{ID: ScoreQuestionID, Label: "Самочувствие", Type: QuestionScale, Min: &low, Max: &high, Required: true}
+}
+
+// ListTemplates returns the templates of a profile, creating the default
+// ones on first use
+func (s *Service) ListTemplates(ctx context.Context, profileID uuid.UUID) ([]TemplateDTO, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, ErrProfileNotFound
+	}
+
+	templates, err := s.ensureDefaultTemplates(profileID)
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := make([]TemplateDTO, len(templates))
+	for i, t := range templates {
+		dtos[i] = t.ToDTO()
+	}
+	return dtos, nil
+}
+
+// CreateTemplate creates a user-defined template
+func (s *Service) CreateTemplate(ctx context.Context, req CreateTemplateRequest) (*TemplateDTO, error) {
+	if err := s.ensureProfileAccess(ctx, req.ProfileID); err != nil {
+		return nil, ErrProfileNotFound
+	}
+
+	if req.Type == "" {
+		req.Type = TypeAdhoc
+	}
+	if !isValidType(req.Type) {
+		return nil, ErrInvalidType
+	}
+	name, questions, err := validateTemplate(req.Name, req.Questions)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	template := &Template{
+		ID:        uuid.New(),
+		ProfileID: req.ProfileID,
+		Name:      name,
+		Type:      req.Type,
+		Questions: questions,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.storage.CreateTemplate(template); err != nil {
+		return nil, err
+	}
+
+	dto := template.ToDTO()
+	return &dto, nil
+}
+
+// UpdateTemplate renames a template or replaces its questions. Answers
+// already given keep their question IDs, so a question that keeps its ID
+// keeps its chart.
+func (s *Service) UpdateTemplate(ctx context.Context, id uuid.UUID, req UpdateTemplateRequest) (*TemplateDTO, error) {
+	template, err := s.accessibleTemplate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	name, questions := template.Name, template.Questions
+	if req.Name != nil {
+		name = *req.Name
+	}
+	if req.Questions != nil {
+		questions = *req.Questions
+	}
+	name, questions, err = validateTemplate(name, questions)
+	if err != nil {
+		return nil, err
+	}
+	if template.IsDefault && !keepsScoreQuestion(questions) {
+		return nil, fmt.Errorf("%w: default templates keep the %q question as a %d–%d scale", ErrInvalidTemplate, ScoreQuestionID, MinScore, MaxScore)
+	}
+
+	template.Name = name
+	template.Questions = questions
+	template.UpdatedAt = time.Now().UTC()
+	if err := s.storage.UpdateTemplate(template); err != nil {
+		return nil, err
+	}
+
+	dto := template.ToDTO()
+	return &dto, nil
+}
+
+// DeleteTemplate deletes a user-defined template
+func (s *Service) DeleteTemplate(ctx context.Context, id uuid.UUID) error {
+	template, err := s.accessibleTemplate(ctx, id)
+	if err != nil {
+		return err
+	}
+	if template.IsDefault {
+		return ErrDefaultTemplate
+	}
+	return s.storage.DeleteTemplate(id)
+}
+
+// ListAnswers returns the answers to one question within a date range,
+// oldest first, for charts
+func (s *Service) ListAnswers(ctx context.Context, profileID uuid.UUID, templateID *uuid.UUID, questionID, from, to string) ([]AnswerPointDTO, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, ErrProfileNotFound
+	}
+	if err := validateDate(from); err != nil {
+		return nil, fmt.Errorf("invalid from date: %w", err)
+	}
+	if err := validateDate(to); err != nil {
+		return nil, fmt.Errorf("invalid to date: %w", err)
+	}
+
+	points, err := s.storage.ListAnswers(profileID, templateID, questionID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := make([]AnswerPointDTO, len(points))
+	for i, p := range points {
+		dtos[i] = AnswerPointDTO{
+			CheckinID:  p.CheckinID,
+			TemplateID: p.TemplateID,
+			Date:       p.Date,
+			RecordedAt: p.RecordedAt,
+			Value:      p.Answer.Value(),
+		}
+	}
+	return dtos, nil
+}
+
+// accessibleTemplate loads a template the caller may change
+func (s *Service) accessibleTemplate(ctx context.Context, id uuid.UUID) (*Template, error) {
+	template, err := s.storage.GetTemplate(id)
+	if err != nil {
+		return nil, ErrTemplateNotFound
+	}
+	if err := s.ensureProfileAccess(ctx, template.ProfileID); err != nil {
+		return nil, ErrTemplateNotFound
+	}
+	return template, nil
+}
+
+// checkinTemplate returns the template a checkin is filled with: the
+// requested one, or the default template of morning and evening checkins.
+// Adhoc checkins may go without template.
+func (s *Service) checkinTemplate(req UpsertCheckinRequest) (*Template, error) {
+	if req.TemplateID != nil {
+		template, err := s.storage.GetTemplate(*req.TemplateID)
+		if err != nil || template.ProfileID != req.ProfileID {
+			return nil, ErrTemplateNotFound
+		}
+		return template, nil
+	}
+	if req.Type != TypeMorning && req.Type != TypeEvening {
+		return nil, nil
+	}
+
+	templates, err := s.ensureDefaultTemplates(req.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range templates {
+		if t.IsDefault && t.Type == req.Type {
+			return &t, nil
+		}
+	}
+	return nil, ErrTemplateNotFound
+}
+
+// ensureDefaultTemplates creates the missing default templates of a profile
+// and returns all its templates
+func (s *Service) ensureDefaultTemplates(profileID uuid.UUID) ([]Template, error) {
+	templates, err := s.storage.ListTemplates(profileID)
+	if err != nil {
+		return nil, err
+	}
+
+	created := false
+	for _, checkinType := range []string{TypeMorning, TypeEvening} {
+		if hasDefaultTemplate(templates, checkinType) {
+			continue
+		}
+		template := defaultTemplate(profileID, checkinType)
+		now := time.Now().UTC()
+		template.CreatedAt, template.UpdatedAt = now, now
+		if err := s.storage.CreateTemplate(&template); err != nil {
+			return nil, err
+		}
+		created = true
+	}
+	if !created {
+		return templates, nil
+	}
+	return s.storage.ListTemplates(profileID)
+}
+
+func hasDefaultTemplate(templates []Template, checkinType string) bool {
+	for _, t := range templates {
+		if t.IsDefault && t.Type == checkinType {
+			return true
+		}
+	}
+	return false
+}
+
+// keepsScoreQuestion reports whether questions still ask for the score the
+// way Checkin.Score stores it
+func keepsScoreQuestion(questions []Question) bool {
+	for _, q := range questions {
+		if q.ID == ScoreQuestionID {
+			return q.Type == QuestionScale && *q.Min == MinScore && *q.Max == MaxScore
+		}
+	}
+	return false
+}
+
+// validateTemplate checks a template name and its questions and returns
+// them normalized: trimmed, with scale bounds filled in and fields that do
+// not apply to the question type dropped
+func validateTemplate(name string, questions []Question) (string, []Question, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxTemplateName {
+		return "", nil, fmt.Errorf("%w: name must be 1–%d characters", ErrInvalidTemplate, maxTemplateName)
+	}
+	if len(questions) > maxQuestions {
+		return "", nil, fmt.Errorf("%w: at most %d questions", ErrInvalidTemplate, maxQuestions)
+	}
+
+	seen := make(map[string]bool, len(questions))
+	normalized := make([]Question, 0, len(questions))
+	for _, q := range questions {
+		q.ID = strings.TrimSpace(q.ID)
+		if !questionIDPattern.MatchString(q.ID) {
+			return "", nil, fmt.Errorf("%w: question id %q must be lowercase letters, digits and _", ErrInvalidTemplate, q.ID)
+		}
+		if seen[q.ID] {
+			return "", nil, fmt.Errorf("%w: duplicate question id %q", ErrInvalidTemplate, q.ID)
+		}
+		seen[q.ID] = true
+
+		q.Label = strings.TrimSpace(q.Label)
+		if q.Label == "" || utf8.RuneCountInString(q.Label) > maxLabel {
+			return "", nil, fmt.Errorf("%w: question %q needs a label of at most %d characters", ErrInvalidTemplate, q.ID, maxLabel)
+		}
+		q.Unit = strings.TrimSpace(q.Unit)
+		if utf8.RuneCountInString(q.Unit) > maxUnit {
+			return "", nil, fmt.Errorf("%w: unit of question %q is too long", ErrInvalidTemplate, q.ID)
+		}
+
+		var err error
+		switch q.Type {
+		case QuestionScale:
+			err = normalizeScale(&q)
+		case QuestionNumber:
+			if q.Min != nil && q.Max != nil && *q.Min > *q.Max {
+				err = fmt.Errorf("%w: question %q has min above max", ErrInvalidTemplate, q.ID)
+			}
+			q.Options = nil
+		case QuestionMultiChoice:
+			err = normalizeOptions(&q)
+			q.Min, q.Max, q.Unit = nil, nil, ""
+		case QuestionBoolean, QuestionText:
+			q.Min, q.Max, q.Unit, q.Options = nil, nil, "", nil
+		default:
+			err = fmt.Errorf("%w: question %q has unknown type %q", ErrInvalidTemplate, q.ID, q.Type)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		normalized = append(normalized, q)
+	}
+	return name, normalized, nil
+}
+
+func normalizeScale(q *Question) error {
+	low, high := float64(MinScore), float64(MaxScore)
+	if q.Min != nil {
+		low = *q.Min
+	}
+	if q.Max != nil {
+		high = *q.Max
+	}
+	if low != math.Trunc(low) || high != math.Trunc(high) || low >= high || high-low > maxScaleSteps {
+		return fmt.Errorf("%w: scale %q needs whole min < max at most %d apart", ErrInvalidTemplate, q.ID, maxScaleSteps)
+	}
+	q.Min, q.Max, q.Options = &low, &high, nil
+	return nil
+}
+
+func normalizeOptions(q *Question) error {
+	if len(q.Options) == 0 || len(q.Options) > maxOptions {
+		return fmt.Errorf("%w: question %q needs 1–%d options", ErrInvalidTemplate, q.ID, maxOptions)
+	}
+	seen := make(map[string]bool, len(q.Options))
+	options := make([]string, 0, len(q.Options))
+	for _, option := range q.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || utf8.RuneCountInString(option) > maxOption || seen[option] {
+			return fmt.Errorf("%w: options of question %q must be unique and 1–%d characters", ErrInvalidTemplate, q.ID, maxOption)
+		}
+		seen[option] = true
+		options = append(options, option)
+	}
+	q.Options = options
+	return nil
+}
+
+// withScoreAnswer adds Score as the answer to the score question when the
+// client sent it the old way, as a top-level field
+func withScoreAnswer(template *Template, raw map[string]json.RawMessage, score int) map[string]json.RawMessage {
+	if template == nil || score == 0 {
+		return raw
+	}
+	if q, ok := template.Question(ScoreQuestionID); !ok || q.Type != QuestionScale {
+		return raw
+	}
+	if value, ok := raw[ScoreQuestionID]; ok && !isNull(value) {
+		return raw
+	}
+
+	withScore := make(map[string]json.RawMessage, len(raw)+1)
+	for id, value := range raw {
+		withScore[id] = value
+	}
+	withScore[ScoreQuestionID] = json.RawMessage(strconv.Itoa(score))
+	return withScore
+}
+
+// scoreFromAnswers returns the score given as the answer to the score
+// question, or score when there is none
+func scoreFromAnswers(template *Template, answers []Answer, score int) (int, error) {
+	if template == nil {
+		return score, nil
+	}
+	if q, ok := template.Question(ScoreQuestionID); !ok || q.Type != QuestionScale {
+		return score, nil
+	}
+	for _, a := range answers {
+		if a.QuestionID != ScoreQuestionID || a.Number == nil {
+			continue
+		}
+		answered := int(*a.Number)
+		if score != 0 && score != answered {
+			return 0, fmt.Errorf("%w: score and answer %q differ", ErrInvalidScore, ScoreQuestionID)
+		}
+		return answered, nil
+	}
+	return score, nil
+}
+
+// parseAnswers validates raw answers against the template questions and
+// returns them in question order. Null and blank text answers are skipped.
+func parseAnswers(template *Template, raw map[string]json.RawMessage) ([]Answer, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if template == nil {
+		return nil, fmt.Errorf("%w: answers need a template", ErrInvalidAnswer)
+	}
+	for id := range raw {
+		if _, ok := template.Question(id); !ok {
+			return nil, fmt.Errorf("%w: template has no question %q", ErrInvalidAnswer, id)
+		}
+	}
+
+	var answers []Answer
+	for _, q := range template.Questions {
+		value, ok := raw[q.ID]
+		if !ok || isNull(value) {
+			continue
+		}
+		answer, ok, err := parseAnswer(q, value)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			answers = append(answers, answer)
+		}
+	}
+	return answers, nil
+}
+
+func parseAnswer(q Question, value json.RawMessage) (Answer, bool, error) {
+	answer := Answer{QuestionID: q.ID}
+	switch q.Type {
+	case QuestionScale, QuestionNumber:
+		var number float64
+		if err := json.Unmarshal(value, &number); err != nil {
+			return answer, false, fmt.Errorf("%w: %q expects a number", ErrInvalidAnswer, q.ID)
+		}
+		if q.Type == QuestionScale && number != math.Trunc(number) {
+			return answer, false, fmt.Errorf("%w: %q expects a whole number", ErrInvalidAnswer, q.ID)
+		}
+		if (q.Min != nil && number < *q.Min) || (q.Max != nil && number > *q.Max) {
+			return answer, false, fmt.Errorf("%w: %q is out of range", ErrInvalidAnswer, q.ID)
+		}
+		answer.Number = &number
+	case QuestionBoolean:
+		var b bool
+		if err := json.Unmarshal(value, &b); err != nil {
+			return answer, false, fmt.Errorf("%w: %q expects true or false", ErrInvalidAnswer, q.ID)
+		}
+		answer.Bool = &b
+	case QuestionMultiChoice:
+		var chosen []string
+		if err := json.Unmarshal(value, &chosen); err != nil {
+			return answer, false, fmt.Errorf("%w: %q expects a list of options", ErrInvalidAnswer, q.ID)
+		}
+		picked := make(map[string]bool, len(chosen))
+		for _, option := range chosen {
+			if !containsString(q.Options, option) {
+				return answer, false, fmt.Errorf("%w: %q has no option %q", ErrInvalidAnswer, q.ID, option)
+			}
+			picked[option] = true
+		}
+		// Options in template order, without repeats
+		answer.Options = make([]string, 0, len(picked))
+		for _, option := range q.Options {
+			if picked[option] {
+				answer.Options = append(answer.Options, option)
+			}
+		}
+	case QuestionText:
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			return answer, false, fmt.Errorf("%w: %q expects text", ErrInvalidAnswer, q.ID)
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return answer, false, nil
+		}
+		if utf8.RuneCountInString(text) > maxTextAnswer {
+			return answer, false, fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidAnswer, q.ID, maxTextAnswer)
+		}
+		answer.Text = &text
+	}
+	return answer, true, nil
+}
+
+// checkRequired returns ErrInvalidAnswer when a required question has no
+// answer
+func checkRequired(template *Template, answers []Answer) error {
+	if template == nil {
+		return nil
+	}
+	answered := make(map[string]bool, len(answers))
+	for _, a := range answers {
+		answered[a.QuestionID] = true
+	}
+	for _, q := range template.Questions {
+		if q.Required && !answered[q.ID] {
+			if q.ID == ScoreQuestionID {
+				return ErrInvalidScore
+			}
+			return fmt.Errorf("%w: %q is required", ErrInvalidAnswer, q.ID)
+		}
+	}
+	return nil
+}
+
+func isNull(value json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package coaching
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+// ProposalKindAdjustment is the proposal a check-in leaves when a program
+// falls behind; proposals.Service applies it through Reschedule.
+const ProposalKindAdjustment = "coaching_adjustment"
+
+// checkinBatch bounds how many programs one pass checks in.
+const checkinBatch = 100
+
+// CheckinDue runs the weekly check-in of every program that is due and
+// returns how many were checked in.
+func (s *Service) CheckinDue(ctx context.Context) (int, error) {
+	programs, err := s.storage.ListDueCoachingPrograms(ctx, s.now().UTC(), checkinBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	checked := 0
+	for _, program := range programs {
+		if err := s.checkin(ctx, program); err != nil {
+			slog.Error("coaching: check-in failed", "program_id", program.ID, "error", err)
+			continue
+		}
+		checked++
+	}
+	return checked, nil
+}
+
+// RunCheckins checks in due programs every interval until ctx is cancelled.
+func (s *Service) RunCheckins(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if checked, err := s.CheckinDue(ctx); err != nil {
+			slog.Error("coaching: check-in pass failed", "error", err)
+		} else if checked > 0 {
+			slog.Info("coaching: checked in programs", "programs", checked)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkin compares the last full week with the schedule, marks reached
+// milestones and, when the program trails, proposes moving its schedule.
+func (s *Service) checkin(ctx context.Context, program storage.CoachingProgram) error {
+	now := s.now().UTC()
+	periodTo := s.today().AddDate(0, 0, -1)
+	periodFrom := periodTo.AddDate(0, 0, -6)
+
+	value, err := s.measure(ctx, program.ProfileID, program.Metric, periodFrom, periodTo)
+	if err != nil {
+		return err
+	}
+	status := assess(program, value, periodTo)
+
+	if value != nil {
+		for i := range program.Milestones {
+			milestone := &program.Milestones[i]
+			if milestone.ReachedAt == nil && reached(program, *value, milestone.TargetValue) {
+				reachedAt := now
+				milestone.ReachedAt = &reachedAt
+			}
+		}
+	}
+
+	checkin := storage.CoachingCheckin{
+		PeriodFrom:    periodFrom,
+		PeriodTo:      periodTo,
+		Value:         value,
+		ExpectedValue: roundValue(program.Metric, expectedAt(program, periodTo)),
+		Status:        status,
+	}
+	switch status {
+	case CheckinAchieved:
+		program.Status = StatusCompleted
+	case CheckinBehind:
+		if s.proposalsStorage != nil {
+			proposalID, err := s.proposeAdjustment(ctx, program, checkin)
+			if err != nil {
+				return err
+			}
+			if proposalID != uuid.Nil {
+				checkin.ProposalID = &proposalID
+			}
+		}
+	}
+
+	// A server that was down for weeks resumes on the weekly rhythm
+	// instead of catching up with one check-in per missed week.
+	for !program.NextCheckinAt.After(now) {
+		program.NextCheckinAt = program.NextCheckinAt.Add(checkinInterval)
+	}
+
+	// false means the program was deleted meanwhile; nothing to record.
+	_, _, err = s.storage.RecordCoachingCheckin(ctx, checkin, program)
+	return err
+}
+
+// proposeAdjustment drafts a coaching_adjustment that moves the first
+// unreached milestone, every one after it and the target date by the
+// whole weeks the program lags. uuid.Nil means the program cannot be
+// extended any further.
+func (s *Service) proposeAdjustment(ctx context.Context, program storage.CoachingProgram, checkin storage.CoachingCheckin) (uuid.UUID, error) {
+	lag := lagDays(program, *checkin.Value, checkin.PeriodTo)
+	weeks := (lag + 6) / 7
+	if weeks < 1 {
+		weeks = 1
+	}
+	shift := weeks * 7
+	target := program.TargetDate.AddDate(0, 0, shift)
+	if daysBetween(program.StartDate, target) > maxProgramDays {
+		return uuid.Nil, nil
+	}
+
+	payload := AdjustmentPayload{
+		ProgramID:  program.ID,
+		TargetDate: formatDate(target),
+		Milestones: make([]MilestoneInput, 0, len(program.Milestones)),
+	}
+	shifting := false
+	for _, milestone := range program.Milestones {
+		shifting = shifting || milestone.ReachedAt == nil
+		date := milestone.DueDate
+		if shifting {
+			date = date.AddDate(0, 0, shift)
+		}
+		payload.Milestones = append(payload.Milestones, MilestoneInput{Date: formatDate(date), Value: milestone.TargetValue})
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	expiresAt := s.now().UTC().Add(s.proposalTTL)
+	created, err := s.proposalsStorage.InsertMany(ctx, program.OwnerUserID, program.ProfileID, []storage.ProposalDraft{{
+		Kind:  ProposalKindAdjustment,
+		Title: fmt.Sprintf("Скорректировать программу «%s»", program.Title),
+		Summary: fmt.Sprintf("За неделю %s — %s: %s при плане %s. Предлагаю сдвинуть оставшиеся вехи и целевую дату на %d нед., до %s.",
+			formatDate(checkin.PeriodFrom), formatDate(checkin.PeriodTo),
+			formatValue(program.Metric, *checkin.Value), formatValue(program.Metric, checkin.ExpectedValue),
+			weeks, payload.TargetDate),
+		Payload:   raw,
+		ExpiresAt: &expiresAt,
+	}})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if len(created) == 0 {
+		return uuid.Nil, nil
+	}
+	proposalID := created[0].ID
+
+	// Only the newest adjustment of a program stays pending; adjustments
+	// of the profile's other programs are kept.
+	pending, err := s.proposalsStorage.List(ctx, program.OwnerUserID, program.ProfileID, []string{"pending"}, 200)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	keep := []uuid.UUID{proposalID}
+	for _, proposal := range pending {
+		if proposal.Kind != ProposalKindAdjustment || proposal.ID == proposalID {
+			continue
+		}
+		var other AdjustmentPayload
+		if json.Unmarshal(proposal.Payload, &other) != nil || other.ProgramID != program.ID {
+			keep = append(keep, proposal.ID)
+		}
+	}
+	if superseded, err := s.proposalsStorage.SupersedePending(ctx, program.OwnerUserID, program.ProfileID, ProposalKindAdjustment, keep); err != nil {
+		return uuid.Nil, err
+	} else if superseded > 0 {
+		slog.Info("coaching: superseded older adjustments", "program_id", program.ID, "superseded", superseded)
+	}
+	return proposalID, nil
+}
+
+// formatValue renders a metric value with its unit for proposal texts.
+func formatValue(metric string, value float64) string {
+	switch metric {
+	case MetricWeightKg:
+		return strconv.FormatFloat(value, 'f', 1, 64) + " кг"
+	case MetricDistanceKm:
+		return strconv.FormatFloat(value, 'f', 1, 64) + " км"
+	case MetricSleepMinutes:
+		return strconv.FormatFloat(value, 'f', 0, 64) + " мин сна"
+	case MetricSteps:
+		return strconv.FormatFloat(value, 'f', 0, 64) + " шагов"
+	default:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+}
@@ -0,0 +1,146 @@
+package coaching
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
+	"github.com/fdg312/health-hub/internal/userctx"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// HandleList handles GET /v1/coaching/programs?profile_id=&status=
+func (h *Handler) HandleList(w http.ResponseWriter, r *http.Request) {
+	profileID, err := uuid.Parse(strings.TrimSpace(r.URL.Query().Get("profile_id")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "profile_id is required")
+		return
+	}
+
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	resp, err := h.service.List(r.Context(), ownerFromRequest(r), profileID, status)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleCreate handles POST /v1/coaching/programs
+func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var req CreateProgramRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.Create(r.Context(), ownerFromRequest(r), req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// HandleGet handles GET /v1/coaching/programs/{id}
+func (h *Handler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	programID, ok := parseProgramID(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.Get(r.Context(), ownerFromRequest(r), programID)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleCancel handles POST /v1/coaching/programs/{id}/cancel
+func (h *Handler) HandleCancel(w http.ResponseWriter, r *http.Request) {
+	programID, ok := parseProgramID(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.Cancel(r.Context(), ownerFromRequest(r), programID)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleDelete handles DELETE /v1/coaching/programs/{id}
+func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	programID, ok := parseProgramID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(r.Context(), ownerFromRequest(r), programID); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+	case errors.Is(err, ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+	case errors.Is(err, ErrProgramNotFound):
+		writeError(w, http.StatusNotFound, "program_not_found", "Coaching program not found")
+	case errors.Is(err, ErrNotActive):
+		writeError(w, http.StatusConflict, "not_active", "Coaching program is not active")
+	case errors.Is(err, ErrNoBaseline):
+		writeError(w, http.StatusConflict, "baseline_unknown", "No recent data for this metric, pass baseline_value")
+	default:
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+	}
+}
+
+func parseProgramID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	programID, err := uuid.Parse(strings.TrimSpace(r.PathValue("id")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid program id")
+		return uuid.Nil, false
+	}
+	return programID, true
+}
+
+func ownerFromRequest(r *http.Request) string {
+	userID, _ := userctx.GetUserID(r.Context())
+	return strings.TrimSpace(userID)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{
+		Error: ErrorDetail{
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
+		},
+	})
+}
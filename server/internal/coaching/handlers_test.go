@@ -0,0 +1,276 @@
+package coaching
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/storage/memory"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+func TestCreateProgramReadsBaselineFromMetrics(t *testing.T) {
+	handler, mem, profileID := setupCoachingHandler(t)
+	today := utcToday()
+	seedWeight(t, mem, profileID, today.AddDate(0, 0, -5), 5, 80.2)
+
+	body := fmt.Sprintf(`{"profile_id":%q,"title":"Минус 5 кг","metric":"weight_kg","target_value":75.2,"target_date":%q}`,
+		profileID, today.AddDate(0, 0, 70).Format("2006-01-02"))
+	w := serveCoaching(handler.HandleCreate, http.MethodPost, "/v1/coaching/programs", "", body, "userA")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	var program ProgramDTO
+	if err := json.NewDecoder(w.Body).Decode(&program); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if program.BaselineValue != 80.2 || program.TargetValue != 75.2 {
+		t.Fatalf("expected 80.2 -> 75.2, got %v -> %v", program.BaselineValue, program.TargetValue)
+	}
+	if program.StartDate != today.Format("2006-01-02") || program.Status != StatusActive {
+		t.Fatalf("unexpected start %s status %s", program.StartDate, program.Status)
+	}
+	if len(program.Milestones) != 10 {
+		t.Fatalf("expected 10 weekly milestones, got %d", len(program.Milestones))
+	}
+	if program.Milestones[0].Value != 79.7 {
+		t.Fatalf("expected first milestone 79.7, got %v", program.Milestones[0].Value)
+	}
+	last := program.Milestones[len(program.Milestones)-1]
+	if last.Date != program.TargetDate || last.Value != program.TargetValue {
+		t.Fatalf("expected the target as last milestone, got %+v", last)
+	}
+}
+
+func TestCreateProgramWithoutDataReturns409(t *testing.T) {
+	handler, _, profileID := setupCoachingHandler(t)
+
+	body := fmt.Sprintf(`{"profile_id":%q,"title":"Бег 10 км","metric":"distance_km","target_value":10,"target_date":%q}`,
+		profileID, utcToday().AddDate(0, 0, 56).Format("2006-01-02"))
+	w := serveCoaching(handler.HandleCreate, http.MethodPost, "/v1/coaching/programs", "", body, "userA")
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "baseline_unknown") {
+		t.Fatalf("expected 409 baseline_unknown, got %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestCreateProgramRejectsMilestonesOutsideProgram(t *testing.T) {
+	handler, _, profileID := setupCoachingHandler(t)
+	today := utcToday()
+
+	body := fmt.Sprintf(`{"profile_id":%q,"title":"Сон 8 часов","metric":"sleep_minutes","baseline_value":400,"target_value":480,
+		"target_date":%q,"milestones":[{"date":%q,"value":440}]}`,
+		profileID, today.AddDate(0, 0, 28).Format("2006-01-02"), today.AddDate(0, 0, 40).Format("2006-01-02"))
+	w := serveCoaching(handler.HandleCreate, http.MethodPost, "/v1/coaching/programs", "", body, "userA")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestWeeklyCheckinBehindProposesAdjustment(t *testing.T) {
+	handler, mem, profileID := setupCoachingHandler(t)
+	today := utcToday()
+	program := createProgram(t, handler.service, profileID, today.AddDate(0, 0, -14), 70)
+	// No progress in two weeks of a ten-week program.
+	seedWeight(t, mem, profileID, today.AddDate(0, 0, -7), 7, 80)
+
+	checked, err := handler.service.CheckinDue(context.Background())
+	if err != nil {
+		t.Fatalf("check-in failed: %v", err)
+	}
+	if checked != 1 {
+		t.Fatalf("expected 1 program checked in, got %d", checked)
+	}
+
+	pending, err := mem.List(context.Background(), "userA", profileID, []string{"pending"}, 10)
+	if err != nil {
+		t.Fatalf("list proposals failed: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Kind != ProposalKindAdjustment || pending[0].ExpiresAt == nil {
+		t.Fatalf("expected one expiring coaching_adjustment, got %+v", pending)
+	}
+	var payload AdjustmentPayload
+	if err := json.Unmarshal(pending[0].Payload, &payload); err != nil {
+		t.Fatalf("decode payload failed: %v", err)
+	}
+	if payload.ProgramID != program.ID {
+		t.Fatalf("expected adjustment of %s, got %s", program.ID, payload.ProgramID)
+	}
+	if want := today.AddDate(0, 0, -14+70+14).Format("2006-01-02"); payload.TargetDate != want {
+		t.Fatalf("expected target date moved two weeks to %s, got %s", want, payload.TargetDate)
+	}
+
+	w := serveCoaching(handler.HandleGet, http.MethodGet, "/v1/coaching/programs/", program.ID.String(), "", "userA")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var detail ProgramDetailResponse
+	if err := json.NewDecoder(w.Body).Decode(&detail); err != nil {
+		t.Fatalf("decode detail failed: %v", err)
+	}
+	if len(detail.Checkins) != 1 || detail.Checkins[0].Status != CheckinBehind {
+		t.Fatalf("expected one behind check-in, got %+v", detail.Checkins)
+	}
+	if detail.Checkins[0].ProposalID == nil || *detail.Checkins[0].ProposalID != pending[0].ID {
+		t.Fatalf("expected check-in to reference the proposal")
+	}
+	if detail.Progress.Status != CheckinBehind || detail.Progress.PercentComplete != 0 {
+		t.Fatalf("unexpected progress %+v", detail.Progress)
+	}
+
+	// The next check-in is a week away.
+	checked, err = handler.service.CheckinDue(context.Background())
+	if err != nil {
+		t.Fatalf("second check-in failed: %v", err)
+	}
+	if checked != 0 {
+		t.Fatalf("expected no program due, got %d", checked)
+	}
+}
+
+func TestCheckinReachingTargetCompletesProgram(t *testing.T) {
+	handler, mem, profileID := setupCoachingHandler(t)
+	today := utcToday()
+	program := createProgram(t, handler.service, profileID, today.AddDate(0, 0, -7), 28)
+	seedWeight(t, mem, profileID, today.AddDate(0, 0, -3), 3, 74.8)
+
+	if _, err := handler.service.CheckinDue(context.Background()); err != nil {
+		t.Fatalf("check-in failed: %v", err)
+	}
+
+	detail, err := handler.service.Get(context.Background(), "userA", program.ID)
+	if err != nil {
+		t.Fatalf("get program failed: %v", err)
+	}
+	if detail.Program.Status != StatusCompleted || detail.Program.NextCheckinAt != nil {
+		t.Fatalf("expected completed program, got %+v", detail.Program)
+	}
+	for _, milestone := range detail.Program.Milestones {
+		if milestone.ReachedAt == nil {
+			t.Fatalf("expected every milestone reached, got %+v", detail.Program.Milestones)
+		}
+	}
+	if detail.Progress.PercentComplete != 100 {
+		t.Fatalf("expected 100%% complete, got %v", detail.Progress.PercentComplete)
+	}
+
+	pending, err := mem.List(context.Background(), "userA", profileID, []string{"pending"}, 10)
+	if err != nil {
+		t.Fatalf("list proposals failed: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("expected no adjustment for a completed program, got %d", len(pending))
+	}
+}
+
+func TestProgramOfAnotherUserReturns404(t *testing.T) {
+	handler, _, profileID := setupCoachingHandler(t)
+	program := createProgram(t, handler.service, profileID, utcToday(), 28)
+
+	w := serveCoaching(handler.HandleGet, http.MethodGet, "/v1/coaching/programs/", program.ID.String(), "", "userB")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d body=%s", w.Code, w.Body.String())
+	}
+	w = serveCoaching(handler.HandleDelete, http.MethodDelete, "/v1/coaching/programs/", program.ID.String(), "", "userB")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 on delete, got %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestCancelStopsCheckins(t *testing.T) {
+	handler, mem, profileID := setupCoachingHandler(t)
+	today := utcToday()
+	program := createProgram(t, handler.service, profileID, today.AddDate(0, 0, -7), 28)
+	seedWeight(t, mem, profileID, today.AddDate(0, 0, -7), 7, 80)
+
+	w := serveCoaching(handler.HandleCancel, http.MethodPost, "/v1/coaching/programs/", program.ID.String(), "", "userA")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	w = serveCoaching(handler.HandleCancel, http.MethodPost, "/v1/coaching/programs/", program.ID.String(), "", "userA")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 on second cancel, got %d", w.Code)
+	}
+
+	checked, err := handler.service.CheckinDue(context.Background())
+	if err != nil {
+		t.Fatalf("check-in failed: %v", err)
+	}
+	if checked != 0 {
+		t.Fatalf("expected cancelled program to be skipped, got %d", checked)
+	}
+}
+
+func setupCoachingHandler(t *testing.T) (*Handler, *memory.MemoryStorage, uuid.UUID) {
+	t.Helper()
+
+	mem := memory.New()
+	profileID := uuid.New()
+	for _, profile := range []storage.Profile{
+		{ID: profileID, OwnerUserID: "userA", Type: "owner", Name: "User A"},
+		{ID: uuid.New(), OwnerUserID: "userB", Type: "owner", Name: "User B"},
+	} {
+		if err := mem.CreateProfile(context.Background(), &profile); err != nil {
+			t.Fatalf("create profile failed: %v", err)
+		}
+	}
+
+	service := NewService(mem.GetCoachingStorage(), mem, mem, mem.GetProposalsStorage())
+	return NewHandler(service), mem, profileID
+}
+
+// createProgram starts an 80 -> 75 kg program on start lasting days.
+func createProgram(t *testing.T, service *Service, profileID uuid.UUID, start time.Time, days int) *ProgramDTO {
+	t.Helper()
+
+	baseline := 80.0
+	program, err := service.Create(context.Background(), "userA", CreateProgramRequest{
+		ProfileID:     profileID,
+		Title:         "Минус 5 кг",
+		Metric:        MetricWeightKg,
+		BaselineValue: &baseline,
+		TargetValue:   75,
+		StartDate:     start.Format("2006-01-02"),
+		TargetDate:    start.AddDate(0, 0, days).Format("2006-01-02"),
+	})
+	if err != nil {
+		t.Fatalf("create program failed: %v", err)
+	}
+	return program
+}
+
+// seedWeight stores weightKg for days consecutive days starting at from.
+func seedWeight(t *testing.T, mem *memory.MemoryStorage, profileID uuid.UUID, from time.Time, days int, weightKg float64) {
+	t.Helper()
+
+	for i := 0; i < days; i++ {
+		date := from.AddDate(0, 0, i).Format("2006-01-02")
+		payload := fmt.Sprintf(`{"date":%q,"body":{"weight_kg_last":%v,"bmi":0}}`, date, weightKg)
+		if err := mem.UpsertDailyMetric(context.Background(), profileID, date, []byte(payload)); err != nil {
+			t.Fatalf("seed daily metric failed: %v", err)
+		}
+	}
+}
+
+func serveCoaching(handle http.HandlerFunc, method, path, programID, body, userID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path+programID, strings.NewReader(body))
+	if programID != "" {
+		req.SetPathValue("id", programID)
+	}
+	req = req.WithContext(userctx.WithUserID(context.Background(), userID))
+	w := httptest.NewRecorder()
+	handle(w, req)
+	return w
+}
+
+func utcToday() time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
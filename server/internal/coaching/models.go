@@ -0,0 +1,128 @@
+package coaching
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Metrics a program can track, read from daily_metrics.
+const (
+	MetricWeightKg     = "weight_kg"     // body.weight_kg_last, latest in the week
+	MetricDistanceKm   = "distance_km"   // activity.distance_km, best day of the week
+	MetricSleepMinutes = "sleep_minutes" // sleep.total_minutes, weekly average
+	MetricSteps        = "steps"         // activity.steps, weekly average
+)
+
+// Program statuses.
+const (
+	StatusActive    = "active"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+)
+
+// Check-in outcomes.
+const (
+	CheckinOnTrack  = "on_track"
+	CheckinAhead    = "ahead"
+	CheckinBehind   = "behind"
+	CheckinNoData   = "no_data"
+	CheckinAchieved = "achieved"
+)
+
+// MilestoneInput is an intermediate target: value by date (YYYY-MM-DD).
+type MilestoneInput struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
+
+// CreateProgramRequest starts a program. Without baseline_value the
+// baseline is read from the last two weeks of daily metrics; without
+// milestones they are spread evenly, at most one a week.
+type CreateProgramRequest struct {
+	ProfileID     uuid.UUID        `json:"profile_id"`
+	Title         string           `json:"title"`
+	Metric        string           `json:"metric"`
+	BaselineValue *float64         `json:"baseline_value,omitempty"`
+	TargetValue   float64          `json:"target_value"`
+	StartDate     string           `json:"start_date,omitempty"`
+	TargetDate    string           `json:"target_date"`
+	Milestones    []MilestoneInput `json:"milestones,omitempty"`
+
+	// SourceProposalID links a program created from an assistant proposal.
+	SourceProposalID *uuid.UUID `json:"-"`
+}
+
+type MilestoneDTO struct {
+	Date      string     `json:"date"`
+	Value     float64    `json:"value"`
+	ReachedAt *time.Time `json:"reached_at,omitempty"`
+}
+
+type ProgramDTO struct {
+	ID               uuid.UUID      `json:"id"`
+	ProfileID        uuid.UUID      `json:"profile_id"`
+	Title            string         `json:"title"`
+	Metric           string         `json:"metric"`
+	BaselineValue    float64        `json:"baseline_value"`
+	TargetValue      float64        `json:"target_value"`
+	StartDate        string         `json:"start_date"`
+	TargetDate       string         `json:"target_date"`
+	Status           string         `json:"status"`
+	Milestones       []MilestoneDTO `json:"milestones"`
+	NextCheckinAt    *time.Time     `json:"next_checkin_at,omitempty"`
+	SourceProposalID *uuid.UUID     `json:"source_proposal_id,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+// ProgressDTO compares the latest week of data with the schedule.
+// PercentComplete is the share of the way from baseline to target, 0-100.
+type ProgressDTO struct {
+	AsOf            string        `json:"as_of"`
+	CurrentValue    *float64      `json:"current_value"`
+	ExpectedValue   float64       `json:"expected_value"`
+	PercentComplete float64       `json:"percent_complete"`
+	Status          string        `json:"status"`
+	NextMilestone   *MilestoneDTO `json:"next_milestone,omitempty"`
+}
+
+type CheckinDTO struct {
+	ID            uuid.UUID  `json:"id"`
+	PeriodFrom    string     `json:"period_from"`
+	PeriodTo      string     `json:"period_to"`
+	Value         *float64   `json:"value"`
+	ExpectedValue float64    `json:"expected_value"`
+	Status        string     `json:"status"`
+	ProposalID    *uuid.UUID `json:"proposal_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type ListProgramsResponse struct {
+	Programs []ProgramDTO `json:"programs"`
+}
+
+type ProgramDetailResponse struct {
+	Program  ProgramDTO   `json:"program"`
+	Progress ProgressDTO  `json:"progress"`
+	Checkins []CheckinDTO `json:"checkins"`
+}
+
+// AdjustmentPayload is the payload of a coaching_adjustment proposal: the
+// program's schedule with the remaining milestones and target date moved.
+type AdjustmentPayload struct {
+	ProgramID  uuid.UUID        `json:"program_id"`
+	TargetDate string           `json:"target_date"`
+	Milestones []MilestoneInput `json:"milestones"`
+}
+
+// ErrorResponse — стандартный формат ошибки.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
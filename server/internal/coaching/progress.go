@@ -0,0 +1,161 @@
+package coaching
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/metrics"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+// behindTolerance is how far, as a share of the whole way from baseline to
+// target, a week may trail the schedule and still count as on track.
+const behindTolerance = 0.1
+
+// measure reads the metric over [from, to] from daily_metrics. nil means
+// no day in the range has a value.
+func (s *Service) measure(ctx context.Context, profileID uuid.UUID, metric string, from, to time.Time) (*float64, error) {
+	rows, err := s.metricsStorage.GetDailyMetrics(ctx, profileID, formatDate(from), formatDate(to))
+	if err != nil {
+		return nil, err
+	}
+	return aggregate(metric, rows), nil
+}
+
+// aggregate folds daily rows into one value: the latest weight, the best
+// distance, average sleep and steps. Days without the metric are skipped.
+func aggregate(metric string, rows []storage.DailyMetricRow) *float64 {
+	var (
+		sum, best  float64
+		count      int
+		latest     float64
+		latestDate string
+		hasLatest  bool
+	)
+	for _, row := range rows {
+		var day metrics.DailyAggregate
+		if err := json.Unmarshal(row.Payload, &day); err != nil {
+			continue
+		}
+
+		var value float64
+		switch metric {
+		case MetricWeightKg:
+			if day.Body == nil || day.Body.WeightKgLast <= 0 {
+				continue
+			}
+			value = day.Body.WeightKgLast
+		case MetricDistanceKm:
+			if day.Activity == nil || day.Activity.DistanceKm <= 0 {
+				continue
+			}
+			value = day.Activity.DistanceKm
+		case MetricSleepMinutes:
+			if day.Sleep == nil || day.Sleep.TotalMinutes <= 0 {
+				continue
+			}
+			value = float64(day.Sleep.TotalMinutes)
+		case MetricSteps:
+			if day.Activity == nil || day.Activity.Steps <= 0 {
+				continue
+			}
+			value = float64(day.Activity.Steps)
+		default:
+			return nil
+		}
+
+		sum += value
+		count++
+		best = math.Max(best, value)
+		if !hasLatest || row.Date >= latestDate {
+			latest, latestDate, hasLatest = value, row.Date, true
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+
+	var result float64
+	switch metric {
+	case MetricWeightKg:
+		result = latest
+	case MetricDistanceKm:
+		result = best
+	default:
+		result = sum / float64(count)
+	}
+	result = roundValue(metric, result)
+	return &result
+}
+
+// expectedAt is where the schedule puts the metric on date: a straight
+// line from the baseline through each milestone.
+func expectedAt(program storage.CoachingProgram, date time.Time) float64 {
+	prevDate, prevValue := program.StartDate, program.BaselineValue
+	if !date.After(prevDate) {
+		return prevValue
+	}
+	for _, milestone := range program.Milestones {
+		if !date.After(milestone.DueDate) {
+			span := milestone.DueDate.Sub(prevDate).Hours()
+			if span <= 0 {
+				return milestone.TargetValue
+			}
+			share := date.Sub(prevDate).Hours() / span
+			return prevValue + (milestone.TargetValue-prevValue)*share
+		}
+		prevDate, prevValue = milestone.DueDate, milestone.TargetValue
+	}
+	return program.TargetValue
+}
+
+// fraction is how much of the way from baseline to target value covers;
+// negative when it moved the wrong way.
+func fraction(program storage.CoachingProgram, value float64) float64 {
+	span := program.TargetValue - program.BaselineValue
+	if span == 0 {
+		return 1
+	}
+	return (value - program.BaselineValue) / span
+}
+
+// reached reports whether value is at or past goal in the program's direction.
+func reached(program storage.CoachingProgram, value, goal float64) bool {
+	if program.TargetValue >= program.BaselineValue {
+		return value >= goal
+	}
+	return value <= goal
+}
+
+// assess compares a week's value with the schedule on date.
+func assess(program storage.CoachingProgram, value *float64, date time.Time) string {
+	if value == nil {
+		return CheckinNoData
+	}
+	if reached(program, *value, program.TargetValue) {
+		return CheckinAchieved
+	}
+	gap := fraction(program, *value) - fraction(program, expectedAt(program, date))
+	switch {
+	case gap < -behindTolerance:
+		return CheckinBehind
+	case gap > behindTolerance:
+		return CheckinAhead
+	default:
+		return CheckinOnTrack
+	}
+}
+
+// lagDays is how many days behind the schedule value is on date: the
+// distance back to the day the schedule expected it.
+func lagDays(program storage.CoachingProgram, value float64, date time.Time) int {
+	for day := date; day.After(program.StartDate); day = day.AddDate(0, 0, -1) {
+		if reached(program, value, expectedAt(program, day)) {
+			return daysBetween(day, date)
+		}
+	}
+	return daysBetween(program.StartDate, date)
+}
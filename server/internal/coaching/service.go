@@ -0,0 +1,535 @@
+package coaching
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrInvalidRequest  = errors.New("invalid request")
+	ErrProgramNotFound = errors.New("program not found")
+	ErrNotActive       = errors.New("program is not active")
+	ErrNoBaseline      = errors.New("no baseline")
+)
+
+// Limits on program shape.
+const (
+	maxMilestones   = 24
+	maxProgramDays  = 730
+	minProgramDays  = 7
+	maxTitleLength  = 200
+	baselineWindow  = 14 // days of data a baseline is read from
+	checkinInterval = 7 * 24 * time.Hour
+)
+
+// DefaultProposalTTL is how long an adjustment proposal stays pending:
+// the next weekly check-in replaces it anyway.
+const DefaultProposalTTL = 7 * 24 * time.Hour
+
+// metricRanges bounds baseline, target and milestone values per metric.
+var metricRanges = map[string][2]float64{
+	MetricWeightKg:     {20, 400},
+	MetricDistanceKm:   {0, 300},
+	MetricSleepMinutes: {0, 1200},
+	MetricSteps:        {0, 100000},
+}
+
+// ValidMetric reports whether a program can track metric.
+func ValidMetric(metric string) bool {
+	_, ok := metricRanges[metric]
+	return ok
+}
+
+type Service struct {
+	storage          storage.CoachingStorage
+	profileStorage   storage.Storage
+	metricsStorage   storage.MetricsStorage
+	proposalsStorage storage.ProposalsStorage
+	proposalTTL      time.Duration
+	now              func() time.Time
+}
+
+func NewService(
+	coachingStorage storage.CoachingStorage,
+	profileStorage storage.Storage,
+	metricsStorage storage.MetricsStorage,
+	proposalsStorage storage.ProposalsStorage,
+) *Service {
+	return &Service{
+		storage:          coachingStorage,
+		profileStorage:   profileStorage,
+		metricsStorage:   metricsStorage,
+		proposalsStorage: proposalsStorage,
+		proposalTTL:      DefaultProposalTTL,
+		now:              time.Now,
+	}
+}
+
+// WithProposalTTL sets how long adjustment proposals stay pending.
+func (s *Service) WithProposalTTL(ttl time.Duration) *Service {
+	if ttl > 0 {
+		s.proposalTTL = ttl
+	}
+	return s
+}
+
+// Create starts a program for a profile of the owner.
+func (s *Service) Create(ctx context.Context, ownerUserID string, req CreateProgramRequest) (*ProgramDTO, error) {
+	ownerUserID = strings.TrimSpace(ownerUserID)
+	if ownerUserID == "" {
+		return nil, ErrUnauthorized
+	}
+	if req.ProfileID == uuid.Nil {
+		return nil, fmt.Errorf("%w: profile_id is required", ErrInvalidRequest)
+	}
+	if err := s.ensureProfileOwned(ctx, ownerUserID, req.ProfileID); err != nil {
+		return nil, err
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" || len(title) > maxTitleLength {
+		return nil, fmt.Errorf("%w: title must be 1-%d characters", ErrInvalidRequest, maxTitleLength)
+	}
+	if !ValidMetric(req.Metric) {
+		return nil, fmt.Errorf("%w: unsupported metric", ErrInvalidRequest)
+	}
+
+	today := s.today()
+	start := today
+	if req.StartDate != "" {
+		parsed, err := parseDate(req.StartDate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid start_date", ErrInvalidRequest)
+		}
+		start = parsed
+	}
+	target, err := parseDate(req.TargetDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid target_date", ErrInvalidRequest)
+	}
+	days := daysBetween(start, target)
+	if days < minProgramDays || days > maxProgramDays {
+		return nil, fmt.Errorf("%w: target_date must be %d-%d days after start_date", ErrInvalidRequest, minProgramDays, maxProgramDays)
+	}
+
+	var baseline float64
+	if req.BaselineValue != nil {
+		baseline = *req.BaselineValue
+	} else {
+		value, found, err := s.Baseline(ctx, ownerUserID, req.ProfileID, req.Metric)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, ErrNoBaseline
+		}
+		baseline = value
+	}
+	if !inRange(req.Metric, baseline) || !inRange(req.Metric, req.TargetValue) {
+		return nil, fmt.Errorf("%w: baseline_value and target_value are out of range for %s", ErrInvalidRequest, req.Metric)
+	}
+	if roundValue(req.Metric, baseline) == roundValue(req.Metric, req.TargetValue) {
+		return nil, fmt.Errorf("%w: target_value must differ from baseline_value", ErrInvalidRequest)
+	}
+
+	program := storage.CoachingProgram{
+		OwnerUserID:      ownerUserID,
+		ProfileID:        req.ProfileID,
+		Title:            title,
+		Metric:           req.Metric,
+		BaselineValue:    roundValue(req.Metric, baseline),
+		TargetValue:      roundValue(req.Metric, req.TargetValue),
+		StartDate:        start,
+		TargetDate:       target,
+		Status:           StatusActive,
+		NextCheckinAt:    start.Add(checkinInterval),
+		SourceProposalID: req.SourceProposalID,
+	}
+	if len(req.Milestones) == 0 {
+		program.Milestones = evenMilestones(program)
+	} else {
+		milestones, err := parseMilestones(program, req.Milestones)
+		if err != nil {
+			return nil, err
+		}
+		program.Milestones = milestones
+	}
+
+	created, err := s.storage.CreateCoachingProgram(ctx, program)
+	if err != nil {
+		return nil, err
+	}
+	dto := programToDTO(created)
+	return &dto, nil
+}
+
+// Baseline is the metric's value over the last two weeks of daily
+// metrics. false means there is no data to start from.
+func (s *Service) Baseline(ctx context.Context, ownerUserID string, profileID uuid.UUID, metric string) (float64, bool, error) {
+	if !ValidMetric(metric) {
+		return 0, false, fmt.Errorf("%w: unsupported metric", ErrInvalidRequest)
+	}
+	if err := s.ensureProfileOwned(ctx, ownerUserID, profileID); err != nil {
+		return 0, false, err
+	}
+	today := s.today()
+	value, err := s.measure(ctx, profileID, metric, today.AddDate(0, 0, -baselineWindow), today.AddDate(0, 0, -1))
+	if err != nil {
+		return 0, false, err
+	}
+	if value == nil {
+		return 0, false, nil
+	}
+	return *value, true, nil
+}
+
+// List returns the profile's programs, newest first. Empty status means any.
+func (s *Service) List(ctx context.Context, ownerUserID string, profileID uuid.UUID, status string) (*ListProgramsResponse, error) {
+	ownerUserID = strings.TrimSpace(ownerUserID)
+	if ownerUserID == "" {
+		return nil, ErrUnauthorized
+	}
+	switch status {
+	case "", StatusActive, StatusCompleted, StatusCancelled:
+	default:
+		return nil, fmt.Errorf("%w: invalid status", ErrInvalidRequest)
+	}
+	if err := s.ensureProfileOwned(ctx, ownerUserID, profileID); err != nil {
+		return nil, err
+	}
+
+	programs, err := s.storage.ListCoachingPrograms(ctx, ownerUserID, profileID, status)
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]ProgramDTO, 0, len(programs))
+	for _, program := range programs {
+		dtos = append(dtos, programToDTO(program))
+	}
+	return &ListProgramsResponse{Programs: dtos}, nil
+}
+
+// GetProgram returns a program without progress.
+func (s *Service) GetProgram(ctx context.Context, ownerUserID string, programID uuid.UUID) (*ProgramDTO, bool, error) {
+	program, err := s.getOwned(ctx, ownerUserID, programID)
+	if errors.Is(err, ErrProgramNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	dto := programToDTO(program)
+	return &dto, true, nil
+}
+
+// Get returns a program with its progress over the last week and its
+// check-in history.
+func (s *Service) Get(ctx context.Context, ownerUserID string, programID uuid.UUID) (*ProgramDetailResponse, error) {
+	program, err := s.getOwned(ctx, ownerUserID, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	asOf := s.today().AddDate(0, 0, -1)
+	value, err := s.measure(ctx, program.ProfileID, program.Metric, asOf.AddDate(0, 0, -6), asOf)
+	if err != nil {
+		return nil, err
+	}
+	progress := ProgressDTO{
+		AsOf:          formatDate(asOf),
+		CurrentValue:  value,
+		ExpectedValue: roundValue(program.Metric, expectedAt(program, asOf)),
+		Status:        assess(program, value, asOf),
+	}
+	if value != nil {
+		progress.PercentComplete = math.Round(clamp(fraction(program, *value), 0, 1)*1000) / 10
+	}
+	for _, milestone := range program.Milestones {
+		if milestone.ReachedAt == nil {
+			next := milestoneToDTO(milestone)
+			progress.NextMilestone = &next
+			break
+		}
+	}
+
+	checkins, err := s.storage.ListCoachingCheckins(ctx, program.OwnerUserID, program.ID)
+	if err != nil {
+		return nil, err
+	}
+	checkinDTOs := make([]CheckinDTO, 0, len(checkins))
+	for _, checkin := range checkins {
+		checkinDTOs = append(checkinDTOs, checkinToDTO(checkin))
+	}
+
+	return &ProgramDetailResponse{
+		Program:  programToDTO(program),
+		Progress: progress,
+		Checkins: checkinDTOs,
+	}, nil
+}
+
+// Cancel stops check-ins of an active program; its history is kept.
+func (s *Service) Cancel(ctx context.Context, ownerUserID string, programID uuid.UUID) (*ProgramDTO, error) {
+	program, err := s.getOwned(ctx, ownerUserID, programID)
+	if err != nil {
+		return nil, err
+	}
+	if program.Status != StatusActive {
+		return nil, ErrNotActive
+	}
+
+	program.Status = StatusCancelled
+	updated, found, err := s.storage.UpdateCoachingProgram(ctx, program)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrProgramNotFound
+	}
+	dto := programToDTO(updated)
+	return &dto, nil
+}
+
+// Delete removes a program with its milestones and check-ins.
+func (s *Service) Delete(ctx context.Context, ownerUserID string, programID uuid.UUID) error {
+	program, err := s.getOwned(ctx, ownerUserID, programID)
+	if err != nil {
+		return err
+	}
+	found, err := s.storage.DeleteCoachingProgram(ctx, program.OwnerUserID, program.ID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrProgramNotFound
+	}
+	return nil
+}
+
+// Reschedule replaces the target date and milestones of an active
+// program. Milestones already reached keep their reached time.
+func (s *Service) Reschedule(ctx context.Context, ownerUserID string, programID uuid.UUID, targetDate string, milestones []MilestoneInput) (*ProgramDTO, error) {
+	program, err := s.getOwned(ctx, ownerUserID, programID)
+	if err != nil {
+		return nil, err
+	}
+	if program.Status != StatusActive {
+		return nil, ErrNotActive
+	}
+
+	target, err := parseDate(targetDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid target_date", ErrInvalidRequest)
+	}
+	days := daysBetween(program.StartDate, target)
+	if days < minProgramDays || days > maxProgramDays {
+		return nil, fmt.Errorf("%w: target_date must be %d-%d days after start_date", ErrInvalidRequest, minProgramDays, maxProgramDays)
+	}
+
+	previous := program.Milestones
+	program.TargetDate = target
+	parsed, err := parseMilestones(program, milestones)
+	if err != nil {
+		return nil, err
+	}
+	for i := range parsed {
+		if i < len(previous) && previous[i].ReachedAt != nil && previous[i].TargetValue == parsed[i].TargetValue {
+			parsed[i].ReachedAt = previous[i].ReachedAt
+		}
+	}
+	program.Milestones = parsed
+
+	updated, found, err := s.storage.UpdateCoachingProgram(ctx, program)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrProgramNotFound
+	}
+	dto := programToDTO(updated)
+	return &dto, nil
+}
+
+func (s *Service) getOwned(ctx context.Context, ownerUserID string, programID uuid.UUID) (storage.CoachingProgram, error) {
+	ownerUserID = strings.TrimSpace(ownerUserID)
+	if ownerUserID == "" {
+		return storage.CoachingProgram{}, ErrUnauthorized
+	}
+	if programID == uuid.Nil {
+		return storage.CoachingProgram{}, ErrInvalidRequest
+	}
+
+	program, found, err := s.storage.GetCoachingProgram(ctx, ownerUserID, programID)
+	if err != nil {
+		return storage.CoachingProgram{}, err
+	}
+	if !found {
+		return storage.CoachingProgram{}, ErrProgramNotFound
+	}
+	return program, nil
+}
+
+func (s *Service) ensureProfileOwned(ctx context.Context, ownerUserID string, profileID uuid.UUID) error {
+	profile, err := s.profileStorage.GetProfile(ctx, profileID)
+	if err != nil || profile.OwnerUserID != ownerUserID {
+		return ErrProgramNotFound
+	}
+	return nil
+}
+
+func (s *Service) today() time.Time {
+	now := s.now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// parseMilestones validates milestones against the program: dates strictly
+// increasing within (start, target], values within the metric's range.
+// The target itself is always the last milestone.
+func parseMilestones(program storage.CoachingProgram, inputs []MilestoneInput) ([]storage.CoachingMilestone, error) {
+	if len(inputs) > maxMilestones {
+		return nil, fmt.Errorf("%w: at most %d milestones", ErrInvalidRequest, maxMilestones)
+	}
+
+	milestones := make([]storage.CoachingMilestone, 0, len(inputs)+1)
+	previous := program.StartDate
+	for i, input := range inputs {
+		date, err := parseDate(input.Date)
+		if err != nil {
+			return nil, fmt.Errorf("%w: milestones[%d]: invalid date", ErrInvalidRequest, i)
+		}
+		if !date.After(previous) || date.After(program.TargetDate) {
+			return nil, fmt.Errorf("%w: milestones[%d]: dates must increase and fall within the program", ErrInvalidRequest, i)
+		}
+		if !inRange(program.Metric, input.Value) {
+			return nil, fmt.Errorf("%w: milestones[%d]: value is out of range for %s", ErrInvalidRequest, i, program.Metric)
+		}
+		milestones = append(milestones, storage.CoachingMilestone{
+			DueDate:     date,
+			TargetValue: roundValue(program.Metric, input.Value),
+		})
+		previous = date
+	}
+
+	if len(milestones) == 0 || !milestones[len(milestones)-1].DueDate.Equal(program.TargetDate) {
+		milestones = append(milestones, storage.CoachingMilestone{
+			DueDate:     program.TargetDate,
+			TargetValue: program.TargetValue,
+		})
+	} else {
+		milestones[len(milestones)-1].TargetValue = program.TargetValue
+	}
+	return milestones, nil
+}
+
+// evenMilestones spreads the way to the target over weekly steps, at most
+// twelve of them.
+func evenMilestones(program storage.CoachingProgram) []storage.CoachingMilestone {
+	days := daysBetween(program.StartDate, program.TargetDate)
+	steps := days / 7
+	if steps < 1 {
+		steps = 1
+	}
+	if steps > 12 {
+		steps = 12
+	}
+
+	milestones := make([]storage.CoachingMilestone, 0, steps)
+	for k := 1; k <= steps; k++ {
+		offset := int(math.Round(float64(days) * float64(k) / float64(steps)))
+		value := program.BaselineValue + (program.TargetValue-program.BaselineValue)*float64(k)/float64(steps)
+		milestones = append(milestones, storage.CoachingMilestone{
+			DueDate:     program.StartDate.AddDate(0, 0, offset),
+			TargetValue: roundValue(program.Metric, value),
+		})
+	}
+	milestones[len(milestones)-1].TargetValue = program.TargetValue
+	return milestones
+}
+
+func inRange(metric string, value float64) bool {
+	limits := metricRanges[metric]
+	return !math.IsNaN(value) && value >= limits[0] && value <= limits[1]
+}
+
+// roundValue keeps one decimal for kilograms and kilometres and whole
+// numbers for minutes and steps.
+func roundValue(metric string, value float64) float64 {
+	switch metric {
+	case MetricWeightKg, MetricDistanceKm:
+		return math.Round(value*10) / 10
+	default:
+		return math.Round(value)
+	}
+}
+
+func clamp(value, min, max float64) float64 {
+	return math.Max(min, math.Min(max, value))
+}
+
+func parseDate(raw string) (time.Time, error) {
+	return time.Parse("2006-01-02", strings.TrimSpace(raw))
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+func programToDTO(program storage.CoachingProgram) ProgramDTO {
+	milestones := make([]MilestoneDTO, 0, len(program.Milestones))
+	for _, milestone := range program.Milestones {
+		milestones = append(milestones, milestoneToDTO(milestone))
+	}
+	dto := ProgramDTO{
+		ID:               program.ID,
+		ProfileID:        program.ProfileID,
+		Title:            program.Title,
+		Metric:           program.Metric,
+		BaselineValue:    program.BaselineValue,
+		TargetValue:      program.TargetValue,
+		StartDate:        formatDate(program.StartDate),
+		TargetDate:       formatDate(program.TargetDate),
+		Status:           program.Status,
+		Milestones:       milestones,
+		SourceProposalID: program.SourceProposalID,
+		CreatedAt:        program.CreatedAt,
+		UpdatedAt:        program.UpdatedAt,
+	}
+	if program.Status == StatusActive {
+		next := program.NextCheckinAt
+		dto.NextCheckinAt = &next
+	}
+	return dto
+}
+
+func milestoneToDTO(milestone storage.CoachingMilestone) MilestoneDTO {
+	return MilestoneDTO{
+		Date:      formatDate(milestone.DueDate),
+		Value:     milestone.TargetValue,
+		ReachedAt: milestone.ReachedAt,
+	}
+}
+
+func checkinToDTO(checkin storage.CoachingCheckin) CheckinDTO {
+	return CheckinDTO{
+		ID:            checkin.ID,
+		PeriodFrom:    formatDate(checkin.PeriodFrom),
+		PeriodTo:      formatDate(checkin.PeriodTo),
+		Value:         checkin.Value,
+		ExpectedValue: checkin.ExpectedValue,
+		Status:        checkin.Status,
+		ProposalID:    checkin.ProposalID,
+		CreatedAt:     checkin.CreatedAt,
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"log"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
@@ -117,6 +118,60 @@ func (c BlobConfig) EffectiveReportsMode() string {
 	return c.Mode
 }
 
+// AI provider modes accepted in AI_MODE and AI_FALLBACK.
+const (
+	AIModeMock             = "mock"
+	AIModeOpenAI           = "openai"
+	AIModeAnthropic        = "anthropic"
+	AIModeOllama           = "ollama"
+	AIModeOpenAICompatible = "openai_compatible"
+)
+
+// Data classes accepted in AI_REDACT. Each one is masked with placeholders
+// before a request leaves the server.
+const (
+	AIRedactEmails = "emails"
+	AIRedactPhones = "phones"
+	AIRedactNames  = "names"
+	AIRedactNotes  = "notes"
+)
+
+// Lab report extractors accepted in LAB_EXTRACTOR.
+const (
+	LabExtractorAI        = "ai"
+	LabExtractorTesseract = "tesseract"
+	LabExtractorStub      = "stub"
+)
+
+// Speech-to-text engines accepted in STT_PROVIDER.
+const (
+	SpeechProviderNone        = "none"
+	SpeechProviderWhisperHTTP = "whisper_http"
+	SpeechProviderWhisperCpp  = "whisper_cpp"
+	SpeechProviderStub        = "stub"
+)
+
+// SpeechConfig holds the settings of voice note transcription.
+type SpeechConfig struct {
+	Provider       string // none | whisper_http | whisper_cpp | stub
+	BaseURL        string
+	APIKey         string
+	Model          string
+	Language       string
+	TimeoutSeconds int
+	WhisperCppCmd  string
+	WhisperModel   string
+	FFmpegCmd      string
+}
+
+// AIProviderConfig holds the connection settings of one AI provider.
+type AIProviderConfig struct {
+	BaseURL        string
+	APIKey         string
+	Model          string
+	TimeoutSeconds int
+}
+
 // Config содержит конфигурацию приложения
 type Config struct {
 	Env      string // local | staging | prod
@@ -156,6 +211,19 @@ type Config struct {
 	UploadMaxMB          int
 	UploadAllowedMime    string
 	SourcesMaxPerCheckin int
+	ImageHEICConverter   string
+	LabExtractor         string // ai | tesseract | stub
+	LabOCRCommand        string
+
+	// Link previews (sources of kind link)
+	LinkPreviewEnabled     bool
+	LinkPreviewArchiveText bool
+	LinkPreviewTimeoutSec  int
+	LinkPreviewMaxMB       int
+
+	// Voice notes (sources of kind audio)
+	AudioMaxSeconds int
+	Speech          SpeechConfig
 
 	// Inbox / Notifications
 	NotificationsMaxPerDay     int
@@ -195,14 +263,60 @@ type Config struct {
 	ResendAPIKey        string
 	ResendFrom          string
 	OTPDebugReturnCode  bool
+	AdminUserIDs        []string
+
+	// Email OTP abuse protection
+	OTPAbuseWindowSeconds     int
+	OTPIPMaxRequestsPerWindow int
+	OTPChallengeAfterRequests int
+	OTPChallengeAfterFailures int
+	OTPChallengeDifficulty    int
+	OTPChallengeTTLSeconds    int
+	OTPLockoutAfterFailures   int
+	OTPLockoutBaseSeconds     int
+	OTPLockoutMaxSeconds      int
+	OTPBlockDisposableEmails  bool
+	OTPBlockedEmailDomains    []string
+	// OTPTrustedProxies lists the reverse proxies whose X-Forwarded-For hop
+	// is believed when the ledger keys requests by IP.
+	OTPTrustedProxies []netip.Prefix
 
 	// AI
-	AIMode            string // mock | openai
-	AIMaxOutputTokens int
-	AITemperature     float64
-	AITimeoutSeconds  int
-	OpenAIAPIKey      string
-	OpenAIModel       string
+	AIMode               string   // mock | openai | anthropic | ollama | openai_compatible
+	AIFallback           []string // modes tried in order when AIMode fails
+	AIMaxRetries         int      // retries per provider on 429/5xx/network errors
+	AIRetryBackoffMs     int      // first retry delay, doubled on each retry
+	AIMaxOutputTokens    int
+	AITemperature        float64
+	AITimeoutSeconds     int      // default for providers without their own timeout
+	AIRedact             []string // data classes masked before AI calls (emails, phones, names, notes)
+	AIConsentVersion     string   // data-processing policy owners must accept before AI calls
+	AIDailyTokenQuota    int      // tokens per owner per UTC day, 0 = unlimited
+	AIMonthlyTokenQuota  int      // tokens per owner per UTC calendar month, 0 = unlimited
+	ProposalUndoDays     int      // days an applied proposal can be undone
+	ProposalTTLDays      int      // days a proposal stays pending before it expires
+	OpenAIAPIKey         string
+	OpenAIModel          string
+	OpenAITimeoutSeconds int
+	Anthropic            AIProviderConfig
+	Ollama               AIProviderConfig
+	OpenAICompatible     AIProviderConfig
+
+	// Chat
+	ChatHistoryTokenBudget int // verbatim thread history per turn before summarizing
+
+	// Audit log
+	AuditRetentionDays int // 0 = keep forever
+
+	// Field-level encryption: "id:base64key" entries, first one is active
+	FieldEncryptionKeys []string
+
+	// Observability
+	MetricsAddr          string // separate listener for /metrics, e.g. ":9090"
+	MetricsToken         string // bearer token for /metrics on the main port
+	OTelExporterEndpoint string // OTLP/HTTP endpoint; empty = tracing disabled
+	OTelServiceName      string
+	OTelSampleRatio      float64 // 0..1
 
 	// Migrations
 	RunMigrationsOnStartup bool
@@ -307,15 +421,105 @@ func Load() *Config {
 	// UPLOAD_MAX_MB (default: 10)
 	uploadMaxMB := envInt("UPLOAD_MAX_MB", 10)
 
-	// UPLOAD_ALLOWED_MIME (default: image/jpeg,image/png,image/heic)
+	// UPLOAD_ALLOWED_MIME (default: JPEG, PNG and HEIC photos, m4a, ogg and wav voice notes)
 	uploadAllowedMime := os.Getenv("UPLOAD_ALLOWED_MIME")
 	if uploadAllowedMime == "" {
-		uploadAllowedMime = "image/jpeg,image/png,image/heic"
+		uploadAllowedMime = "image/jpeg,image/png,image/heic,audio/mp4,audio/x-m4a,audio/m4a,audio/ogg,audio/opus,audio/wav,audio/x-wav"
 	}
 
 	// SOURCES_MAX_PER_CHECKIN (default: 4)
 	sourcesMaxPerCheckin := envInt("SOURCES_MAX_PER_CHECKIN", 4)
 
+	// IMAGE_HEIC_CONVERTER (default: empty — HEIC is kept, metadata is blanked)
+	imageHEICConverter := strings.TrimSpace(os.Getenv("IMAGE_HEIC_CONVERTER"))
+
+	// LAB_EXTRACTOR (default: ai — lab reports are read by AI_MODE's provider)
+	labExtractor := strings.ToLower(strings.TrimSpace(os.Getenv("LAB_EXTRACTOR")))
+	switch labExtractor {
+	case "":
+		labExtractor = LabExtractorAI
+	case LabExtractorAI, LabExtractorTesseract, LabExtractorStub:
+	default:
+		log.Printf("WARNING: unknown LAB_EXTRACTOR=%q, fallback to ai", labExtractor)
+		labExtractor = LabExtractorAI
+	}
+
+	// LAB_OCR_COMMAND (default: tesseract)
+	labOCRCommand := strings.TrimSpace(os.Getenv("LAB_OCR_COMMAND"))
+	if labOCRCommand == "" {
+		labOCRCommand = "tesseract"
+	}
+
+	// LINK_PREVIEW_ENABLED (default: true — link sources fetch their page in the background)
+	linkPreviewEnabled := true
+	if strings.TrimSpace(os.Getenv("LINK_PREVIEW_ENABLED")) != "" {
+		linkPreviewEnabled = parseBoolEnv("LINK_PREVIEW_ENABLED")
+	}
+
+	// LINK_PREVIEW_ARCHIVE_TEXT (default: false — keep only the preview)
+	linkPreviewArchiveText := parseBoolEnv("LINK_PREVIEW_ARCHIVE_TEXT")
+
+	// LINK_PREVIEW_TIMEOUT_SECONDS (default: 10)
+	linkPreviewTimeoutSec := envInt("LINK_PREVIEW_TIMEOUT_SECONDS", 10)
+	if linkPreviewTimeoutSec <= 0 {
+		linkPreviewTimeoutSec = 10
+	}
+
+	// LINK_PREVIEW_MAX_MB (default: 5) — limit for a page and for its image
+	linkPreviewMaxMB := envInt("LINK_PREVIEW_MAX_MB", 5)
+	if linkPreviewMaxMB <= 0 {
+		linkPreviewMaxMB = 5
+	}
+
+	// AUDIO_MAX_SECONDS (default: 600) — longest voice note accepted
+	audioMaxSeconds := envInt("AUDIO_MAX_SECONDS", 600)
+	if audioMaxSeconds <= 0 {
+		audioMaxSeconds = 600
+	}
+
+	// STT_PROVIDER (default: none — voice notes are stored without transcript)
+	speechCfg := SpeechConfig{
+		Provider:       strings.ToLower(strings.TrimSpace(os.Getenv("STT_PROVIDER"))),
+		BaseURL:        strings.TrimRight(strings.TrimSpace(os.Getenv("STT_BASE_URL")), "/"),
+		APIKey:         strings.TrimSpace(os.Getenv("STT_API_KEY")),
+		Model:          strings.TrimSpace(os.Getenv("STT_MODEL")),
+		Language:       strings.ToLower(strings.TrimSpace(os.Getenv("STT_LANGUAGE"))),
+		TimeoutSeconds: envInt("STT_TIMEOUT_SECONDS", 120),
+		WhisperCppCmd:  strings.TrimSpace(os.Getenv("STT_WHISPER_CPP_COMMAND")),
+		WhisperModel:   strings.TrimSpace(os.Getenv("STT_WHISPER_CPP_MODEL")),
+		FFmpegCmd:      strings.TrimSpace(os.Getenv("STT_FFMPEG_COMMAND")),
+	}
+	switch speechCfg.Provider {
+	case "":
+		speechCfg.Provider = SpeechProviderNone
+	case SpeechProviderNone, SpeechProviderWhisperHTTP, SpeechProviderWhisperCpp, SpeechProviderStub:
+	default:
+		log.Printf("WARNING: unknown STT_PROVIDER=%q, fallback to none", speechCfg.Provider)
+		speechCfg.Provider = SpeechProviderNone
+	}
+	if speechCfg.BaseURL == "" {
+		speechCfg.BaseURL = "https://api.openai.com/v1"
+	}
+	if speechCfg.APIKey == "" {
+		speechCfg.APIKey = strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
+	}
+	if speechCfg.Model == "" {
+		speechCfg.Model = "whisper-1"
+	}
+	if speechCfg.TimeoutSeconds <= 0 {
+		speechCfg.TimeoutSeconds = 120
+	}
+	if speechCfg.WhisperCppCmd == "" {
+		speechCfg.WhisperCppCmd = "whisper-cli"
+	}
+	if speechCfg.FFmpegCmd == "" {
+		speechCfg.FFmpegCmd = "ffmpeg"
+	}
+	if speechCfg.Provider == SpeechProviderWhisperCpp && speechCfg.WhisperModel == "" {
+		log.Printf("WARNING: STT_PROVIDER=whisper_cpp without STT_WHISPER_CPP_MODEL, transcription disabled")
+		speechCfg.Provider = SpeechProviderNone
+	}
+
 	// NOTIFICATIONS_MAX_PER_DAY (default: 4)
 	notificationsMaxPerDay := envInt("NOTIFICATIONS_MAX_PER_DAY", 4)
 
@@ -433,6 +637,53 @@ func Load() *Config {
 	}
 	smtpUseTLS := parseBoolEnv("SMTP_USE_TLS")
 	otpDebugReturnCode := parseBoolEnv("OTP_DEBUG_RETURN_CODE")
+	adminUserIDs := envList("ADMIN_USER_IDS")
+
+	// OTP abuse protection
+	otpAbuseWindowSeconds := envInt("OTP_ABUSE_WINDOW_SECONDS", 3600)
+	if otpAbuseWindowSeconds <= 0 {
+		otpAbuseWindowSeconds = 3600
+	}
+	otpIPMaxRequests := envInt("OTP_IP_MAX_REQUESTS", 30)
+	if otpIPMaxRequests <= 0 {
+		otpIPMaxRequests = 30
+	}
+	otpChallengeAfterRequests := envInt("OTP_CHALLENGE_AFTER_REQUESTS", 5)
+	if otpChallengeAfterRequests <= 0 {
+		otpChallengeAfterRequests = 5
+	}
+	otpChallengeAfterFailures := envInt("OTP_CHALLENGE_AFTER_FAILURES", 3)
+	if otpChallengeAfterFailures <= 0 {
+		otpChallengeAfterFailures = 3
+	}
+	otpChallengeDifficulty := envInt("OTP_CHALLENGE_DIFFICULTY", 18)
+	if otpChallengeDifficulty <= 0 || otpChallengeDifficulty > 32 {
+		log.Printf("WARNING: OTP_CHALLENGE_DIFFICULTY=%d out of range 1..32, fallback to 18", otpChallengeDifficulty)
+		otpChallengeDifficulty = 18
+	}
+	otpChallengeTTLSeconds := envInt("OTP_CHALLENGE_TTL_SECONDS", 300)
+	if otpChallengeTTLSeconds <= 0 {
+		otpChallengeTTLSeconds = 300
+	}
+	otpLockoutAfterFailures := envInt("OTP_LOCKOUT_AFTER_FAILURES", 10)
+	if otpLockoutAfterFailures <= 0 {
+		otpLockoutAfterFailures = 10
+	}
+	otpLockoutBaseSeconds := envInt("OTP_LOCKOUT_BASE_SECONDS", 60)
+	if otpLockoutBaseSeconds <= 0 {
+		otpLockoutBaseSeconds = 60
+	}
+	otpLockoutMaxSeconds := envInt("OTP_LOCKOUT_MAX_SECONDS", 86400)
+	if otpLockoutMaxSeconds < otpLockoutBaseSeconds {
+		otpLockoutMaxSeconds = otpLockoutBaseSeconds
+	}
+	// Disposable-domain blocklist is on by default.
+	otpBlockDisposable := true
+	if strings.TrimSpace(os.Getenv("OTP_BLOCK_DISPOSABLE_EMAILS")) != "" {
+		otpBlockDisposable = parseBoolEnv("OTP_BLOCK_DISPOSABLE_EMAILS")
+	}
+	otpBlockedEmailDomains := envList("OTP_BLOCKED_EMAIL_DOMAINS")
+	otpTrustedProxies := parseTrustedProxies("OTP_TRUSTED_PROXIES")
 
 	// Apple Sign-In config
 	appleBundleID := strings.TrimSpace(os.Getenv("APPLE_BUNDLE_ID"))
@@ -463,11 +714,76 @@ func Load() *Config {
 	// ---------- AI ----------
 	aiMode := strings.ToLower(strings.TrimSpace(os.Getenv("AI_MODE")))
 	if aiMode == "" {
-		aiMode = "mock"
+		aiMode = AIModeMock
 	}
-	if aiMode != "mock" && aiMode != "openai" {
+	if !isAIMode(aiMode) {
 		log.Printf("WARNING: unknown AI_MODE=%q, fallback to mock", aiMode)
-		aiMode = "mock"
+		aiMode = AIModeMock
+	}
+
+	var aiFallback []string
+	for _, mode := range envList("AI_FALLBACK") {
+		mode = strings.ToLower(mode)
+		if !isAIMode(mode) {
+			log.Printf("WARNING: unknown AI_FALLBACK entry %q, skipped", mode)
+			continue
+		}
+		if mode != aiMode {
+			aiFallback = append(aiFallback, mode)
+		}
+	}
+
+	aiMaxRetries := envInt("AI_MAX_RETRIES", 2)
+	if aiMaxRetries < 0 {
+		aiMaxRetries = 0
+	}
+	if aiMaxRetries > 5 {
+		aiMaxRetries = 5
+	}
+	aiRetryBackoffMs := envInt("AI_RETRY_BACKOFF_MS", 500)
+	if aiRetryBackoffMs < 0 {
+		aiRetryBackoffMs = 0
+	}
+
+	// Everything is masked unless AI_REDACT narrows it; "none" turns it off.
+	aiRedact := []string{AIRedactEmails, AIRedactPhones, AIRedactNames, AIRedactNotes}
+	if raw := envList("AI_REDACT"); len(raw) > 0 {
+		aiRedact = nil
+		for _, class := range raw {
+			class = strings.ToLower(class)
+			switch class {
+			case "none":
+			case AIRedactEmails, AIRedactPhones, AIRedactNames, AIRedactNotes:
+				aiRedact = append(aiRedact, class)
+			default:
+				log.Printf("WARNING: unknown AI_REDACT entry %q, skipped", class)
+			}
+		}
+	}
+
+	aiConsentVersion := envOr("AI_CONSENT_VERSION", "2026-10")
+
+	aiDailyTokenQuota := envInt("AI_DAILY_TOKEN_QUOTA", 100000)
+	if aiDailyTokenQuota < 0 {
+		aiDailyTokenQuota = 0
+	}
+	aiMonthlyTokenQuota := envInt("AI_MONTHLY_TOKEN_QUOTA", 1000000)
+	if aiMonthlyTokenQuota < 0 {
+		aiMonthlyTokenQuota = 0
+	}
+
+	proposalUndoDays := envInt("PROPOSAL_UNDO_DAYS", 7)
+	if proposalUndoDays < 1 {
+		proposalUndoDays = 1
+	}
+	proposalTTLDays := envInt("PROPOSAL_TTL_DAYS", 14)
+	if proposalTTLDays < 1 {
+		proposalTTLDays = 1
+	}
+
+	chatHistoryTokenBudget := envInt("CHAT_HISTORY_TOKEN_BUDGET", 3000)
+	if chatHistoryTokenBudget < 500 {
+		chatHistoryTokenBudget = 500
 	}
 
 	aiMaxOutputTokens := envInt("AI_MAX_OUTPUT_TOKENS", 600)
@@ -493,9 +809,65 @@ func Load() *Config {
 	if openAIModel == "" {
 		openAIModel = "gpt-4.1-mini"
 	}
+	openAITimeout := envTimeout("OPENAI_TIMEOUT_SECONDS", aiTimeoutSeconds)
+
+	anthropic := AIProviderConfig{
+		BaseURL:        envOr("ANTHROPIC_BASE_URL", "https://api.anthropic.com"),
+		APIKey:         strings.TrimSpace(os.Getenv("ANTHROPIC_API_KEY")),
+		Model:          envOr("ANTHROPIC_MODEL", "claude-sonnet-4-5"),
+		TimeoutSeconds: envTimeout("ANTHROPIC_TIMEOUT_SECONDS", aiTimeoutSeconds),
+	}
+	// Local models are slower; their default timeout is three times longer.
+	ollama := AIProviderConfig{
+		BaseURL:        envOr("OLLAMA_BASE_URL", "http://localhost:11434"),
+		Model:          envOr("OLLAMA_MODEL", "llama3.1"),
+		TimeoutSeconds: envTimeout("OLLAMA_TIMEOUT_SECONDS", 3*aiTimeoutSeconds),
+	}
+	openAICompatible := AIProviderConfig{
+		BaseURL:        strings.TrimRight(strings.TrimSpace(os.Getenv("OPENAI_COMPATIBLE_BASE_URL")), "/"),
+		APIKey:         strings.TrimSpace(os.Getenv("OPENAI_COMPATIBLE_API_KEY")),
+		Model:          strings.TrimSpace(os.Getenv("OPENAI_COMPATIBLE_MODEL")),
+		TimeoutSeconds: envTimeout("OPENAI_COMPATIBLE_TIMEOUT_SECONDS", aiTimeoutSeconds),
+	}
 
-	if aiMode == "openai" && openAIAPIKey == "" {
-		log.Fatal("OPENAI_API_KEY is required when AI_MODE=openai")
+	for _, mode := range append([]string{aiMode}, aiFallback...) {
+		switch mode {
+		case AIModeOpenAI:
+			if openAIAPIKey == "" {
+				log.Fatalf("OPENAI_API_KEY is required when AI_MODE or AI_FALLBACK uses openai")
+			}
+		case AIModeAnthropic:
+			if anthropic.APIKey == "" {
+				log.Fatalf("ANTHROPIC_API_KEY is required when AI_MODE or AI_FALLBACK uses anthropic")
+			}
+		case AIModeOpenAICompatible:
+			if openAICompatible.BaseURL == "" || openAICompatible.Model == "" {
+				log.Fatalf("OPENAI_COMPATIBLE_BASE_URL and OPENAI_COMPATIBLE_MODEL are required when AI_MODE or AI_FALLBACK uses openai_compatible")
+			}
+		}
+	}
+
+	// ---------- Audit ----------
+	auditRetentionDays := envInt("AUDIT_RETENTION_DAYS", 365)
+	if auditRetentionDays < 0 {
+		log.Printf("WARNING: AUDIT_RETENTION_DAYS=%d is negative, fallback to 365", auditRetentionDays)
+		auditRetentionDays = 365
+	}
+
+	// ---------- Field encryption ----------
+	fieldEncryptionKeys := envList("FIELD_ENCRYPTION_KEYS")
+
+	metricsAddr := strings.TrimSpace(os.Getenv("METRICS_ADDR"))
+	metricsToken := strings.TrimSpace(os.Getenv("METRICS_TOKEN"))
+	otelEndpoint := strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+	otelServiceName := strings.TrimSpace(os.Getenv("OTEL_SERVICE_NAME"))
+	if otelServiceName == "" {
+		otelServiceName = "health-hub-api"
+	}
+	otelSampleRatio := envFloat("OTEL_TRACES_SAMPLER_ARG", 1.0)
+	if otelSampleRatio < 0 || otelSampleRatio > 1 {
+		log.Printf("WARNING: OTEL_TRACES_SAMPLER_ARG=%v is outside 0..1, fallback to 1", otelSampleRatio)
+		otelSampleRatio = 1
 	}
 
 	return &Config{
@@ -528,6 +900,17 @@ func Load() *Config {
 		UploadMaxMB:          uploadMaxMB,
 		UploadAllowedMime:    uploadAllowedMime,
 		SourcesMaxPerCheckin: sourcesMaxPerCheckin,
+		ImageHEICConverter:   imageHEICConverter,
+		LabExtractor:         labExtractor,
+		LabOCRCommand:        labOCRCommand,
+
+		LinkPreviewEnabled:     linkPreviewEnabled,
+		LinkPreviewArchiveText: linkPreviewArchiveText,
+		LinkPreviewTimeoutSec:  linkPreviewTimeoutSec,
+		LinkPreviewMaxMB:       linkPreviewMaxMB,
+
+		AudioMaxSeconds: audioMaxSeconds,
+		Speech:          speechCfg,
 
 		NotificationsMaxPerDay:     notificationsMaxPerDay,
 		DefaultSleepMinMinutes:     defaultSleepMinMinutes,
@@ -564,12 +947,52 @@ func Load() *Config {
 		ResendAPIKey:        resendAPIKey,
 		ResendFrom:          resendFrom,
 		OTPDebugReturnCode:  otpDebugReturnCode,
-		AIMode:              aiMode,
-		AIMaxOutputTokens:   aiMaxOutputTokens,
-		AITemperature:       aiTemperature,
-		AITimeoutSeconds:    aiTimeoutSeconds,
-		OpenAIAPIKey:        openAIAPIKey,
-		OpenAIModel:         openAIModel,
+		AdminUserIDs:        adminUserIDs,
+
+		OTPAbuseWindowSeconds:     otpAbuseWindowSeconds,
+		OTPIPMaxRequestsPerWindow: otpIPMaxRequests,
+		OTPChallengeAfterRequests: otpChallengeAfterRequests,
+		OTPChallengeAfterFailures: otpChallengeAfterFailures,
+		OTPChallengeDifficulty:    otpChallengeDifficulty,
+		OTPChallengeTTLSeconds:    otpChallengeTTLSeconds,
+		OTPLockoutAfterFailures:   otpLockoutAfterFailures,
+		OTPLockoutBaseSeconds:     otpLockoutBaseSeconds,
+		OTPLockoutMaxSeconds:      otpLockoutMaxSeconds,
+		OTPBlockDisposableEmails:  otpBlockDisposable,
+		OTPBlockedEmailDomains:    otpBlockedEmailDomains,
+		OTPTrustedProxies:         otpTrustedProxies,
+
+		AIMode:               aiMode,
+		AIFallback:           aiFallback,
+		AIMaxRetries:         aiMaxRetries,
+		AIRetryBackoffMs:     aiRetryBackoffMs,
+		AIMaxOutputTokens:    aiMaxOutputTokens,
+		AITemperature:        aiTemperature,
+		AITimeoutSeconds:     aiTimeoutSeconds,
+		AIRedact:             aiRedact,
+		AIConsentVersion:     aiConsentVersion,
+		AIDailyTokenQuota:    aiDailyTokenQuota,
+		AIMonthlyTokenQuota:  aiMonthlyTokenQuota,
+		ProposalUndoDays:     proposalUndoDays,
+		ProposalTTLDays:      proposalTTLDays,
+		OpenAIAPIKey:         openAIAPIKey,
+		OpenAIModel:          openAIModel,
+		OpenAITimeoutSeconds: openAITimeout,
+		Anthropic:            anthropic,
+		Ollama:               ollama,
+		OpenAICompatible:     openAICompatible,
+
+		ChatHistoryTokenBudget: chatHistoryTokenBudget,
+
+		AuditRetentionDays: auditRetentionDays,
+
+		FieldEncryptionKeys: fieldEncryptionKeys,
+
+		MetricsAddr:          metricsAddr,
+		MetricsToken:         metricsToken,
+		OTelExporterEndpoint: otelEndpoint,
+		OTelServiceName:      otelServiceName,
+		OTelSampleRatio:      otelSampleRatio,
 
 		RunMigrationsOnStartup: runMigrationsOnStartup,
 	}
@@ -636,6 +1059,68 @@ func envFloat(key string, defaultVal float64) float64 {
 	return v
 }
 
+// envList reads a comma-separated env var, dropping empty entries.
+func envList(key string) []string {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	values := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			values = append(values, p)
+		}
+	}
+	return values
+}
+
+// parseTrustedProxies reads a comma-separated list of CIDRs or single IPs,
+// skipping invalid entries with a warning.
+func parseTrustedProxies(key string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, value := range envList(key) {
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			log.Printf("WARNING: %s entry %q is not an IP or CIDR, ignored", key, value)
+			continue
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes
+}
+
+// envOr reads a string env var, falling back to defaultVal when empty.
+func envOr(key, defaultVal string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return defaultVal
+}
+
+// envTimeout reads a positive number of seconds with a default.
+func envTimeout(key string, defaultVal int) int {
+	if v := envInt(key, defaultVal); v > 0 {
+		return v
+	}
+	return defaultVal
+}
+
+func isAIMode(mode string) bool {
+	switch mode {
+	case AIModeMock, AIModeOpenAI, AIModeAnthropic, AIModeOllama, AIModeOpenAICompatible:
+		return true
+	default:
+		return false
+	}
+}
+
 func parseBoolEnv(key string) bool {
 	v := strings.ToLower(strings.TrimSpace(os.Getenv(key)))
 	return v == "1" || v == "true" || v == "yes" || v == "on"
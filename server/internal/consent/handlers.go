@@ -0,0 +1,79 @@
+package consent
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fdg312/health-hub/internal/logging"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.service.Get(r.Context())
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) HandleGrant(w http.ResponseWriter, r *http.Request) {
+	var req GrantConsentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.Grant(r.Context(), req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Revoke(r.Context()); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+	case errors.Is(err, ErrInvalidRequest):
+		writeError(w, http.StatusBadRequest, "invalid_request", "policy_version is required")
+	case errors.Is(err, ErrPolicyVersionMismatch):
+		writeError(w, http.StatusConflict, "policy_version_mismatch", "Policy version is outdated, show the current policy")
+	default:
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{
+		Error: ErrorDetail{
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
+		},
+	})
+}
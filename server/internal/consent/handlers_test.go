@@ -0,0 +1,81 @@
+package consent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fdg312/health-hub/internal/storage/memory"
+	"github.com/fdg312/health-hub/internal/userctx"
+)
+
+func doRequest(t *testing.T, handle http.HandlerFunc, method, userID, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, "/v1/ai/consent", bytes.NewBufferString(body))
+	req = req.WithContext(userctx.WithUserID(context.Background(), userID))
+	w := httptest.NewRecorder()
+	handle(w, req)
+	return w
+}
+
+func decodeConsent(t *testing.T, w *httptest.ResponseRecorder) ConsentDTO {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var dto ConsentDTO
+	if err := json.NewDecoder(w.Body).Decode(&dto); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	return dto
+}
+
+func TestConsentGrantRevokeAndPolicyBump(t *testing.T) {
+	mem := memory.New()
+	service := NewService(mem.GetAIConsentStorage(), "v1", []string{"emails", "names"})
+	handler := NewHandler(service)
+	ctx := context.Background()
+
+	dto := decodeConsent(t, doRequest(t, handler.HandleGet, http.MethodGet, "userA", ""))
+	if dto.Granted || dto.CurrentPolicyVersion != "v1" || len(dto.Redacted) != 2 {
+		t.Fatalf("unexpected initial consent %+v", dto)
+	}
+
+	if w := doRequest(t, handler.HandleGrant, http.MethodPost, "userA", `{"policy_version":"v0"}`); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for an outdated policy, got %d", w.Code)
+	}
+	if w := doRequest(t, handler.HandleGrant, http.MethodPost, "userA", `{}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without policy_version, got %d", w.Code)
+	}
+
+	dto = decodeConsent(t, doRequest(t, handler.HandleGrant, http.MethodPost, "userA", `{"policy_version":"v1"}`))
+	if !dto.Granted || dto.GrantedAt == nil {
+		t.Fatalf("expected granted consent, got %+v", dto)
+	}
+	if ok, _ := service.HasAIConsent(ctx, "userA"); !ok {
+		t.Fatalf("expected userA to have consent")
+	}
+	if ok, _ := service.HasAIConsent(ctx, "userB"); ok {
+		t.Fatalf("consent must be per owner")
+	}
+
+	// A new policy version invalidates the old consent.
+	bumped := NewService(mem.GetAIConsentStorage(), "v2", nil)
+	if ok, _ := bumped.HasAIConsent(ctx, "userA"); ok {
+		t.Fatalf("consent to v1 must not cover v2")
+	}
+
+	if w := doRequest(t, handler.HandleRevoke, http.MethodDelete, "userA", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	dto = decodeConsent(t, doRequest(t, handler.HandleGet, http.MethodGet, "userA", ""))
+	if dto.Granted || dto.RevokedAt == nil {
+		t.Fatalf("expected revoked consent, got %+v", dto)
+	}
+	if w := doRequest(t, handler.HandleRevoke, http.MethodDelete, "userA", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected repeated revoke to be a no-op, got %d", w.Code)
+	}
+}
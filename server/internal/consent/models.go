@@ -0,0 +1,50 @@
+package consent
+
+import (
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+)
+
+// ConsentDTO describes the owner's consent to AI processing. Granted is
+// true only for an active consent to the current policy version.
+type ConsentDTO struct {
+	Granted              bool       `json:"granted"`
+	CurrentPolicyVersion string     `json:"current_policy_version"`
+	PolicyVersion        *string    `json:"policy_version,omitempty"`
+	GrantedAt            *time.Time `json:"granted_at,omitempty"`
+	RevokedAt            *time.Time `json:"revoked_at,omitempty"`
+	// Redacted lists the data classes masked before requests leave the server.
+	Redacted []string `json:"redacted"`
+}
+
+type GrantConsentRequest struct {
+	PolicyVersion string `json:"policy_version"`
+}
+
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func (s *Service) toDTO(row *storage.AIConsent) ConsentDTO {
+	dto := ConsentDTO{
+		CurrentPolicyVersion: s.policyVersion,
+		Redacted:             s.redacted,
+	}
+	if row == nil {
+		return dto
+	}
+	version := row.PolicyVersion
+	grantedAt := row.GrantedAt
+	dto.PolicyVersion = &version
+	dto.GrantedAt = &grantedAt
+	dto.RevokedAt = row.RevokedAt
+	dto.Granted = s.active(*row)
+	return dto
+}
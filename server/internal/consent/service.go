@@ -0,0 +1,109 @@
+package consent
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/userctx"
+)
+
+var (
+	ErrUnauthorized          = errors.New("unauthorized")
+	ErrInvalidRequest        = errors.New("invalid request")
+	ErrPolicyVersionMismatch = errors.New("policy version mismatch")
+)
+
+// Service tracks owners' consent to sending their data to AI providers.
+// No AI call is made for an owner without an active consent to the
+// current policy version; bumping AI_CONSENT_VERSION asks everyone again.
+type Service struct {
+	storage       storage.AIConsentStorage
+	policyVersion string
+	redacted      []string
+}
+
+func NewService(consentStorage storage.AIConsentStorage, policyVersion string, redacted []string) *Service {
+	if redacted == nil {
+		redacted = []string{}
+	}
+	return &Service{
+		storage:       consentStorage,
+		policyVersion: policyVersion,
+		redacted:      redacted,
+	}
+}
+
+func (s *Service) Get(ctx context.Context) (*ConsentDTO, error) {
+	userID := userIDFromContext(ctx)
+	if userID == "" {
+		return nil, ErrUnauthorized
+	}
+
+	row, found, err := s.storage.GetAIConsent(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var dto ConsentDTO
+	if found {
+		dto = s.toDTO(&row)
+	} else {
+		dto = s.toDTO(nil)
+	}
+	return &dto, nil
+}
+
+// Grant records consent. The client echoes the policy version it showed
+// to the user, so a consent never covers a policy the user has not seen.
+func (s *Service) Grant(ctx context.Context, req GrantConsentRequest) (*ConsentDTO, error) {
+	userID := userIDFromContext(ctx)
+	if userID == "" {
+		return nil, ErrUnauthorized
+	}
+	version := strings.TrimSpace(req.PolicyVersion)
+	if version == "" {
+		return nil, ErrInvalidRequest
+	}
+	if version != s.policyVersion {
+		return nil, ErrPolicyVersionMismatch
+	}
+
+	row, err := s.storage.GrantAIConsent(ctx, userID, version)
+	if err != nil {
+		return nil, err
+	}
+	dto := s.toDTO(&row)
+	return &dto, nil
+}
+
+// Revoke withdraws consent. Revoking without an active consent is a no-op.
+func (s *Service) Revoke(ctx context.Context) error {
+	userID := userIDFromContext(ctx)
+	if userID == "" {
+		return ErrUnauthorized
+	}
+	_, err := s.storage.RevokeAIConsent(ctx, userID)
+	return err
+}
+
+// HasAIConsent reports whether the owner may use AI features now.
+func (s *Service) HasAIConsent(ctx context.Context, ownerUserID string) (bool, error) {
+	row, found, err := s.storage.GetAIConsent(ctx, strings.TrimSpace(ownerUserID))
+	if err != nil || !found {
+		return false, err
+	}
+	return s.active(row), nil
+}
+
+func (s *Service) active(row storage.AIConsent) bool {
+	return row.RevokedAt == nil && row.PolicyVersion == s.policyVersion
+}
+
+func userIDFromContext(ctx context.Context) string {
+	userID, ok := userctx.GetUserID(ctx)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(userID)
+}
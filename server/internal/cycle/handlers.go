@@ -0,0 +1,231 @@
+package cycle
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// HandleListPeriods handles GET /v1/cycle/periods?profile_id=&from=&to=
+func (h *Handler) HandleListPeriods(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	profileID, ok := parseProfileID(w, query.Get("profile_id"))
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.ListPeriods(r.Context(), profileID, query.Get("from"), query.Get("to"))
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleCreatePeriod handles POST /v1/cycle/periods
+func (h *Handler) HandleCreatePeriod(w http.ResponseWriter, r *http.Request) {
+	var req CreatePeriodRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.CreatePeriod(r.Context(), req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// HandleUpdatePeriod handles PATCH /v1/cycle/periods/{id}
+func (h *Handler) HandleUpdatePeriod(w http.ResponseWriter, r *http.Request) {
+	periodID, ok := parsePeriodID(w, r)
+	if !ok {
+		return
+	}
+	var req UpdatePeriodRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.UpdatePeriod(r.Context(), periodID, req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleDeletePeriod handles DELETE /v1/cycle/periods/{id}
+func (h *Handler) HandleDeletePeriod(w http.ResponseWriter, r *http.Request) {
+	periodID, ok := parsePeriodID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeletePeriod(r.Context(), periodID); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListDays handles GET /v1/cycle/days?profile_id=&from=&to=
+func (h *Handler) HandleListDays(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	profileID, ok := parseProfileID(w, query.Get("profile_id"))
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.ListDays(r.Context(), profileID, query.Get("from"), query.Get("to"))
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleUpsertDay handles PUT /v1/cycle/days
+func (h *Handler) HandleUpsertDay(w http.ResponseWriter, r *http.Request) {
+	var req UpsertDayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.UpsertDay(r.Context(), req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleDeleteDay handles DELETE /v1/cycle/days/{date}?profile_id=
+func (h *Handler) HandleDeleteDay(w http.ResponseWriter, r *http.Request) {
+	profileID, ok := parseProfileID(w, r.URL.Query().Get("profile_id"))
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteDay(r.Context(), profileID, r.PathValue("date")); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePrediction handles GET /v1/cycle/prediction?profile_id=&date=
+func (h *Handler) HandlePrediction(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	profileID, ok := parseProfileID(w, query.Get("profile_id"))
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.Predict(r.Context(), profileID, query.Get("date"))
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleGetSettings handles GET /v1/cycle/settings?profile_id=
+func (h *Handler) HandleGetSettings(w http.ResponseWriter, r *http.Request) {
+	profileID, ok := parseProfileID(w, r.URL.Query().Get("profile_id"))
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.GetSettings(r.Context(), profileID)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleUpdateSettings handles PUT /v1/cycle/settings
+func (h *Handler) HandleUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var req UpdateSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.UpdateSettings(r.Context(), req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+	case errors.Is(err, ErrProfileNotFound):
+		writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
+	case errors.Is(err, ErrPeriodNotFound):
+		writeError(w, http.StatusNotFound, "period_not_found", "Period not found")
+	case errors.Is(err, ErrDayNotFound):
+		writeError(w, http.StatusNotFound, "cycle_day_not_found", "Cycle day not found")
+	case errors.Is(err, ErrPeriodOverlap):
+		writeError(w, http.StatusConflict, "period_overlap", "Period overlaps another period")
+	default:
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+	}
+}
+
+func parseProfileID(w http.ResponseWriter, value string) (uuid.UUID, bool) {
+	profileID, err := uuid.Parse(strings.TrimSpace(value))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "profile_id is required")
+		return uuid.Nil, false
+	}
+	return profileID, true
+}
+
+func parsePeriodID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	periodID, err := uuid.Parse(strings.TrimSpace(r.PathValue("id")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid period id")
+		return uuid.Nil, false
+	}
+	return periodID, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{
+		Error: ErrorDetail{
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
+		},
+	})
+}
@@ -0,0 +1,278 @@
+package cycle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/storage/memory"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+func TestCyclePeriodsCRUDAndOverlap(t *testing.T) {
+	handler, profileID := setupCycleHandler(t)
+
+	w := serveCycleBody(handler.HandleCreatePeriod, http.MethodPost, "/v1/cycle/periods", "", "userA", map[string]any{
+		"profile_id": profileID, "start_date": "2026-03-01", "end_date": "2026-03-05", "note": " спокойно ",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d body=%s", w.Code, w.Body.String())
+	}
+	var created PeriodDTO
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if created.LengthDays == nil || *created.LengthDays != 5 || created.Note != "спокойно" {
+		t.Fatalf("unexpected period %+v", created)
+	}
+
+	for name, body := range map[string]map[string]any{
+		"overlap":      {"profile_id": profileID, "start_date": "2026-03-04"},
+		"covers start": {"profile_id": profileID, "start_date": "2026-02-27", "end_date": "2026-03-01"},
+	} {
+		w = serveCycleBody(handler.HandleCreatePeriod, http.MethodPost, "/v1/cycle/periods", "", "userA", body)
+		if w.Code != http.StatusConflict {
+			t.Fatalf("%s: expected status 409, got %d body=%s", name, w.Code, w.Body.String())
+		}
+	}
+	for name, body := range map[string]map[string]any{
+		"future":         {"profile_id": profileID, "start_date": "2026-06-01"},
+		"end before":     {"profile_id": profileID, "start_date": "2026-04-10", "end_date": "2026-04-09"},
+		"too long":       {"profile_id": profileID, "start_date": "2026-04-01", "end_date": "2026-04-20"},
+		"missing start":  {"profile_id": profileID},
+		"malformed date": {"profile_id": profileID, "start_date": "01.04.2026"},
+	} {
+		w = serveCycleBody(handler.HandleCreatePeriod, http.MethodPost, "/v1/cycle/periods", "", "userA", body)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d body=%s", name, w.Code, w.Body.String())
+		}
+	}
+
+	id := created.ID.String()
+	w = serveCycleBody(handler.HandleUpdatePeriod, http.MethodPatch, "/v1/cycle/periods/"+id, id, "userA", map[string]any{"ongoing": true})
+	var updated PeriodDTO
+	if err := json.NewDecoder(w.Body).Decode(&updated); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if w.Code != http.StatusOK || updated.EndDate != nil || updated.LengthDays != nil {
+		t.Fatalf("expected an ongoing period, got %d %+v", w.Code, updated)
+	}
+
+	w = serveCycleBody(handler.HandleUpdatePeriod, http.MethodPatch, "/v1/cycle/periods/"+id, id, "userB", map[string]any{"end_date": "2026-03-06"})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user, got %d", w.Code)
+	}
+	w = serveCycle(handler.HandleListPeriods, http.MethodGet, "/v1/cycle/periods?profile_id="+profileID.String(), "", "userB")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user's profile, got %d", w.Code)
+	}
+
+	w = serveCycle(handler.HandleDeletePeriod, http.MethodDelete, "/v1/cycle/periods/"+id, id, "userA")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d body=%s", w.Code, w.Body.String())
+	}
+	w = serveCycle(handler.HandleListPeriods, http.MethodGet, "/v1/cycle/periods?profile_id="+profileID.String(), "", "userA")
+	var listed PeriodsResponse
+	if err := json.NewDecoder(w.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(listed.Periods) != 0 {
+		t.Fatalf("expected no periods after delete, got %+v", listed.Periods)
+	}
+}
+
+func TestCycleDaysAndSettings(t *testing.T) {
+	handler, profileID := setupCycleHandler(t)
+
+	w := serveCycleBody(handler.HandleUpsertDay, http.MethodPut, "/v1/cycle/days", "", "userA", map[string]any{
+		"profile_id": profileID, "date": "2026-03-02", "flow": "heavy",
+		"symptoms": []string{"fatigue", "cramps", "cramps"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var day DayDTO
+	if err := json.NewDecoder(w.Body).Decode(&day); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if day.Flow != FlowHeavy || len(day.Symptoms) != 2 || day.Symptoms[0] != "cramps" || day.Symptoms[1] != "fatigue" {
+		t.Fatalf("expected symptoms once each in catalog order, got %+v", day)
+	}
+
+	for name, body := range map[string]map[string]any{
+		"flow":    {"profile_id": profileID, "date": "2026-03-02", "flow": "extreme"},
+		"symptom": {"profile_id": profileID, "date": "2026-03-02", "symptoms": []string{"hiccups"}},
+		"future":  {"profile_id": profileID, "date": "2026-05-02"},
+	} {
+		w = serveCycleBody(handler.HandleUpsertDay, http.MethodPut, "/v1/cycle/days", "", "userA", body)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d body=%s", name, w.Code, w.Body.String())
+		}
+	}
+
+	w = serveCycle(handler.HandleListDays, http.MethodGet, "/v1/cycle/days?from=2026-03-01&to=2026-03-02&profile_id="+profileID.String(), "", "userA")
+	var days DaysResponse
+	if err := json.NewDecoder(w.Body).Decode(&days); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(days.Days) != 1 || days.Days[0].Date != "2026-03-02" {
+		t.Fatalf("expected the logged day in the inclusive range, got %+v", days.Days)
+	}
+
+	deletePath := "/v1/cycle/days/2026-03-02?profile_id=" + profileID.String()
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		req := httptest.NewRequest(http.MethodDelete, deletePath, nil)
+		req.SetPathValue("date", "2026-03-02")
+		req = req.WithContext(userctx.WithUserID(context.Background(), "userA"))
+		w = httptest.NewRecorder()
+		handler.HandleDeleteDay(w, req)
+		if w.Code != want {
+			t.Fatalf("expected status %d, got %d body=%s", want, w.Code, w.Body.String())
+		}
+	}
+
+	w = serveCycle(handler.HandleGetSettings, http.MethodGet, "/v1/cycle/settings?profile_id="+profileID.String(), "", "userA")
+	var settings SettingsDTO
+	if err := json.NewDecoder(w.Body).Decode(&settings); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if settings.ShareWithAI || !settings.RemindersEnabled || settings.RemindDaysBefore != 2 {
+		t.Fatalf("expected private defaults, got %+v", settings)
+	}
+
+	w = serveCycleBody(handler.HandleUpdateSettings, http.MethodPut, "/v1/cycle/settings", "", "userA", map[string]any{
+		"profile_id": profileID, "remind_days_before": 9,
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for remind_days_before, got %d", w.Code)
+	}
+	w = serveCycleBody(handler.HandleUpdateSettings, http.MethodPut, "/v1/cycle/settings", "", "userA", map[string]any{
+		"profile_id": profileID, "share_with_ai": true,
+	})
+	if err := json.NewDecoder(w.Body).Decode(&settings); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if w.Code != http.StatusOK || !settings.ShareWithAI || settings.RemindDaysBefore != 2 {
+		t.Fatalf("expected only sharing changed, got %d %+v", w.Code, settings)
+	}
+	shared, err := handler.service.SharedWithAI(context.Background(), profileID)
+	if err != nil || !shared {
+		t.Fatalf("expected sharing on, got %v %v", shared, err)
+	}
+}
+
+func TestCyclePredictionAndReminders(t *testing.T) {
+	handler, profileID := setupCycleHandler(t)
+
+	for _, start := range []string{"2026-01-05", "2026-02-02", "2026-03-02", "2026-03-30"} {
+		w := serveCycleBody(handler.HandleCreatePeriod, http.MethodPost, "/v1/cycle/periods", "", "userA", map[string]any{
+			"profile_id": profileID, "start_date": start,
+		})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d body=%s", w.Code, w.Body.String())
+		}
+	}
+
+	w := serveCycle(handler.HandlePrediction, http.MethodGet, "/v1/cycle/prediction?date=2026-04-20&profile_id="+profileID.String(), "", "userA")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var prediction PredictionResponse
+	if err := json.NewDecoder(w.Body).Decode(&prediction); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if prediction.CyclesLogged != 3 || prediction.AvgCycleLengthDays != 28 || prediction.Confidence != ConfidenceMedium {
+		t.Fatalf("unexpected averages %+v", prediction)
+	}
+	if next := prediction.NextPeriod; next == nil || next.Expected != "2026-04-27" || next.Earliest != "2026-04-25" || next.Latest != "2026-04-29" {
+		t.Fatalf("unexpected next period %+v", prediction.NextPeriod)
+	}
+	if prediction.Ovulation == nil || prediction.Ovulation.Expected != "2026-04-13" || prediction.Ovulation.Method != MethodCalendar {
+		t.Fatalf("unexpected ovulation %+v", prediction.Ovulation)
+	}
+	if prediction.FertileWindow == nil || prediction.FertileWindow.Start != "2026-04-08" || prediction.FertileWindow.End != "2026-04-14" {
+		t.Fatalf("unexpected fertile window %+v", prediction.FertileWindow)
+	}
+	if cur := prediction.CurrentCycle; cur == nil || cur.Day != 22 || cur.Phase != PhaseLuteal {
+		t.Fatalf("unexpected current cycle %+v", prediction.CurrentCycle)
+	}
+
+	ctx := context.Background()
+	for date, wantKind := range map[string]string{
+		"2026-04-20": "",
+		"2026-04-25": "cycle_period_soon",
+		"2026-05-05": "cycle_period_late",
+		"2026-06-20": "",
+	} {
+		reminder, err := handler.service.CycleReminder(ctx, profileID, mustDate(t, date))
+		if err != nil {
+			t.Fatalf("%s: reminder failed: %v", date, err)
+		}
+		if (reminder == nil && wantKind != "") || (reminder != nil && reminder.Kind != wantKind) {
+			t.Fatalf("%s: expected reminder %q, got %+v", date, wantKind, reminder)
+		}
+	}
+
+	off := false
+	if _, err := handler.service.UpdateSettings(ctx, UpdateSettingsRequest{ProfileID: profileID, RemindersEnabled: &off}); err != nil {
+		t.Fatalf("update settings failed: %v", err)
+	}
+	if reminder, err := handler.service.CycleReminder(ctx, profileID, mustDate(t, "2026-04-25")); err != nil || reminder != nil {
+		t.Fatalf("expected no reminder when disabled, got %+v %v", reminder, err)
+	}
+}
+
+func setupCycleHandler(t *testing.T) (*Handler, uuid.UUID) {
+	t.Helper()
+
+	mem := memory.New()
+	profileID := uuid.New()
+	for _, profile := range []storage.Profile{
+		{ID: profileID, OwnerUserID: "userA", Type: "owner", Name: "User A"},
+		{ID: uuid.New(), OwnerUserID: "userB", Type: "owner", Name: "User B"},
+	} {
+		if err := mem.CreateProfile(context.Background(), &profile); err != nil {
+			t.Fatalf("create profile failed: %v", err)
+		}
+	}
+
+	service := NewService(mem.GetCycleStorage(), mem, mem)
+	service.now = func() time.Time { return time.Date(2026, 4, 20, 9, 0, 0, 0, time.UTC) }
+	return NewHandler(service), profileID
+}
+
+func mustDate(t *testing.T, value string) time.Time {
+	t.Helper()
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		t.Fatalf("parse date failed: %v", err)
+	}
+	return date
+}
+
+func serveCycle(handle http.HandlerFunc, method, path, id, userID string) *httptest.ResponseRecorder {
+	return serveCycleBody(handle, method, path, id, userID, nil)
+}
+
+func serveCycleBody(handle http.HandlerFunc, method, path, id, userID string, body any) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if id != "" {
+		req.SetPathValue("id", id)
+	}
+	req = req.WithContext(userctx.WithUserID(context.Background(), userID))
+	w := httptest.NewRecorder()
+	handle(w, req)
+	return w
+}
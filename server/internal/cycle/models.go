@@ -0,0 +1,146 @@
+package cycle
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PeriodDTO is one logged period. EndDate and LengthDays are null while
+// the period is ongoing.
+type PeriodDTO struct {
+	ID         uuid.UUID `json:"id"`
+	ProfileID  uuid.UUID `json:"profile_id"`
+	StartDate  string    `json:"start_date"`
+	EndDate    *string   `json:"end_date"`
+	LengthDays *int      `json:"length_days"`
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// CreatePeriodRequest logs the start, and optionally the end, of a period.
+type CreatePeriodRequest struct {
+	ProfileID uuid.UUID `json:"profile_id"`
+	StartDate string    `json:"start_date"`
+	EndDate   *string   `json:"end_date,omitempty"`
+	Note      string    `json:"note,omitempty"`
+}
+
+// UpdatePeriodRequest changes the given fields. Ongoing clears the end of
+// a period that turned out not to be over.
+type UpdatePeriodRequest struct {
+	StartDate *string `json:"start_date,omitempty"`
+	EndDate   *string `json:"end_date,omitempty"`
+	Ongoing   bool    `json:"ongoing,omitempty"`
+	Note      *string `json:"note,omitempty"`
+}
+
+// PeriodsResponse lists periods, oldest first.
+type PeriodsResponse struct {
+	Periods []PeriodDTO `json:"periods"`
+}
+
+// DayDTO is what was logged for one day: flow and symptoms from the
+// catalog.
+type DayDTO struct {
+	Date      string    `json:"date"`
+	Flow      string    `json:"flow"`
+	Symptoms  []string  `json:"symptoms"`
+	Note      string    `json:"note"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UpsertDayRequest replaces what is logged for a day.
+type UpsertDayRequest struct {
+	ProfileID uuid.UUID `json:"profile_id"`
+	Date      string    `json:"date"`
+	Flow      string    `json:"flow"`
+	Symptoms  []string  `json:"symptoms"`
+	Note      string    `json:"note,omitempty"`
+}
+
+// DaysResponse lists logged days, oldest first.
+type DaysResponse struct {
+	Days []DayDTO `json:"days"`
+}
+
+// SettingsDTO holds the cycle settings of a profile. Cycle data is shared
+// with the assistant only when ShareWithAI is set. Zero lengths mean the
+// lengths are learned from the logged periods.
+type SettingsDTO struct {
+	ProfileID           uuid.UUID `json:"profile_id"`
+	ShareWithAI         bool      `json:"share_with_ai"`
+	RemindersEnabled    bool      `json:"reminders_enabled"`
+	RemindDaysBefore    int       `json:"remind_days_before"`
+	RemindFertileWindow bool      `json:"remind_fertile_window"`
+	CycleLengthDays     int       `json:"cycle_length_days"`
+	PeriodLengthDays    int       `json:"period_length_days"`
+}
+
+// UpdateSettingsRequest changes the given settings.
+type UpdateSettingsRequest struct {
+	ProfileID           uuid.UUID `json:"profile_id"`
+	ShareWithAI         *bool     `json:"share_with_ai,omitempty"`
+	RemindersEnabled    *bool     `json:"reminders_enabled,omitempty"`
+	RemindDaysBefore    *int      `json:"remind_days_before,omitempty"`
+	RemindFertileWindow *bool     `json:"remind_fertile_window,omitempty"`
+	CycleLengthDays     *int      `json:"cycle_length_days,omitempty"`
+	PeriodLengthDays    *int      `json:"period_length_days,omitempty"`
+}
+
+// DateRangeDTO is an expected date with the interval it likely falls in.
+type DateRangeDTO struct {
+	Expected string `json:"expected"`
+	Earliest string `json:"earliest"`
+	Latest   string `json:"latest"`
+}
+
+// CurrentCycleDTO places the as-of date in the current cycle.
+type CurrentCycleDTO struct {
+	StartDate string `json:"start_date"`
+	Day       int    `json:"day"`
+	Phase     string `json:"phase"`
+}
+
+// OvulationDTO is the estimated ovulation of the current cycle. Method is
+// "calendar" or "temperature"; the shift fields are set for the latter.
+type OvulationDTO struct {
+	DateRangeDTO
+	Method            string   `json:"method"`
+	TemperatureShiftC *float64 `json:"temperature_shift_c"`
+	RestingHRRiseBpm  *float64 `json:"resting_hr_rise_bpm"`
+}
+
+// FertileWindowDTO spans five days before ovulation through the day after.
+type FertileWindowDTO struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// PredictionResponse is the cycle forecast as of a date. Without logged
+// periods only the averages are set.
+type PredictionResponse struct {
+	ProfileID           uuid.UUID         `json:"profile_id"`
+	Date                string            `json:"date"`
+	CyclesLogged        int               `json:"cycles_logged"`
+	AvgCycleLengthDays  float64           `json:"avg_cycle_length_days"`
+	CycleLengthSDDays   float64           `json:"cycle_length_sd_days"`
+	AvgPeriodLengthDays float64           `json:"avg_period_length_days"`
+	CurrentCycle        *CurrentCycleDTO  `json:"current_cycle"`
+	NextPeriod          *DateRangeDTO     `json:"next_period"`
+	Ovulation           *OvulationDTO     `json:"ovulation"`
+	FertileWindow       *FertileWindowDTO `json:"fertile_window"`
+	Confidence          string            `json:"confidence,omitempty"`
+}
+
+// ErrorResponse — стандартный формат ошибки.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
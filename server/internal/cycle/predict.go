@@ -0,0 +1,296 @@
+package cycle
+
+import (
+	"math"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+)
+
+// Phases of the current cycle.
+const (
+	PhaseMenstrual  = "menstrual"
+	PhaseFollicular = "follicular"
+	PhaseFertile    = "fertile"
+	PhaseLuteal     = "luteal"
+	PhaseLate       = "late"
+)
+
+// Confidence levels of a prediction.
+const (
+	ConfidenceLow    = "low"
+	ConfidenceMedium = "medium"
+	ConfidenceHigh   = "high"
+)
+
+// Ovulation estimation methods.
+const (
+	MethodCalendar    = "calendar"
+	MethodTemperature = "temperature"
+)
+
+const (
+	defaultCycleLength  = 28
+	defaultPeriodLength = 5
+	// Cycles outside this range are gaps in logging or errors, not cycles.
+	minCycleLength = 15
+	maxCycleLength = 90
+	maxCyclesUsed  = 12
+	// fallbackSD is assumed until there are two cycles to measure.
+	fallbackSD = 3.0
+	// intervalZ makes the prediction interval cover about 80% of cycles.
+	intervalZ       = 1.28
+	lutealPhaseDays = 14
+	fertileBefore   = 5
+	fertileAfter    = 1
+
+	// The temperature shift is three days at least minShiftC above the
+	// average of the six days before ("3 over 6").
+	baselineDays      = 6
+	minBaselineValues = 4
+	shiftDays         = 3
+	minShiftC         = 0.2
+)
+
+// dayVitals are the daily values the temperature shift is detected from.
+type dayVitals struct {
+	wristC    *float64
+	restingHR *float64
+}
+
+// temperatureShift is a detected post-ovulation temperature rise.
+type temperatureShift struct {
+	ovulation time.Time
+	riseC     float64
+	hrRiseBpm *float64
+}
+
+// predict builds the forecast as of asOf from the periods started on or
+// before it, oldest first, and the vitals of the current cycle keyed by
+// date.
+func predict(asOf time.Time, periods []storage.CyclePeriod, settings storage.CycleSettings, vitals map[string]dayVitals) PredictionResponse {
+	lengths := cycleLengths(periods)
+	fallbackMean := float64(defaultCycleLength)
+	if settings.CycleLengthDays > 0 {
+		fallbackMean = float64(settings.CycleLengthDays)
+	}
+	mean, sd := meanSD(lengths, fallbackMean)
+	periodLength := averagePeriodLength(periods, settings)
+
+	resp := PredictionResponse{
+		Date:                formatDate(asOf),
+		CyclesLogged:        len(lengths),
+		AvgCycleLengthDays:  round(mean, 1),
+		CycleLengthSDDays:   round(sd, 1),
+		AvgPeriodLengthDays: round(periodLength, 1),
+	}
+	if len(periods) == 0 {
+		return resp
+	}
+	last := periods[len(periods)-1]
+
+	halfWidth := max(int(math.Ceil(intervalZ*sd)), 1)
+	if len(lengths) < 3 {
+		halfWidth += 2
+	}
+	next := last.StartDate.AddDate(0, 0, int(math.Round(mean)))
+	nextPeriod := around(next, halfWidth)
+	ovulationDate := next.AddDate(0, 0, -lutealPhaseDays)
+	ovulation := OvulationDTO{DateRangeDTO: around(ovulationDate, halfWidth), Method: MethodCalendar}
+	confidence := confidenceOf(len(lengths), sd)
+
+	if shift, ok := detectTemperatureShift(vitals, last.StartDate, asOf); ok {
+		ovulationDate = shift.ovulation
+		riseC := shift.riseC
+		ovulation = OvulationDTO{
+			DateRangeDTO:      around(ovulationDate, 1),
+			Method:            MethodTemperature,
+			TemperatureShiftC: &riseC,
+			RestingHRRiseBpm:  shift.hrRiseBpm,
+		}
+		next = ovulationDate.AddDate(0, 0, lutealPhaseDays)
+		nextPeriod = around(next, 2)
+		if confidence == ConfidenceLow {
+			confidence = ConfidenceMedium
+		}
+	}
+
+	fertileStart := ovulationDate.AddDate(0, 0, -fertileBefore)
+	fertileEnd := ovulationDate.AddDate(0, 0, fertileAfter)
+	latest := next.AddDate(0, 0, halfWidth)
+	if ovulation.Method == MethodTemperature {
+		latest = next.AddDate(0, 0, 2)
+	}
+
+	resp.CurrentCycle = &CurrentCycleDTO{
+		StartDate: formatDate(last.StartDate),
+		Day:       daysBetween(last.StartDate, asOf) + 1,
+		Phase:     phaseOf(asOf, last, periodLength, fertileStart, fertileEnd, latest),
+	}
+	resp.NextPeriod = &nextPeriod
+	resp.Ovulation = &ovulation
+	resp.FertileWindow = &FertileWindowDTO{Start: formatDate(fertileStart), End: formatDate(fertileEnd)}
+	resp.Confidence = confidence
+	return resp
+}
+
+// cycleLengths returns the lengths of the last complete cycles, skipping
+// implausible ones.
+func cycleLengths(periods []storage.CyclePeriod) []int {
+	lengths := make([]int, 0, len(periods))
+	for i := 1; i < len(periods); i++ {
+		length := daysBetween(periods[i-1].StartDate, periods[i].StartDate)
+		if length >= minCycleLength && length <= maxCycleLength {
+			lengths = append(lengths, length)
+		}
+	}
+	if len(lengths) > maxCyclesUsed {
+		lengths = lengths[len(lengths)-maxCyclesUsed:]
+	}
+	return lengths
+}
+
+// meanSD returns the mean and sample standard deviation of the cycle
+// lengths, never reporting less than a day of spread.
+func meanSD(lengths []int, fallbackMean float64) (float64, float64) {
+	if len(lengths) == 0 {
+		return fallbackMean, fallbackSD
+	}
+	var sum float64
+	for _, length := range lengths {
+		sum += float64(length)
+	}
+	mean := sum / float64(len(lengths))
+	if len(lengths) < 2 {
+		return mean, fallbackSD
+	}
+	var squares float64
+	for _, length := range lengths {
+		squares += (float64(length) - mean) * (float64(length) - mean)
+	}
+	return mean, math.Max(math.Sqrt(squares/float64(len(lengths)-1)), 1)
+}
+
+func averagePeriodLength(periods []storage.CyclePeriod, settings storage.CycleSettings) float64 {
+	var sum, count int
+	for _, period := range periods {
+		if period.EndDate != nil {
+			sum += daysBetween(period.StartDate, *period.EndDate) + 1
+			count++
+		}
+	}
+	switch {
+	case count > 0:
+		return float64(sum) / float64(count)
+	case settings.PeriodLengthDays > 0:
+		return float64(settings.PeriodLengthDays)
+	default:
+		return defaultPeriodLength
+	}
+}
+
+func confidenceOf(cycles int, sd float64) string {
+	switch {
+	case cycles < 3 || sd > 4:
+		return ConfidenceLow
+	case cycles >= 6 && sd <= 2:
+		return ConfidenceHigh
+	default:
+		return ConfidenceMedium
+	}
+}
+
+// detectTemperatureShift looks for the first sustained wrist temperature
+// rise of the cycle. Ovulation is taken as the day before the rise.
+func detectTemperatureShift(vitals map[string]dayVitals, cycleStart, asOf time.Time) (temperatureShift, bool) {
+	for day := cycleStart.AddDate(0, 0, baselineDays); !day.AddDate(0, 0, shiftDays-1).After(asOf); day = day.AddDate(0, 0, 1) {
+		var baselineC, baselineHR []float64
+		for i := baselineDays; i >= 1; i-- {
+			v := vitals[formatDate(day.AddDate(0, 0, -i))]
+			if v.wristC != nil {
+				baselineC = append(baselineC, *v.wristC)
+			}
+			if v.restingHR != nil {
+				baselineHR = append(baselineHR, *v.restingHR)
+			}
+		}
+		if len(baselineC) < minBaselineValues {
+			continue
+		}
+		threshold := average(baselineC) + minShiftC
+
+		var shiftC, shiftHR []float64
+		for i := 0; i < shiftDays; i++ {
+			v := vitals[formatDate(day.AddDate(0, 0, i))]
+			if v.wristC == nil || *v.wristC < threshold {
+				break
+			}
+			shiftC = append(shiftC, *v.wristC)
+			if v.restingHR != nil {
+				shiftHR = append(shiftHR, *v.restingHR)
+			}
+		}
+		if len(shiftC) < shiftDays {
+			continue
+		}
+
+		shift := temperatureShift{
+			ovulation: day.AddDate(0, 0, -1),
+			riseC:     round(average(shiftC)-average(baselineC), 2),
+		}
+		if len(shiftHR) > 0 && len(baselineHR) > 0 {
+			rise := round(average(shiftHR)-average(baselineHR), 1)
+			shift.hrRiseBpm = &rise
+		}
+		return shift, true
+	}
+	return temperatureShift{}, false
+}
+
+func phaseOf(asOf time.Time, last storage.CyclePeriod, periodLength float64, fertileStart, fertileEnd, latest time.Time) string {
+	inPeriod := daysBetween(last.StartDate, asOf) < int(math.Round(periodLength))
+	if last.EndDate != nil {
+		inPeriod = !asOf.After(*last.EndDate)
+	}
+	switch {
+	case inPeriod:
+		return PhaseMenstrual
+	case asOf.Before(fertileStart):
+		return PhaseFollicular
+	case !asOf.After(fertileEnd):
+		return PhaseFertile
+	case !asOf.After(latest):
+		return PhaseLuteal
+	default:
+		return PhaseLate
+	}
+}
+
+func around(date time.Time, halfWidth int) DateRangeDTO {
+	return DateRangeDTO{
+		Expected: formatDate(date),
+		Earliest: formatDate(date.AddDate(0, 0, -halfWidth)),
+		Latest:   formatDate(date.AddDate(0, 0, halfWidth)),
+	}
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+func average(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func round(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
+
+func formatDate(date time.Time) string {
+	return date.Format("2006-01-02")
+}
@@ -0,0 +1,51 @@
+package cycle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+)
+
+func TestPredictWithTemperatureShift(t *testing.T) {
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	periods := []storage.CyclePeriod{{StartDate: start}}
+
+	vitals := make(map[string]dayVitals)
+	for day := 0; day < 19; day++ {
+		temp, hr := 36.3, 60.0
+		if day >= 14 {
+			temp, hr = 36.6, 64.0
+		}
+		vitals[formatDate(start.AddDate(0, 0, day))] = dayVitals{wristC: &temp, restingHR: &hr}
+	}
+
+	resp := predict(start.AddDate(0, 0, 18), periods, storage.CycleSettings{}, vitals)
+	ov := resp.Ovulation
+	if ov == nil || ov.Method != MethodTemperature || ov.Expected != "2026-03-15" {
+		t.Fatalf("expected ovulation the day before the rise, got %+v", ov)
+	}
+	if ov.TemperatureShiftC == nil || *ov.TemperatureShiftC != 0.3 || ov.RestingHRRiseBpm == nil || *ov.RestingHRRiseBpm != 4 {
+		t.Fatalf("unexpected shift %+v", ov)
+	}
+	if resp.NextPeriod == nil || resp.NextPeriod.Expected != "2026-03-29" || resp.NextPeriod.Latest != "2026-03-31" {
+		t.Fatalf("expected the next period a luteal phase after ovulation, got %+v", resp.NextPeriod)
+	}
+	if resp.Confidence != ConfidenceMedium || resp.CurrentCycle.Phase != PhaseLuteal {
+		t.Fatalf("unexpected confidence or phase %+v %+v", resp.Confidence, resp.CurrentCycle)
+	}
+
+	// A single warm day is not a shift.
+	delete(vitals, "2026-03-17")
+	resp = predict(start.AddDate(0, 0, 16), periods, storage.CycleSettings{CycleLengthDays: 30}, vitals)
+	if resp.Ovulation.Method != MethodCalendar || resp.NextPeriod.Expected != "2026-04-01" || resp.Confidence != ConfidenceLow {
+		t.Fatalf("expected a calendar prediction from the set cycle length, got %+v", resp)
+	}
+}
+
+func TestPredictWithoutPeriods(t *testing.T) {
+	resp := predict(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), nil, storage.CycleSettings{}, nil)
+	if resp.CurrentCycle != nil || resp.NextPeriod != nil || resp.Confidence != "" || resp.AvgCycleLengthDays != 28 {
+		t.Fatalf("expected averages only, got %+v", resp)
+	}
+}
@@ -0,0 +1,643 @@
+package cycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRequest  = errors.New("invalid request")
+	ErrProfileNotFound = errors.New("profile not found")
+	ErrPeriodNotFound  = errors.New("period not found")
+	ErrDayNotFound     = errors.New("cycle day not found")
+	ErrPeriodOverlap   = errors.New("period overlaps another period")
+)
+
+// Flow intensities of a logged day; "" is no flow.
+const (
+	FlowNone     = ""
+	FlowSpotting = "spotting"
+	FlowLight    = "light"
+	FlowMedium   = "medium"
+	FlowHeavy    = "heavy"
+)
+
+var validFlows = map[string]bool{
+	FlowNone: true, FlowSpotting: true, FlowLight: true, FlowMedium: true, FlowHeavy: true,
+}
+
+// SymptomCatalog lists the symptoms a cycle day can be tagged with.
+var SymptomCatalog = []string{
+	"cramps", "headache", "migraine", "bloating", "breast_tenderness", "acne",
+	"mood_swings", "fatigue", "back_pain", "nausea", "insomnia", "cravings",
+}
+
+// Limits on logged data and settings.
+const (
+	maxPeriodDays      = 15
+	maxNoteLength      = 1000
+	maxRemindDays      = 7
+	defaultRemindDays  = 2
+	maxPredictionsBack = 2 * 365
+	// maxLateReminderDays stops late reminders once logging has likely
+	// just lapsed.
+	maxLateReminderDays = 14
+)
+
+type profileReader interface {
+	GetProfile(ctx context.Context, id uuid.UUID) (*storage.Profile, error)
+}
+
+// metricsReader supplies wrist temperature and resting heart rate.
+type metricsReader interface {
+	GetDailyMetrics(ctx context.Context, profileID uuid.UUID, from, to string) ([]storage.DailyMetricRow, error)
+}
+
+type Service struct {
+	storage        storage.CycleStorage
+	profileStorage profileReader
+	metrics        metricsReader
+	now            func() time.Time
+}
+
+func NewService(cycleStorage storage.CycleStorage, profileStorage profileReader, metrics metricsReader) *Service {
+	return &Service{
+		storage:        cycleStorage,
+		profileStorage: profileStorage,
+		metrics:        metrics,
+		now:            time.Now,
+	}
+}
+
+// ListPeriods returns the periods started between the from and to dates,
+// both inclusive.
+func (s *Service) ListPeriods(ctx context.Context, profileID uuid.UUID, from, to string) (*PeriodsResponse, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, err
+	}
+	fromDate, toDate, err := parseRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	periods, err := s.storage.ListPeriods(ctx, profileID, fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]PeriodDTO, 0, len(periods))
+	for _, period := range periods {
+		dtos = append(dtos, toPeriodDTO(period))
+	}
+	return &PeriodsResponse{Periods: dtos}, nil
+}
+
+// CreatePeriod logs a period. The end may be left open and set later.
+func (s *Service) CreatePeriod(ctx context.Context, req CreatePeriodRequest) (*PeriodDTO, error) {
+	if err := s.ensureProfileAccess(ctx, req.ProfileID); err != nil {
+		return nil, err
+	}
+
+	period := storage.CyclePeriod{ProfileID: req.ProfileID}
+	var err error
+	if period.StartDate, err = parseDate(req.StartDate, "start_date"); err != nil {
+		return nil, err
+	}
+	if req.EndDate != nil {
+		end, err := parseDate(*req.EndDate, "end_date")
+		if err != nil {
+			return nil, err
+		}
+		period.EndDate = &end
+	}
+	if period.Note, err = normalizeNote(req.Note); err != nil {
+		return nil, err
+	}
+	if err := s.validatePeriod(ctx, period); err != nil {
+		return nil, err
+	}
+
+	created, err := s.storage.CreatePeriod(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	dto := toPeriodDTO(created)
+	return &dto, nil
+}
+
+// UpdatePeriod changes the given fields of a period.
+func (s *Service) UpdatePeriod(ctx context.Context, id uuid.UUID, req UpdatePeriodRequest) (*PeriodDTO, error) {
+	period, err := s.getOwnedPeriod(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Ongoing && req.EndDate != nil {
+		return nil, fmt.Errorf("%w: ongoing excludes end_date", ErrInvalidRequest)
+	}
+	if req.StartDate != nil {
+		if period.StartDate, err = parseDate(*req.StartDate, "start_date"); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case req.Ongoing:
+		period.EndDate = nil
+	case req.EndDate != nil:
+		end, err := parseDate(*req.EndDate, "end_date")
+		if err != nil {
+			return nil, err
+		}
+		period.EndDate = &end
+	}
+	if req.Note != nil {
+		if period.Note, err = normalizeNote(*req.Note); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.validatePeriod(ctx, period); err != nil {
+		return nil, err
+	}
+
+	updated, ok, err := s.storage.UpdatePeriod(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrPeriodNotFound
+	}
+	dto := toPeriodDTO(updated)
+	return &dto, nil
+}
+
+// DeletePeriod deletes a period of an accessible profile.
+func (s *Service) DeletePeriod(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.getOwnedPeriod(ctx, id); err != nil {
+		return err
+	}
+	deleted, err := s.storage.DeletePeriod(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPeriodNotFound
+	}
+	return nil
+}
+
+// ListDays returns the logged days between the from and to dates, both
+// inclusive.
+func (s *Service) ListDays(ctx context.Context, profileID uuid.UUID, from, to string) (*DaysResponse, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, err
+	}
+	fromDate, toDate, err := parseRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := s.storage.ListCycleDays(ctx, profileID, fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]DayDTO, 0, len(days))
+	for _, day := range days {
+		dtos = append(dtos, toDayDTO(day))
+	}
+	return &DaysResponse{Days: dtos}, nil
+}
+
+// UpsertDay replaces the flow, symptoms and note logged for a day.
+func (s *Service) UpsertDay(ctx context.Context, req UpsertDayRequest) (*DayDTO, error) {
+	if err := s.ensureProfileAccess(ctx, req.ProfileID); err != nil {
+		return nil, err
+	}
+
+	day := storage.CycleDay{ProfileID: req.ProfileID, Flow: strings.TrimSpace(req.Flow)}
+	var err error
+	if day.Date, err = parseDate(req.Date, "date"); err != nil {
+		return nil, err
+	}
+	if day.Date.After(s.latestDate()) {
+		return nil, fmt.Errorf("%w: date is in the future", ErrInvalidRequest)
+	}
+	if !validFlows[day.Flow] {
+		return nil, fmt.Errorf("%w: unknown flow %q", ErrInvalidRequest, day.Flow)
+	}
+	if day.Symptoms, err = normalizeSymptoms(req.Symptoms); err != nil {
+		return nil, err
+	}
+	if day.Note, err = normalizeNote(req.Note); err != nil {
+		return nil, err
+	}
+
+	saved, err := s.storage.UpsertCycleDay(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+	dto := toDayDTO(saved)
+	return &dto, nil
+}
+
+// DeleteDay clears what is logged for a day.
+func (s *Service) DeleteDay(ctx context.Context, profileID uuid.UUID, date string) error {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return err
+	}
+	parsed, err := parseDate(date, "date")
+	if err != nil {
+		return err
+	}
+	deleted, err := s.storage.DeleteCycleDay(ctx, profileID, parsed)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrDayNotFound
+	}
+	return nil
+}
+
+// GetSettings returns the cycle settings, defaults when never saved.
+func (s *Service) GetSettings(ctx context.Context, profileID uuid.UUID) (*SettingsDTO, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, err
+	}
+	settings, err := s.loadSettings(ctx, profileID)
+	if err != nil {
+		return nil, err
+	}
+	dto := toSettingsDTO(settings)
+	return &dto, nil
+}
+
+// UpdateSettings changes the given settings.
+func (s *Service) UpdateSettings(ctx context.Context, req UpdateSettingsRequest) (*SettingsDTO, error) {
+	if err := s.ensureProfileAccess(ctx, req.ProfileID); err != nil {
+		return nil, err
+	}
+	settings, err := s.loadSettings(ctx, req.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ShareWithAI != nil {
+		settings.ShareWithAI = *req.ShareWithAI
+	}
+	if req.RemindersEnabled != nil {
+		settings.RemindersEnabled = *req.RemindersEnabled
+	}
+	if req.RemindDaysBefore != nil {
+		if *req.RemindDaysBefore < 0 || *req.RemindDaysBefore > maxRemindDays {
+			return nil, fmt.Errorf("%w: remind_days_before must be 0-%d", ErrInvalidRequest, maxRemindDays)
+		}
+		settings.RemindDaysBefore = *req.RemindDaysBefore
+	}
+	if req.RemindFertileWindow != nil {
+		settings.RemindFertileWindow = *req.RemindFertileWindow
+	}
+	if req.CycleLengthDays != nil {
+		if v := *req.CycleLengthDays; v != 0 && (v < minCycleLength || v > maxCycleLength) {
+			return nil, fmt.Errorf("%w: cycle_length_days must be 0 or %d-%d", ErrInvalidRequest, minCycleLength, maxCycleLength)
+		}
+		settings.CycleLengthDays = *req.CycleLengthDays
+	}
+	if req.PeriodLengthDays != nil {
+		if v := *req.PeriodLengthDays; v < 0 || v > maxPeriodDays {
+			return nil, fmt.Errorf("%w: period_length_days must be 0-%d", ErrInvalidRequest, maxPeriodDays)
+		}
+		settings.PeriodLengthDays = *req.PeriodLengthDays
+	}
+
+	saved, err := s.storage.UpsertCycleSettings(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+	dto := toSettingsDTO(saved)
+	return &dto, nil
+}
+
+// Predict forecasts the next period, ovulation and fertile window as of a
+// date, today by default.
+func (s *Service) Predict(ctx context.Context, profileID uuid.UUID, date string) (*PredictionResponse, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, err
+	}
+	asOf, err := parseOptionalDate(date, "date")
+	if err != nil {
+		return nil, err
+	}
+	if asOf.IsZero() {
+		asOf = today(s.now())
+	}
+	return s.predictAt(ctx, profileID, asOf)
+}
+
+// SharedWithAI reports whether the profile opted in to sharing cycle data
+// with the assistant. It is off until the user turns it on.
+func (s *Service) SharedWithAI(ctx context.Context, profileID uuid.UUID) (bool, error) {
+	settings, ok, err := s.storage.GetCycleSettings(ctx, profileID)
+	if err != nil || !ok {
+		return false, err
+	}
+	return settings.ShareWithAI, nil
+}
+
+// CycleReminder builds the cycle reminder for a date, nil when none is
+// due: the period is expected soon, is late, or the fertile window is on.
+// The texts stay discreet since they may show on a lock screen.
+func (s *Service) CycleReminder(ctx context.Context, profileID uuid.UUID, date time.Time) (*storage.Notification, error) {
+	settings, err := s.loadSettings(ctx, profileID)
+	if err != nil || !settings.RemindersEnabled {
+		return nil, err
+	}
+	prediction, err := s.predictAt(ctx, profileID, date)
+	if err != nil || prediction.CurrentCycle == nil {
+		return nil, err
+	}
+
+	expected, _ := time.Parse("2006-01-02", prediction.NextPeriod.Expected)
+	daysUntil := daysBetween(date, expected)
+	notification := &storage.Notification{
+		ProfileID:  profileID,
+		Title:      "Календарь цикла",
+		SourceDate: &date,
+		Severity:   "info",
+	}
+	switch phase := prediction.CurrentCycle.Phase; {
+	case phase == PhaseMenstrual:
+		return nil, nil
+	case phase == PhaseLate && -daysUntil <= maxLateReminderDays:
+		notification.Kind = "cycle_period_late"
+		notification.Body = fmt.Sprintf("Задержка %d дн. относительно прогноза. Отметь начало, когда оно наступит.", -daysUntil)
+	case daysUntil >= 0 && daysUntil <= settings.RemindDaysBefore:
+		notification.Kind = "cycle_period_soon"
+		if daysUntil == 0 {
+			notification.Body = "Новый цикл ожидается сегодня."
+		} else {
+			notification.Body = fmt.Sprintf("Новый цикл ожидается через %d дн.", daysUntil)
+		}
+	case phase == PhaseFertile && settings.RemindFertileWindow:
+		notification.Kind = "cycle_fertile_window"
+		notification.Body = fmt.Sprintf("Фертильное окно до %s.", prediction.FertileWindow.End)
+	default:
+		return nil, nil
+	}
+	return notification, nil
+}
+
+func (s *Service) predictAt(ctx context.Context, profileID uuid.UUID, asOf time.Time) (*PredictionResponse, error) {
+	settings, err := s.loadSettings(ctx, profileID)
+	if err != nil {
+		return nil, err
+	}
+	periods, err := s.storage.ListPeriods(ctx, profileID, asOf.AddDate(0, 0, -maxPredictionsBack), asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	var vitals map[string]dayVitals
+	if len(periods) > 0 && s.metrics != nil {
+		start := periods[len(periods)-1].StartDate
+		if vitals, err = s.loadVitals(ctx, profileID, start, asOf); err != nil {
+			return nil, err
+		}
+	}
+
+	resp := predict(asOf, periods, settings, vitals)
+	resp.ProfileID = profileID
+	return &resp, nil
+}
+
+// loadVitals reads wrist temperature and resting heart rate from the daily
+// metrics; zero heart rates mean no reading.
+func (s *Service) loadVitals(ctx context.Context, profileID uuid.UUID, from, to time.Time) (map[string]dayVitals, error) {
+	rows, err := s.metrics.GetDailyMetrics(ctx, profileID, formatDate(from), formatDate(to))
+	if err != nil {
+		return nil, err
+	}
+	vitals := make(map[string]dayVitals, len(rows))
+	for _, row := range rows {
+		var payload struct {
+			Temperature struct {
+				WristCAvg *float64 `json:"wrist_c_avg"`
+			} `json:"temperature"`
+			Heart struct {
+				RestingHRBpm *float64 `json:"resting_hr_bpm"`
+			} `json:"heart"`
+		}
+		if err := json.Unmarshal(row.Payload, &payload); err != nil {
+			continue
+		}
+		v := dayVitals{wristC: payload.Temperature.WristCAvg}
+		if hr := payload.Heart.RestingHRBpm; hr != nil && *hr > 0 {
+			v.restingHR = hr
+		}
+		vitals[row.Date] = v
+	}
+	return vitals, nil
+}
+
+func (s *Service) loadSettings(ctx context.Context, profileID uuid.UUID) (storage.CycleSettings, error) {
+	settings, ok, err := s.storage.GetCycleSettings(ctx, profileID)
+	if err != nil {
+		return storage.CycleSettings{}, err
+	}
+	if !ok {
+		return storage.CycleSettings{
+			ProfileID:        profileID,
+			RemindersEnabled: true,
+			RemindDaysBefore: defaultRemindDays,
+		}, nil
+	}
+	return settings, nil
+}
+
+// validatePeriod checks dates and refuses periods overlapping another one
+// of the profile. An open period occupies its start day only.
+func (s *Service) validatePeriod(ctx context.Context, period storage.CyclePeriod) error {
+	if period.StartDate.After(s.latestDate()) {
+		return fmt.Errorf("%w: start_date is in the future", ErrInvalidRequest)
+	}
+	if period.EndDate != nil {
+		if period.EndDate.Before(period.StartDate) {
+			return fmt.Errorf("%w: end_date is before start_date", ErrInvalidRequest)
+		}
+		if daysBetween(period.StartDate, *period.EndDate)+1 > maxPeriodDays {
+			return fmt.Errorf("%w: a period lasts at most %d days", ErrInvalidRequest, maxPeriodDays)
+		}
+	}
+
+	others, err := s.storage.ListPeriods(ctx, period.ProfileID,
+		period.StartDate.AddDate(0, 0, -maxPeriodDays), lastDay(period))
+	if err != nil {
+		return err
+	}
+	for _, other := range others {
+		if other.ID != period.ID && !lastDay(other).Before(period.StartDate) {
+			return ErrPeriodOverlap
+		}
+	}
+	return nil
+}
+
+// latestDate is the last date that may be logged: today with a day of
+// slack for time zones ahead of UTC.
+func (s *Service) latestDate() time.Time {
+	return today(s.now()).AddDate(0, 0, 1)
+}
+
+func (s *Service) getOwnedPeriod(ctx context.Context, id uuid.UUID) (storage.CyclePeriod, error) {
+	period, ok, err := s.storage.GetPeriod(ctx, id)
+	if err != nil {
+		return storage.CyclePeriod{}, err
+	}
+	if !ok {
+		return storage.CyclePeriod{}, ErrPeriodNotFound
+	}
+	if err := s.ensureProfileAccess(ctx, period.ProfileID); err != nil {
+		return storage.CyclePeriod{}, ErrPeriodNotFound
+	}
+	return period, nil
+}
+
+func (s *Service) ensureProfileAccess(ctx context.Context, profileID uuid.UUID) error {
+	profile, err := s.profileStorage.GetProfile(ctx, profileID)
+	if err != nil {
+		return ErrProfileNotFound
+	}
+
+	if userID, ok := userctx.GetUserID(ctx); ok && strings.TrimSpace(userID) != "" && profile.OwnerUserID != userID {
+		return ErrProfileNotFound
+	}
+
+	return nil
+}
+
+func lastDay(period storage.CyclePeriod) time.Time {
+	if period.EndDate != nil {
+		return *period.EndDate
+	}
+	return period.StartDate
+}
+
+// normalizeSymptoms keeps catalog symptoms once each, in catalog order.
+func normalizeSymptoms(symptoms []string) ([]string, error) {
+	given := make(map[string]bool, len(symptoms))
+	for _, symptom := range symptoms {
+		given[strings.TrimSpace(symptom)] = true
+	}
+	out := make([]string, 0, len(given))
+	for _, symptom := range SymptomCatalog {
+		if given[symptom] {
+			out = append(out, symptom)
+			delete(given, symptom)
+		}
+	}
+	for symptom := range given {
+		return nil, fmt.Errorf("%w: unknown symptom %q", ErrInvalidRequest, symptom)
+	}
+	return out, nil
+}
+
+func normalizeNote(note string) (string, error) {
+	note = strings.TrimSpace(note)
+	if len([]rune(note)) > maxNoteLength {
+		return "", fmt.Errorf("%w: note is too long", ErrInvalidRequest)
+	}
+	return note, nil
+}
+
+func parseRange(from, to string) (time.Time, time.Time, error) {
+	fromDate, err := parseOptionalDate(from, "from")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	toDate, err := parseOptionalDate(to, "to")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !fromDate.IsZero() && !toDate.IsZero() && toDate.Before(fromDate) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to is before from", ErrInvalidRequest)
+	}
+	return fromDate, toDate, nil
+}
+
+func parseDate(value, field string) (time.Time, error) {
+	parsed, err := parseOptionalDate(value, field)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if parsed.IsZero() {
+		return time.Time{}, fmt.Errorf("%w: %s is required", ErrInvalidRequest, field)
+	}
+	return parsed, nil
+}
+
+func parseOptionalDate(value, field string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid %s", ErrInvalidRequest, field)
+	}
+	return parsed, nil
+}
+
+func today(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func toPeriodDTO(period storage.CyclePeriod) PeriodDTO {
+	dto := PeriodDTO{
+		ID:        period.ID,
+		ProfileID: period.ProfileID,
+		StartDate: formatDate(period.StartDate),
+		Note:      period.Note,
+		CreatedAt: period.CreatedAt,
+		UpdatedAt: period.UpdatedAt,
+	}
+	if period.EndDate != nil {
+		end := formatDate(*period.EndDate)
+		length := daysBetween(period.StartDate, *period.EndDate) + 1
+		dto.EndDate = &end
+		dto.LengthDays = &length
+	}
+	return dto
+}
+
+func toDayDTO(day storage.CycleDay) DayDTO {
+	symptoms := day.Symptoms
+	if symptoms == nil {
+		symptoms = []string{}
+	}
+	return DayDTO{
+		Date:      formatDate(day.Date),
+		Flow:      day.Flow,
+		Symptoms:  symptoms,
+		Note:      day.Note,
+		UpdatedAt: day.UpdatedAt,
+	}
+}
+
+func toSettingsDTO(settings storage.CycleSettings) SettingsDTO {
+	return SettingsDTO{
+		ProfileID:           settings.ProfileID,
+		ShareWithAI:         settings.ShareWithAI,
+		RemindersEnabled:    settings.RemindersEnabled,
+		RemindDaysBefore:    settings.RemindDaysBefore,
+		RemindFertileWindow: settings.RemindFertileWindow,
+		CycleLengthDays:     settings.CycleLengthDays,
+		PeriodLengthDays:    settings.PeriodLengthDays,
+	}
+}
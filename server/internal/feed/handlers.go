@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 // HandleGetDay handles GET /v1/feed/day?profile_id=&date=
@@ -35,6 +37,7 @@ func HandleGetDay(service *Service) http.HandlerFunc {
 				writeError(w, http.StatusBadRequest, "invalid_date", err.Error())
 				return
 			}
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal", err.Error())
 			return
 		}
@@ -50,8 +53,9 @@ func writeError(w http.ResponseWriter, status int, code, message string) {
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(ErrorResponse{
 		Error: ErrorDetail{
-			Code:    code,
-			Message: message,
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
 		},
 	})
 }
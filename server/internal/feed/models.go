@@ -18,6 +18,7 @@ type FeedDayResponse struct {
 	MealToday         []MealPlanItem     `json:"meal_today,omitempty"`
 	MealPlanTitle     string             `json:"meal_plan_title,omitempty"`
 	FoodPrefsCount    int                `json:"food_prefs_count"`
+	Cycle             *CycleStatus       `json:"cycle,omitempty"`
 	MissingFields     []string           `json:"missing_fields"` // e.g., ["daily", "morning_checkin", "evening_checkin", "weight", "resting_hr"]
 }
 
@@ -67,6 +68,17 @@ type CheckinSummary struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// CycleStatus places the day in the menstrual cycle
+type CycleStatus struct {
+	CycleDay        int    `json:"cycle_day"`
+	Phase           string `json:"phase"`
+	Flow            string `json:"flow,omitempty"`
+	NextPeriodStart string `json:"next_period_start"`
+	DaysUntilNext   int    `json:"days_until_next"`
+	FertileWindow   bool   `json:"fertile_window"`
+	Confidence      string `json:"confidence"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error ErrorDetail `json:"error"`
@@ -74,8 +86,9 @@ type ErrorResponse struct {
 
 // ErrorDetail contains error code and message
 type ErrorDetail struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // MealPlanItem represents a meal in the daily meal plan
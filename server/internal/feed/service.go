@@ -113,6 +113,11 @@ type FoodPref struct {
 	ID string
 }
 
+// CycleReader defines the interface for menstrual cycle status
+type CycleReader interface {
+	GetCycleStatus(ctx context.Context, profileID uuid.UUID, date string) (*CycleStatus, error)
+}
+
 // Service handles feed business logic
 type Service struct {
 	metricsStorage          MetricsStorage
@@ -122,6 +127,7 @@ type Service struct {
 	nutritionTargetsStorage NutritionTargetsStorage
 	mealPlansStorage        MealPlansStorage
 	foodPrefsStorage        FoodPrefsStorage
+	cycle                   CycleReader
 }
 
 // NewService creates a new feed service
@@ -152,6 +158,12 @@ func (s *Service) WithFoodPrefsStorage(storage FoodPrefsStorage) *Service {
 	return s
 }
 
+// WithCycle adds menstrual cycle status to the day summary
+func (s *Service) WithCycle(reader CycleReader) *Service {
+	s.cycle = reader
+	return s
+}
+
 // GetDaySummary returns a summary for a specific day
 func (s *Service) GetDaySummary(ctx context.Context, profileID uuid.UUID, date string) (*FeedDayResponse, error) {
 	profile, err := s.profileStorage.GetProfile(ctx, profileID)
@@ -358,6 +370,15 @@ func (s *Service) GetDaySummary(ctx context.Context, profileID uuid.UUID, date s
 		}
 	}
 
+	// Cycle status (optional, only once periods are logged)
+	var cycleStatus *CycleStatus
+	if s.cycle != nil {
+		status, err := s.cycle.GetCycleStatus(ctx, profileID, date)
+		if err == nil {
+			cycleStatus = status
+		}
+	}
+
 	return &FeedDayResponse{
 		Date:              date,
 		ProfileID:         profileID,
@@ -369,6 +390,7 @@ func (s *Service) GetDaySummary(ctx context.Context, profileID uuid.UUID, date s
 		MealToday:         mealToday,
 		MealPlanTitle:     mealPlanTitle,
 		FoodPrefsCount:    foodPrefsCount,
+		Cycle:             cycleStatus,
 		MissingFields:     missingFields,
 	}, nil
 }
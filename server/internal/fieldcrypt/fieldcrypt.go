@@ -0,0 +1,205 @@
+// Package fieldcrypt implements envelope encryption for sensitive columns.
+//
+// Every row gets its own random AES-256 data key. Field values are sealed
+// with the data key (AES-GCM), and the data key itself is wrapped with a
+// master key from config. The row stores the wrapped data key together with
+// the master key ID, so master keys can be rotated by re-wrapping data keys
+// without touching the field ciphertext.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const keySize = 32
+
+var (
+	ErrUnknownKey = errors.New("fieldcrypt: unknown master key id")
+	ErrDecrypt    = errors.New("fieldcrypt: ciphertext is invalid or was tampered with")
+)
+
+// Keyring holds the master keys. The first configured key is active and is
+// used for new data keys; the rest are kept only to unwrap older rows.
+type Keyring struct {
+	activeID string
+	keys     map[string]cipher.AEAD
+}
+
+// ParseKeyring parses "id:base64key" entries (FIELD_ENCRYPTION_KEYS).
+// Keys must decode to 32 bytes.
+func ParseKeyring(entries []string) (*Keyring, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("fieldcrypt: no master keys configured")
+	}
+
+	kr := &Keyring{keys: make(map[string]cipher.AEAD, len(entries))}
+	for _, entry := range entries {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return nil, fmt.Errorf("fieldcrypt: key entry must look like id:base64key")
+		}
+		if _, dup := kr.keys[id]; dup {
+			return nil, fmt.Errorf("fieldcrypt: duplicate key id %q", id)
+		}
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: key %q is not valid base64", id)
+		}
+		if len(raw) != keySize {
+			return nil, fmt.Errorf("fieldcrypt: key %q must be %d bytes, got %d", id, keySize, len(raw))
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, err
+		}
+		kr.keys[id] = aead
+		if kr.activeID == "" {
+			kr.activeID = id
+		}
+	}
+	return kr, nil
+}
+
+// ActiveKeyID returns the ID of the master key used for new rows.
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeID
+}
+
+// NewDataKey generates a fresh data key wrapped with the active master key.
+func (k *Keyring) NewDataKey() (*DataKey, error) {
+	raw := make([]byte, keySize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(k.keys[k.activeID], raw, wrapAAD(k.activeID))
+	if err != nil {
+		return nil, err
+	}
+	return &DataKey{KeyID: k.activeID, Wrapped: wrapped, aead: aead}, nil
+}
+
+// OpenDataKey unwraps a data key stored with a row.
+func (k *Keyring) OpenDataKey(keyID string, wrapped []byte) (*DataKey, error) {
+	raw, err := k.unwrap(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &DataKey{KeyID: keyID, Wrapped: wrapped, aead: aead}, nil
+}
+
+// Rewrap re-wraps a data key with the active master key. The field
+// ciphertext sealed with the data key stays valid.
+func (k *Keyring) Rewrap(keyID string, wrapped []byte) (string, []byte, error) {
+	raw, err := k.unwrap(keyID, wrapped)
+	if err != nil {
+		return "", nil, err
+	}
+	rewrapped, err := seal(k.keys[k.activeID], raw, wrapAAD(k.activeID))
+	if err != nil {
+		return "", nil, err
+	}
+	return k.activeID, rewrapped, nil
+}
+
+func (k *Keyring) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	master, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	raw, err := open(master, wrapped, wrapAAD(keyID))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != keySize {
+		return nil, ErrDecrypt
+	}
+	return raw, nil
+}
+
+// DataKey is an unwrapped per-row key.
+type DataKey struct {
+	KeyID   string
+	Wrapped []byte
+	aead    cipher.AEAD
+}
+
+// Seal encrypts plaintext; aad names the field so ciphertexts cannot be
+// moved between columns.
+func (d *DataKey) Seal(plaintext []byte, aad string) ([]byte, error) {
+	return seal(d.aead, plaintext, []byte(aad))
+}
+
+// Open decrypts a value produced by Seal with the same aad.
+func (d *DataKey) Open(ciphertext []byte, aad string) ([]byte, error) {
+	return open(d.aead, ciphertext, []byte(aad))
+}
+
+// SealString is Seal with base64 output, for TEXT columns.
+func (d *DataKey) SealString(plaintext, aad string) (string, error) {
+	ct, err := d.Seal([]byte(plaintext), aad)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ct), nil
+}
+
+// OpenString reverses SealString.
+func (d *DataKey) OpenString(ciphertext, aad string) (string, error) {
+	ct, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	pt, err := d.Open(ct, aad)
+	if err != nil {
+		return "", err
+	}
+	return string(pt), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns nonce || ciphertext.
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, aad []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	nonce, body := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, body, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func wrapAAD(keyID string) []byte {
+	return []byte("data-key:" + keyID)
+}
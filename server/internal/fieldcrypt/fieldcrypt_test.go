@@ -0,0 +1,102 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, keySize))
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	kr, err := ParseKeyring([]string{"k1:" + testKey(1)})
+	if err != nil {
+		t.Fatalf("parse keyring failed: %v", err)
+	}
+
+	dk, err := kr.NewDataKey()
+	if err != nil {
+		t.Fatalf("new data key failed: %v", err)
+	}
+	if dk.KeyID != "k1" {
+		t.Fatalf("expected key id k1, got %q", dk.KeyID)
+	}
+
+	sealed, err := dk.SealString("slept badly", "checkins.note")
+	if err != nil {
+		t.Fatalf("seal failed: %v", err)
+	}
+	if strings.Contains(sealed, "slept") {
+		t.Fatalf("ciphertext leaks plaintext: %q", sealed)
+	}
+
+	reopened, err := kr.OpenDataKey(dk.KeyID, dk.Wrapped)
+	if err != nil {
+		t.Fatalf("open data key failed: %v", err)
+	}
+	plain, err := reopened.OpenString(sealed, "checkins.note")
+	if err != nil || plain != "slept badly" {
+		t.Fatalf("expected round trip, got %q err=%v", plain, err)
+	}
+
+	if _, err := reopened.OpenString(sealed, "sources.text"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected ErrDecrypt for wrong field, got %v", err)
+	}
+}
+
+func TestRewrapAfterRotation(t *testing.T) {
+	old, err := ParseKeyring([]string{"k1:" + testKey(1)})
+	if err != nil {
+		t.Fatalf("parse keyring failed: %v", err)
+	}
+	dk, _ := old.NewDataKey()
+	sealed, _ := dk.SealString("secret", "chat_messages.content")
+
+	rotated, err := ParseKeyring([]string{"k2:" + testKey(2), "k1:" + testKey(1)})
+	if err != nil {
+		t.Fatalf("parse keyring failed: %v", err)
+	}
+	if rotated.ActiveKeyID() != "k2" {
+		t.Fatalf("expected first key to be active, got %q", rotated.ActiveKeyID())
+	}
+
+	keyID, wrapped, err := rotated.Rewrap(dk.KeyID, dk.Wrapped)
+	if err != nil {
+		t.Fatalf("rewrap failed: %v", err)
+	}
+	if keyID != "k2" {
+		t.Fatalf("expected rewrap to k2, got %q", keyID)
+	}
+
+	onlyNew, _ := ParseKeyring([]string{"k2:" + testKey(2)})
+	reopened, err := onlyNew.OpenDataKey(keyID, wrapped)
+	if err != nil {
+		t.Fatalf("open rewrapped key failed: %v", err)
+	}
+	if plain, err := reopened.OpenString(sealed, "chat_messages.content"); err != nil || plain != "secret" {
+		t.Fatalf("expected old ciphertext to stay readable, got %q err=%v", plain, err)
+	}
+
+	if _, err := onlyNew.OpenDataKey(dk.KeyID, dk.Wrapped); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey for retired key, got %v", err)
+	}
+}
+
+func TestParseKeyringRejectsBadEntries(t *testing.T) {
+	cases := [][]string{
+		nil,
+		{"nokey"},
+		{"k1:not-base64!"},
+		{"k1:" + base64.StdEncoding.EncodeToString([]byte("short"))},
+		{"k1:" + testKey(1), "k1:" + testKey(2)},
+	}
+	for _, entries := range cases {
+		if _, err := ParseKeyring(entries); err == nil {
+			t.Fatalf("expected error for %v", entries)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/fdg312/health-hub/internal/logging"
 	"github.com/fdg312/health-hub/internal/storage"
 	"github.com/fdg312/health-hub/internal/userctx"
 )
@@ -43,6 +44,7 @@ func (h *Handler) HandleList(w http.ResponseWriter, r *http.Request) {
 	// List food preferences
 	prefs, total, err := h.service.List(ctx, ownerUserID, profileID, query, limit, offset)
 	if err != nil {
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to list food preferences")
 		return
 	}
@@ -101,6 +103,7 @@ func (h *Handler) HandleUpsert(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusConflict, "limit_reached", errMsg)
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to save food preference")
 		return
 	}
@@ -136,6 +139,7 @@ func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, "not_found", "Food preference not found")
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to delete food preference")
 		return
 	}
@@ -178,10 +182,14 @@ func parseIntQuery(r *http.Request, key string, defaultValue int) int {
 func writeError(w http.ResponseWriter, status int, code, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
+	detail := map[string]string{
+		"code":    code,
+		"message": message,
+	}
+	if requestID := logging.ResponseRequestID(w); requestID != "" {
+		detail["request_id"] = requestID
+	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": map[string]string{
-			"code":    code,
-			"message": message,
-		},
+		"error": detail,
 	})
 }
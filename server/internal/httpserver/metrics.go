@@ -0,0 +1,37 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// metricsHandler serves Prometheus metrics, requiring
+// "Authorization: Bearer <METRICS_TOKEN>" when a token is configured.
+func (s *Server) metricsHandler() http.Handler {
+	next := s.telemetry.Handler()
+	token := s.config.MetricsToken
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeMiddlewareError(w, http.StatusUnauthorized, "unauthorized", "invalid metrics token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveMetrics exposes /metrics on a separate listener (METRICS_ADDR),
+// which is usually reachable only from the internal network.
+func (s *Server) serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", s.metricsHandler())
+	log.Printf("Metrics: http://localhost%s/metrics\n", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("ERROR metrics listener stopped: %v", err)
+	}
+}
@@ -47,7 +47,5 @@ func requireProfileOwned(ctx context.Context, storage storage.Storage, profileID
 // writeOwnershipError writes a 404 response for ownership violations
 // (using 404 instead of 403 for security reasons - don't reveal profile existence)
 func writeOwnershipError(w http.ResponseWriter) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotFound)
-	w.Write([]byte(`{"error":{"code":"profile_not_found","message":"Profile not found"}}`))
+	writeMiddlewareError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
 }
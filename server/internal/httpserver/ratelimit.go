@@ -8,6 +8,7 @@ import (
 	"sync/atomic"
 
 	"github.com/fdg312/health-hub/internal/config"
+	"github.com/fdg312/health-hub/internal/logging"
 	"golang.org/x/time/rate"
 )
 
@@ -81,15 +82,8 @@ func RateLimitMiddleware(cfg *config.Config, next http.Handler) http.Handler {
 		limiter := store.getLimiter(ip)
 
 		if !limiter.Allow() {
-			w.Header().Set("Content-Type", "application/json")
 			w.Header().Set("Retry-After", "1")
-			w.WriteHeader(http.StatusTooManyRequests)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error": map[string]string{
-					"code":    "rate_limited",
-					"message": "Too many requests",
-				},
-			})
+			writeMiddlewareError(w, http.StatusTooManyRequests, "rate_limited", "Too many requests")
 			return
 		}
 
@@ -118,3 +112,20 @@ func extractIP(r *http.Request) string {
 	}
 	return ip
 }
+
+// writeMiddlewareError writes the standard error body for responses produced
+// by server middleware, including the request ID when one is assigned.
+func writeMiddlewareError(w http.ResponseWriter, status int, code, message string) {
+	detail := map[string]string{
+		"code":    code,
+		"message": message,
+	}
+	if requestID := logging.ResponseRequestID(w); requestID != "" {
+		detail["request_id"] = requestID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": detail,
+	})
+}
@@ -5,21 +5,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/fdg312/health-hub/internal/ai"
+	"github.com/fdg312/health-hub/internal/aiusage"
+	"github.com/fdg312/health-hub/internal/audit"
 	"github.com/fdg312/health-hub/internal/auth"
 	"github.com/fdg312/health-hub/internal/auth/emailotp"
 	"github.com/fdg312/health-hub/internal/blob"
 	"github.com/fdg312/health-hub/internal/chat"
 	"github.com/fdg312/health-hub/internal/checkins"
+	"github.com/fdg312/health-hub/internal/coaching"
 	"github.com/fdg312/health-hub/internal/config"
+	"github.com/fdg312/health-hub/internal/consent"
+	"github.com/fdg312/health-hub/internal/cycle"
 	"github.com/fdg312/health-hub/internal/feed"
+	"github.com/fdg312/health-hub/internal/fieldcrypt"
 	"github.com/fdg312/health-hub/internal/foodprefs"
+	"github.com/fdg312/health-hub/internal/imageproc"
 	"github.com/fdg312/health-hub/internal/intakes"
+	"github.com/fdg312/health-hub/internal/labs"
+	"github.com/fdg312/health-hub/internal/linkpreview"
+	"github.com/fdg312/health-hub/internal/logging"
 	"github.com/fdg312/health-hub/internal/mailer"
 	"github.com/fdg312/health-hub/internal/mealplans"
+	"github.com/fdg312/health-hub/internal/medications"
 	"github.com/fdg312/health-hub/internal/metrics"
 	"github.com/fdg312/health-hub/internal/notifications"
 	"github.com/fdg312/health-hub/internal/nutrition"
@@ -27,11 +39,15 @@ import (
 	"github.com/fdg312/health-hub/internal/proposals"
 	"github.com/fdg312/health-hub/internal/reports"
 	"github.com/fdg312/health-hub/internal/schedules"
+	"github.com/fdg312/health-hub/internal/search"
 	"github.com/fdg312/health-hub/internal/settings"
 	"github.com/fdg312/health-hub/internal/sources"
+	"github.com/fdg312/health-hub/internal/speech"
 	"github.com/fdg312/health-hub/internal/storage"
 	"github.com/fdg312/health-hub/internal/storage/memory"
 	"github.com/fdg312/health-hub/internal/storage/postgres"
+	"github.com/fdg312/health-hub/internal/symptoms"
+	"github.com/fdg312/health-hub/internal/telemetry"
 	"github.com/fdg312/health-hub/internal/workouts"
 	"github.com/google/uuid"
 )
@@ -42,13 +58,20 @@ type Server struct {
 	mux            *http.ServeMux
 	storage        storage.Storage
 	authMiddleware *auth.Middleware
+	audit          *audit.Service
+	proposals      *proposals.Service
+	coaching       *coaching.Service
+	sources        *sources.Service
+	telemetry      *telemetry.Metrics
+	stopJobs       context.CancelFunc
 }
 
 // New создаёт новый HTTP сервер
 func New(cfg *config.Config) *Server {
 	s := &Server{
-		config: cfg,
-		mux:    http.NewServeMux(),
+		config:    cfg,
+		mux:       http.NewServeMux(),
+		telemetry: telemetry.NewMetrics(),
 	}
 
 	// Инициализируем storage
@@ -74,6 +97,15 @@ func (s *Server) initStorage() {
 			s.storage = memory.New()
 		} else {
 			log.Println("PostgreSQL подключен успешно")
+			if len(s.config.FieldEncryptionKeys) > 0 {
+				keys, err := fieldcrypt.ParseKeyring(s.config.FieldEncryptionKeys)
+				if err != nil {
+					log.Fatalf("FIELD_ENCRYPTION_KEYS: %v", err)
+				}
+				pgStorage.WithFieldEncryption(keys)
+				log.Printf("Шифрование полей включено (active key %s)", keys.ActiveKeyID())
+			}
+			s.telemetry.RegisterPgxPool(pgStorage.PoolStat)
 			s.storage = pgStorage
 		}
 	}
@@ -84,6 +116,11 @@ func (s *Server) routes() {
 	// Health check (no auth required)
 	s.mux.HandleFunc("/healthz", s.handleHealthz)
 
+	// GET /metrics - Prometheus on the main port (only with METRICS_TOKEN and no METRICS_ADDR)
+	if s.config.MetricsToken != "" && s.config.MetricsAddr == "" {
+		s.mux.Handle("GET /metrics", s.metricsHandler())
+	}
+
 	// Auth API (no auth required)
 	var appleVerifier auth.AppleTokenVerifier
 	if s.config.AuthMode == "siwa" {
@@ -101,8 +138,11 @@ func (s *Server) routes() {
 		log.Printf("email sender initialization skipped (email auth disabled): %v", err)
 		emailSender = mailer.NewLocalSender(log.Default())
 	}
-	emailOTPService := emailotp.NewService(s.config, otpStorage, emailSender)
-	authHandler := auth.NewHandlers(authService).WithEmailOTP(emailOTPService)
+	emailOTPService := emailotp.NewService(s.config, otpStorage, emailSender).
+		WithAbuseLedger(s.getEmailOTPAbuseStorage())
+	authHandler := auth.NewHandlers(authService).
+		WithEmailOTP(emailOTPService).
+		WithTrustedProxies(s.config.OTPTrustedProxies)
 	s.authMiddleware = auth.NewMiddleware(s.config, authService)
 
 	// POST /v1/auth/dev - local dev token without Apple
@@ -120,6 +160,19 @@ func (s *Server) routes() {
 	// POST /v1/auth/apple - sign in with Apple
 	s.mux.HandleFunc("POST /v1/auth/apple", authHandler.HandleSignInApple)
 
+	// GET /v1/admin/otp-abuse - inspect OTP abuse ledger (admins only)
+	s.mux.HandleFunc("GET /v1/admin/otp-abuse", authHandler.HandleAdminOTPAbuseList)
+
+	// DELETE /v1/admin/otp-abuse/{scope}/{key} - lift a lockout (admins only)
+	s.mux.HandleFunc("DELETE /v1/admin/otp-abuse/{scope}/{key}", authHandler.HandleAdminOTPAbuseReset)
+
+	// Audit log
+	s.audit = audit.NewService(s.getAuditStorage(), s.storage, s.config.AuditRetentionDays)
+	auditHandler := audit.NewHandler(s.audit)
+
+	// GET /v1/audit - audit events for the current user
+	s.mux.HandleFunc("GET /v1/audit", auditHandler.HandleList)
+
 	// Profiles API
 	profileService := profiles.NewService(s.storage)
 	profileHandler := profiles.NewHandler(profileService)
@@ -138,7 +191,8 @@ func (s *Server) routes() {
 
 	// Metrics API
 	// Используем s.storage который реализует и Storage и MetricsStorage
-	metricsService := metrics.NewService(s.storage, s.storage.(storage.MetricsStorage))
+	metricsService := metrics.NewService(s.storage, s.storage.(storage.MetricsStorage)).
+		WithTelemetry(s.telemetry)
 	metricsHandler := metrics.NewHandler(metricsService)
 
 	// POST /v1/sync/batch - batch sync
@@ -164,6 +218,57 @@ func (s *Server) routes() {
 	// DELETE /v1/checkins/{id} - delete checkin
 	s.mux.HandleFunc("DELETE /v1/checkins/{id}", checkins.HandleDelete(checkinsService))
 
+	// Checkin templates: typed questions a checkin is filled with
+	s.mux.HandleFunc("GET /v1/checkins/templates", checkins.HandleListTemplates(checkinsService))
+	s.mux.HandleFunc("POST /v1/checkins/templates", checkins.HandleCreateTemplate(checkinsService))
+	s.mux.HandleFunc("PATCH /v1/checkins/templates/{id}", checkins.HandleUpdateTemplate(checkinsService))
+	s.mux.HandleFunc("DELETE /v1/checkins/templates/{id}", checkins.HandleDeleteTemplate(checkinsService))
+	// GET /v1/checkins/answers - answers to one question over time, for charts
+	s.mux.HandleFunc("GET /v1/checkins/answers", checkins.HandleListAnswers(checkinsService))
+
+	// Symptom log, optionally attached to checkins
+	symptomsHandler := symptoms.NewHandler(symptoms.NewService(s.getSymptomsStorage(), s.storage, checkinsStorage))
+	s.mux.HandleFunc("GET /v1/symptoms", symptomsHandler.HandleList)
+	s.mux.HandleFunc("POST /v1/symptoms", symptomsHandler.HandleCreate)
+	// GET /v1/symptoms/summary - frequency, severity and duration per symptom
+	s.mux.HandleFunc("GET /v1/symptoms/summary", symptomsHandler.HandleSummary)
+	s.mux.HandleFunc("GET /v1/symptoms/{id}", symptomsHandler.HandleGet)
+	s.mux.HandleFunc("PATCH /v1/symptoms/{id}", symptomsHandler.HandleUpdate)
+	s.mux.HandleFunc("DELETE /v1/symptoms/{id}", symptomsHandler.HandleDelete)
+
+	// Menstrual cycle: periods, daily flow and symptoms, predictions.
+	// Private by default: shared with the assistant only after opt-in.
+	cycleService := cycle.NewService(s.getCycleStorage(), s.storage, s.storage.(storage.MetricsStorage))
+	cycleHandler := cycle.NewHandler(cycleService)
+	s.mux.HandleFunc("GET /v1/cycle/periods", cycleHandler.HandleListPeriods)
+	s.mux.HandleFunc("POST /v1/cycle/periods", cycleHandler.HandleCreatePeriod)
+	s.mux.HandleFunc("PATCH /v1/cycle/periods/{id}", cycleHandler.HandleUpdatePeriod)
+	s.mux.HandleFunc("DELETE /v1/cycle/periods/{id}", cycleHandler.HandleDeletePeriod)
+	s.mux.HandleFunc("GET /v1/cycle/days", cycleHandler.HandleListDays)
+	s.mux.HandleFunc("PUT /v1/cycle/days", cycleHandler.HandleUpsertDay)
+	s.mux.HandleFunc("DELETE /v1/cycle/days/{date}", cycleHandler.HandleDeleteDay)
+	// GET /v1/cycle/prediction - next period, ovulation and fertile window
+	s.mux.HandleFunc("GET /v1/cycle/prediction", cycleHandler.HandlePrediction)
+	s.mux.HandleFunc("GET /v1/cycle/settings", cycleHandler.HandleGetSettings)
+	s.mux.HandleFunc("PUT /v1/cycle/settings", cycleHandler.HandleUpdateSettings)
+
+	// Medications: dosing, as-needed doses, stock and refills, interaction
+	// warnings against the other medications and supplements.
+	medicationsService := medications.NewService(s.getMedicationsStorage(), s.storage).
+		WithSupplements(s.getSupplementsStorage())
+	medicationsHandler := medications.NewHandler(medicationsService)
+	s.mux.HandleFunc("GET /v1/medications", medicationsHandler.HandleList)
+	s.mux.HandleFunc("POST /v1/medications", medicationsHandler.HandleCreate)
+	// GET /v1/medications/interactions - pairwise check of active medications and supplements
+	s.mux.HandleFunc("GET /v1/medications/interactions", medicationsHandler.HandleInteractions)
+	s.mux.HandleFunc("GET /v1/medications/doses", medicationsHandler.HandleListDoses)
+	s.mux.HandleFunc("DELETE /v1/medications/doses/{id}", medicationsHandler.HandleDeleteDose)
+	s.mux.HandleFunc("GET /v1/medications/{id}", medicationsHandler.HandleGet)
+	s.mux.HandleFunc("PATCH /v1/medications/{id}", medicationsHandler.HandleUpdate)
+	s.mux.HandleFunc("DELETE /v1/medications/{id}", medicationsHandler.HandleDelete)
+	s.mux.HandleFunc("POST /v1/medications/{id}/refill", medicationsHandler.HandleRefill)
+	s.mux.HandleFunc("POST /v1/medications/{id}/doses", medicationsHandler.HandleLogDose)
+
 	// Feed API
 	metricsStorageAdapter := &metricsStorageAdapter{storage: s.storage.(storage.MetricsStorage)}
 	checkinsStorageAdapter := &checkinsStorageAdapter{storage: checkinsStorage}
@@ -183,7 +288,8 @@ func (s *Server) routes() {
 	feedService := feed.NewService(metricsStorageAdapter, checkinsStorageAdapter, profileAdapter, intakesStorageAdapter).
 		WithNutritionTargetsStorage(nutritionTargetsStorageAdapter).
 		WithMealPlansStorage(mealPlansStorageAdapter).
-		WithFoodPrefsStorage(foodPrefsStorageAdapter)
+		WithFoodPrefsStorage(foodPrefsStorageAdapter).
+		WithCycle(&cycleFeedAdapter{service: cycleService})
 
 	// GET /v1/feed/day - day summary
 	s.mux.HandleFunc("GET /v1/feed/day", feed.HandleGetDay(feedService))
@@ -194,8 +300,22 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("GET /v1/settings", settingsHandler.HandleGet)
 	s.mux.HandleFunc("PUT /v1/settings", settingsHandler.HandlePut)
 
+	// AI consent: required before any chat message reaches a provider
+	consentService := consent.NewService(s.getAIConsentStorage(), s.config.AIConsentVersion, s.config.AIRedact)
+	consentHandler := consent.NewHandler(consentService)
+	s.mux.HandleFunc("GET /v1/ai/consent", consentHandler.HandleGet)
+	s.mux.HandleFunc("POST /v1/ai/consent", consentHandler.HandleGrant)
+	s.mux.HandleFunc("DELETE /v1/ai/consent", consentHandler.HandleRevoke)
+
+	// AI token usage: per-owner quotas and the admin spend report
+	usageService := aiusage.NewService(s.getAIUsageStorage(), s.config.AIDailyTokenQuota, s.config.AIMonthlyTokenQuota)
+	usageHandler := aiusage.NewHandler(usageService, s.config)
+	s.mux.HandleFunc("GET /v1/ai/usage", usageHandler.HandleGet)
+	// GET /v1/admin/ai/usage - spend by model across owners (admins only)
+	s.mux.HandleFunc("GET /v1/admin/ai/usage", usageHandler.HandleAdminAggregate)
+
 	// Chat API
-	aiProvider := ai.NewProvider(s.config)
+	aiProvider := ai.NewProvider(s.config, s.telemetry)
 	chatService := chat.NewService(
 		s.getChatStorage(),
 		s.getProposalsStorage(),
@@ -204,9 +324,23 @@ func (s *Server) routes() {
 		settingsService,
 		aiProvider,
 	)
+	chatService.WithAuditRecorder(s.audit).
+		WithHistoryTokenBudget(s.config.ChatHistoryTokenBudget).
+		WithConsentChecker(consentService).
+		WithUsageMeter(usageService).
+		WithProposalTTL(time.Duration(s.config.ProposalTTLDays) * 24 * time.Hour)
 	chatHandler := chat.NewHandler(chatService)
 	s.mux.HandleFunc("GET /v1/chat/messages", chatHandler.HandleListMessages)
 	s.mux.HandleFunc("POST /v1/chat/messages", chatHandler.HandleSendMessage)
+	// POST /v1/chat/messages/stream - same as above, reply streamed over SSE
+	s.mux.HandleFunc("POST /v1/chat/messages/stream", chatHandler.HandleSendMessageStream)
+	s.mux.HandleFunc("GET /v1/chat/threads", chatHandler.HandleListThreads)
+	s.mux.HandleFunc("POST /v1/chat/threads", chatHandler.HandleCreateThread)
+	// PATCH /v1/chat/threads/{id} - rename and/or archive
+	s.mux.HandleFunc("PATCH /v1/chat/threads/{id}", chatHandler.HandleUpdateThread)
+	// Facts the assistant remembers; added by applying "memory" proposals
+	s.mux.HandleFunc("GET /v1/chat/memory", chatHandler.HandleListFacts)
+	s.mux.HandleFunc("DELETE /v1/chat/memory/{id}", chatHandler.HandleDeleteFact)
 
 	// Reports API
 	reportsStorage := s.getReportsStorage()
@@ -223,7 +357,9 @@ func (s *Server) routes() {
 		s.config.Blob.S3.PresignTTLSeconds,
 		s.config.Blob.S3.PublicBaseURL,
 		s.config.Blob.S3.PreferPublicURL,
-	)
+	).WithAuditRecorder(s.audit).
+		WithTelemetry(s.telemetry).
+		WithLabResults(s.getLabResultsStorage())
 	reportsHandler := reports.NewHandlers(reportsService)
 
 	// POST /v1/reports - create report
@@ -241,7 +377,7 @@ func (s *Server) routes() {
 	// Sources API
 	sourcesStorage := s.getSourcesStorage()
 	sourcesProfileAdapter := &sourcesProfileAdapter{storage: s.storage}
-	sourcesService := sources.NewService(
+	s.sources = sources.NewService(
 		sourcesStorage,
 		sourcesProfileAdapter,
 		sourcesBlobStore,
@@ -250,8 +386,25 @@ func (s *Server) routes() {
 		s.config.SourcesMaxPerCheckin,
 		s.config.Blob.S3.PublicBaseURL,
 		s.config.Blob.S3.PreferPublicURL,
-	)
-	sourcesHandler := sources.NewHandlers(sourcesService)
+	).WithAuditRecorder(s.audit).
+		WithDirectUploads(s.getSourceUploadsStorage(), s.config.Blob.S3.PresignTTLSeconds).
+		WithImageProcessor(imageproc.New(s.config.ImageHEICConverter))
+	if s.config.LinkPreviewEnabled {
+		s.sources.WithLinkPreviews(linkpreview.New(linkpreview.Config{
+			Timeout:     time.Duration(s.config.LinkPreviewTimeoutSec) * time.Second,
+			MaxBytes:    int64(s.config.LinkPreviewMaxMB) << 20,
+			ArchiveText: s.config.LinkPreviewArchiveText,
+		}))
+	}
+	s.sources.WithMaxAudioDuration(time.Duration(s.config.AudioMaxSeconds) * time.Second)
+	if transcriber := s.transcriber(); transcriber != nil {
+		s.sources.WithTranscriber(transcriber)
+		if s.config.Speech.Provider == config.SpeechProviderWhisperHTTP {
+			// Recordings leave the server only with the HTTP engine
+			s.sources.WithTranscriptionConsent(consentService)
+		}
+	}
+	sourcesHandler := sources.NewHandlers(s.sources)
 
 	// POST /v1/sources - create link/note source
 	s.mux.HandleFunc("POST /v1/sources", sourcesHandler.HandleCreate)
@@ -259,15 +412,63 @@ func (s *Server) routes() {
 	// POST /v1/sources/image - upload image source
 	s.mux.HandleFunc("POST /v1/sources/image", sourcesHandler.HandleCreateImage)
 
+	// POST /v1/sources/audio - upload voice note (multipart)
+	s.mux.HandleFunc("POST /v1/sources/audio", sourcesHandler.HandleCreateAudio)
+
+	// POST /v1/sources/image/upload-url - presigned PUT for a direct S3 upload
+	s.mux.HandleFunc("POST /v1/sources/image/upload-url", sourcesHandler.HandleCreateUploadURL)
+
+	// POST /v1/sources/image/complete - create the source once the upload is done
+	s.mux.HandleFunc("POST /v1/sources/image/complete", sourcesHandler.HandleCompleteUpload)
+
 	// GET /v1/sources - list sources
 	s.mux.HandleFunc("GET /v1/sources", sourcesHandler.HandleList)
 
-	// GET /v1/sources/{id}/download - download image
+	// GET /v1/sources/{id}/download - download image or voice note
 	s.mux.HandleFunc("GET /v1/sources/{id}/download", sourcesHandler.HandleDownload)
 
+	// GET /v1/sources/{id}/thumbnail - image thumbnail
+	s.mux.HandleFunc("GET /v1/sources/{id}/thumbnail", sourcesHandler.HandleThumbnail)
+
+	// POST /v1/sources/{id}/preview - fetch the link preview again
+	s.mux.HandleFunc("POST /v1/sources/{id}/preview", sourcesHandler.HandleRefreshPreview)
+
+	// GET /v1/sources/{id}/archive - archived text of a link
+	s.mux.HandleFunc("GET /v1/sources/{id}/archive", sourcesHandler.HandleArchive)
+
+	// POST /v1/sources/{id}/transcribe - transcribe a voice note again
+	s.mux.HandleFunc("POST /v1/sources/{id}/transcribe", sourcesHandler.HandleTranscribe)
+
 	// DELETE /v1/sources/{id} - delete source
 	s.mux.HandleFunc("DELETE /v1/sources/{id}", sourcesHandler.HandleDelete)
 
+	// Lab results, entered by hand or read from report photos (sources)
+	labsService := labs.NewService(s.getLabResultsStorage(), s.storage, s.sources, s.labExtractor(aiProvider)).
+		WithNotifications(s.getNotificationsStorage()).
+		WithSupplements(s.getSupplementsStorage(), s.getIntakesStorage())
+	if s.config.LabExtractor == config.LabExtractorAI {
+		// Photos leave the server only with the AI extractor
+		labsService.WithConsentChecker(consentService).WithUsageMeter(usageService)
+	}
+	labsHandler := labs.NewHandler(labsService)
+	// POST /v1/sources/{id}/labs/extract - read lab values from an image source
+	s.mux.HandleFunc("POST /v1/sources/{id}/labs/extract", labsHandler.HandleExtract)
+	s.mux.HandleFunc("GET /v1/sources/{id}/labs", labsHandler.HandleListSource)
+	// GET /v1/labs/results - values of a profile over time, for charts
+	s.mux.HandleFunc("GET /v1/labs/results", labsHandler.HandleListResults)
+	s.mux.HandleFunc("POST /v1/labs/results", labsHandler.HandleCreate)
+	s.mux.HandleFunc("GET /v1/labs/results/{id}", labsHandler.HandleGet)
+	s.mux.HandleFunc("PATCH /v1/labs/results/{id}", labsHandler.HandleUpdate)
+	s.mux.HandleFunc("DELETE /v1/labs/results/{id}", labsHandler.HandleDelete)
+	// GET /v1/labs/analytes - catalog of analytes, units and default ranges
+	s.mux.HandleFunc("GET /v1/labs/analytes", labsHandler.HandleListAnalytes)
+	// GET /v1/labs/correlations - analyte vs supplement intakes of its nutrient
+	s.mux.HandleFunc("GET /v1/labs/correlations", labsHandler.HandleCorrelation)
+
+	// GET /v1/search - full-text search over sources, checkins and chat messages
+	searchHandler := search.NewHandler(search.NewService(s.getSearchStorage(), s.storage))
+	s.mux.HandleFunc("GET /v1/search", searchHandler.HandleSearch)
+
 	// Notifications/Inbox API
 	notificationsStorage := s.getNotificationsStorage()
 	notificationsService := notifications.NewService(
@@ -283,7 +484,8 @@ func (s *Server) routes() {
 		s.getWorkoutCompletionsStorage(),
 	).WithMealPlansStorage(
 		s.getMealPlansStorage(),
-	)
+	).WithCycleReminders(cycleService).
+		WithMedicationReminders(medicationsService)
 	notificationsHandler := notifications.NewHandler(notificationsService)
 
 	// GET /v1/inbox - list notifications
@@ -310,7 +512,8 @@ func (s *Server) routes() {
 		intakesStorage,
 		s.storage,
 		s.config,
-	)
+	).WithSchedulesStorage(supplementSchedulesStorage).
+		WithInteractionChecker(medicationsService)
 	intakesHandler := intakes.NewHandlers(intakesService)
 
 	// Supplement schedules API
@@ -425,16 +628,48 @@ func (s *Server) routes() {
 	// DELETE /v1/meal/plan - delete active meal plan
 	s.mux.HandleFunc("DELETE /v1/meal/plan", mealPlansHandler.HandleDelete)
 
-	// AI Proposals API (after workouts and nutrition to allow all proposal kinds)
-	proposalsService := proposals.NewService(
+	// Chat tools: read access to history (after all read services exist)
+	chatService.WithTools(chat.ToolDeps{
+		Metrics:   metricsService,
+		Checkins:  checkinsService,
+		Intakes:   intakesService,
+		Workouts:  workoutsService,
+		MealPlans: mealPlansService,
+		Nutrition: nutritionService,
+		Cycle:     cycleService,
+	})
+
+	// Coaching programs: goal, milestones and weekly check-ins against daily metrics
+	s.coaching = coaching.NewService(
+		s.getCoachingStorage(),
+		s.storage,
+		s.storage.(storage.MetricsStorage),
+		s.getProposalsStorage(),
+	)
+	coachingHandler := coaching.NewHandler(s.coaching)
+	s.mux.HandleFunc("GET /v1/coaching/programs", coachingHandler.HandleList)
+	s.mux.HandleFunc("POST /v1/coaching/programs", coachingHandler.HandleCreate)
+	// GET /v1/coaching/programs/{id} - program with progress and check-ins
+	s.mux.HandleFunc("GET /v1/coaching/programs/{id}", coachingHandler.HandleGet)
+	s.mux.HandleFunc("POST /v1/coaching/programs/{id}/cancel", coachingHandler.HandleCancel)
+	s.mux.HandleFunc("DELETE /v1/coaching/programs/{id}", coachingHandler.HandleDelete)
+
+	// AI Proposals API (after workouts, nutrition and coaching to allow all proposal kinds)
+	s.proposals = proposals.NewService(
 		s.getProposalsStorage(),
 		s.storage,
 		settingsService,
-	).WithWorkoutService(workoutsService).WithNutritionService(nutritionService).WithMealPlanService(mealPlansService)
-	proposalsHandler := proposals.NewHandler(proposalsService)
+	).WithWorkoutService(workoutsService).WithNutritionService(nutritionService).WithMealPlanService(mealPlansService).
+		WithCoachingService(s.coaching).
+		WithUndoWindow(time.Duration(s.config.ProposalUndoDays) * 24 * time.Hour)
+	proposalsHandler := proposals.NewHandler(s.proposals)
 	s.mux.HandleFunc("GET /v1/ai/proposals", proposalsHandler.HandleList)
+	// GET /v1/ai/proposals/{id} - proposal with status history (records "viewed")
+	s.mux.HandleFunc("GET /v1/ai/proposals/{id}", proposalsHandler.HandleGet)
+	s.mux.HandleFunc("GET /v1/ai/proposals/{id}/preview", proposalsHandler.HandlePreview)
 	s.mux.HandleFunc("POST /v1/ai/proposals/{id}/apply", proposalsHandler.HandleApply)
 	s.mux.HandleFunc("POST /v1/ai/proposals/{id}/reject", proposalsHandler.HandleReject)
+	s.mux.HandleFunc("POST /v1/ai/proposals/{id}/undo", proposalsHandler.HandleUndo)
 }
 
 // getCheckinsStorage returns the checkins storage based on storage type
@@ -541,6 +776,32 @@ func (s *Server) getEmailOTPStorage() storage.EmailOTPStorage {
 	}
 }
 
+// getEmailOTPAbuseStorage returns the email OTP abuse ledger based on storage type.
+func (s *Server) getEmailOTPAbuseStorage() storage.EmailOTPAbuseStorage {
+	switch st := s.storage.(type) {
+	case *memory.MemoryStorage:
+		return st.GetEmailOTPAbuseStorage()
+	case *postgres.PostgresStorage:
+		return st.GetEmailOTPAbuseStorage()
+	default:
+		log.Fatal("unknown storage type")
+		return nil
+	}
+}
+
+// getAuditStorage returns the audit log storage based on storage type.
+func (s *Server) getAuditStorage() storage.AuditStorage {
+	switch st := s.storage.(type) {
+	case *memory.MemoryStorage:
+		return st.GetAuditStorage()
+	case *postgres.PostgresStorage:
+		return st.GetAuditStorage()
+	default:
+		log.Fatal("unknown storage type")
+		return nil
+	}
+}
+
 // getSettingsStorage returns the user settings storage based on storage type.
 func (s *Server) getSettingsStorage() storage.SettingsStorage {
 	switch st := s.storage.(type) {
@@ -640,6 +901,30 @@ func (s *Server) getMealPlansStorage() storage.MealPlansStorage {
 	}
 }
 
+// getAIConsentStorage returns AI consent storage based on storage type.
+func (s *Server) getAIConsentStorage() storage.AIConsentStorage {
+	switch st := s.storage.(type) {
+	case *memory.MemoryStorage:
+		return st.GetAIConsentStorage()
+	case *postgres.PostgresStorage:
+		return st.GetAIConsentStorage()
+	default:
+		panic("unsupported storage type")
+	}
+}
+
+// getAIUsageStorage returns AI token usage storage based on storage type.
+func (s *Server) getAIUsageStorage() storage.AIUsageStorage {
+	switch st := s.storage.(type) {
+	case *memory.MemoryStorage:
+		return st.GetAIUsageStorage()
+	case *postgres.PostgresStorage:
+		return st.GetAIUsageStorage()
+	default:
+		panic("unsupported storage type")
+	}
+}
+
 // getProposalsStorage returns proposals storage based on storage type.
 func (s *Server) getProposalsStorage() storage.ProposalsStorage {
 	switch st := s.storage.(type) {
@@ -653,6 +938,118 @@ func (s *Server) getProposalsStorage() storage.ProposalsStorage {
 	}
 }
 
+// getSourceUploadsStorage returns direct upload storage based on storage type.
+func (s *Server) getSourceUploadsStorage() storage.SourceUploadsStorage {
+	switch st := s.storage.(type) {
+	case *memory.MemoryStorage:
+		return st.GetSourcesStorage()
+	case *postgres.PostgresStorage:
+		return st.GetSourcesStorage()
+	default:
+		panic("unsupported storage type")
+	}
+}
+
+// getCoachingStorage returns coaching programs storage based on storage type.
+func (s *Server) getCoachingStorage() storage.CoachingStorage {
+	switch st := s.storage.(type) {
+	case *memory.MemoryStorage:
+		return st.GetCoachingStorage()
+	case *postgres.PostgresStorage:
+		return st.GetCoachingStorage()
+	default:
+		panic("unsupported storage type")
+	}
+}
+
+// getLabResultsStorage returns lab results storage based on storage type.
+func (s *Server) getLabResultsStorage() storage.LabResultsStorage {
+	switch st := s.storage.(type) {
+	case *memory.MemoryStorage:
+		return st.GetLabResultsStorage()
+	case *postgres.PostgresStorage:
+		return st.GetLabResultsStorage()
+	default:
+		panic("unsupported storage type")
+	}
+}
+
+// getSymptomsStorage returns symptom log storage based on storage type.
+func (s *Server) getSymptomsStorage() storage.SymptomsStorage {
+	switch st := s.storage.(type) {
+	case *memory.MemoryStorage:
+		return st.GetSymptomsStorage()
+	case *postgres.PostgresStorage:
+		return st.GetSymptomsStorage()
+	default:
+		panic("unsupported storage type")
+	}
+}
+
+// getCycleStorage returns menstrual cycle storage based on storage type.
+func (s *Server) getCycleStorage() storage.CycleStorage {
+	switch st := s.storage.(type) {
+	case *memory.MemoryStorage:
+		return st.GetCycleStorage()
+	case *postgres.PostgresStorage:
+		return st.GetCycleStorage()
+	default:
+		panic("unsupported storage type")
+	}
+}
+
+// getMedicationsStorage returns medication storage based on storage type.
+func (s *Server) getMedicationsStorage() storage.MedicationsStorage {
+	switch st := s.storage.(type) {
+	case *memory.MemoryStorage:
+		return st.GetMedicationsStorage()
+	case *postgres.PostgresStorage:
+		return st.GetMedicationsStorage()
+	default:
+		panic("unsupported storage type")
+	}
+}
+
+// getSearchStorage returns full-text search storage based on storage type.
+func (s *Server) getSearchStorage() storage.SearchStorage {
+	switch st := s.storage.(type) {
+	case *memory.MemoryStorage:
+		return st.GetSearchStorage()
+	case *postgres.PostgresStorage:
+		return st.GetSearchStorage()
+	default:
+		panic("unsupported storage type")
+	}
+}
+
+// labExtractor returns the lab report extractor selected by LAB_EXTRACTOR.
+func (s *Server) labExtractor(provider ai.Provider) labs.Extractor {
+	switch s.config.LabExtractor {
+	case config.LabExtractorTesseract:
+		return labs.NewOCRExtractor(s.config.LabOCRCommand)
+	case config.LabExtractorStub:
+		return labs.NewStubExtractor()
+	default:
+		return labs.NewAIExtractor(provider)
+	}
+}
+
+// transcriber returns the speech-to-text engine selected by STT_PROVIDER, or
+// nil when voice notes are kept without transcript.
+func (s *Server) transcriber() speech.Transcriber {
+	cfg := s.config.Speech
+	switch cfg.Provider {
+	case config.SpeechProviderWhisperHTTP:
+		return speech.NewWhisperHTTP(cfg.BaseURL, cfg.APIKey, cfg.Model, cfg.Language, time.Duration(cfg.TimeoutSeconds)*time.Second)
+	case config.SpeechProviderWhisperCpp:
+		return speech.NewWhisperCpp(cfg.WhisperCppCmd, cfg.WhisperModel, cfg.FFmpegCmd, cfg.Language)
+	case config.SpeechProviderStub:
+		return speech.NewStubTranscriber("")
+	default:
+		return nil
+	}
+}
+
 // initBlobStores initializes blob stores for sources and reports.
 // Sources always follow BLOB_MODE, reports may override via REPORTS_MODE.
 func (s *Server) initBlobStores() (sourcesStore blob.Store, reportsStore blob.Store) {
@@ -755,6 +1152,37 @@ func (c *checkinsStorageAdapter) ListCheckins(ctx context.Context, profileID uui
 	return result, nil
 }
 
+// cycleFeedAdapter adapts cycle.Service to feed.CycleReader
+type cycleFeedAdapter struct {
+	service *cycle.Service
+}
+
+func (c *cycleFeedAdapter) GetCycleStatus(ctx context.Context, profileID uuid.UUID, date string) (*feed.CycleStatus, error) {
+	prediction, err := c.service.Predict(ctx, profileID, date)
+	if err != nil || prediction.CurrentCycle == nil {
+		return nil, err
+	}
+	days, err := c.service.ListDays(ctx, profileID, date, date)
+	if err != nil {
+		return nil, err
+	}
+
+	day, _ := time.Parse("2006-01-02", date)
+	next, _ := time.Parse("2006-01-02", prediction.NextPeriod.Expected)
+	status := &feed.CycleStatus{
+		CycleDay:        prediction.CurrentCycle.Day,
+		Phase:           prediction.CurrentCycle.Phase,
+		NextPeriodStart: prediction.NextPeriod.Expected,
+		DaysUntilNext:   int(next.Sub(day).Hours() / 24),
+		FertileWindow:   prediction.CurrentCycle.Phase == cycle.PhaseFertile,
+		Confidence:      prediction.Confidence,
+	}
+	if len(days.Days) > 0 {
+		status.Flow = days.Days[0].Flow
+	}
+	return status, nil
+}
+
 // reportsCheckinsAdapter adapts checkins.Storage to reports.CheckinsStorageAdapter
 type reportsCheckinsAdapter struct {
 	storage checkins.Storage
@@ -940,12 +1368,13 @@ func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// Start запускает HTTP сервер
-func (s *Server) Start() error {
-	addr := fmt.Sprintf(":%d", s.config.Port)
-
-	// Build middleware chain (outermost first): CORS → Rate Limit → Auth → Router
-	var handler http.Handler = s.mux
+// Handler собирает цепочку middleware (outermost first):
+// Request ID + access log → Tracing + HTTP metrics → CORS → Rate Limit → Auth → Audit → Route info → Router
+func (s *Server) Handler() http.Handler {
+	var handler http.Handler = logging.RouteMiddleware(s.mux)
+	if s.audit != nil {
+		handler = s.audit.Middleware(extractIP, handler)
+	}
 	if s.authMiddleware != nil && s.config.AuthMode != "none" {
 		if s.config.AuthRequired {
 			handler = s.authMiddleware.RequireAuth(handler)
@@ -955,6 +1384,35 @@ func (s *Server) Start() error {
 	}
 	handler = RateLimitMiddleware(s.config, handler)
 	handler = CORSMiddleware(s.config, handler)
+	handler = telemetry.Middleware(s.telemetry, handler)
+	handler = logging.Middleware(slog.Default(), extractIP, handler)
+	return handler
+}
+
+// Start запускает HTTP сервер и фоновые задачи
+func (s *Server) Start() error {
+	addr := fmt.Sprintf(":%d", s.config.Port)
+	handler := s.Handler()
+
+	jobsCtx, cancel := context.WithCancel(context.Background())
+	s.stopJobs = cancel
+	if s.audit != nil {
+		go s.audit.RunRetention(jobsCtx, time.Hour)
+	}
+	if s.proposals != nil {
+		go s.proposals.RunExpiry(jobsCtx, 15*time.Minute)
+	}
+	if s.coaching != nil {
+		go s.coaching.RunCheckins(jobsCtx, time.Hour)
+	}
+	if s.sources != nil {
+		go s.sources.RunUploadJanitor(jobsCtx, time.Hour)
+		go s.sources.RunLinkPreviews(jobsCtx, time.Minute)
+		go s.sources.RunTranscriptions(jobsCtx, time.Minute)
+	}
+	if s.config.MetricsAddr != "" {
+		go s.serveMetrics(s.config.MetricsAddr)
+	}
 
 	log.Printf("Сервер запущен на http://localhost%s\n", addr)
 	log.Printf("Health check: http://localhost%s/healthz\n", addr)
@@ -965,6 +1423,9 @@ func (s *Server) Start() error {
 
 // Close закрывает storage и освобождает ресурсы
 func (s *Server) Close() error {
+	if s.stopJobs != nil {
+		s.stopJobs()
+	}
 	if s.storage != nil {
 		return s.storage.Close()
 	}
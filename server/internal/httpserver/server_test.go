@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -77,6 +78,52 @@ func TestAuthRequiredWithoutToken(t *testing.T) {
 	}
 }
 
+func TestErrorBodyCarriesRequestID(t *testing.T) {
+	cfg := &config.Config{
+		Port:         8080,
+		AuthMode:     "dev",
+		AuthEnabled:  true,
+		AuthRequired: true,
+		JWTSecret:    "test-secret",
+		JWTIssuer:    "health-hub-test",
+	}
+	srv := New(cfg)
+	handler := buildServerHandler(srv)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/profiles", nil)
+	req.Header.Set("X-Request-ID", "client-req-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-Request-ID"); got != "client-req-1" {
+		t.Fatalf("expected X-Request-ID to be echoed, got %q", got)
+	}
+
+	var resp struct {
+		Error struct {
+			Code      string `json:"code"`
+			RequestID string `json:"request_id"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error.Code != "unauthorized" || resp.Error.RequestID != "client-req-1" {
+		t.Fatalf("expected request_id in error body, got %+v", resp.Error)
+	}
+
+	// Without a client ID the server generates one.
+	req = httptest.NewRequest(http.MethodGet, "/v1/profiles", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if _, err := uuid.Parse(w.Header().Get("X-Request-ID")); err != nil {
+		t.Fatalf("expected generated uuid request id, got %q", w.Header().Get("X-Request-ID"))
+	}
+}
+
 func TestUserIsolationAcrossTokens(t *testing.T) {
 	cfg := &config.Config{
 		Port:         8080,
@@ -87,7 +134,7 @@ func TestUserIsolationAcrossTokens(t *testing.T) {
 		JWTIssuer:    "health-hub-test",
 	}
 	srv := New(cfg)
-	handler := buildServerHandler(srv, cfg)
+	handler := buildServerHandler(srv)
 
 	tokenA := testJWT(t, cfg.JWTSecret, cfg.JWTIssuer, "userA")
 	tokenB := testJWT(t, cfg.JWTSecret, cfg.JWTIssuer, "userB")
@@ -144,18 +191,8 @@ func TestUserIsolationAcrossTokens(t *testing.T) {
 	}
 }
 
-func buildServerHandler(srv *Server, cfg *config.Config) http.Handler {
-	var handler http.Handler = srv.mux
-	if srv.authMiddleware != nil && cfg.AuthMode != "none" {
-		if cfg.AuthRequired {
-			handler = srv.authMiddleware.RequireAuth(handler)
-		} else {
-			handler = srv.authMiddleware.OptionalAuth(handler)
-		}
-	}
-	handler = RateLimitMiddleware(cfg, handler)
-	handler = CORSMiddleware(cfg, handler)
-	return handler
+func buildServerHandler(srv *Server) http.Handler {
+	return srv.Handler()
 }
 
 func testJWT(t *testing.T, secret, issuer, sub string) string {
@@ -219,3 +256,52 @@ func createGuestProfile(t *testing.T, handler http.Handler, token, name string)
 	}
 	return profile.ID
 }
+
+func TestMetricsEndpointRequiresToken(t *testing.T) {
+	cfg := &config.Config{
+		Port:         8080,
+		AuthMode:     "dev",
+		AuthEnabled:  true,
+		AuthRequired: true,
+		JWTSecret:    "test-secret",
+		JWTIssuer:    "health-hub-test",
+		MetricsToken: "scrape-secret",
+	}
+	srv := New(cfg)
+	handler := buildServerHandler(srv)
+
+	// Generate one request so the route histogram has a sample.
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with wrong token, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape-secret")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 with metrics token, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `health_hub_http_request_duration_seconds_count{method="GET",route="/healthz",status="200"} 1`) {
+		t.Fatalf("expected /healthz latency sample in metrics output")
+	}
+}
+
+func TestMetricsEndpointDisabledWithoutToken(t *testing.T) {
+	cfg := &config.Config{Port: 8080}
+	srv := New(cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 when metrics are not exposed, got %d", w.Code)
+	}
+}
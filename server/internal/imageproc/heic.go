@@ -0,0 +1,315 @@
+package imageproc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// heicConvertTimeout bounds one external HEIC conversion.
+const heicConvertTimeout = 30 * time.Second
+
+// convertHEIC runs `converter in.heic out.jpg` in a temporary directory and
+// returns the JPEG it wrote.
+func convertHEIC(ctx context.Context, converter string, data []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "heic-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.heic")
+	out := filepath.Join(dir, "out.jpg")
+	if err := os.WriteFile(in, data, 0o600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, heicConvertTimeout)
+	defer cancel()
+	if output, err := exec.CommandContext(ctx, converter, in, out).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("heic converter: %v: %s", err, bytes.TrimSpace(output))
+	}
+	return os.ReadFile(out)
+}
+
+// isoBox is a box of an ISO base media file (HEIF is one), with its
+// payload located in the enclosing buffer.
+type isoBox struct {
+	boxType string
+	start   int
+	end     int
+}
+
+var errBadBox = errors.New("malformed box")
+
+func readBoxes(data []byte, start, end int) ([]isoBox, error) {
+	boxes := make([]isoBox, 0, 8)
+	pos := start
+	for pos+8 <= end {
+		size := int64(binary.BigEndian.Uint32(data[pos:]))
+		boxType := string(data[pos+4 : pos+8])
+		header := 8
+		switch size {
+		case 0:
+			size = int64(end - pos)
+		case 1:
+			if pos+16 > end {
+				return nil, errBadBox
+			}
+			size = int64(binary.BigEndian.Uint64(data[pos+8:]))
+			header = 16
+		}
+		if size < int64(header) || size > int64(end-pos) {
+			return nil, errBadBox
+		}
+		boxes = append(boxes, isoBox{boxType: boxType, start: pos + header, end: pos + int(size)})
+		pos += int(size)
+	}
+	return boxes, nil
+}
+
+func findBox(boxes []isoBox, boxType string) (isoBox, bool) {
+	for _, box := range boxes {
+		if box.boxType == boxType {
+			return box, true
+		}
+	}
+	return isoBox{}, false
+}
+
+// stripHEIC zeroes the Exif and XMP items of a HEIC file in place, which
+// keeps every offset in the file valid. The image items are untouched.
+func stripHEIC(data []byte) ([]byte, error) {
+	clean, err := blankHEICMetadata(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	return clean, nil
+}
+
+func blankHEICMetadata(data []byte) ([]byte, error) {
+	top, err := readBoxes(data, 0, len(data))
+	if err != nil {
+		return nil, err
+	}
+	if ftyp, ok := findBox(top, "ftyp"); !ok || ftyp.start != 8 {
+		return nil, errors.New("missing ftyp box")
+	}
+	meta, ok := findBox(top, "meta")
+	if !ok || meta.end-meta.start < 4 {
+		return nil, errors.New("missing meta box")
+	}
+	// meta is a full box: version and flags precede its children.
+	children, err := readBoxes(data, meta.start+4, meta.end)
+	if err != nil {
+		return nil, err
+	}
+
+	iinf, ok := findBox(children, "iinf")
+	if !ok {
+		return nil, errors.New("missing iinf box")
+	}
+	metadataItems, err := readMetadataItems(data, iinf)
+	if err != nil {
+		return nil, err
+	}
+	if len(metadataItems) == 0 {
+		return data, nil
+	}
+
+	iloc, ok := findBox(children, "iloc")
+	if !ok {
+		return nil, errors.New("missing iloc box")
+	}
+	extents, err := readItemExtents(data, iloc, metadataItems)
+	if err != nil {
+		return nil, err
+	}
+
+	idat, hasIdat := findBox(children, "idat")
+	clean := bytes.Clone(data)
+	for _, extent := range extents {
+		base, limit := 0, len(clean)
+		if extent.inIdat {
+			if !hasIdat {
+				return nil, errors.New("missing idat box")
+			}
+			base, limit = idat.start, idat.end
+		}
+		start := base + int(extent.offset)
+		end := limit
+		if extent.length > 0 {
+			end = start + int(extent.length)
+		}
+		if extent.offset < 0 || start > limit || end > limit || end < start {
+			return nil, errors.New("item extent out of range")
+		}
+		clear(clean[start:end])
+	}
+	return clean, nil
+}
+
+// readMetadataItems returns the IDs of Exif and XMP items listed in iinf.
+func readMetadataItems(data []byte, iinf isoBox) (map[uint32]bool, error) {
+	if iinf.end-iinf.start < 6 {
+		return nil, errBadBox
+	}
+	pos := iinf.start + 4
+	if data[iinf.start] == 0 {
+		pos += 2
+	} else {
+		pos += 4
+	}
+	entries, err := readBoxes(data, pos, iinf.end)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[uint32]bool)
+	for _, entry := range entries {
+		if entry.boxType != "infe" || entry.end-entry.start < 4 {
+			continue
+		}
+		version := data[entry.start]
+		p := entry.start + 4
+		var id uint32
+		switch version {
+		case 2:
+			if p+8 > entry.end {
+				return nil, errBadBox
+			}
+			id = uint32(binary.BigEndian.Uint16(data[p:]))
+			p += 2
+		case 3:
+			if p+10 > entry.end {
+				return nil, errBadBox
+			}
+			id = binary.BigEndian.Uint32(data[p:])
+			p += 4
+		default:
+			// Versions 0 and 1 predate item types and are not used by HEIF.
+			continue
+		}
+		p += 2 // item_protection_index
+		itemType := string(data[p : p+4])
+		p += 4
+
+		switch itemType {
+		case "Exif":
+			items[id] = true
+		case "mime":
+			// item_name, then content_type, both null-terminated.
+			fields := bytes.SplitN(data[p:entry.end], []byte{0}, 3)
+			if len(fields) >= 2 && bytes.HasPrefix(fields[1], []byte("application/rdf+xml")) {
+				items[id] = true
+			}
+		}
+	}
+	return items, nil
+}
+
+type itemExtent struct {
+	inIdat bool
+	offset int64
+	length int64
+}
+
+// readItemExtents returns where the data of the given items is stored,
+// according to iloc versions 0-2.
+func readItemExtents(data []byte, iloc isoBox, items map[uint32]bool) ([]itemExtent, error) {
+	r := boxReader{data: data, pos: iloc.start, end: iloc.end}
+	version := r.uint(1)
+	r.skip(3)
+	sizes := r.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0x0F)
+	sizes = r.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), int(sizes&0x0F)
+	if version == 0 {
+		indexSize = 0
+	}
+	var itemCount uint64
+	if version < 2 {
+		itemCount = r.uint(2)
+	} else {
+		itemCount = r.uint(4)
+	}
+
+	extents := make([]itemExtent, 0)
+	for i := uint64(0); i < itemCount && r.err == nil; i++ {
+		var id uint64
+		if version < 2 {
+			id = r.uint(2)
+		} else {
+			id = r.uint(4)
+		}
+		construction := uint64(0)
+		if version > 0 {
+			construction = r.uint(2) & 0x0F
+		}
+		r.skip(2) // data_reference_index
+		baseOffset := int64(r.uint(baseOffsetSize))
+		extentCount := r.uint(2)
+		for j := uint64(0); j < extentCount && r.err == nil; j++ {
+			r.skip(indexSize)
+			offset := int64(r.uint(offsetSize))
+			length := int64(r.uint(lengthSize))
+			if !items[uint32(id)] {
+				continue
+			}
+			if construction > 1 {
+				return nil, fmt.Errorf("unsupported construction method %d", construction)
+			}
+			extents = append(extents, itemExtent{
+				inIdat: construction == 1,
+				offset: baseOffset + offset,
+				length: length,
+			})
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return extents, nil
+}
+
+// boxReader reads big-endian fields of 0-8 bytes, remembering the first
+// overrun.
+type boxReader struct {
+	data []byte
+	pos  int
+	end  int
+	err  error
+}
+
+func (r *boxReader) uint(size int) uint64 {
+	if r.err != nil {
+		return 0
+	}
+	if size > 8 || r.pos+size > r.end {
+		r.err = errBadBox
+		return 0
+	}
+	var value uint64
+	for _, b := range r.data[r.pos : r.pos+size] {
+		value = value<<8 | uint64(b)
+	}
+	r.pos += size
+	return value
+}
+
+func (r *boxReader) skip(size int) {
+	if r.err != nil {
+		return
+	}
+	if r.pos+size > r.end {
+		r.err = errBadBox
+		return
+	}
+	r.pos += size
+}
@@ -0,0 +1,244 @@
+// Package imageproc prepares uploaded photos for storage: it removes
+// metadata (EXIF with GPS, XMP, comments), applies the EXIF orientation,
+// converts HEIC to JPEG when a converter is configured, re-encodes GIF and
+// WebP as PNG and renders JPEG thumbnails.
+package imageproc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ErrInvalidImage is returned for data that does not parse as the declared
+// image type, or whose metadata cannot be removed reliably.
+var ErrInvalidImage = errors.New("invalid image")
+
+// ThumbnailSizes are the longest sides, in pixels, thumbnails are rendered at.
+var ThumbnailSizes = []int{256, 512, 1024}
+
+const (
+	// maxPixels rejects decompression bombs before decoding.
+	maxPixels = 50_000_000
+
+	rotatedQuality   = 90
+	thumbnailQuality = 80
+)
+
+// Processor runs the processing stage for image uploads.
+type Processor struct {
+	heicConverter string
+}
+
+// New creates a Processor. heicConverter is a command called as
+// `<heicConverter> in.heic out.jpg` (heif-convert from libheif fits); when
+// empty, HEIC files are kept as HEIC with their metadata blanked and get
+// no thumbnails.
+func New(heicConverter string) *Processor {
+	return &Processor{heicConverter: strings.TrimSpace(heicConverter)}
+}
+
+// Result is the image to store in place of the upload.
+type Result struct {
+	Data        []byte
+	ContentType string
+	Thumbnails  []Thumbnail
+}
+
+// Thumbnail is a JPEG whose longest side is at most Size pixels.
+type Thumbnail struct {
+	Size int
+	Data []byte
+}
+
+// Sizes lists the sizes of the rendered thumbnails.
+func (r Result) Sizes() []int {
+	sizes := make([]int, 0, len(r.Thumbnails))
+	for _, thumb := range r.Thumbnails {
+		sizes = append(sizes, thumb.Size)
+	}
+	return sizes
+}
+
+// Process strips metadata from data and renders thumbnails. Other types the
+// server can decode (GIF, WebP) are re-encoded as PNG; the rest are
+// rejected, since their metadata cannot be removed.
+func (p *Processor) Process(ctx context.Context, data []byte, contentType string) (Result, error) {
+	switch strings.ToLower(strings.TrimSpace(contentType)) {
+	case "image/jpeg", "image/jpg":
+		return processJPEG(data)
+	case "image/png":
+		return processPNG(data)
+	case "image/heic", "image/heif":
+		if p.heicConverter == "" {
+			clean, err := stripHEIC(data)
+			if err != nil {
+				return Result{}, err
+			}
+			return Result{Data: clean, ContentType: contentType}, nil
+		}
+		converted, err := convertHEIC(ctx, p.heicConverter, data)
+		if err != nil {
+			return Result{}, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+		}
+		return processJPEG(converted)
+	default:
+		return reencodePNG(data)
+	}
+}
+
+// Render draws a thumbnail of a stored (already processed) JPEG or PNG.
+func Render(data []byte, size int) ([]byte, error) {
+	img, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return thumbnail(img, size)
+}
+
+// Preview re-encodes an image fetched from the web (JPEG, PNG, GIF or WebP)
+// as a JPEG no larger than the biggest thumbnail size, dropping whatever
+// metadata it carried, and renders its thumbnails.
+func Preview(data []byte) (Result, error) {
+	img, err := decode(data)
+	if err != nil {
+		return Result{}, err
+	}
+	preview, err := thumbnail(img, ThumbnailSizes[len(ThumbnailSizes)-1])
+	if err != nil {
+		return Result{}, err
+	}
+	thumbs, err := thumbnails(img)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Data: preview, ContentType: "image/jpeg", Thumbnails: thumbs}, nil
+}
+
+func processJPEG(data []byte) (Result, error) {
+	segments, scan, err := splitJPEG(data)
+	if err != nil {
+		return Result{}, err
+	}
+	clean := joinJPEG(segments, scan)
+
+	img, err := decode(clean)
+	if err != nil {
+		return Result{}, err
+	}
+	// Rotating means re-encoding; upright photos keep their original pixels.
+	if orientation := jpegOrientation(segments); orientation > 1 {
+		img = orient(img, orientation)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: rotatedQuality}); err != nil {
+			return Result{}, err
+		}
+		clean = buf.Bytes()
+	}
+
+	thumbs, err := thumbnails(img)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Data: clean, ContentType: "image/jpeg", Thumbnails: thumbs}, nil
+}
+
+func processPNG(data []byte) (Result, error) {
+	clean, err := stripPNG(data)
+	if err != nil {
+		return Result{}, err
+	}
+	img, err := decode(clean)
+	if err != nil {
+		return Result{}, err
+	}
+	thumbs, err := thumbnails(img)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Data: clean, ContentType: "image/png", Thumbnails: thumbs}, nil
+}
+
+// reencodePNG stores only the pixels of an image, dropping whatever
+// metadata came with it. Animated GIFs keep their first frame.
+func reencodePNG(data []byte) (Result, error) {
+	img, err := decode(data)
+	if err != nil {
+		return Result{}, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return Result{}, err
+	}
+	thumbs, err := thumbnails(img)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Data: buf.Bytes(), ContentType: "image/png", Thumbnails: thumbs}, nil
+}
+
+func decode(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels", ErrInvalidImage, cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	return img, nil
+}
+
+// thumbnails renders every size smaller than the image, and always the
+// smallest one so small photos still get a thumbnail.
+func thumbnails(img image.Image) ([]Thumbnail, error) {
+	longest := max(img.Bounds().Dx(), img.Bounds().Dy())
+	thumbs := make([]Thumbnail, 0, len(ThumbnailSizes))
+	for i, size := range ThumbnailSizes {
+		if i > 0 && size >= longest {
+			break
+		}
+		data, err := thumbnail(img, size)
+		if err != nil {
+			return nil, err
+		}
+		thumbs = append(thumbs, Thumbnail{Size: size, Data: data})
+	}
+	return thumbs, nil
+}
+
+// thumbnail scales img down to fit size (never up) over a white background,
+// since JPEG has no transparency.
+func thumbnail(img image.Image, size int) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,304 @@
+package imageproc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// twoTone is w×h, red on the left half and blue on the right.
+func twoTone(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// exifSegment builds an APP1 segment with an orientation tag and a GPS IFD.
+func exifSegment(orientation uint16) []byte {
+	le := binary.LittleEndian
+	tiff := []byte("II*\x00")
+	tiff = le.AppendUint32(tiff, 8)
+	// IFD0: Orientation, GPSInfo pointer.
+	tiff = le.AppendUint16(tiff, 2)
+	tiff = le.AppendUint16(tiff, 0x0112)
+	tiff = le.AppendUint16(tiff, 3)
+	tiff = le.AppendUint32(tiff, 1)
+	tiff = le.AppendUint16(tiff, orientation)
+	tiff = le.AppendUint16(tiff, 0)
+	tiff = le.AppendUint16(tiff, 0x8825)
+	tiff = le.AppendUint16(tiff, 4)
+	tiff = le.AppendUint32(tiff, 1)
+	tiff = le.AppendUint32(tiff, 8+2+2*12+4)
+	tiff = le.AppendUint32(tiff, 0)
+	// GPS IFD: GPSLatitudeRef "N".
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = le.AppendUint16(tiff, 0x0001)
+	tiff = le.AppendUint16(tiff, 2)
+	tiff = le.AppendUint32(tiff, 2)
+	tiff = append(tiff, 'N', 0, 0, 0)
+	tiff = le.AppendUint32(tiff, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, markerAPP1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func jpegWithEXIF(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	out := append([]byte{}, data[:2]...)
+	out = append(out, exifSegment(orientation)...)
+	out = append(out, 0xFF, markerCOM, 0x00, 0x07, 'h', 'e', 'l', 'l', 'o')
+	return append(out, data[2:]...)
+}
+
+func TestProcessJPEGStripsMetadataAndKeepsUprightPixels(t *testing.T) {
+	data := jpegWithEXIF(t, twoTone(64, 32), 1)
+
+	result, err := New("").Process(context.Background(), data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if bytes.Contains(result.Data, []byte("Exif")) || bytes.Contains(result.Data, []byte("hello")) {
+		t.Fatal("EXIF and comments must be removed")
+	}
+	// Upright photos are not re-encoded: the scan data stays byte-identical.
+	if !bytes.HasSuffix(data, result.Data[2:]) {
+		t.Fatal("Expected original image data to be kept")
+	}
+	if result.ContentType != "image/jpeg" {
+		t.Fatalf("Expected image/jpeg, got %s", result.ContentType)
+	}
+	if sizes := result.Sizes(); len(sizes) != 1 || sizes[0] != 256 {
+		t.Fatalf("Expected a single 256 thumbnail for a small image, got %v", sizes)
+	}
+}
+
+func app2Segment(payload string) []byte {
+	segment := []byte{0xFF, markerAPP2}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func TestProcessJPEGDropsMPFAndTrailer(t *testing.T) {
+	data := jpegWithEXIF(t, twoTone(64, 32), 1)
+	icc := app2Segment("ICC_PROFILE\x00\x01\x01profile")
+	mpf := app2Segment("MPF\x00index")
+	// A secondary image with its own EXIF, as phones append after EOI.
+	secondary := jpegWithEXIF(t, twoTone(16, 16), 1)
+
+	withMeta := append([]byte{}, data[:2]...)
+	withMeta = append(withMeta, icc...)
+	withMeta = append(withMeta, mpf...)
+	withMeta = append(withMeta, data[2:len(data)-2]...)
+	// A comment between the scan and EOI, where progressive JPEGs may carry
+	// APPn and COM segments between scans.
+	withMeta = append(withMeta, 0xFF, markerCOM, 0x00, 0x09, 'l', 'a', 't', 'e', 'r', '!', '!')
+	withMeta = append(withMeta, 0xFF, markerEOI)
+	withMeta = append(withMeta, secondary...)
+
+	result, err := New("").Process(context.Background(), withMeta, "image/jpeg")
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	for _, leak := range []string{"MPF", "Exif", "later", "hello"} {
+		if bytes.Contains(result.Data, []byte(leak)) {
+			t.Errorf("Expected %q to be removed", leak)
+		}
+	}
+	if !bytes.Contains(result.Data, []byte("ICC_PROFILE")) {
+		t.Error("Expected the ICC profile to be kept")
+	}
+	if !bytes.HasSuffix(result.Data, []byte{0xFF, markerEOI}) || bytes.Count(result.Data, []byte{0xFF, markerSOI}) != 1 {
+		t.Error("Expected the output to end at the first EOI")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(result.Data)); err != nil {
+		t.Fatalf("Expected a valid JPEG: %v", err)
+	}
+}
+
+func TestProcessJPEGAppliesOrientation(t *testing.T) {
+	data := jpegWithEXIF(t, twoTone(40, 20), 6)
+
+	result, err := New("").Process(context.Background(), data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 20 || b.Dy() != 40 {
+		t.Fatalf("Expected 20x40 after rotation, got %dx%d", b.Dx(), b.Dy())
+	}
+	// A clockwise turn moves the red left half to the top.
+	if r, _, b, _ := img.At(10, 5).RGBA(); r < b {
+		t.Fatal("Expected red at the top")
+	}
+	if r, _, b, _ := img.At(10, 35).RGBA(); b < r {
+		t.Fatal("Expected blue at the bottom")
+	}
+	if bytes.Contains(result.Data, []byte("Exif")) {
+		t.Fatal("EXIF must be removed")
+	}
+}
+
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func TestProcessPNGDropsTextChunks(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, twoTone(1200, 600)); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// Insert tEXt and eXIf chunks right after IHDR (8 + 25 bytes).
+	withMeta := append([]byte{}, data[:33]...)
+	withMeta = append(withMeta, pngChunk("tEXt", []byte("Location\x0055.75,37.61"))...)
+	withMeta = append(withMeta, pngChunk("eXIf", []byte("II*\x00"))...)
+	withMeta = append(withMeta, data[33:]...)
+
+	result, err := New("").Process(context.Background(), withMeta, "image/png")
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if !bytes.Equal(result.Data, data) {
+		t.Fatal("Expected metadata chunks to be removed and the rest kept")
+	}
+	if sizes := result.Sizes(); len(sizes) != 3 {
+		t.Fatalf("Expected all thumbnail sizes for a large image, got %v", sizes)
+	}
+	thumb, err := jpeg.Decode(bytes.NewReader(result.Thumbnails[0].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 256 || b.Dy() != 128 {
+		t.Fatalf("Expected 256x128 thumbnail, got %dx%d", b.Dx(), b.Dy())
+	}
+}
+
+func TestProcessReencodesGIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, twoTone(600, 300), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// Insert a comment extension before the trailer.
+	comment := "Location 55.75,37.61"
+	withMeta := append([]byte{}, data[:len(data)-1]...)
+	withMeta = append(withMeta, 0x21, 0xFE, byte(len(comment)))
+	withMeta = append(withMeta, comment...)
+	withMeta = append(withMeta, 0x00, 0x3B)
+
+	result, err := New("").Process(context.Background(), withMeta, "image/gif")
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if result.ContentType != "image/png" || bytes.Contains(result.Data, []byte(comment)) {
+		t.Fatalf("Expected a PNG without the comment, got %s", result.ContentType)
+	}
+	img, err := png.Decode(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 600 || b.Dy() != 300 {
+		t.Fatalf("Expected 600x300 image, got %dx%d", b.Dx(), b.Dy())
+	}
+	if sizes := result.Sizes(); len(sizes) != 2 {
+		t.Fatalf("Expected 256 and 512 thumbnails, got %v", sizes)
+	}
+}
+
+func TestProcessRejectsInvalidImages(t *testing.T) {
+	for _, contentType := range []string{"image/jpeg", "image/png", "image/heic", "image/webp", "image/tiff"} {
+		_, err := New("").Process(context.Background(), []byte("not an image"), contentType)
+		if !errors.Is(err, ErrInvalidImage) {
+			t.Errorf("%s: expected ErrInvalidImage, got %v", contentType, err)
+		}
+	}
+}
+
+// heicBox builds an ISO BMFF box.
+func heicBox(boxType string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	box := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(box, boxType...), body...)
+}
+
+func TestStripHEICBlanksExifItem(t *testing.T) {
+	be := binary.BigEndian
+	infe := func(id uint16, itemType string) []byte {
+		payload := []byte{2, 0, 0, 0}
+		payload = be.AppendUint16(payload, id)
+		payload = be.AppendUint16(payload, 0)
+		return heicBox("infe", append(payload, itemType+"\x00"...))
+	}
+	iinf := heicBox("iinf", []byte{0, 0, 0, 0, 0, 2}, infe(1, "hvc1"), infe(2, "Exif"))
+
+	exif := []byte("\x00\x00\x00\x00Exif\x00\x00II*\x00GPS")
+	pixels := []byte("pixel-data")
+	iloc := func(mdatStart uint32) []byte {
+		payload := []byte{0, 0, 0, 0, 0x44, 0x00}
+		payload = be.AppendUint16(payload, 2)
+		for i, item := range [][]byte{pixels, exif} {
+			payload = be.AppendUint16(payload, uint16(i+1))
+			payload = be.AppendUint16(payload, 0)
+			payload = be.AppendUint16(payload, 1)
+			offset := mdatStart
+			if i == 1 {
+				offset += uint32(len(pixels))
+			}
+			payload = be.AppendUint32(payload, offset)
+			payload = be.AppendUint32(payload, uint32(len(item)))
+		}
+		return heicBox("iloc", payload)
+	}
+
+	ftyp := heicBox("ftyp", []byte("heic\x00\x00\x00\x00mif1heic"))
+	metaLen := len(heicBox("meta", []byte{0, 0, 0, 0}, iinf, iloc(0)))
+	mdatStart := uint32(len(ftyp) + metaLen + 8)
+	file := bytes.Join([][]byte{
+		ftyp,
+		heicBox("meta", []byte{0, 0, 0, 0}, iinf, iloc(mdatStart)),
+		heicBox("mdat", pixels, exif),
+	}, nil)
+
+	result, err := New("").Process(context.Background(), file, "image/heic")
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if len(result.Data) != len(file) || len(result.Thumbnails) != 0 {
+		t.Fatalf("Expected same-size HEIC without thumbnails")
+	}
+	if bytes.Contains(result.Data, []byte("GPS")) {
+		t.Fatal("Exif item must be blanked")
+	}
+	if !bytes.Contains(result.Data, pixels) {
+		t.Fatal("Image item must be kept")
+	}
+}
@@ -0,0 +1,220 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	markerSOI   = 0xD8
+	markerEOI   = 0xD9
+	markerSOS   = 0xDA
+	markerAPP0  = 0xE0
+	markerAPP1  = 0xE1
+	markerAPP2  = 0xE2
+	markerAPP14 = 0xEE
+	markerAPP15 = 0xEF
+	markerCOM   = 0xFE
+)
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	iccHeader  = []byte("ICC_PROFILE\x00")
+)
+
+// jpegSegment is one marker segment before the scan, including the marker
+// and length bytes.
+type jpegSegment struct {
+	marker byte
+	raw    []byte
+}
+
+func (s jpegSegment) payload() []byte {
+	return s.raw[4:]
+}
+
+// splitJPEG returns the header segments and the image data from the first
+// SOS marker through the first EOI, cleaned by cleanScan.
+func splitJPEG(data []byte) ([]jpegSegment, []byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != markerSOI {
+		return nil, nil, fmt.Errorf("%w: missing JPEG SOI marker", ErrInvalidImage)
+	}
+
+	segments := make([]jpegSegment, 0, 8)
+	pos := 2
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			return nil, nil, fmt.Errorf("%w: bad JPEG marker at %d", ErrInvalidImage, pos)
+		}
+		// Markers may be preceded by any number of 0xFF fill bytes.
+		for pos < len(data) && data[pos] == 0xFF {
+			pos++
+		}
+		if pos >= len(data) {
+			break
+		}
+		marker := data[pos]
+		start := pos - 1
+		if marker == markerSOS {
+			scan, err := cleanScan(data[start:])
+			if err != nil {
+				return nil, nil, err
+			}
+			return segments, scan, nil
+		}
+		if pos+2 >= len(data) {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+1:]))
+		end := pos + 1 + length
+		if length < 2 || end > len(data) {
+			return nil, nil, fmt.Errorf("%w: truncated JPEG segment", ErrInvalidImage)
+		}
+		segments = append(segments, jpegSegment{marker: marker, raw: data[start:end]})
+		pos = end
+	}
+	return nil, nil, fmt.Errorf("%w: JPEG has no image data", ErrInvalidImage)
+}
+
+// cleanScan copies the scans and the tables between them up to and
+// including the first EOI. APPn and COM segments between progressive scans
+// are dropped, and whatever follows EOI is cut: phones append MPF secondary
+// images (gain maps, depth) there, each with its own EXIF and GPS.
+func cleanScan(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	pos := 0
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			return nil, fmt.Errorf("%w: bad JPEG marker in scan data", ErrInvalidImage)
+		}
+		for pos < len(data) && data[pos] == 0xFF {
+			pos++
+		}
+		if pos >= len(data) {
+			break
+		}
+		marker := data[pos]
+		start := pos - 1
+		if marker == markerEOI {
+			buf.Write([]byte{0xFF, markerEOI})
+			return buf.Bytes(), nil
+		}
+		if pos+2 >= len(data) {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+1:]))
+		end := pos + 1 + length
+		if length < 2 || end > len(data) {
+			return nil, fmt.Errorf("%w: truncated JPEG segment", ErrInvalidImage)
+		}
+		pos = end
+		if isMetadataMarker(marker) {
+			continue
+		}
+		buf.Write(data[start:end])
+		if marker == markerSOS {
+			scanEnd := entropyEnd(data, pos)
+			buf.Write(data[pos:scanEnd])
+			pos = scanEnd
+		}
+	}
+	return nil, fmt.Errorf("%w: JPEG has no EOI marker", ErrInvalidImage)
+}
+
+// entropyEnd returns where the entropy-coded data starting at pos ends: at
+// the first marker other than byte stuffing (FF 00) and restart markers.
+func entropyEnd(data []byte, pos int) int {
+	for ; pos+1 < len(data); pos++ {
+		if data[pos] != 0xFF {
+			continue
+		}
+		next := data[pos+1]
+		if next == 0x00 || next == 0xFF || (next >= 0xD0 && next <= 0xD7) {
+			continue
+		}
+		return pos
+	}
+	return len(data)
+}
+
+func isMetadataMarker(marker byte) bool {
+	return (marker >= markerAPP0 && marker <= markerAPP15) || marker == markerCOM
+}
+
+// keepSegment reports whether a header segment survives stripping. JFIF
+// (APP0), ICC profiles (APP2 with an ICC_PROFILE payload) and Adobe color
+// info (APP14) affect how the image renders; EXIF, XMP, IPTC, the MPF
+// index (also APP2), vendor blocks and comments do not.
+func keepSegment(segment jpegSegment) bool {
+	switch {
+	case segment.marker == markerAPP0, segment.marker == markerAPP14:
+		return true
+	case segment.marker == markerAPP2:
+		return bytes.HasPrefix(segment.payload(), iccHeader)
+	default:
+		return !isMetadataMarker(segment.marker)
+	}
+}
+
+func joinJPEG(segments []jpegSegment, scan []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0xFF, markerSOI})
+	for _, segment := range segments {
+		if keepSegment(segment) {
+			buf.Write(segment.raw)
+		}
+	}
+	buf.Write(scan)
+	return buf.Bytes()
+}
+
+// jpegOrientation reads the EXIF orientation tag (1-8) from IFD0, returning
+// 1 when there is none.
+func jpegOrientation(segments []jpegSegment) int {
+	for _, segment := range segments {
+		if segment.marker != markerAPP1 || !bytes.HasPrefix(segment.payload(), exifHeader) {
+			continue
+		}
+		if orientation := exifOrientation(segment.payload()[len(exifHeader):]); orientation > 0 {
+			return orientation
+		}
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) != 0x0112 {
+			continue
+		}
+		orientation := int(order.Uint16(tiff[entry+8:]))
+		if orientation < 1 || orientation > 8 {
+			return 0
+		}
+		return orientation
+	}
+	return 0
+}
@@ -0,0 +1,47 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// droppedPNGChunks carry metadata only: EXIF, text (including XMP, which is
+// stored in iTXt) and the modification time.
+var droppedPNGChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// stripPNG copies data without metadata chunks and without anything
+// appended after IEND.
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("%w: missing PNG signature", ErrInvalidImage)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	pos := len(pngSignature)
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, fmt.Errorf("%w: truncated PNG chunk", ErrInvalidImage)
+		}
+		if !droppedPNGChunks[chunkType] {
+			buf.Write(data[pos:end])
+		}
+		if chunkType == "IEND" {
+			return buf.Bytes(), nil
+		}
+		pos = end
+	}
+	return nil, fmt.Errorf("%w: PNG has no IEND chunk", ErrInvalidImage)
+}
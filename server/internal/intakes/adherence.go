@@ -0,0 +1,130 @@
+package intakes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+// SupplementAdherenceDTO — соблюдение приёма одной добавки за период.
+type SupplementAdherenceDTO struct {
+	SupplementID uuid.UUID `json:"supplement_id"`
+	Name         string    `json:"name"`
+	ExpectedDays int       `json:"expected_days"`
+	TakenDays    int       `json:"taken_days"`
+	SkippedDays  int       `json:"skipped_days"`
+}
+
+type SupplementAdherenceResponse struct {
+	From        string                   `json:"from"`
+	To          string                   `json:"to"`
+	Supplements []SupplementAdherenceDTO `json:"supplements"`
+}
+
+// WithSchedulesStorage lets adherence count only scheduled days.
+func (s *Service) WithSchedulesStorage(schedules storage.SupplementSchedulesStorage) *Service {
+	s.schedulesStorage = schedules
+	return s
+}
+
+// GetSupplementAdherence counts taken/skipped days per supplement in
+// [from, to]. Expected days follow enabled schedules (days_mask) when
+// schedules are available, otherwise every day of the range.
+func (s *Service) GetSupplementAdherence(ctx context.Context, profileID uuid.UUID, from, to string) (*SupplementAdherenceResponse, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, fmt.Errorf("profile_not_found")
+	}
+
+	fromDate, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid_date")
+	}
+	toDate, err := time.Parse("2006-01-02", to)
+	if err != nil || toDate.Before(fromDate) {
+		return nil, fmt.Errorf("invalid_date")
+	}
+
+	supplements, err := s.supplementsStorage.ListSupplements(ctx, profileID)
+	if err != nil {
+		return nil, err
+	}
+	intakes, err := s.intakesStorage.ListSupplementIntakes(ctx, profileID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	masks, err := s.scheduleMasks(ctx, profileID)
+	if err != nil {
+		return nil, err
+	}
+
+	// One status per supplement and day; "taken" wins over "skipped".
+	statuses := make(map[uuid.UUID]map[string]string, len(supplements))
+	for _, intake := range intakes {
+		byDay, ok := statuses[intake.SupplementID]
+		if !ok {
+			byDay = make(map[string]string)
+			statuses[intake.SupplementID] = byDay
+		}
+		day := intake.TakenAt.UTC().Format("2006-01-02")
+		if byDay[day] != "taken" {
+			byDay[day] = intake.Status
+		}
+	}
+
+	resp := &SupplementAdherenceResponse{
+		From:        from,
+		To:          to,
+		Supplements: make([]SupplementAdherenceDTO, 0, len(supplements)),
+	}
+	for _, sup := range supplements {
+		item := SupplementAdherenceDTO{SupplementID: sup.ID, Name: sup.Name}
+		mask, scheduled := masks[sup.ID]
+		for d := fromDate; !d.After(toDate); d = d.AddDate(0, 0, 1) {
+			if !scheduled || mask&(1<<mondayIndex(d)) != 0 {
+				item.ExpectedDays++
+			}
+		}
+		for _, status := range statuses[sup.ID] {
+			switch status {
+			case "taken":
+				item.TakenDays++
+			case "skipped":
+				item.SkippedDays++
+			}
+		}
+		resp.Supplements = append(resp.Supplements, item)
+	}
+	return resp, nil
+}
+
+// scheduleMasks merges enabled schedules into one days_mask per supplement.
+func (s *Service) scheduleMasks(ctx context.Context, profileID uuid.UUID) (map[uuid.UUID]int, error) {
+	masks := make(map[uuid.UUID]int)
+	if s.schedulesStorage == nil {
+		return masks, nil
+	}
+	userID, ok := userctx.GetUserID(ctx)
+	if !ok || strings.TrimSpace(userID) == "" {
+		return masks, nil
+	}
+	schedules, err := s.schedulesStorage.ListSchedules(ctx, userID, profileID)
+	if err != nil {
+		return nil, err
+	}
+	for _, sch := range schedules {
+		if sch.IsEnabled {
+			masks[sch.SupplementID] |= sch.DaysMask
+		}
+	}
+	return masks, nil
+}
+
+// mondayIndex maps a date to the days_mask bit: Monday = 0 ... Sunday = 6.
+func mondayIndex(d time.Time) int {
+	return (int(d.Weekday()) + 6) % 7
+}
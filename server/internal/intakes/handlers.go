@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 type Handlers struct {
@@ -40,6 +42,7 @@ func (h *Handlers) HandleCreateSupplement(w http.ResponseWriter, r *http.Request
 			writeError(w, http.StatusBadRequest, "max_supplements_reached", err.Error())
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -69,6 +72,7 @@ func (h *Handlers) HandleListSupplements(w http.ResponseWriter, r *http.Request)
 			writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -99,6 +103,7 @@ func (h *Handlers) HandleUpdateSupplement(w http.ResponseWriter, r *http.Request
 			writeError(w, http.StatusNotFound, "supplement_not_found", err.Error())
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -122,6 +127,7 @@ func (h *Handlers) HandleDeleteSupplement(w http.ResponseWriter, r *http.Request
 			writeError(w, http.StatusNotFound, "supplement_not_found", err.Error())
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -155,6 +161,7 @@ func (h *Handlers) HandleAddWater(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -187,6 +194,7 @@ func (h *Handlers) HandleGetIntakesDaily(w http.ResponseWriter, r *http.Request)
 			writeError(w, http.StatusNotFound, "profile_not_found", err.Error())
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -223,6 +231,7 @@ func (h *Handlers) HandleUpsertSupplementIntake(w http.ResponseWriter, r *http.R
 			writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -235,8 +244,9 @@ func writeError(w http.ResponseWriter, status int, code, message string) {
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(ErrorResponse{
 		Error: ErrorDetail{
-			Code:    code,
-			Message: message,
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
 		},
 	})
 }
@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/fdg312/health-hub/internal/config"
+	"github.com/fdg312/health-hub/internal/medications"
+	"github.com/fdg312/health-hub/internal/storage"
 	"github.com/fdg312/health-hub/internal/storage/memory"
 	"github.com/google/uuid"
 )
@@ -318,6 +321,99 @@ func TestIntakesDaily_Empty(t *testing.T) {
 	}
 }
 
+func TestCreateSupplementReturnsInteractions(t *testing.T) {
+	memStorage := memory.New()
+	ctx := context.Background()
+	profiles, _ := memStorage.ListProfiles(ctx)
+	ownerID := profiles[0].ID
+
+	medicationsService := medications.NewService(memStorage.GetMedicationsStorage(), memStorage).
+		WithSupplements(memStorage.GetSupplementsStorage())
+	warfarin, err := memStorage.GetMedicationsStorage().CreateMedication(ctx, storage.Medication{
+		ProfileID: ownerID, Name: "Варфарин", Form: "tablet", DoseAmount: 1, DoseUnit: "tablet", DaysMask: 127, Active: true,
+	})
+	if err != nil {
+		t.Fatalf("create medication failed: %v", err)
+	}
+	service := NewService(
+		memStorage.GetSupplementsStorage(),
+		memStorage.GetIntakesStorage(),
+		memStorage,
+		&config.Config{IntakesMaxSupplements: 100},
+	).WithInteractionChecker(medicationsService)
+	handler := NewHandlers(service)
+
+	body, _ := json.Marshal(CreateSupplementRequest{
+		ProfileID:  ownerID,
+		Name:       "K2 MK-7",
+		Components: []ComponentInput{{NutrientKey: "vitamin_k", Amount: 100, Unit: "mcg"}},
+	})
+	w := httptest.NewRecorder()
+	handler.HandleCreateSupplement(w, httptest.NewRequest("POST", "/v1/supplements", bytes.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var created SupplementDTO
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(created.Interactions) != 1 || created.Interactions[0].With.ID != warfarin.ID ||
+		created.Interactions[0].Subject.ID != created.ID || created.Interactions[0].Severity != medications.SeverityModerate {
+		t.Fatalf("expected the vitamin K warning, got %+v", created.Interactions)
+	}
+}
+
+func TestSupplementSavedWhenInteractionsFail(t *testing.T) {
+	memStorage := memory.New()
+	ctx := context.Background()
+	profiles, _ := memStorage.ListProfiles(ctx)
+	ownerID := profiles[0].ID
+
+	service := NewService(
+		memStorage.GetSupplementsStorage(),
+		memStorage.GetIntakesStorage(),
+		memStorage,
+		&config.Config{IntakesMaxSupplements: 100},
+	).WithInteractionChecker(failingInteractions{})
+	handler := NewHandlers(service)
+
+	body, _ := json.Marshal(CreateSupplementRequest{ProfileID: ownerID, Name: "K2 MK-7"})
+	w := httptest.NewRecorder()
+	handler.HandleCreateSupplement(w, httptest.NewRequest("POST", "/v1/supplements", bytes.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var created SupplementDTO
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if created.Interactions != nil {
+		t.Fatalf("expected no interactions, got %+v", created.Interactions)
+	}
+
+	body, _ = json.Marshal(UpdateSupplementRequest{Name: strPtr("K2 MK-7 200")})
+	w = httptest.NewRecorder()
+	handler.HandleUpdateSupplement(w, httptest.NewRequest("PATCH", "/v1/supplements/"+created.ID.String(), bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	supplements, err := memStorage.GetSupplementsStorage().ListSupplements(ctx, ownerID)
+	if err != nil {
+		t.Fatalf("list supplements failed: %v", err)
+	}
+	if len(supplements) != 1 || supplements[0].Name != "K2 MK-7 200" {
+		t.Fatalf("expected one updated supplement, got %+v", supplements)
+	}
+}
+
+type failingInteractions struct{}
+
+func (failingInteractions) SupplementInteractions(context.Context, uuid.UUID, uuid.UUID) ([]medications.InteractionDTO, error) {
+	return nil, errors.New("interactions unavailable")
+}
+
 func strPtr(s string) *string {
 	return &s
 }
@@ -3,6 +3,7 @@ package intakes
 import (
 	"time"
 
+	"github.com/fdg312/health-hub/internal/medications"
 	"github.com/google/uuid"
 )
 
@@ -13,6 +14,8 @@ type SupplementDTO struct {
 	Name       string                 `json:"name"`
 	Notes      *string                `json:"notes,omitempty"`
 	Components []SupplementComponentDTO `json:"components,omitempty"`
+	// Interactions — взаимодействия с лекарствами и добавками профиля, при создании и изменении
+	Interactions []medications.InteractionDTO `json:"interactions,omitempty"`
 	CreatedAt  time.Time              `json:"created_at"`
 	UpdatedAt  time.Time              `json:"updated_at"`
 }
@@ -98,6 +101,7 @@ type ErrorResponse struct {
 }
 
 type ErrorDetail struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
 }
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/fdg312/health-hub/internal/config"
+	"github.com/fdg312/health-hub/internal/logging"
+	"github.com/fdg312/health-hub/internal/medications"
 	"github.com/fdg312/health-hub/internal/storage"
 	"github.com/fdg312/health-hub/internal/userctx"
 	"github.com/google/uuid"
@@ -16,9 +18,17 @@ type Service struct {
 	supplementsStorage storage.SupplementsStorage
 	intakesStorage     storage.IntakesStorage
 	profileStorage     storage.Storage
+	schedulesStorage   storage.SupplementSchedulesStorage
+	interactions       InteractionChecker
 	config             *config.Config
 }
 
+// InteractionChecker checks a supplement against the medications and other
+// supplements of its profile.
+type InteractionChecker interface {
+	SupplementInteractions(ctx context.Context, profileID, supplementID uuid.UUID) ([]medications.InteractionDTO, error)
+}
+
 func NewService(
 	supplementsStorage storage.SupplementsStorage,
 	intakesStorage storage.IntakesStorage,
@@ -33,6 +43,13 @@ func NewService(
 	}
 }
 
+// WithInteractionChecker makes CreateSupplement return interaction
+// warnings.
+func (s *Service) WithInteractionChecker(checker InteractionChecker) *Service {
+	s.interactions = checker
+	return s
+}
+
 // MARK: - Supplements
 
 func (s *Service) CreateSupplement(ctx context.Context, req *CreateSupplementRequest) (*SupplementDTO, error) {
@@ -82,7 +99,12 @@ func (s *Service) CreateSupplement(ctx context.Context, req *CreateSupplementReq
 		}
 	}
 
-	return s.buildSupplementDTO(ctx, supplement)
+	dto, err := s.buildSupplementDTO(ctx, supplement)
+	if err != nil {
+		return nil, err
+	}
+	s.addInteractions(ctx, dto)
+	return dto, nil
 }
 
 func (s *Service) ListSupplements(ctx context.Context, profileID uuid.UUID) ([]SupplementDTO, error) {
@@ -145,7 +167,27 @@ func (s *Service) UpdateSupplement(ctx context.Context, id uuid.UUID, req *Updat
 		}
 	}
 
-	return s.buildSupplementDTO(ctx, supplement)
+	dto, err := s.buildSupplementDTO(ctx, supplement)
+	if err != nil {
+		return nil, err
+	}
+	s.addInteractions(ctx, dto)
+	return dto, nil
+}
+
+// addInteractions attaches the interactions of a saved supplement. The
+// supplement is already stored, so a failed lookup is only logged: an error
+// here would make clients retry and create a duplicate.
+func (s *Service) addInteractions(ctx context.Context, dto *SupplementDTO) {
+	if s.interactions == nil {
+		return
+	}
+	interactions, err := s.interactions.SupplementInteractions(ctx, dto.ProfileID, dto.ID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("supplement interactions failed", "supplement_id", dto.ID, "error", err)
+		return
+	}
+	dto.Interactions = interactions
 }
 
 func (s *Service) DeleteSupplement(ctx context.Context, id uuid.UUID) error {
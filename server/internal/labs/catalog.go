@@ -0,0 +1,266 @@
+package labs
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Analyte keys of the catalog. Values of other analytes are keyed by their
+// normalized name and are stored without conversion or default ranges.
+const (
+	AnalyteGlucose       = "glucose"
+	AnalyteHbA1c         = "hba1c"
+	AnalyteLDL           = "ldl"
+	AnalyteHDL           = "hdl"
+	AnalyteCholesterol   = "cholesterol"
+	AnalyteTriglycerides = "triglycerides"
+	AnalyteFerritin      = "ferritin"
+	AnalyteIron          = "iron"
+	AnalyteVitaminD      = "vitamin_d"
+	AnalyteVitaminB12    = "vitamin_b12"
+	AnalyteHemoglobin    = "hemoglobin"
+	AnalyteTSH           = "tsh"
+	AnalyteCreatinine    = "creatinine"
+	AnalyteSystolicBP    = "systolic_bp"
+	AnalyteDiastolicBP   = "diastolic_bp"
+)
+
+// Units of the catalog, as returned by the API. Printed variants ("ммоль/л",
+// "mmol/l", "мкмоль/л") are mapped to them by NormalizeUnit.
+const (
+	UnitMmolL   = "mmol/L"
+	UnitMgDL    = "mg/dL"
+	UnitPercent = "%"
+	UnitNgML    = "ng/mL"
+	UnitUgL     = "µg/L"
+	UnitNmolL   = "nmol/L"
+	UnitUmolL   = "µmol/L"
+	UnitUgDL    = "µg/dL"
+	UnitPgML    = "pg/mL"
+	UnitPmolL   = "pmol/L"
+	UnitGL      = "g/L"
+	UnitGDL     = "g/dL"
+	UnitMIUL    = "mIU/L"
+	UnitUIUML   = "µIU/mL"
+	UnitMmHg    = "mmHg"
+)
+
+// Analyte describes a catalog analyte. Reference ranges are adult defaults
+// in Unit, used when a result has none of its own.
+type Analyte struct {
+	Key     string
+	Name    string
+	Unit    string
+	RefLow  *float64
+	RefHigh *float64
+	// NutrientKey is the supplement component (SupplementComponent.NutrientKey)
+	// that raises this analyte.
+	NutrientKey string
+
+	// factors convert a value in another unit to Unit by multiplication.
+	factors map[string]float64
+	aliases []string
+}
+
+// Units returns the units a value of the analyte may be entered in, the
+// catalog unit first.
+func (a Analyte) Units() []string {
+	units := []string{a.Unit}
+	for _, unit := range unitOrder {
+		if _, ok := a.factors[unit]; ok {
+			units = append(units, unit)
+		}
+	}
+	return units
+}
+
+// catalog is ordered from specific to general: a name is matched against
+// "ЛПНП" before "холестерин" and "гликированный гемоглобин" before
+// "гемоглобин".
+var catalog = []Analyte{
+	{Key: AnalyteGlucose, Name: "Глюкоза", Unit: UnitMmolL, RefLow: ptr(3.9), RefHigh: ptr(5.5),
+		factors: map[string]float64{UnitMgDL: 1 / 18.016},
+		aliases: []string{"глюкоза", "glucose"}},
+	{Key: AnalyteHbA1c, Name: "Гликированный гемоглобин", Unit: UnitPercent, RefLow: ptr(4), RefHigh: ptr(6),
+		aliases: []string{"гликированный гемоглобин", "гликозилированный гемоглобин", "hba1c", "glycated hemoglobin"}},
+	{Key: AnalyteLDL, Name: "Холестерин ЛПНП", Unit: UnitMmolL, RefHigh: ptr(3),
+		factors: map[string]float64{UnitMgDL: 1 / 38.67},
+		aliases: []string{"лпнп", "ldl", "низкой плотности", "low density"}},
+	{Key: AnalyteHDL, Name: "Холестерин ЛПВП", Unit: UnitMmolL, RefLow: ptr(1),
+		factors: map[string]float64{UnitMgDL: 1 / 38.67},
+		aliases: []string{"лпвп", "hdl", "высокой плотности", "high density"}},
+	{Key: AnalyteCholesterol, Name: "Холестерин общий", Unit: UnitMmolL, RefHigh: ptr(5.2),
+		factors: map[string]float64{UnitMgDL: 1 / 38.67},
+		aliases: []string{"холестерин", "cholesterol"}},
+	{Key: AnalyteTriglycerides, Name: "Триглицериды", Unit: UnitMmolL, RefHigh: ptr(1.7),
+		factors: map[string]float64{UnitMgDL: 1 / 88.57},
+		aliases: []string{"триглицериды", "triglycerides"}},
+	{Key: AnalyteFerritin, Name: "Ферритин", Unit: UnitNgML, RefLow: ptr(20), RefHigh: ptr(250), NutrientKey: "iron",
+		factors: map[string]float64{UnitUgL: 1},
+		aliases: []string{"ферритин", "ferritin"}},
+	{Key: AnalyteIron, Name: "Железо", Unit: UnitUmolL, RefLow: ptr(9), RefHigh: ptr(30), NutrientKey: "iron",
+		factors: map[string]float64{UnitUgDL: 1 / 5.585},
+		aliases: []string{"железо", "iron"}},
+	{Key: AnalyteVitaminD, Name: "Витамин D (25-OH)", Unit: UnitNgML, RefLow: ptr(30), RefHigh: ptr(100), NutrientKey: "vitamin_d",
+		factors: map[string]float64{UnitNmolL: 1 / 2.496},
+		aliases: []string{"витамин d", "витамин д", "25-oh d", "25-гидроксивитамин d", "кальцидиол", "vitamin d", "25-hydroxyvitamin d", "calcidiol"}},
+	{Key: AnalyteVitaminB12, Name: "Витамин B12", Unit: UnitPgML, RefLow: ptr(200), RefHigh: ptr(900), NutrientKey: "vitamin_b12",
+		factors: map[string]float64{UnitPmolL: 1.355},
+		aliases: []string{"витамин b12", "витамин в12", "цианокобаламин", "кобаламин", "vitamin b12", "cobalamin"}},
+	{Key: AnalyteHemoglobin, Name: "Гемоглобин", Unit: UnitGL, RefLow: ptr(120), RefHigh: ptr(160),
+		factors: map[string]float64{UnitGDL: 10},
+		aliases: []string{"гемоглобин", "hemoglobin", "haemoglobin", "hgb"}},
+	{Key: AnalyteTSH, Name: "ТТГ", Unit: UnitMIUL, RefLow: ptr(0.4), RefHigh: ptr(4),
+		factors: map[string]float64{UnitUIUML: 1},
+		aliases: []string{"ттг", "тиреотропный гормон", "tsh", "thyroid stimulating hormone"}},
+	{Key: AnalyteCreatinine, Name: "Креатинин", Unit: UnitUmolL, RefLow: ptr(62), RefHigh: ptr(115),
+		factors: map[string]float64{UnitMgDL: 88.4},
+		aliases: []string{"креатинин", "creatinine"}},
+	{Key: AnalyteSystolicBP, Name: "Давление систолическое", Unit: UnitMmHg, RefLow: ptr(90), RefHigh: ptr(120),
+		aliases: []string{"систолическое", "systolic"}},
+	{Key: AnalyteDiastolicBP, Name: "Давление диастолическое", Unit: UnitMmHg, RefLow: ptr(60), RefHigh: ptr(80),
+		aliases: []string{"диастолическое", "diastolic"}},
+}
+
+// unitOrder lists the catalog units in the order Units reports them.
+var unitOrder = []string{
+	UnitMmolL, UnitMgDL, UnitPercent, UnitNgML, UnitUgL, UnitNmolL, UnitUmolL,
+	UnitUgDL, UnitPgML, UnitPmolL, UnitGL, UnitGDL, UnitMIUL, UnitUIUML, UnitMmHg,
+}
+
+// unitSpellings maps printed units, lowercased without spaces and dots, to
+// catalog units.
+var unitSpellings = map[string]string{
+	"mmol/l": UnitMmolL, "ммоль/л": UnitMmolL,
+	"mg/dl": UnitMgDL, "мг/дл": UnitMgDL,
+	"%":     UnitPercent,
+	"ng/ml": UnitNgML, "нг/мл": UnitNgML,
+	"µg/l": UnitUgL, "μg/l": UnitUgL, "ug/l": UnitUgL, "mcg/l": UnitUgL, "мкг/л": UnitUgL,
+	"nmol/l": UnitNmolL, "нмоль/л": UnitNmolL,
+	"µmol/l": UnitUmolL, "μmol/l": UnitUmolL, "umol/l": UnitUmolL, "мкмоль/л": UnitUmolL,
+	"µg/dl": UnitUgDL, "μg/dl": UnitUgDL, "ug/dl": UnitUgDL, "mcg/dl": UnitUgDL, "мкг/дл": UnitUgDL,
+	"pg/ml": UnitPgML, "пг/мл": UnitPgML,
+	"pmol/l": UnitPmolL, "пмоль/л": UnitPmolL,
+	"g/l": UnitGL, "г/л": UnitGL,
+	"g/dl": UnitGDL, "г/дл": UnitGDL,
+	"miu/l": UnitMIUL, "мме/л": UnitMIUL, "мед/л": UnitMIUL,
+	"µiu/ml": UnitUIUML, "μiu/ml": UnitUIUML, "uiu/ml": UnitUIUML, "мкме/мл": UnitUIUML, "мкед/мл": UnitUIUML,
+	"mmhg": UnitMmHg, "ммртст": UnitMmHg,
+}
+
+// Catalog returns the catalog analytes.
+func Catalog() []Analyte {
+	return append([]Analyte{}, catalog...)
+}
+
+// Lookup returns the catalog analyte with key.
+func Lookup(key string) (Analyte, bool) {
+	for _, analyte := range catalog {
+		if analyte.Key == key {
+			return analyte, true
+		}
+	}
+	return Analyte{}, false
+}
+
+// AnalyteKey returns the analyte key of a name as printed on a report:
+// "Витамин D (25-OH)" and "25-OH vitamin D" are both vitamin_d. Names
+// outside the catalog are keyed by their words joined with "_".
+func AnalyteKey(name string) string {
+	words := nameWords(name)
+	for _, analyte := range catalog {
+		for _, alias := range analyte.aliases {
+			if containsWords(words, nameWords(alias)) {
+				return analyte.Key
+			}
+		}
+	}
+	return strings.Join(words, "_")
+}
+
+// NormalizeUnit returns the catalog spelling of a printed unit, or the unit
+// trimmed when it is not a catalog unit.
+func NormalizeUnit(unit string) string {
+	unit = strings.TrimSpace(unit)
+	key := strings.ToLower(strings.NewReplacer(" ", "", "\u00a0", "", ".", "").Replace(unit))
+	if normalized, ok := unitSpellings[key]; ok {
+		return normalized
+	}
+	return unit
+}
+
+// Convert converts a value of the analyte with key between units. It
+// reports false when either unit is not one of the analyte's units.
+func Convert(key string, value float64, from, to string) (float64, bool) {
+	from, to = NormalizeUnit(from), NormalizeUnit(to)
+	if from == to {
+		return value, true
+	}
+	analyte, ok := Lookup(key)
+	if !ok {
+		return 0, false
+	}
+	toCanonical, ok := analyte.factor(from)
+	if !ok {
+		return 0, false
+	}
+	fromCanonical, ok := analyte.factor(to)
+	if !ok {
+		return 0, false
+	}
+	return value * toCanonical / fromCanonical, true
+}
+
+func (a Analyte) factor(unit string) (float64, bool) {
+	if unit == a.Unit {
+		return 1, true
+	}
+	factor, ok := a.factors[unit]
+	return factor, ok
+}
+
+// Flags of a result against its reference range.
+const (
+	FlagLow     = "low"
+	FlagNormal  = "normal"
+	FlagHigh    = "high"
+	FlagUnknown = "unknown"
+)
+
+// Flag compares value with a reference range; either bound may be missing.
+func Flag(value float64, refLow, refHigh *float64) string {
+	switch {
+	case refLow == nil && refHigh == nil:
+		return FlagUnknown
+	case refLow != nil && value < *refLow:
+		return FlagLow
+	case refHigh != nil && value > *refHigh:
+		return FlagHigh
+	default:
+		return FlagNormal
+	}
+}
+
+// nameWords lowercases name and splits it into letter and digit runs.
+func nameWords(name string) []string {
+	name = strings.ReplaceAll(strings.ToLower(name), "ё", "е")
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func containsWords(words, sub []string) bool {
+	for i := 0; i+len(sub) <= len(words); i++ {
+		match := true
+		for j := range sub {
+			if words[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
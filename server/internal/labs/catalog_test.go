@@ -0,0 +1,50 @@
+package labs
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	for _, tc := range []struct {
+		analyte  string
+		value    float64
+		from, to string
+		want     float64
+		ok       bool
+	}{
+		{AnalyteGlucose, 5.5, "ммоль/л", "mg/dL", 99.088, true},
+		{AnalyteGlucose, 90, "mg/dl", UnitMmolL, 4.995, true},
+		{AnalyteCholesterol, 200, "мг/дл", UnitMmolL, 5.172, true},
+		{AnalyteVitaminD, 75, "нмоль/л", "нг/мл", 30.048, true},
+		{AnalyteHemoglobin, 13.5, "г/дл", "г/л", 135, true},
+		{AnalyteFerritin, 45, "нг/мл", "мкг/л", 45, true},
+		{AnalyteGlucose, 5, UnitMmolL, UnitPercent, 0, false},
+		{"unknown_test", 5, UnitMmolL, UnitMgDL, 0, false},
+	} {
+		got, ok := Convert(tc.analyte, tc.value, tc.from, tc.to)
+		if ok != tc.ok || math.Abs(got-tc.want) > 0.001 {
+			t.Fatalf("Convert(%s, %v, %s, %s) = %v, %v; want %v, %v", tc.analyte, tc.value, tc.from, tc.to, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestAnalyteKeyAndFlag(t *testing.T) {
+	for name, want := range map[string]string{
+		"Холестерин ЛПНП":          AnalyteLDL,
+		"Холестерин общий":         AnalyteCholesterol,
+		"HbA1c":                    AnalyteHbA1c,
+		"Гликированный гемоглобин": AnalyteHbA1c,
+		"Гемоглобин":               AnalyteHemoglobin,
+		"Антитела к ТПО":           "антитела_к_тпо",
+	} {
+		if got := AnalyteKey(name); got != want {
+			t.Fatalf("AnalyteKey(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	if Flag(3, ptr(3.9), ptr(5.5)) != FlagLow || Flag(5.5, ptr(3.9), ptr(5.5)) != FlagNormal ||
+		Flag(6, nil, ptr(5.2)) != FlagHigh || Flag(6, nil, nil) != FlagUnknown {
+		t.Fatal("unexpected flags")
+	}
+}
@@ -0,0 +1,186 @@
+package labs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bounds of the intake window before each result.
+const (
+	defaultWindowDays = 90
+	minWindowDays     = 7
+	maxWindowDays     = 365
+)
+
+// doseUnits maps the component units of a nutrient to factors into its dose
+// unit: vitamin D doses are summed in IU, 1 µg being 40 IU.
+var doseUnits = map[string]struct {
+	unit    string
+	factors map[string]float64
+}{
+	"vitamin_d":   {unit: "IU", factors: map[string]float64{"iu": 1, "ме": 1, "mcg": 40, "µg": 40, "μg": 40, "ug": 40, "мкг": 40}},
+	"iron":        {unit: "mg", factors: map[string]float64{"mg": 1, "мг": 1}},
+	"vitamin_b12": {unit: "mcg", factors: map[string]float64{"mcg": 1, "µg": 1, "μg": 1, "ug": 1, "мкг": 1}},
+}
+
+// Correlate pairs every result of an analyte with the average daily dose of
+// its nutrient taken from supplements over windowDays before the sampling
+// day, and computes the Pearson correlation of the two once there are at
+// least three results. Values are in the catalog unit.
+func (s *Service) Correlate(ctx context.Context, profileID uuid.UUID, key string, windowDays int) (*CorrelationResponse, error) {
+	if s.supplements == nil || s.intakes == nil {
+		return nil, fmt.Errorf("%w: supplements are not available", ErrInvalidRequest)
+	}
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, err
+	}
+	analyte, ok := Lookup(strings.TrimSpace(key))
+	if !ok || analyte.NutrientKey == "" {
+		return nil, fmt.Errorf("%w: analyte must be a catalog analyte with a nutrient", ErrInvalidRequest)
+	}
+	if windowDays == 0 {
+		windowDays = defaultWindowDays
+	}
+	if windowDays < minWindowDays || windowDays > maxWindowDays {
+		return nil, fmt.Errorf("%w: window_days must be %d-%d", ErrInvalidRequest, minWindowDays, maxWindowDays)
+	}
+	dose := doseUnits[analyte.NutrientKey]
+
+	results, err := s.storage.ListLabResults(ctx, profileID, analyte.Key, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	results = convertResults(results, analyte.Unit)
+
+	amounts, names, err := s.nutrientAmounts(ctx, profileID, analyte.NutrientKey)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &CorrelationResponse{
+		Analyte:     analyte.Key,
+		Unit:        analyte.Unit,
+		NutrientKey: analyte.NutrientKey,
+		DoseUnit:    dose.unit,
+		WindowDays:  windowDays,
+		Supplements: names,
+		Points:      []CorrelationPointDTO{},
+	}
+	if len(results) == 0 {
+		return resp, nil
+	}
+
+	// Daily totals of the nutrient over every window.
+	from := results[0].TakenOn.AddDate(0, 0, -windowDays)
+	to := results[len(results)-1].TakenOn
+	daily := make(map[time.Time]float64)
+	if len(amounts) > 0 {
+		intakes, err := s.intakes.ListSupplementIntakes(ctx, profileID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+		if err != nil {
+			return nil, err
+		}
+		for _, intake := range intakes {
+			if amount, ok := amounts[intake.SupplementID]; ok && intake.Status == "taken" {
+				daily[dateOf(intake.TakenAt)] += amount
+			}
+		}
+	}
+
+	var doses, values []float64
+	for i, result := range results {
+		start := result.TakenOn.AddDate(0, 0, -windowDays)
+		total, daysTaken := 0.0, 0
+		for day, amount := range daily {
+			if !day.Before(start) && day.Before(result.TakenOn) {
+				total += amount
+				daysTaken++
+			}
+		}
+
+		point := CorrelationPointDTO{
+			TakenOn:      result.TakenOn.Format("2006-01-02"),
+			Value:        result.Value,
+			Flag:         result.Flag,
+			AvgDailyDose: round(total / float64(windowDays)),
+			DaysTaken:    daysTaken,
+		}
+		if i > 0 {
+			change := round(result.Value - results[i-1].Value)
+			point.Change = &change
+		}
+		resp.Points = append(resp.Points, point)
+		doses = append(doses, point.AvgDailyDose)
+		values = append(values, result.Value)
+	}
+	resp.Correlation = pearson(doses, values)
+	return resp, nil
+}
+
+// nutrientAmounts returns the amount of the nutrient per intake of every
+// supplement containing it, in the dose unit, and the supplement names.
+// Components in units that do not convert are left out.
+func (s *Service) nutrientAmounts(ctx context.Context, profileID uuid.UUID, nutrientKey string) (map[uuid.UUID]float64, []string, error) {
+	supplements, err := s.supplements.ListSupplements(ctx, profileID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	factors := doseUnits[nutrientKey].factors
+	amounts := make(map[uuid.UUID]float64)
+	names := []string{}
+	for _, supplement := range supplements {
+		components, err := s.supplements.GetSupplementComponents(ctx, supplement.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, component := range components {
+			if component.NutrientKey != nutrientKey {
+				continue
+			}
+			factor, ok := factors[strings.ToLower(strings.TrimSpace(component.Unit))]
+			if !ok {
+				continue
+			}
+			if _, seen := amounts[supplement.ID]; !seen {
+				names = append(names, supplement.Name)
+			}
+			amounts[supplement.ID] += component.Amount * factor
+		}
+	}
+	sort.Strings(names)
+	return amounts, names, nil
+}
+
+// pearson returns the correlation coefficient of xs and ys, or nil with
+// fewer than three points or when either has no variance.
+func pearson(xs, ys []float64) *float64 {
+	n := float64(len(xs))
+	if len(xs) < 3 {
+		return nil
+	}
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return nil
+	}
+	r := math.Round(cov/math.Sqrt(varX*varY)*1000) / 1000
+	return &r
+}
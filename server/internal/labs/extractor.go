@@ -0,0 +1,105 @@
+package labs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fdg312/health-hub/internal/ai"
+)
+
+// ErrExtractionFailed wraps every extractor failure: the engine is down,
+// timed out or returned something unreadable.
+var ErrExtractionFailed = errors.New("lab extraction failed")
+
+// ErrUnredactedImage means the extractor would send the photo, with the
+// personal data printed on it, to an AI provider without the user's consent.
+var ErrUnredactedImage = errors.New("lab photo would be sent unredacted")
+
+// Image is a photo or scan of a lab report. AllowUnredacted is the user's
+// consent to send it to an AI provider as is.
+type Image struct {
+	Data            []byte
+	ContentType     string
+	AllowUnredacted bool
+}
+
+// Value is one analyte as printed on a report. TakenOn (YYYY-MM-DD) is
+// empty when the report does not show the sampling date.
+type Value struct {
+	Name    string
+	Value   float64
+	Unit    string
+	RefLow  *float64
+	RefHigh *float64
+	TakenOn string
+}
+
+// Extractor turns a lab report image into values.
+type Extractor interface {
+	Extract(ctx context.Context, image Image) ([]Value, error)
+}
+
+// StubExtractor returns the same values for every image. It stands in for
+// a real engine in tests and in local runs without OCR or AI.
+type StubExtractor struct {
+	Values []Value
+}
+
+// NewStubExtractor returns values, or ferritin and vitamin D when none are
+// given.
+func NewStubExtractor(values ...Value) *StubExtractor {
+	if len(values) == 0 {
+		values = []Value{
+			{Name: "Ферритин", Value: 45, Unit: "нг/мл", RefLow: ptr(20), RefHigh: ptr(250)},
+			{Name: "Витамин D (25-OH)", Value: 28, Unit: "нг/мл", RefLow: ptr(30), RefHigh: ptr(100)},
+		}
+	}
+	return &StubExtractor{Values: values}
+}
+
+func (e *StubExtractor) Extract(ctx context.Context, image Image) ([]Value, error) {
+	_ = ctx
+	_ = image
+	return append([]Value{}, e.Values...), nil
+}
+
+// AIExtractor reads reports with a vision-capable AI provider.
+type AIExtractor struct {
+	provider ai.Provider
+}
+
+func NewAIExtractor(provider ai.Provider) *AIExtractor {
+	return &AIExtractor{provider: provider}
+}
+
+func (e *AIExtractor) Extract(ctx context.Context, image Image) ([]Value, error) {
+	read, err := e.provider.ReadLabReport(ctx, ai.LabReportRequest{
+		Image:           image.Data,
+		ContentType:     image.ContentType,
+		AllowUnredacted: image.AllowUnredacted,
+	})
+	if errors.Is(err, ai.ErrUnredactedImage) {
+		return nil, ErrUnredactedImage
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExtractionFailed, err)
+	}
+
+	values := make([]Value, 0, len(read))
+	for _, v := range read {
+		values = append(values, Value{
+			Name:    v.Name,
+			Value:   v.Value,
+			Unit:    v.Unit,
+			RefLow:  v.RefLow,
+			RefHigh: v.RefHigh,
+			TakenOn: v.Date,
+		})
+	}
+	return values, nil
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
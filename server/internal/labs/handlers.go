@@ -0,0 +1,228 @@
+package labs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// HandleExtract handles POST /v1/sources/{id}/labs/extract?allow_unredacted=
+func (h *Handler) HandleExtract(w http.ResponseWriter, r *http.Request) {
+	sourceID, ok := parseSourceID(w, r)
+	if !ok {
+		return
+	}
+
+	allowUnredacted := r.URL.Query().Get("allow_unredacted") == "true"
+	resp, err := h.service.ExtractFromSource(r.Context(), sourceID, allowUnredacted)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleListSource handles GET /v1/sources/{id}/labs
+func (h *Handler) HandleListSource(w http.ResponseWriter, r *http.Request) {
+	sourceID, ok := parseSourceID(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.ListSourceResults(r.Context(), sourceID)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleListResults handles GET /v1/labs/results?profile_id=&analyte=&unit=&from=&to=
+func (h *Handler) HandleListResults(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	profileID, err := uuid.Parse(strings.TrimSpace(query.Get("profile_id")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "profile_id is required")
+		return
+	}
+
+	resp, err := h.service.ListResults(r.Context(), profileID, query.Get("analyte"), query.Get("unit"),
+		strings.TrimSpace(query.Get("from")), strings.TrimSpace(query.Get("to")))
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleCreate handles POST /v1/labs/results
+func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var req CreateResultRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.CreateResult(r.Context(), req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// HandleGet handles GET /v1/labs/results/{id}
+func (h *Handler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	resultID, ok := parseResultID(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.GetResult(r.Context(), resultID)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleUpdate handles PATCH /v1/labs/results/{id}
+func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	resultID, ok := parseResultID(w, r)
+	if !ok {
+		return
+	}
+	var req UpdateResultRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.UpdateResult(r.Context(), resultID, req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleDelete handles DELETE /v1/labs/results/{id}
+func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	resultID, ok := parseResultID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteResult(r.Context(), resultID); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListAnalytes handles GET /v1/labs/analytes
+func (h *Handler) HandleListAnalytes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.service.ListAnalytes())
+}
+
+// HandleCorrelation handles GET /v1/labs/correlations?profile_id=&analyte=&window_days=
+func (h *Handler) HandleCorrelation(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	profileID, err := uuid.Parse(strings.TrimSpace(query.Get("profile_id")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "profile_id is required")
+		return
+	}
+	windowDays := 0
+	if raw := strings.TrimSpace(query.Get("window_days")); raw != "" {
+		windowDays, err = strconv.Atoi(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "window_days must be a number")
+			return
+		}
+	}
+
+	resp, err := h.service.Correlate(r.Context(), profileID, query.Get("analyte"), windowDays)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+	case errors.Is(err, ErrProfileNotFound):
+		writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
+	case errors.Is(err, ErrResultNotFound):
+		writeError(w, http.StatusNotFound, "result_not_found", "Lab result not found")
+	case errors.Is(err, ErrSourceNotFound):
+		writeError(w, http.StatusNotFound, "source_not_found", "Source not found")
+	case errors.Is(err, ErrNotImage):
+		writeError(w, http.StatusBadRequest, "not_an_image", "Lab results can only be read from image sources")
+	case errors.Is(err, ErrUnsupportedImage):
+		writeError(w, http.StatusBadRequest, "unsupported_image", "Lab results can only be read from JPEG or PNG images")
+	case errors.Is(err, ErrConsentRequired):
+		writeError(w, http.StatusForbidden, "ai_consent_required", "Consent to AI processing is required")
+	case errors.Is(err, ErrUnredactedImage):
+		writeError(w, http.StatusForbidden, "unredacted_image_consent_required", "Lab photos reach the AI provider unredacted, repeat with allow_unredacted=true")
+	case errors.Is(err, ErrQuotaExceeded):
+		writeError(w, http.StatusTooManyRequests, "quota_exceeded", "AI token quota is used up, see GET /v1/ai/usage")
+	case errors.Is(err, ErrExtractionFailed):
+		logging.FromContext(r.Context()).Error("lab extraction failed", "error", err)
+		writeError(w, http.StatusBadGateway, "extraction_failed", "Could not read the lab report")
+	default:
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+	}
+}
+
+func parseSourceID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	sourceID, err := uuid.Parse(strings.TrimSpace(r.PathValue("id")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid source id")
+		return uuid.Nil, false
+	}
+	return sourceID, true
+}
+
+func parseResultID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	resultID, err := uuid.Parse(strings.TrimSpace(r.PathValue("id")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid result id")
+		return uuid.Nil, false
+	}
+	return resultID, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{
+		Error: ErrorDetail{
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
+		},
+	})
+}
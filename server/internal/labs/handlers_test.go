@@ -0,0 +1,370 @@
+package labs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/ai"
+	"github.com/fdg312/health-hub/internal/config"
+	"github.com/fdg312/health-hub/internal/sources"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/storage/memory"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+func TestExtractStoresResultsAndChartsByAnalyte(t *testing.T) {
+	extractor := NewStubExtractor(
+		Value{Name: "Ферритин", Value: 12.5, Unit: "нг/мл", RefLow: ptr(20), RefHigh: ptr(250), TakenOn: "2026-03-02"},
+		Value{Name: "25-OH витамин D", Value: 28, Unit: "нг/мл"},
+		Value{Name: "  ", Value: 1},
+	)
+	handler, mem, profileID := setupLabsHandler(t, extractor)
+	sourceID := createImageSource(t, mem, profileID, "image/jpeg")
+
+	w := serveLabs(handler.HandleExtract, http.MethodPost, "/v1/sources/"+sourceID.String()+"/labs/extract", sourceID.String(), "userA")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var extracted SourceResultsResponse
+	if err := json.NewDecoder(w.Body).Decode(&extracted); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(extracted.Results) != 2 {
+		t.Fatalf("expected 2 results without the unnamed value, got %+v", extracted.Results)
+	}
+	today := time.Now().UTC().Format("2006-01-02")
+	for _, result := range extracted.Results {
+		if result.SourceID == nil || *result.SourceID != sourceID {
+			t.Fatalf("expected results linked to the source, got %+v", result)
+		}
+		switch result.Analyte {
+		case AnalyteFerritin:
+			if result.TakenOn != "2026-03-02" || result.RefLow == nil || *result.RefLow != 20 || result.Flag != FlagLow {
+				t.Fatalf("unexpected ferritin %+v", result)
+			}
+		case AnalyteVitaminD:
+			if result.TakenOn != today {
+				t.Fatalf("expected undated vitamin D on the upload day, got %+v", result)
+			}
+			// Without a printed range the catalog default applies.
+			if result.Unit != UnitNgML || result.RefLow == nil || *result.RefLow != 30 || result.Flag != FlagLow {
+				t.Fatalf("expected vitamin D flagged against the catalog range, got %+v", result)
+			}
+		default:
+			t.Fatalf("unexpected analyte %q", result.Analyte)
+		}
+	}
+
+	// Extracting again replaces the values read from the source.
+	serveLabs(handler.HandleExtract, http.MethodPost, "/v1/sources/"+sourceID.String()+"/labs/extract", sourceID.String(), "userA")
+
+	w = serveLabs(handler.HandleListResults, http.MethodGet, "/v1/labs/results?analyte=ferritin&from=2026-01-01&profile_id="+profileID.String(), "", "userA")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var listed ResultsResponse
+	if err := json.NewDecoder(w.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(listed.Results) != 1 || listed.Results[0].Value != 12.5 {
+		t.Fatalf("expected a single ferritin value, got %+v", listed.Results)
+	}
+
+	w = serveLabs(handler.HandleListResults, http.MethodGet, "/v1/labs/results?profile_id="+profileID.String(), "", "userB")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for another owner, got %d", w.Code)
+	}
+}
+
+func TestExtractRejectsUnreadableSources(t *testing.T) {
+	handler, mem, profileID := setupLabsHandler(t, NewStubExtractor())
+	heic := createImageSource(t, mem, profileID, "image/heic")
+	text := "Ферритин 45"
+	note := &storage.Source{ProfileID: profileID, Kind: sources.KindNote, Text: &text}
+	if err := mem.GetSourcesStorage().CreateSource(context.Background(), note); err != nil {
+		t.Fatalf("create source failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		sourceID uuid.UUID
+		userID   string
+		status   int
+		code     string
+	}{
+		{heic, "userA", http.StatusBadRequest, "unsupported_image"},
+		{note.ID, "userA", http.StatusBadRequest, "not_an_image"},
+		{heic, "userB", http.StatusNotFound, "source_not_found"},
+	} {
+		w := serveLabs(handler.HandleExtract, http.MethodPost, "/v1/sources/"+tc.sourceID.String()+"/labs/extract", tc.sourceID.String(), tc.userID)
+		if w.Code != tc.status || !strings.Contains(w.Body.String(), tc.code) {
+			t.Fatalf("expected %d %s, got %d body=%s", tc.status, tc.code, w.Code, w.Body.String())
+		}
+	}
+}
+
+type fakeConsent struct{ granted bool }
+
+func (f fakeConsent) HasAIConsent(ctx context.Context, ownerUserID string) (bool, error) {
+	return f.granted, nil
+}
+
+type fakeMeter struct{ recorded []ai.Usage }
+
+func (f *fakeMeter) WithinQuota(ctx context.Context, ownerUserID string) (bool, error) {
+	return true, nil
+}
+
+func (f *fakeMeter) Record(ctx context.Context, ownerUserID string, usage ai.Usage) {
+	f.recorded = append(f.recorded, usage)
+}
+
+func TestAIExtractionRequiresConsentAndMetersUsage(t *testing.T) {
+	handler, mem, profileID := setupLabsHandler(t, NewAIExtractor(ai.NewMockProvider()))
+	sourceID := createImageSource(t, mem, profileID, "image/png")
+	meter := &fakeMeter{}
+	handler.service.WithConsentChecker(fakeConsent{}).WithUsageMeter(meter)
+
+	w := serveLabs(handler.HandleExtract, http.MethodPost, "/v1/sources/"+sourceID.String()+"/labs/extract", sourceID.String(), "userA")
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "ai_consent_required") {
+		t.Fatalf("expected 403 ai_consent_required, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	handler.service.WithConsentChecker(fakeConsent{granted: true})
+	w = serveLabs(handler.HandleExtract, http.MethodPost, "/v1/sources/"+sourceID.String()+"/labs/extract", sourceID.String(), "userA")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if len(meter.recorded) != 1 {
+		t.Fatalf("expected one metered call, got %d", len(meter.recorded))
+	}
+
+	w = serveLabs(handler.HandleListSource, http.MethodGet, "/v1/sources/"+sourceID.String()+"/labs", sourceID.String(), "userA")
+	var listed SourceResultsResponse
+	if err := json.NewDecoder(w.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(listed.Results) != 2 || listed.Results[0].Analyte == listed.Results[1].Analyte {
+		t.Fatalf("expected the mock provider's two values, got %+v", listed.Results)
+	}
+}
+
+func TestAIExtractionUnderRedactionRequiresOptIn(t *testing.T) {
+	provider := ai.WithRedaction(ai.NewMockProvider(), ai.NewRedactionPolicy([]string{config.AIRedactNames}))
+	handler, mem, profileID := setupLabsHandler(t, NewAIExtractor(provider))
+	sourceID := createImageSource(t, mem, profileID, "image/png")
+	path := "/v1/sources/" + sourceID.String() + "/labs/extract"
+
+	w := serveLabs(handler.HandleExtract, http.MethodPost, path, sourceID.String(), "userA")
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "unredacted_image_consent_required") {
+		t.Fatalf("expected 403 unredacted_image_consent_required, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	w = serveLabs(handler.HandleExtract, http.MethodPost, path+"?allow_unredacted=true", sourceID.String(), "userA")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestResultsCRUDFlagsAndConvertsUnits(t *testing.T) {
+	handler, mem, profileID := setupLabsHandler(t, NewStubExtractor())
+
+	w := serveLabsBody(handler.HandleCreate, http.MethodPost, "/v1/labs/results", "", "userA", map[string]any{
+		"profile_id": profileID, "analyte": AnalyteGlucose, "value": 110, "unit": "мг/дл", "taken_on": "2025-05-14",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d body=%s", w.Code, w.Body.String())
+	}
+	var created LabResultDTO
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if created.Name != "Глюкоза" || created.Unit != UnitMgDL || created.Flag != FlagHigh || created.RefHigh == nil || *created.RefHigh != 99.09 {
+		t.Fatalf("expected high glucose against the converted catalog range, got %+v", created)
+	}
+
+	notifications, err := mem.GetNotificationsStorage().ListNotifications(context.Background(), profileID, false, 10, 0)
+	if err != nil {
+		t.Fatalf("list notifications failed: %v", err)
+	}
+	if len(notifications) != 1 || notifications[0].Kind != NotificationKind || notifications[0].Severity != "warn" ||
+		!strings.Contains(notifications[0].Body, "Глюкоза 110 mg/dL — выше нормы (70.26–99.09)") {
+		t.Fatalf("expected an out-of-range notification, got %+v", notifications)
+	}
+
+	w = serveLabs(handler.HandleListResults, http.MethodGet, "/v1/labs/results?analyte=glucose&unit=mmol/L&profile_id="+profileID.String(), "", "userA")
+	var listed ResultsResponse
+	if err := json.NewDecoder(w.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(listed.Results) != 1 || listed.Results[0].Value != 6.11 || listed.Results[0].Unit != UnitMmolL || *listed.Results[0].RefLow != 3.9 {
+		t.Fatalf("expected glucose converted to mmol/L, got %+v", listed.Results)
+	}
+
+	id := created.ID.String()
+	w = serveLabsBody(handler.HandleUpdate, http.MethodPatch, "/v1/labs/results/"+id, id, "userA", map[string]any{"value": 90, "note": "натощак"})
+	var updated LabResultDTO
+	if err := json.NewDecoder(w.Body).Decode(&updated); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if updated.Value != 90 || updated.Flag != FlagNormal || updated.Note != "натощак" {
+		t.Fatalf("expected a normal value after the correction, got %+v", updated)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		handle http.HandlerFunc
+		method string
+		body   any
+		userID string
+		status int
+		code   string
+	}{
+		{"unit not convertible", handler.HandleUpdate, http.MethodPatch, map[string]any{"unit": "%"}, "userA", http.StatusBadRequest, "invalid_request"},
+		{"another owner", handler.HandleGet, http.MethodGet, nil, "userB", http.StatusNotFound, "result_not_found"},
+	} {
+		w = serveLabsBody(tc.handle, tc.method, "/v1/labs/results/"+id, id, tc.userID, tc.body)
+		if w.Code != tc.status || !strings.Contains(w.Body.String(), tc.code) {
+			t.Fatalf("%s: expected %d %s, got %d body=%s", tc.name, tc.status, tc.code, w.Code, w.Body.String())
+		}
+	}
+
+	w = serveLabs(handler.HandleDelete, http.MethodDelete, "/v1/labs/results/"+id, id, "userA")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d body=%s", w.Code, w.Body.String())
+	}
+	w = serveLabs(handler.HandleGet, http.MethodGet, "/v1/labs/results/"+id, id, "userA")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 after delete, got %d", w.Code)
+	}
+}
+
+func TestCorrelateVitaminDWithSupplementIntakes(t *testing.T) {
+	handler, mem, profileID := setupLabsHandler(t, NewStubExtractor())
+	ctx := context.Background()
+
+	supplement := &storage.Supplement{ProfileID: profileID, Name: "Витамин D3"}
+	if err := mem.GetSupplementsStorage().CreateSupplement(ctx, supplement); err != nil {
+		t.Fatalf("create supplement failed: %v", err)
+	}
+	components := []storage.SupplementComponent{{NutrientKey: "vitamin_d", Amount: 50, Unit: "mcg"}}
+	if err := mem.GetSupplementsStorage().SetSupplementComponents(ctx, supplement.ID, components); err != nil {
+		t.Fatalf("set components failed: %v", err)
+	}
+	// 45 days before the second result and every day before the third.
+	day := func(date string) time.Time {
+		parsed, _ := time.Parse("2006-01-02", date)
+		return parsed.Add(9 * time.Hour)
+	}
+	intakes := mem.GetIntakesStorage()
+	for d := day("2025-02-24"); d.Before(day("2025-04-10")); d = d.AddDate(0, 0, 1) {
+		intakes.UpsertSupplementIntake(ctx, &storage.SupplementIntake{ProfileID: profileID, SupplementID: supplement.ID, TakenAt: d, Status: "taken"})
+	}
+	for d := day("2025-04-11"); d.Before(day("2025-07-10")); d = d.AddDate(0, 0, 1) {
+		intakes.UpsertSupplementIntake(ctx, &storage.SupplementIntake{ProfileID: profileID, SupplementID: supplement.ID, TakenAt: d, Status: "taken"})
+	}
+	intakes.UpsertSupplementIntake(ctx, &storage.SupplementIntake{ProfileID: profileID, SupplementID: supplement.ID, TakenAt: day("2025-01-05"), Status: "skipped"})
+
+	for _, body := range []map[string]any{
+		{"profile_id": profileID, "analyte": AnalyteVitaminD, "value": 18, "taken_on": "2025-01-10"},
+		{"profile_id": profileID, "analyte": AnalyteVitaminD, "value": 30, "taken_on": "2025-04-10"},
+		{"profile_id": profileID, "name": "25-OH vitamin D", "value": 112.32, "unit": "nmol/l", "taken_on": "2025-07-10"},
+	} {
+		if w := serveLabsBody(handler.HandleCreate, http.MethodPost, "/v1/labs/results", "", "userA", body); w.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d body=%s", w.Code, w.Body.String())
+		}
+	}
+
+	w := serveLabs(handler.HandleCorrelation, http.MethodGet, "/v1/labs/correlations?analyte=vitamin_d&profile_id="+profileID.String(), "", "userA")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var resp CorrelationResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if resp.DoseUnit != "IU" || resp.WindowDays != 90 || len(resp.Supplements) != 1 || len(resp.Points) != 3 {
+		t.Fatalf("unexpected correlation %+v", resp)
+	}
+	for i, want := range []struct {
+		value, dose float64
+		days        int
+	}{{18, 0, 0}, {30, 1000, 45}, {45, 2000, 90}} {
+		point := resp.Points[i]
+		if point.Value != want.value || point.AvgDailyDose != want.dose || point.DaysTaken != want.days {
+			t.Fatalf("point %d: expected %+v, got %+v", i, want, point)
+		}
+	}
+	if resp.Points[0].Change != nil || resp.Points[2].Change == nil || *resp.Points[2].Change != 15 {
+		t.Fatalf("expected changes since the previous result, got %+v", resp.Points)
+	}
+	if resp.Correlation == nil || *resp.Correlation < 0.99 {
+		t.Fatalf("expected a strong positive correlation, got %v", resp.Correlation)
+	}
+
+	w = serveLabs(handler.HandleCorrelation, http.MethodGet, "/v1/labs/correlations?analyte=glucose&profile_id="+profileID.String(), "", "userA")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an analyte without a nutrient, got %d", w.Code)
+	}
+}
+
+func setupLabsHandler(t *testing.T, extractor Extractor) (*Handler, *memory.MemoryStorage, uuid.UUID) {
+	t.Helper()
+
+	mem := memory.New()
+	profileID := uuid.New()
+	for _, profile := range []storage.Profile{
+		{ID: profileID, OwnerUserID: "userA", Type: "owner", Name: "User A"},
+		{ID: uuid.New(), OwnerUserID: "userB", Type: "owner", Name: "User B"},
+	} {
+		if err := mem.CreateProfile(context.Background(), &profile); err != nil {
+			t.Fatalf("create profile failed: %v", err)
+		}
+	}
+
+	sourcesService := sources.NewService(mem.GetSourcesStorage(), mem, nil, 10, "image/jpeg,image/png,image/heic", 4, "", false)
+	service := NewService(mem.GetLabResultsStorage(), mem, sourcesService, extractor).
+		WithNotifications(mem.GetNotificationsStorage()).
+		WithSupplements(mem.GetSupplementsStorage(), mem.GetIntakesStorage())
+	return NewHandler(service), mem, profileID
+}
+
+func createImageSource(t *testing.T, mem *memory.MemoryStorage, profileID uuid.UUID, contentType string) uuid.UUID {
+	t.Helper()
+
+	source := &storage.Source{ProfileID: profileID, Kind: sources.KindImage, ContentType: &contentType, SizeBytes: 5}
+	if err := mem.GetSourcesStorage().CreateSource(context.Background(), source); err != nil {
+		t.Fatalf("create source failed: %v", err)
+	}
+	if err := mem.GetSourcesStorage().PutSourceBlob(context.Background(), source.ID, []byte("image"), contentType); err != nil {
+		t.Fatalf("put blob failed: %v", err)
+	}
+	return source.ID
+}
+
+func serveLabs(handle http.HandlerFunc, method, path, id, userID string) *httptest.ResponseRecorder {
+	return serveLabsBody(handle, method, path, id, userID, nil)
+}
+
+func serveLabsBody(handle http.HandlerFunc, method, path, id, userID string, body any) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if id != "" {
+		req.SetPathValue("id", id)
+	}
+	req = req.WithContext(userctx.WithUserID(context.Background(), userID))
+	w := httptest.NewRecorder()
+	handle(w, req)
+	return w
+}
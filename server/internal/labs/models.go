@@ -0,0 +1,114 @@
+package labs
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LabResultDTO is one stored lab value. SourceID is null for values entered
+// by hand and once the report photo a value was read from is deleted.
+type LabResultDTO struct {
+	ID        uuid.UUID  `json:"id"`
+	ProfileID uuid.UUID  `json:"profile_id"`
+	SourceID  *uuid.UUID `json:"source_id"`
+	Analyte   string     `json:"analyte"`
+	Name      string     `json:"name"`
+	Value     float64    `json:"value"`
+	Unit      string     `json:"unit"`
+	RefLow    *float64   `json:"ref_low"`
+	RefHigh   *float64   `json:"ref_high"`
+	Flag      string     `json:"flag"`
+	TakenOn   string     `json:"taken_on"`
+	Note      string     `json:"note"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CreateResultRequest enters a value by hand. Analyte is a catalog key or
+// is derived from Name; at least one is required. Unit defaults to the
+// catalog unit, the reference range to the catalog default.
+type CreateResultRequest struct {
+	ProfileID uuid.UUID `json:"profile_id"`
+	Analyte   string    `json:"analyte,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Value     *float64  `json:"value"`
+	Unit      string    `json:"unit,omitempty"`
+	RefLow    *float64  `json:"ref_low,omitempty"`
+	RefHigh   *float64  `json:"ref_high,omitempty"`
+	TakenOn   string    `json:"taken_on"`
+	Note      string    `json:"note,omitempty"`
+}
+
+// UpdateResultRequest changes the given fields; the flag is recomputed.
+type UpdateResultRequest struct {
+	Name    *string  `json:"name,omitempty"`
+	Value   *float64 `json:"value,omitempty"`
+	Unit    *string  `json:"unit,omitempty"`
+	RefLow  *float64 `json:"ref_low,omitempty"`
+	RefHigh *float64 `json:"ref_high,omitempty"`
+	TakenOn *string  `json:"taken_on,omitempty"`
+	Note    *string  `json:"note,omitempty"`
+}
+
+// AnalyteDTO is a catalog analyte. Units lists the units values may be
+// entered in and converted to, the catalog unit first.
+type AnalyteDTO struct {
+	Key         string   `json:"key"`
+	Name        string   `json:"name"`
+	Unit        string   `json:"unit"`
+	Units       []string `json:"units"`
+	RefLow      *float64 `json:"ref_low"`
+	RefHigh     *float64 `json:"ref_high"`
+	NutrientKey string   `json:"nutrient_key,omitempty"`
+}
+
+type AnalytesResponse struct {
+	Analytes []AnalyteDTO `json:"analytes"`
+}
+
+// SourceResultsResponse lists the values read from one source.
+type SourceResultsResponse struct {
+	SourceID uuid.UUID      `json:"source_id"`
+	Results  []LabResultDTO `json:"results"`
+}
+
+// ResultsResponse lists the values of a profile, oldest first.
+type ResultsResponse struct {
+	Results []LabResultDTO `json:"results"`
+}
+
+// ErrorResponse — стандартный формат ошибки.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// CorrelationPointDTO is one result of the analyte with the nutrient taken
+// before it. Change is null for the first result.
+type CorrelationPointDTO struct {
+	TakenOn      string   `json:"taken_on"`
+	Value        float64  `json:"value"`
+	Flag         string   `json:"flag"`
+	Change       *float64 `json:"change"`
+	AvgDailyDose float64  `json:"avg_daily_dose"`
+	DaysTaken    int      `json:"days_taken"`
+}
+
+// CorrelationResponse relates an analyte to supplement intakes of its
+// nutrient. Correlation is null with fewer than three results or no spread.
+type CorrelationResponse struct {
+	Analyte     string                `json:"analyte"`
+	Unit        string                `json:"unit"`
+	NutrientKey string                `json:"nutrient_key"`
+	DoseUnit    string                `json:"dose_unit"`
+	WindowDays  int                   `json:"window_days"`
+	Supplements []string              `json:"supplements"`
+	Points      []CorrelationPointDTO `json:"points"`
+	Correlation *float64              `json:"correlation"`
+}
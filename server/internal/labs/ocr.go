@@ -0,0 +1,157 @@
+package labs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ocrTimeout bounds one OCR run.
+const ocrTimeout = 60 * time.Second
+
+// OCRExtractor runs a Tesseract-compatible command (`cmd stdin stdout -l
+// rus+eng`) and parses the recognized text with ParseReport.
+type OCRExtractor struct {
+	command string
+}
+
+func NewOCRExtractor(command string) *OCRExtractor {
+	return &OCRExtractor{command: command}
+}
+
+func (e *OCRExtractor) Extract(ctx context.Context, image Image) ([]Value, error) {
+	ctx, cancel := context.WithTimeout(ctx, ocrTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.command, "stdin", "stdout", "-l", "rus+eng")
+	cmd.Stdin = bytes.NewReader(image.Data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: ocr: %v: %s", ErrExtractionFailed, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return ParseReport(stdout.String()), nil
+}
+
+var (
+	// valueLine is "<name> <value> [<unit>] [<reference>]"; the name ends
+	// with a letter, digit or closing bracket ("Витамин B12", "25-OH (D)").
+	valueLine = regexp.MustCompile(`^(.*?[\p{L}\d)])[\s:]+[<>≤≥]?\s*(\d+(?:[.,]\d+)?)(?:\s+(\S*[\p{L}%/]\S*))?(?:\s+(.*))?$`)
+	refRange  = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)\s*[-–—]\s*(\d+(?:[.,]\d+)?)`)
+	refBelow  = regexp.MustCompile(`^(?:<|≤|<=|до)\s*(\d+(?:[.,]\d+)?)`)
+	refAbove  = regexp.MustCompile(`^(?:>|≥|>=|от)\s*(\d+(?:[.,]\d+)?)`)
+	dateDMY   = regexp.MustCompile(`\b(\d{2})[./](\d{2})[./](\d{4})\b`)
+	dateISO   = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+)
+
+// ParseReport reads values from the plain text of a lab report, one per
+// line. The sampling date is taken from a line mentioning it ("Дата
+// взятия", "Collected"), otherwise from the first date in the text that is
+// not a birth date.
+// Lines that do not look like a value are skipped.
+func ParseReport(text string) []Value {
+	var takenOn, firstDate string
+	values := make([]Value, 0)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			continue
+		}
+
+		if date := findDate(line); date != "" {
+			switch lower := strings.ToLower(line); {
+			case containsAny(lower, "рожд", "birth"):
+			case takenOn == "" && containsAny(lower, "взят", "забор", "collect", "sampl"):
+				takenOn = date
+			case firstDate == "":
+				firstDate = date
+			}
+			continue
+		}
+
+		if value, ok := parseValueLine(line); ok {
+			values = append(values, value)
+		}
+	}
+
+	if takenOn == "" {
+		takenOn = firstDate
+	}
+	for i := range values {
+		values[i].TakenOn = takenOn
+	}
+	return values
+}
+
+func parseValueLine(line string) (Value, bool) {
+	m := valueLine.FindStringSubmatch(line)
+	if m == nil {
+		return Value{}, false
+	}
+	name := strings.TrimSpace(m[1])
+	if len([]rune(name)) < 2 || len(name) > maxNameLength || !hasLetters(name, 2) {
+		return Value{}, false
+	}
+
+	value := Value{Name: name, Value: parseNumber(m[2]), Unit: m[3]}
+	ref := strings.TrimSpace(m[4])
+	switch {
+	case refRange.MatchString(ref):
+		r := refRange.FindStringSubmatch(ref)
+		value.RefLow, value.RefHigh = ptr(parseNumber(r[1])), ptr(parseNumber(r[2]))
+	case refBelow.MatchString(ref):
+		value.RefHigh = ptr(parseNumber(refBelow.FindStringSubmatch(ref)[1]))
+	case refAbove.MatchString(ref):
+		value.RefLow = ptr(parseNumber(refAbove.FindStringSubmatch(ref)[1]))
+	}
+	return value, true
+}
+
+func findDate(line string) string {
+	if m := dateISO.FindStringSubmatch(line); m != nil {
+		return validDate(m[1], m[2], m[3])
+	}
+	if m := dateDMY.FindStringSubmatch(line); m != nil {
+		return validDate(m[3], m[2], m[1])
+	}
+	return ""
+}
+
+func validDate(year, month, day string) string {
+	date := year + "-" + month + "-" + day
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return ""
+	}
+	return date
+}
+
+func parseNumber(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+	return v
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLetters(s string, n int) bool {
+	count := 0
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			count++
+		}
+	}
+	return count >= n
+}
@@ -0,0 +1,57 @@
+package labs
+
+import "testing"
+
+func TestParseReport(t *testing.T) {
+	text := `ООО «Лаборатория»
+Пациент: Иванова А. А.
+Дата рождения: 01.02.1990
+Дата взятия материала: 14.09.2026
+
+Показатель Результат Ед. Референсные значения
+Гемоглобин 118 г/л 120 - 160
+Ферритин 12,5 нг/мл 20-250
+Витамин D (25-OH) 28 нг/мл 30 – 100
+Витамин B12 350 пг/мл
+ТТГ 2.1 мкМЕ/мл < 4.2
+Не обнаружено
+`
+	values := ParseReport(text)
+
+	want := []struct {
+		name    string
+		analyte string
+		value   float64
+		unit    string
+		low     *float64
+		high    *float64
+	}{
+		{"Гемоглобин", AnalyteHemoglobin, 118, "г/л", ptr(120), ptr(160)},
+		{"Ферритин", AnalyteFerritin, 12.5, "нг/мл", ptr(20), ptr(250)},
+		{"Витамин D (25-OH)", AnalyteVitaminD, 28, "нг/мл", ptr(30), ptr(100)},
+		{"Витамин B12", AnalyteVitaminB12, 350, "пг/мл", nil, nil},
+		{"ТТГ", AnalyteTSH, 2.1, "мкМЕ/мл", nil, ptr(4.2)},
+	}
+	if len(values) != len(want) {
+		t.Fatalf("expected %d values, got %+v", len(want), values)
+	}
+	for i, w := range want {
+		got := values[i]
+		if got.Name != w.name || AnalyteKey(got.Name) != w.analyte || got.Value != w.value || got.Unit != w.unit {
+			t.Errorf("value %d: got %+v", i, got)
+		}
+		if !equalRef(got.RefLow, w.low) || !equalRef(got.RefHigh, w.high) {
+			t.Errorf("value %d: unexpected reference %v-%v", i, got.RefLow, got.RefHigh)
+		}
+		if got.TakenOn != "2026-09-14" {
+			t.Errorf("value %d: expected the sampling date, got %q", i, got.TakenOn)
+		}
+	}
+}
+
+func equalRef(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
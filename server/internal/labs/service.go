@@ -0,0 +1,710 @@
+package labs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/ai"
+	"github.com/fdg312/health-hub/internal/logging"
+	"github.com/fdg312/health-hub/internal/sources"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRequest   = errors.New("invalid request")
+	ErrProfileNotFound  = errors.New("profile not found")
+	ErrSourceNotFound   = errors.New("source not found")
+	ErrNotImage         = errors.New("source is not an image")
+	ErrUnsupportedImage = errors.New("unsupported image type")
+	ErrConsentRequired  = errors.New("ai consent required")
+	ErrQuotaExceeded    = errors.New("ai quota exceeded")
+	ErrResultNotFound   = errors.New("lab result not found")
+)
+
+// Limits on what one report can produce.
+const (
+	maxValuesPerReport = 200
+	maxNameLength      = 200
+	maxUnitLength      = 50
+	maxNoteLength      = 1000
+)
+
+// NotificationKind is the notification kind of out-of-range results; one
+// per profile and sampling day.
+const NotificationKind = "lab_out_of_range"
+
+type profileReader interface {
+	GetProfile(ctx context.Context, id uuid.UUID) (*storage.Profile, error)
+}
+
+// sourceReader reads report photos; *sources.Service checks access.
+type sourceReader interface {
+	GetSource(ctx context.Context, id uuid.UUID) (*storage.Source, error)
+	GetImageData(ctx context.Context, id uuid.UUID) ([]byte, string, error)
+}
+
+type consentChecker interface {
+	HasAIConsent(ctx context.Context, ownerUserID string) (bool, error)
+}
+
+type usageMeter interface {
+	WithinQuota(ctx context.Context, ownerUserID string) (bool, error)
+	Record(ctx context.Context, ownerUserID string, usage ai.Usage)
+}
+
+type Service struct {
+	storage        storage.LabResultsStorage
+	profileStorage profileReader
+	sources        sourceReader
+	extractor      Extractor
+	consent        consentChecker
+	usage          usageMeter
+	notifications  storage.NotificationsStorage
+	supplements    storage.SupplementsStorage
+	intakes        storage.IntakesStorage
+	now            func() time.Time
+}
+
+func NewService(
+	labResultsStorage storage.LabResultsStorage,
+	profileStorage profileReader,
+	sourceReader sourceReader,
+	extractor Extractor,
+) *Service {
+	return &Service{
+		storage:        labResultsStorage,
+		profileStorage: profileStorage,
+		sources:        sourceReader,
+		extractor:      extractor,
+		now:            time.Now,
+	}
+}
+
+// WithConsentChecker refuses extraction until the profile owner has agreed
+// to AI processing. Only set it for extractors that send images out.
+func (s *Service) WithConsentChecker(checker consentChecker) *Service {
+	s.consent = checker
+	return s
+}
+
+// WithUsageMeter records the tokens of every extraction per owner and
+// refuses new ones once the owner's quota is used up.
+func (s *Service) WithUsageMeter(meter usageMeter) *Service {
+	s.usage = meter
+	return s
+}
+
+// WithNotifications warns the profile about results outside their
+// reference range.
+func (s *Service) WithNotifications(notifications storage.NotificationsStorage) *Service {
+	s.notifications = notifications
+	return s
+}
+
+// WithSupplements enables correlating results with supplement intakes.
+func (s *Service) WithSupplements(supplements storage.SupplementsStorage, intakes storage.IntakesStorage) *Service {
+	s.supplements = supplements
+	s.intakes = intakes
+	return s
+}
+
+// ExtractFromSource reads the lab report photo of a source and replaces the
+// results previously read from it. Values without a date on the report are
+// dated by the upload day. allowUnredacted is the user's consent to send the
+// photo to an AI provider without masking the personal data printed on it.
+func (s *Service) ExtractFromSource(ctx context.Context, sourceID uuid.UUID, allowUnredacted bool) (*SourceResultsResponse, error) {
+	source, err := s.sources.GetSource(ctx, sourceID)
+	if err != nil {
+		return nil, mapSourceError(err)
+	}
+	if source.Kind != sources.KindImage {
+		return nil, ErrNotImage
+	}
+	if source.ContentType == nil || !extractable(*source.ContentType) {
+		return nil, ErrUnsupportedImage
+	}
+
+	profile, err := s.profileStorage.GetProfile(ctx, source.ProfileID)
+	if err != nil {
+		return nil, ErrSourceNotFound
+	}
+	if err := s.checkAI(ctx, profile.OwnerUserID); err != nil {
+		return nil, err
+	}
+
+	data, contentType, err := s.sources.GetImageData(ctx, source.ID)
+	if err != nil {
+		return nil, mapSourceError(err)
+	}
+
+	values, err := s.extractor.Extract(s.meterUsage(ctx, profile.OwnerUserID), Image{Data: data, ContentType: contentType, AllowUnredacted: allowUnredacted})
+	if err != nil {
+		return nil, err
+	}
+
+	defaultDate := dateOf(source.CreatedAt)
+	results := make([]storage.LabResult, 0, len(values))
+	for _, value := range values {
+		result, ok := s.toResult(source.ProfileID, value, defaultDate)
+		if !ok {
+			continue
+		}
+		results = append(results, result)
+		if len(results) == maxValuesPerReport {
+			break
+		}
+	}
+
+	saved, err := s.storage.ReplaceSourceLabResults(ctx, source.ID, results)
+	if err != nil {
+		return nil, err
+	}
+	notified := make(map[time.Time]bool)
+	for _, result := range saved {
+		if !notified[result.TakenOn] {
+			notified[result.TakenOn] = true
+			s.notifyOutOfRange(ctx, source.ProfileID, result.TakenOn)
+		}
+	}
+	return &SourceResultsResponse{SourceID: source.ID, Results: toDTOs(saved)}, nil
+}
+
+// ListSourceResults returns the values read from a source.
+func (s *Service) ListSourceResults(ctx context.Context, sourceID uuid.UUID) (*SourceResultsResponse, error) {
+	source, err := s.sources.GetSource(ctx, sourceID)
+	if err != nil {
+		return nil, mapSourceError(err)
+	}
+
+	results, err := s.storage.ListSourceLabResults(ctx, source.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &SourceResultsResponse{SourceID: source.ID, Results: toDTOs(results)}, nil
+}
+
+// ListResults returns a profile's values taken within [from, to]
+// (YYYY-MM-DD, both optional), optionally of one analyte, oldest first.
+// With unit, values and ranges of the analyte are converted to it and values
+// in units that do not convert are left out.
+func (s *Service) ListResults(ctx context.Context, profileID uuid.UUID, analyte, unit, from, to string) (*ResultsResponse, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, err
+	}
+
+	analyte = strings.TrimSpace(analyte)
+	unit = NormalizeUnit(unit)
+	if unit != "" {
+		if analyte == "" {
+			return nil, fmt.Errorf("%w: unit requires analyte", ErrInvalidRequest)
+		}
+		if err := checkUnit(analyte, unit); err != nil {
+			return nil, err
+		}
+	}
+
+	fromDate, err := parseOptionalDate(from, "from")
+	if err != nil {
+		return nil, err
+	}
+	toDate, err := parseOptionalDate(to, "to")
+	if err != nil {
+		return nil, err
+	}
+	if !fromDate.IsZero() && !toDate.IsZero() && toDate.Before(fromDate) {
+		return nil, fmt.Errorf("%w: to is before from", ErrInvalidRequest)
+	}
+
+	results, err := s.storage.ListLabResults(ctx, profileID, analyte, fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+	if unit != "" {
+		results = convertResults(results, unit)
+	}
+	return &ResultsResponse{Results: toDTOs(results)}, nil
+}
+
+// CreateResult stores a value entered by hand.
+func (s *Service) CreateResult(ctx context.Context, req CreateResultRequest) (*LabResultDTO, error) {
+	if err := s.ensureProfileAccess(ctx, req.ProfileID); err != nil {
+		return nil, err
+	}
+	if req.Value == nil || finite(req.Value) == nil {
+		return nil, fmt.Errorf("%w: value is required", ErrInvalidRequest)
+	}
+
+	name := strings.TrimSpace(req.Name)
+	key := strings.TrimSpace(req.Analyte)
+	switch {
+	case key != "":
+		analyte, ok := Lookup(key)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown analyte, see GET /v1/labs/analytes", ErrInvalidRequest)
+		}
+		if name == "" {
+			name = analyte.Name
+		}
+	case name != "":
+		key = AnalyteKey(name)
+	default:
+		return nil, fmt.Errorf("%w: analyte or name is required", ErrInvalidRequest)
+	}
+	if len([]rune(name)) > maxNameLength {
+		return nil, fmt.Errorf("%w: name is too long", ErrInvalidRequest)
+	}
+
+	unit := NormalizeUnit(req.Unit)
+	if analyte, ok := Lookup(key); ok && unit == "" {
+		unit = analyte.Unit
+	}
+	if err := checkUnit(key, unit); err != nil {
+		return nil, err
+	}
+
+	takenOn, err := s.parseTakenOn(req.TakenOn)
+	if err != nil {
+		return nil, err
+	}
+	note := strings.TrimSpace(req.Note)
+	if len([]rune(note)) > maxNoteLength {
+		return nil, fmt.Errorf("%w: note is too long", ErrInvalidRequest)
+	}
+
+	result := storage.LabResult{
+		ProfileID: req.ProfileID,
+		Analyte:   key,
+		Name:      name,
+		Value:     *req.Value,
+		Unit:      unit,
+		RefLow:    finite(req.RefLow),
+		RefHigh:   finite(req.RefHigh),
+		TakenOn:   takenOn,
+		Note:      note,
+	}
+	classify(&result)
+
+	created, err := s.storage.CreateLabResult(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyOutOfRange(ctx, created.ProfileID, created.TakenOn)
+	dto := toDTO(created)
+	return &dto, nil
+}
+
+// GetResult returns one result.
+func (s *Service) GetResult(ctx context.Context, id uuid.UUID) (*LabResultDTO, error) {
+	result, err := s.getOwnedResult(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	dto := toDTO(result)
+	return &dto, nil
+}
+
+// UpdateResult corrects a result, typically a misread value. Renaming
+// re-keys the analyte; changing the unit alone converts the stored range.
+func (s *Service) UpdateResult(ctx context.Context, id uuid.UUID, req UpdateResultRequest) (*LabResultDTO, error) {
+	result, err := s.getOwnedResult(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len([]rune(name)) > maxNameLength {
+			return nil, fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidRequest, maxNameLength)
+		}
+		result.Name = name
+		result.Analyte = AnalyteKey(name)
+	}
+	if req.Value != nil {
+		if finite(req.Value) == nil {
+			return nil, fmt.Errorf("%w: value must be a number", ErrInvalidRequest)
+		}
+		result.Value = *req.Value
+	}
+	if req.Unit != nil {
+		unit := NormalizeUnit(*req.Unit)
+		if unit != result.Unit && req.RefLow == nil && req.RefHigh == nil {
+			result.RefLow = convertRef(result.Analyte, result.RefLow, result.Unit, unit)
+			result.RefHigh = convertRef(result.Analyte, result.RefHigh, result.Unit, unit)
+		}
+		result.Unit = unit
+	}
+	if req.Name != nil || req.Unit != nil {
+		if err := checkUnit(result.Analyte, result.Unit); err != nil {
+			return nil, err
+		}
+	}
+	if req.RefLow != nil {
+		result.RefLow = finite(req.RefLow)
+	}
+	if req.RefHigh != nil {
+		result.RefHigh = finite(req.RefHigh)
+	}
+	if req.TakenOn != nil {
+		takenOn, err := s.parseTakenOn(*req.TakenOn)
+		if err != nil {
+			return nil, err
+		}
+		result.TakenOn = takenOn
+	}
+	if req.Note != nil {
+		note := strings.TrimSpace(*req.Note)
+		if len([]rune(note)) > maxNoteLength {
+			return nil, fmt.Errorf("%w: note is too long", ErrInvalidRequest)
+		}
+		result.Note = note
+	}
+	classify(&result)
+
+	updated, ok, err := s.storage.UpdateLabResult(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrResultNotFound
+	}
+	s.notifyOutOfRange(ctx, updated.ProfileID, updated.TakenOn)
+	dto := toDTO(updated)
+	return &dto, nil
+}
+
+// DeleteResult deletes one result.
+func (s *Service) DeleteResult(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.getOwnedResult(ctx, id); err != nil {
+		return err
+	}
+	deleted, err := s.storage.DeleteLabResult(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrResultNotFound
+	}
+	return nil
+}
+
+// ListAnalytes returns the analyte catalog.
+func (s *Service) ListAnalytes() *AnalytesResponse {
+	analytes := make([]AnalyteDTO, 0, len(catalog))
+	for _, analyte := range catalog {
+		analytes = append(analytes, AnalyteDTO{
+			Key:         analyte.Key,
+			Name:        analyte.Name,
+			Unit:        analyte.Unit,
+			Units:       analyte.Units(),
+			RefLow:      analyte.RefLow,
+			RefHigh:     analyte.RefHigh,
+			NutrientKey: analyte.NutrientKey,
+		})
+	}
+	return &AnalytesResponse{Analytes: analytes}
+}
+
+func (s *Service) getOwnedResult(ctx context.Context, id uuid.UUID) (storage.LabResult, error) {
+	result, ok, err := s.storage.GetLabResult(ctx, id)
+	if err != nil {
+		return storage.LabResult{}, err
+	}
+	if !ok {
+		return storage.LabResult{}, ErrResultNotFound
+	}
+	if err := s.ensureProfileAccess(ctx, result.ProfileID); err != nil {
+		return storage.LabResult{}, ErrResultNotFound
+	}
+	return result, nil
+}
+
+// notifyOutOfRange upserts the warning for a profile's sampling day listing
+// every value outside its range. Failures are logged: the results are saved
+// either way.
+func (s *Service) notifyOutOfRange(ctx context.Context, profileID uuid.UUID, takenOn time.Time) {
+	if s.notifications == nil {
+		return
+	}
+	results, err := s.storage.ListLabResults(ctx, profileID, "", takenOn, takenOn)
+	if err != nil {
+		logging.FromContext(ctx).Warn("lab notification skipped", "profile_id", profileID, "error", err)
+		return
+	}
+
+	var lines []string
+	for _, result := range results {
+		if result.Flag == FlagLow || result.Flag == FlagHigh {
+			lines = append(lines, describeOutOfRange(result))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	sourceDate := takenOn
+	notification := &storage.Notification{
+		ProfileID:  profileID,
+		Kind:       NotificationKind,
+		Title:      "Анализы вне нормы",
+		Body:       strings.Join(lines, "; "),
+		SourceDate: &sourceDate,
+		Severity:   "warn",
+	}
+	if err := s.notifications.CreateNotification(ctx, notification); err != nil {
+		logging.FromContext(ctx).Warn("lab notification failed", "profile_id", profileID, "error", err)
+	}
+}
+
+// checkAI enforces consent and quota of the profile owner, when set.
+func (s *Service) checkAI(ctx context.Context, ownerUserID string) error {
+	if s.consent != nil {
+		granted, err := s.consent.HasAIConsent(ctx, ownerUserID)
+		if err != nil {
+			return err
+		}
+		if !granted {
+			return ErrConsentRequired
+		}
+	}
+	if s.usage != nil {
+		within, err := s.usage.WithinQuota(ctx, ownerUserID)
+		if err != nil {
+			return err
+		}
+		if !within {
+			return ErrQuotaExceeded
+		}
+	}
+	return nil
+}
+
+func (s *Service) meterUsage(ctx context.Context, ownerUserID string) context.Context {
+	if s.usage == nil {
+		return ctx
+	}
+	return ai.WithUsage(ctx, func(usage ai.Usage) {
+		s.usage.Record(ctx, ownerUserID, usage)
+	})
+}
+
+// toResult validates an extracted value; values without a name or a finite
+// number are dropped.
+func (s *Service) toResult(profileID uuid.UUID, value Value, defaultDate time.Time) (storage.LabResult, bool) {
+	name := strings.TrimSpace(value.Name)
+	if name == "" || len([]rune(name)) > maxNameLength || math.IsNaN(value.Value) || math.IsInf(value.Value, 0) {
+		return storage.LabResult{}, false
+	}
+	unit := strings.TrimSpace(value.Unit)
+	if len([]rune(unit)) > maxUnitLength {
+		unit = ""
+	}
+
+	takenOn := defaultDate
+	if parsed, err := time.Parse("2006-01-02", value.TakenOn); err == nil && !parsed.After(s.now().UTC()) {
+		takenOn = parsed
+	}
+
+	result := storage.LabResult{
+		ProfileID: profileID,
+		Analyte:   AnalyteKey(name),
+		Name:      name,
+		Value:     value.Value,
+		Unit:      unit,
+		RefLow:    finite(value.RefLow),
+		RefHigh:   finite(value.RefHigh),
+		TakenOn:   takenOn,
+	}
+	classify(&result)
+	return result, true
+}
+
+// parseTakenOn parses a required sampling date; results cannot be from the
+// future.
+func (s *Service) parseTakenOn(value string) (time.Time, error) {
+	takenOn, err := time.Parse("2006-01-02", strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: taken_on must be YYYY-MM-DD", ErrInvalidRequest)
+	}
+	if takenOn.After(s.now().UTC()) {
+		return time.Time{}, fmt.Errorf("%w: taken_on is in the future", ErrInvalidRequest)
+	}
+	return takenOn, nil
+}
+
+func (s *Service) ensureProfileAccess(ctx context.Context, profileID uuid.UUID) error {
+	profile, err := s.profileStorage.GetProfile(ctx, profileID)
+	if err != nil {
+		return ErrProfileNotFound
+	}
+
+	if userID, ok := userctx.GetUserID(ctx); ok && strings.TrimSpace(userID) != "" && profile.OwnerUserID != userID {
+		return ErrProfileNotFound
+	}
+
+	return nil
+}
+
+// classify spells the unit of a result as the catalog does, fills a missing
+// reference range with the catalog default and flags the value.
+func classify(result *storage.LabResult) {
+	result.Unit = NormalizeUnit(result.Unit)
+	if result.RefLow == nil && result.RefHigh == nil {
+		if analyte, ok := Lookup(result.Analyte); ok {
+			result.RefLow = convertRef(analyte.Key, analyte.RefLow, analyte.Unit, result.Unit)
+			result.RefHigh = convertRef(analyte.Key, analyte.RefHigh, analyte.Unit, result.Unit)
+		}
+	}
+	result.Flag = Flag(result.Value, result.RefLow, result.RefHigh)
+}
+
+// checkUnit refuses units a catalog analyte cannot be converted from; any
+// unit goes for analytes outside the catalog.
+func checkUnit(key, unit string) error {
+	analyte, ok := Lookup(key)
+	if !ok {
+		if len([]rune(unit)) > maxUnitLength {
+			return fmt.Errorf("%w: unit is too long", ErrInvalidRequest)
+		}
+		return nil
+	}
+	if _, ok := analyte.factor(unit); !ok {
+		return fmt.Errorf("%w: unit of %s must be one of %s", ErrInvalidRequest, key, strings.Join(analyte.Units(), ", "))
+	}
+	return nil
+}
+
+func convertResults(results []storage.LabResult, unit string) []storage.LabResult {
+	converted := make([]storage.LabResult, 0, len(results))
+	for _, result := range results {
+		value, ok := Convert(result.Analyte, result.Value, result.Unit, unit)
+		if !ok {
+			continue
+		}
+		result.RefLow = convertRef(result.Analyte, result.RefLow, result.Unit, unit)
+		result.RefHigh = convertRef(result.Analyte, result.RefHigh, result.Unit, unit)
+		result.Value = round(value)
+		result.Unit = unit
+		converted = append(converted, result)
+	}
+	return converted
+}
+
+// convertRef converts a range bound; bounds that do not convert are dropped.
+func convertRef(key string, bound *float64, from, to string) *float64 {
+	if bound == nil {
+		return nil
+	}
+	value, ok := Convert(key, *bound, from, to)
+	if !ok {
+		return nil
+	}
+	value = round(value)
+	return &value
+}
+
+// round keeps two decimals of converted values.
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func describeOutOfRange(result storage.LabResult) string {
+	direction := "ниже нормы"
+	if result.Flag == FlagHigh {
+		direction = "выше нормы"
+	}
+	line := strings.TrimSpace(result.Name + " " + formatNumber(result.Value) + " " + result.Unit)
+	return fmt.Sprintf("%s — %s (%s)", line, direction, formatRange(result.RefLow, result.RefHigh))
+}
+
+func formatRange(low, high *float64) string {
+	switch {
+	case low != nil && high != nil:
+		return formatNumber(*low) + "–" + formatNumber(*high)
+	case low != nil:
+		return "от " + formatNumber(*low)
+	case high != nil:
+		return "до " + formatNumber(*high)
+	default:
+		return ""
+	}
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func parseOptionalDate(value, field string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid %s", ErrInvalidRequest, field)
+	}
+	return parsed, nil
+}
+
+func mapSourceError(err error) error {
+	if errors.Is(err, sources.ErrSourceNotFound) {
+		return ErrSourceNotFound
+	}
+	return err
+}
+
+// extractable reports whether extractors can read the image: HEIC photos
+// kept as HEIC cannot be decoded.
+func extractable(contentType string) bool {
+	switch strings.ToLower(contentType) {
+	case "image/jpeg", "image/jpg", "image/png":
+		return true
+	default:
+		return false
+	}
+}
+
+func finite(v *float64) *float64 {
+	if v == nil || math.IsNaN(*v) || math.IsInf(*v, 0) {
+		return nil
+	}
+	return v
+}
+
+func dateOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func toDTOs(results []storage.LabResult) []LabResultDTO {
+	dtos := make([]LabResultDTO, 0, len(results))
+	for _, result := range results {
+		dtos = append(dtos, toDTO(result))
+	}
+	return dtos
+}
+
+func toDTO(result storage.LabResult) LabResultDTO {
+	return LabResultDTO{
+		ID:        result.ID,
+		ProfileID: result.ProfileID,
+		SourceID:  result.SourceID,
+		Analyte:   result.Analyte,
+		Name:      result.Name,
+		Value:     result.Value,
+		Unit:      result.Unit,
+		RefLow:    result.RefLow,
+		RefHigh:   result.RefHigh,
+		Flag:      result.Flag,
+		TakenOn:   result.TakenOn.Format("2006-01-02"),
+		Note:      result.Note,
+		CreatedAt: result.CreatedAt,
+		UpdatedAt: result.UpdatedAt,
+	}
+}
@@ -0,0 +1,183 @@
+package linkpreview
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// page is what is read from an HTML document.
+type page struct {
+	title       string
+	ogTitle     string
+	description string
+	siteName    string
+	image       string
+	text        string
+}
+
+// skippedElements hold no readable article text.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Form: true, atom.Button: true, atom.Select: true, atom.Svg: true,
+	atom.Iframe: true, atom.Object: true, atom.Canvas: true, atom.Figcaption: true,
+}
+
+// blockElements end a paragraph of the readable text.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Tr: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Section: true, atom.Article: true,
+	atom.Dt: true, atom.Dd: true, atom.Table: true, atom.Ul: true, atom.Ol: true,
+}
+
+// parsePage reads the title, OpenGraph tags and, when withText is set, the
+// readable text of an HTML document: the <article> if there is one, else
+// <main>, else <body>, without navigation, scripts and forms.
+func parsePage(r io.Reader, withText bool) (page, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return page{}, err
+	}
+
+	var p page
+	var article, main, body *html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Title:
+				if p.title == "" {
+					p.title = collapseSpace(nodeText(n))
+				}
+			case atom.Meta:
+				p.readMeta(n)
+			case atom.Article:
+				if article == nil {
+					article = n
+				}
+			case atom.Main:
+				if main == nil {
+					main = n
+				}
+			case atom.Body:
+				body = n
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	if withText {
+		for _, root := range []*html.Node{article, main, body} {
+			if root != nil {
+				p.text = readableText(root)
+				break
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *page) readMeta(n *html.Node) {
+	var key, content string
+	for _, attr := range n.Attr {
+		switch strings.ToLower(attr.Key) {
+		case "property", "name":
+			if key == "" || strings.HasPrefix(strings.ToLower(attr.Val), "og:") {
+				key = strings.ToLower(strings.TrimSpace(attr.Val))
+			}
+		case "content":
+			content = collapseSpace(attr.Val)
+		}
+	}
+	if content == "" {
+		return
+	}
+	set := func(field *string) {
+		if *field == "" {
+			*field = content
+		}
+	}
+	switch key {
+	case "og:title", "twitter:title":
+		set(&p.ogTitle)
+	case "og:description", "twitter:description", "description":
+		set(&p.description)
+	case "og:site_name":
+		set(&p.siteName)
+	case "og:image", "og:image:url", "og:image:secure_url", "twitter:image", "twitter:image:src":
+		set(&p.image)
+	}
+}
+
+// readableText joins the text of root into paragraphs separated by blank
+// lines.
+func readableText(root *html.Node) string {
+	var paragraphs []string
+	var current strings.Builder
+	flush := func() {
+		if text := collapseSpace(current.String()); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+		current.Reset()
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			current.WriteString(n.Data)
+			current.WriteByte(' ')
+			return
+		case html.ElementNode:
+			if skippedElements[n.DataAtom] {
+				return
+			}
+			if blockElements[n.DataAtom] {
+				flush()
+				defer flush()
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(root)
+	flush()
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			b.WriteString(child.Data)
+		}
+	}
+	return b.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// truncate cuts s to at most limit runes, at a word boundary when one is
+// close.
+func truncate(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	runes := []rune(s)[:limit]
+	cut := string(runes)
+	if i := strings.LastIndexAny(cut, " \n"); i > len(cut)*9/10 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + "…"
+}
@@ -0,0 +1,230 @@
+// Package linkpreview fetches web pages saved as link sources: the title,
+// description and image from OpenGraph tags and, optionally, the readable
+// text of the article. Pages come from user input, so every request goes
+// only to public addresses and is limited in size, time and redirects.
+package linkpreview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	ErrInvalidURL     = errors.New("url must be an absolute http(s) url")
+	ErrBlockedAddress = errors.New("address is not public")
+	ErrTooLarge       = errors.New("response too large")
+	ErrHTTPStatus     = errors.New("unexpected http status")
+)
+
+// Limits that are not configurable.
+const (
+	maxRedirects     = 5
+	maxTitleRunes    = 300
+	maxDescRunes     = 1000
+	maxSiteNameRunes = 100
+	// MaxArchiveRunes bounds the archived text of one page.
+	MaxArchiveRunes = 200_000
+	userAgent       = "HealthHubLinkPreview/1.0 (+https://github.com/fdg312/health-hub)"
+)
+
+// Config sets the limits of a Fetcher.
+type Config struct {
+	// Timeout bounds one request, redirects and body included.
+	Timeout time.Duration
+	// MaxBytes bounds a page and its image separately.
+	MaxBytes int64
+	// ArchiveText keeps the readable text of HTML pages.
+	ArchiveText bool
+}
+
+// Fetcher downloads pages and their preview images.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+	archive  bool
+}
+
+// Preview is what was read from a page. Empty fields were not found.
+type Preview struct {
+	// URL is the page after redirects.
+	URL         string
+	Title       string
+	Description string
+	SiteName    string
+	// Image is the preview image as downloaded; ImageContentType is its
+	// declared type.
+	Image            []byte
+	ImageContentType string
+	// Text is the readable text; set only with Config.ArchiveText.
+	Text string
+}
+
+// New creates a Fetcher that connects only to public addresses.
+func New(cfg Config) *Fetcher {
+	return newFetcher(cfg, dialControl)
+}
+
+func newFetcher(cfg Config, control func(network, address string, c syscall.RawConn) error) *Fetcher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 5 << 20
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout, Control: control}
+	transport := &http.Transport{
+		// A proxy would be dialled instead of the page and defeat the
+		// address check.
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return checkURL(req.URL)
+		},
+	}
+	return &Fetcher{client: client, maxBytes: cfg.MaxBytes, archive: cfg.ArchiveText}
+}
+
+// Fetch reads the page at rawURL. Pages other than HTML give an empty
+// preview, except images, which become the preview image themselves. A
+// preview image that cannot be downloaded is left out without an error.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Preview, error) {
+	pageURL, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return Preview{}, ErrInvalidURL
+	}
+	if err := checkURL(pageURL); err != nil {
+		return Preview{}, err
+	}
+
+	body, contentType, finalURL, err := f.get(ctx, pageURL.String(), "text/html,application/xhtml+xml;q=0.9,*/*;q=0.5")
+	if err != nil {
+		return Preview{}, err
+	}
+
+	preview := Preview{URL: finalURL.String(), SiteName: finalURL.Hostname()}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+		if err != nil {
+			// Unknown charset: read the bytes as UTF-8.
+			reader = bytes.NewReader(body)
+		}
+		p, err := parsePage(reader, f.archive)
+		if err != nil {
+			return Preview{}, fmt.Errorf("parse page: %w", err)
+		}
+		preview.Title = truncate(firstNonEmpty(p.ogTitle, p.title), maxTitleRunes)
+		preview.Description = truncate(p.description, maxDescRunes)
+		if p.siteName != "" {
+			preview.SiteName = truncate(p.siteName, maxSiteNameRunes)
+		}
+		preview.Text = truncate(p.text, MaxArchiveRunes)
+		if p.image != "" {
+			f.fetchImage(ctx, finalURL, p.image, &preview)
+		}
+	case strings.HasPrefix(mediaType, "image/"):
+		preview.Image, preview.ImageContentType = body, mediaType
+	}
+	return preview, nil
+}
+
+// fetchImage downloads the preview image referenced by the page.
+func (f *Fetcher) fetchImage(ctx context.Context, base *url.URL, ref string, preview *Preview) {
+	imageURL, err := base.Parse(ref)
+	if err != nil || checkURL(imageURL) != nil {
+		return
+	}
+	data, contentType, _, err := f.get(ctx, imageURL.String(), "image/*")
+	if err != nil {
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if !strings.HasPrefix(mediaType, "image/") {
+		return
+	}
+	preview.Image, preview.ImageContentType = data, mediaType
+}
+
+// get downloads rawURL, refusing bodies over maxBytes, and returns the body,
+// its content type and the URL after redirects.
+func (f *Fetcher) get(ctx context.Context, rawURL, accept string) ([]byte, string, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", nil, ErrInvalidURL
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", accept)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedAddress) {
+			return nil, "", nil, ErrBlockedAddress
+		}
+		if errors.Is(err, ErrInvalidURL) {
+			return nil, "", nil, ErrInvalidURL
+		}
+		return nil, "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, "", nil, fmt.Errorf("%w: %d", ErrHTTPStatus, resp.StatusCode)
+	}
+	if resp.ContentLength > f.maxBytes {
+		return nil, "", nil, ErrTooLarge
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if int64(len(body)) > f.maxBytes {
+		return nil, "", nil, ErrTooLarge
+	}
+	return body, resp.Header.Get("Content-Type"), resp.Request.URL, nil
+}
+
+// checkURL allows absolute http(s) URLs without credentials. Addresses are
+// checked when connecting.
+func checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrInvalidURL
+	}
+	if u.Host == "" || u.User != nil {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+// Package logging provides the structured (log/slog, JSON) logger, request
+// IDs and the HTTP access log.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/fdg312/health-hub/internal/userctx"
+)
+
+// New returns a JSON logger writing to w. level is LOG_LEVEL
+// (debug | info | warn | error); anything else means info.
+func New(w io.Writer, level string) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: parseLevel(level)}))
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// FromContext returns the default logger bound to the request in ctx:
+// every record carries request_id and user_id when they are known.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if requestID := RequestID(ctx); requestID != "" {
+		logger = logger.With("request_id", requestID)
+	}
+	if userID, ok := userctx.GetUserID(ctx); ok && userID != "" {
+		logger = logger.With("user_id", userID)
+	}
+	return logger
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+// Middleware assigns a request ID (propagating a sane X-Request-ID from the
+// client), echoes it on the response and writes one access log record per
+// request. It must be the outermost middleware so that responses written by
+// CORS, rate limiting and auth are logged too.
+func Middleware(logger *slog.Logger, clientIP func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := strings.TrimSpace(r.Header.Get(HeaderRequestID))
+		if requestID == "" || len(requestID) > maxRequestIDLength || strings.ContainsAny(requestID, "\r\n\"") {
+			requestID = uuid.NewString()
+		}
+		info := &requestInfo{id: requestID}
+		w.Header().Set(HeaderRequestID, requestID)
+
+		rec := NewStatusRecorder(w)
+		next.ServeHTTP(rec, r.WithContext(withRequestInfo(r.Context(), info)))
+
+		level := slog.LevelInfo
+		switch {
+		case rec.Status() >= 500:
+			level = slog.LevelError
+		case r.URL.Path == "/healthz":
+			level = slog.LevelDebug
+		}
+
+		attrs := []slog.Attr{
+			slog.String("request_id", requestID),
+			slog.String("method", r.Method),
+			slog.String("route", info.route),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.Status()),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int64("bytes", rec.Bytes()),
+			slog.String("ip", clientIP(r)),
+		}
+		if info.userID != "" {
+			attrs = append(attrs, slog.String("user_id", info.userID))
+		}
+		if info.profileID != "" {
+			attrs = append(attrs, slog.String("profile_id", info.profileID))
+		}
+		logger.LogAttrs(r.Context(), level, "http_request", attrs...)
+	})
+}
+
+// RouteMiddleware wraps the mux and copies the matched route, the
+// authenticated user and the profile ID into the access log record.
+// It must sit inside the auth middleware and directly around the mux:
+// ServeMux sets Pattern on the request it is given.
+func RouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		info := requestInfoFrom(r.Context())
+		if info == nil {
+			return
+		}
+		if _, route, ok := strings.Cut(r.Pattern, " "); ok {
+			info.route = route
+		} else {
+			info.route = r.Pattern
+		}
+		if userID, ok := userctx.GetUserID(r.Context()); ok {
+			info.userID = userID
+		}
+		if info.profileID == "" {
+			info.profileID = profileIDFromRequest(r)
+		}
+	})
+}
+
+func profileIDFromRequest(r *http.Request) string {
+	if id := r.URL.Query().Get("profile_id"); id != "" {
+		if _, err := uuid.Parse(id); err == nil {
+			return id
+		}
+	}
+	if strings.HasSuffix(r.Pattern, " /v1/profiles/") {
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/profiles/"), "/")
+		if _, err := uuid.Parse(id); err == nil {
+			return id
+		}
+	}
+	return ""
+}
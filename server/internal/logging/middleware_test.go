@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fdg312/health-hub/internal/userctx"
+)
+
+func testIP(*http.Request) string { return "203.0.113.7" }
+
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("log line is not JSON: %q", line)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestMiddlewareWritesAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "info")
+	profileID := "7f1d2c4e-0000-4000-8000-000000000001"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/checkins", func(w http.ResponseWriter, r *http.Request) {
+		if RequestID(r.Context()) != "abc-123" {
+			t.Errorf("expected request id in handler context, got %q", RequestID(r.Context()))
+		}
+		w.WriteHeader(http.StatusTeapot)
+	})
+	// Auth runs between the access log and the router in the real chain.
+	withUser := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RouteMiddleware(mux).ServeHTTP(w, r.WithContext(userctx.WithUserID(r.Context(), "userA")))
+	})
+	handler := Middleware(logger, testIP, withUser)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/checkins?profile_id="+profileID, nil)
+	req.Header.Set(HeaderRequestID, "abc-123")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Header().Get(HeaderRequestID) != "abc-123" {
+		t.Fatalf("expected request id to be echoed, got %q", w.Header().Get(HeaderRequestID))
+	}
+
+	records := decodeLogLines(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 access log record, got %d: %s", len(records), buf.String())
+	}
+	rec := records[0]
+	expect := map[string]any{
+		"msg":        "http_request",
+		"request_id": "abc-123",
+		"method":     "GET",
+		"route":      "/v1/checkins",
+		"status":     float64(http.StatusTeapot),
+		"user_id":    "userA",
+		"profile_id": profileID,
+		"ip":         "203.0.113.7",
+	}
+	for key, want := range expect {
+		if rec[key] != want {
+			t.Errorf("expected %s=%v, got %v", key, want, rec[key])
+		}
+	}
+	if _, ok := rec["latency_ms"]; !ok {
+		t.Errorf("expected latency_ms in access log")
+	}
+}
+
+func TestMiddlewareReplacesUnsafeRequestID(t *testing.T) {
+	handler := Middleware(New(&bytes.Buffer{}, "info"), testIP, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/feed", nil)
+	req.Header.Set(HeaderRequestID, strings.Repeat("x", maxRequestIDLength+1))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	got := w.Header().Get(HeaderRequestID)
+	if got == "" || len(got) > maxRequestIDLength {
+		t.Fatalf("expected generated request id, got %q", got)
+	}
+}
+
+func TestFromContextAddsRequestAndUser(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(New(&buf, "info"))
+	defer slog.SetDefault(prev)
+
+	ctx := userctx.WithUserID(WithRequestID(context.Background(), "req-9"), "userB")
+	FromContext(ctx).Error("storage failed", "error", "boom")
+
+	records := decodeLogLines(t, &buf)
+	if len(records) != 1 || records[0]["request_id"] != "req-9" || records[0]["user_id"] != "userB" {
+		t.Fatalf("expected request_id and user_id on log record, got %s", buf.String())
+	}
+}
+
+func TestStatusRecorderPassesThroughFlushAndUnwrap(t *testing.T) {
+	inner := httptest.NewRecorder()
+	outer := NewStatusRecorder(NewStatusRecorder(inner))
+
+	if err := http.NewResponseController(outer).Flush(); err != nil {
+		t.Fatalf("flush through stacked recorders failed: %v", err)
+	}
+	if !inner.Flushed {
+		t.Fatal("expected the underlying writer to be flushed")
+	}
+
+	outer.WriteHeader(http.StatusAccepted)
+	_, _ = outer.Write([]byte("ok"))
+	if outer.Status() != http.StatusAccepted || outer.Bytes() != 2 {
+		t.Fatalf("unexpected status %d or bytes %d", outer.Status(), outer.Bytes())
+	}
+}
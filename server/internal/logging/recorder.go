@@ -0,0 +1,57 @@
+package logging
+
+import "net/http"
+
+// StatusRecorder wraps a ResponseWriter and records the status code and the
+// number of body bytes written. The access log, audit and telemetry
+// middlewares all use it, so a streaming handler behind any of them sees the
+// same Flush and Unwrap behaviour.
+type StatusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// NewStatusRecorder wraps w. The status defaults to 200 until the handler
+// writes a header.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Status returns the first status code written.
+func (r *StatusRecorder) Status() int {
+	return r.status
+}
+
+// Bytes returns the number of body bytes written.
+func (r *StatusRecorder) Bytes() int64 {
+	return r.bytes
+}
+
+func (r *StatusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *StatusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush keeps streaming handlers working behind the recorder.
+func (r *StatusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *StatusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
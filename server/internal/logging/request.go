@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+)
+
+// HeaderRequestID is accepted from clients and echoed on every response.
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied IDs; longer ones are replaced.
+const maxRequestIDLength = 128
+
+type requestInfoKey struct{}
+
+// requestInfo is attached to the context by Middleware. Inner middleware
+// fills in what only becomes known after routing and auth.
+type requestInfo struct {
+	id        string
+	route     string
+	userID    string
+	profileID string
+}
+
+func withRequestInfo(ctx context.Context, info *requestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// RequestID returns the ID of the request in ctx, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	if info := requestInfoFrom(ctx); info != nil {
+		return info.id
+	}
+	return ""
+}
+
+// Route returns the matched route pattern once RouteMiddleware has run,
+// or "" when the request did not match any route.
+func Route(ctx context.Context) string {
+	if info := requestInfoFrom(ctx); info != nil {
+		return info.route
+	}
+	return ""
+}
+
+// WithRequestID attaches a request ID to ctx outside of Middleware
+// (background jobs, tests).
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return withRequestInfo(ctx, &requestInfo{id: requestID})
+}
+
+// SetProfileID records the profile a request acts on for the access log,
+// for handlers and middleware that resolve it from the body.
+func SetProfileID(ctx context.Context, profileID string) {
+	if info := requestInfoFrom(ctx); info != nil && profileID != "" {
+		info.profileID = profileID
+	}
+}
+
+// ResponseRequestID returns the request ID Middleware set on the response.
+// Error writers use it so that error bodies can be correlated with logs.
+func ResponseRequestID(w http.ResponseWriter) string {
+	return w.Header().Get(HeaderRequestID)
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/fdg312/health-hub/internal/logging"
 	"github.com/fdg312/health-hub/internal/userctx"
 )
 
@@ -36,6 +37,7 @@ func (h *Handler) HandleGet(w http.ResponseWriter, r *http.Request) {
 
 	plan, items, found, err := h.service.GetActive(ctx, ownerUserID, profileID)
 	if err != nil {
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to get meal plan")
 		return
 	}
@@ -84,6 +86,7 @@ func (h *Handler) HandleReplace(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusBadRequest, "invalid_request", errMsg[19:])
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to replace meal plan")
 		return
 	}
@@ -120,6 +123,7 @@ func (h *Handler) HandleGetToday(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to get today's meal plan")
 		return
 	}
@@ -159,6 +163,7 @@ func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 
 	err := h.service.DeleteActive(ctx, ownerUserID, profileID)
 	if err != nil {
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to delete meal plan")
 		return
 	}
@@ -170,10 +175,14 @@ func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 func writeError(w http.ResponseWriter, status int, code, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
+	detail := map[string]string{
+		"code":    code,
+		"message": message,
+	}
+	if requestID := logging.ResponseRequestID(w); requestID != "" {
+		detail["request_id"] = requestID
+	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": map[string]string{
-			"code":    code,
-			"message": message,
-		},
+		"error": detail,
 	})
 }
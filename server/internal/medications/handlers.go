@@ -0,0 +1,248 @@
+package medications
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// HandleList handles GET /v1/medications?profile_id=&include_inactive=
+func (h *Handler) HandleList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	profileID, ok := parseProfileID(w, query.Get("profile_id"))
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.ListMedications(r.Context(), profileID, query.Get("include_inactive") == "true")
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleCreate handles POST /v1/medications
+func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var req CreateMedicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.CreateMedication(r.Context(), req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// HandleGet handles GET /v1/medications/{id}
+func (h *Handler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r, "invalid medication id")
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.GetMedication(r.Context(), id)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleUpdate handles PATCH /v1/medications/{id}
+func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r, "invalid medication id")
+	if !ok {
+		return
+	}
+	var req UpdateMedicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.UpdateMedication(r.Context(), id, req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleDelete handles DELETE /v1/medications/{id}
+func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r, "invalid medication id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteMedication(r.Context(), id); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRefill handles POST /v1/medications/{id}/refill
+func (h *Handler) HandleRefill(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r, "invalid medication id")
+	if !ok {
+		return
+	}
+	var req RefillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.Refill(r.Context(), id, req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleLogDose handles POST /v1/medications/{id}/doses
+func (h *Handler) HandleLogDose(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r, "invalid medication id")
+	if !ok {
+		return
+	}
+	var req LogDoseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.LogDose(r.Context(), id, req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// HandleListDoses handles GET /v1/medications/doses?profile_id=&medication_id=&from=&to=
+func (h *Handler) HandleListDoses(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	profileID, ok := parseProfileID(w, query.Get("profile_id"))
+	if !ok {
+		return
+	}
+	medicationID := uuid.Nil
+	if value := strings.TrimSpace(query.Get("medication_id")); value != "" {
+		var err error
+		if medicationID, err = uuid.Parse(value); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "invalid medication_id")
+			return
+		}
+	}
+
+	resp, err := h.service.ListDoses(r.Context(), profileID, medicationID, query.Get("from"), query.Get("to"))
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleDeleteDose handles DELETE /v1/medications/doses/{id}
+func (h *Handler) HandleDeleteDose(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r, "invalid dose id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteDose(r.Context(), id); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleInteractions handles GET /v1/medications/interactions?profile_id=
+func (h *Handler) HandleInteractions(w http.ResponseWriter, r *http.Request) {
+	profileID, ok := parseProfileID(w, r.URL.Query().Get("profile_id"))
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.Interactions(r.Context(), profileID)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+	case errors.Is(err, ErrProfileNotFound):
+		writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
+	case errors.Is(err, ErrMedicationNotFound):
+		writeError(w, http.StatusNotFound, "medication_not_found", "Medication not found")
+	case errors.Is(err, ErrDoseNotFound):
+		writeError(w, http.StatusNotFound, "dose_not_found", "Dose not found")
+	case errors.Is(err, ErrMedicationNotActive):
+		writeError(w, http.StatusConflict, "medication_not_active", "Medication is not active")
+	case errors.Is(err, ErrDoseLimitReached):
+		writeError(w, http.StatusConflict, "dose_limit_reached", err.Error())
+	default:
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+	}
+}
+
+func parseProfileID(w http.ResponseWriter, value string) (uuid.UUID, bool) {
+	profileID, err := uuid.Parse(strings.TrimSpace(value))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "profile_id is required")
+		return uuid.Nil, false
+	}
+	return profileID, true
+}
+
+func parseID(w http.ResponseWriter, r *http.Request, message string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(strings.TrimSpace(r.PathValue("id")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", message)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{
+		Error: ErrorDetail{
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
+		},
+	})
+}
@@ -0,0 +1,396 @@
+package medications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/storage/memory"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+func TestMedicationsCRUDAndInteractions(t *testing.T) {
+	handler, mem, profileID := setupMedicationsHandler(t)
+
+	supplement := &storage.Supplement{ProfileID: profileID, Name: "Зверобой экстракт"}
+	if err := mem.GetSupplementsStorage().CreateSupplement(context.Background(), supplement); err != nil {
+		t.Fatalf("create supplement failed: %v", err)
+	}
+
+	w := serveMedicationsBody(handler.HandleCreate, http.MethodPost, "/v1/medications", "", "userA", map[string]any{
+		"profile_id": profileID, "name": "Варфарин", "strength": "2.5 mg", "dose_amount": 1, "dose_unit": "tablet",
+		"schedule":   []map[string]any{{"time_minutes": 1200}, {"time_minutes": 480, "amount": 0.5}},
+		"prescriber": " Dr. Ivanova ", "pharmacy": "Аптека на углу", "stock_quantity": 30, "refill_threshold": 10,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d body=%s", w.Code, w.Body.String())
+	}
+	var warfarin MedicationDTO
+	if err := json.NewDecoder(w.Body).Decode(&warfarin); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if warfarin.Form != FormTablet || warfarin.DaysMask != 127 || warfarin.Prescriber != "Dr. Ivanova" ||
+		warfarin.Schedule[0].TimeMinutes != 480 || warfarin.DaysOfSupply == nil || *warfarin.DaysOfSupply != 20 {
+		t.Fatalf("unexpected medication %+v", warfarin)
+	}
+	if len(warfarin.Interactions) != 1 || warfarin.Interactions[0].Severity != SeverityMajor ||
+		warfarin.Interactions[0].With.Kind != KindSupplement || warfarin.Interactions[0].With.ID != supplement.ID {
+		t.Fatalf("expected the St John's wort warning, got %+v", warfarin.Interactions)
+	}
+
+	w = serveMedicationsBody(handler.HandleCreate, http.MethodPost, "/v1/medications", "", "userA", map[string]any{
+		"profile_id": profileID, "name": "Нурофен", "ingredients": []string{"Ibuprofen"}, "dose_amount": 200,
+		"dose_unit": "mg", "as_needed": true,
+	})
+	var ibuprofen MedicationDTO
+	if err := json.NewDecoder(w.Body).Decode(&ibuprofen); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if w.Code != http.StatusCreated || len(ibuprofen.Interactions) != 1 || ibuprofen.Interactions[0].With.ID != warfarin.ID ||
+		ibuprofen.Interactions[0].Severity != SeverityMajor || ibuprofen.Ingredients[0] != "ibuprofen" {
+		t.Fatalf("expected the bleeding warning, got %d %+v", w.Code, ibuprofen)
+	}
+
+	w = serveMedications(handler.HandleInteractions, http.MethodGet, "/v1/medications/interactions?profile_id="+profileID.String(), "", "userA")
+	var all InteractionsResponse
+	if err := json.NewDecoder(w.Body).Decode(&all); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if w.Code != http.StatusOK || len(all.Interactions) != 2 || all.Disclaimer == "" {
+		t.Fatalf("expected both pairs, got %d %+v", w.Code, all)
+	}
+
+	for name, body := range map[string]map[string]any{
+		"missing name":  {"profile_id": profileID, "dose_amount": 1, "dose_unit": "tablet"},
+		"zero dose":     {"profile_id": profileID, "name": "X", "dose_amount": 0, "dose_unit": "tablet"},
+		"unknown form":  {"profile_id": profileID, "name": "X", "form": "powder", "dose_amount": 1, "dose_unit": "g"},
+		"bad time":      {"profile_id": profileID, "name": "X", "dose_amount": 1, "dose_unit": "g", "schedule": []map[string]any{{"time_minutes": 1440}}},
+		"empty mask":    {"profile_id": profileID, "name": "X", "dose_amount": 1, "dose_unit": "g", "days_mask": 0},
+		"negative left": {"profile_id": profileID, "name": "X", "dose_amount": 1, "dose_unit": "g", "stock_quantity": -1},
+	} {
+		w = serveMedicationsBody(handler.HandleCreate, http.MethodPost, "/v1/medications", "", "userA", body)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d body=%s", name, w.Code, w.Body.String())
+		}
+	}
+
+	id := ibuprofen.ID.String()
+	w = serveMedicationsBody(handler.HandleUpdate, http.MethodPatch, "/v1/medications/"+id, id, "userA", map[string]any{"active": false})
+	var stopped MedicationDTO
+	if err := json.NewDecoder(w.Body).Decode(&stopped); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if w.Code != http.StatusOK || stopped.Active || stopped.Interactions != nil {
+		t.Fatalf("expected a stopped medication without warnings, got %d %+v", w.Code, stopped)
+	}
+	w = serveMedications(handler.HandleList, http.MethodGet, "/v1/medications?profile_id="+profileID.String(), "", "userA")
+	var list MedicationsResponse
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(list.Medications) != 1 || list.Medications[0].ID != warfarin.ID {
+		t.Fatalf("expected only the active medication, got %+v", list.Medications)
+	}
+
+	w = serveMedications(handler.HandleGet, http.MethodGet, "/v1/medications/"+id, id, "userB")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user, got %d", w.Code)
+	}
+	w = serveMedications(handler.HandleList, http.MethodGet, "/v1/medications?profile_id="+profileID.String(), "", "userB")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user's profile, got %d", w.Code)
+	}
+	w = serveMedications(handler.HandleDelete, http.MethodDelete, "/v1/medications/"+id, id, "userA")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestMedicationDosesStockAndRefill(t *testing.T) {
+	handler, mem, profileID := setupMedicationsHandler(t)
+
+	w := serveMedicationsBody(handler.HandleCreate, http.MethodPost, "/v1/medications", "", "userA", map[string]any{
+		"profile_id": profileID, "name": "Левотироксин", "dose_amount": 1, "dose_unit": "tablet",
+		"schedule": []map[string]any{{"time_minutes": 420, "amount": 2}}, "stock_quantity": 6, "refill_threshold": 4,
+	})
+	var medication MedicationDTO
+	if err := json.NewDecoder(w.Body).Decode(&medication); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	id := medication.ID.String()
+
+	// 07:10 in UTC+3 is the 07:00 dose of two tablets.
+	w = serveMedicationsBody(handler.HandleLogDose, http.MethodPost, "/v1/medications/"+id+"/doses", id, "userA", map[string]any{
+		"taken_at": "2026-04-20T07:10:00+03:00", "reason": "утро",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d body=%s", w.Code, w.Body.String())
+	}
+	var dose DoseDTO
+	if err := json.NewDecoder(w.Body).Decode(&dose); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if dose.Amount != 2 || dose.Status != DoseTaken || dose.StockRemaining == nil || *dose.StockRemaining != 4 {
+		t.Fatalf("expected the scheduled dose deducted from the stock, got %+v", dose)
+	}
+
+	w = serveMedicationsBody(handler.HandleLogDose, http.MethodPost, "/v1/medications/"+id+"/doses", id, "userA", map[string]any{
+		"taken_at": "2026-04-19T07:00:00+03:00", "status": "skipped",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	reminder, err := handler.service.RefillReminder(context.Background(), profileID, mustDate(t, "2026-04-20"))
+	if err != nil || reminder == nil || reminder.Kind != "medication_refill" || reminder.Severity != "warn" ||
+		reminder.Body != "Левотироксин — осталось 4 tablet (~2 дн.)." {
+		t.Fatalf("expected a low-stock warning, got %+v %v", reminder, err)
+	}
+
+	w = serveMedications(handler.HandleListDoses, http.MethodGet,
+		"/v1/medications/doses?profile_id="+profileID.String()+"&medication_id="+id+"&from=2026-04-19&to=2026-04-20", "", "userA")
+	var doses DosesResponse
+	if err := json.NewDecoder(w.Body).Decode(&doses); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if w.Code != http.StatusOK || len(doses.Doses) != 2 || doses.Doses[0].Status != DoseSkipped || doses.Doses[1].Reason != "утро" {
+		t.Fatalf("unexpected doses %d %+v", w.Code, doses)
+	}
+
+	doseID := dose.ID.String()
+	w = serveMedications(handler.HandleDeleteDose, http.MethodDelete, "/v1/medications/doses/"+doseID, doseID, "userB")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user, got %d", w.Code)
+	}
+	w = serveMedications(handler.HandleDeleteDose, http.MethodDelete, "/v1/medications/doses/"+doseID, doseID, "userA")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	w = serveMedicationsBody(handler.HandleRefill, http.MethodPost, "/v1/medications/"+id+"/refill", id, "userA", map[string]any{"quantity": 30})
+	var refilled MedicationDTO
+	if err := json.NewDecoder(w.Body).Decode(&refilled); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if w.Code != http.StatusOK || refilled.StockQuantity == nil || *refilled.StockQuantity != 36 || refilled.NeedsRefill {
+		t.Fatalf("expected the deleted dose returned and the refill added, got %d %+v", w.Code, refilled)
+	}
+	if reminder, err := handler.service.RefillReminder(context.Background(), profileID, mustDate(t, "2026-04-20")); err != nil || reminder != nil {
+		t.Fatalf("expected no reminder after the refill, got %+v %v", reminder, err)
+	}
+
+	stored, _, _ := mem.GetMedicationsStorage().GetMedication(context.Background(), medication.ID)
+	if stored.StockQuantity == nil || *stored.StockQuantity != 36 {
+		t.Fatalf("unexpected stored stock %+v", stored.StockQuantity)
+	}
+}
+
+func TestDeletedDoseRestoresOnlyDeductedStock(t *testing.T) {
+	handler, mem, profileID := setupMedicationsHandler(t)
+	ctx := context.Background()
+
+	create := func(body map[string]any) string {
+		w := serveMedicationsBody(handler.HandleCreate, http.MethodPost, "/v1/medications", "", "userA", body)
+		var medication MedicationDTO
+		if err := json.NewDecoder(w.Body).Decode(&medication); err != nil {
+			t.Fatalf("decode response failed: %v", err)
+		}
+		return medication.ID.String()
+	}
+	logDose := func(id string, amount float64) string {
+		w := serveMedicationsBody(handler.HandleLogDose, http.MethodPost, "/v1/medications/"+id+"/doses", id, "userA", map[string]any{
+			"taken_at": "2026-04-20T08:00:00Z", "amount": amount,
+		})
+		var dose DoseDTO
+		if err := json.NewDecoder(w.Body).Decode(&dose); err != nil || w.Code != http.StatusCreated {
+			t.Fatalf("log dose failed: %d %v", w.Code, err)
+		}
+		return dose.ID.String()
+	}
+	deleteDose := func(doseID string) {
+		w := serveMedications(handler.HandleDeleteDose, http.MethodDelete, "/v1/medications/doses/"+doseID, doseID, "userA")
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d body=%s", w.Code, w.Body.String())
+		}
+	}
+	stock := func(id string) *float64 {
+		stored, _, _ := mem.GetMedicationsStorage().GetMedication(ctx, uuid.MustParse(id))
+		return stored.StockQuantity
+	}
+
+	// A dose of 3 with 2 left empties the stock; deleting it gives back 2.
+	short := create(map[string]any{"profile_id": profileID, "name": "Сироп", "dose_amount": 3, "dose_unit": "ml", "stock_quantity": 2})
+	doseID := logDose(short, 3)
+	if got := stock(short); got == nil || *got != 0 {
+		t.Fatalf("expected an empty stock, got %v", got)
+	}
+	deleteDose(doseID)
+	if got := stock(short); got == nil || *got != 2 {
+		t.Fatalf("expected the stock restored to 2, got %v", got)
+	}
+
+	// A dose logged before stock was tracked gives nothing back.
+	untracked := create(map[string]any{"profile_id": profileID, "name": "Капли", "dose_amount": 1, "dose_unit": "ml"})
+	doseID = logDose(untracked, 1)
+	w := serveMedicationsBody(handler.HandleRefill, http.MethodPost, "/v1/medications/"+untracked+"/refill", untracked, "userA", map[string]any{"quantity": 10})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	deleteDose(doseID)
+	if got := stock(untracked); got == nil || *got != 10 {
+		t.Fatalf("expected the stock to stay 10, got %v", got)
+	}
+}
+
+func TestUpdateKeepsConcurrentStockChanges(t *testing.T) {
+	_, mem, profileID := setupMedicationsHandler(t)
+	racing := &racingMedications{MedicationsStorage: mem.GetMedicationsStorage()}
+	handler := NewHandler(NewService(racing, mem))
+
+	w := serveMedicationsBody(handler.HandleCreate, http.MethodPost, "/v1/medications", "", "userA", map[string]any{
+		"profile_id": profileID, "name": "Метформин", "dose_amount": 1, "dose_unit": "tablet", "stock_quantity": 10,
+	})
+	var medication MedicationDTO
+	if err := json.NewDecoder(w.Body).Decode(&medication); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	id := medication.ID.String()
+
+	// A dose lands between the PATCH reading the medication and writing it.
+	racing.deduct = 1
+	w = serveMedicationsBody(handler.HandleUpdate, http.MethodPatch, "/v1/medications/"+id, id, "userA", map[string]any{"name": "Метформин 500"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if err := json.NewDecoder(w.Body).Decode(&medication); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if medication.Name != "Метформин 500" || medication.StockQuantity == nil || *medication.StockQuantity != 9 {
+		t.Fatalf("expected the rename to keep the deducted stock 9, got %+v", medication)
+	}
+
+	// Setting stock_quantity explicitly still overwrites it.
+	racing.deduct = 1
+	w = serveMedicationsBody(handler.HandleUpdate, http.MethodPatch, "/v1/medications/"+id, id, "userA", map[string]any{"stock_quantity": 30})
+	if err := json.NewDecoder(w.Body).Decode(&medication); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if medication.StockQuantity == nil || *medication.StockQuantity != 30 {
+		t.Fatalf("expected stock 30, got %v", medication.StockQuantity)
+	}
+}
+
+// racingMedications deducts stock right after the medication is read, as
+// a dose logged concurrently would.
+type racingMedications struct {
+	storage.MedicationsStorage
+	deduct float64
+}
+
+func (r *racingMedications) GetMedication(ctx context.Context, id uuid.UUID) (storage.Medication, bool, error) {
+	medication, ok, err := r.MedicationsStorage.GetMedication(ctx, id)
+	if ok && r.deduct > 0 {
+		_, _, _ = r.MedicationsStorage.AdjustMedicationStock(ctx, id, -r.deduct)
+		r.deduct = 0
+	}
+	return medication, ok, err
+}
+
+func TestAsNeededDoseLimits(t *testing.T) {
+	handler, _, profileID := setupMedicationsHandler(t)
+
+	w := serveMedicationsBody(handler.HandleCreate, http.MethodPost, "/v1/medications", "", "userA", map[string]any{
+		"profile_id": profileID, "name": "Парацетамол", "dose_amount": 500, "dose_unit": "mg",
+		"as_needed": true, "max_daily_doses": 2, "min_interval_hours": 4,
+	})
+	var medication MedicationDTO
+	if err := json.NewDecoder(w.Body).Decode(&medication); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	id := medication.ID.String()
+
+	logDose := func(takenAt string) *httptest.ResponseRecorder {
+		return serveMedicationsBody(handler.HandleLogDose, http.MethodPost, "/v1/medications/"+id+"/doses", id, "userA",
+			map[string]any{"taken_at": takenAt, "reason": "головная боль"})
+	}
+	if w = logDose("2026-04-19T08:00:00Z"); w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w = logDose("2026-04-19T10:00:00Z"); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 inside the interval, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w = logDose("2026-04-19T13:00:00Z"); w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w = logDose("2026-04-19T20:00:00Z"); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 over the daily limit, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w = logDose("2026-04-20T08:30:00Z"); w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201 once the first dose left the window, got %d body=%s", w.Code, w.Body.String())
+	}
+	var dose DoseDTO
+	if err := json.NewDecoder(w.Body).Decode(&dose); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if !dose.AsNeeded || dose.Amount != 500 || dose.StockRemaining != nil {
+		t.Fatalf("unexpected as-needed dose %+v", dose)
+	}
+	if w = logDose("2026-04-20T12:00:00Z"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a future dose, got %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func setupMedicationsHandler(t *testing.T) (*Handler, *memory.MemoryStorage, uuid.UUID) {
+	t.Helper()
+
+	mem := memory.New()
+	profileID := uuid.New()
+	for _, profile := range []storage.Profile{
+		{ID: profileID, OwnerUserID: "userA", Type: "guest", Name: "Мама"},
+		{ID: uuid.New(), OwnerUserID: "userB", Type: "owner", Name: "User B"},
+	} {
+		if err := mem.CreateProfile(context.Background(), &profile); err != nil {
+			t.Fatalf("create profile failed: %v", err)
+		}
+	}
+
+	service := NewService(mem.GetMedicationsStorage(), mem).WithSupplements(mem.GetSupplementsStorage())
+	service.now = func() time.Time { return time.Date(2026, 4, 20, 9, 0, 0, 0, time.UTC) }
+	return NewHandler(service), mem, profileID
+}
+
+func mustDate(t *testing.T, value string) time.Time {
+	t.Helper()
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		t.Fatalf("parse date failed: %v", err)
+	}
+	return date
+}
+
+func serveMedications(handle http.HandlerFunc, method, path, id, userID string) *httptest.ResponseRecorder {
+	return serveMedicationsBody(handle, method, path, id, userID, nil)
+}
+
+func serveMedicationsBody(handle http.HandlerFunc, method, path, id, userID string, body any) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if id != "" {
+		req.SetPathValue("id", id)
+	}
+	req = req.WithContext(userctx.WithUserID(context.Background(), userID))
+	w := httptest.NewRecorder()
+	handle(w, req)
+	return w
+}
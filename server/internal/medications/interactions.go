@@ -0,0 +1,341 @@
+package medications
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Interaction severities, most severe first.
+const (
+	SeverityMajor    = "major"
+	SeverityModerate = "moderate"
+	SeverityMinor    = "minor"
+)
+
+// Kinds of items an interaction is found between.
+const (
+	KindMedication = "medication"
+	KindSupplement = "supplement"
+)
+
+// Disclaimer is returned with every interaction check.
+const Disclaimer = "Проверка по встроенной таблице частых взаимодействий. Она неполная и не заменяет консультацию врача или фармацевта."
+
+var severityRank = map[string]int{SeverityMajor: 0, SeverityModerate: 1, SeverityMinor: 2}
+
+// substance is an active ingredient or supplement of the interaction table.
+// Rules refer to substances by key or by class.
+type substance struct {
+	key     string
+	name    string
+	classes []string
+	// aliases are matched as substrings of lowercased names, ingredients
+	// and nutrient keys: generic and brand names, Russian and English.
+	aliases []string
+}
+
+var substances = []substance{
+	{key: "warfarin", name: "варфарин", classes: []string{"anticoagulant", "vitamin_k_antagonist"},
+		aliases: []string{"варфарин", "warfarin", "coumadin"}},
+	{key: "apixaban", name: "апиксабан", classes: []string{"anticoagulant"},
+		aliases: []string{"апиксабан", "эликвис", "apixaban", "eliquis"}},
+	{key: "rivaroxaban", name: "ривароксабан", classes: []string{"anticoagulant"},
+		aliases: []string{"ривароксабан", "ксарелто", "rivaroxaban", "xarelto"}},
+	{key: "dabigatran", name: "дабигатран", classes: []string{"anticoagulant"},
+		aliases: []string{"дабигатран", "прадакса", "dabigatran", "pradaxa"}},
+	{key: "clopidogrel", name: "клопидогрел", classes: []string{"antiplatelet"},
+		aliases: []string{"клопидогрел", "плавикс", "clopidogrel", "plavix"}},
+	{key: "aspirin", name: "ацетилсалициловая кислота", classes: []string{"nsaid", "antiplatelet"},
+		aliases: []string{"аспирин", "ацетилсалицил", "кардиомагнил", "тромбо асс", "aspirin", "acetylsalicylic"}},
+	{key: "ibuprofen", name: "ибупрофен", classes: []string{"nsaid"},
+		aliases: []string{"ибупрофен", "нурофен", "ibuprofen", "advil", "motrin"}},
+	{key: "naproxen", name: "напроксен", classes: []string{"nsaid"},
+		aliases: []string{"напроксен", "налгезин", "naproxen", "aleve"}},
+	{key: "diclofenac", name: "диклофенак", classes: []string{"nsaid"},
+		aliases: []string{"диклофенак", "вольтарен", "diclofenac", "voltaren"}},
+	{key: "ketorolac", name: "кеторолак", classes: []string{"nsaid"},
+		aliases: []string{"кеторолак", "кеторол", "ketorolac"}},
+	{key: "nimesulide", name: "нимесулид", classes: []string{"nsaid"},
+		aliases: []string{"нимесулид", "нимесил", "найз", "nimesulide"}},
+	{key: "meloxicam", name: "мелоксикам", classes: []string{"nsaid"},
+		aliases: []string{"мелоксикам", "мовалис", "meloxicam"}},
+	{key: "sertraline", name: "сертралин", classes: []string{"ssri", "serotonergic"},
+		aliases: []string{"сертралин", "золофт", "sertraline", "zoloft"}},
+	{key: "fluoxetine", name: "флуоксетин", classes: []string{"ssri", "serotonergic"},
+		aliases: []string{"флуоксетин", "прозак", "fluoxetine", "prozac"}},
+	{key: "escitalopram", name: "эсциталопрам", classes: []string{"ssri", "serotonergic"},
+		aliases: []string{"эсциталопрам", "ципралекс", "escitalopram", "lexapro"}},
+	{key: "paroxetine", name: "пароксетин", classes: []string{"ssri", "serotonergic"},
+		aliases: []string{"пароксетин", "паксил", "paroxetine", "paxil"}},
+	{key: "tramadol", name: "трамадол", classes: []string{"serotonergic"},
+		aliases: []string{"трамадол", "tramadol"}},
+	{key: "sumatriptan", name: "суматриптан", classes: []string{"serotonergic"},
+		aliases: []string{"суматриптан", "sumatriptan"}},
+	{key: "lisinopril", name: "лизиноприл", classes: []string{"ace_inhibitor"},
+		aliases: []string{"лизиноприл", "lisinopril"}},
+	{key: "enalapril", name: "эналаприл", classes: []string{"ace_inhibitor"},
+		aliases: []string{"эналаприл", "enalapril"}},
+	{key: "ramipril", name: "рамиприл", classes: []string{"ace_inhibitor"},
+		aliases: []string{"рамиприл", "ramipril"}},
+	{key: "perindopril", name: "периндоприл", classes: []string{"ace_inhibitor"},
+		aliases: []string{"периндоприл", "престариум", "perindopril"}},
+	{key: "losartan", name: "лозартан", classes: []string{"arb"},
+		aliases: []string{"лозартан", "лориста", "losartan"}},
+	{key: "valsartan", name: "валсартан", classes: []string{"arb"},
+		aliases: []string{"валсартан", "valsartan"}},
+	{key: "spironolactone", name: "спиронолактон", classes: []string{"potassium_sparing_diuretic"},
+		aliases: []string{"спиронолактон", "верошпирон", "spironolactone"}},
+	{key: "levothyroxine", name: "левотироксин", classes: []string{"thyroid_hormone"},
+		aliases: []string{"левотироксин", "эутирокс", "l-тироксин", "levothyroxine", "euthyrox", "synthroid"}},
+	{key: "ciprofloxacin", name: "ципрофлоксацин", classes: []string{"quinolone_or_tetracycline"},
+		aliases: []string{"ципрофлоксацин", "ципролет", "ciprofloxacin"}},
+	{key: "levofloxacin", name: "левофлоксацин", classes: []string{"quinolone_or_tetracycline"},
+		aliases: []string{"левофлоксацин", "levofloxacin"}},
+	{key: "doxycycline", name: "доксициклин", classes: []string{"quinolone_or_tetracycline"},
+		aliases: []string{"доксициклин", "юнидокс", "doxycycline"}},
+	{key: "alendronate", name: "алендроновая кислота", classes: []string{"bisphosphonate"},
+		aliases: []string{"алендрон", "фосамакс", "alendron"}},
+	{key: "clarithromycin", name: "кларитромицин", classes: []string{"strong_cyp3a4_inhibitor"},
+		aliases: []string{"кларитромицин", "клацид", "clarithromycin"}},
+	{key: "simvastatin", name: "симвастатин", classes: []string{"cyp3a4_statin"},
+		aliases: []string{"симвастатин", "simvastatin"}},
+	{key: "atorvastatin", name: "аторвастатин", classes: []string{"cyp3a4_statin"},
+		aliases: []string{"аторвастатин", "аторис", "липримар", "atorvastatin", "lipitor"}},
+	{key: "digoxin", name: "дигоксин", aliases: []string{"дигоксин", "digoxin"}},
+	{key: "lithium", name: "лития карбонат", aliases: []string{"лития", "литий", "lithium"}},
+	{key: "methotrexate", name: "метотрексат", aliases: []string{"метотрексат", "methotrexate"}},
+	{key: "sildenafil", name: "силденафил", classes: []string{"pde5_inhibitor"},
+		aliases: []string{"силденафил", "виагра", "sildenafil", "viagra"}},
+	{key: "tadalafil", name: "тадалафил", classes: []string{"pde5_inhibitor"},
+		aliases: []string{"тадалафил", "сиалис", "tadalafil", "cialis"}},
+	{key: "nitroglycerin", name: "нитроглицерин", classes: []string{"nitrate"},
+		aliases: []string{"нитроглицерин", "nitroglycerin"}},
+	{key: "isosorbide", name: "изосорбид", classes: []string{"nitrate"},
+		aliases: []string{"изосорбид", "кардикет", "моночинкве", "isosorbide"}},
+	{key: "ethinylestradiol", name: "этинилэстрадиол", classes: []string{"hormonal_contraceptive"},
+		aliases: []string{"этинилэстрадиол", "ethinylestradiol", "ethinyl estradiol"}},
+	{key: "metformin", name: "метформин", aliases: []string{"метформин", "глюкофаж", "сиофор", "metformin"}},
+
+	// Supplements and foods.
+	{key: "st_johns_wort", name: "зверобой", aliases: []string{"зверобой", "st john", "st. john", "hypericum"}},
+	{key: "vitamin_k", name: "витамин K", aliases: []string{"витамин k", "витамин к", "vitamin k", "vitamin_k"}},
+	{key: "omega_3", name: "омега-3", aliases: []string{"омега", "рыбий жир", "omega", "fish oil", "fish_oil"}},
+	{key: "ginkgo", name: "гинкго", aliases: []string{"гинкго", "ginkgo"}},
+	{key: "calcium", name: "кальций", classes: []string{"polyvalent_mineral"}, aliases: []string{"кальци", "calcium"}},
+	{key: "iron", name: "железо", classes: []string{"polyvalent_mineral"}, aliases: []string{"желез", "iron", "ferrum"}},
+	{key: "magnesium", name: "магний", classes: []string{"polyvalent_mineral"}, aliases: []string{"магни", "магне", "magnesium"}},
+	{key: "zinc", name: "цинк", classes: []string{"polyvalent_mineral"}, aliases: []string{"цинк", "zinc"}},
+	{key: "potassium", name: "калий", aliases: []string{"калий", "калия", "аспаркам", "панангин", "potassium"}},
+	{key: "grapefruit", name: "грейпфрут", aliases: []string{"грейпфрут", "grapefruit"}},
+}
+
+// interactionRule fires when one item contains any of a and the other any
+// of b, each a substance key or class. Rules are ordered most severe first
+// and only the first matching rule is reported for a pair of items.
+type interactionRule struct {
+	a, b        []string
+	severity    string
+	description string
+}
+
+var interactionRules = []interactionRule{
+	{a: []string{"anticoagulant"}, b: []string{"nsaid"}, severity: SeverityMajor,
+		description: "Вместе повышают риск кровотечений, в том числе желудочно-кишечных."},
+	{a: []string{"anticoagulant"}, b: []string{"antiplatelet"}, severity: SeverityMajor,
+		description: "Антикоагулянт с антиагрегантом заметно повышают риск кровотечений."},
+	{a: []string{"anticoagulant"}, b: []string{"st_johns_wort"}, severity: SeverityMajor,
+		description: "Зверобой ускоряет выведение антикоагулянта и ослабляет его — риск тромбозов."},
+	{a: []string{"serotonergic"}, b: []string{"st_johns_wort"}, severity: SeverityMajor,
+		description: "Зверобой вместе с серотонинергическим препаратом — риск серотонинового синдрома."},
+	{a: []string{"hormonal_contraceptive"}, b: []string{"st_johns_wort"}, severity: SeverityMajor,
+		description: "Зверобой снижает надёжность гормональной контрацепции."},
+	{a: []string{"ace_inhibitor", "arb"}, b: []string{"potassium_sparing_diuretic"}, severity: SeverityMajor,
+		description: "Риск опасного повышения калия в крови (гиперкалиемии)."},
+	{a: []string{"cyp3a4_statin"}, b: []string{"strong_cyp3a4_inhibitor"}, severity: SeverityMajor,
+		description: "Кларитромицин повышает концентрацию статина — риск поражения мышц (рабдомиолиза)."},
+	{a: []string{"lithium"}, b: []string{"nsaid", "ace_inhibitor", "arb"}, severity: SeverityMajor,
+		description: "Препарат задерживает литий в организме — риск литиевой интоксикации."},
+	{a: []string{"methotrexate"}, b: []string{"nsaid"}, severity: SeverityMajor,
+		description: "НПВП замедляют выведение метотрексата — риск его токсичности."},
+	{a: []string{"pde5_inhibitor"}, b: []string{"nitrate"}, severity: SeverityMajor,
+		description: "Резкое падение давления; сочетание противопоказано."},
+	{a: []string{"serotonergic"}, b: []string{"serotonergic"}, severity: SeverityMajor,
+		description: "Два серотонинергических препарата — риск серотонинового синдрома."},
+
+	{a: []string{"ssri"}, b: []string{"nsaid", "anticoagulant", "antiplatelet"}, severity: SeverityModerate,
+		description: "Антидепрессанты группы СИОЗС повышают риск кровотечений вместе с этим препаратом."},
+	{a: []string{"vitamin_k_antagonist"}, b: []string{"vitamin_k"}, severity: SeverityModerate,
+		description: "Витамин K ослабляет действие варфарина: нужен стабильный приём и контроль МНО."},
+	{a: []string{"anticoagulant", "antiplatelet"}, b: []string{"ginkgo"}, severity: SeverityModerate,
+		description: "Гинкго может усиливать кровоточивость."},
+	{a: []string{"ace_inhibitor", "arb", "potassium_sparing_diuretic"}, b: []string{"potassium"}, severity: SeverityModerate,
+		description: "Калий на фоне препарата может повысить калий в крови — нужен контроль анализов."},
+	{a: []string{"ace_inhibitor", "arb"}, b: []string{"nsaid"}, severity: SeverityModerate,
+		description: "НПВП ослабляют действие препаратов от давления и нагружают почки."},
+	{a: []string{"thyroid_hormone"}, b: []string{"calcium", "iron", "magnesium"}, severity: SeverityModerate,
+		description: "Минерал снижает всасывание левотироксина — принимать с интервалом не менее 4 часов."},
+	{a: []string{"quinolone_or_tetracycline"}, b: []string{"polyvalent_mineral"}, severity: SeverityModerate,
+		description: "Минерал связывает антибиотик и снижает его всасывание — антибиотик за 2 часа до или через 6 часов после."},
+	{a: []string{"bisphosphonate"}, b: []string{"polyvalent_mineral"}, severity: SeverityModerate,
+		description: "Минерал снижает всасывание бисфосфоната — принимать его натощак, минералы не раньше чем через час."},
+	{a: []string{"cyp3a4_statin"}, b: []string{"grapefruit"}, severity: SeverityModerate,
+		description: "Грейпфрут повышает концентрацию статина и риск побочных эффектов."},
+	{a: []string{"digoxin"}, b: []string{"st_johns_wort"}, severity: SeverityModerate,
+		description: "Зверобой снижает концентрацию дигоксина."},
+	{a: []string{"nsaid"}, b: []string{"nsaid"}, severity: SeverityModerate,
+		description: "Два НПВП не усиливают обезболивание, но повышают риск для желудка и почек."},
+
+	{a: []string{"anticoagulant", "antiplatelet"}, b: []string{"omega_3"}, severity: SeverityMinor,
+		description: "Омега-3 в высоких дозах может немного усиливать кровоточивость."},
+	{a: []string{"iron"}, b: []string{"calcium"}, severity: SeverityMinor,
+		description: "Кальций снижает всасывание железа — лучше разнести приёмы."},
+}
+
+// checkItem is a medication or supplement reduced to what the interaction
+// table matches on.
+type checkItem struct {
+	ref InteractionItemDTO
+	// substances are the keys of the table substances found in the item;
+	// terms are those keys with their classes.
+	substances map[string]bool
+	terms      map[string]bool
+	// ingredients are the listed active ingredients, lowercased.
+	ingredients []string
+}
+
+// newCheckItem matches the name and the given ingredients (medications) or
+// nutrient keys (supplements) against the substances of the table.
+func newCheckItem(kind string, id uuid.UUID, name string, keys []string) checkItem {
+	item := checkItem{
+		ref:        InteractionItemDTO{Kind: kind, ID: id, Name: name},
+		substances: make(map[string]bool),
+		terms:      make(map[string]bool),
+	}
+	texts := append([]string{name}, keys...)
+	for i, text := range texts {
+		text = strings.ToLower(strings.TrimSpace(text))
+		if text == "" {
+			continue
+		}
+		if i > 0 && kind == KindMedication {
+			item.ingredients = append(item.ingredients, text)
+		}
+		for _, sub := range substances {
+			if !matchesSubstance(text, sub) {
+				continue
+			}
+			item.substances[sub.key] = true
+			item.terms[sub.key] = true
+			for _, class := range sub.classes {
+				item.terms[class] = true
+			}
+		}
+	}
+	return item
+}
+
+func matchesSubstance(text string, sub substance) bool {
+	if text == sub.key {
+		return true
+	}
+	for _, alias := range sub.aliases {
+		if strings.Contains(text, alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// interactionsWith returns the interactions of subject with each of the
+// other items.
+func interactionsWith(subject checkItem, others []checkItem) []InteractionDTO {
+	out := make([]InteractionDTO, 0)
+	for _, other := range others {
+		if other.ref.ID == subject.ref.ID && other.ref.Kind == subject.ref.Kind {
+			continue
+		}
+		if interaction, ok := interactionBetween(subject, other); ok {
+			out = append(out, interaction)
+		}
+	}
+	sortInteractions(out)
+	return out
+}
+
+// allInteractions returns the interactions between every pair of items.
+func allInteractions(items []checkItem) []InteractionDTO {
+	out := make([]InteractionDTO, 0)
+	for i := range items {
+		for j := i + 1; j < len(items); j++ {
+			if interaction, ok := interactionBetween(items[i], items[j]); ok {
+				out = append(out, interaction)
+			}
+		}
+	}
+	sortInteractions(out)
+	return out
+}
+
+// interactionBetween reports the most severe interaction of a pair: the
+// same active ingredient in two medications, or else the first matching
+// table rule.
+func interactionBetween(subject, other checkItem) (InteractionDTO, bool) {
+	interaction := InteractionDTO{Subject: subject.ref, With: other.ref}
+	if subject.ref.Kind == KindMedication && other.ref.Kind == KindMedication {
+		if name, ok := sharedIngredient(subject, other); ok {
+			interaction.Severity = SeverityMajor
+			interaction.Description = fmt.Sprintf("Одно и то же действующее вещество (%s) в двух лекарствах — риск передозировки.", name)
+			return interaction, true
+		}
+	}
+	for _, rule := range interactionRules {
+		if (anyTerm(subject.terms, rule.a) && anyTerm(other.terms, rule.b)) ||
+			(anyTerm(subject.terms, rule.b) && anyTerm(other.terms, rule.a)) {
+			interaction.Severity = rule.severity
+			interaction.Description = rule.description
+			return interaction, true
+		}
+	}
+	return interaction, false
+}
+
+// sharedIngredient returns the name of a table substance or listed
+// ingredient both items contain.
+func sharedIngredient(a, b checkItem) (string, bool) {
+	for _, sub := range substances {
+		if a.substances[sub.key] && b.substances[sub.key] {
+			return sub.name, true
+		}
+	}
+	for _, ingredient := range a.ingredients {
+		if slices.Contains(b.ingredients, ingredient) {
+			return ingredient, true
+		}
+	}
+	return "", false
+}
+
+func anyTerm(terms map[string]bool, keys []string) bool {
+	for _, key := range keys {
+		if terms[key] {
+			return true
+		}
+	}
+	return false
+}
+
+func sortInteractions(interactions []InteractionDTO) {
+	sort.SliceStable(interactions, func(i, j int) bool {
+		if severityRank[interactions[i].Severity] != severityRank[interactions[j].Severity] {
+			return severityRank[interactions[i].Severity] < severityRank[interactions[j].Severity]
+		}
+		if interactions[i].Subject.Name != interactions[j].Subject.Name {
+			return interactions[i].Subject.Name < interactions[j].Subject.Name
+		}
+		return interactions[i].With.Name < interactions[j].With.Name
+	})
+}
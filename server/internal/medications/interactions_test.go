@@ -0,0 +1,50 @@
+package medications
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestInteractionTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		subject  checkItem
+		other    checkItem
+		severity string
+	}{
+		{"brand names", medItem("Кардиомагнил 75 мг"), medItem("Xarelto 20 mg"), SeverityMajor},
+		{"ingredients", medItem("Таблетки от давления", "Losartan"), medItem("Спиронолактон"), SeverityMajor},
+		{"same ingredient", medItem("Нурофен"), medItem("Ибупрофен-Акрихин"), SeverityMajor},
+		{"unknown shared ingredient", medItem("A", "bisoprolol"), medItem("B", "bisoprolol"), SeverityMajor},
+		{"nutrient key", medItem("Эутирокс"), supplementItem("Мультиминерал", "calcium_mg"), SeverityModerate},
+		{"supplement pair", supplementItem("Железо хелат"), supplementItem("Кальций D3"), SeverityMinor},
+		{"same supplement twice", supplementItem("Омега-3"), supplementItem("Рыбий жир"), ""},
+		{"unrelated", medItem("Метформин"), supplementItem("Витамин D3", "vitamin_d"), ""},
+	}
+	for _, tt := range tests {
+		interaction, ok := interactionBetween(tt.subject, tt.other)
+		if tt.severity == "" {
+			if ok {
+				t.Fatalf("%s: expected no interaction, got %+v", tt.name, interaction)
+			}
+			continue
+		}
+		if !ok || interaction.Severity != tt.severity || interaction.Description == "" {
+			t.Fatalf("%s: expected a %s interaction, got %+v", tt.name, tt.severity, interaction)
+		}
+	}
+}
+
+func medItem(name string, ingredients ...string) checkItem {
+	return newCheckItem(KindMedication, uuid.New(), name, normalizeTestIngredients(ingredients))
+}
+
+func supplementItem(name string, nutrientKeys ...string) checkItem {
+	return newCheckItem(KindSupplement, uuid.New(), name, nutrientKeys)
+}
+
+func normalizeTestIngredients(ingredients []string) []string {
+	normalized, _ := normalizeIngredients(ingredients)
+	return normalized
+}
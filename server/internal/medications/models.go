@@ -0,0 +1,170 @@
+package medications
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MedicationDTO is a medication of a profile. Schedule amounts of 0 mean
+// the usual dose_amount. StockQuantity is counted in dose_unit and is null
+// when stock is not tracked; DaysOfSupply is null unless both stock and a
+// schedule are set. days_mask: bit 0 = Monday ... bit 6 = Sunday.
+type MedicationDTO struct {
+	ID               uuid.UUID         `json:"id"`
+	ProfileID        uuid.UUID         `json:"profile_id"`
+	Name             string            `json:"name"`
+	Form             string            `json:"form"`
+	Strength         string            `json:"strength"`
+	Ingredients      []string          `json:"ingredients"`
+	DoseAmount       float64           `json:"dose_amount"`
+	DoseUnit         string            `json:"dose_unit"`
+	Schedule         []ScheduleTimeDTO `json:"schedule"`
+	DaysMask         int               `json:"days_mask"`
+	AsNeeded         bool              `json:"as_needed"`
+	MaxDailyDoses    int               `json:"max_daily_doses"`
+	MinIntervalHours int               `json:"min_interval_hours"`
+	StockQuantity    *float64          `json:"stock_quantity"`
+	RefillThreshold  float64           `json:"refill_threshold"`
+	DaysOfSupply     *float64          `json:"days_of_supply"`
+	NeedsRefill      bool              `json:"needs_refill"`
+	Prescriber       string            `json:"prescriber"`
+	Pharmacy         string            `json:"pharmacy"`
+	Instructions     string            `json:"instructions"`
+	Active           bool              `json:"active"`
+	// Interactions are returned when a medication is created or changed.
+	Interactions []InteractionDTO `json:"interactions,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+}
+
+// ScheduleTimeDTO is a scheduled dose: minutes since midnight and the
+// amount taken then, 0 for the usual dose.
+type ScheduleTimeDTO struct {
+	TimeMinutes int     `json:"time_minutes"`
+	Amount      float64 `json:"amount,omitempty"`
+}
+
+// CreateMedicationRequest adds a medication. Form defaults to tablet and
+// days_mask to every day.
+type CreateMedicationRequest struct {
+	ProfileID        uuid.UUID         `json:"profile_id"`
+	Name             string            `json:"name"`
+	Form             string            `json:"form,omitempty"`
+	Strength         string            `json:"strength,omitempty"`
+	Ingredients      []string          `json:"ingredients,omitempty"`
+	DoseAmount       float64           `json:"dose_amount"`
+	DoseUnit         string            `json:"dose_unit"`
+	Schedule         []ScheduleTimeDTO `json:"schedule,omitempty"`
+	DaysMask         *int              `json:"days_mask,omitempty"`
+	AsNeeded         bool              `json:"as_needed,omitempty"`
+	MaxDailyDoses    int               `json:"max_daily_doses,omitempty"`
+	MinIntervalHours int               `json:"min_interval_hours,omitempty"`
+	StockQuantity    *float64          `json:"stock_quantity,omitempty"`
+	RefillThreshold  float64           `json:"refill_threshold,omitempty"`
+	Prescriber       string            `json:"prescriber,omitempty"`
+	Pharmacy         string            `json:"pharmacy,omitempty"`
+	Instructions     string            `json:"instructions,omitempty"`
+}
+
+// UpdateMedicationRequest changes the given fields. UntrackStock stops
+// tracking stock.
+type UpdateMedicationRequest struct {
+	Name             *string            `json:"name,omitempty"`
+	Form             *string            `json:"form,omitempty"`
+	Strength         *string            `json:"strength,omitempty"`
+	Ingredients      *[]string          `json:"ingredients,omitempty"`
+	DoseAmount       *float64           `json:"dose_amount,omitempty"`
+	DoseUnit         *string            `json:"dose_unit,omitempty"`
+	Schedule         *[]ScheduleTimeDTO `json:"schedule,omitempty"`
+	DaysMask         *int               `json:"days_mask,omitempty"`
+	AsNeeded         *bool              `json:"as_needed,omitempty"`
+	MaxDailyDoses    *int               `json:"max_daily_doses,omitempty"`
+	MinIntervalHours *int               `json:"min_interval_hours,omitempty"`
+	StockQuantity    *float64           `json:"stock_quantity,omitempty"`
+	UntrackStock     bool               `json:"untrack_stock,omitempty"`
+	RefillThreshold  *float64           `json:"refill_threshold,omitempty"`
+	Prescriber       *string            `json:"prescriber,omitempty"`
+	Pharmacy         *string            `json:"pharmacy,omitempty"`
+	Instructions     *string            `json:"instructions,omitempty"`
+	Active           *bool              `json:"active,omitempty"`
+}
+
+// RefillRequest adds a filled prescription to the stock.
+type RefillRequest struct {
+	Quantity float64 `json:"quantity"`
+}
+
+// MedicationsResponse lists medications by name.
+type MedicationsResponse struct {
+	Medications []MedicationDTO `json:"medications"`
+}
+
+// DoseDTO is a logged dose. StockRemaining is returned when a dose is
+// logged for a medication with tracked stock.
+type DoseDTO struct {
+	ID             uuid.UUID `json:"id"`
+	ProfileID      uuid.UUID `json:"profile_id"`
+	MedicationID   uuid.UUID `json:"medication_id"`
+	TakenAt        time.Time `json:"taken_at"`
+	Amount         float64   `json:"amount"`
+	Unit           string    `json:"unit"`
+	Status         string    `json:"status"`
+	AsNeeded       bool      `json:"as_needed"`
+	Reason         string    `json:"reason"`
+	StockRemaining *float64  `json:"stock_remaining,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// LogDoseRequest logs a dose. TakenAt defaults to now, Amount to the
+// scheduled or usual dose and Status to taken. The scheduled dose is
+// looked up by the local time of TakenAt, so it should carry the client's
+// UTC offset.
+type LogDoseRequest struct {
+	TakenAt *time.Time `json:"taken_at,omitempty"`
+	Amount  *float64   `json:"amount,omitempty"`
+	Status  string     `json:"status,omitempty"`
+	Reason  string     `json:"reason,omitempty"`
+}
+
+// DosesResponse lists logged doses, oldest first.
+type DosesResponse struct {
+	From  string    `json:"from"`
+	To    string    `json:"to"`
+	Doses []DoseDTO `json:"doses"`
+}
+
+// InteractionItemDTO is one side of an interaction.
+type InteractionItemDTO struct {
+	Kind string    `json:"kind"`
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// InteractionDTO is a warning from the local interaction table about a
+// medication or supplement (Subject) taken together with another one.
+type InteractionDTO struct {
+	Severity    string             `json:"severity"`
+	Description string             `json:"description"`
+	Subject     InteractionItemDTO `json:"subject"`
+	With        InteractionItemDTO `json:"with"`
+}
+
+// InteractionsResponse lists the interactions among the active medications
+// and supplements of a profile, most severe first.
+type InteractionsResponse struct {
+	ProfileID    uuid.UUID        `json:"profile_id"`
+	Interactions []InteractionDTO `json:"interactions"`
+	Disclaimer   string           `json:"disclaimer"`
+}
+
+// ErrorResponse — стандартный формат ошибки.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
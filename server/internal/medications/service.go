@@ -0,0 +1,827 @@
+package medications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRequest      = errors.New("invalid request")
+	ErrProfileNotFound     = errors.New("profile not found")
+	ErrMedicationNotFound  = errors.New("medication not found")
+	ErrDoseNotFound        = errors.New("dose not found")
+	ErrMedicationNotActive = errors.New("medication is not active")
+	ErrDoseLimitReached    = errors.New("dose limit reached")
+)
+
+// Dose forms of a medication.
+const (
+	FormTablet    = "tablet"
+	FormCapsule   = "capsule"
+	FormLiquid    = "liquid"
+	FormInjection = "injection"
+	FormInhaler   = "inhaler"
+	FormDrops     = "drops"
+	FormPatch     = "patch"
+	FormTopical   = "topical"
+	FormOther     = "other"
+)
+
+var validForms = map[string]bool{
+	FormTablet: true, FormCapsule: true, FormLiquid: true, FormInjection: true, FormInhaler: true,
+	FormDrops: true, FormPatch: true, FormTopical: true, FormOther: true,
+}
+
+// Statuses of a logged dose.
+const (
+	DoseTaken   = "taken"
+	DoseSkipped = "skipped"
+)
+
+// Limits on medications and the dose log.
+const (
+	allDays            = 127
+	maxNameLength      = 200
+	maxShortLength     = 100
+	maxUnitLength      = 20
+	maxNoteLength      = 2000
+	maxIngredients     = 20
+	maxScheduleTimes   = 12
+	maxDailyDosesLimit = 48
+	maxIntervalHours   = 72
+	maxDoseRangeDays   = 366
+	defaultDoseDays    = 7
+	// lowSupplyDays makes a refill reminder a warning.
+	lowSupplyDays = 3
+)
+
+type profileReader interface {
+	GetProfile(ctx context.Context, id uuid.UUID) (*storage.Profile, error)
+}
+
+type Service struct {
+	storage        storage.MedicationsStorage
+	supplements    storage.SupplementsStorage
+	profileStorage profileReader
+	now            func() time.Time
+}
+
+func NewService(medicationsStorage storage.MedicationsStorage, profileStorage profileReader) *Service {
+	return &Service{
+		storage:        medicationsStorage,
+		profileStorage: profileStorage,
+		now:            time.Now,
+	}
+}
+
+// WithSupplements includes the supplements of a profile in interaction
+// checks.
+func (s *Service) WithSupplements(supplements storage.SupplementsStorage) *Service {
+	s.supplements = supplements
+	return s
+}
+
+// ListMedications returns the medications of a profile; stopped ones only
+// with includeInactive.
+func (s *Service) ListMedications(ctx context.Context, profileID uuid.UUID, includeInactive bool) (*MedicationsResponse, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, err
+	}
+
+	medications, err := s.storage.ListMedications(ctx, profileID, !includeInactive)
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]MedicationDTO, 0, len(medications))
+	for _, medication := range medications {
+		dtos = append(dtos, toMedicationDTO(medication))
+	}
+	return &MedicationsResponse{Medications: dtos}, nil
+}
+
+// CreateMedication adds a medication and returns it with its interactions
+// with the other active medications and the supplements of the profile.
+func (s *Service) CreateMedication(ctx context.Context, req CreateMedicationRequest) (*MedicationDTO, error) {
+	if err := s.ensureProfileAccess(ctx, req.ProfileID); err != nil {
+		return nil, err
+	}
+
+	medication := storage.Medication{
+		ProfileID:        req.ProfileID,
+		Name:             req.Name,
+		Form:             req.Form,
+		Strength:         req.Strength,
+		Ingredients:      req.Ingredients,
+		DoseAmount:       req.DoseAmount,
+		DoseUnit:         req.DoseUnit,
+		Schedule:         toMedicationTimes(req.Schedule),
+		DaysMask:         allDays,
+		AsNeeded:         req.AsNeeded,
+		MaxDailyDoses:    req.MaxDailyDoses,
+		MinIntervalHours: req.MinIntervalHours,
+		StockQuantity:    req.StockQuantity,
+		RefillThreshold:  req.RefillThreshold,
+		Prescriber:       req.Prescriber,
+		Pharmacy:         req.Pharmacy,
+		Instructions:     req.Instructions,
+		Active:           true,
+	}
+	if req.DaysMask != nil {
+		medication.DaysMask = *req.DaysMask
+	}
+	if medication.Form == "" {
+		medication.Form = FormTablet
+	}
+	if err := normalizeMedication(&medication); err != nil {
+		return nil, err
+	}
+
+	created, err := s.storage.CreateMedication(ctx, medication)
+	if err != nil {
+		return nil, err
+	}
+	return s.withInteractions(ctx, created)
+}
+
+// GetMedication returns a medication of an accessible profile.
+func (s *Service) GetMedication(ctx context.Context, id uuid.UUID) (*MedicationDTO, error) {
+	medication, err := s.getOwnedMedication(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	dto := toMedicationDTO(medication)
+	return &dto, nil
+}
+
+// UpdateMedication changes the given fields of a medication. Interactions
+// are returned while the medication is active.
+func (s *Service) UpdateMedication(ctx context.Context, id uuid.UUID, req UpdateMedicationRequest) (*MedicationDTO, error) {
+	medication, err := s.getOwnedMedication(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.UntrackStock && req.StockQuantity != nil {
+		return nil, fmt.Errorf("%w: untrack_stock excludes stock_quantity", ErrInvalidRequest)
+	}
+	setIf(&medication.Name, req.Name)
+	setIf(&medication.Form, req.Form)
+	setIf(&medication.Strength, req.Strength)
+	setIf(&medication.Ingredients, req.Ingredients)
+	setIf(&medication.DoseAmount, req.DoseAmount)
+	setIf(&medication.DoseUnit, req.DoseUnit)
+	setIf(&medication.DaysMask, req.DaysMask)
+	setIf(&medication.AsNeeded, req.AsNeeded)
+	setIf(&medication.MaxDailyDoses, req.MaxDailyDoses)
+	setIf(&medication.MinIntervalHours, req.MinIntervalHours)
+	setIf(&medication.RefillThreshold, req.RefillThreshold)
+	setIf(&medication.Prescriber, req.Prescriber)
+	setIf(&medication.Pharmacy, req.Pharmacy)
+	setIf(&medication.Instructions, req.Instructions)
+	setIf(&medication.Active, req.Active)
+	if req.Schedule != nil {
+		medication.Schedule = toMedicationTimes(*req.Schedule)
+	}
+	switch {
+	case req.UntrackStock:
+		medication.StockQuantity = nil
+	case req.StockQuantity != nil:
+		stock := *req.StockQuantity
+		medication.StockQuantity = &stock
+	}
+	if err := normalizeMedication(&medication); err != nil {
+		return nil, err
+	}
+
+	// Stock is only written when the request sets it; otherwise a dose or
+	// refill landing meanwhile would be overwritten by this stale copy.
+	updated, ok, err := s.storage.UpdateMedication(ctx, medication, req.UntrackStock || req.StockQuantity != nil)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrMedicationNotFound
+	}
+	if !updated.Active {
+		dto := toMedicationDTO(updated)
+		return &dto, nil
+	}
+	return s.withInteractions(ctx, updated)
+}
+
+// DeleteMedication deletes a medication and its dose log. Stopping a
+// medication (active=false) keeps the history instead.
+func (s *Service) DeleteMedication(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.getOwnedMedication(ctx, id); err != nil {
+		return err
+	}
+	deleted, err := s.storage.DeleteMedication(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrMedicationNotFound
+	}
+	return nil
+}
+
+// Refill adds a filled prescription to the stock and starts tracking stock
+// if it was not tracked.
+func (s *Service) Refill(ctx context.Context, id uuid.UUID, req RefillRequest) (*MedicationDTO, error) {
+	medication, err := s.getOwnedMedication(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Quantity <= 0 || math.IsInf(req.Quantity, 0) || math.IsNaN(req.Quantity) {
+		return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidRequest)
+	}
+
+	var refilled storage.Medication
+	var ok bool
+	if medication.StockQuantity == nil {
+		medication.StockQuantity = &req.Quantity
+		refilled, ok, err = s.storage.UpdateMedication(ctx, medication, true)
+	} else {
+		refilled, ok, err = s.storage.AdjustMedicationStock(ctx, id, req.Quantity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrMedicationNotFound
+	}
+	dto := toMedicationDTO(refilled)
+	return &dto, nil
+}
+
+// LogDose logs a taken or skipped dose. A taken dose is deducted from the
+// stock; for as-needed medications it must keep within max_daily_doses
+// over the last 24 hours and min_interval_hours from other doses.
+func (s *Service) LogDose(ctx context.Context, medicationID uuid.UUID, req LogDoseRequest) (*DoseDTO, error) {
+	medication, err := s.getOwnedMedication(ctx, medicationID)
+	if err != nil {
+		return nil, err
+	}
+	if !medication.Active {
+		return nil, ErrMedicationNotActive
+	}
+
+	dose := storage.MedicationDose{
+		ProfileID:    medication.ProfileID,
+		MedicationID: medication.ID,
+		TakenAt:      s.now().UTC(),
+		Unit:         medication.DoseUnit,
+		Status:       strings.TrimSpace(req.Status),
+		AsNeeded:     medication.AsNeeded,
+	}
+	if dose.Status == "" {
+		dose.Status = DoseTaken
+	}
+	if dose.Status != DoseTaken && dose.Status != DoseSkipped {
+		return nil, fmt.Errorf("%w: status must be taken or skipped", ErrInvalidRequest)
+	}
+	// The schedule is matched in the UTC offset the client sent taken_at in.
+	localTime := dose.TakenAt
+	if req.TakenAt != nil {
+		localTime = *req.TakenAt
+		dose.TakenAt = req.TakenAt.UTC()
+	}
+	if dose.TakenAt.After(s.now().Add(time.Hour)) {
+		return nil, fmt.Errorf("%w: taken_at is in the future", ErrInvalidRequest)
+	}
+	dose.Amount = scheduledAmount(medication, localTime)
+	if req.Amount != nil {
+		dose.Amount = *req.Amount
+	}
+	if !validNumber(dose.Amount) || dose.Amount < 0 || (dose.Status == DoseTaken && dose.Amount == 0) {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidRequest)
+	}
+	if dose.Reason, err = normalizeText(req.Reason, "reason", maxNoteLength); err != nil {
+		return nil, err
+	}
+	if dose.Status == DoseTaken && medication.AsNeeded {
+		if err := s.checkDoseLimits(ctx, medication, dose.TakenAt); err != nil {
+			return nil, err
+		}
+	}
+
+	created, err := s.storage.CreateMedicationDose(ctx, dose)
+	if err != nil {
+		return nil, err
+	}
+	dto := toDoseDTO(created)
+	if medication.StockQuantity != nil {
+		if current, ok, err := s.storage.GetMedication(ctx, medication.ID); err == nil && ok {
+			dto.StockRemaining = current.StockQuantity
+		}
+	}
+	return &dto, nil
+}
+
+// ListDoses returns the doses logged between the from and to dates, both
+// inclusive; the last week by default. medicationID == uuid.Nil lists all
+// medications of the profile.
+func (s *Service) ListDoses(ctx context.Context, profileID, medicationID uuid.UUID, from, to string) (*DosesResponse, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, err
+	}
+	fromDate, toDate, err := s.parseRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	doses, err := s.storage.ListMedicationDoses(ctx, profileID, medicationID, fromDate, toDate.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]DoseDTO, 0, len(doses))
+	for _, dose := range doses {
+		dtos = append(dtos, toDoseDTO(dose))
+	}
+	return &DosesResponse{From: formatDate(fromDate), To: formatDate(toDate), Doses: dtos}, nil
+}
+
+// DeleteDose deletes a logged dose, returning a taken dose to the stock.
+func (s *Service) DeleteDose(ctx context.Context, id uuid.UUID) error {
+	dose, ok, err := s.storage.GetMedicationDose(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDoseNotFound
+	}
+	if err := s.ensureProfileAccess(ctx, dose.ProfileID); err != nil {
+		return ErrDoseNotFound
+	}
+
+	deleted, err := s.storage.DeleteMedicationDose(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrDoseNotFound
+	}
+	return nil
+}
+
+// Interactions checks every pair of the active medications and the
+// supplements of a profile.
+func (s *Service) Interactions(ctx context.Context, profileID uuid.UUID) (*InteractionsResponse, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, err
+	}
+	items, err := s.loadCheckItems(ctx, profileID)
+	if err != nil {
+		return nil, err
+	}
+	return &InteractionsResponse{
+		ProfileID:    profileID,
+		Interactions: allInteractions(items),
+		Disclaimer:   Disclaimer,
+	}, nil
+}
+
+// SupplementInteractions returns the interactions of a supplement with the
+// active medications and the other supplements of its profile.
+func (s *Service) SupplementInteractions(ctx context.Context, profileID, supplementID uuid.UUID) ([]InteractionDTO, error) {
+	items, err := s.loadCheckItems(ctx, profileID)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.ref.Kind == KindSupplement && item.ref.ID == supplementID {
+			return interactionsWith(item, items), nil
+		}
+	}
+	return []InteractionDTO{}, nil
+}
+
+// RefillReminder builds the refill reminder for a date, nil when no active
+// medication has its stock at or below the refill threshold. It is a
+// warning when some medication has run out or lasts under lowSupplyDays.
+func (s *Service) RefillReminder(ctx context.Context, profileID uuid.UUID, date time.Time) (*storage.Notification, error) {
+	medications, err := s.storage.ListMedications(ctx, profileID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	severity := "info"
+	for _, medication := range medications {
+		if !needsRefill(medication) {
+			continue
+		}
+		stock := *medication.StockQuantity
+		line := fmt.Sprintf("%s — осталось %s %s", medication.Name, formatAmount(stock), medication.DoseUnit)
+		days := daysOfSupply(medication)
+		if days != nil {
+			line += fmt.Sprintf(" (~%s дн.)", formatAmount(math.Floor(*days)))
+		}
+		if stock <= 0 || (days != nil && *days < lowSupplyDays) {
+			severity = "warn"
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	return &storage.Notification{
+		ProfileID:  profileID,
+		Kind:       "medication_refill",
+		Title:      "Пора пополнить лекарства",
+		Body:       strings.Join(lines, "; ") + ".",
+		SourceDate: &date,
+		Severity:   severity,
+	}, nil
+}
+
+// withInteractions returns the DTO of an active medication with its
+// interactions.
+func (s *Service) withInteractions(ctx context.Context, medication storage.Medication) (*MedicationDTO, error) {
+	items, err := s.loadCheckItems(ctx, medication.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+	dto := toMedicationDTO(medication)
+	dto.Interactions = interactionsWith(medicationCheckItem(medication), items)
+	return &dto, nil
+}
+
+// loadCheckItems loads the active medications and the supplements of a
+// profile for an interaction check.
+func (s *Service) loadCheckItems(ctx context.Context, profileID uuid.UUID) ([]checkItem, error) {
+	medications, err := s.storage.ListMedications(ctx, profileID, true)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]checkItem, 0, len(medications))
+	for _, medication := range medications {
+		items = append(items, medicationCheckItem(medication))
+	}
+	if s.supplements == nil {
+		return items, nil
+	}
+
+	supplements, err := s.supplements.ListSupplements(ctx, profileID)
+	if err != nil {
+		return nil, err
+	}
+	for _, supplement := range supplements {
+		components, err := s.supplements.GetSupplementComponents(ctx, supplement.ID)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]string, 0, len(components))
+		for _, component := range components {
+			keys = append(keys, component.NutrientKey)
+		}
+		items = append(items, newCheckItem(KindSupplement, supplement.ID, supplement.Name, keys))
+	}
+	return items, nil
+}
+
+// checkDoseLimits enforces the limits of an as-needed medication for a
+// dose taken at takenAt.
+func (s *Service) checkDoseLimits(ctx context.Context, medication storage.Medication, takenAt time.Time) error {
+	if medication.MaxDailyDoses == 0 && medication.MinIntervalHours == 0 {
+		return nil
+	}
+	window := 24 * time.Hour
+	interval := time.Duration(medication.MinIntervalHours) * time.Hour
+	doses, err := s.storage.ListMedicationDoses(ctx, medication.ProfileID, medication.ID,
+		takenAt.Add(-max(window, interval)), takenAt.Add(interval+time.Second))
+	if err != nil {
+		return err
+	}
+
+	taken := 0
+	for _, dose := range doses {
+		if dose.Status != DoseTaken {
+			continue
+		}
+		if interval > 0 && dose.TakenAt.After(takenAt.Add(-interval)) && dose.TakenAt.Before(takenAt.Add(interval)) {
+			return fmt.Errorf("%w: less than %d h since the dose at %s", ErrDoseLimitReached,
+				medication.MinIntervalHours, dose.TakenAt.Format(time.RFC3339))
+		}
+		if dose.TakenAt.After(takenAt.Add(-window)) && !dose.TakenAt.After(takenAt) {
+			taken++
+		}
+	}
+	if medication.MaxDailyDoses > 0 && taken >= medication.MaxDailyDoses {
+		return fmt.Errorf("%w: %d doses already taken in 24 h", ErrDoseLimitReached, taken)
+	}
+	return nil
+}
+
+func (s *Service) parseRange(from, to string) (time.Time, time.Time, error) {
+	toDate := today(s.now())
+	if strings.TrimSpace(to) != "" {
+		parsed, err := time.Parse("2006-01-02", strings.TrimSpace(to))
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: invalid to", ErrInvalidRequest)
+		}
+		toDate = parsed
+	}
+	fromDate := toDate.AddDate(0, 0, -(defaultDoseDays - 1))
+	if strings.TrimSpace(from) != "" {
+		parsed, err := time.Parse("2006-01-02", strings.TrimSpace(from))
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: invalid from", ErrInvalidRequest)
+		}
+		fromDate = parsed
+	}
+	if toDate.Before(fromDate) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to is before from", ErrInvalidRequest)
+	}
+	if toDate.Sub(fromDate) >= maxDoseRangeDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: range is longer than %d days", ErrInvalidRequest, maxDoseRangeDays)
+	}
+	return fromDate, toDate, nil
+}
+
+func (s *Service) getOwnedMedication(ctx context.Context, id uuid.UUID) (storage.Medication, error) {
+	medication, ok, err := s.storage.GetMedication(ctx, id)
+	if err != nil {
+		return storage.Medication{}, err
+	}
+	if !ok {
+		return storage.Medication{}, ErrMedicationNotFound
+	}
+	if err := s.ensureProfileAccess(ctx, medication.ProfileID); err != nil {
+		return storage.Medication{}, ErrMedicationNotFound
+	}
+	return medication, nil
+}
+
+func (s *Service) ensureProfileAccess(ctx context.Context, profileID uuid.UUID) error {
+	profile, err := s.profileStorage.GetProfile(ctx, profileID)
+	if err != nil {
+		return ErrProfileNotFound
+	}
+
+	if userID, ok := userctx.GetUserID(ctx); ok && strings.TrimSpace(userID) != "" && profile.OwnerUserID != userID {
+		return ErrProfileNotFound
+	}
+
+	return nil
+}
+
+// normalizeMedication trims and validates the fields of a medication being
+// saved.
+func normalizeMedication(medication *storage.Medication) error {
+	var err error
+	if medication.Name, err = normalizeText(medication.Name, "name", maxNameLength); err != nil {
+		return err
+	}
+	if medication.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRequest)
+	}
+	medication.Form = strings.TrimSpace(medication.Form)
+	if !validForms[medication.Form] {
+		return fmt.Errorf("%w: unknown form %q", ErrInvalidRequest, medication.Form)
+	}
+	if medication.Strength, err = normalizeText(medication.Strength, "strength", maxShortLength); err != nil {
+		return err
+	}
+	if medication.Ingredients, err = normalizeIngredients(medication.Ingredients); err != nil {
+		return err
+	}
+	if medication.DoseAmount <= 0 || !validNumber(medication.DoseAmount) {
+		return fmt.Errorf("%w: dose_amount must be positive", ErrInvalidRequest)
+	}
+	if medication.DoseUnit, err = normalizeText(medication.DoseUnit, "dose_unit", maxUnitLength); err != nil {
+		return err
+	}
+	if medication.DoseUnit == "" {
+		return fmt.Errorf("%w: dose_unit is required", ErrInvalidRequest)
+	}
+	if medication.Schedule, err = normalizeSchedule(medication.Schedule); err != nil {
+		return err
+	}
+	if medication.DaysMask < 1 || medication.DaysMask > allDays {
+		return fmt.Errorf("%w: days_mask must be in range 1..127", ErrInvalidRequest)
+	}
+	if medication.MaxDailyDoses < 0 || medication.MaxDailyDoses > maxDailyDosesLimit {
+		return fmt.Errorf("%w: max_daily_doses must be in range 0..%d", ErrInvalidRequest, maxDailyDosesLimit)
+	}
+	if medication.MinIntervalHours < 0 || medication.MinIntervalHours > maxIntervalHours {
+		return fmt.Errorf("%w: min_interval_hours must be in range 0..%d", ErrInvalidRequest, maxIntervalHours)
+	}
+	if medication.StockQuantity != nil && (*medication.StockQuantity < 0 || !validNumber(*medication.StockQuantity)) {
+		return fmt.Errorf("%w: stock_quantity must not be negative", ErrInvalidRequest)
+	}
+	if medication.RefillThreshold < 0 || !validNumber(medication.RefillThreshold) {
+		return fmt.Errorf("%w: refill_threshold must not be negative", ErrInvalidRequest)
+	}
+	if medication.Prescriber, err = normalizeText(medication.Prescriber, "prescriber", maxNameLength); err != nil {
+		return err
+	}
+	if medication.Pharmacy, err = normalizeText(medication.Pharmacy, "pharmacy", maxNameLength); err != nil {
+		return err
+	}
+	medication.Instructions, err = normalizeText(medication.Instructions, "instructions", maxNoteLength)
+	return err
+}
+
+// normalizeIngredients lowercases the ingredients and keeps each once.
+func normalizeIngredients(ingredients []string) ([]string, error) {
+	out := make([]string, 0, len(ingredients))
+	for _, ingredient := range ingredients {
+		ingredient = strings.ToLower(strings.TrimSpace(ingredient))
+		if ingredient == "" || slices.Contains(out, ingredient) {
+			continue
+		}
+		if len([]rune(ingredient)) > maxShortLength {
+			return nil, fmt.Errorf("%w: ingredient is too long", ErrInvalidRequest)
+		}
+		out = append(out, ingredient)
+	}
+	if len(out) > maxIngredients {
+		return nil, fmt.Errorf("%w: at most %d ingredients", ErrInvalidRequest, maxIngredients)
+	}
+	return out, nil
+}
+
+// normalizeSchedule validates the times and sorts them through the day.
+func normalizeSchedule(schedule []storage.MedicationTime) ([]storage.MedicationTime, error) {
+	if len(schedule) > maxScheduleTimes {
+		return nil, fmt.Errorf("%w: at most %d schedule times", ErrInvalidRequest, maxScheduleTimes)
+	}
+	out := slices.Clone(schedule)
+	for _, t := range out {
+		if t.TimeMinutes < 0 || t.TimeMinutes >= 24*60 {
+			return nil, fmt.Errorf("%w: time_minutes must be in range 0..1439", ErrInvalidRequest)
+		}
+		if t.Amount < 0 || !validNumber(t.Amount) {
+			return nil, fmt.Errorf("%w: schedule amount must not be negative", ErrInvalidRequest)
+		}
+	}
+	slices.SortFunc(out, func(a, b storage.MedicationTime) int { return a.TimeMinutes - b.TimeMinutes })
+	for i := 1; i < len(out); i++ {
+		if out[i].TimeMinutes == out[i-1].TimeMinutes {
+			return nil, fmt.Errorf("%w: duplicate schedule time", ErrInvalidRequest)
+		}
+	}
+	return out, nil
+}
+
+func normalizeText(value, field string, maxLength int) (string, error) {
+	value = strings.TrimSpace(value)
+	if len([]rune(value)) > maxLength {
+		return "", fmt.Errorf("%w: %s is too long", ErrInvalidRequest, field)
+	}
+	return value, nil
+}
+
+// scheduledAmount is the dose of the schedule time closest to the time of
+// day of takenAt, within an hour and a half, or the usual dose.
+func scheduledAmount(medication storage.Medication, takenAt time.Time) float64 {
+	minutes := takenAt.Hour()*60 + takenAt.Minute()
+	best := 91
+	amount := medication.DoseAmount
+	for _, t := range medication.Schedule {
+		diff := abs(t.TimeMinutes - minutes)
+		diff = min(diff, 24*60-diff)
+		if diff < best && t.Amount > 0 {
+			best, amount = diff, t.Amount
+		}
+	}
+	return amount
+}
+
+// dailyAmount is the average scheduled amount per day, 0 without a
+// schedule.
+func dailyAmount(medication storage.Medication) float64 {
+	var perDay float64
+	for _, t := range medication.Schedule {
+		if t.Amount > 0 {
+			perDay += t.Amount
+		} else {
+			perDay += medication.DoseAmount
+		}
+	}
+	days := 0
+	for bit := 0; bit < 7; bit++ {
+		if medication.DaysMask&(1<<bit) != 0 {
+			days++
+		}
+	}
+	return perDay * float64(days) / 7
+}
+
+// daysOfSupply is how many days the stock lasts on schedule, nil when
+// stock is not tracked or there is no schedule.
+func daysOfSupply(medication storage.Medication) *float64 {
+	daily := dailyAmount(medication)
+	if medication.StockQuantity == nil || daily <= 0 {
+		return nil
+	}
+	days := math.Round(*medication.StockQuantity/daily*10) / 10
+	return &days
+}
+
+func needsRefill(medication storage.Medication) bool {
+	return medication.StockQuantity != nil && *medication.StockQuantity <= medication.RefillThreshold
+}
+
+func medicationCheckItem(medication storage.Medication) checkItem {
+	return newCheckItem(KindMedication, medication.ID, medication.Name, medication.Ingredients)
+}
+
+func setIf[T any](dst *T, value *T) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+func validNumber(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func formatAmount(value float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", value), "0"), ".")
+}
+
+func today(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func formatDate(date time.Time) string {
+	return date.Format("2006-01-02")
+}
+
+func toMedicationTimes(times []ScheduleTimeDTO) []storage.MedicationTime {
+	out := make([]storage.MedicationTime, 0, len(times))
+	for _, t := range times {
+		out = append(out, storage.MedicationTime{TimeMinutes: t.TimeMinutes, Amount: t.Amount})
+	}
+	return out
+}
+
+func toMedicationDTO(medication storage.Medication) MedicationDTO {
+	ingredients := medication.Ingredients
+	if ingredients == nil {
+		ingredients = []string{}
+	}
+	schedule := make([]ScheduleTimeDTO, 0, len(medication.Schedule))
+	for _, t := range medication.Schedule {
+		schedule = append(schedule, ScheduleTimeDTO{TimeMinutes: t.TimeMinutes, Amount: t.Amount})
+	}
+	return MedicationDTO{
+		ID:               medication.ID,
+		ProfileID:        medication.ProfileID,
+		Name:             medication.Name,
+		Form:             medication.Form,
+		Strength:         medication.Strength,
+		Ingredients:      ingredients,
+		DoseAmount:       medication.DoseAmount,
+		DoseUnit:         medication.DoseUnit,
+		Schedule:         schedule,
+		DaysMask:         medication.DaysMask,
+		AsNeeded:         medication.AsNeeded,
+		MaxDailyDoses:    medication.MaxDailyDoses,
+		MinIntervalHours: medication.MinIntervalHours,
+		StockQuantity:    medication.StockQuantity,
+		RefillThreshold:  medication.RefillThreshold,
+		DaysOfSupply:     daysOfSupply(medication),
+		NeedsRefill:      needsRefill(medication),
+		Prescriber:       medication.Prescriber,
+		Pharmacy:         medication.Pharmacy,
+		Instructions:     medication.Instructions,
+		Active:           medication.Active,
+		CreatedAt:        medication.CreatedAt,
+		UpdatedAt:        medication.UpdatedAt,
+	}
+}
+
+func toDoseDTO(dose storage.MedicationDose) DoseDTO {
+	return DoseDTO{
+		ID:           dose.ID,
+		ProfileID:    dose.ProfileID,
+		MedicationID: dose.MedicationID,
+		TakenAt:      dose.TakenAt,
+		Amount:       dose.Amount,
+		Unit:         dose.Unit,
+		Status:       dose.Status,
+		AsNeeded:     dose.AsNeeded,
+		Reason:       dose.Reason,
+		CreatedAt:    dose.CreatedAt,
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 // Handler содержит HTTP обработчики для метрик
@@ -40,6 +42,7 @@ func (h *Handler) HandleSyncBatch(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, ErrInvalidTime):
 			h.sendError(w, http.StatusBadRequest, "invalid_time", "Invalid time range")
 		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			h.sendError(w, http.StatusInternalServerError, "internal_error", "Failed to sync batch")
 		}
 		return
@@ -75,6 +78,7 @@ func (h *Handler) HandleGetDailyMetrics(w http.ResponseWriter, r *http.Request)
 		case errors.Is(err, ErrInvalidRange):
 			h.sendError(w, http.StatusBadRequest, "invalid_range", "Invalid date range")
 		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			h.sendError(w, http.StatusInternalServerError, "internal_error", "Failed to get daily metrics")
 		}
 		return
@@ -113,6 +117,7 @@ func (h *Handler) HandleGetHourlyMetrics(w http.ResponseWriter, r *http.Request)
 		case errors.Is(err, ErrInvalidDate):
 			h.sendError(w, http.StatusBadRequest, "invalid_date", "Invalid date format")
 		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			h.sendError(w, http.StatusInternalServerError, "internal_error", "Failed to get hourly metrics")
 		}
 		return
@@ -132,8 +137,9 @@ func (h *Handler) sendJSON(w http.ResponseWriter, status int, data interface{})
 func (h *Handler) sendError(w http.ResponseWriter, status int, code, message string) {
 	h.sendJSON(w, status, ErrorResponse{
 		Error: ErrorDetail{
-			Code:    code,
-			Message: message,
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
 		},
 	})
 }
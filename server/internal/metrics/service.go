@@ -8,8 +8,10 @@ import (
 	"time"
 
 	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/telemetry"
 	"github.com/fdg312/health-hub/internal/userctx"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -24,6 +26,7 @@ var (
 type Service struct {
 	profileStorage storage.Storage
 	metricsStorage storage.MetricsStorage
+	telemetry      *telemetry.Metrics
 }
 
 // NewService создаёт новый сервис
@@ -34,12 +37,35 @@ func NewService(profileStorage storage.Storage, metricsStorage storage.MetricsSt
 	}
 }
 
+// WithTelemetry включает метрики размеров батчей синхронизации.
+func (s *Service) WithTelemetry(m *telemetry.Metrics) *Service {
+	s.telemetry = m
+	return s
+}
+
 // SyncBatch обрабатывает батчевую синхронизацию
 func (s *Service) SyncBatch(ctx context.Context, req SyncBatchRequest) (*SyncBatchResponse, error) {
+	ctx, span := telemetry.StartSpan(ctx, "metrics.SyncBatch",
+		attribute.Int("sync.daily", len(req.Daily)),
+		attribute.Int("sync.hourly", len(req.Hourly)),
+		attribute.Int("sync.sleep_segments", len(req.Sessions.SleepSegments)),
+		attribute.Int("sync.workouts", len(req.Sessions.Workouts)),
+	)
+	resp, err := s.syncBatch(ctx, req)
+	telemetry.EndSpan(span, err)
+	return resp, err
+}
+
+func (s *Service) syncBatch(ctx context.Context, req SyncBatchRequest) (*SyncBatchResponse, error) {
 	if err := s.ensureProfileAccess(ctx, req.ProfileID); err != nil {
 		return nil, ErrProfileNotFound
 	}
 
+	s.telemetry.ObserveSyncBatch("daily", len(req.Daily))
+	s.telemetry.ObserveSyncBatch("hourly", len(req.Hourly))
+	s.telemetry.ObserveSyncBatch("sleep_segments", len(req.Sessions.SleepSegments))
+	s.telemetry.ObserveSyncBatch("workouts", len(req.Sessions.Workouts))
+
 	resp := &SyncBatchResponse{
 		Status: "ok",
 	}
@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 type Handler struct {
@@ -53,6 +55,7 @@ func (h *Handler) HandleList(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -82,6 +85,7 @@ func (h *Handler) HandleUnreadCount(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -115,6 +119,7 @@ func (h *Handler) HandleMarkRead(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -143,6 +148,7 @@ func (h *Handler) HandleMarkAllRead(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -176,6 +182,7 @@ func (h *Handler) HandleGenerate(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		return
 	}
@@ -190,8 +197,9 @@ func writeError(w http.ResponseWriter, status int, code, message string) {
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(ErrorResponse{
 		Error: ErrorDetail{
-			Code:    code,
-			Message: message,
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
 		},
 	})
 }
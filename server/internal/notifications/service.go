@@ -36,17 +36,29 @@ type MealPlansStorage interface {
 	GetActive(ctx context.Context, ownerUserID string, profileID string) (storage.MealPlan, []storage.MealPlanItem, bool, error)
 }
 
+// CycleReminders builds menstrual cycle reminders
+type CycleReminders interface {
+	CycleReminder(ctx context.Context, profileID uuid.UUID, date time.Time) (*storage.Notification, error)
+}
+
+// MedicationReminders builds medication refill reminders
+type MedicationReminders interface {
+	RefillReminder(ctx context.Context, profileID uuid.UUID, date time.Time) (*storage.Notification, error)
+}
+
 type Service struct {
-	storage            storage.NotificationsStorage
-	metrics            storage.MetricsStorage
-	checkins           checkins.Storage
-	profiles           storage.Storage
-	settings           storage.SettingsStorage
-	config             *config.Config
-	workoutPlans       WorkoutPlansStorage
-	workoutItems       WorkoutPlanItemsStorage
-	workoutCompletions WorkoutCompletionsStorage
-	mealPlans          MealPlansStorage
+	storage             storage.NotificationsStorage
+	metrics             storage.MetricsStorage
+	checkins            checkins.Storage
+	profiles            storage.Storage
+	settings            storage.SettingsStorage
+	config              *config.Config
+	workoutPlans        WorkoutPlansStorage
+	workoutItems        WorkoutPlanItemsStorage
+	workoutCompletions  WorkoutCompletionsStorage
+	mealPlans           MealPlansStorage
+	cycleReminders      CycleReminders
+	medicationReminders MedicationReminders
 }
 
 func NewService(storage storage.NotificationsStorage, metrics storage.MetricsStorage, checkins checkins.Storage, profiles storage.Storage, settings storage.SettingsStorage, cfg *config.Config) *Service {
@@ -74,6 +86,18 @@ func (s *Service) WithMealPlansStorage(mealPlans MealPlansStorage) *Service {
 	return s
 }
 
+// WithCycleReminders adds menstrual cycle reminders
+func (s *Service) WithCycleReminders(reminders CycleReminders) *Service {
+	s.cycleReminders = reminders
+	return s
+}
+
+// WithMedicationReminders adds medication refill reminders
+func (s *Service) WithMedicationReminders(reminders MedicationReminders) *Service {
+	s.medicationReminders = reminders
+	return s
+}
+
 func (s *Service) ListNotifications(ctx context.Context, profileID uuid.UUID, onlyUnread bool, limit, offset int) ([]NotificationDTO, error) {
 	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
 		return nil, err
@@ -268,6 +292,28 @@ func (s *Service) Generate(ctx context.Context, req *GenerateRequest) (*Generate
 		candidates = append(candidates, *mealPlanReminder)
 	}
 
+	// 8. Cycle reminder (only for today; the cycle settings decide which one)
+	if s.cycleReminders != nil && isToday(date, req.Now, loc) {
+		cycleReminder, err := s.cycleReminders.CycleReminder(ctx, req.ProfileID, date)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build cycle reminder: %w", err)
+		}
+		if cycleReminder != nil {
+			candidates = append(candidates, *cycleReminder)
+		}
+	}
+
+	// 9. Medication refill reminder (only for today; stock is current)
+	if s.medicationReminders != nil && isToday(date, req.Now, loc) {
+		refillReminder, err := s.medicationReminders.RefillReminder(ctx, req.ProfileID, date)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build medication refill reminder: %w", err)
+		}
+		if refillReminder != nil {
+			candidates = append(candidates, *refillReminder)
+		}
+	}
+
 	// Quiet hours: suppress info reminders, keep warn notifications.
 	if effective.QuietEnabled && isInQuietHours(minutesOfDay(req.Now.In(loc)), effective.QuietStartMinutes, effective.QuietEndMinutes) {
 		candidates = filterBySeverity(candidates, "warn")
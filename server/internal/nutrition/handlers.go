@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/fdg312/health-hub/internal/logging"
 	"github.com/fdg312/health-hub/internal/userctx"
 	"github.com/google/uuid"
 )
@@ -48,6 +49,7 @@ func (h *Handler) HandleGetTargets(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to get nutrition targets")
 		return
 	}
@@ -91,6 +93,7 @@ func (h *Handler) HandleUpsertTargets(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusBadRequest, "invalid_request", errMsg[17:])
 			return
 		}
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to upsert nutrition targets")
 		return
 	}
@@ -104,10 +107,14 @@ func (h *Handler) HandleUpsertTargets(w http.ResponseWriter, r *http.Request) {
 func writeError(w http.ResponseWriter, status int, code, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
+	detail := map[string]string{
+		"code":    code,
+		"message": message,
+	}
+	if requestID := logging.ResponseRequestID(w); requestID != "" {
+		detail["request_id"] = requestID
+	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": map[string]string{
-			"code":    code,
-			"message": message,
-		},
+		"error": detail,
 	})
 }
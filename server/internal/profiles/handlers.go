@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 // Handler содержит HTTP обработчики для профилей
@@ -28,6 +30,7 @@ func (h *Handler) HandleList(w http.ResponseWriter, r *http.Request) {
 
 	profiles, err := h.service.ListProfiles(r.Context())
 	if err != nil {
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		h.sendError(w, http.StatusInternalServerError, "internal_error", "Failed to list profiles")
 		return
 	}
@@ -56,6 +59,7 @@ func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, ErrInvalidType):
 			h.sendError(w, http.StatusBadRequest, "invalid_type", "Only 'guest' type is allowed")
 		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			h.sendError(w, http.StatusInternalServerError, "internal_error", "Failed to create profile")
 		}
 		return
@@ -91,6 +95,7 @@ func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, ErrNotFound):
 			h.sendError(w, http.StatusNotFound, "not_found", "Profile not found")
 		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			h.sendError(w, http.StatusInternalServerError, "internal_error", "Failed to update profile")
 		}
 		return
@@ -120,6 +125,7 @@ func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, ErrCannotDeleteOwner):
 			h.sendError(w, http.StatusConflict, "cannot_delete_owner", "Cannot delete owner profile")
 		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			h.sendError(w, http.StatusInternalServerError, "internal_error", "Failed to delete profile")
 		}
 		return
@@ -149,8 +155,9 @@ func (h *Handler) sendJSON(w http.ResponseWriter, status int, data interface{})
 func (h *Handler) sendError(w http.ResponseWriter, status int, code, message string) {
 	h.sendJSON(w, status, ErrorResponse{
 		Error: ErrorDetail{
-			Code:    code,
-			Message: message,
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
 		},
 	})
 }
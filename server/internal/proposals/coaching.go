@@ -0,0 +1,260 @@
+package proposals
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fdg312/health-hub/internal/coaching"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+// coachingProgramPayload is what the assistant drafts: a goal given as an
+// absolute target or as a change from the current value, and a duration.
+// The baseline is read from the profile's metrics on preview and apply.
+type coachingProgramPayload struct {
+	Title         string   `json:"title"`
+	Metric        string   `json:"metric"`
+	TargetValue   *float64 `json:"target_value"`
+	TargetChange  *float64 `json:"target_change"`
+	DurationWeeks int      `json:"duration_weeks"`
+}
+
+// coachingProgramSnapshot records the program an apply created, so undo
+// deletes only that program.
+type coachingProgramSnapshot struct {
+	CreatedProgramID *uuid.UUID `json:"created_program_id,omitempty"`
+}
+
+// coachingScheduleSnapshot is the schedule restored when undoing a
+// coaching_adjustment.
+type coachingScheduleSnapshot struct {
+	ProgramID  uuid.UUID                 `json:"program_id"`
+	TargetDate string                    `json:"target_date"`
+	Milestones []coaching.MilestoneInput `json:"milestones"`
+}
+
+func parseCoachingProgramPayload(payload []byte) (coachingProgramPayload, error) {
+	var parsed coachingProgramPayload
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&parsed); err != nil {
+		return coachingProgramPayload{}, err
+	}
+
+	parsed.Title = strings.TrimSpace(parsed.Title)
+	if parsed.Title == "" || len(parsed.Title) > 200 {
+		return coachingProgramPayload{}, ErrInvalidPayload
+	}
+	if !coaching.ValidMetric(parsed.Metric) {
+		return coachingProgramPayload{}, ErrInvalidPayload
+	}
+	if (parsed.TargetValue == nil) == (parsed.TargetChange == nil) {
+		return coachingProgramPayload{}, ErrInvalidPayload
+	}
+	if parsed.DurationWeeks < 1 || parsed.DurationWeeks > 52 {
+		return coachingProgramPayload{}, ErrInvalidPayload
+	}
+	return parsed, nil
+}
+
+func (s *Service) planCoachingProgram(ctx context.Context, userID string, proposal storage.AIProposal) (*applyPlan, error) {
+	if s.coachingService == nil {
+		return nil, ErrUnsupportedKind
+	}
+
+	payload, err := parseCoachingProgramPayload(proposal.Payload)
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+
+	baseline, found, err := s.coachingService.Baseline(ctx, userID, proposal.ProfileID, payload.Metric)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNoBaseline
+	}
+	target := baseline
+	if payload.TargetValue != nil {
+		target = *payload.TargetValue
+	} else {
+		target += *payload.TargetChange
+	}
+
+	now := s.now().UTC()
+	start := now.Format("2006-01-02")
+	end := now.AddDate(0, 0, 7*payload.DurationWeeks).Format("2006-01-02")
+	req := coaching.CreateProgramRequest{
+		ProfileID:        proposal.ProfileID,
+		Title:            payload.Title,
+		Metric:           payload.Metric,
+		BaselineValue:    &baseline,
+		TargetValue:      target,
+		StartDate:        start,
+		TargetDate:       end,
+		SourceProposalID: &proposal.ID,
+	}
+
+	snapshot := &coachingProgramSnapshot{}
+	return &applyPlan{
+		changes: []ChangeDTO{{
+			Key:   "program",
+			Op:    opAdd,
+			Label: payload.Title,
+			After: map[string]any{
+				"metric":         payload.Metric,
+				"baseline_value": baseline,
+				"target_value":   target,
+				"start_date":     start,
+				"target_date":    end,
+			},
+		}},
+		snapshot: snapshot,
+		apply: func(ctx context.Context, selected map[string]bool) (*AppliedResultDTO, error) {
+			program, err := s.coachingService.Create(ctx, userID, req)
+			if errors.Is(err, coaching.ErrInvalidRequest) {
+				return nil, ErrInvalidPayload
+			}
+			if err != nil {
+				return nil, err
+			}
+			snapshot.CreatedProgramID = &program.ID
+			return &AppliedResultDTO{CoachingProgramID: &program.ID}, nil
+		},
+	}, nil
+}
+
+func parseCoachingAdjustmentPayload(payload []byte) (coaching.AdjustmentPayload, error) {
+	var parsed coaching.AdjustmentPayload
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&parsed); err != nil {
+		return coaching.AdjustmentPayload{}, err
+	}
+	if parsed.ProgramID == uuid.Nil || parsed.TargetDate == "" || len(parsed.Milestones) == 0 {
+		return coaching.AdjustmentPayload{}, ErrInvalidPayload
+	}
+	return parsed, nil
+}
+
+func coachingMilestoneKey(index int) string {
+	return "milestone:" + strconv.Itoa(index)
+}
+
+func (s *Service) planCoachingAdjustment(ctx context.Context, userID string, proposal storage.AIProposal) (*applyPlan, error) {
+	if s.coachingService == nil {
+		return nil, ErrUnsupportedKind
+	}
+
+	payload, err := parseCoachingAdjustmentPayload(proposal.Payload)
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+
+	program, found, err := s.coachingService.GetProgram(ctx, userID, payload.ProgramID)
+	if err != nil {
+		return nil, err
+	}
+	if !found || program.Status != coaching.StatusActive {
+		return nil, ErrProgramUnavailable
+	}
+
+	snapshot := coachingScheduleSnapshot{
+		ProgramID:  program.ID,
+		TargetDate: program.TargetDate,
+		Milestones: make([]coaching.MilestoneInput, 0, len(program.Milestones)),
+	}
+	current := make([]keyedItem[coaching.MilestoneInput], 0, len(program.Milestones))
+	for i, milestone := range program.Milestones {
+		value := coaching.MilestoneInput{Date: milestone.Date, Value: milestone.Value}
+		snapshot.Milestones = append(snapshot.Milestones, value)
+		current = append(current, keyedItem[coaching.MilestoneInput]{
+			key:   coachingMilestoneKey(i),
+			label: fmt.Sprintf("%g", milestone.Value),
+			value: value,
+		})
+	}
+	proposed := make([]keyedItem[coaching.MilestoneInput], 0, len(payload.Milestones))
+	for i, milestone := range payload.Milestones {
+		proposed = append(proposed, keyedItem[coaching.MilestoneInput]{
+			key:   coachingMilestoneKey(i),
+			label: fmt.Sprintf("%g", milestone.Value),
+			value: milestone,
+		})
+	}
+
+	changes := make([]ChangeDTO, 0)
+	if program.TargetDate != payload.TargetDate {
+		changes = append(changes, ChangeDTO{Key: "target_date", Op: opUpdate, Label: program.Title, Before: program.TargetDate, After: payload.TargetDate})
+	}
+	changes = append(changes, diffItems(current, proposed)...)
+
+	return &applyPlan{
+		changes:  changes,
+		snapshot: snapshot,
+		apply: func(ctx context.Context, selected map[string]bool) (*AppliedResultDTO, error) {
+			targetDate := payload.TargetDate
+			if selected != nil && !selected["target_date"] {
+				targetDate = program.TargetDate
+			}
+			merged := mergeItems(current, proposed, selected)
+			milestones := make([]coaching.MilestoneInput, 0, len(merged))
+			for _, item := range merged {
+				milestones = append(milestones, item.value)
+			}
+
+			updated, err := s.coachingService.Reschedule(ctx, userID, program.ID, targetDate, milestones)
+			switch {
+			case errors.Is(err, coaching.ErrInvalidRequest) && selected != nil:
+				// Moving only some milestones can leave them out of order.
+				return nil, ErrInvalidSelection
+			case errors.Is(err, coaching.ErrInvalidRequest):
+				return nil, ErrInvalidPayload
+			case errors.Is(err, coaching.ErrProgramNotFound), errors.Is(err, coaching.ErrNotActive):
+				return nil, ErrProgramUnavailable
+			case err != nil:
+				return nil, err
+			}
+			return &AppliedResultDTO{CoachingProgramID: &updated.ID}, nil
+		},
+	}, nil
+}
+
+// restoreCoaching undoes a coaching_program or coaching_adjustment apply.
+func (s *Service) restoreCoaching(ctx context.Context, userID string, proposal storage.AIProposal) error {
+	if s.coachingService == nil {
+		return ErrUnsupportedKind
+	}
+
+	if proposal.Kind == "coaching_program" {
+		var snapshot coachingProgramSnapshot
+		if err := json.Unmarshal(proposal.Snapshot, &snapshot); err != nil {
+			return ErrUndoUnavailable
+		}
+		if snapshot.CreatedProgramID == nil {
+			return nil
+		}
+		// The program may already have been deleted by the user.
+		err := s.coachingService.Delete(ctx, userID, *snapshot.CreatedProgramID)
+		if errors.Is(err, coaching.ErrProgramNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	var snapshot coachingScheduleSnapshot
+	if err := json.Unmarshal(proposal.Snapshot, &snapshot); err != nil {
+		return ErrUndoUnavailable
+	}
+	_, err := s.coachingService.Reschedule(ctx, userID, snapshot.ProgramID, snapshot.TargetDate, snapshot.Milestones)
+	if errors.Is(err, coaching.ErrProgramNotFound) || errors.Is(err, coaching.ErrNotActive) {
+		return ErrProgramUnavailable
+	}
+	return err
+}
@@ -3,11 +3,14 @@ package proposals
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 type Handler struct {
@@ -43,7 +46,7 @@ func (h *Handler) HandleList(w http.ResponseWriter, r *http.Request) {
 	status := strings.TrimSpace(r.URL.Query().Get("status"))
 	resp, err := h.service.List(r.Context(), profileID, status, limit)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 
@@ -58,9 +61,64 @@ func (h *Handler) HandleApply(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := h.service.Apply(r.Context(), proposalID)
+	// The body is optional: without it the whole proposal is applied.
+	var req ApplyProposalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.Apply(r.Context(), proposalID, req.Keys)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	idRaw := strings.TrimSpace(r.PathValue("id"))
+	proposalID, err := uuid.Parse(idRaw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid proposal id")
+		return
+	}
+
+	resp, err := h.service.Get(r.Context(), proposalID)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) HandlePreview(w http.ResponseWriter, r *http.Request) {
+	idRaw := strings.TrimSpace(r.PathValue("id"))
+	proposalID, err := uuid.Parse(idRaw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid proposal id")
+		return
+	}
+
+	resp, err := h.service.Preview(r.Context(), proposalID)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) HandleUndo(w http.ResponseWriter, r *http.Request) {
+	idRaw := strings.TrimSpace(r.PathValue("id"))
+	proposalID, err := uuid.Parse(idRaw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid proposal id")
+		return
+	}
+
+	resp, err := h.service.Undo(r.Context(), proposalID)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -76,13 +134,13 @@ func (h *Handler) HandleReject(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.service.Reject(r.Context(), proposalID)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
 
-func (h *Handler) handleError(w http.ResponseWriter, err error) {
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error) {
 	switch {
 	case errors.Is(err, ErrInvalidRequest):
 		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
@@ -96,7 +154,24 @@ func (h *Handler) handleError(w http.ResponseWriter, err error) {
 		writeError(w, http.StatusNotFound, "proposal_not_found", "Proposal not found")
 	case errors.Is(err, ErrNotPending):
 		writeError(w, http.StatusConflict, "not_pending", "Proposal is not pending")
+	case errors.Is(err, ErrExpired):
+		writeError(w, http.StatusConflict, "proposal_expired", "Proposal has expired")
+	case errors.Is(err, ErrMemoryFull):
+		writeError(w, http.StatusConflict, "memory_full", "Assistant memory is full, delete a fact first")
+	case errors.Is(err, ErrInvalidSelection):
+		writeError(w, http.StatusBadRequest, "invalid_selection", "Selected keys must be changes from the proposal preview")
+	case errors.Is(err, ErrNotApplied):
+		writeError(w, http.StatusConflict, "not_applied", "Proposal is not applied")
+	case errors.Is(err, ErrUndoExpired):
+		writeError(w, http.StatusConflict, "undo_expired", "Undo window has passed")
+	case errors.Is(err, ErrNoBaseline):
+		writeError(w, http.StatusConflict, "baseline_unknown", "No recent data for the program's metric")
+	case errors.Is(err, ErrProgramUnavailable):
+		writeError(w, http.StatusConflict, "program_unavailable", "Coaching program was deleted or is no longer active")
+	case errors.Is(err, ErrUndoUnavailable):
+		writeError(w, http.StatusConflict, "undo_unavailable", "Proposal was applied without a snapshot and cannot be undone")
 	default:
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
 	}
 }
@@ -110,8 +185,9 @@ func writeJSON(w http.ResponseWriter, status int, data any) {
 func writeError(w http.ResponseWriter, status int, code, message string) {
 	writeJSON(w, status, ErrorResponse{
 		Error: ErrorDetail{
-			Code:    code,
-			Message: message,
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
 		},
 	})
 }
@@ -1,12 +1,18 @@
 package proposals
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/fdg312/health-hub/internal/coaching"
 	"github.com/fdg312/health-hub/internal/config"
 	"github.com/fdg312/health-hub/internal/settings"
 	"github.com/fdg312/health-hub/internal/storage"
@@ -20,7 +26,7 @@ func TestListPendingReturnsProposals(t *testing.T) {
 
 	pending := createProposal(t, mem, "userA", profileA, "settings_update", []byte(`{"min_steps":8000}`))
 	applied := createProposal(t, mem, "userA", profileA, "settings_update", []byte(`{"min_steps":9000}`))
-	if err := mem.UpdateStatus(context.Background(), "userA", applied.ID, "applied"); err != nil {
+	if ok, err := mem.UpdateStatus(context.Background(), "userA", applied.ID, "pending", "applied"); err != nil || !ok {
 		t.Fatalf("update status failed: %v", err)
 	}
 
@@ -160,11 +166,61 @@ func TestApplyVitaminsScheduleCreatesSchedules(t *testing.T) {
 	}
 }
 
+func TestApplyMemoryStoresFactOnce(t *testing.T) {
+	handler, mem, profileA, _, _ := setupProposalsHandler(t)
+
+	apply := func(payload string) *httptest.ResponseRecorder {
+		proposal := createProposal(t, mem, "userA", profileA, "memory", []byte(payload))
+		req := httptest.NewRequest(http.MethodPost, "/v1/ai/proposals/"+proposal.ID.String()+"/apply", nil)
+		req.SetPathValue("id", proposal.ID.String())
+		req = req.WithContext(userctx.WithUserID(context.Background(), "userA"))
+		w := httptest.NewRecorder()
+		handler.HandleApply(w, req)
+		return w
+	}
+
+	w := apply(`{"fact":"Аллергия на орехи"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var resp ApplyProposalResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if resp.Applied == nil || resp.Applied.MemoryFactID == nil {
+		t.Fatalf("expected memory_fact_id in response")
+	}
+
+	if w := apply(`{"fact":"  аллергия на орехи "}`); w.Code != http.StatusOK {
+		t.Fatalf("expected duplicate to apply, got %d", w.Code)
+	}
+	facts, err := mem.ListFacts(context.Background(), "userA", profileA)
+	if err != nil {
+		t.Fatalf("list facts failed: %v", err)
+	}
+	if len(facts) != 1 || facts[0].ID != *resp.Applied.MemoryFactID {
+		t.Fatalf("expected exactly one stored fact, got %+v", facts)
+	}
+
+	if w := apply(`{"fact":"   "}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty fact, got %d", w.Code)
+	}
+
+	for i := len(facts); i < maxMemoryFacts; i++ {
+		if _, err := mem.InsertFact(context.Background(), "userA", profileA, "факт "+strconv.Itoa(i)); err != nil {
+			t.Fatalf("insert fact failed: %v", err)
+		}
+	}
+	if w := apply(`{"fact":"Работает по ночам"}`); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 when memory is full, got %d", w.Code)
+	}
+}
+
 func TestApplyNonPendingReturns409(t *testing.T) {
 	handler, mem, profileA, _, _ := setupProposalsHandler(t)
 
 	proposal := createProposal(t, mem, "userA", profileA, "settings_update", []byte(`{"min_steps":8000}`))
-	if err := mem.UpdateStatus(context.Background(), "userA", proposal.ID, "applied"); err != nil {
+	if ok, err := mem.UpdateStatus(context.Background(), "userA", proposal.ID, "pending", "applied"); err != nil || !ok {
 		t.Fatalf("update status failed: %v", err)
 	}
 
@@ -203,6 +259,89 @@ func TestRejectPendingSetsRejectedStatus(t *testing.T) {
 	}
 }
 
+// blockingSettings holds Upsert until release is closed, so a test can act
+// while a proposal is being applied.
+type blockingSettings struct {
+	*settings.Service
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (b *blockingSettings) Upsert(ctx context.Context, ownerUserID string, dto settings.SettingsDTO) (settings.SettingsDTO, error) {
+	b.once.Do(func() { close(b.started) })
+	<-b.release
+	return b.Service.Upsert(ctx, ownerUserID, dto)
+}
+
+func TestApplyClaimsProposalBeforeChangingState(t *testing.T) {
+	_, mem, profileA, _, settingsService := setupProposalsHandler(t)
+	blocking := &blockingSettings{Service: settingsService, started: make(chan struct{}), release: make(chan struct{})}
+	handler := NewHandler(NewService(mem.GetProposalsStorage(), mem, blocking))
+	proposal := createProposal(t, mem, "userA", profileA, "settings_update", []byte(`{"min_steps":8000}`))
+
+	serve := func(handle http.HandlerFunc, action string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/ai/proposals/"+proposal.ID.String()+"/"+action, nil)
+		req.SetPathValue("id", proposal.ID.String())
+		req = req.WithContext(userctx.WithUserID(context.Background(), "userA"))
+		w := httptest.NewRecorder()
+		handle(w, req)
+		return w
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serve(handler.HandleApply, "apply") }()
+	<-blocking.started
+
+	// While the first Apply is writing settings, nobody else gets in.
+	if w := serve(handler.HandleReject, "reject"); w.Code != http.StatusConflict {
+		t.Fatalf("expected reject to get 409 during apply, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w := serve(handler.HandleApply, "apply"); w.Code != http.StatusConflict {
+		t.Fatalf("expected a second apply to get 409, got %d body=%s", w.Code, w.Body.String())
+	}
+	if expired, err := mem.ExpirePending(context.Background(), time.Now().Add(365*24*time.Hour)); err != nil || expired != 0 {
+		t.Fatalf("expected expiry to skip the proposal being applied, got %d %v", expired, err)
+	}
+
+	close(blocking.release)
+	if w := <-done; w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	stored, _, _ := mem.Get(context.Background(), "userA", proposal.ID)
+	if stored.Status != "applied" || len(stored.Snapshot) == 0 {
+		t.Fatalf("expected applied with a snapshot, got %q", stored.Status)
+	}
+
+	// Undo succeeds once; the second one finds it already undone.
+	if w := serve(handler.HandleUndo, "undo"); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if ok, err := mem.UpdateStatus(context.Background(), "userA", proposal.ID, "applied", "undone"); err != nil || ok {
+		t.Fatalf("expected the status change from applied to fail after undo, got %v %v", ok, err)
+	}
+}
+
+func TestFailedApplyReleasesClaim(t *testing.T) {
+	handler, mem, profileA, _, _ := setupProposalsHandler(t)
+	proposal := createProposal(t, mem, "userA", profileA, "settings_update", []byte(`{"min_steps":8000}`))
+
+	body := bytes.NewReader([]byte(`{"keys":["unknown"]}`))
+	req := httptest.NewRequest(http.MethodPost, "/v1/ai/proposals/"+proposal.ID.String()+"/apply", body)
+	req.SetPathValue("id", proposal.ID.String())
+	req = req.WithContext(userctx.WithUserID(context.Background(), "userA"))
+	w := httptest.NewRecorder()
+	handler.HandleApply(w, req)
+	if w.Code == http.StatusOK {
+		t.Fatalf("expected unknown keys to fail, got 200")
+	}
+
+	stored, _, _ := mem.Get(context.Background(), "userA", proposal.ID)
+	if stored.Status != "pending" {
+		t.Fatalf("expected the proposal back to pending, got %q", stored.Status)
+	}
+}
+
 func TestOwnershipCrossUserReturns404(t *testing.T) {
 	handler, mem, profileA, _, _ := setupProposalsHandler(t)
 
@@ -219,6 +358,389 @@ func TestOwnershipCrossUserReturns404(t *testing.T) {
 	}
 }
 
+func TestPreviewVitaminsScheduleShowsDiff(t *testing.T) {
+	handler, mem, profileA, _, _ := setupProposalsHandler(t)
+	seedSchedules(t, mem, profileA)
+
+	proposal := createProposal(t, mem, "userA", profileA, "vitamins_schedule", []byte(`{
+		"replace": true,
+		"items": [
+			{"supplement_name":"Витамин D","time_minutes":480,"days_mask":31},
+			{"supplement_name":"Магний","time_minutes":1260,"days_mask":127}
+		]
+	}`))
+
+	w := serveProposal(handler.HandlePreview, http.MethodGet, "/preview", proposal.ID, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var resp PreviewProposalResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode preview failed: %v", err)
+	}
+
+	ops := make(map[string]string)
+	for _, change := range resp.Changes {
+		ops[change.Key] = change.Op
+	}
+	want := map[string]string{
+		"schedule:витамин d@480": "update",
+		"schedule:магний@1260":   "add",
+		"schedule:омега-3@540":   "remove",
+	}
+	if len(ops) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), resp.Changes)
+	}
+	for key, op := range want {
+		if ops[key] != op {
+			t.Fatalf("expected %s to be %q, got %q", key, op, ops[key])
+		}
+	}
+
+	stored, _, _ := mem.Get(context.Background(), "userA", proposal.ID)
+	if stored.Status != "pending" {
+		t.Fatalf("preview must not change status, got %q", stored.Status)
+	}
+}
+
+func TestApplySelectedKeysKeepsUnselectedItems(t *testing.T) {
+	handler, mem, profileA, _, _ := setupProposalsHandler(t)
+	seedSchedules(t, mem, profileA)
+
+	proposal := createProposal(t, mem, "userA", profileA, "vitamins_schedule", []byte(`{
+		"replace": true,
+		"items": [
+			{"supplement_name":"Витамин D","time_minutes":480,"days_mask":31},
+			{"supplement_name":"Магний","time_minutes":1260,"days_mask":127}
+		]
+	}`))
+
+	w := serveProposal(handler.HandleApply, http.MethodPost, "/apply", proposal.ID, `{"keys":["schedule:unknown@1"]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for unknown key, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	w = serveProposal(handler.HandleApply, http.MethodPost, "/apply", proposal.ID, `{"keys":["schedule:магний@1260"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var resp ApplyProposalResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if resp.UndoUntil == nil {
+		t.Fatalf("expected undo_until in response")
+	}
+
+	schedules, err := mem.ListSchedules(context.Background(), "userA", profileA)
+	if err != nil {
+		t.Fatalf("list schedules failed: %v", err)
+	}
+	if len(schedules) != 3 {
+		t.Fatalf("expected 3 schedules, got %d", len(schedules))
+	}
+	for _, schedule := range schedules {
+		if schedule.TimeMinutes == 480 && schedule.DaysMask != 127 {
+			t.Fatalf("unselected update must keep days_mask=127, got %d", schedule.DaysMask)
+		}
+	}
+}
+
+func TestUndoRestoresSnapshot(t *testing.T) {
+	handler, mem, profileA, _, _ := setupProposalsHandler(t)
+	seedSchedules(t, mem, profileA)
+
+	proposal := createProposal(t, mem, "userA", profileA, "vitamins_schedule", []byte(`{
+		"replace": true,
+		"items": [{"supplement_name":"Магний","time_minutes":1260,"days_mask":127}]
+	}`))
+
+	if w := serveProposal(handler.HandleUndo, http.MethodPost, "/undo", proposal.ID, ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for pending proposal, got %d", w.Code)
+	}
+	if w := serveProposal(handler.HandleApply, http.MethodPost, "/apply", proposal.ID, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	w := serveProposal(handler.HandleUndo, http.MethodPost, "/undo", proposal.ID, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	schedules, err := mem.ListSchedules(context.Background(), "userA", profileA)
+	if err != nil {
+		t.Fatalf("list schedules failed: %v", err)
+	}
+	if len(schedules) != 2 {
+		t.Fatalf("expected the 2 original schedules back, got %d", len(schedules))
+	}
+	for _, schedule := range schedules {
+		if schedule.TimeMinutes == 1260 {
+			t.Fatalf("applied schedule must be removed by undo")
+		}
+	}
+
+	stored, _, _ := mem.Get(context.Background(), "userA", proposal.ID)
+	if stored.Status != "undone" {
+		t.Fatalf("expected status undone, got %q", stored.Status)
+	}
+	if w := serveProposal(handler.HandleUndo, http.MethodPost, "/undo", proposal.ID, ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for second undo, got %d", w.Code)
+	}
+}
+
+func TestUndoAfterWindowReturns409(t *testing.T) {
+	handler, mem, profileA, _, settingsService := setupProposalsHandler(t)
+
+	proposal := createProposal(t, mem, "userA", profileA, "settings_update", []byte(`{"min_steps":8000}`))
+	if w := serveProposal(handler.HandleApply, http.MethodPost, "/apply", proposal.ID, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	handler.service.now = func() time.Time { return time.Now().Add(defaultUndoWindow + time.Hour) }
+	w := serveProposal(handler.HandleUndo, http.MethodPost, "/undo", proposal.ID, "")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d body=%s", w.Code, w.Body.String())
+	}
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode error failed: %v", err)
+	}
+	if resp.Error.Code != "undo_expired" {
+		t.Fatalf("expected undo_expired, got %q", resp.Error.Code)
+	}
+
+	current, err := settingsService.GetOrDefault(context.Background(), "userA")
+	if err != nil {
+		t.Fatalf("get settings failed: %v", err)
+	}
+	if current.Settings.MinSteps != 8000 {
+		t.Fatalf("expired undo must not change settings, got min_steps=%d", current.Settings.MinSteps)
+	}
+}
+
+func TestExpiredProposalCannotBeApplied(t *testing.T) {
+	handler, mem, profileA, _, _ := setupProposalsHandler(t)
+
+	past := time.Now().Add(-time.Hour)
+	rows, err := mem.InsertMany(context.Background(), "userA", profileA, []storage.ProposalDraft{
+		{Kind: "settings_update", Title: "Шаги", Payload: []byte(`{"min_steps":8000}`), ExpiresAt: &past},
+		{Kind: "settings_update", Title: "Сон", Payload: []byte(`{"min_sleep_minutes":450}`), ExpiresAt: &past},
+	})
+	if err != nil {
+		t.Fatalf("insert proposals failed: %v", err)
+	}
+
+	w := serveProposal(handler.HandleApply, http.MethodPost, "/apply", rows[0].ID, "")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d body=%s", w.Code, w.Body.String())
+	}
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode error failed: %v", err)
+	}
+	if resp.Error.Code != "proposal_expired" {
+		t.Fatalf("expected proposal_expired, got %q", resp.Error.Code)
+	}
+
+	expired, err := handler.service.ExpirePending(context.Background())
+	if err != nil {
+		t.Fatalf("expire pending failed: %v", err)
+	}
+	if expired != 1 {
+		t.Fatalf("expected sweep to expire the remaining proposal, got %d", expired)
+	}
+
+	req := httptest.NewRequest(
+		http.MethodGet,
+		"/v1/ai/proposals?profile_id="+profileA.String()+"&status=expired,superseded",
+		nil,
+	)
+	req = req.WithContext(userctx.WithUserID(context.Background(), "userA"))
+	w = httptest.NewRecorder()
+	handler.HandleList(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var list ListProposalsResponse
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("decode list failed: %v", err)
+	}
+	if len(list.Proposals) != 2 {
+		t.Fatalf("expected 2 expired proposals, got %d", len(list.Proposals))
+	}
+}
+
+func TestGetProposalReturnsHistory(t *testing.T) {
+	handler, mem, profileA, _, _ := setupProposalsHandler(t)
+
+	proposal := createProposal(t, mem, "userA", profileA, "settings_update", []byte(`{"min_steps":8000}`))
+
+	for i := 0; i < 2; i++ {
+		if w := serveProposal(handler.HandleGet, http.MethodGet, "", proposal.ID, ""); w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+		}
+	}
+	if w := serveProposal(handler.HandleReject, http.MethodPost, "/reject", proposal.ID, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	w := serveProposal(handler.HandleGet, http.MethodGet, "", proposal.ID, "")
+	var resp ProposalDetailResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode detail failed: %v", err)
+	}
+	if resp.Proposal.Status != "rejected" {
+		t.Fatalf("expected status rejected, got %q", resp.Proposal.Status)
+	}
+	events := make([]string, 0, len(resp.History))
+	for _, event := range resp.History {
+		events = append(events, event.Event)
+	}
+	if strings.Join(events, ",") != "created,viewed,rejected" {
+		t.Fatalf("unexpected history %v", events)
+	}
+}
+
+func TestApplyCoachingProgramUsesBaselineAndUndoDeletesIt(t *testing.T) {
+	handler, mem, profileA, _, _ := setupProposalsHandler(t)
+	coachingService := coaching.NewService(mem.GetCoachingStorage(), mem, mem, mem.GetProposalsStorage())
+	handler.service.WithCoachingService(coachingService)
+
+	proposal := createProposal(t, mem, "userA", profileA, "coaching_program", []byte(`{
+		"title":"Минус 5 кг","metric":"weight_kg","target_value":null,"target_change":-5,"duration_weeks":10
+	}`))
+	if w := serveProposal(handler.HandleApply, http.MethodPost, "/apply", proposal.ID, ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 without metrics, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	date := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	if err := mem.UpsertDailyMetric(context.Background(), profileA, date, []byte(`{"date":"`+date+`","body":{"weight_kg_last":82,"bmi":0}}`)); err != nil {
+		t.Fatalf("seed daily metric failed: %v", err)
+	}
+
+	w := serveProposal(handler.HandleApply, http.MethodPost, "/apply", proposal.ID, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var resp ApplyProposalResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if resp.Applied.CoachingProgramID == nil {
+		t.Fatalf("expected coaching_program_id in result")
+	}
+
+	program, found, err := coachingService.GetProgram(context.Background(), "userA", *resp.Applied.CoachingProgramID)
+	if err != nil || !found {
+		t.Fatalf("expected created program, found=%v err=%v", found, err)
+	}
+	if program.BaselineValue != 82 || program.TargetValue != 77 || len(program.Milestones) != 10 {
+		t.Fatalf("unexpected program %+v", program)
+	}
+	if program.SourceProposalID == nil || *program.SourceProposalID != proposal.ID {
+		t.Fatalf("expected program to reference its proposal")
+	}
+
+	if w := serveProposal(handler.HandleUndo, http.MethodPost, "/undo", proposal.ID, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 on undo, got %d body=%s", w.Code, w.Body.String())
+	}
+	if _, found, _ := coachingService.GetProgram(context.Background(), "userA", program.ID); found {
+		t.Fatalf("undo must delete the created program")
+	}
+}
+
+func TestApplyCoachingAdjustmentSelectedTargetDateOnly(t *testing.T) {
+	handler, mem, profileA, _, _ := setupProposalsHandler(t)
+	coachingService := coaching.NewService(mem.GetCoachingStorage(), mem, mem, mem.GetProposalsStorage())
+	handler.service.WithCoachingService(coachingService)
+
+	today := time.Now().UTC().Format("2006-01-02")
+	baseline := 80.0
+	program, err := coachingService.Create(context.Background(), "userA", coaching.CreateProgramRequest{
+		ProfileID:     profileA,
+		Title:         "Минус 2 кг",
+		Metric:        coaching.MetricWeightKg,
+		BaselineValue: &baseline,
+		TargetValue:   78,
+		StartDate:     today,
+		TargetDate:    time.Now().UTC().AddDate(0, 0, 14).Format("2006-01-02"),
+	})
+	if err != nil {
+		t.Fatalf("create program failed: %v", err)
+	}
+	newTarget := time.Now().UTC().AddDate(0, 0, 21).Format("2006-01-02")
+	proposal := createProposal(t, mem, "userA", profileA, "coaching_adjustment", []byte(`{
+		"program_id":"`+program.ID.String()+`","target_date":"`+newTarget+`",
+		"milestones":[{"date":"`+program.Milestones[0].Date+`","value":79},{"date":"`+newTarget+`","value":78}]
+	}`))
+
+	w := serveProposal(handler.HandleApply, http.MethodPost, "/apply", proposal.ID, `{"keys":["target_date","milestone:1"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	updated, _, err := coachingService.GetProgram(context.Background(), "userA", program.ID)
+	if err != nil {
+		t.Fatalf("get program failed: %v", err)
+	}
+	if updated.TargetDate != newTarget || updated.Milestones[1].Date != newTarget {
+		t.Fatalf("expected target moved to %s, got %+v", newTarget, updated)
+	}
+	if updated.Milestones[0].Date != program.Milestones[0].Date {
+		t.Fatalf("unselected milestone must stay, got %s", updated.Milestones[0].Date)
+	}
+
+	if w := serveProposal(handler.HandleUndo, http.MethodPost, "/undo", proposal.ID, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 on undo, got %d body=%s", w.Code, w.Body.String())
+	}
+	restored, _, _ := coachingService.GetProgram(context.Background(), "userA", program.ID)
+	if restored.TargetDate != program.TargetDate {
+		t.Fatalf("expected target date %s restored, got %s", program.TargetDate, restored.TargetDate)
+	}
+}
+
+func serveProposal(
+	handle http.HandlerFunc,
+	method string,
+	action string,
+	proposalID uuid.UUID,
+	body string,
+) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/v1/ai/proposals/"+proposalID.String()+action, strings.NewReader(body))
+	req.SetPathValue("id", proposalID.String())
+	req = req.WithContext(userctx.WithUserID(context.Background(), "userA"))
+	w := httptest.NewRecorder()
+	handle(w, req)
+	return w
+}
+
+// seedSchedules gives profileID two daily schedules: Витамин D at 08:00 and
+// Омега-3 at 09:00.
+func seedSchedules(t *testing.T, mem *memory.MemoryStorage, profileID uuid.UUID) {
+	t.Helper()
+
+	for _, seed := range []struct {
+		name        string
+		timeMinutes int
+	}{
+		{"Витамин D", 480},
+		{"Омега-3", 540},
+	} {
+		supplement := &storage.Supplement{ProfileID: profileID, Name: seed.name}
+		if err := mem.CreateSupplement(context.Background(), supplement); err != nil {
+			t.Fatalf("create supplement failed: %v", err)
+		}
+		if _, err := mem.UpsertSchedule(context.Background(), "userA", profileID, storage.ScheduleUpsert{
+			SupplementID: supplement.ID,
+			TimeMinutes:  seed.timeMinutes,
+			DaysMask:     127,
+			IsEnabled:    true,
+		}); err != nil {
+			t.Fatalf("upsert schedule failed: %v", err)
+		}
+	}
+}
+
 func setupProposalsHandler(t *testing.T) (*Handler, *memory.MemoryStorage, uuid.UUID, uuid.UUID, *settings.Service) {
 	t.Helper()
 
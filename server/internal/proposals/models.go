@@ -18,15 +18,35 @@ type ProposalDTO struct {
 	Summary   string         `json:"summary"`
 	Payload   map[string]any `json:"payload"`
 	CreatedAt time.Time      `json:"created_at"`
+	AppliedAt *time.Time     `json:"applied_at,omitempty"`
+	ExpiresAt *time.Time     `json:"expires_at,omitempty"`
+}
+
+// ProposalEventDTO is one entry of a proposal's status history.
+type ProposalEventDTO struct {
+	Event string    `json:"event"`
+	At    time.Time `json:"at"`
+}
+
+type ProposalDetailResponse struct {
+	Proposal ProposalDTO        `json:"proposal"`
+	History  []ProposalEventDTO `json:"history"`
 }
 
 type ListProposalsResponse struct {
 	Proposals []ProposalDTO `json:"proposals"`
 }
 
+// ApplyProposalRequest selects which preview changes to apply. An absent
+// body or nil keys applies the whole proposal.
+type ApplyProposalRequest struct {
+	Keys []string `json:"keys"`
+}
+
 type ApplyProposalResponse struct {
-	Status  string            `json:"status"`
-	Applied *AppliedResultDTO `json:"applied,omitempty"`
+	Status    string            `json:"status"`
+	Applied   *AppliedResultDTO `json:"applied,omitempty"`
+	UndoUntil *time.Time        `json:"undo_until,omitempty"`
 }
 
 type AppliedResultDTO struct {
@@ -35,19 +55,42 @@ type AppliedResultDTO struct {
 	WorkoutItemsCreated  *int                  `json:"workout_items_created,omitempty"`
 	NutritionTargets     *bool                 `json:"nutrition_targets_updated,omitempty"`
 	MealPlanItemsCreated *int                  `json:"meal_plan_items_created,omitempty"`
+	MemoryFactID         *uuid.UUID            `json:"memory_fact_id,omitempty"`
+	CoachingProgramID    *uuid.UUID            `json:"coaching_program_id,omitempty"`
 }
 
 type RejectProposalResponse struct {
 	Status string `json:"status"`
 }
 
+// ChangeDTO is one difference between a proposal and the current state.
+// Key is stable for the proposal and is what partial apply selects.
+type ChangeDTO struct {
+	Key    string `json:"key"`
+	Op     string `json:"op"`
+	Label  string `json:"label,omitempty"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+type PreviewProposalResponse struct {
+	ProposalID uuid.UUID   `json:"proposal_id"`
+	Kind       string      `json:"kind"`
+	Changes    []ChangeDTO `json:"changes"`
+}
+
+type UndoProposalResponse struct {
+	Status string `json:"status"`
+}
+
 type ErrorResponse struct {
 	Error ErrorDetail `json:"error"`
 }
 
 type ErrorDetail struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 func proposalToDTO(p storage.AIProposal) ProposalDTO {
@@ -64,6 +107,8 @@ func proposalToDTO(p storage.AIProposal) ProposalDTO {
 		Summary:   p.Summary,
 		Payload:   payload,
 		CreatedAt: p.CreatedAt,
+		AppliedAt: p.AppliedAt,
+		ExpiresAt: p.ExpiresAt,
 	}
 }
 
@@ -136,3 +181,16 @@ func parseMealPlanPayload(data []byte) (*MealPlanPayload, error) {
 	}
 	return &payload, nil
 }
+
+// MemoryPayload represents the payload for memory proposals
+type MemoryPayload struct {
+	Fact string `json:"fact"`
+}
+
+func parseMemoryPayload(data []byte) (*MemoryPayload, error) {
+	var payload MemoryPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
@@ -0,0 +1,863 @@
+package proposals
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/fdg312/health-hub/internal/mealplans"
+	"github.com/fdg312/health-hub/internal/settings"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/workouts"
+	"github.com/google/uuid"
+)
+
+// Change operations reported by preview.
+const (
+	opAdd    = "add"
+	opUpdate = "update"
+	opRemove = "remove"
+)
+
+// applyPlan is a proposal resolved against the profile's current state:
+// the changes it would make, a snapshot to restore on undo, and a function
+// that applies the selected changes (nil selected means all of them).
+type applyPlan struct {
+	changes  []ChangeDTO
+	snapshot any
+	apply    func(ctx context.Context, selected map[string]bool) (*AppliedResultDTO, error)
+}
+
+// keyedItem is one entry of a list-shaped state (schedules, plan items),
+// identified by a key that is stable between the current state and the
+// proposal.
+type keyedItem[T any] struct {
+	key   string
+	label string
+	value T
+}
+
+// diffItems lists what replacing current with proposed would add, change
+// and remove.
+func diffItems[T any](current, proposed []keyedItem[T]) []ChangeDTO {
+	currentByKey := make(map[string]keyedItem[T], len(current))
+	for _, item := range current {
+		currentByKey[item.key] = item
+	}
+	proposedKeys := make(map[string]bool, len(proposed))
+
+	changes := make([]ChangeDTO, 0)
+	for _, item := range proposed {
+		proposedKeys[item.key] = true
+		existing, found := currentByKey[item.key]
+		switch {
+		case !found:
+			changes = append(changes, ChangeDTO{Key: item.key, Op: opAdd, Label: item.label, After: item.value})
+		case !reflect.DeepEqual(existing.value, item.value):
+			changes = append(changes, ChangeDTO{Key: item.key, Op: opUpdate, Label: item.label, Before: existing.value, After: item.value})
+		}
+	}
+	for _, item := range current {
+		if !proposedKeys[item.key] {
+			changes = append(changes, ChangeDTO{Key: item.key, Op: opRemove, Label: item.label, Before: item.value})
+		}
+	}
+	return changes
+}
+
+// mergeItems builds the resulting list when only the selected changes are
+// applied: unselected removals are kept, unselected updates keep the
+// current value and unselected additions are dropped.
+func mergeItems[T any](current, proposed []keyedItem[T], selected map[string]bool) []keyedItem[T] {
+	if selected == nil {
+		return proposed
+	}
+
+	proposedByKey := make(map[string]keyedItem[T], len(proposed))
+	for _, item := range proposed {
+		proposedByKey[item.key] = item
+	}
+	currentKeys := make(map[string]bool, len(current))
+
+	merged := make([]keyedItem[T], 0, len(current)+len(proposed))
+	for _, item := range current {
+		currentKeys[item.key] = true
+		next, found := proposedByKey[item.key]
+		switch {
+		case !found && !selected[item.key]:
+			merged = append(merged, item)
+		case found && selected[item.key]:
+			merged = append(merged, next)
+		case found:
+			merged = append(merged, item)
+		}
+	}
+	for _, item := range proposed {
+		if !currentKeys[item.key] && selected[item.key] {
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
+
+// uniqueItems drops repeated keys, keeping the first occurrence.
+func uniqueItems[T any](items []keyedItem[T]) []keyedItem[T] {
+	seen := make(map[string]bool, len(items))
+	out := make([]keyedItem[T], 0, len(items))
+	for _, item := range items {
+		if seen[item.key] {
+			continue
+		}
+		seen[item.key] = true
+		out = append(out, item)
+	}
+	return out
+}
+
+// selectChanges validates a partial-apply selection. nil keys select every
+// change; otherwise each key must name a change from the preview.
+func selectChanges(changes []ChangeDTO, keys []string) (map[string]bool, error) {
+	if keys == nil {
+		return nil, nil
+	}
+	if len(keys) == 0 {
+		return nil, ErrInvalidSelection
+	}
+
+	known := make(map[string]bool, len(changes))
+	for _, change := range changes {
+		known[change.Key] = true
+	}
+	selected := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if !known[key] {
+			return nil, ErrInvalidSelection
+		}
+		selected[key] = true
+	}
+	return selected, nil
+}
+
+func (s *Service) buildPlan(ctx context.Context, userID string, proposal storage.AIProposal) (*applyPlan, error) {
+	switch proposal.Kind {
+	case "settings_update":
+		return s.planSettings(ctx, userID, proposal)
+	case "vitamins_schedule":
+		return s.planVitamins(ctx, userID, proposal)
+	case "workout_plan":
+		return s.planWorkout(ctx, proposal)
+	case "nutrition_plan":
+		return s.planNutrition(ctx, userID, proposal)
+	case "meal_plan":
+		return s.planMealPlan(ctx, userID, proposal)
+	case "memory":
+		return s.planMemory(ctx, userID, proposal)
+	case "coaching_program":
+		return s.planCoachingProgram(ctx, userID, proposal)
+	case "coaching_adjustment":
+		return s.planCoachingAdjustment(ctx, userID, proposal)
+	default:
+		return nil, ErrUnsupportedKind
+	}
+}
+
+// settingsFields lists settings_update payload fields in display order.
+var settingsFields = []string{
+	"time_zone",
+	"quiet_start_minutes",
+	"quiet_end_minutes",
+	"notifications_max_per_day",
+	"min_sleep_minutes",
+	"min_steps",
+	"min_active_energy_kcal",
+	"morning_checkin_time_minutes",
+	"evening_checkin_time_minutes",
+	"vitamins_time_minutes",
+}
+
+func (s *Service) planSettings(ctx context.Context, userID string, proposal storage.AIProposal) (*applyPlan, error) {
+	patch, err := parseSettingsPatch(proposal.Payload)
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(proposal.Payload, &fields); err != nil {
+		return nil, ErrInvalidPayload
+	}
+
+	current, err := s.settingsService.GetOrDefault(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	before := settingsValues(current.Settings)
+	after := settingsValues(mergeSettings(current.Settings, patch))
+
+	changes := make([]ChangeDTO, 0)
+	for _, field := range settingsFields {
+		if _, ok := fields[field]; !ok || reflect.DeepEqual(before[field], after[field]) {
+			continue
+		}
+		changes = append(changes, ChangeDTO{Key: field, Op: opUpdate, Before: before[field], After: after[field]})
+	}
+
+	return &applyPlan{
+		changes:  changes,
+		snapshot: current.Settings,
+		apply: func(ctx context.Context, selected map[string]bool) (*AppliedResultDTO, error) {
+			selectedPatch := patch
+			if selected != nil {
+				partial := make(map[string]json.RawMessage, len(selected))
+				for field := range selected {
+					partial[field] = fields[field]
+				}
+				raw, err := json.Marshal(partial)
+				if err != nil {
+					return nil, err
+				}
+				if selectedPatch, err = parseSettingsPatch(raw); err != nil {
+					return nil, ErrInvalidPayload
+				}
+			}
+
+			merged := mergeSettings(current.Settings, selectedPatch)
+			if err := merged.Validate(); err != nil {
+				return nil, ErrInvalidPayload
+			}
+			updated, err := s.settingsService.Upsert(ctx, userID, merged)
+			if err != nil {
+				return nil, ErrInvalidPayload
+			}
+			return &AppliedResultDTO{Settings: &updated}, nil
+		},
+	}, nil
+}
+
+// settingsValues flattens settings to their JSON field values so fields can
+// be compared one by one.
+func settingsValues(dto settings.SettingsDTO) map[string]any {
+	values := make(map[string]any)
+	raw, err := json.Marshal(dto)
+	if err != nil {
+		return values
+	}
+	_ = json.Unmarshal(raw, &values)
+	return values
+}
+
+// vitaminsSnapshot is the schedule set restored on undo.
+type vitaminsSnapshot struct {
+	Schedules []scheduleSnapshot `json:"schedules"`
+}
+
+type scheduleSnapshot struct {
+	SupplementID uuid.UUID `json:"supplement_id"`
+	TimeMinutes  int       `json:"time_minutes"`
+	DaysMask     int       `json:"days_mask"`
+	IsEnabled    bool      `json:"is_enabled"`
+}
+
+type vitaminsItemValue struct {
+	SupplementName string `json:"supplement_name"`
+	TimeMinutes    int    `json:"time_minutes"`
+	DaysMask       int    `json:"days_mask"`
+	IsEnabled      bool   `json:"is_enabled"`
+}
+
+func vitaminsItemKey(name string, timeMinutes int) string {
+	return fmt.Sprintf("schedule:%s@%d", normalizeSupplementName(name), timeMinutes)
+}
+
+func (s *Service) planVitamins(ctx context.Context, userID string, proposal storage.AIProposal) (*applyPlan, error) {
+	payload, err := parseVitaminsSchedulePayload(proposal.Payload)
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+
+	supplementsStorage, ok := s.profileStorage.(storage.SupplementsStorage)
+	if !ok || supplementsStorage == nil {
+		return nil, ErrUnsupportedKind
+	}
+	schedulesStorage, ok := s.profileStorage.(storage.SupplementSchedulesStorage)
+	if !ok || schedulesStorage == nil {
+		return nil, ErrUnsupportedKind
+	}
+
+	existingSupplements, err := supplementsStorage.ListSupplements(ctx, proposal.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]storage.Supplement, len(existingSupplements))
+	byName := make(map[string]storage.Supplement, len(existingSupplements))
+	for _, sup := range existingSupplements {
+		byID[sup.ID] = sup
+		if key := normalizeSupplementName(sup.Name); key != "" {
+			byName[key] = sup
+		}
+	}
+
+	schedules, err := schedulesStorage.ListSchedules(ctx, userID, proposal.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := vitaminsSnapshot{Schedules: make([]scheduleSnapshot, 0, len(schedules))}
+	current := make([]keyedItem[vitaminsItemValue], 0, len(schedules))
+	for _, schedule := range schedules {
+		snapshot.Schedules = append(snapshot.Schedules, scheduleSnapshot{
+			SupplementID: schedule.SupplementID,
+			TimeMinutes:  schedule.TimeMinutes,
+			DaysMask:     schedule.DaysMask,
+			IsEnabled:    schedule.IsEnabled,
+		})
+		name := byID[schedule.SupplementID].Name
+		current = append(current, keyedItem[vitaminsItemValue]{
+			key:   vitaminsItemKey(name, schedule.TimeMinutes),
+			label: name,
+			value: vitaminsItemValue{
+				SupplementName: name,
+				TimeMinutes:    schedule.TimeMinutes,
+				DaysMask:       schedule.DaysMask,
+				IsEnabled:      schedule.IsEnabled,
+			},
+		})
+	}
+
+	proposed := make([]keyedItem[vitaminsItemValue], 0, len(payload.Items))
+	for _, item := range payload.Items {
+		proposed = append(proposed, keyedItem[vitaminsItemValue]{
+			key:   vitaminsItemKey(item.SupplementName, item.TimeMinutes),
+			label: item.SupplementName,
+			value: vitaminsItemValue{
+				SupplementName: item.SupplementName,
+				TimeMinutes:    item.TimeMinutes,
+				DaysMask:       item.DaysMask,
+				IsEnabled:      *item.IsEnabled,
+			},
+		})
+	}
+	proposed = uniqueItems(proposed)
+
+	return &applyPlan{
+		changes:  diffItems(current, proposed),
+		snapshot: snapshot,
+		apply: func(ctx context.Context, selected map[string]bool) (*AppliedResultDTO, error) {
+			merged := mergeItems(current, proposed, selected)
+
+			upserts := make([]storage.ScheduleUpsert, 0, len(merged))
+			for _, item := range merged {
+				normalizedName := normalizeSupplementName(item.value.SupplementName)
+				supplement, found := byName[normalizedName]
+				if !found {
+					newSupplement := &storage.Supplement{
+						ProfileID: proposal.ProfileID,
+						Name:      item.value.SupplementName,
+					}
+					if err := supplementsStorage.CreateSupplement(ctx, newSupplement); err != nil {
+						return nil, err
+					}
+					supplement = *newSupplement
+					byName[normalizedName] = supplement
+				}
+				upserts = append(upserts, storage.ScheduleUpsert{
+					SupplementID: supplement.ID,
+					TimeMinutes:  item.value.TimeMinutes,
+					DaysMask:     item.value.DaysMask,
+					IsEnabled:    item.value.IsEnabled,
+				})
+			}
+
+			saved, err := schedulesStorage.ReplaceAll(ctx, userID, proposal.ProfileID, upserts)
+			if err != nil {
+				return nil, err
+			}
+			count := len(saved)
+			return &AppliedResultDTO{SchedulesCreated: &count}, nil
+		},
+	}, nil
+}
+
+type workoutItemValue struct {
+	Kind        string          `json:"kind"`
+	TimeMinutes int             `json:"time_minutes"`
+	DaysMask    int             `json:"days_mask"`
+	DurationMin int             `json:"duration_min"`
+	Intensity   string          `json:"intensity"`
+	Note        string          `json:"note"`
+	Details     json.RawMessage `json:"details"`
+}
+
+func workoutItemKey(kind string, timeMinutes, daysMask int) string {
+	return fmt.Sprintf("item:%s@%d/%d", kind, timeMinutes, daysMask)
+}
+
+func (s *Service) planWorkout(ctx context.Context, proposal storage.AIProposal) (*applyPlan, error) {
+	if s.workoutService == nil {
+		return nil, ErrUnsupportedKind
+	}
+
+	payload, err := parseWorkoutPlanPayload(proposal.Payload)
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+	if !payload.Replace {
+		return nil, ErrInvalidPayload
+	}
+	if len(payload.Items) == 0 || len(payload.Items) > 30 {
+		return nil, ErrInvalidPayload
+	}
+
+	active, err := s.workoutService.GetActivePlan(ctx, proposal.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make([]keyedItem[workoutItemValue], 0, len(active.Items))
+	for _, item := range active.Items {
+		current = append(current, keyedItem[workoutItemValue]{
+			key:   workoutItemKey(item.Kind, item.TimeMinutes, item.DaysMask),
+			label: item.Kind,
+			value: workoutItemValue{
+				Kind:        item.Kind,
+				TimeMinutes: item.TimeMinutes,
+				DaysMask:    item.DaysMask,
+				DurationMin: item.DurationMin,
+				Intensity:   item.Intensity,
+				Note:        item.Note,
+				Details:     item.Details,
+			},
+		})
+	}
+	proposed := make([]keyedItem[workoutItemValue], 0, len(payload.Items))
+	for _, item := range payload.Items {
+		// Structured outputs carry no details; store an empty object.
+		if len(item.Details) == 0 {
+			item.Details = json.RawMessage(`{}`)
+		}
+		proposed = append(proposed, keyedItem[workoutItemValue]{
+			key:   workoutItemKey(item.Kind, item.TimeMinutes, item.DaysMask),
+			label: item.Kind,
+			value: workoutItemValue{
+				Kind:        item.Kind,
+				TimeMinutes: item.TimeMinutes,
+				DaysMask:    item.DaysMask,
+				DurationMin: item.DurationMin,
+				Intensity:   item.Intensity,
+				Note:        item.Note,
+				Details:     item.Details,
+			},
+		})
+	}
+	proposed = uniqueItems(proposed)
+
+	changes := make([]ChangeDTO, 0)
+	if active.Plan != nil {
+		if active.Plan.Title != payload.Title {
+			changes = append(changes, ChangeDTO{Key: "plan:title", Op: opUpdate, Before: active.Plan.Title, After: payload.Title})
+		}
+		if active.Plan.Goal != payload.Goal {
+			changes = append(changes, ChangeDTO{Key: "plan:goal", Op: opUpdate, Before: active.Plan.Goal, After: payload.Goal})
+		}
+	}
+	changes = append(changes, diffItems(current, proposed)...)
+
+	return &applyPlan{
+		changes:  changes,
+		snapshot: active,
+		apply: func(ctx context.Context, selected map[string]bool) (*AppliedResultDTO, error) {
+			title, goal := payload.Title, payload.Goal
+			if selected != nil && active.Plan != nil {
+				if !selected["plan:title"] {
+					title = active.Plan.Title
+				}
+				if !selected["plan:goal"] {
+					goal = active.Plan.Goal
+				}
+			}
+
+			merged := mergeItems(current, proposed, selected)
+			items := make([]workouts.ItemUpsertRequest, 0, len(merged))
+			for _, item := range merged {
+				items = append(items, workouts.ItemUpsertRequest{
+					Kind:        item.value.Kind,
+					TimeMinutes: item.value.TimeMinutes,
+					DaysMask:    item.value.DaysMask,
+					DurationMin: item.value.DurationMin,
+					Intensity:   item.value.Intensity,
+					Note:        item.value.Note,
+					Details:     item.value.Details,
+				})
+			}
+
+			_, err := s.workoutService.ReplacePlanAndItems(ctx, &workouts.ReplaceItemsRequest{
+				ProfileID: proposal.ProfileID,
+				Title:     title,
+				Goal:      goal,
+				Replace:   true,
+				Items:     items,
+			})
+			if errors.Is(err, workouts.ErrInvalidRequest) {
+				return nil, ErrInvalidPayload
+			}
+			if err != nil {
+				return nil, err
+			}
+			count := len(items)
+			return &AppliedResultDTO{WorkoutItemsCreated: &count}, nil
+		},
+	}, nil
+}
+
+// nutritionSnapshot is the targets restored on undo.
+type nutritionSnapshot struct {
+	CaloriesKcal int `json:"calories_kcal"`
+	ProteinG     int `json:"protein_g"`
+	FatG         int `json:"fat_g"`
+	CarbsG       int `json:"carbs_g"`
+	CalciumMg    int `json:"calcium_mg"`
+}
+
+func (s *Service) planNutrition(ctx context.Context, userID string, proposal storage.AIProposal) (*applyPlan, error) {
+	if s.nutritionService == nil {
+		return nil, ErrUnsupportedKind
+	}
+
+	payload, err := parseNutritionPlanPayload(proposal.Payload)
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+
+	// Validate payload - all fields are required
+	if payload.CaloriesKcal < 800 || payload.CaloriesKcal > 6000 {
+		return nil, ErrInvalidPayload
+	}
+	if payload.ProteinG < 0 || payload.ProteinG > 400 {
+		return nil, ErrInvalidPayload
+	}
+	if payload.FatG < 0 || payload.FatG > 400 {
+		return nil, ErrInvalidPayload
+	}
+	if payload.CarbsG < 0 || payload.CarbsG > 400 {
+		return nil, ErrInvalidPayload
+	}
+	if payload.CalciumMg < 0 || payload.CalciumMg > 5000 {
+		return nil, ErrInvalidPayload
+	}
+
+	targets, _, err := s.nutritionService.GetOrDefault(ctx, userID, proposal.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+	current := nutritionSnapshot{
+		CaloriesKcal: targets.CaloriesKcal,
+		ProteinG:     targets.ProteinG,
+		FatG:         targets.FatG,
+		CarbsG:       targets.CarbsG,
+		CalciumMg:    targets.CalciumMg,
+	}
+
+	changes := make([]ChangeDTO, 0)
+	for _, field := range []struct {
+		key           string
+		before, after int
+	}{
+		{"calories_kcal", current.CaloriesKcal, payload.CaloriesKcal},
+		{"protein_g", current.ProteinG, payload.ProteinG},
+		{"fat_g", current.FatG, payload.FatG},
+		{"carbs_g", current.CarbsG, payload.CarbsG},
+		{"calcium_mg", current.CalciumMg, payload.CalciumMg},
+	} {
+		if field.before != field.after {
+			changes = append(changes, ChangeDTO{Key: field.key, Op: opUpdate, Before: field.before, After: field.after})
+		}
+	}
+
+	return &applyPlan{
+		changes:  changes,
+		snapshot: current,
+		apply: func(ctx context.Context, selected map[string]bool) (*AppliedResultDTO, error) {
+			// Unselected fields keep their current value.
+			pick := func(key string, before, after int) int {
+				if selected == nil || selected[key] {
+					return after
+				}
+				return before
+			}
+			err := s.nutritionService.UpsertSimple(ctx, userID, proposal.ProfileID,
+				pick("calories_kcal", current.CaloriesKcal, payload.CaloriesKcal),
+				pick("protein_g", current.ProteinG, payload.ProteinG),
+				pick("fat_g", current.FatG, payload.FatG),
+				pick("carbs_g", current.CarbsG, payload.CarbsG),
+				pick("calcium_mg", current.CalciumMg, payload.CalciumMg))
+			if err != nil {
+				return nil, err
+			}
+			updated := true
+			return &AppliedResultDTO{NutritionTargets: &updated}, nil
+		},
+	}, nil
+}
+
+// mealPlanSnapshot is the active meal plan restored on undo; a nil plan
+// means there was none.
+type mealPlanSnapshot struct {
+	Plan  *mealplans.MealPlanDTO              `json:"plan"`
+	Items []mealplans.MealPlanItemUpsertInput `json:"items"`
+}
+
+func mealPlanItemKey(dayIndex int, mealSlot string) string {
+	return fmt.Sprintf("meal:%d/%s", dayIndex, mealSlot)
+}
+
+func (s *Service) planMealPlan(ctx context.Context, userID string, proposal storage.AIProposal) (*applyPlan, error) {
+	if s.mealPlanService == nil {
+		return nil, ErrUnsupportedKind
+	}
+
+	payload, err := parseMealPlanPayload(proposal.Payload)
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+	if payload.Title == "" || len(payload.Title) > 200 {
+		return nil, ErrInvalidPayload
+	}
+	if len(payload.Items) == 0 || len(payload.Items) > 28 {
+		return nil, ErrInvalidPayload
+	}
+
+	plan, planItems, found, err := s.mealPlanService.GetActive(ctx, userID, proposal.ProfileID.String())
+	if err != nil {
+		return nil, err
+	}
+	snapshot := mealPlanSnapshot{Items: make([]mealplans.MealPlanItemUpsertInput, 0, len(planItems))}
+	if found {
+		snapshot.Plan = plan
+	}
+	current := make([]keyedItem[mealplans.MealPlanItemUpsertInput], 0, len(planItems))
+	for _, item := range planItems {
+		value := mealplans.MealPlanItemUpsertInput{
+			DayIndex:       item.DayIndex,
+			MealSlot:       item.MealSlot,
+			Title:          item.Title,
+			Notes:          item.Notes,
+			ApproxKcal:     item.ApproxKcal,
+			ApproxProteinG: item.ApproxProteinG,
+			ApproxFatG:     item.ApproxFatG,
+			ApproxCarbsG:   item.ApproxCarbsG,
+		}
+		snapshot.Items = append(snapshot.Items, value)
+		current = append(current, keyedItem[mealplans.MealPlanItemUpsertInput]{
+			key:   mealPlanItemKey(item.DayIndex, item.MealSlot),
+			label: item.Title,
+			value: value,
+		})
+	}
+	proposed := make([]keyedItem[mealplans.MealPlanItemUpsertInput], 0, len(payload.Items))
+	for _, item := range payload.Items {
+		proposed = append(proposed, keyedItem[mealplans.MealPlanItemUpsertInput]{
+			key:   mealPlanItemKey(item.DayIndex, item.MealSlot),
+			label: item.Title,
+			value: mealplans.MealPlanItemUpsertInput{
+				DayIndex:       item.DayIndex,
+				MealSlot:       item.MealSlot,
+				Title:          item.Title,
+				Notes:          item.Notes,
+				ApproxKcal:     item.ApproxKcal,
+				ApproxProteinG: item.ApproxProteinG,
+				ApproxFatG:     item.ApproxFatG,
+				ApproxCarbsG:   item.ApproxCarbsG,
+			},
+		})
+	}
+	proposed = uniqueItems(proposed)
+
+	changes := make([]ChangeDTO, 0)
+	if found && plan.Title != payload.Title {
+		changes = append(changes, ChangeDTO{Key: "plan:title", Op: opUpdate, Before: plan.Title, After: payload.Title})
+	}
+	changes = append(changes, diffItems(current, proposed)...)
+
+	return &applyPlan{
+		changes:  changes,
+		snapshot: snapshot,
+		apply: func(ctx context.Context, selected map[string]bool) (*AppliedResultDTO, error) {
+			title := payload.Title
+			if selected != nil && found && !selected["plan:title"] {
+				title = plan.Title
+			}
+
+			merged := mergeItems(current, proposed, selected)
+			if len(merged) == 0 {
+				return nil, ErrInvalidPayload
+			}
+			items := make([]mealplans.MealPlanItemUpsertInput, 0, len(merged))
+			for _, item := range merged {
+				items = append(items, item.value)
+			}
+
+			_, _, err := s.mealPlanService.ReplaceActive(ctx, userID, mealplans.ReplaceMealPlanRequest{
+				ProfileID: proposal.ProfileID.String(),
+				Title:     title,
+				Items:     items,
+			})
+			if err != nil {
+				return nil, err
+			}
+			count := len(items)
+			return &AppliedResultDTO{MealPlanItemsCreated: &count}, nil
+		},
+	}, nil
+}
+
+// memorySnapshot records the fact an apply stored, so undo removes only
+// what it added.
+type memorySnapshot struct {
+	CreatedFactID *uuid.UUID `json:"created_fact_id,omitempty"`
+}
+
+func (s *Service) planMemory(ctx context.Context, userID string, proposal storage.AIProposal) (*applyPlan, error) {
+	chatStorage, ok := s.profileStorage.(storage.ChatStorage)
+	if !ok || chatStorage == nil {
+		return nil, ErrUnsupportedKind
+	}
+
+	payload, err := parseMemoryPayload(proposal.Payload)
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+	fact := strings.TrimSpace(payload.Fact)
+	if fact == "" || len(fact) > 500 {
+		return nil, ErrInvalidPayload
+	}
+
+	existing, err := chatStorage.ListFacts(ctx, userID, proposal.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+	// A fact the assistant already knows is not stored twice; the
+	// proposal is still marked applied.
+	var knownID uuid.UUID
+	for _, known := range existing {
+		if strings.EqualFold(strings.TrimSpace(known.Content), fact) {
+			knownID = known.ID
+			break
+		}
+	}
+
+	changes := make([]ChangeDTO, 0, 1)
+	if knownID == uuid.Nil {
+		changes = append(changes, ChangeDTO{Key: "fact", Op: opAdd, After: fact})
+	}
+
+	plan := &applyPlan{changes: changes}
+	snapshot := &memorySnapshot{}
+	plan.snapshot = snapshot
+	plan.apply = func(ctx context.Context, selected map[string]bool) (*AppliedResultDTO, error) {
+		factID := knownID
+		if factID == uuid.Nil {
+			if len(existing) >= maxMemoryFacts {
+				return nil, ErrMemoryFull
+			}
+			created, err := chatStorage.InsertFact(ctx, userID, proposal.ProfileID, fact)
+			if err != nil {
+				return nil, err
+			}
+			factID = created.ID
+			snapshot.CreatedFactID = &factID
+		}
+		return &AppliedResultDTO{MemoryFactID: &factID}, nil
+	}
+	return plan, nil
+}
+
+// restore puts back the snapshot taken when the proposal was applied.
+// Supplements created by a vitamins_schedule apply are kept; only the
+// schedules are restored.
+func (s *Service) restore(ctx context.Context, userID string, proposal storage.AIProposal) error {
+	switch proposal.Kind {
+	case "settings_update":
+		var snapshot settings.SettingsDTO
+		if err := json.Unmarshal(proposal.Snapshot, &snapshot); err != nil {
+			return ErrUndoUnavailable
+		}
+		_, err := s.settingsService.Upsert(ctx, userID, snapshot)
+		return err
+	case "vitamins_schedule":
+		schedulesStorage, ok := s.profileStorage.(storage.SupplementSchedulesStorage)
+		if !ok || schedulesStorage == nil {
+			return ErrUnsupportedKind
+		}
+		var snapshot vitaminsSnapshot
+		if err := json.Unmarshal(proposal.Snapshot, &snapshot); err != nil {
+			return ErrUndoUnavailable
+		}
+		upserts := make([]storage.ScheduleUpsert, 0, len(snapshot.Schedules))
+		for _, schedule := range snapshot.Schedules {
+			upserts = append(upserts, storage.ScheduleUpsert{
+				SupplementID: schedule.SupplementID,
+				TimeMinutes:  schedule.TimeMinutes,
+				DaysMask:     schedule.DaysMask,
+				IsEnabled:    schedule.IsEnabled,
+			})
+		}
+		_, err := schedulesStorage.ReplaceAll(ctx, userID, proposal.ProfileID, upserts)
+		return err
+	case "workout_plan":
+		if s.workoutService == nil {
+			return ErrUnsupportedKind
+		}
+		var snapshot workouts.GetPlanResponse
+		if err := json.Unmarshal(proposal.Snapshot, &snapshot); err != nil {
+			return ErrUndoUnavailable
+		}
+		return s.workoutService.RestorePlan(ctx, proposal.ProfileID, &snapshot)
+	case "nutrition_plan":
+		if s.nutritionService == nil {
+			return ErrUnsupportedKind
+		}
+		var snapshot nutritionSnapshot
+		if err := json.Unmarshal(proposal.Snapshot, &snapshot); err != nil {
+			return ErrUndoUnavailable
+		}
+		return s.nutritionService.UpsertSimple(ctx, userID, proposal.ProfileID,
+			snapshot.CaloriesKcal, snapshot.ProteinG, snapshot.FatG, snapshot.CarbsG, snapshot.CalciumMg)
+	case "meal_plan":
+		if s.mealPlanService == nil {
+			return ErrUnsupportedKind
+		}
+		var snapshot mealPlanSnapshot
+		if err := json.Unmarshal(proposal.Snapshot, &snapshot); err != nil {
+			return ErrUndoUnavailable
+		}
+		if snapshot.Plan == nil {
+			return s.mealPlanService.DeleteActive(ctx, userID, proposal.ProfileID.String())
+		}
+		_, _, err := s.mealPlanService.ReplaceActive(ctx, userID, mealplans.ReplaceMealPlanRequest{
+			ProfileID: proposal.ProfileID.String(),
+			Title:     snapshot.Plan.Title,
+			Items:     snapshot.Items,
+		})
+		return err
+	case "memory":
+		chatStorage, ok := s.profileStorage.(storage.ChatStorage)
+		if !ok || chatStorage == nil {
+			return ErrUnsupportedKind
+		}
+		var snapshot memorySnapshot
+		if err := json.Unmarshal(proposal.Snapshot, &snapshot); err != nil {
+			return ErrUndoUnavailable
+		}
+		if snapshot.CreatedFactID == nil {
+			return nil
+		}
+		// The fact may already have been deleted by the user.
+		_, err := chatStorage.DeleteFact(ctx, userID, *snapshot.CreatedFactID)
+		return err
+	case "coaching_program", "coaching_adjustment":
+		return s.restoreCoaching(ctx, userID, proposal)
+	default:
+		return ErrUnsupportedKind
+	}
+}
@@ -5,9 +5,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"strings"
+	"time"
 
+	"github.com/fdg312/health-hub/internal/coaching"
 	"github.com/fdg312/health-hub/internal/mealplans"
+	"github.com/fdg312/health-hub/internal/nutrition"
 	"github.com/fdg312/health-hub/internal/settings"
 	"github.com/fdg312/health-hub/internal/storage"
 	"github.com/fdg312/health-hub/internal/userctx"
@@ -16,29 +20,56 @@ import (
 )
 
 var (
-	ErrUnauthorized     = errors.New("unauthorized")
-	ErrInvalidRequest   = errors.New("invalid request")
-	ErrInvalidPayload   = errors.New("invalid payload")
-	ErrUnsupportedKind  = errors.New("unsupported kind")
-	ErrProposalNotFound = errors.New("proposal not found")
-	ErrNotPending       = errors.New("not pending")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrInvalidRequest     = errors.New("invalid request")
+	ErrInvalidPayload     = errors.New("invalid payload")
+	ErrUnsupportedKind    = errors.New("unsupported kind")
+	ErrProposalNotFound   = errors.New("proposal not found")
+	ErrNotPending         = errors.New("not pending")
+	ErrMemoryFull         = errors.New("memory full")
+	ErrInvalidSelection   = errors.New("invalid selection")
+	ErrNotApplied         = errors.New("not applied")
+	ErrUndoExpired        = errors.New("undo expired")
+	ErrUndoUnavailable    = errors.New("undo unavailable")
+	ErrExpired            = errors.New("expired")
+	ErrNoBaseline         = errors.New("no baseline")
+	ErrProgramUnavailable = errors.New("program unavailable")
 )
 
+// maxMemoryFacts caps how many facts the assistant remembers per profile.
+const maxMemoryFacts = 50
+
+// defaultUndoWindow is how long an applied proposal can be undone.
+const defaultUndoWindow = 7 * 24 * time.Hour
+
 type settingsService interface {
 	GetOrDefault(ctx context.Context, ownerUserID string) (settings.SettingsResponse, error)
 	Upsert(ctx context.Context, ownerUserID string, dto settings.SettingsDTO) (settings.SettingsDTO, error)
 }
 
 type workoutService interface {
+	GetActivePlan(ctx context.Context, profileID uuid.UUID) (*workouts.GetPlanResponse, error)
 	ReplacePlanAndItems(ctx context.Context, req *workouts.ReplaceItemsRequest) (*workouts.ReplaceItemsResponse, error)
+	RestorePlan(ctx context.Context, profileID uuid.UUID, snapshot *workouts.GetPlanResponse) error
 }
 
 type nutritionService interface {
+	GetOrDefault(ctx context.Context, ownerUserID string, profileID uuid.UUID) (nutrition.TargetsDTO, bool, error)
 	UpsertSimple(ctx context.Context, ownerUserID string, profileID uuid.UUID, caloriesKcal, proteinG, fatG, carbsG, calciumMg int) error
 }
 
 type mealPlanService interface {
+	GetActive(ctx context.Context, ownerUserID string, profileID string) (*mealplans.MealPlanDTO, []mealplans.MealPlanItemDTO, bool, error)
 	ReplaceActive(ctx context.Context, ownerUserID string, req mealplans.ReplaceMealPlanRequest) (*mealplans.MealPlanDTO, []mealplans.MealPlanItemDTO, error)
+	DeleteActive(ctx context.Context, ownerUserID string, profileID string) error
+}
+
+type coachingService interface {
+	Baseline(ctx context.Context, ownerUserID string, profileID uuid.UUID, metric string) (float64, bool, error)
+	Create(ctx context.Context, ownerUserID string, req coaching.CreateProgramRequest) (*coaching.ProgramDTO, error)
+	GetProgram(ctx context.Context, ownerUserID string, programID uuid.UUID) (*coaching.ProgramDTO, bool, error)
+	Reschedule(ctx context.Context, ownerUserID string, programID uuid.UUID, targetDate string, milestones []coaching.MilestoneInput) (*coaching.ProgramDTO, error)
+	Delete(ctx context.Context, ownerUserID string, programID uuid.UUID) error
 }
 
 type Service struct {
@@ -48,6 +79,9 @@ type Service struct {
 	workoutService   workoutService
 	nutritionService nutritionService
 	mealPlanService  mealPlanService
+	coachingService  coachingService
+	undoWindow       time.Duration
+	now              func() time.Time
 }
 
 func NewService(
@@ -59,6 +93,8 @@ func NewService(
 		proposalsStorage: proposalsStorage,
 		profileStorage:   profileStorage,
 		settingsService:  settingsService,
+		undoWindow:       defaultUndoWindow,
+		now:              time.Now,
 	}
 }
 
@@ -80,6 +116,21 @@ func (s *Service) WithMealPlanService(ms mealPlanService) *Service {
 	return s
 }
 
+// WithCoachingService adds coaching service for coaching_program and
+// coaching_adjustment proposals
+func (s *Service) WithCoachingService(cs coachingService) *Service {
+	s.coachingService = cs
+	return s
+}
+
+// WithUndoWindow sets how long an applied proposal can be undone.
+func (s *Service) WithUndoWindow(window time.Duration) *Service {
+	if window > 0 {
+		s.undoWindow = window
+	}
+	return s
+}
+
 func (s *Service) List(ctx context.Context, profileID uuid.UUID, status string, limit int) (*ListProposalsResponse, error) {
 	userID := strings.TrimSpace(userIDFromContext(ctx))
 	if userID == "" {
@@ -89,7 +140,7 @@ func (s *Service) List(ctx context.Context, profileID uuid.UUID, status string,
 		return nil, ErrInvalidRequest
 	}
 
-	statusFilter, err := normalizeStatusFilter(status)
+	statuses, err := parseStatusFilter(status)
 	if err != nil {
 		return nil, ErrInvalidRequest
 	}
@@ -104,7 +155,7 @@ func (s *Service) List(ctx context.Context, profileID uuid.UUID, status string,
 		return nil, err
 	}
 
-	rows, err := s.proposalsStorage.List(ctx, userID, profileID, statusFilter, limit)
+	rows, err := s.proposalsStorage.List(ctx, userID, profileID, statuses, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +168,9 @@ func (s *Service) List(ctx context.Context, profileID uuid.UUID, status string,
 	return &ListProposalsResponse{Proposals: dtos}, nil
 }
 
-func (s *Service) Apply(ctx context.Context, proposalID uuid.UUID) (*ApplyProposalResponse, error) {
+// Get returns a proposal with its status history and records that the
+// owner has seen it.
+func (s *Service) Get(ctx context.Context, proposalID uuid.UUID) (*ProposalDetailResponse, error) {
 	userID := strings.TrimSpace(userIDFromContext(ctx))
 	if userID == "" {
 		return nil, ErrUnauthorized
@@ -136,270 +189,154 @@ func (s *Service) Apply(ctx context.Context, proposalID uuid.UUID) (*ApplyPropos
 	if err := s.ensureProfileOwned(ctx, userID, proposal.ProfileID); err != nil {
 		return nil, ErrProposalNotFound
 	}
-	if proposal.Status != "pending" {
-		return nil, ErrNotPending
-	}
-
-	switch proposal.Kind {
-	case "settings_update":
-		patch, err := parseSettingsPatch(proposal.Payload)
-		if err != nil {
-			return nil, ErrInvalidPayload
-		}
-
-		current, err := s.settingsService.GetOrDefault(ctx, userID)
-		if err != nil {
-			return nil, err
-		}
-		merged := mergeSettings(current.Settings, patch)
-		if err := merged.Validate(); err != nil {
-			return nil, ErrInvalidPayload
-		}
-
-		updated, err := s.settingsService.Upsert(ctx, userID, merged)
-		if err != nil {
-			return nil, ErrInvalidPayload
-		}
-
-		if err := s.proposalsStorage.UpdateStatus(ctx, userID, proposalID, "applied"); err != nil {
-			return nil, err
-		}
-
-		return &ApplyProposalResponse{
-			Status: "applied",
-			Applied: &AppliedResultDTO{
-				Settings: &updated,
-			},
-		}, nil
-	case "vitamins_schedule":
-		payload, err := parseVitaminsSchedulePayload(proposal.Payload)
-		if err != nil {
-			return nil, ErrInvalidPayload
-		}
-
-		supplementsStorage, ok := s.profileStorage.(storage.SupplementsStorage)
-		if !ok || supplementsStorage == nil {
-			return nil, ErrUnsupportedKind
-		}
-		schedulesStorage, ok := s.profileStorage.(storage.SupplementSchedulesStorage)
-		if !ok || schedulesStorage == nil {
-			return nil, ErrUnsupportedKind
-		}
-
-		existingSupplements, err := supplementsStorage.ListSupplements(ctx, proposal.ProfileID)
-		if err != nil {
-			return nil, err
-		}
-		byName := make(map[string]storage.Supplement, len(existingSupplements))
-		for _, sup := range existingSupplements {
-			key := normalizeSupplementName(sup.Name)
-			if key == "" {
-				continue
-			}
-			byName[key] = sup
-		}
-
-		upserts := make([]storage.ScheduleUpsert, 0, len(payload.Items))
-		for _, item := range payload.Items {
-			normalizedName := normalizeSupplementName(item.SupplementName)
-			supplement, found := byName[normalizedName]
-			if !found {
-				newSupplement := &storage.Supplement{
-					ProfileID: proposal.ProfileID,
-					Name:      strings.TrimSpace(item.SupplementName),
-				}
-				if err := supplementsStorage.CreateSupplement(ctx, newSupplement); err != nil {
-					return nil, err
-				}
-				supplement = *newSupplement
-				byName[normalizedName] = supplement
-			}
-
-			enabled := true
-			if item.IsEnabled != nil {
-				enabled = *item.IsEnabled
-			}
-			upserts = append(upserts, storage.ScheduleUpsert{
-				SupplementID: supplement.ID,
-				TimeMinutes:  item.TimeMinutes,
-				DaysMask:     item.DaysMask,
-				IsEnabled:    enabled,
-			})
-		}
-
-		saved, err := schedulesStorage.ReplaceAll(ctx, userID, proposal.ProfileID, upserts)
-		if err != nil {
-			return nil, err
-		}
-		if err := s.proposalsStorage.UpdateStatus(ctx, userID, proposalID, "applied"); err != nil {
-			return nil, err
-		}
-		count := len(saved)
-		return &ApplyProposalResponse{
-			Status: "applied",
-			Applied: &AppliedResultDTO{
-				SchedulesCreated: &count,
-			},
-		}, nil
-	case "workout_plan":
-		if s.workoutService == nil {
-			return nil, ErrUnsupportedKind
-		}
-
-		payload, err := parseWorkoutPlanPayload(proposal.Payload)
-		if err != nil {
-			return nil, ErrInvalidPayload
-		}
-
-		// Validate payload
-		if !payload.Replace {
-			return nil, ErrInvalidPayload
-		}
-		if len(payload.Items) == 0 || len(payload.Items) > 30 {
-			return nil, ErrInvalidPayload
-		}
-
-		// Convert to workout service request
-		items := make([]workouts.ItemUpsertRequest, 0, len(payload.Items))
-		for _, item := range payload.Items {
-			items = append(items, workouts.ItemUpsertRequest{
-				Kind:        item.Kind,
-				TimeMinutes: item.TimeMinutes,
-				DaysMask:    item.DaysMask,
-				DurationMin: item.DurationMin,
-				Intensity:   item.Intensity,
-				Note:        item.Note,
-				Details:     item.Details,
-			})
-		}
-
-		req := &workouts.ReplaceItemsRequest{
-			ProfileID: proposal.ProfileID,
-			Title:     payload.Title,
-			Goal:      payload.Goal,
-			Replace:   payload.Replace,
-			Items:     items,
-		}
 
-		_, err = s.workoutService.ReplacePlanAndItems(ctx, req)
-		if err != nil {
-			return nil, err
-		}
-
-		if err := s.proposalsStorage.UpdateStatus(ctx, userID, proposalID, "applied"); err != nil {
-			return nil, err
-		}
+	if err := s.proposalsStorage.MarkViewed(ctx, userID, proposalID); err != nil {
+		return nil, err
+	}
+	events, err := s.proposalsStorage.ListEvents(ctx, userID, proposalID)
+	if err != nil {
+		return nil, err
+	}
 
-		count := len(payload.Items)
-		return &ApplyProposalResponse{
-			Status: "applied",
-			Applied: &AppliedResultDTO{
-				WorkoutItemsCreated: &count,
-			},
-		}, nil
-	case "nutrition_plan":
-		if s.nutritionService == nil {
-			return nil, ErrUnsupportedKind
-		}
+	history := make([]ProposalEventDTO, 0, len(events))
+	for _, event := range events {
+		history = append(history, ProposalEventDTO{Event: event.Event, At: event.CreatedAt})
+	}
+	return &ProposalDetailResponse{Proposal: proposalToDTO(proposal), History: history}, nil
+}
 
-		payload, err := parseNutritionPlanPayload(proposal.Payload)
-		if err != nil {
-			return nil, ErrInvalidPayload
-		}
+// Preview reports what applying a pending proposal would change.
+func (s *Service) Preview(ctx context.Context, proposalID uuid.UUID) (*PreviewProposalResponse, error) {
+	userID, proposal, err := s.getPending(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.proposalsStorage.MarkViewed(ctx, userID, proposalID); err != nil {
+		return nil, err
+	}
 
-		// Validate payload - all fields are required
-		if payload.CaloriesKcal < 800 || payload.CaloriesKcal > 6000 {
-			return nil, ErrInvalidPayload
-		}
-		if payload.ProteinG < 0 || payload.ProteinG > 400 {
-			return nil, ErrInvalidPayload
-		}
-		if payload.FatG < 0 || payload.FatG > 400 {
-			return nil, ErrInvalidPayload
-		}
-		if payload.CarbsG < 0 || payload.CarbsG > 400 {
-			return nil, ErrInvalidPayload
-		}
-		if payload.CalciumMg < 0 || payload.CalciumMg > 5000 {
-			return nil, ErrInvalidPayload
-		}
+	plan, err := s.buildPlan(ctx, userID, proposal)
+	if err != nil {
+		return nil, err
+	}
 
-		// Call nutrition service to upsert targets
-		err = s.nutritionService.UpsertSimple(ctx, userID, proposal.ProfileID,
-			payload.CaloriesKcal, payload.ProteinG, payload.FatG, payload.CarbsG, payload.CalciumMg)
-		if err != nil {
-			return nil, err
-		}
+	return &PreviewProposalResponse{
+		ProposalID: proposal.ID,
+		Kind:       proposal.Kind,
+		Changes:    plan.changes,
+	}, nil
+}
 
-		if err := s.proposalsStorage.UpdateStatus(ctx, userID, proposalID, "applied"); err != nil {
-			return nil, err
-		}
+// Apply applies a pending proposal. keys selects changes from Preview; nil
+// applies all of them. The state before apply is kept for Undo.
+func (s *Service) Apply(ctx context.Context, proposalID uuid.UUID, keys []string) (*ApplyProposalResponse, error) {
+	userID, proposal, err := s.getPending(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
 
-		updated := true
-		return &ApplyProposalResponse{
-			Status: "applied",
-			Applied: &AppliedResultDTO{
-				NutritionTargets: &updated,
-			},
-		}, nil
-	case "meal_plan":
-		if s.mealPlanService == nil {
-			return nil, ErrUnsupportedKind
+	// Claim the proposal before touching user state, so a concurrent Apply,
+	// Reject or expiry cannot change anything and the snapshot below is the
+	// only baseline Undo will see.
+	claimed, err := s.proposalsStorage.ClaimPending(ctx, userID, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrNotPending
+	}
+	resp, err := s.applyClaimed(ctx, userID, proposal, keys)
+	if err != nil {
+		if releaseErr := s.proposalsStorage.ReleaseClaim(context.WithoutCancel(ctx), userID, proposalID); releaseErr != nil {
+			slog.Error("proposals: release claim failed", "proposal_id", proposalID, "error", releaseErr)
 		}
+		return nil, err
+	}
+	return resp, nil
+}
 
-		payload, err := parseMealPlanPayload(proposal.Payload)
-		if err != nil {
-			return nil, ErrInvalidPayload
-		}
+// applyClaimed applies a proposal claimed by Apply and stores the snapshot.
+func (s *Service) applyClaimed(ctx context.Context, userID string, proposal storage.AIProposal, keys []string) (*ApplyProposalResponse, error) {
+	plan, err := s.buildPlan(ctx, userID, proposal)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := selectChanges(plan.changes, keys)
+	if err != nil {
+		return nil, err
+	}
 
-		// Validate payload
-		if payload.Title == "" || len(payload.Title) > 200 {
-			return nil, ErrInvalidPayload
-		}
-		if len(payload.Items) == 0 || len(payload.Items) > 28 {
-			return nil, ErrInvalidPayload
-		}
+	applied, err := plan.apply(ctx, selected)
+	if err != nil {
+		return nil, err
+	}
 
-		// Convert to meal plan service request
-		items := make([]mealplans.MealPlanItemUpsertInput, 0, len(payload.Items))
-		for _, item := range payload.Items {
-			items = append(items, mealplans.MealPlanItemUpsertInput{
-				DayIndex:       item.DayIndex,
-				MealSlot:       item.MealSlot,
-				Title:          item.Title,
-				Notes:          item.Notes,
-				ApproxKcal:     item.ApproxKcal,
-				ApproxProteinG: item.ApproxProteinG,
-				ApproxFatG:     item.ApproxFatG,
-				ApproxCarbsG:   item.ApproxCarbsG,
-			})
-		}
+	snapshot, err := json.Marshal(plan.snapshot)
+	if err != nil {
+		return nil, err
+	}
+	marked, found, err := s.proposalsStorage.MarkApplied(ctx, userID, proposal.ID, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNotPending
+	}
 
-		req := mealplans.ReplaceMealPlanRequest{
-			ProfileID: proposal.ProfileID.String(),
-			Title:     payload.Title,
-			Items:     items,
-		}
+	resp := &ApplyProposalResponse{
+		Status:  "applied",
+		Applied: applied,
+	}
+	if marked.AppliedAt != nil {
+		undoUntil := marked.AppliedAt.Add(s.undoWindow)
+		resp.UndoUntil = &undoUntil
+	}
+	return resp, nil
+}
 
-		_, _, err = s.mealPlanService.ReplaceActive(ctx, userID, req)
-		if err != nil {
-			return nil, err
-		}
+// Undo restores the state captured when the proposal was applied.
+func (s *Service) Undo(ctx context.Context, proposalID uuid.UUID) (*UndoProposalResponse, error) {
+	userID := strings.TrimSpace(userIDFromContext(ctx))
+	if userID == "" {
+		return nil, ErrUnauthorized
+	}
+	if proposalID == uuid.Nil {
+		return nil, ErrInvalidRequest
+	}
 
-		if err := s.proposalsStorage.UpdateStatus(ctx, userID, proposalID, "applied"); err != nil {
-			return nil, err
-		}
+	proposal, found, err := s.proposalsStorage.Get(ctx, userID, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrProposalNotFound
+	}
+	if err := s.ensureProfileOwned(ctx, userID, proposal.ProfileID); err != nil {
+		return nil, ErrProposalNotFound
+	}
+	if proposal.Status != "applied" {
+		return nil, ErrNotApplied
+	}
+	// Proposals applied before snapshots existed cannot be undone.
+	if proposal.AppliedAt == nil || len(proposal.Snapshot) == 0 {
+		return nil, ErrUndoUnavailable
+	}
+	if s.now().After(proposal.AppliedAt.Add(s.undoWindow)) {
+		return nil, ErrUndoExpired
+	}
 
-		count := len(payload.Items)
-		return &ApplyProposalResponse{
-			Status: "applied",
-			Applied: &AppliedResultDTO{
-				MealPlanItemsCreated: &count,
-			},
-		}, nil
-	default:
-		return nil, ErrUnsupportedKind
+	if err := s.restore(ctx, userID, proposal); err != nil {
+		return nil, err
 	}
+	undone, err := s.proposalsStorage.UpdateStatus(ctx, userID, proposalID, "applied", "undone")
+	if err != nil {
+		return nil, err
+	}
+	if !undone {
+		return nil, ErrNotApplied
+	}
+
+	return &UndoProposalResponse{Status: "undone"}, nil
 }
 
 func (s *Service) Reject(ctx context.Context, proposalID uuid.UUID) (*RejectProposalResponse, error) {
@@ -425,13 +362,80 @@ func (s *Service) Reject(ctx context.Context, proposalID uuid.UUID) (*RejectProp
 		return nil, ErrNotPending
 	}
 
-	if err := s.proposalsStorage.UpdateStatus(ctx, userID, proposalID, "rejected"); err != nil {
+	rejected, err := s.proposalsStorage.UpdateStatus(ctx, userID, proposalID, "pending", "rejected")
+	if err != nil {
 		return nil, err
 	}
+	if !rejected {
+		return nil, ErrNotPending
+	}
 
 	return &RejectProposalResponse{Status: "rejected"}, nil
 }
 
+// getPending loads a pending proposal owned by the caller.
+func (s *Service) getPending(ctx context.Context, proposalID uuid.UUID) (string, storage.AIProposal, error) {
+	userID := strings.TrimSpace(userIDFromContext(ctx))
+	if userID == "" {
+		return "", storage.AIProposal{}, ErrUnauthorized
+	}
+	if proposalID == uuid.Nil {
+		return "", storage.AIProposal{}, ErrInvalidRequest
+	}
+
+	proposal, found, err := s.proposalsStorage.Get(ctx, userID, proposalID)
+	if err != nil {
+		return "", storage.AIProposal{}, err
+	}
+	if !found {
+		return "", storage.AIProposal{}, ErrProposalNotFound
+	}
+	if err := s.ensureProfileOwned(ctx, userID, proposal.ProfileID); err != nil {
+		return "", storage.AIProposal{}, ErrProposalNotFound
+	}
+	if proposal.Status != "pending" {
+		return "", storage.AIProposal{}, ErrNotPending
+	}
+	// The sweep runs periodically; a proposal past its expiry is closed
+	// here rather than applied late.
+	if proposal.ExpiresAt != nil && !s.now().Before(*proposal.ExpiresAt) {
+		expired, err := s.proposalsStorage.UpdateStatus(ctx, userID, proposalID, "pending", "expired")
+		if err != nil {
+			return "", storage.AIProposal{}, err
+		}
+		if !expired {
+			return "", storage.AIProposal{}, ErrNotPending
+		}
+		return "", storage.AIProposal{}, ErrExpired
+	}
+	return userID, proposal, nil
+}
+
+// ExpirePending moves pending proposals past their expiry to expired.
+func (s *Service) ExpirePending(ctx context.Context) (int64, error) {
+	return s.proposalsStorage.ExpirePending(ctx, s.now().UTC())
+}
+
+// RunExpiry expires stale proposals every interval until ctx is cancelled.
+func (s *Service) RunExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if expired, err := s.ExpirePending(ctx); err != nil {
+			slog.Error("proposals: expiry failed", "error", err)
+		} else if expired > 0 {
+			slog.Info("proposals: expired stale proposals", "expired", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func (s *Service) ensureProfileOwned(ctx context.Context, ownerUserID string, profileID uuid.UUID) error {
 	profile, err := s.profileStorage.GetProfile(ctx, profileID)
 	if err != nil {
@@ -451,13 +455,24 @@ func userIDFromContext(ctx context.Context) string {
 	return strings.TrimSpace(userID)
 }
 
-func normalizeStatusFilter(status string) (string, error) {
-	switch strings.TrimSpace(status) {
-	case "", "pending", "applied", "rejected":
-		return strings.TrimSpace(status), nil
-	default:
-		return "", ErrInvalidRequest
+// parseStatusFilter reads a comma-separated list of statuses; empty means
+// any status.
+func parseStatusFilter(raw string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	statuses := make([]string, 0, 2)
+	for _, status := range strings.Split(raw, ",") {
+		switch status = strings.TrimSpace(status); status {
+		case "pending", "applying", "applied", "rejected", "undone", "expired", "superseded":
+			statuses = append(statuses, status)
+		default:
+			return nil, ErrInvalidRequest
+		}
 	}
+	return statuses, nil
 }
 
 type settingsPatch struct {
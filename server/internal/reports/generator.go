@@ -25,6 +25,7 @@ type Generator struct {
 	metricsStorage  storage.MetricsStorage
 	checkinsStorage CheckinsStorage
 	profileStorage  ProfileStorage
+	labResults      storage.LabResultsStorage
 }
 
 // NewGenerator creates a new report generator
@@ -58,7 +59,11 @@ func (g *Generator) GenerateReport(ctx context.Context, req CreateReportRequest)
 	// Generate based on format
 	switch req.Format {
 	case FormatPDF:
-		return g.generatePDF(req, dailyMetrics, checkins)
+		labTrends, err := g.fetchLabTrends(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch lab results: %w", err)
+		}
+		return g.generatePDF(req, dailyMetrics, checkins, labTrends)
 	case FormatCSV:
 		return g.generateCSV(req, dailyMetrics, checkins)
 	default:
@@ -168,7 +173,7 @@ func (g *Generator) generateCSV(req CreateReportRequest, dailyMetrics []storage.
 }
 
 // generatePDF generates a PDF report in Russian with Cyrillic support
-func (g *Generator) generatePDF(req CreateReportRequest, dailyMetrics []storage.DailyMetricRow, checkins []Checkin) ([]byte, error) {
+func (g *Generator) generatePDF(req CreateReportRequest, dailyMetrics []storage.DailyMetricRow, checkins []Checkin, labTrends []labTrend) ([]byte, error) {
 	pdf := gofpdf.New("P", "mm", "A4", "")
 
 	// Try to add DejaVuSans font for Cyrillic support
@@ -250,6 +255,11 @@ func (g *Generator) generatePDF(req CreateReportRequest, dailyMetrics []storage.
 
 	g.drawRecentDaysTable(pdf, dailyMetrics, checkins, fontName)
 
+	// Lab result trends, when there are any
+	if len(labTrends) > 0 {
+		g.drawLabTrends(pdf, labTrends, fontName)
+	}
+
 	var buf bytes.Buffer
 	if err := pdf.Output(&buf); err != nil {
 		return nil, fmt.Errorf("failed to generate PDF: %w", err)
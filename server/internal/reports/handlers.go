@@ -7,6 +7,8 @@ import (
 	"strconv"
 
 	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 // Handlers handles HTTP requests for reports
@@ -41,6 +43,7 @@ func (h *Handlers) HandleCreate(w http.ResponseWriter, r *http.Request) {
 		case ErrProfileNotFound:
 			writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
 		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		}
 		return
@@ -50,6 +53,7 @@ func (h *Handlers) HandleCreate(w http.ResponseWriter, r *http.Request) {
 	baseURL := getBaseURL(r)
 	downloadURL, err := h.service.GetReportDownloadURL(r.Context(), report.ID, baseURL)
 	if err != nil {
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to generate download URL")
 		return
 	}
@@ -105,6 +109,7 @@ func (h *Handlers) HandleList(w http.ResponseWriter, r *http.Request) {
 		if err == ErrProfileNotFound {
 			writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
 		} else {
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		}
 		return
@@ -147,6 +152,7 @@ func (h *Handlers) HandleDownload(w http.ResponseWriter, r *http.Request) {
 		if err == ErrReportNotFound {
 			writeError(w, http.StatusNotFound, "report_not_found", "Report not found")
 		} else {
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		}
 		return
@@ -157,6 +163,7 @@ func (h *Handlers) HandleDownload(w http.ResponseWriter, r *http.Request) {
 		// Local mode: serve file directly
 		data, contentType, err := h.service.GetReportData(r.Context(), reportID)
 		if err != nil {
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 			return
 		}
@@ -165,16 +172,19 @@ func (h *Handlers) HandleDownload(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", contentType)
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
 		w.Header().Set("Content-Length", strconv.FormatInt(int64(len(data)), 10))
+		h.service.recordDownload(r.Context(), report)
 		w.Write(data)
 	} else {
 		// S3 mode: redirect to presigned URL
 		baseURL := getBaseURL(r)
 		presignedURL, err := h.service.GetReportDownloadURL(r.Context(), reportID, baseURL)
 		if err != nil {
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", "Failed to generate download URL")
 			return
 		}
 
+		h.service.recordDownload(r.Context(), report)
 		http.Redirect(w, r, presignedURL, http.StatusFound)
 	}
 }
@@ -192,6 +202,7 @@ func (h *Handlers) HandleDelete(w http.ResponseWriter, r *http.Request) {
 		if err == ErrReportNotFound {
 			writeError(w, http.StatusNotFound, "report_not_found", "Report not found")
 		} else {
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		}
 		return
@@ -205,11 +216,15 @@ func (h *Handlers) HandleDelete(w http.ResponseWriter, r *http.Request) {
 func writeError(w http.ResponseWriter, status int, code, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
+	detail := map[string]string{
+		"code":    code,
+		"message": message,
+	}
+	if requestID := logging.ResponseRequestID(w); requestID != "" {
+		detail["request_id"] = requestID
+	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": map[string]string{
-			"code":    code,
-			"message": message,
-		},
+		"error": detail,
 	})
 }
 
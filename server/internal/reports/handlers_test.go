@@ -321,3 +321,54 @@ func TestHandleDelete_NotFound(t *testing.T) {
 		t.Errorf("expected status 404, got %d", w.Code)
 	}
 }
+
+func TestCreatePDF_WithLabTrends(t *testing.T) {
+	t.Setenv("SKIP_CUSTOM_FONT", "1")
+
+	service, profileID := setupTestService()
+	labResults := memory.New().GetLabResultsStorage()
+	service.WithLabResults(labResults)
+
+	low, high := 3.9, 5.5
+	for _, result := range []storage.LabResult{
+		{Analyte: "glucose", Name: "Глюкоза", Value: 5.1, Unit: "mmol/L", RefLow: &low, RefHigh: &high, Flag: "normal", TakenOn: time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{Analyte: "glucose", Name: "Глюкоза", Value: 108, Unit: "mg/dL", Flag: "high", TakenOn: time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)},
+		{Analyte: "antitela_k_tpo", Name: "Антитела к ТПО", Value: 12, Unit: "IU/mL", TakenOn: time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)},
+		{Analyte: "glucose", Name: "Глюкоза", Value: 4.2, Unit: "mmol/L", TakenOn: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)},
+	} {
+		result.ProfileID = profileID
+		if _, err := labResults.CreateLabResult(context.Background(), result); err != nil {
+			t.Fatalf("create lab result failed: %v", err)
+		}
+	}
+
+	trends, err := service.generator.fetchLabTrends(context.Background(), CreateReportRequest{ProfileID: profileID, From: "2026-02-01", To: "2026-02-15"})
+	if err != nil {
+		t.Fatalf("fetch lab trends failed: %v", err)
+	}
+	// Results older than a year before the period are left out; mg/dL is
+	// converted to the catalog unit.
+	if len(trends) != 2 || trends[0].unit != "mmol/L" || len(trends[0].points) != 2 || trends[1].name != "Антитела к ТПО" {
+		t.Fatalf("unexpected trends %+v", trends)
+	}
+	if value := trends[0].points[1].value; value < 5.99 || value > 6 {
+		t.Fatalf("expected 108 mg/dL as 5.99 mmol/L, got %v", value)
+	}
+
+	report, err := service.CreateReport(context.Background(), CreateReportRequest{
+		ProfileID: profileID,
+		From:      "2026-02-01",
+		To:        "2026-02-15",
+		Format:    FormatPDF,
+	})
+	if err != nil {
+		t.Fatalf("failed to create report: %v", err)
+	}
+	data, _, err := service.GetReportData(context.Background(), report.ID)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	if !bytes.Contains(data, []byte("/Count 2")) {
+		t.Error("expected a second page with lab trends")
+	}
+}
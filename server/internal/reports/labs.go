@@ -0,0 +1,264 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/labs"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// labHistoryDays is how long before the report period lab results are
+// charted: tests are taken months apart, so a trend needs earlier values.
+const labHistoryDays = 365
+
+// Chart geometry, mm.
+const (
+	labChartLeft   = 30.0
+	labChartWidth  = 160.0
+	labChartHeight = 35.0
+	labChartBlock  = 58.0 // title, chart and date labels
+	labPageBottom  = 280.0
+)
+
+// labTrend is the history of one analyte in a single unit.
+type labTrend struct {
+	name    string
+	unit    string
+	refLow  *float64
+	refHigh *float64
+	points  []labPoint
+}
+
+type labPoint struct {
+	date  time.Time
+	value float64
+	flag  string
+}
+
+// fetchLabTrends returns the analytes with results from labHistoryDays
+// before the period to its end.
+func (g *Generator) fetchLabTrends(ctx context.Context, req CreateReportRequest) ([]labTrend, error) {
+	if g.labResults == nil {
+		return nil, nil
+	}
+	from, err := time.Parse("2006-01-02", req.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from: %w", err)
+	}
+	to, err := time.Parse("2006-01-02", req.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to: %w", err)
+	}
+
+	results, err := g.labResults.ListLabResults(ctx, req.ProfileID, "", from.AddDate(0, 0, -labHistoryDays), to)
+	if err != nil {
+		return nil, err
+	}
+	return labTrends(results), nil
+}
+
+// labTrends groups results (oldest first) by analyte, catalog analytes in
+// catalog order and in the catalog unit, others by key in the unit of their
+// latest value. Values that do not convert are left out.
+func labTrends(results []storage.LabResult) []labTrend {
+	byAnalyte := make(map[string][]storage.LabResult)
+	var keys []string
+	for _, result := range results {
+		if _, ok := byAnalyte[result.Analyte]; !ok {
+			keys = append(keys, result.Analyte)
+		}
+		byAnalyte[result.Analyte] = append(byAnalyte[result.Analyte], result)
+	}
+
+	order := make(map[string]int)
+	for i, analyte := range labs.Catalog() {
+		order[analyte.Key] = i
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		oi, iKnown := order[keys[i]]
+		oj, jKnown := order[keys[j]]
+		if iKnown != jKnown {
+			return iKnown
+		}
+		if iKnown {
+			return oi < oj
+		}
+		return keys[i] < keys[j]
+	})
+
+	trends := make([]labTrend, 0, len(keys))
+	for _, key := range keys {
+		group := byAnalyte[key]
+		latest := group[len(group)-1]
+		trend := labTrend{name: latest.Name, unit: latest.Unit}
+		if analyte, ok := labs.Lookup(key); ok {
+			trend.name, trend.unit = analyte.Name, analyte.Unit
+		}
+		trend.refLow = convertBound(key, latest.RefLow, latest.Unit, trend.unit)
+		trend.refHigh = convertBound(key, latest.RefHigh, latest.Unit, trend.unit)
+
+		for _, result := range group {
+			value, ok := labs.Convert(key, result.Value, result.Unit, trend.unit)
+			if !ok {
+				continue
+			}
+			trend.points = append(trend.points, labPoint{date: result.TakenOn, value: value, flag: result.Flag})
+		}
+		if len(trend.points) > 0 {
+			trends = append(trends, trend)
+		}
+	}
+	return trends
+}
+
+func convertBound(key string, bound *float64, from, to string) *float64 {
+	if bound == nil {
+		return nil
+	}
+	value, ok := labs.Convert(key, *bound, from, to)
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
+// drawLabTrends adds the "Анализы" page: a line chart per analyte with the
+// reference range shaded and out-of-range values in red.
+func (g *Generator) drawLabTrends(pdf *gofpdf.Fpdf, trends []labTrend, fontName string) {
+	pdf.AddPage()
+	pdf.SetFont(fontName, "", 14)
+	pdf.Cell(0, 8, "Анализы")
+	pdf.Ln(10)
+
+	for _, trend := range trends {
+		if pdf.GetY()+labChartBlock > labPageBottom {
+			pdf.AddPage()
+		}
+		drawLabChart(pdf, trend, fontName)
+	}
+}
+
+func drawLabChart(pdf *gofpdf.Fpdf, trend labTrend, fontName string) {
+	title := trend.name
+	if trend.unit != "" {
+		title += ", " + trend.unit
+	}
+	if trend.refLow != nil || trend.refHigh != nil {
+		title += " (норма " + formatLabRange(trend.refLow, trend.refHigh) + ")"
+	}
+	pdf.SetFont(fontName, "", 10)
+	pdf.Cell(0, 6, title)
+	pdf.Ln(8)
+
+	top := pdf.GetY()
+	low, high := labScale(trend)
+	yOf := func(value float64) float64 {
+		return top + labChartHeight - (value-low)/(high-low)*labChartHeight
+	}
+	first, last := trend.points[0].date, trend.points[len(trend.points)-1].date
+	xOf := func(date time.Time) float64 {
+		if !last.After(first) {
+			return labChartLeft + labChartWidth/2
+		}
+		return labChartLeft + float64(date.Sub(first))/float64(last.Sub(first))*labChartWidth
+	}
+
+	// Reference band
+	if trend.refLow != nil || trend.refHigh != nil {
+		bandTop, bandBottom := top, top+labChartHeight
+		if trend.refHigh != nil {
+			bandTop = yOf(*trend.refHigh)
+		}
+		if trend.refLow != nil {
+			bandBottom = yOf(*trend.refLow)
+		}
+		pdf.SetFillColor(220, 240, 220)
+		pdf.Rect(labChartLeft, bandTop, labChartWidth, bandBottom-bandTop, "F")
+	}
+
+	pdf.SetDrawColor(160, 160, 160)
+	pdf.SetLineWidth(0.2)
+	pdf.Rect(labChartLeft, top, labChartWidth, labChartHeight, "D")
+
+	pdf.SetFont(fontName, "", 7)
+	pdf.SetTextColor(90, 90, 90)
+	pdf.Text(labChartLeft-18, top+2, formatLabValue(high))
+	pdf.Text(labChartLeft-18, top+labChartHeight, formatLabValue(low))
+	pdf.Text(labChartLeft, top+labChartHeight+4, first.Format("02.01.2006"))
+	if last.After(first) {
+		pdf.Text(labChartLeft+labChartWidth-14, top+labChartHeight+4, last.Format("02.01.2006"))
+	}
+
+	pdf.SetDrawColor(60, 90, 160)
+	pdf.SetLineWidth(0.5)
+	for i := 1; i < len(trend.points); i++ {
+		prev, point := trend.points[i-1], trend.points[i]
+		pdf.Line(xOf(prev.date), yOf(prev.value), xOf(point.date), yOf(point.value))
+	}
+	for _, point := range trend.points {
+		if point.flag == labs.FlagLow || point.flag == labs.FlagHigh {
+			pdf.SetFillColor(200, 40, 40)
+		} else {
+			pdf.SetFillColor(60, 90, 160)
+		}
+		pdf.Circle(xOf(point.date), yOf(point.value), 1.2, "F")
+	}
+
+	// Latest value next to its point
+	latest := trend.points[len(trend.points)-1]
+	pdf.SetTextColor(0, 0, 0)
+	pdf.Text(labChartLeft+labChartWidth+2, yOf(latest.value)+1, formatLabValue(latest.value))
+
+	pdf.SetLineWidth(0.2)
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetY(top + labChartHeight + 9)
+}
+
+// labScale returns the value range of a chart: the values and the
+// reference range with a margin.
+func labScale(trend labTrend) (float64, float64) {
+	low, high := trend.points[0].value, trend.points[0].value
+	extend := func(value float64) {
+		if value < low {
+			low = value
+		}
+		if value > high {
+			high = value
+		}
+	}
+	for _, point := range trend.points {
+		extend(point.value)
+	}
+	if trend.refLow != nil {
+		extend(*trend.refLow)
+	}
+	if trend.refHigh != nil {
+		extend(*trend.refHigh)
+	}
+	if high == low {
+		return low - 1, high + 1
+	}
+	margin := (high - low) * 0.1
+	return low - margin, high + margin
+}
+
+func formatLabRange(low, high *float64) string {
+	switch {
+	case low != nil && high != nil:
+		return formatLabValue(*low) + "–" + formatLabValue(*high)
+	case low != nil:
+		return "от " + formatLabValue(*low)
+	default:
+		return "до " + formatLabValue(*high)
+	}
+}
+
+func formatLabValue(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
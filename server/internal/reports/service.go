@@ -6,10 +6,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/fdg312/health-hub/internal/audit"
 	"github.com/fdg312/health-hub/internal/blob"
 	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/telemetry"
 	"github.com/fdg312/health-hub/internal/userctx"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Service handles reports business logic
@@ -25,6 +28,8 @@ type Service struct {
 	localMode       bool   // true if no S3 configured
 	publicBaseURL   string // S3 public base URL (if prefer_public_url mode)
 	preferPublicURL bool   // if true, use public URLs instead of presigned
+	audit           audit.Recorder
+	metrics         *telemetry.Metrics
 }
 
 // NewService creates a new reports service
@@ -60,6 +65,13 @@ func NewService(
 
 // CreateReport creates a new report
 func (s *Service) CreateReport(ctx context.Context, req CreateReportRequest) (*Report, error) {
+	ctx, span := telemetry.StartSpan(ctx, "reports.CreateReport", attribute.String("report.format", req.Format))
+	report, err := s.createReport(ctx, req)
+	telemetry.EndSpan(span, err)
+	return report, err
+}
+
+func (s *Service) createReport(ctx context.Context, req CreateReportRequest) (*Report, error) {
 	// Validate format
 	if req.Format != FormatPDF && req.Format != FormatCSV {
 		return nil, ErrInvalidFormat
@@ -90,6 +102,25 @@ func (s *Service) CreateReport(ctx context.Context, req CreateReportRequest) (*R
 		return nil, ErrProfileNotFound
 	}
 
+	start := time.Now()
+	report, err := s.renderReport(ctx, req)
+	s.metrics.ObserveReport(req.Format, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	// Save metadata
+	if err := s.reportsStorage.CreateReport(ctx, report); err != nil {
+		return nil, fmt.Errorf("failed to save report metadata: %w", err)
+	}
+
+	// Convert to Report model
+	return s.toReport(report), nil
+}
+
+// renderReport generates the file and uploads it (or keeps it inline in
+// local mode).
+func (s *Service) renderReport(ctx context.Context, req CreateReportRequest) (*storage.ReportMeta, error) {
 	// Generate report
 	data, err := s.generator.GenerateReport(ctx, req)
 	if err != nil {
@@ -133,13 +164,7 @@ func (s *Service) CreateReport(ctx context.Context, req CreateReportRequest) (*R
 		report.ObjectKey = &objectKey
 	}
 
-	// Save metadata
-	if err := s.reportsStorage.CreateReport(ctx, report); err != nil {
-		return nil, fmt.Errorf("failed to save report metadata: %w", err)
-	}
-
-	// Convert to Report model
-	return s.toReport(report), nil
+	return report, nil
 }
 
 // GetReport retrieves a report by ID
@@ -201,6 +226,38 @@ func (s *Service) DeleteReport(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
+// WithAuditRecorder enables audit events for report downloads.
+func (s *Service) WithAuditRecorder(recorder audit.Recorder) *Service {
+	s.audit = recorder
+	return s
+}
+
+// WithLabResults adds lab result trend charts to PDF reports.
+func (s *Service) WithLabResults(labResults storage.LabResultsStorage) *Service {
+	s.generator.labResults = labResults
+	return s
+}
+
+// WithTelemetry enables report generation metrics.
+func (s *Service) WithTelemetry(m *telemetry.Metrics) *Service {
+	s.metrics = m
+	return s
+}
+
+// recordDownload appends a report.download audit event.
+func (s *Service) recordDownload(ctx context.Context, report *Report) {
+	if s.audit == nil {
+		return
+	}
+	profileID := report.ProfileID
+	s.audit.Record(ctx, audit.Event{
+		ProfileID:    &profileID,
+		Action:       audit.ActionReportDownload,
+		ResourceType: "reports",
+		ResourceID:   report.ID.String(),
+	})
+}
+
 // GetReportDownloadURL generates a download URL for a report
 func (s *Service) GetReportDownloadURL(ctx context.Context, id uuid.UUID, baseURL string) (string, error) {
 	meta, err := s.reportsStorage.GetReport(ctx, id)
@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 type Handlers struct {
@@ -31,7 +33,7 @@ func (h *Handlers) HandleList(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.service.List(r.Context(), profileID)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -46,7 +48,7 @@ func (h *Handlers) HandleUpsert(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.service.Upsert(r.Context(), req)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -61,7 +63,7 @@ func (h *Handlers) HandleReplaceAll(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.service.ReplaceAll(r.Context(), req)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -76,13 +78,13 @@ func (h *Handlers) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.service.Delete(r.Context(), scheduleID); err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *Handlers) handleError(w http.ResponseWriter, err error) {
+func (h *Handlers) handleError(w http.ResponseWriter, r *http.Request, err error) {
 	switch {
 	case errors.Is(err, ErrInvalidRequest):
 		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
@@ -97,6 +99,7 @@ func (h *Handlers) handleError(w http.ResponseWriter, err error) {
 	case errors.Is(err, ErrScheduleNotFound):
 		writeError(w, http.StatusNotFound, "schedule_not_found", "Schedule not found")
 	default:
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
 	}
 }
@@ -109,6 +112,6 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 
 func writeError(w http.ResponseWriter, status int, code, message string) {
 	writeJSON(w, status, ErrorResponse{
-		Error: ErrorDetail{Code: code, Message: message},
+		Error: ErrorDetail{Code: code, Message: message, RequestID: logging.ResponseRequestID(w)},
 	})
 }
@@ -0,0 +1,83 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// HandleSearch handles GET /v1/search?profile_id=&q=&types=&from=&to=&limit=&offset=
+func (h *Handler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	profileID, err := uuid.Parse(strings.TrimSpace(query.Get("profile_id")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "profile_id is required")
+		return
+	}
+	params := Params{
+		ProfileID: profileID,
+		Query:     query.Get("q"),
+		Types:     query.Get("types"),
+		From:      query.Get("from"),
+		To:        query.Get("to"),
+	}
+	for name, target := range map[string]*int{"limit": &params.Limit, "offset": &params.Offset} {
+		raw := strings.TrimSpace(query.Get(name))
+		if raw == "" {
+			continue
+		}
+		if *target, err = strconv.Atoi(raw); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", name+" must be a number")
+			return
+		}
+	}
+
+	resp, err := h.service.Search(r.Context(), params)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+	case errors.Is(err, ErrProfileNotFound):
+		writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
+	default:
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{
+		Error: ErrorDetail{
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
+		},
+	})
+}
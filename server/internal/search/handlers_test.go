@@ -0,0 +1,173 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/checkins"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/storage/memory"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+func TestSearchFindsNotesCheckinsAndChat(t *testing.T) {
+	handler, mem, profileID := setupSearchHandler(t)
+	ctx := context.Background()
+
+	title := "Дневник"
+	text := "Sunday: strong headache after coffee at breakfast, went away by noon"
+	source := &storage.Source{ProfileID: profileID, Kind: "note", Title: &title, Text: &text}
+	if err := mem.GetSourcesStorage().CreateSource(ctx, source); err != nil {
+		t.Fatalf("create source failed: %v", err)
+	}
+	other := "Bought more coffee beans"
+	if err := mem.GetSourcesStorage().CreateSource(ctx, &storage.Source{ProfileID: profileID, Kind: "note", Text: &other}); err != nil {
+		t.Fatalf("create source failed: %v", err)
+	}
+
+	checkin := &checkins.Checkin{
+		ID: uuid.New(), ProfileID: profileID, Date: "2026-03-10", Type: "evening", Score: 2,
+		Tags: []string{"headache", "coffee"}, Note: "Голова болела весь вечер",
+	}
+	if err := mem.GetCheckinsStorage().UpsertCheckin(checkin); err != nil {
+		t.Fatalf("upsert checkin failed: %v", err)
+	}
+
+	thread, err := mem.GetChatStorage().CreateThread(ctx, "userA", profileID, "")
+	if err != nil {
+		t.Fatalf("create thread failed: %v", err)
+	}
+	msg, err := mem.GetChatStorage().InsertMessage(ctx, "userA", profileID, thread.ID, "user", "Why do I get a headache after my morning coffee?")
+	if err != nil {
+		t.Fatalf("insert message failed: %v", err)
+	}
+	// Chat messages are searched only for the profile owner.
+	foreignThread, err := mem.GetChatStorage().CreateThread(ctx, "userB", profileID, "")
+	if err != nil {
+		t.Fatalf("create thread failed: %v", err)
+	}
+	if _, err := mem.GetChatStorage().InsertMessage(ctx, "userB", profileID, foreignThread.ID, "user", "My headache after coffee"); err != nil {
+		t.Fatalf("insert message failed: %v", err)
+	}
+
+	resp := search(t, handler, "userA", "profile_id="+profileID.String()+"&q=headache+coffee")
+	if len(resp.Results) != 3 {
+		t.Fatalf("expected the note, the checkin and the message, got %+v", resp.Results)
+	}
+	found := make(map[string]ResultDTO)
+	for _, result := range resp.Results {
+		found[result.Type] = result
+		if result.Rank <= 0 {
+			t.Fatalf("expected a positive rank, got %+v", result)
+		}
+	}
+	if got := found[storage.SearchTypeSource]; got.ID != source.ID || got.Title == nil || *got.Title != title ||
+		!strings.Contains(got.Snippet, "<mark>headache</mark> after <mark>coffee</mark>") {
+		t.Fatalf("unexpected source hit %+v", got)
+	}
+	if got := found[storage.SearchTypeCheckin]; got.ID != checkin.ID || got.Kind != "evening" ||
+		!got.Date.Equal(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected checkin hit %+v", got)
+	}
+	if got := found[storage.SearchTypeChatMessage]; got.ID != msg.ID || got.ThreadID == nil || *got.ThreadID != thread.ID {
+		t.Fatalf("unexpected chat hit %+v", got)
+	}
+
+	// Russian words match other forms of the word, all terms are required.
+	resp = search(t, handler, "userA", "profile_id="+profileID.String()+"&q=голова+утром")
+	if len(resp.Results) != 0 {
+		t.Fatalf("expected no match without every term, got %+v", resp.Results)
+	}
+	resp = search(t, handler, "userA", "profile_id="+profileID.String()+"&q=голову")
+	if len(resp.Results) != 1 || resp.Results[0].ID != checkin.ID {
+		t.Fatalf("expected the checkin for another form of the word, got %+v", resp.Results)
+	}
+
+	// Type and date filters, exclusion.
+	resp = search(t, handler, "userA", "profile_id="+profileID.String()+"&q=coffee&types=checkin,chat_message&from=2026-03-01&to=2026-03-31")
+	if len(resp.Results) != 1 || resp.Results[0].ID != checkin.ID {
+		t.Fatalf("expected only the checkin, got %+v", resp.Results)
+	}
+	resp = search(t, handler, "userA", "profile_id="+profileID.String()+"&q=coffee+-headache&types=source")
+	if len(resp.Results) != 1 || resp.Results[0].Snippet != "Bought more <mark>coffee</mark> beans" {
+		t.Fatalf("expected the other note only, got %+v", resp.Results)
+	}
+	resp = search(t, handler, "userA", "profile_id="+profileID.String()+"&q=coffee&limit=1&offset=1")
+	if len(resp.Results) != 1 || resp.Limit != 1 || resp.Offset != 1 {
+		t.Fatalf("expected the second page of one, got %+v", resp)
+	}
+
+	// Deleted documents leave the index.
+	if err := mem.GetSourcesStorage().DeleteSource(ctx, source.ID); err != nil {
+		t.Fatalf("delete source failed: %v", err)
+	}
+	resp = search(t, handler, "userA", "profile_id="+profileID.String()+"&q=headache&types=source")
+	if len(resp.Results) != 0 {
+		t.Fatalf("expected the deleted note gone, got %+v", resp.Results)
+	}
+}
+
+func TestSearchValidatesAndChecksAccess(t *testing.T) {
+	handler, _, profileID := setupSearchHandler(t)
+
+	for _, query := range []string{
+		"q=coffee",
+		"profile_id=" + profileID.String(),
+		"profile_id=" + profileID.String() + "&q=coffee&types=labs",
+		"profile_id=" + profileID.String() + "&q=coffee&from=10.03.2026",
+		"profile_id=" + profileID.String() + "&q=coffee&limit=500",
+		"profile_id=" + profileID.String() + "&q=coffee&offset=x",
+	} {
+		if w := serveSearch(handler, "userA", query); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 for %q, got %d body=%s", query, w.Code, w.Body.String())
+		}
+	}
+
+	if w := serveSearch(handler, "userB", "profile_id="+profileID.String()+"&q=coffee"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for another owner, got %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func setupSearchHandler(t *testing.T) (*Handler, *memory.MemoryStorage, uuid.UUID) {
+	t.Helper()
+
+	mem := memory.New()
+	profileID := uuid.New()
+	for _, profile := range []storage.Profile{
+		{ID: profileID, OwnerUserID: "userA", Type: "owner", Name: "User A"},
+		{ID: uuid.New(), OwnerUserID: "userB", Type: "owner", Name: "User B"},
+	} {
+		if err := mem.CreateProfile(context.Background(), &profile); err != nil {
+			t.Fatalf("create profile failed: %v", err)
+		}
+	}
+	return NewHandler(NewService(mem.GetSearchStorage(), mem)), mem, profileID
+}
+
+func search(t *testing.T, handler *Handler, userID, query string) SearchResponse {
+	t.Helper()
+
+	w := serveSearch(handler, userID, query)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var resp SearchResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	return resp
+}
+
+func serveSearch(handler *Handler, userID, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/v1/search?"+query, nil)
+	req = req.WithContext(userctx.WithUserID(context.Background(), userID))
+	w := httptest.NewRecorder()
+	handler.HandleSearch(w, req)
+	return w
+}
@@ -0,0 +1,39 @@
+package search
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ResultDTO is one found document. Kind is the source kind, the checkin
+// type or the author role of a chat message; ThreadID is set for chat
+// messages only. Snippet marks matches with <mark>…</mark>.
+type ResultDTO struct {
+	Type     string     `json:"type"`
+	ID       uuid.UUID  `json:"id"`
+	Kind     string     `json:"kind"`
+	ThreadID *uuid.UUID `json:"thread_id"`
+	Title    *string    `json:"title"`
+	Snippet  string     `json:"snippet"`
+	Rank     float64    `json:"rank"`
+	Date     time.Time  `json:"date"`
+}
+
+type SearchResponse struct {
+	Query   string      `json:"query"`
+	Results []ResultDTO `json:"results"`
+	Limit   int         `json:"limit"`
+	Offset  int         `json:"offset"`
+}
+
+// ErrorResponse — стандартный формат ошибки.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
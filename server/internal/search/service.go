@@ -0,0 +1,156 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRequest  = errors.New("invalid request")
+	ErrProfileNotFound = errors.New("profile not found")
+)
+
+const (
+	maxQueryLength = 200
+	defaultLimit   = 20
+	maxLimit       = 100
+)
+
+var types = []string{storage.SearchTypeSource, storage.SearchTypeCheckin, storage.SearchTypeChatMessage}
+
+type profileReader interface {
+	GetProfile(ctx context.Context, id uuid.UUID) (*storage.Profile, error)
+}
+
+type Service struct {
+	storage        storage.SearchStorage
+	profileStorage profileReader
+}
+
+func NewService(searchStorage storage.SearchStorage, profileStorage profileReader) *Service {
+	return &Service{storage: searchStorage, profileStorage: profileStorage}
+}
+
+// Params are the query parameters of GET /v1/search. Types is a comma
+// separated list; from/to are YYYY-MM-DD days, inclusive.
+type Params struct {
+	ProfileID uuid.UUID
+	Query     string
+	Types     string
+	From      string
+	To        string
+	Limit     int
+	Offset    int
+}
+
+// Search finds sources, checkins and chat messages of a profile matching
+// the query, best first.
+func (s *Service) Search(ctx context.Context, params Params) (*SearchResponse, error) {
+	profile, err := s.ensureProfileAccess(ctx, params.ProfileID)
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimSpace(params.Query)
+	if text == "" {
+		return nil, fmt.Errorf("%w: q is required", ErrInvalidRequest)
+	}
+	if utf8.RuneCountInString(text) > maxQueryLength {
+		return nil, fmt.Errorf("%w: q is longer than %d characters", ErrInvalidRequest, maxQueryLength)
+	}
+
+	query := storage.SearchQuery{
+		ProfileID:   params.ProfileID,
+		OwnerUserID: profile.OwnerUserID,
+		Text:        text,
+		Limit:       params.Limit,
+		Offset:      params.Offset,
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultLimit
+	}
+	if query.Limit < 0 || query.Limit > maxLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidRequest, maxLimit)
+	}
+	if query.Offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative", ErrInvalidRequest)
+	}
+
+	for _, docType := range strings.Split(params.Types, ",") {
+		docType = strings.TrimSpace(docType)
+		if docType == "" {
+			continue
+		}
+		if !slices.Contains(types, docType) {
+			return nil, fmt.Errorf("%w: unknown type %q, expected one of %s", ErrInvalidRequest, docType, strings.Join(types, ", "))
+		}
+		if !slices.Contains(query.Types, docType) {
+			query.Types = append(query.Types, docType)
+		}
+	}
+
+	if query.From, err = parseOptionalDate(params.From, "from"); err != nil {
+		return nil, err
+	}
+	if query.To, err = parseOptionalDate(params.To, "to"); err != nil {
+		return nil, err
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && query.To.Before(query.From) {
+		return nil, fmt.Errorf("%w: to is before from", ErrInvalidRequest)
+	}
+
+	hits, err := s.storage.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &SearchResponse{Query: text, Results: make([]ResultDTO, 0, len(hits)), Limit: query.Limit, Offset: query.Offset}
+	for _, hit := range hits {
+		resp.Results = append(resp.Results, ResultDTO{
+			Type:     hit.Type,
+			ID:       hit.ID,
+			Kind:     hit.Kind,
+			ThreadID: hit.ThreadID,
+			Title:    hit.Title,
+			Snippet:  hit.Snippet,
+			Rank:     math.Round(hit.Rank*10000) / 10000,
+			Date:     hit.Date,
+		})
+	}
+	return resp, nil
+}
+
+func (s *Service) ensureProfileAccess(ctx context.Context, profileID uuid.UUID) (*storage.Profile, error) {
+	profile, err := s.profileStorage.GetProfile(ctx, profileID)
+	if err != nil {
+		return nil, ErrProfileNotFound
+	}
+
+	if userID, ok := userctx.GetUserID(ctx); ok && strings.TrimSpace(userID) != "" && profile.OwnerUserID != userID {
+		return nil, ErrProfileNotFound
+	}
+
+	return profile, nil
+}
+
+func parseOptionalDate(value, field string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid %s", ErrInvalidRequest, field)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,320 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/audioprobe"
+	"github.com/fdg312/health-hub/internal/speech"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Transcription statuses.
+const (
+	TranscriptionPending = "pending"
+	TranscriptionReady   = "ready"
+	TranscriptionFailed  = "failed"
+)
+
+// defaultMaxAudioDuration bounds voice notes unless WithMaxAudioDuration
+// says otherwise.
+const defaultMaxAudioDuration = 10 * time.Minute
+
+// transcribeBatch bounds how many pending transcriptions one query returns.
+const transcribeBatch = 10
+
+// consentChecker is satisfied by the AI consent service.
+type consentChecker interface {
+	HasAIConsent(ctx context.Context, ownerUserID string) (bool, error)
+}
+
+// WithMaxAudioDuration refuses voice notes longer than d.
+func (s *Service) WithMaxAudioDuration(d time.Duration) *Service {
+	if d > 0 {
+		s.maxAudioDuration = d
+	}
+	return s
+}
+
+// WithTranscriber makes new voice notes get transcribed in the background;
+// the transcript becomes the text of the source.
+func (s *Service) WithTranscriber(transcriber speech.Transcriber) *Service {
+	s.transcriber = transcriber
+	s.transcribeQueue = make(chan struct{}, 1)
+	return s
+}
+
+// WithTranscriptionConsent holds transcription until the profile owner has
+// agreed to AI processing. Only set it for engines that send audio out.
+func (s *Service) WithTranscriptionConsent(checker consentChecker) *Service {
+	s.transcribeConsent = checker
+	return s
+}
+
+// CreateAudioSource stores a voice note and queues its transcription.
+func (s *Service) CreateAudioSource(ctx context.Context, profileID uuid.UUID, checkinID *uuid.UUID, title *string, fileHeader *multipart.FileHeader) (*SourceDTO, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, ErrProfileNotFound
+	}
+
+	maxBytes := int64(s.maxUploadMB) * 1024 * 1024
+	if fileHeader.Size > maxBytes {
+		return nil, ErrFileTooLarge
+	}
+
+	// Recorders add parameters ("audio/ogg; codecs=opus").
+	contentType, _, _ := strings.Cut(fileHeader.Header.Get("Content-Type"), ";")
+	contentType = strings.TrimSpace(contentType)
+	if !s.isAllowedMime(KindAudio, contentType) {
+		return nil, ErrUnsupportedMime
+	}
+
+	if err := s.checkSourcesLimit(ctx, profileID, checkinID); err != nil {
+		return nil, err
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	duration, err := audioprobe.Duration(data, contentType)
+	if errors.Is(err, audioprobe.ErrUnsupportedType) {
+		return nil, ErrUnsupportedMime
+	}
+	if err != nil {
+		return nil, ErrInvalidAudio
+	}
+	if duration > s.maxAudioDuration {
+		return nil, ErrAudioTooLong
+	}
+
+	contentType = audioprobe.Normalize(contentType)
+	durationMS := duration.Milliseconds()
+	source := &storage.Source{
+		ID:          uuid.New(),
+		ProfileID:   profileID,
+		Kind:        KindAudio,
+		Title:       title,
+		CheckinID:   checkinID,
+		ContentType: &contentType,
+		SizeBytes:   int64(len(data)),
+		DurationMS:  &durationMS,
+	}
+	if s.transcriber != nil {
+		source.Transcription = &storage.Transcription{Status: TranscriptionPending}
+	}
+
+	if s.localMode {
+		if err := s.sourcesStorage.CreateSource(ctx, source); err != nil {
+			return nil, err
+		}
+		if err := s.sourcesStorage.PutSourceBlob(ctx, source.ID, data, contentType); err != nil {
+			_ = s.sourcesStorage.DeleteSource(ctx, source.ID)
+			return nil, fmt.Errorf("failed to store blob: %w", err)
+		}
+	} else {
+		objectKey := sourceObjectKey(source.ProfileID, source.ID)
+		if _, err := s.blobStore.PutObject(ctx, objectKey, data, contentType); err != nil {
+			return nil, fmt.Errorf("failed to upload to S3: %w", err)
+		}
+		source.ObjectKey = &objectKey
+		if err := s.sourcesStorage.CreateSource(ctx, source); err != nil {
+			_ = s.blobStore.DeleteObject(ctx, objectKey)
+			return nil, err
+		}
+	}
+
+	if source.Transcription != nil {
+		s.wakeTranscriptions()
+	}
+	return s.toDTO(source), nil
+}
+
+// wakeTranscriptions tells RunTranscriptions there is work without waiting
+// for its next tick.
+func (s *Service) wakeTranscriptions() {
+	select {
+	case s.transcribeQueue <- struct{}{}:
+	default:
+	}
+}
+
+// RunTranscriptions transcribes voice notes as they are uploaded, and every
+// interval in case a wake-up was missed, until ctx is cancelled.
+func (s *Service) RunTranscriptions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if done, err := s.TranscribePending(ctx); err != nil {
+			slog.Error("sources: transcription failed", "error", err)
+		} else if done > 0 {
+			slog.Info("sources: transcribed voice notes", "notes", done)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.transcribeQueue:
+		}
+	}
+}
+
+// TranscribePending transcribes every pending voice note and returns how
+// many were processed. A recording the engine cannot transcribe is marked
+// failed; only storage errors are returned.
+func (s *Service) TranscribePending(ctx context.Context) (int, error) {
+	if s.transcriber == nil {
+		return 0, nil
+	}
+
+	done := 0
+	for {
+		pending, err := s.sourcesStorage.ListPendingTranscriptions(ctx, transcribeBatch)
+		if err != nil {
+			return done, err
+		}
+		for _, source := range pending {
+			if err := s.transcribe(ctx, source); err != nil {
+				return done, err
+			}
+			done++
+		}
+		if len(pending) < transcribeBatch || ctx.Err() != nil {
+			return done, nil
+		}
+	}
+}
+
+// transcribe runs the engine on one voice note and stores the transcript as
+// the text of the source.
+func (s *Service) transcribe(ctx context.Context, source storage.Source) error {
+	if err := s.checkTranscriptionConsent(ctx, source.ProfileID); err != nil {
+		if errors.Is(err, ErrConsentRequired) {
+			return s.failTranscription(ctx, source.ID, err, "ai consent required")
+		}
+		return err
+	}
+
+	data, err := s.audioData(ctx, &source)
+	if err != nil {
+		return s.failTranscription(ctx, source.ID, err, "recording not found")
+	}
+	contentType := ""
+	if source.ContentType != nil {
+		contentType = *source.ContentType
+	}
+
+	transcript, err := s.transcriber.Transcribe(ctx, speech.Audio{Data: data, ContentType: contentType})
+	if err != nil {
+		return s.failTranscription(ctx, source.ID, err, "transcription failed")
+	}
+
+	transcribedAt := s.now().UTC()
+	_, err = s.sourcesStorage.SaveTranscript(ctx, source.ID, optionalString(transcript.Text), storage.Transcription{
+		Status:        TranscriptionReady,
+		Language:      optionalString(transcript.Language),
+		TranscribedAt: &transcribedAt,
+	})
+	return err
+}
+
+// failTranscription marks the transcription failed with a message safe to
+// show; the transcript from an earlier run is kept.
+func (s *Service) failTranscription(ctx context.Context, id uuid.UUID, cause error, message string) error {
+	slog.Warn("sources: transcription failed", "source_id", id, "error", cause)
+	_, err := s.sourcesStorage.SetTranscriptionStatus(ctx, id, TranscriptionFailed, &message)
+	return err
+}
+
+// audioData reads a stored recording.
+func (s *Service) audioData(ctx context.Context, source *storage.Source) ([]byte, error) {
+	if s.localMode {
+		data, _, err := s.sourcesStorage.GetSourceBlob(ctx, source.ID)
+		return data, err
+	}
+	if source.ObjectKey == nil {
+		return nil, errors.New("object key not found")
+	}
+	return s.blobStore.GetObject(ctx, *source.ObjectKey)
+}
+
+// checkTranscriptionConsent returns ErrConsentRequired while the profile
+// owner has not agreed to AI processing, when consent is required.
+func (s *Service) checkTranscriptionConsent(ctx context.Context, profileID uuid.UUID) error {
+	if s.transcribeConsent == nil {
+		return nil
+	}
+	profile, err := s.profileStorage.GetProfile(ctx, profileID)
+	if err != nil {
+		return err
+	}
+	granted, err := s.transcribeConsent.HasAIConsent(ctx, profile.OwnerUserID)
+	if err != nil {
+		return err
+	}
+	if !granted {
+		return ErrConsentRequired
+	}
+	return nil
+}
+
+// Retranscribe queues a voice note to be transcribed again, e.g. after a
+// failure or once the owner has consented to AI processing.
+func (s *Service) Retranscribe(ctx context.Context, id uuid.UUID) (*SourceDTO, error) {
+	source, err := s.GetSource(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if source.Kind != KindAudio {
+		return nil, ErrNotAudio
+	}
+	if s.transcriber == nil {
+		return nil, ErrTranscriptionDisabled
+	}
+	if err := s.checkTranscriptionConsent(ctx, source.ProfileID); err != nil {
+		return nil, err
+	}
+
+	ok, err := s.sourcesStorage.SetTranscriptionStatus(ctx, id, TranscriptionPending, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSourceNotFound
+	}
+	s.wakeTranscriptions()
+
+	if source.Transcription == nil {
+		source.Transcription = &storage.Transcription{}
+	}
+	source.Transcription.Status, source.Transcription.Error = TranscriptionPending, nil
+	return s.toDTO(source), nil
+}
+
+func transcriptionDTO(transcription *storage.Transcription) *TranscriptionDTO {
+	if transcription == nil {
+		return nil
+	}
+	return &TranscriptionDTO{
+		Status:        transcription.Status,
+		Language:      transcription.Language,
+		Error:         transcription.Error,
+		TranscribedAt: transcription.TranscribedAt,
+	}
+}
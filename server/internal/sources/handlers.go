@@ -3,11 +3,15 @@ package sources
 import (
 	"encoding/json"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"path/filepath"
 	"strconv"
 
 	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/imageproc"
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 // Handlers handles HTTP requests for sources
@@ -40,6 +44,7 @@ func (h *Handlers) HandleCreate(w http.ResponseWriter, r *http.Request) {
 		case ErrMissingText:
 			writeError(w, http.StatusBadRequest, "missing_text", "Text is required for note")
 		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		}
 		return
@@ -52,63 +57,189 @@ func (h *Handlers) HandleCreate(w http.ResponseWriter, r *http.Request) {
 
 // HandleCreateImage handles POST /v1/sources/image (multipart upload)
 func (h *Handlers) HandleCreateImage(w http.ResponseWriter, r *http.Request) {
+	form, ok := parseUploadForm(w, r)
+	if !ok {
+		return
+	}
+
+	// Create image source
+	dto, err := h.service.CreateImageSource(r.Context(), form.profileID, form.checkinID, form.title, form.file)
+	if err != nil {
+		switch err {
+		case ErrInvalidImage:
+			writeError(w, http.StatusBadRequest, "invalid_image", "File is not a valid image")
+		default:
+			h.writeUploadError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto)
+}
+
+// HandleCreateAudio handles POST /v1/sources/audio (multipart upload)
+func (h *Handlers) HandleCreateAudio(w http.ResponseWriter, r *http.Request) {
+	form, ok := parseUploadForm(w, r)
+	if !ok {
+		return
+	}
+
+	dto, err := h.service.CreateAudioSource(r.Context(), form.profileID, form.checkinID, form.title, form.file)
+	if err != nil {
+		switch err {
+		case ErrInvalidAudio:
+			writeError(w, http.StatusBadRequest, "invalid_audio", "File is not a valid recording")
+		case ErrAudioTooLong:
+			writeError(w, http.StatusBadRequest, "audio_too_long", fmt.Sprintf("Recording exceeds %d seconds", int(h.service.maxAudioDuration.Seconds())))
+		default:
+			h.writeUploadError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto)
+}
+
+// uploadForm is the multipart form of image and audio uploads.
+type uploadForm struct {
+	profileID uuid.UUID
+	checkinID *uuid.UUID
+	title     *string
+	file      *multipart.FileHeader
+}
+
+// parseUploadForm reads an upload form, writing the error response when it
+// is invalid.
+func parseUploadForm(w http.ResponseWriter, r *http.Request) (uploadForm, bool) {
 	// Parse multipart form (max 32 MB in memory)
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid_request", "Failed to parse multipart form")
-		return
+		return uploadForm{}, false
 	}
 
 	// Get profile_id
 	profileIDStr := r.FormValue("profile_id")
 	if profileIDStr == "" {
 		writeError(w, http.StatusBadRequest, "missing_profile_id", "profile_id is required")
-		return
+		return uploadForm{}, false
 	}
 
-	profileID, err := uuid.Parse(profileIDStr)
+	var form uploadForm
+	var err error
+	form.profileID, err = uuid.Parse(profileIDStr)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "invalid_profile_id", "Invalid profile_id format")
-		return
+		return uploadForm{}, false
 	}
 
 	// Get optional checkin_id
-	var checkinID *uuid.UUID
 	if checkinIDStr := r.FormValue("checkin_id"); checkinIDStr != "" {
 		cid, err := uuid.Parse(checkinIDStr)
 		if err != nil {
 			writeError(w, http.StatusBadRequest, "invalid_checkin_id", "Invalid checkin_id format")
-			return
+			return uploadForm{}, false
 		}
-		checkinID = &cid
+		form.checkinID = &cid
 	}
 
 	// Get optional title
-	var title *string
 	if t := r.FormValue("title"); t != "" {
-		title = &t
+		form.title = &t
 	}
 
 	// Get file
 	file, fileHeader, err := r.FormFile("file")
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "missing_file", "File is required")
-		return
+		return uploadForm{}, false
 	}
 	file.Close() // Close immediately, service will reopen
+	form.file = fileHeader
 
-	// Create image source
-	dto, err := h.service.CreateImageSource(r.Context(), profileID, checkinID, title, fileHeader)
+	return form, true
+}
+
+// writeUploadError writes the errors image and audio uploads share.
+func (h *Handlers) writeUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case ErrProfileNotFound:
+		writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
+	case ErrFileTooLarge:
+		writeError(w, http.StatusBadRequest, "file_too_large", fmt.Sprintf("File exceeds maximum size of %d MB", h.service.maxUploadMB))
+	case ErrUnsupportedMime:
+		writeError(w, http.StatusBadRequest, "unsupported_mime", "File type not supported")
+	case ErrMaxSourcesExceeded:
+		writeError(w, http.StatusBadRequest, "max_sources_exceeded", fmt.Sprintf("Maximum %d sources per checkin", h.service.maxSourcesPerCheck))
+	default:
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+	}
+}
+
+// HandleCreateUploadURL handles POST /v1/sources/image/upload-url
+func (h *Handlers) HandleCreateUploadURL(w http.ResponseWriter, r *http.Request) {
+	var req CreateUploadURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON")
+		return
+	}
+
+	resp, err := h.service.CreateUploadURL(r.Context(), req)
 	if err != nil {
 		switch err {
 		case ErrProfileNotFound:
 			writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
+		case ErrInvalidSize:
+			writeError(w, http.StatusBadRequest, "invalid_size", "size_bytes must be positive")
 		case ErrFileTooLarge:
 			writeError(w, http.StatusBadRequest, "file_too_large", fmt.Sprintf("File exceeds maximum size of %d MB", h.service.maxUploadMB))
 		case ErrUnsupportedMime:
 			writeError(w, http.StatusBadRequest, "unsupported_mime", "File type not supported")
 		case ErrMaxSourcesExceeded:
 			writeError(w, http.StatusBadRequest, "max_sources_exceeded", fmt.Sprintf("Maximum %d sources per checkin", h.service.maxSourcesPerCheck))
+		case ErrDirectUploadUnavailable:
+			writeError(w, http.StatusConflict, "direct_upload_unavailable", "Direct upload requires S3, use POST /v1/sources/image")
 		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleCompleteUpload handles POST /v1/sources/image/complete
+func (h *Handlers) HandleCompleteUpload(w http.ResponseWriter, r *http.Request) {
+	var req CompleteUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UploadID == uuid.Nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "upload_id is required")
+		return
+	}
+
+	dto, err := h.service.CompleteUpload(r.Context(), req.UploadID)
+	if err != nil {
+		switch err {
+		case ErrUploadNotFound:
+			writeError(w, http.StatusNotFound, "upload_not_found", "Upload not found")
+		case ErrUploadIncomplete:
+			writeError(w, http.StatusConflict, "upload_incomplete", "File has not been uploaded yet")
+		case ErrUploadMismatch:
+			writeError(w, http.StatusBadRequest, "upload_mismatch", "Uploaded file does not match the declared size or type")
+		case ErrInvalidImage:
+			writeError(w, http.StatusBadRequest, "invalid_image", "File is not a valid image")
+		case ErrMaxSourcesExceeded:
+			writeError(w, http.StatusBadRequest, "max_sources_exceeded", fmt.Sprintf("Maximum %d sources per checkin", h.service.maxSourcesPerCheck))
+		case ErrDirectUploadUnavailable:
+			writeError(w, http.StatusConflict, "direct_upload_unavailable", "Direct upload requires S3, use POST /v1/sources/image")
+		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		}
 		return
@@ -167,6 +298,7 @@ func (h *Handlers) HandleList(w http.ResponseWriter, r *http.Request) {
 		if err == ErrProfileNotFound {
 			writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
 		} else {
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		}
 		return
@@ -191,6 +323,7 @@ func (h *Handlers) HandleDownload(w http.ResponseWriter, r *http.Request) {
 		if err == ErrSourceNotFound {
 			writeError(w, http.StatusNotFound, "source_not_found", "Source not found")
 		} else {
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		}
 		return
@@ -208,6 +341,7 @@ func (h *Handlers) HandleDownload(w http.ResponseWriter, r *http.Request) {
 		if err == ErrSourceNotFound {
 			writeError(w, http.StatusNotFound, "source_not_found", "Source not found")
 		} else {
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		}
 		return
@@ -222,6 +356,12 @@ func (h *Handlers) HandleDownload(w http.ResponseWriter, r *http.Request) {
 		ext = ".jpg"
 	case "image/heic":
 		ext = ".heic"
+	case "audio/mp4":
+		ext = ".m4a"
+	case "audio/ogg":
+		ext = ".ogg"
+	case "audio/wav":
+		ext = ".wav"
 	}
 
 	filename := fmt.Sprintf("source_%s%s", sourceID.String()[:8], ext)
@@ -232,6 +372,138 @@ func (h *Handlers) HandleDownload(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
+// HandleThumbnail handles GET /v1/sources/{id}/thumbnail?size=256|512|1024
+func (h *Handlers) HandleThumbnail(w http.ResponseWriter, r *http.Request) {
+	sourceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "Invalid source ID")
+		return
+	}
+
+	size := imageproc.ThumbnailSizes[0]
+	if sizeStr := r.URL.Query().Get("size"); sizeStr != "" {
+		if size, err = strconv.Atoi(sizeStr); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_size", "size must be an integer")
+			return
+		}
+	}
+
+	thumbnailURL, data, err := h.service.GetThumbnail(r.Context(), sourceID, size)
+	if err != nil {
+		switch err {
+		case ErrSourceNotFound:
+			writeError(w, http.StatusNotFound, "source_not_found", "Source not found")
+		case ErrInvalidThumbnailSize:
+			writeError(w, http.StatusBadRequest, "invalid_size", fmt.Sprintf("size must be one of %v", imageproc.ThumbnailSizes))
+		case ErrThumbnailNotFound:
+			writeError(w, http.StatusNotFound, "thumbnail_not_found", "Thumbnail is not available for this source")
+		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		}
+		return
+	}
+
+	// S3 mode: redirect to the stored thumbnail
+	if thumbnailURL != "" {
+		http.Redirect(w, r, thumbnailURL, http.StatusFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// HandleRefreshPreview handles POST /v1/sources/{id}/preview
+func (h *Handlers) HandleRefreshPreview(w http.ResponseWriter, r *http.Request) {
+	sourceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "Invalid source ID")
+		return
+	}
+
+	dto, err := h.service.RefreshPreview(r.Context(), sourceID)
+	if err != nil {
+		switch err {
+		case ErrSourceNotFound:
+			writeError(w, http.StatusNotFound, "source_not_found", "Source not found")
+		case ErrNotLink:
+			writeError(w, http.StatusBadRequest, "not_link", "Source is not a link")
+		case ErrLinkPreviewsDisabled:
+			writeError(w, http.StatusConflict, "link_previews_disabled", "Link previews are disabled")
+		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(dto)
+}
+
+// HandleArchive handles GET /v1/sources/{id}/archive
+func (h *Handlers) HandleArchive(w http.ResponseWriter, r *http.Request) {
+	sourceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "Invalid source ID")
+		return
+	}
+
+	archive, err := h.service.GetArchive(r.Context(), sourceID)
+	if err != nil {
+		switch err {
+		case ErrSourceNotFound:
+			writeError(w, http.StatusNotFound, "source_not_found", "Source not found")
+		case ErrNotLink:
+			writeError(w, http.StatusBadRequest, "not_link", "Source is not a link")
+		case ErrArchiveNotFound:
+			writeError(w, http.StatusNotFound, "archive_not_found", "No archived text for this link")
+		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(archive)
+}
+
+// HandleTranscribe handles POST /v1/sources/{id}/transcribe
+func (h *Handlers) HandleTranscribe(w http.ResponseWriter, r *http.Request) {
+	sourceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "Invalid source ID")
+		return
+	}
+
+	dto, err := h.service.Retranscribe(r.Context(), sourceID)
+	if err != nil {
+		switch err {
+		case ErrSourceNotFound:
+			writeError(w, http.StatusNotFound, "source_not_found", "Source not found")
+		case ErrNotAudio:
+			writeError(w, http.StatusBadRequest, "not_audio", "Source is not a voice note")
+		case ErrTranscriptionDisabled:
+			writeError(w, http.StatusConflict, "transcription_disabled", "Transcription is disabled")
+		case ErrConsentRequired:
+			writeError(w, http.StatusForbidden, "ai_consent_required", "AI processing consent is required")
+		default:
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(dto)
+}
+
 // HandleDelete handles DELETE /v1/sources/{id}
 func (h *Handlers) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
@@ -245,6 +517,7 @@ func (h *Handlers) HandleDelete(w http.ResponseWriter, r *http.Request) {
 		if err == ErrSourceNotFound {
 			writeError(w, http.StatusNotFound, "source_not_found", "Source not found")
 		} else {
+			logging.FromContext(r.Context()).Error("request failed", "error", err)
 			writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
 		}
 		return
@@ -258,10 +531,14 @@ func (h *Handlers) HandleDelete(w http.ResponseWriter, r *http.Request) {
 func writeError(w http.ResponseWriter, status int, code, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
+	detail := map[string]string{
+		"code":    code,
+		"message": message,
+	}
+	if requestID := logging.ResponseRequestID(w); requestID != "" {
+		detail["request_id"] = requestID
+	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": map[string]string{
-			"code":    code,
-			"message": message,
-		},
+		"error": detail,
 	})
 }
@@ -3,15 +3,27 @@ package sources
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/fdg312/health-hub/internal/blob"
 	"github.com/fdg312/health-hub/internal/checkins"
+	"github.com/fdg312/health-hub/internal/imageproc"
+	"github.com/fdg312/health-hub/internal/linkpreview"
+	"github.com/fdg312/health-hub/internal/speech"
 	"github.com/fdg312/health-hub/internal/storage/memory"
+	"github.com/google/uuid"
 )
 
 func TestSourcesHandlers(t *testing.T) {
@@ -275,3 +287,679 @@ func TestSourcesHandlers(t *testing.T) {
 		}
 	})
 }
+
+func TestDirectUploadCreatesSourceAfterUpload(t *testing.T) {
+	handlers, store, ownerID := setupDirectUploads(t)
+
+	w := serveJSON(handlers.HandleCreateUploadURL, "/v1/sources/image/upload-url",
+		`{"profile_id":"`+ownerID.String()+`","content_type":"image/jpeg","size_bytes":2048,"title":"Анализ крови"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var upload UploadURLResponse
+	json.NewDecoder(w.Body).Decode(&upload)
+	if upload.Method != "PUT" || upload.Headers["Content-Length"] != "2048" || upload.Headers["Content-Type"] != "image/jpeg" {
+		t.Fatalf("Unexpected upload instructions: %+v", upload)
+	}
+	key := "sources/" + ownerID.String() + "/" + upload.UploadID.String()
+	if upload.UploadURL != "https://s3.test/put/"+key {
+		t.Fatalf("Expected presigned URL for %s, got %s", key, upload.UploadURL)
+	}
+
+	complete := `{"upload_id":"` + upload.UploadID.String() + `"}`
+	if w := serveJSON(handlers.HandleCompleteUpload, "/v1/sources/image/complete", complete); w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409 before the upload, got %d", w.Code)
+	}
+
+	store.objects[key] = blob.ObjectInfo{SizeBytes: 2048, ContentType: "image/jpeg"}
+	w = serveJSON(handlers.HandleCompleteUpload, "/v1/sources/image/complete", complete)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var dto SourceDTO
+	json.NewDecoder(w.Body).Decode(&dto)
+	if dto.ID != upload.UploadID || dto.Kind != KindImage || dto.SizeBytes != 2048 || dto.Title == nil {
+		t.Fatalf("Unexpected source: %+v", dto)
+	}
+
+	if w := serveJSON(handlers.HandleCompleteUpload, "/v1/sources/image/complete", complete); w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404 on second complete, got %d", w.Code)
+	}
+}
+
+func TestDirectUploadRejectsMismatchedObject(t *testing.T) {
+	handlers, store, ownerID := setupDirectUploads(t)
+
+	w := serveJSON(handlers.HandleCreateUploadURL, "/v1/sources/image/upload-url",
+		`{"profile_id":"`+ownerID.String()+`","content_type":"image/png","size_bytes":100}`)
+	var upload UploadURLResponse
+	json.NewDecoder(w.Body).Decode(&upload)
+
+	key := "sources/" + ownerID.String() + "/" + upload.UploadID.String()
+	store.objects[key] = blob.ObjectInfo{SizeBytes: 5000, ContentType: "image/png"}
+
+	w = serveJSON(handlers.HandleCompleteUpload, "/v1/sources/image/complete", `{"upload_id":"`+upload.UploadID.String()+`"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := store.objects[key]; ok {
+		t.Fatalf("Mismatched object must be deleted")
+	}
+}
+
+func TestDirectUploadValidatesRequest(t *testing.T) {
+	handlers, _, ownerID := setupDirectUploads(t)
+
+	cases := map[string]struct {
+		body string
+		code int
+	}{
+		"too large":   {`{"profile_id":"` + ownerID.String() + `","content_type":"image/png","size_bytes":20971520}`, http.StatusBadRequest},
+		"zero size":   {`{"profile_id":"` + ownerID.String() + `","content_type":"image/png","size_bytes":0}`, http.StatusBadRequest},
+		"bad mime":    {`{"profile_id":"` + ownerID.String() + `","content_type":"application/pdf","size_bytes":10}`, http.StatusBadRequest},
+		"bad profile": {`{"profile_id":"` + uuid.New().String() + `","content_type":"image/png","size_bytes":10}`, http.StatusNotFound},
+	}
+	for name, tc := range cases {
+		if w := serveJSON(handlers.HandleCreateUploadURL, "/v1/sources/image/upload-url", tc.body); w.Code != tc.code {
+			t.Errorf("%s: expected status %d, got %d: %s", name, tc.code, w.Code, w.Body.String())
+		}
+	}
+
+	// Without S3 there is nothing to upload to.
+	memStorage := memory.New()
+	profiles, _ := memStorage.ListProfiles(context.Background())
+	local := NewHandlers(NewService(memStorage.GetSourcesStorage(), memStorage, nil, 10, "image/png", 4, "", false).
+		WithDirectUploads(memStorage.GetSourcesStorage(), 900))
+	w := serveJSON(local.HandleCreateUploadURL, "/v1/sources/image/upload-url",
+		`{"profile_id":"`+profiles[0].ID.String()+`","content_type":"image/png","size_bytes":10}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409 in local mode, got %d", w.Code)
+	}
+}
+
+func TestCleanupUploadsRemovesAbandonedUploads(t *testing.T) {
+	handlers, store, ownerID := setupDirectUploads(t)
+
+	w := serveJSON(handlers.HandleCreateUploadURL, "/v1/sources/image/upload-url",
+		`{"profile_id":"`+ownerID.String()+`","content_type":"image/png","size_bytes":100}`)
+	var upload UploadURLResponse
+	json.NewDecoder(w.Body).Decode(&upload)
+	key := "sources/" + ownerID.String() + "/" + upload.UploadID.String()
+	store.objects[key] = blob.ObjectInfo{SizeBytes: 100, ContentType: "image/png"}
+
+	removed, err := handlers.service.CleanupUploads(context.Background())
+	if err != nil || removed != 0 {
+		t.Fatalf("Expected fresh upload to stay, removed=%d err=%v", removed, err)
+	}
+
+	handlers.service.now = func() time.Time { return upload.ExpiresAt.Add(uploadGrace + time.Minute) }
+	removed, err = handlers.service.CleanupUploads(context.Background())
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected 1 abandoned upload removed, removed=%d err=%v", removed, err)
+	}
+	if _, ok := store.objects[key]; ok {
+		t.Fatalf("Abandoned object must be deleted")
+	}
+	if w := serveJSON(handlers.HandleCompleteUpload, "/v1/sources/image/complete", `{"upload_id":"`+upload.UploadID.String()+`"}`); w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404 after cleanup, got %d", w.Code)
+	}
+}
+
+func TestDirectUploadProcessesImage(t *testing.T) {
+	handlers, store, ownerID := setupDirectUploads(t)
+	handlers.service.WithImageProcessor(imageproc.New(""))
+
+	photo := testJPEG(t, 600, 300)
+	w := serveJSON(handlers.HandleCreateUploadURL, "/v1/sources/image/upload-url",
+		`{"profile_id":"`+ownerID.String()+`","content_type":"image/jpeg","size_bytes":`+strconv.Itoa(len(photo))+`}`)
+	var upload UploadURLResponse
+	json.NewDecoder(w.Body).Decode(&upload)
+	key := "sources/" + ownerID.String() + "/" + upload.UploadID.String()
+	store.PutObject(context.Background(), key, photo, "image/jpeg")
+
+	w = serveJSON(handlers.HandleCompleteUpload, "/v1/sources/image/complete", `{"upload_id":"`+upload.UploadID.String()+`"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var dto SourceDTO
+	json.NewDecoder(w.Body).Decode(&dto)
+	if bytes.Contains(store.data[key], []byte("Exif")) {
+		t.Fatalf("EXIF must be stripped from the stored object")
+	}
+	if dto.SizeBytes != int64(len(store.data[key])) || dto.SizeBytes >= int64(len(photo)) {
+		t.Fatalf("Expected size of the processed object, got %d", dto.SizeBytes)
+	}
+	if dto.ThumbnailURL == nil || *dto.ThumbnailURL != "/v1/sources/"+dto.ID.String()+"/thumbnail" {
+		t.Fatalf("Unexpected thumbnail_url: %v", dto.ThumbnailURL)
+	}
+	for _, size := range []string{"256", "512"} {
+		if _, ok := store.objects[key+"_thumb_"+size+".jpg"]; !ok {
+			t.Fatalf("Expected %s thumbnail to be stored", size)
+		}
+	}
+	if _, ok := store.objects[key+"_thumb_1024.jpg"]; ok {
+		t.Fatalf("Thumbnails must not be larger than the image")
+	}
+
+	w = serveThumbnail(handlers, dto.ID, "512")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://s3.test/get/"+key+"_thumb_512.jpg" {
+		t.Fatalf("Expected redirect to stored thumbnail, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+
+	// Deleting the source removes its thumbnails.
+	req := httptest.NewRequest(http.MethodDelete, "/v1/sources/"+dto.ID.String(), nil)
+	req.SetPathValue("id", dto.ID.String())
+	handlers.HandleDelete(httptest.NewRecorder(), req)
+	if len(store.objects) != 0 {
+		t.Fatalf("Expected all objects deleted, left %v", store.objects)
+	}
+}
+
+func TestDirectUploadBoundsProcessingRead(t *testing.T) {
+	handlers, store, ownerID := setupDirectUploads(t)
+	handlers.service.WithImageProcessor(imageproc.New(""))
+
+	photo := testJPEG(t, 600, 300)
+	w := serveJSON(handlers.HandleCreateUploadURL, "/v1/sources/image/upload-url",
+		`{"profile_id":"`+ownerID.String()+`","content_type":"image/jpeg","size_bytes":100}`)
+	var upload UploadURLResponse
+	json.NewDecoder(w.Body).Decode(&upload)
+	key := "sources/" + ownerID.String() + "/" + upload.UploadID.String()
+	// HEAD still reports the signed size, but the object was replaced with
+	// a larger one before it is read.
+	store.objects[key] = blob.ObjectInfo{SizeBytes: 100, ContentType: "image/jpeg"}
+	store.data[key] = photo
+
+	w = serveJSON(handlers.HandleCompleteUpload, "/v1/sources/image/complete", `{"upload_id":"`+upload.UploadID.String()+`"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := store.objects[key]; ok {
+		t.Fatalf("Oversized object must be deleted")
+	}
+}
+
+func TestDirectUploadRejectsInvalidImage(t *testing.T) {
+	handlers, store, ownerID := setupDirectUploads(t)
+	handlers.service.WithImageProcessor(imageproc.New(""))
+
+	w := serveJSON(handlers.HandleCreateUploadURL, "/v1/sources/image/upload-url",
+		`{"profile_id":"`+ownerID.String()+`","content_type":"image/png","size_bytes":12}`)
+	var upload UploadURLResponse
+	json.NewDecoder(w.Body).Decode(&upload)
+	key := "sources/" + ownerID.String() + "/" + upload.UploadID.String()
+	store.PutObject(context.Background(), key, []byte("not an image"), "image/png")
+
+	w = serveJSON(handlers.HandleCompleteUpload, "/v1/sources/image/complete", `{"upload_id":"`+upload.UploadID.String()+`"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_image") {
+		t.Fatalf("Expected invalid_image, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := store.objects[key]; ok {
+		t.Fatalf("Invalid object must be deleted")
+	}
+}
+
+func TestThumbnailRenderedInLocalMode(t *testing.T) {
+	memStorage := memory.New()
+	profiles, _ := memStorage.ListProfiles(context.Background())
+	handlers := NewHandlers(NewService(memStorage.GetSourcesStorage(), memStorage, nil, 10, "image/jpeg,image/png", 4, "", false).
+		WithImageProcessor(imageproc.New("")))
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("profile_id", profiles[0].ID.String())
+	part, _ := writer.CreatePart(map[string][]string{
+		"Content-Disposition": {`form-data; name="file"; filename="photo.jpg"`},
+		"Content-Type":        {"image/jpeg"},
+	})
+	part.Write(testJPEG(t, 800, 400))
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/sources/image", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	handlers.HandleCreateImage(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var dto SourceDTO
+	json.NewDecoder(w.Body).Decode(&dto)
+
+	w = serveThumbnail(handlers, dto.ID, "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("Expected rendered thumbnail, got %d: %s", w.Code, w.Body.String())
+	}
+	thumb, err := jpeg.Decode(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 256 || b.Dy() != 128 {
+		t.Fatalf("Expected 256x128 thumbnail, got %dx%d", b.Dx(), b.Dy())
+	}
+
+	if w := serveThumbnail(handlers, dto.ID, "300"); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for unsupported size, got %d", w.Code)
+	}
+	if w := serveThumbnail(handlers, uuid.New(), "256"); w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404 for unknown source, got %d", w.Code)
+	}
+}
+
+func TestLinkPreviewFetchedInBackground(t *testing.T) {
+	memStorage := memory.New()
+	profiles, _ := memStorage.ListProfiles(context.Background())
+	store := &fakeStore{objects: make(map[string]blob.ObjectInfo), data: make(map[string][]byte)}
+	fetcher := &stubFetcher{preview: linkpreview.Preview{
+		Title:            "Caffeine and headaches",
+		Description:      "What the evidence says",
+		SiteName:         "Journal of Things",
+		Image:            testJPEG(t, 1600, 800),
+		ImageContentType: "image/jpeg",
+		Text:             "Caffeine withdrawal is a common cause of headache.",
+	}}
+	service := NewService(memStorage.GetSourcesStorage(), memStorage, store, 10, "image/jpeg,image/png", 4, "", false).
+		WithLinkPreviews(fetcher)
+	handlers := NewHandlers(service)
+
+	w := serveJSON(handlers.HandleCreate, "/v1/sources",
+		`{"profile_id":"`+profiles[0].ID.String()+`","kind":"link","url":"https://example.com/article"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var dto SourceDTO
+	json.NewDecoder(w.Body).Decode(&dto)
+	if dto.Preview == nil || dto.Preview.Status != PreviewPending || dto.ThumbnailURL != nil {
+		t.Fatalf("Expected a pending preview, got %+v", dto.Preview)
+	}
+
+	if fetched, err := service.FetchPendingPreviews(context.Background()); err != nil || fetched != 1 {
+		t.Fatalf("Expected one preview fetched, got %d, %v", fetched, err)
+	}
+	if fetcher.urls[0] != "https://example.com/article" {
+		t.Fatalf("Unexpected fetched url %q", fetcher.urls[0])
+	}
+
+	sources, _ := service.ListSources(context.Background(), profiles[0].ID, "", nil, 10, 0)
+	preview := sources[0].Preview
+	if preview == nil || preview.Status != PreviewReady || preview.Title == nil || *preview.Title != "Caffeine and headaches" ||
+		preview.SiteName == nil || preview.FetchedAt == nil || !preview.Archived {
+		t.Fatalf("Unexpected preview %+v", preview)
+	}
+	if sources[0].ThumbnailURL == nil {
+		t.Fatal("Expected a thumbnail for the preview image")
+	}
+	key := "sources/" + profiles[0].ID.String() + "/" + dto.ID.String()
+	stored, err := jpeg.Decode(bytes.NewReader(store.data[key]))
+	if err != nil {
+		t.Fatalf("Expected the preview image stored as JPEG: %v", err)
+	}
+	if b := stored.Bounds(); b.Dx() != 1024 || b.Dy() != 512 {
+		t.Fatalf("Expected the preview image scaled to 1024x512, got %dx%d", b.Dx(), b.Dy())
+	}
+	if _, ok := store.objects[thumbnailObjectKey(key, 256)]; !ok {
+		t.Fatal("Expected thumbnails stored next to the preview image")
+	}
+
+	w = servePath(handlers.HandleArchive, http.MethodGet, "/v1/sources/"+dto.ID.String()+"/archive", dto.ID)
+	var archive ArchiveDTO
+	json.NewDecoder(w.Body).Decode(&archive)
+	if w.Code != http.StatusOK || archive.Text != fetcher.preview.Text || archive.Title == nil || archive.FetchedAt == nil {
+		t.Fatalf("Unexpected archive %d: %+v", w.Code, archive)
+	}
+
+	// A refresh after the page dropped its image removes the stored one.
+	fetcher.preview.Image, fetcher.preview.Text = nil, ""
+	w = servePath(handlers.HandleRefreshPreview, http.MethodPost, "/v1/sources/"+dto.ID.String()+"/preview", dto.ID)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	json.NewDecoder(w.Body).Decode(&dto)
+	if dto.Preview == nil || dto.Preview.Status != PreviewPending {
+		t.Fatalf("Expected the preview pending again, got %+v", dto.Preview)
+	}
+	if _, err := service.FetchPendingPreviews(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.objects) != 0 {
+		t.Fatalf("Expected the old preview image deleted, got %v", store.objects)
+	}
+	sources, _ = service.ListSources(context.Background(), profiles[0].ID, "", nil, 10, 0)
+	if sources[0].ThumbnailURL != nil || sources[0].Preview.Archived {
+		t.Fatalf("Expected no image and no archive, got %+v", sources[0])
+	}
+	w = servePath(handlers.HandleArchive, http.MethodGet, "/v1/sources/"+dto.ID.String()+"/archive", dto.ID)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404 without archive, got %d", w.Code)
+	}
+}
+
+func TestLinkPreviewFailures(t *testing.T) {
+	memStorage := memory.New()
+	profiles, _ := memStorage.ListProfiles(context.Background())
+	ownerID := profiles[0].ID.String()
+	fetcher := &stubFetcher{err: fmt.Errorf("%w: 10.0.0.1", linkpreview.ErrBlockedAddress)}
+	service := NewService(memStorage.GetSourcesStorage(), memStorage, nil, 10, "image/jpeg,image/png", 4, "", false).
+		WithLinkPreviews(fetcher)
+	handlers := NewHandlers(service)
+
+	w := serveJSON(handlers.HandleCreate, "/v1/sources", `{"profile_id":"`+ownerID+`","kind":"link","url":"http://10.0.0.1/"}`)
+	var link SourceDTO
+	json.NewDecoder(w.Body).Decode(&link)
+	if _, err := service.FetchPendingPreviews(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sources, _ := service.ListSources(context.Background(), profiles[0].ID, "", nil, 10, 0)
+	preview := sources[0].Preview
+	if preview == nil || preview.Status != PreviewFailed || preview.Error == nil || *preview.Error != "address is not public" {
+		t.Fatalf("Expected a failed preview, got %+v", preview)
+	}
+
+	w = serveJSON(handlers.HandleCreate, "/v1/sources", `{"profile_id":"`+ownerID+`","kind":"note","text":"note"}`)
+	var note SourceDTO
+	json.NewDecoder(w.Body).Decode(&note)
+	if note.Preview != nil {
+		t.Fatalf("Expected no preview for a note, got %+v", note.Preview)
+	}
+	if w := servePath(handlers.HandleRefreshPreview, http.MethodPost, "/v1/sources/"+note.ID.String()+"/preview", note.ID); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for a note, got %d", w.Code)
+	}
+
+	// Without a fetcher links keep just their URL.
+	disabled := NewHandlers(NewService(memStorage.GetSourcesStorage(), memStorage, nil, 10, "image/jpeg,image/png", 4, "", false))
+	w = serveJSON(disabled.HandleCreate, "/v1/sources", `{"profile_id":"`+ownerID+`","kind":"link","url":"https://example.com"}`)
+	var plain SourceDTO
+	json.NewDecoder(w.Body).Decode(&plain)
+	if plain.Preview != nil {
+		t.Fatalf("Expected no preview with previews disabled, got %+v", plain.Preview)
+	}
+	if w := servePath(disabled.HandleRefreshPreview, http.MethodPost, "/v1/sources/"+link.ID.String()+"/preview", link.ID); w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409 with previews disabled, got %d", w.Code)
+	}
+}
+
+func TestVoiceNoteTranscribedInBackground(t *testing.T) {
+	memStorage := memory.New()
+	profiles, _ := memStorage.ListProfiles(context.Background())
+	ownerID := profiles[0].ID
+	checkinID := uuid.New()
+	service := NewService(memStorage.GetSourcesStorage(), memStorage, nil, 10, "image/jpeg,audio/x-wav,audio/ogg", 4, "", false).
+		WithTranscriber(speech.NewStubTranscriber("Вечером болела голова после кофе"))
+	handlers := NewHandlers(service)
+
+	recording := testWAV(16000, 16000*3)
+	w := serveUpload(handlers.HandleCreateAudio, "/v1/sources/audio", ownerID, &checkinID, "note.wav", "audio/x-wav", recording)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var dto SourceDTO
+	json.NewDecoder(w.Body).Decode(&dto)
+	if dto.Kind != KindAudio || dto.DurationMS == nil || *dto.DurationMS != 3000 || *dto.ContentType != "audio/wav" ||
+		dto.CheckinID == nil || *dto.CheckinID != checkinID {
+		t.Fatalf("Unexpected voice note %+v", dto)
+	}
+	if dto.Transcription == nil || dto.Transcription.Status != TranscriptionPending || dto.Text != nil {
+		t.Fatalf("Expected a pending transcription, got %+v", dto.Transcription)
+	}
+
+	if done, err := service.TranscribePending(context.Background()); err != nil || done != 1 {
+		t.Fatalf("Expected one transcription, got %d, %v", done, err)
+	}
+	sources, _ := service.ListSources(context.Background(), ownerID, "голова", &checkinID, 10, 0)
+	if len(sources) != 1 || sources[0].Text == nil || *sources[0].Text != "Вечером болела голова после кофе" {
+		t.Fatalf("Expected the transcript as searchable text, got %+v", sources)
+	}
+	transcription := sources[0].Transcription
+	if transcription.Status != TranscriptionReady || transcription.Language == nil || *transcription.Language != "ru" || transcription.TranscribedAt == nil {
+		t.Fatalf("Unexpected transcription %+v", transcription)
+	}
+
+	w = servePath(handlers.HandleDownload, http.MethodGet, "/v1/sources/"+dto.ID.String()+"/download", dto.ID)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "audio/wav" || !bytes.Equal(w.Body.Bytes(), recording) {
+		t.Fatalf("Expected the recording, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	w = servePath(handlers.HandleTranscribe, http.MethodPost, "/v1/sources/"+dto.ID.String()+"/transcribe", dto.ID)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	json.NewDecoder(w.Body).Decode(&dto)
+	if dto.Transcription.Status != TranscriptionPending || dto.Text == nil {
+		t.Fatalf("Expected a pending transcription keeping the old text, got %+v", dto)
+	}
+}
+
+func TestVoiceNoteValidation(t *testing.T) {
+	memStorage := memory.New()
+	profiles, _ := memStorage.ListProfiles(context.Background())
+	ownerID := profiles[0].ID
+	service := NewService(memStorage.GetSourcesStorage(), memStorage, nil, 10, "image/jpeg,audio/x-wav,audio/ogg", 4, "", false).
+		WithMaxAudioDuration(2 * time.Second)
+	handlers := NewHandlers(service)
+
+	for _, tt := range []struct {
+		name        string
+		contentType string
+		data        []byte
+		code        string
+	}{
+		{"not a recording", "audio/x-wav", []byte("RIFF...."), "invalid_audio"},
+		{"too long", "audio/x-wav", testWAV(16000, 16000*3), "audio_too_long"},
+		{"not allowed", "audio/mpeg", testWAV(16000, 16000), "unsupported_mime"},
+		{"image", "image/jpeg", testJPEG(t, 10, 10), "unsupported_mime"},
+	} {
+		w := serveUpload(handlers.HandleCreateAudio, "/v1/sources/audio", ownerID, nil, "note", tt.contentType, tt.data)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.code) {
+			t.Errorf("%s: expected 400 %s, got %d: %s", tt.name, tt.code, w.Code, w.Body.String())
+		}
+	}
+
+	// Recordings are not accepted as images either.
+	w := serveUpload(handlers.HandleCreateImage, "/v1/sources/image", ownerID, nil, "note.wav", "audio/x-wav", testWAV(16000, 16000))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "unsupported_mime") {
+		t.Fatalf("Expected 400 unsupported_mime for audio sent as image, got %d", w.Code)
+	}
+
+	// Without a transcriber voice notes are stored without transcription.
+	w = serveUpload(handlers.HandleCreateAudio, "/v1/sources/audio", ownerID, nil, "note.wav", "audio/x-wav", testWAV(16000, 16000))
+	var dto SourceDTO
+	json.NewDecoder(w.Body).Decode(&dto)
+	if w.Code != http.StatusCreated || dto.Transcription != nil {
+		t.Fatalf("Expected a voice note without transcription, got %d: %s", w.Code, w.Body.String())
+	}
+	w = servePath(handlers.HandleTranscribe, http.MethodPost, "/v1/sources/"+dto.ID.String()+"/transcribe", dto.ID)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409 with transcription disabled, got %d", w.Code)
+	}
+}
+
+func TestVoiceNoteTranscriptionWaitsForConsent(t *testing.T) {
+	memStorage := memory.New()
+	profiles, _ := memStorage.ListProfiles(context.Background())
+	ownerID := profiles[0].ID
+	consent := &stubConsent{}
+	service := NewService(memStorage.GetSourcesStorage(), memStorage, nil, 10, "audio/x-wav", 4, "", false).
+		WithTranscriber(speech.NewStubTranscriber("")).
+		WithTranscriptionConsent(consent)
+	handlers := NewHandlers(service)
+
+	w := serveUpload(handlers.HandleCreateAudio, "/v1/sources/audio", ownerID, nil, "note.wav", "audio/x-wav", testWAV(16000, 16000))
+	var dto SourceDTO
+	json.NewDecoder(w.Body).Decode(&dto)
+	if _, err := service.TranscribePending(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	source, _ := service.GetSource(context.Background(), dto.ID)
+	if source.Transcription.Status != TranscriptionFailed || *source.Transcription.Error != "ai consent required" || source.Text != nil {
+		t.Fatalf("Expected the transcription held for consent, got %+v", source.Transcription)
+	}
+
+	w = servePath(handlers.HandleTranscribe, http.MethodPost, "/v1/sources/"+dto.ID.String()+"/transcribe", dto.ID)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403 without consent, got %d", w.Code)
+	}
+
+	consent.granted = true
+	w = servePath(handlers.HandleTranscribe, http.MethodPost, "/v1/sources/"+dto.ID.String()+"/transcribe", dto.ID)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202 after consent, got %d", w.Code)
+	}
+	if _, err := service.TranscribePending(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	source, _ = service.GetSource(context.Background(), dto.ID)
+	if source.Transcription.Status != TranscriptionReady || source.Transcription.Error != nil || source.Text == nil {
+		t.Fatalf("Expected the transcript after consent, got %+v", source.Transcription)
+	}
+}
+
+// testJPEG encodes a w×h JPEG carrying an EXIF block.
+func testJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	exif := []byte("\xff\xe1\x00\x16Exif\x00\x00II*\x00\x08\x00\x00\x00\x00\x00GPS\x00")
+	return append(append(buf.Bytes()[:2:2], exif...), buf.Bytes()[2:]...)
+}
+
+func serveThumbnail(handlers *Handlers, id uuid.UUID, size string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/v1/sources/"+id.String()+"/thumbnail?size="+size, nil)
+	req.SetPathValue("id", id.String())
+	w := httptest.NewRecorder()
+	handlers.HandleThumbnail(w, req)
+	return w
+}
+
+// testWAV is a PCM WAV with dataSize bytes of silence.
+func testWAV(byteRate, dataSize uint32) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, 36+dataSize)
+	b.WriteString("WAVEfmt ")
+	for _, v := range []any{uint32(16), uint16(1), uint16(1), byteRate / 2, byteRate, uint16(2), uint16(16)} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, dataSize)
+	b.Write(make([]byte, dataSize))
+	return b.Bytes()
+}
+
+func serveUpload(handle http.HandlerFunc, path string, profileID uuid.UUID, checkinID *uuid.UUID, filename, contentType string, data []byte) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("profile_id", profileID.String())
+	if checkinID != nil {
+		writer.WriteField("checkin_id", checkinID.String())
+	}
+	part, _ := writer.CreatePart(map[string][]string{
+		"Content-Disposition": {`form-data; name="file"; filename="` + filename + `"`},
+		"Content-Type":        {contentType},
+	})
+	part.Write(data)
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, path, &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	handle(w, req)
+	return w
+}
+
+type stubConsent struct {
+	granted bool
+}
+
+func (c *stubConsent) HasAIConsent(ctx context.Context, ownerUserID string) (bool, error) {
+	return c.granted, nil
+}
+
+func servePath(handle http.HandlerFunc, method, path string, id uuid.UUID) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.SetPathValue("id", id.String())
+	w := httptest.NewRecorder()
+	handle(w, req)
+	return w
+}
+
+// stubFetcher returns the same page, or error, for every link.
+type stubFetcher struct {
+	preview linkpreview.Preview
+	err     error
+	urls    []string
+}
+
+func (f *stubFetcher) Fetch(ctx context.Context, rawURL string) (linkpreview.Preview, error) {
+	f.urls = append(f.urls, rawURL)
+	return f.preview, f.err
+}
+
+// fakeStore is an S3 stand-in that tracks object metadata, and data for
+// objects written through PutObject.
+type fakeStore struct {
+	objects map[string]blob.ObjectInfo
+	data    map[string][]byte
+}
+
+func (f *fakeStore) PutObject(ctx context.Context, key string, data []byte, contentType string) (int64, error) {
+	f.objects[key] = blob.ObjectInfo{SizeBytes: int64(len(data)), ContentType: contentType}
+	f.data[key] = data
+	return int64(len(data)), nil
+}
+
+func (f *fakeStore) GetObject(ctx context.Context, key string) ([]byte, error) {
+	data, ok := f.data[key]
+	if !ok {
+		return nil, blob.ErrObjectNotFound
+	}
+	return data, nil
+}
+
+func (f *fakeStore) GetObjectLimit(ctx context.Context, key string, maxBytes int64) ([]byte, error) {
+	data, err := f.GetObject(ctx, key)
+	if err == nil && int64(len(data)) > maxBytes {
+		return nil, blob.ErrObjectTooLarge
+	}
+	return data, err
+}
+
+func (f *fakeStore) PresignGet(ctx context.Context, key string, ttlSeconds int) (string, error) {
+	return "https://s3.test/get/" + key, nil
+}
+
+func (f *fakeStore) PresignPut(ctx context.Context, key, contentType string, sizeBytes int64, ttlSeconds int) (string, error) {
+	return "https://s3.test/put/" + key, nil
+}
+
+func (f *fakeStore) HeadObject(ctx context.Context, key string) (blob.ObjectInfo, error) {
+	info, ok := f.objects[key]
+	if !ok {
+		return blob.ObjectInfo{}, blob.ErrObjectNotFound
+	}
+	return info, nil
+}
+
+func (f *fakeStore) DeleteObject(ctx context.Context, key string) error {
+	delete(f.objects, key)
+	delete(f.data, key)
+	return nil
+}
+
+func setupDirectUploads(t *testing.T) (*Handlers, *fakeStore, uuid.UUID) {
+	t.Helper()
+
+	memStorage := memory.New()
+	store := &fakeStore{objects: make(map[string]blob.ObjectInfo), data: make(map[string][]byte)}
+	sourcesStorage := memStorage.GetSourcesStorage()
+	service := NewService(sourcesStorage, memStorage, store, 10, "image/jpeg,image/png", 4, "", false).
+		WithDirectUploads(sourcesStorage, 900)
+
+	profiles, _ := memStorage.ListProfiles(context.Background())
+	return NewHandlers(service), store, profiles[0].ID
+}
+
+func serveJSON(handle http.HandlerFunc, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handle(w, req)
+	return w
+}
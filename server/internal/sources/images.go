@@ -0,0 +1,131 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/fdg312/health-hub/internal/imageproc"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+// WithImageProcessor strips metadata from uploaded images, fixes their
+// orientation and stores thumbnails next to them.
+func (s *Service) WithImageProcessor(processor *imageproc.Processor) *Service {
+	s.images = processor
+	return s
+}
+
+// processImage returns the bytes to store for an upload. Without a
+// processor the upload is stored as is.
+func (s *Service) processImage(ctx context.Context, data []byte, contentType string) (imageproc.Result, error) {
+	if s.images == nil {
+		return imageproc.Result{Data: data, ContentType: contentType}, nil
+	}
+	result, err := s.images.Process(ctx, data, contentType)
+	if errors.Is(err, imageproc.ErrInvalidImage) {
+		return imageproc.Result{}, ErrInvalidImage
+	}
+	return result, err
+}
+
+// storeThumbnails uploads rendered thumbnails next to objectKey and returns
+// their sizes. Nothing is left behind on error.
+func (s *Service) storeThumbnails(ctx context.Context, objectKey string, thumbnails []imageproc.Thumbnail) ([]int, error) {
+	sizes := make([]int, 0, len(thumbnails))
+	for _, thumb := range thumbnails {
+		if _, err := s.blobStore.PutObject(ctx, thumbnailObjectKey(objectKey, thumb.Size), thumb.Data, "image/jpeg"); err != nil {
+			s.deleteThumbnails(ctx, objectKey, sizes)
+			return nil, fmt.Errorf("failed to upload thumbnail: %w", err)
+		}
+		sizes = append(sizes, thumb.Size)
+	}
+	return sizes, nil
+}
+
+func (s *Service) deleteThumbnails(ctx context.Context, objectKey string, sizes []int) {
+	for _, size := range sizes {
+		if err := s.blobStore.DeleteObject(ctx, thumbnailObjectKey(objectKey, size)); err != nil {
+			slog.Error("sources: delete thumbnail failed", "object_key", objectKey, "size", size, "error", err)
+		}
+	}
+}
+
+// GetThumbnail returns a thumbnail whose longest side is at most size: a
+// URL to redirect to when it is stored in S3, otherwise JPEG bytes rendered
+// from the original image.
+func (s *Service) GetThumbnail(ctx context.Context, id uuid.UUID, size int) (string, []byte, error) {
+	if !slices.Contains(imageproc.ThumbnailSizes, size) {
+		return "", nil, ErrInvalidThumbnailSize
+	}
+
+	source, err := s.GetSource(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if !hasThumbnail(source) {
+		return "", nil, ErrThumbnailNotFound
+	}
+
+	if !s.localMode && source.ObjectKey != nil && slices.Contains(source.ThumbnailSizes, size) {
+		url, err := s.objectURL(ctx, thumbnailObjectKey(*source.ObjectKey, size))
+		if err != nil {
+			return "", nil, err
+		}
+		return url, nil, nil
+	}
+
+	// Images uploaded before thumbnails existed, and every image in local
+	// mode, are scaled on request.
+	var original []byte
+	if s.localMode {
+		original, _, err = s.sourcesStorage.GetSourceBlob(ctx, source.ID)
+	} else if source.ObjectKey != nil {
+		original, err = s.blobStore.GetObject(ctx, *source.ObjectKey)
+	} else {
+		return "", nil, ErrThumbnailNotFound
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	data, err := imageproc.Render(original, size)
+	if errors.Is(err, imageproc.ErrInvalidImage) {
+		return "", nil, ErrThumbnailNotFound
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return "", data, nil
+}
+
+// hasThumbnail reports whether thumbnails can be served for source: images
+// and links with a preview image. HEIC images kept as HEIC cannot be
+// decoded by the server.
+func hasThumbnail(source *storage.Source) bool {
+	if (source.Kind != KindImage && source.Kind != KindLink) || source.ContentType == nil {
+		return false
+	}
+	switch strings.ToLower(*source.ContentType) {
+	case "image/jpeg", "image/jpg", "image/png":
+		return true
+	default:
+		return false
+	}
+}
+
+func thumbnailURL(source *storage.Source) *string {
+	if !hasThumbnail(source) {
+		return nil
+	}
+	url := "/v1/sources/" + source.ID.String() + "/thumbnail"
+	return &url
+}
+
+func thumbnailObjectKey(objectKey string, size int) string {
+	return fmt.Sprintf("%s_thumb_%d.jpg", objectKey, size)
+}
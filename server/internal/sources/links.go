@@ -0,0 +1,312 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/audit"
+	"github.com/fdg312/health-hub/internal/imageproc"
+	"github.com/fdg312/health-hub/internal/linkpreview"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Link preview statuses.
+const (
+	PreviewPending = "pending"
+	PreviewReady   = "ready"
+	PreviewFailed  = "failed"
+)
+
+// previewBatch bounds how many pending previews one query returns.
+const previewBatch = 20
+
+// LinkFetcher downloads the page behind a link source.
+type LinkFetcher interface {
+	Fetch(ctx context.Context, rawURL string) (linkpreview.Preview, error)
+}
+
+// WithLinkPreviews makes new link sources fetch their page in the
+// background: the OpenGraph title, description and image and, if the
+// fetcher archives text, the readable text of the page.
+func (s *Service) WithLinkPreviews(fetcher LinkFetcher) *Service {
+	s.links = fetcher
+	s.linkQueue = make(chan struct{}, 1)
+	return s
+}
+
+// wakeLinkPreviews tells RunLinkPreviews there is work without waiting for
+// its next tick.
+func (s *Service) wakeLinkPreviews() {
+	select {
+	case s.linkQueue <- struct{}{}:
+	default:
+	}
+}
+
+// RunLinkPreviews fetches pending previews as links are saved, and every
+// interval in case a wake-up was missed, until ctx is cancelled.
+func (s *Service) RunLinkPreviews(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if fetched, err := s.FetchPendingPreviews(ctx); err != nil {
+			slog.Error("sources: link previews failed", "error", err)
+		} else if fetched > 0 {
+			slog.Info("sources: fetched link previews", "links", fetched)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.linkQueue:
+		}
+	}
+}
+
+// FetchPendingPreviews fetches every pending preview and returns how many
+// links were processed. A page that cannot be fetched marks its preview
+// failed; only storage errors are returned.
+func (s *Service) FetchPendingPreviews(ctx context.Context) (int, error) {
+	if s.links == nil {
+		return 0, nil
+	}
+
+	fetched := 0
+	for {
+		pending, err := s.sourcesStorage.ListPendingLinkPreviews(ctx, previewBatch)
+		if err != nil {
+			return fetched, err
+		}
+		for _, source := range pending {
+			if err := s.fetchPreview(ctx, source); err != nil {
+				return fetched, err
+			}
+			fetched++
+		}
+		if len(pending) < previewBatch || ctx.Err() != nil {
+			return fetched, nil
+		}
+	}
+}
+
+// fetchPreview fetches the page of one link and stores its preview. The
+// preview image replaces the previous one under the source's object key.
+func (s *Service) fetchPreview(ctx context.Context, source storage.Source) error {
+	if source.URL == nil {
+		return s.failPreview(ctx, source.ID, errors.New("missing url"))
+	}
+	page, err := s.links.Fetch(ctx, *source.URL)
+	if err != nil {
+		return s.failPreview(ctx, source.ID, err)
+	}
+
+	fetchedAt := s.now().UTC()
+	updated := source
+	updated.Preview = &storage.LinkPreview{
+		Status:      PreviewReady,
+		Title:       optionalString(page.Title),
+		Description: optionalString(page.Description),
+		SiteName:    optionalString(page.SiteName),
+		FetchedAt:   &fetchedAt,
+	}
+	updated.ObjectKey, updated.ContentType, updated.SizeBytes, updated.ThumbnailSizes = nil, nil, 0, nil
+	if len(page.Image) > 0 {
+		if err := s.storePreviewImage(ctx, &updated, page.Image); err != nil {
+			slog.Warn("sources: link preview image skipped", "source_id", source.ID, "error", err)
+		}
+	}
+
+	var archive *string
+	if page.Text != "" {
+		archive = &page.Text
+	}
+	saved, err := s.sourcesStorage.SaveLinkPreview(ctx, &updated, archive)
+	if err != nil {
+		return err
+	}
+
+	if s.localMode {
+		return nil
+	}
+	// Objects no longer referenced: everything when the link was deleted
+	// meanwhile, otherwise thumbnails of sizes the new image did not get and
+	// the old image if the page has none now.
+	if !saved {
+		if updated.ObjectKey != nil {
+			_ = s.blobStore.DeleteObject(ctx, *updated.ObjectKey)
+			s.deleteThumbnails(ctx, *updated.ObjectKey, updated.ThumbnailSizes)
+		}
+		return nil
+	}
+	if source.ObjectKey != nil {
+		var stale []int
+		for _, size := range source.ThumbnailSizes {
+			if updated.ObjectKey == nil || !slices.Contains(updated.ThumbnailSizes, size) {
+				stale = append(stale, size)
+			}
+		}
+		s.deleteThumbnails(ctx, *source.ObjectKey, stale)
+		if updated.ObjectKey == nil {
+			if err := s.blobStore.DeleteObject(ctx, *source.ObjectKey); err != nil {
+				slog.Error("sources: delete old preview image failed", "source_id", source.ID, "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// storePreviewImage re-encodes the page image and stores it with its
+// thumbnails, setting the image fields of source.
+func (s *Service) storePreviewImage(ctx context.Context, source *storage.Source, data []byte) error {
+	processed, err := imageproc.Preview(data)
+	if err != nil {
+		return err
+	}
+
+	if s.localMode {
+		if err := s.sourcesStorage.PutSourceBlob(ctx, source.ID, processed.Data, processed.ContentType); err != nil {
+			return err
+		}
+	} else {
+		objectKey := sourceObjectKey(source.ProfileID, source.ID)
+		if _, err := s.blobStore.PutObject(ctx, objectKey, processed.Data, processed.ContentType); err != nil {
+			return err
+		}
+		sizes, err := s.storeThumbnails(ctx, objectKey, processed.Thumbnails)
+		if err != nil {
+			_ = s.blobStore.DeleteObject(ctx, objectKey)
+			return err
+		}
+		source.ObjectKey = &objectKey
+		source.ThumbnailSizes = sizes
+	}
+	contentType := processed.ContentType
+	source.ContentType = &contentType
+	source.SizeBytes = int64(len(processed.Data))
+	return nil
+}
+
+// failPreview marks the preview failed, keeping the last good one. The
+// stored message never repeats the URL, which is encrypted with the source.
+func (s *Service) failPreview(ctx context.Context, id uuid.UUID, cause error) error {
+	slog.Warn("sources: link preview failed", "source_id", id, "error", cause)
+	message := previewErrorMessage(cause)
+	_, err := s.sourcesStorage.SetLinkPreviewStatus(ctx, id, PreviewFailed, &message)
+	return err
+}
+
+func previewErrorMessage(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, linkpreview.ErrBlockedAddress):
+		return "address is not public"
+	case errors.Is(err, linkpreview.ErrInvalidURL):
+		return "url must be an absolute http(s) url"
+	case errors.Is(err, linkpreview.ErrTooLarge):
+		return "page too large"
+	case errors.Is(err, linkpreview.ErrHTTPStatus):
+		return err.Error()
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timed out"
+	default:
+		return "page could not be fetched"
+	}
+}
+
+// RefreshPreview queues the preview of a link to be fetched again, e.g. for
+// links saved before previews were enabled.
+func (s *Service) RefreshPreview(ctx context.Context, id uuid.UUID) (*SourceDTO, error) {
+	source, err := s.GetSource(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if source.Kind != KindLink {
+		return nil, ErrNotLink
+	}
+	if s.links == nil {
+		return nil, ErrLinkPreviewsDisabled
+	}
+
+	ok, err := s.sourcesStorage.SetLinkPreviewStatus(ctx, id, PreviewPending, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSourceNotFound
+	}
+	s.wakeLinkPreviews()
+
+	if source.Preview == nil {
+		source.Preview = &storage.LinkPreview{}
+	}
+	source.Preview.Status, source.Preview.Error = PreviewPending, nil
+	return s.toDTO(source), nil
+}
+
+// GetArchive returns the archived text of a link's page.
+func (s *Service) GetArchive(ctx context.Context, id uuid.UUID) (*ArchiveDTO, error) {
+	source, err := s.GetSource(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if source.Kind != KindLink {
+		return nil, ErrNotLink
+	}
+
+	text, ok, err := s.sourcesStorage.GetSourceArchive(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrArchiveNotFound
+	}
+
+	if s.audit != nil {
+		profileID := source.ProfileID
+		s.audit.Record(ctx, audit.Event{
+			ProfileID:    &profileID,
+			Action:       audit.ActionSourceDownload,
+			ResourceType: "sources",
+			ResourceID:   source.ID.String(),
+		})
+	}
+
+	archive := &ArchiveDTO{SourceID: source.ID, URL: source.URL, Title: source.Title, Text: text}
+	if source.Preview != nil {
+		archive.FetchedAt = source.Preview.FetchedAt
+		if archive.Title == nil {
+			archive.Title = source.Preview.Title
+		}
+	}
+	return archive, nil
+}
+
+func previewDTO(preview *storage.LinkPreview) *LinkPreviewDTO {
+	if preview == nil {
+		return nil
+	}
+	return &LinkPreviewDTO{
+		Status:      preview.Status,
+		Title:       preview.Title,
+		Description: preview.Description,
+		SiteName:    preview.SiteName,
+		Error:       preview.Error,
+		FetchedAt:   preview.FetchedAt,
+		Archived:    preview.Archived,
+	}
+}
+
+func optionalString(value string) *string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	return &value
+}
@@ -18,16 +18,73 @@ type CreateSourceRequest struct {
 
 // SourceDTO — представление source для API
 type SourceDTO struct {
-	ID          uuid.UUID  `json:"id"`
+	ID           uuid.UUID  `json:"id"`
+	ProfileID    uuid.UUID  `json:"profile_id"`
+	Kind         string     `json:"kind"`
+	Title        *string    `json:"title,omitempty"`
+	Text         *string    `json:"text,omitempty"`
+	URL          *string    `json:"url,omitempty"`
+	CheckinID    *uuid.UUID `json:"checkin_id,omitempty"`
+	ContentType  *string    `json:"content_type,omitempty"`
+	SizeBytes    int64      `json:"size_bytes,omitempty"`
+	ThumbnailURL *string    `json:"thumbnail_url,omitempty"`
+	// Preview — превью страницы ссылки; нет, если загрузка превью выключена
+	Preview *LinkPreviewDTO `json:"preview,omitempty"`
+	// DurationMS — длительность голосовой заметки; расшифровка — в text
+	DurationMS    *int64            `json:"duration_ms,omitempty"`
+	Transcription *TranscriptionDTO `json:"transcription,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// TranscriptionDTO — статус расшифровки голосовой заметки
+type TranscriptionDTO struct {
+	Status        string     `json:"status"` // pending, ready, failed
+	Language      *string    `json:"language,omitempty"`
+	Error         *string    `json:"error,omitempty"`
+	TranscribedAt *time.Time `json:"transcribed_at,omitempty"`
+}
+
+// LinkPreviewDTO — OpenGraph-превью страницы; картинка — thumbnail_url source
+type LinkPreviewDTO struct {
+	Status      string     `json:"status"` // pending, ready, failed
+	Title       *string    `json:"title,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	SiteName    *string    `json:"site_name,omitempty"`
+	Error       *string    `json:"error,omitempty"`
+	FetchedAt   *time.Time `json:"fetched_at,omitempty"`
+	Archived    bool       `json:"archived"`
+}
+
+// ArchiveDTO — сохранённый текст страницы ссылки
+type ArchiveDTO struct {
+	SourceID  uuid.UUID  `json:"source_id"`
+	URL       *string    `json:"url,omitempty"`
+	Title     *string    `json:"title,omitempty"`
+	Text      string     `json:"text"`
+	FetchedAt *time.Time `json:"fetched_at,omitempty"`
+}
+
+// CreateUploadURLRequest — запрос ссылки на прямую загрузку изображения в S3
+type CreateUploadURLRequest struct {
 	ProfileID   uuid.UUID  `json:"profile_id"`
-	Kind        string     `json:"kind"`
+	ContentType string     `json:"content_type"`
+	SizeBytes   int64      `json:"size_bytes"`
 	Title       *string    `json:"title,omitempty"`
-	Text        *string    `json:"text,omitempty"`
-	URL         *string    `json:"url,omitempty"`
 	CheckinID   *uuid.UUID `json:"checkin_id,omitempty"`
-	ContentType *string    `json:"content_type,omitempty"`
-	SizeBytes   int64      `json:"size_bytes,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// UploadURLResponse — presigned PUT; headers нужно отправить без изменений
+type UploadURLResponse struct {
+	UploadID  uuid.UUID         `json:"upload_id"`
+	UploadURL string            `json:"upload_url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// CompleteUploadRequest — подтверждение загрузки, создаёт image source
+type CompleteUploadRequest struct {
+	UploadID uuid.UUID `json:"upload_id"`
 }
 
 // SourcesResponse — список sources
@@ -40,4 +97,5 @@ const (
 	KindLink  = "link"
 	KindNote  = "note"
 	KindImage = "image"
+	KindAudio = "audio"
 )
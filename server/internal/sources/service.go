@@ -7,8 +7,12 @@ import (
 	"io"
 	"mime/multipart"
 	"strings"
+	"time"
 
+	"github.com/fdg312/health-hub/internal/audit"
 	"github.com/fdg312/health-hub/internal/blob"
+	"github.com/fdg312/health-hub/internal/imageproc"
+	"github.com/fdg312/health-hub/internal/speech"
 	"github.com/fdg312/health-hub/internal/storage"
 	"github.com/fdg312/health-hub/internal/userctx"
 	"github.com/google/uuid"
@@ -23,6 +27,26 @@ var (
 	ErrFileTooLarge       = errors.New("file too large")
 	ErrUnsupportedMime    = errors.New("unsupported mime type")
 	ErrMaxSourcesExceeded = errors.New("max sources per checkin exceeded")
+
+	ErrInvalidSize             = errors.New("size_bytes must be positive")
+	ErrDirectUploadUnavailable = errors.New("direct upload requires S3 blob storage")
+	ErrUploadNotFound          = errors.New("upload not found")
+	ErrUploadIncomplete        = errors.New("object not uploaded yet")
+	ErrUploadMismatch          = errors.New("uploaded object does not match the upload")
+
+	ErrInvalidImage         = errors.New("file is not a valid image")
+	ErrInvalidThumbnailSize = errors.New("unsupported thumbnail size")
+	ErrThumbnailNotFound    = errors.New("thumbnail not available")
+
+	ErrNotLink              = errors.New("source is not a link")
+	ErrLinkPreviewsDisabled = errors.New("link previews are disabled")
+	ErrArchiveNotFound      = errors.New("archive not found")
+
+	ErrInvalidAudio          = errors.New("file is not a valid recording")
+	ErrAudioTooLong          = errors.New("recording too long")
+	ErrNotAudio              = errors.New("source is not a voice note")
+	ErrTranscriptionDisabled = errors.New("transcription is disabled")
+	ErrConsentRequired       = errors.New("ai consent required")
 )
 
 // ProfileStorageAdapter — адаптер для доступа к профилям
@@ -41,6 +65,17 @@ type Service struct {
 	maxUploadMB        int
 	allowedMimes       []string
 	maxSourcesPerCheck int
+	audit              audit.Recorder
+	uploads            storage.SourceUploadsStorage
+	uploadTTLSeconds   int
+	images             *imageproc.Processor
+	links              LinkFetcher
+	linkQueue          chan struct{}
+	maxAudioDuration   time.Duration
+	transcriber        speech.Transcriber
+	transcribeQueue    chan struct{}
+	transcribeConsent  consentChecker
+	now                func() time.Time
 }
 
 // NewService creates a new sources service
@@ -72,9 +107,17 @@ func NewService(
 		maxUploadMB:        maxUploadMB,
 		allowedMimes:       mimes,
 		maxSourcesPerCheck: maxSourcesPerCheck,
+		maxAudioDuration:   defaultMaxAudioDuration,
+		now:                time.Now,
 	}
 }
 
+// WithAuditRecorder enables audit events for image downloads.
+func (s *Service) WithAuditRecorder(recorder audit.Recorder) *Service {
+	s.audit = recorder
+	return s
+}
+
 // CreateSource creates a link or note source
 func (s *Service) CreateSource(ctx context.Context, req CreateSourceRequest) (*SourceDTO, error) {
 	if err := s.ensureProfileAccess(ctx, req.ProfileID); err != nil {
@@ -109,10 +152,16 @@ func (s *Service) CreateSource(ctx context.Context, req CreateSourceRequest) (*S
 		URL:       req.URL,
 		CheckinID: req.CheckinID,
 	}
+	if req.Kind == KindLink && s.links != nil {
+		source.Preview = &storage.LinkPreview{Status: PreviewPending}
+	}
 
 	if err := s.sourcesStorage.CreateSource(ctx, source); err != nil {
 		return nil, err
 	}
+	if source.Preview != nil {
+		s.wakeLinkPreviews()
+	}
 
 	return s.toDTO(source), nil
 }
@@ -131,19 +180,13 @@ func (s *Service) CreateImageSource(ctx context.Context, profileID uuid.UUID, ch
 
 	// Check MIME type
 	contentType := fileHeader.Header.Get("Content-Type")
-	if !s.isAllowedMime(contentType) {
+	if !s.isAllowedMime(KindImage, contentType) {
 		return nil, ErrUnsupportedMime
 	}
 
 	// Check max sources per checkin (if checkin_id specified)
-	if checkinID != nil {
-		existingSources, err := s.sourcesStorage.ListSources(ctx, profileID, "", checkinID, 100, 0)
-		if err != nil {
-			return nil, err
-		}
-		if len(existingSources) >= s.maxSourcesPerCheck {
-			return nil, ErrMaxSourcesExceeded
-		}
+	if err := s.checkSourcesLimit(ctx, profileID, checkinID); err != nil {
+		return nil, err
 	}
 
 	// Read file data
@@ -158,6 +201,13 @@ func (s *Service) CreateImageSource(ctx context.Context, profileID uuid.UUID, ch
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	// Metadata is stripped before anything is stored
+	processed, err := s.processImage(ctx, data, contentType)
+	if err != nil {
+		return nil, err
+	}
+	data, contentType = processed.Data, processed.ContentType
+
 	// Generate source ID first (for S3 key)
 	sourceID := uuid.New()
 
@@ -169,7 +219,7 @@ func (s *Service) CreateImageSource(ctx context.Context, profileID uuid.UUID, ch
 		Title:       title,
 		CheckinID:   checkinID,
 		ContentType: &contentType,
-		SizeBytes:   fileHeader.Size,
+		SizeBytes:   int64(len(data)),
 	}
 
 	// Store blob first (before creating metadata)
@@ -184,16 +234,24 @@ func (s *Service) CreateImageSource(ctx context.Context, profileID uuid.UUID, ch
 		}
 	} else {
 		// S3 mode: upload to S3 first
-		objectKey := fmt.Sprintf("sources/%s/%s", source.ProfileID.String(), source.ID.String())
+		objectKey := sourceObjectKey(source.ProfileID, source.ID)
 		if _, err := s.blobStore.PutObject(ctx, objectKey, data, contentType); err != nil {
 			return nil, fmt.Errorf("failed to upload to S3: %w", err)
 		}
 
+		sizes, err := s.storeThumbnails(ctx, objectKey, processed.Thumbnails)
+		if err != nil {
+			_ = s.blobStore.DeleteObject(ctx, objectKey)
+			return nil, err
+		}
+
 		// Set object_key and create metadata
 		source.ObjectKey = &objectKey
+		source.ThumbnailSizes = sizes
 		if err := s.sourcesStorage.CreateSource(ctx, source); err != nil {
 			// Rollback: delete from S3
 			_ = s.blobStore.DeleteObject(ctx, objectKey)
+			s.deleteThumbnails(ctx, objectKey, sizes)
 			return nil, err
 		}
 	}
@@ -247,12 +305,13 @@ func (s *Service) DeleteSource(ctx context.Context, id uuid.UUID) error {
 		if err := s.blobStore.DeleteObject(ctx, *source.ObjectKey); err != nil {
 			// Log error but continue with metadata deletion
 		}
+		s.deleteThumbnails(ctx, *source.ObjectKey, source.ThumbnailSizes)
 	}
 
 	return s.sourcesStorage.DeleteSource(ctx, id)
 }
 
-// GetImageDownloadURL returns download URL and whether to redirect (true for S3, false for local)
+// GetImageDownloadURL returns download URL of an image or voice note and whether to redirect (true for S3, false for local)
 func (s *Service) GetImageDownloadURL(ctx context.Context, id uuid.UUID) (string, bool, error) {
 	source, err := s.sourcesStorage.GetSource(ctx, id)
 	if err != nil {
@@ -262,8 +321,18 @@ func (s *Service) GetImageDownloadURL(ctx context.Context, id uuid.UUID) (string
 		return "", false, ErrSourceNotFound
 	}
 
-	if source.Kind != KindImage {
-		return "", false, errors.New("source is not an image")
+	if !hasFile(source) {
+		return "", false, errors.New("source has no file")
+	}
+
+	if s.audit != nil {
+		profileID := source.ProfileID
+		s.audit.Record(ctx, audit.Event{
+			ProfileID:    &profileID,
+			Action:       audit.ActionSourceDownload,
+			ResourceType: "sources",
+			ResourceID:   source.ID.String(),
+		})
 	}
 
 	// Local mode: no redirect, will serve directly
@@ -276,22 +345,29 @@ func (s *Service) GetImageDownloadURL(ctx context.Context, id uuid.UUID) (string
 		return "", false, errors.New("object key not found")
 	}
 
+	url, err := s.objectURL(ctx, *source.ObjectKey)
+	if err != nil {
+		return "", false, err
+	}
+	return url, true, nil
+}
+
+// objectURL returns a public or presigned URL for an S3 object
+func (s *Service) objectURL(ctx context.Context, objectKey string) (string, error) {
 	// If prefer public URL mode, construct public URL directly
 	if s.preferPublicURL && s.publicBaseURL != "" {
-		publicURL := strings.TrimSuffix(s.publicBaseURL, "/") + "/" + *source.ObjectKey
-		return publicURL, true, nil
+		return strings.TrimSuffix(s.publicBaseURL, "/") + "/" + objectKey, nil
 	}
 
 	// Otherwise, generate presigned URL
-	presignedURL, err := s.blobStore.PresignGet(ctx, *source.ObjectKey, 900) // 15 min default
+	presignedURL, err := s.blobStore.PresignGet(ctx, objectKey, 900) // 15 min default
 	if err != nil {
-		return "", false, fmt.Errorf("failed to generate presigned URL: %w", err)
+		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
 	}
-
-	return presignedURL, true, nil
+	return presignedURL, nil
 }
 
-// GetImageData retrieves image bytes for download (local mode only)
+// GetImageData retrieves image or voice note bytes for download (local mode only)
 func (s *Service) GetImageData(ctx context.Context, id uuid.UUID) ([]byte, string, error) {
 	source, err := s.sourcesStorage.GetSource(ctx, id)
 	if err != nil {
@@ -301,8 +377,8 @@ func (s *Service) GetImageData(ctx context.Context, id uuid.UUID) ([]byte, strin
 		return nil, "", ErrSourceNotFound
 	}
 
-	if source.Kind != KindImage {
-		return nil, "", errors.New("source is not an image")
+	if !hasFile(source) {
+		return nil, "", errors.New("source has no file")
 	}
 
 	if s.localMode {
@@ -332,20 +408,51 @@ func (s *Service) GetImageData(ctx context.Context, id uuid.UUID) ([]byte, strin
 
 func (s *Service) toDTO(source *storage.Source) *SourceDTO {
 	return &SourceDTO{
-		ID:          source.ID,
-		ProfileID:   source.ProfileID,
-		Kind:        source.Kind,
-		Title:       source.Title,
-		Text:        source.Text,
-		URL:         source.URL,
-		CheckinID:   source.CheckinID,
-		ContentType: source.ContentType,
-		SizeBytes:   source.SizeBytes,
-		CreatedAt:   source.CreatedAt,
+		ID:            source.ID,
+		ProfileID:     source.ProfileID,
+		Kind:          source.Kind,
+		Title:         source.Title,
+		Text:          source.Text,
+		URL:           source.URL,
+		CheckinID:     source.CheckinID,
+		ContentType:   source.ContentType,
+		SizeBytes:     source.SizeBytes,
+		ThumbnailURL:  thumbnailURL(source),
+		Preview:       previewDTO(source.Preview),
+		DurationMS:    source.DurationMS,
+		Transcription: transcriptionDTO(source.Transcription),
+		CreatedAt:     source.CreatedAt,
+	}
+}
+
+// hasFile reports whether the source is an uploaded file that can be
+// downloaded.
+func hasFile(source *storage.Source) bool {
+	return source.Kind == KindImage || source.Kind == KindAudio
+}
+
+// checkSourcesLimit returns ErrMaxSourcesExceeded when the checkin already
+// has maxSourcesPerCheck sources
+func (s *Service) checkSourcesLimit(ctx context.Context, profileID uuid.UUID, checkinID *uuid.UUID) error {
+	if checkinID == nil {
+		return nil
 	}
+	existingSources, err := s.sourcesStorage.ListSources(ctx, profileID, "", checkinID, 100, 0)
+	if err != nil {
+		return err
+	}
+	if len(existingSources) >= s.maxSourcesPerCheck {
+		return ErrMaxSourcesExceeded
+	}
+	return nil
 }
 
-func (s *Service) isAllowedMime(contentType string) bool {
+// isAllowedMime reports whether contentType is in UPLOAD_ALLOWED_MIME and is
+// a type of kind (image/*, audio/*): the list is shared by both uploads.
+func (s *Service) isAllowedMime(kind, contentType string) bool {
+	if !strings.HasPrefix(strings.ToLower(contentType), kind+"/") {
+		return false
+	}
 	for _, allowed := range s.allowedMimes {
 		if strings.EqualFold(contentType, allowed) {
 			return true
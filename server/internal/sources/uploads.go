@@ -0,0 +1,272 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/blob"
+	"github.com/fdg312/health-hub/internal/imageproc"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+// uploadGrace is how long after its URL expires an upload can still be
+// completed; the janitor removes it after that.
+const uploadGrace = time.Hour
+
+// uploadJanitorBatch bounds how many abandoned uploads one pass removes.
+const uploadJanitorBatch = 100
+
+// WithDirectUploads enables presigned uploads straight to S3. Upload URLs
+// stay valid for ttlSeconds.
+func (s *Service) WithDirectUploads(uploads storage.SourceUploadsStorage, ttlSeconds int) *Service {
+	s.uploads = uploads
+	s.uploadTTLSeconds = ttlSeconds
+	return s
+}
+
+// CreateUploadURL issues a presigned PUT for an image the client uploads to
+// S3 itself. The size and content type are signed into the URL, so S3
+// rejects anything else.
+func (s *Service) CreateUploadURL(ctx context.Context, req CreateUploadURLRequest) (*UploadURLResponse, error) {
+	if s.localMode || s.uploads == nil {
+		return nil, ErrDirectUploadUnavailable
+	}
+	if err := s.ensureProfileAccess(ctx, req.ProfileID); err != nil {
+		return nil, ErrProfileNotFound
+	}
+
+	if req.SizeBytes <= 0 {
+		return nil, ErrInvalidSize
+	}
+	if req.SizeBytes > int64(s.maxUploadMB)*1024*1024 {
+		return nil, ErrFileTooLarge
+	}
+	contentType := strings.TrimSpace(req.ContentType)
+	if !s.isAllowedMime(KindImage, contentType) {
+		return nil, ErrUnsupportedMime
+	}
+	if err := s.checkSourcesLimit(ctx, req.ProfileID, req.CheckinID); err != nil {
+		return nil, err
+	}
+
+	// The upload ID becomes the source ID, keeping the usual object key.
+	uploadID := uuid.New()
+	objectKey := sourceObjectKey(req.ProfileID, uploadID)
+	url, err := s.blobStore.PresignPut(ctx, objectKey, contentType, req.SizeBytes, s.uploadTTLSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign upload: %w", err)
+	}
+
+	expiresAt := s.now().UTC().Add(time.Duration(s.uploadTTLSeconds) * time.Second)
+	if err := s.uploads.CreateSourceUpload(ctx, storage.SourceUpload{
+		ID:          uploadID,
+		ProfileID:   req.ProfileID,
+		CheckinID:   req.CheckinID,
+		Title:       req.Title,
+		ObjectKey:   objectKey,
+		ContentType: contentType,
+		SizeBytes:   req.SizeBytes,
+		ExpiresAt:   expiresAt,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &UploadURLResponse{
+		UploadID:  uploadID,
+		UploadURL: url,
+		Method:    "PUT",
+		Headers: map[string]string{
+			"Content-Type":   contentType,
+			"Content-Length": strconv.FormatInt(req.SizeBytes, 10),
+		},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// CompleteUpload checks the uploaded object with HEAD, runs the image
+// pipeline on it and creates the image source. An object that does not
+// match the upload is deleted.
+func (s *Service) CompleteUpload(ctx context.Context, uploadID uuid.UUID) (*SourceDTO, error) {
+	if s.localMode || s.uploads == nil {
+		return nil, ErrDirectUploadUnavailable
+	}
+
+	upload, found, err := s.uploads.GetSourceUpload(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrUploadNotFound
+	}
+	if err := s.ensureProfileAccess(ctx, upload.ProfileID); err != nil {
+		return nil, ErrUploadNotFound
+	}
+
+	info, err := s.blobStore.HeadObject(ctx, upload.ObjectKey)
+	if errors.Is(err, blob.ErrObjectNotFound) {
+		return nil, ErrUploadIncomplete
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check uploaded object: %w", err)
+	}
+	if info.SizeBytes != upload.SizeBytes || !strings.EqualFold(strings.TrimSpace(info.ContentType), upload.ContentType) {
+		s.discardUpload(ctx, upload)
+		return nil, ErrUploadMismatch
+	}
+
+	// Other sources may have been attached to the checkin meanwhile.
+	if err := s.checkSourcesLimit(ctx, upload.ProfileID, upload.CheckinID); err != nil {
+		return nil, err
+	}
+
+	contentType := upload.ContentType
+	objectKey := upload.ObjectKey
+	sizeBytes := info.SizeBytes
+	var thumbnailSizes []int
+	if s.images != nil {
+		processed, sizes, err := s.processUploadedObject(ctx, upload)
+		if err != nil {
+			return nil, err
+		}
+		contentType = processed.ContentType
+		sizeBytes = int64(len(processed.Data))
+		thumbnailSizes = sizes
+	}
+
+	source := &storage.Source{
+		ID:             upload.ID,
+		ProfileID:      upload.ProfileID,
+		Kind:           KindImage,
+		Title:          upload.Title,
+		CheckinID:      upload.CheckinID,
+		ObjectKey:      &objectKey,
+		ContentType:    &contentType,
+		SizeBytes:      sizeBytes,
+		ThumbnailSizes: thumbnailSizes,
+	}
+	completed, err := s.uploads.CompleteSourceUpload(ctx, upload.ID, source)
+	if err != nil {
+		return nil, err
+	}
+	if !completed {
+		// Completed by a concurrent request or removed by the janitor.
+		return nil, ErrUploadNotFound
+	}
+
+	return s.toDTO(source), nil
+}
+
+// processUploadedObject runs the image pipeline on an uploaded object,
+// replaces it with the processed image and stores the thumbnails. An object
+// that is not a valid image is discarded together with the upload.
+//
+// Metadata has to be stripped before the source exists, so the image does
+// pass through API memory here, unlike the upload itself. The read is
+// bounded by the size signed into the upload URL (at most UPLOAD_MAX_MB):
+// an object replaced with a larger one after the HEAD check is refused
+// rather than read in full.
+func (s *Service) processUploadedObject(ctx context.Context, upload storage.SourceUpload) (imageproc.Result, []int, error) {
+	data, err := s.blobStore.GetObjectLimit(ctx, upload.ObjectKey, upload.SizeBytes)
+	if errors.Is(err, blob.ErrObjectTooLarge) {
+		s.discardUpload(ctx, upload)
+		return imageproc.Result{}, nil, ErrUploadMismatch
+	}
+	if err != nil {
+		return imageproc.Result{}, nil, fmt.Errorf("failed to read uploaded object: %w", err)
+	}
+
+	processed, err := s.processImage(ctx, data, upload.ContentType)
+	if errors.Is(err, ErrInvalidImage) {
+		s.discardUpload(ctx, upload)
+		return imageproc.Result{}, nil, ErrInvalidImage
+	}
+	if err != nil {
+		return imageproc.Result{}, nil, err
+	}
+
+	if processed.ContentType != upload.ContentType || !bytes.Equal(processed.Data, data) {
+		if _, err := s.blobStore.PutObject(ctx, upload.ObjectKey, processed.Data, processed.ContentType); err != nil {
+			return imageproc.Result{}, nil, fmt.Errorf("failed to replace uploaded object: %w", err)
+		}
+	}
+
+	sizes, err := s.storeThumbnails(ctx, upload.ObjectKey, processed.Thumbnails)
+	if err != nil {
+		return imageproc.Result{}, nil, err
+	}
+	return processed, sizes, nil
+}
+
+// CleanupUploads removes uploads that were never completed, together with
+// whatever the client managed to put in S3, and returns how many it removed.
+func (s *Service) CleanupUploads(ctx context.Context) (int, error) {
+	if s.localMode || s.uploads == nil {
+		return 0, nil
+	}
+
+	expired, err := s.uploads.ListExpiredSourceUploads(ctx, s.now().UTC().Add(-uploadGrace), uploadJanitorBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, upload := range expired {
+		if err := s.blobStore.DeleteObject(ctx, upload.ObjectKey); err != nil {
+			slog.Error("sources: delete abandoned upload failed", "upload_id", upload.ID, "error", err)
+			continue
+		}
+		if s.images != nil {
+			// Completion may have stored thumbnails before failing.
+			s.deleteThumbnails(ctx, upload.ObjectKey, imageproc.ThumbnailSizes)
+		}
+		if err := s.uploads.DeleteSourceUpload(ctx, upload.ID); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// RunUploadJanitor removes abandoned uploads every interval until ctx is
+// cancelled.
+func (s *Service) RunUploadJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if removed, err := s.CleanupUploads(ctx); err != nil {
+			slog.Error("sources: upload cleanup failed", "error", err)
+		} else if removed > 0 {
+			slog.Info("sources: removed abandoned uploads", "uploads", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// discardUpload drops a rejected upload and its object; the client has to
+// request a new URL.
+func (s *Service) discardUpload(ctx context.Context, upload storage.SourceUpload) {
+	if err := s.blobStore.DeleteObject(ctx, upload.ObjectKey); err != nil {
+		slog.Error("sources: delete rejected upload failed", "upload_id", upload.ID, "error", err)
+		return
+	}
+	if err := s.uploads.DeleteSourceUpload(ctx, upload.ID); err != nil {
+		slog.Error("sources: drop rejected upload failed", "upload_id", upload.ID, "error", err)
+	}
+}
+
+func sourceObjectKey(profileID, sourceID uuid.UUID) string {
+	return fmt.Sprintf("sources/%s/%s", profileID.String(), sourceID.String())
+}
@@ -0,0 +1,51 @@
+// Package speech turns voice notes into text. Engines are pluggable: a
+// Whisper-compatible HTTP API (OpenAI or a self-hosted server), a local
+// whisper.cpp binary, or a stub for tests.
+package speech
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTranscriptionFailed wraps every engine failure: the engine is down,
+// timed out or returned something unreadable.
+var ErrTranscriptionFailed = errors.New("transcription failed")
+
+// Audio is a recorded voice note.
+type Audio struct {
+	Data        []byte
+	ContentType string
+}
+
+// Transcript is the recognized text. Language is the ISO 639-1 code the
+// engine detected or was told; empty when unknown.
+type Transcript struct {
+	Text     string
+	Language string
+}
+
+// Transcriber turns audio into text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio Audio) (Transcript, error)
+}
+
+// StubTranscriber returns the same transcript for every recording. It
+// stands in for a real engine in tests and in local runs.
+type StubTranscriber struct {
+	Transcript Transcript
+}
+
+// NewStubTranscriber returns text, or a fixed evening note when empty.
+func NewStubTranscriber(text string) *StubTranscriber {
+	if text == "" {
+		text = "Спал плохо, днём болела голова, вечером прогулка 40 минут."
+	}
+	return &StubTranscriber{Transcript: Transcript{Text: text, Language: "ru"}}
+}
+
+func (t *StubTranscriber) Transcribe(ctx context.Context, audio Audio) (Transcript, error) {
+	_ = ctx
+	_ = audio
+	return t.Transcript, nil
+}
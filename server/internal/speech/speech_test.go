@@ -0,0 +1,116 @@
+package speech
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWhisperHTTPTranscribe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("unexpected request %s auth=%q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("parse form: %v", err)
+		}
+		if r.FormValue("model") != "whisper-1" || r.FormValue("language") != "ru" {
+			t.Errorf("unexpected form %v", r.MultipartForm.Value)
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("missing file: %v", err)
+		}
+		data, _ := io.ReadAll(file)
+		if header.Filename != "note.m4a" || header.Header.Get("Content-Type") != "audio/mp4" || string(data) != "audio" {
+			t.Errorf("unexpected file %q %q %q", header.Filename, header.Header.Get("Content-Type"), data)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"text":"  Болела голова. "}`))
+	}))
+	defer server.Close()
+
+	transcriber := NewWhisperHTTP(server.URL+"/v1/", "sk-test", "whisper-1", "ru", time.Second)
+	got, err := transcriber.Transcribe(context.Background(), Audio{Data: []byte("audio"), ContentType: "audio/x-m4a"})
+	if err != nil {
+		t.Fatalf("transcribe failed: %v", err)
+	}
+	if got.Text != "Болела голова." || got.Language != "ru" {
+		t.Fatalf("unexpected transcript %+v", got)
+	}
+}
+
+func TestWhisperHTTPReportsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bad/audio/transcriptions":
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write([]byte(`not json`))
+		}
+	}))
+	defer server.Close()
+
+	for _, base := range []string{server.URL + "/bad", server.URL + "/garbage", "http://127.0.0.1:1"} {
+		_, err := NewWhisperHTTP(base, "", "whisper-1", "", time.Second).
+			Transcribe(context.Background(), Audio{Data: []byte("audio"), ContentType: "audio/wav"})
+		if !errors.Is(err, ErrTranscriptionFailed) {
+			t.Fatalf("%s: expected ErrTranscriptionFailed, got %v", base, err)
+		}
+	}
+}
+
+func TestWhisperCppTranscribe(t *testing.T) {
+	dir := t.TempDir()
+	// ffmpeg stand-in: copies the input (after -i) to the last argument.
+	ffmpeg := writeScript(t, dir, "ffmpeg", `#!/bin/sh
+while [ "$1" != "-i" ]; do shift; done
+in="$2"
+for last; do :; done
+cp "$in" "$last"
+`)
+	// whisper.cpp stand-in: prints the language and the WAV it was given
+	// on separate lines, the way segments are printed.
+	whisper := writeScript(t, dir, "whisper-cli", `#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -f) file="$2"; shift ;;
+    -l) lang="$2"; shift ;;
+  esac
+  shift
+done
+echo " lang=$lang "
+echo
+cat "$file"
+echo
+`)
+
+	transcriber := NewWhisperCpp(whisper, "ggml-base.bin", ffmpeg, "")
+	got, err := transcriber.Transcribe(context.Background(), Audio{Data: []byte("tired, went to bed early"), ContentType: "audio/ogg"})
+	if err != nil {
+		t.Fatalf("transcribe failed: %v", err)
+	}
+	if got.Text != "lang=auto tired, went to bed early" || got.Language != "" {
+		t.Fatalf("unexpected transcript %+v", got)
+	}
+
+	broken := NewWhisperCpp(filepath.Join(dir, "missing"), "ggml-base.bin", ffmpeg, "ru")
+	if _, err := broken.Transcribe(context.Background(), Audio{Data: []byte("x"), ContentType: "audio/wav"}); !errors.Is(err, ErrTranscriptionFailed) {
+		t.Fatalf("expected ErrTranscriptionFailed, got %v", err)
+	}
+}
+
+func writeScript(t *testing.T, dir, name, script string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
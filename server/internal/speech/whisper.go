@@ -0,0 +1,124 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/audioprobe"
+)
+
+// maxResponseBytes bounds a transcription response.
+const maxResponseBytes = 4 << 20
+
+// WhisperHTTP posts audio to a Whisper-compatible transcription endpoint:
+// `POST {baseURL}/audio/transcriptions` with a multipart file and model, as
+// served by OpenAI, faster-whisper-server, LocalAI and similar.
+type WhisperHTTP struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	language   string
+	httpClient *http.Client
+}
+
+// NewWhisperHTTP creates a client for baseURL (e.g.
+// https://api.openai.com/v1). The API key is optional for local servers;
+// an empty language lets the engine detect it.
+func NewWhisperHTTP(baseURL, apiKey, model, language string, timeout time.Duration) *WhisperHTTP {
+	if timeout <= 0 {
+		timeout = 120 * time.Second
+	}
+	return &WhisperHTTP{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		language:   language,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (w *WhisperHTTP) Transcribe(ctx context.Context, audio Audio) (Transcript, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="note%s"`, audioprobe.Extension(audio.ContentType)))
+	header.Set("Content-Type", audioprobe.Normalize(audio.ContentType))
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return Transcript{}, err
+	}
+	if _, err := part.Write(audio.Data); err != nil {
+		return Transcript{}, err
+	}
+	_ = form.WriteField("model", w.model)
+	_ = form.WriteField("response_format", "json")
+	if w.language != "" {
+		_ = form.WriteField("language", w.language)
+	}
+	if err := form.Close(); err != nil {
+		return Transcript{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.baseURL+"/audio/transcriptions", &body)
+	if err != nil {
+		return Transcript{}, err
+	}
+	if w.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+w.apiKey)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("%w: %v", ErrTranscriptionFailed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Transcript{}, fmt.Errorf("%w: status %d", ErrTranscriptionFailed, resp.StatusCode)
+	}
+
+	var decoded struct {
+		Text     string `json:"text"`
+		Language string `json:"language"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&decoded); err != nil {
+		return Transcript{}, fmt.Errorf("%w: decode response: %v", ErrTranscriptionFailed, err)
+	}
+	return Transcript{
+		Text:     strings.TrimSpace(decoded.Text),
+		Language: firstNonEmpty(languageCode(decoded.Language), w.language),
+	}, nil
+}
+
+// languageCode maps the language names some servers report ("russian") to
+// ISO 639-1 codes; codes pass through.
+func languageCode(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	switch language {
+	case "russian":
+		return "ru"
+	case "english":
+		return "en"
+	}
+	if len(language) == 2 {
+		return language
+	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
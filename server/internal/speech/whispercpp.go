@@ -0,0 +1,88 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/audioprobe"
+)
+
+// whisperCppTimeout bounds one local run, conversion included.
+const whisperCppTimeout = 5 * time.Minute
+
+// WhisperCpp runs a local whisper.cpp binary. whisper.cpp reads 16 kHz WAV,
+// so other recordings are converted with ffmpeg first; the audio never
+// leaves the server.
+type WhisperCpp struct {
+	command  string
+	model    string
+	ffmpeg   string
+	language string
+}
+
+// NewWhisperCpp creates a transcriber running `<command> -m <model> -f
+// in.wav -nt -np -l <language>`. An empty language lets whisper.cpp detect
+// it.
+func NewWhisperCpp(command, model, ffmpeg, language string) *WhisperCpp {
+	return &WhisperCpp{command: command, model: model, ffmpeg: ffmpeg, language: language}
+}
+
+func (w *WhisperCpp) Transcribe(ctx context.Context, audio Audio) (Transcript, error) {
+	ctx, cancel := context.WithTimeout(ctx, whisperCppTimeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "whisper-*")
+	if err != nil {
+		return Transcript{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "in"+audioprobe.Extension(audio.ContentType))
+	if err := os.WriteFile(input, audio.Data, 0o600); err != nil {
+		return Transcript{}, err
+	}
+	wav := filepath.Join(dir, "in16k.wav")
+	if _, err := run(ctx, w.ffmpeg, "-nostdin", "-loglevel", "error", "-i", input, "-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", wav); err != nil {
+		return Transcript{}, fmt.Errorf("%w: ffmpeg: %v", ErrTranscriptionFailed, err)
+	}
+
+	language := w.language
+	if language == "" {
+		language = "auto"
+	}
+	out, err := run(ctx, w.command, "-m", w.model, "-f", wav, "-nt", "-np", "-l", language)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("%w: whisper.cpp: %v", ErrTranscriptionFailed, err)
+	}
+	return Transcript{Text: joinLines(out), Language: w.language}, nil
+}
+
+// run executes name with args and returns its standard output.
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.String(), nil
+}
+
+// joinLines turns whisper.cpp's one-segment-per-line output into running
+// text.
+func joinLines(out string) string {
+	var parts []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+type aiConsentStorage struct {
+	mu sync.RWMutex
+	// history per owner, oldest first; only the last entry may be active
+	history map[string][]storage.AIConsent
+}
+
+func newAIConsentStorage() *aiConsentStorage {
+	return &aiConsentStorage{history: make(map[string][]storage.AIConsent)}
+}
+
+func (s *aiConsentStorage) GetAIConsent(ctx context.Context, ownerUserID string) (storage.AIConsent, bool, error) {
+	_ = ctx
+	key := strings.TrimSpace(ownerUserID)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows := s.history[key]
+	if len(rows) == 0 {
+		return storage.AIConsent{}, false, nil
+	}
+	return rows[len(rows)-1], true, nil
+}
+
+func (s *aiConsentStorage) GrantAIConsent(ctx context.Context, ownerUserID, policyVersion string) (storage.AIConsent, error) {
+	_ = ctx
+	key := strings.TrimSpace(ownerUserID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	s.revokeLocked(key, now)
+	row := storage.AIConsent{
+		ID:            uuid.New(),
+		OwnerUserID:   key,
+		PolicyVersion: policyVersion,
+		GrantedAt:     now,
+	}
+	s.history[key] = append(s.history[key], row)
+	return row, nil
+}
+
+func (s *aiConsentStorage) RevokeAIConsent(ctx context.Context, ownerUserID string) (bool, error) {
+	_ = ctx
+	key := strings.TrimSpace(ownerUserID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.revokeLocked(key, time.Now().UTC()), nil
+}
+
+func (s *aiConsentStorage) revokeLocked(key string, at time.Time) bool {
+	rows := s.history[key]
+	if len(rows) == 0 || rows[len(rows)-1].RevokedAt != nil {
+		return false
+	}
+	rows[len(rows)-1].RevokedAt = &at
+	return true
+}
@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+)
+
+type aiUsageKey struct {
+	owner    string
+	day      time.Time
+	provider string
+	model    string
+}
+
+type aiUsageStorage struct {
+	mu   sync.RWMutex
+	rows map[aiUsageKey]*storage.AIUsageDay
+}
+
+func newAIUsageStorage() *aiUsageStorage {
+	return &aiUsageStorage{rows: make(map[aiUsageKey]*storage.AIUsageDay)}
+}
+
+func (s *aiUsageStorage) AddAIUsage(ctx context.Context, ownerUserID string, day time.Time, provider, model string, inputTokens, outputTokens int) error {
+	_ = ctx
+	key := aiUsageKey{
+		owner:    strings.TrimSpace(ownerUserID),
+		day:      usageDay(day),
+		provider: provider,
+		model:    model,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.rows[key]
+	if !ok {
+		row = &storage.AIUsageDay{
+			OwnerUserID: key.owner,
+			Day:         key.day,
+			Provider:    provider,
+			Model:       model,
+		}
+		s.rows[key] = row
+	}
+	row.Requests++
+	row.InputTokens += int64(inputTokens)
+	row.OutputTokens += int64(outputTokens)
+	return nil
+}
+
+func (s *aiUsageStorage) ListAIUsage(ctx context.Context, ownerUserID string, from, to time.Time) ([]storage.AIUsageDay, error) {
+	_ = ctx
+	owner := strings.TrimSpace(ownerUserID)
+	from, to = usageDay(from), usageDay(to)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]storage.AIUsageDay, 0)
+	for key, row := range s.rows {
+		if key.owner == owner && !key.day.Before(from) && !key.day.After(to) {
+			out = append(out, *row)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Day.Equal(out[j].Day) {
+			return out[i].Day.Before(out[j].Day)
+		}
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out, nil
+}
+
+func (s *aiUsageStorage) AggregateAIUsage(ctx context.Context, from, to time.Time) ([]storage.AIUsageTotal, error) {
+	_ = ctx
+	from, to = usageDay(from), usageDay(to)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type modelKey struct{ provider, model string }
+	totals := make(map[modelKey]*storage.AIUsageTotal)
+	owners := make(map[modelKey]map[string]bool)
+	for key, row := range s.rows {
+		if key.day.Before(from) || key.day.After(to) {
+			continue
+		}
+		mk := modelKey{key.provider, key.model}
+		total, ok := totals[mk]
+		if !ok {
+			total = &storage.AIUsageTotal{Provider: key.provider, Model: key.model}
+			totals[mk] = total
+			owners[mk] = make(map[string]bool)
+		}
+		owners[mk][key.owner] = true
+		total.Requests += row.Requests
+		total.InputTokens += row.InputTokens
+		total.OutputTokens += row.OutputTokens
+	}
+
+	out := make([]storage.AIUsageTotal, 0, len(totals))
+	for mk, total := range totals {
+		total.Owners = int64(len(owners[mk]))
+		out = append(out, *total)
+	}
+	// Most expensive first.
+	sort.Slice(out, func(i, j int) bool {
+		ti := out[i].InputTokens + out[i].OutputTokens
+		tj := out[j].InputTokens + out[j].OutputTokens
+		if ti != tj {
+			return ti > tj
+		}
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out, nil
+}
+
+// usageDay truncates t to its UTC date.
+func usageDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
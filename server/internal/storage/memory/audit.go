@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+)
+
+// AuditMemoryStorage keeps audit events in memory for local/dev usage.
+type AuditMemoryStorage struct {
+	mu     sync.RWMutex
+	events []storage.AuditEvent
+}
+
+func NewAuditMemoryStorage() *AuditMemoryStorage {
+	return &AuditMemoryStorage{}
+}
+
+func (s *AuditMemoryStorage) InsertAuditEvent(ctx context.Context, event storage.AuditEvent) error {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *AuditMemoryStorage) ListAuditEvents(ctx context.Context, filter storage.AuditFilter) ([]storage.AuditEvent, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]storage.AuditEvent, 0)
+	for _, e := range s.events {
+		if e.OwnerUserID != filter.OwnerUserID {
+			continue
+		}
+		if filter.ProfileID != nil && (e.ProfileID == nil || *e.ProfileID != *filter.ProfileID) {
+			continue
+		}
+		if filter.Action != "" && e.Action != filter.Action {
+			continue
+		}
+		if filter.ResourceType != "" && e.ResourceType != filter.ResourceType {
+			continue
+		}
+		if filter.From != nil && e.CreatedAt.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !e.CreatedAt.Before(*filter.To) {
+			continue
+		}
+		if filter.Before != nil && !e.CreatedAt.Before(*filter.Before) {
+			continue
+		}
+		result = append(result, e)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+func (s *AuditMemoryStorage) DeleteAuditEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.events[:0]
+	var deleted int64
+	for _, e := range s.events {
+		if e.CreatedAt.Before(before) {
+			deleted++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	s.events = kept
+	return deleted, nil
+}
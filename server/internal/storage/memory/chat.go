@@ -8,21 +8,27 @@ import (
 	"time"
 
 	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/textsearch"
 	"github.com/google/uuid"
 )
 
 type ChatMemoryStorage struct {
 	mu       sync.RWMutex
 	messages []storage.ChatMessage
+	threads  map[uuid.UUID]*storage.ChatThread
+	facts    []storage.ChatFact
+	index    *textsearch.Index // set by New for unified search
 }
 
 func NewChatMemoryStorage() *ChatMemoryStorage {
 	return &ChatMemoryStorage{
 		messages: make([]storage.ChatMessage, 0),
+		threads:  make(map[uuid.UUID]*storage.ChatThread),
+		facts:    make([]storage.ChatFact, 0),
 	}
 }
 
-func (s *ChatMemoryStorage) InsertMessage(ctx context.Context, ownerUserID string, profileID uuid.UUID, role, content string) (storage.ChatMessage, error) {
+func (s *ChatMemoryStorage) InsertMessage(ctx context.Context, ownerUserID string, profileID, threadID uuid.UUID, role, content string) (storage.ChatMessage, error) {
 	_ = ctx
 
 	s.mu.Lock()
@@ -32,12 +38,17 @@ func (s *ChatMemoryStorage) InsertMessage(ctx context.Context, ownerUserID strin
 		ID:          uuid.New(),
 		OwnerUserID: strings.TrimSpace(ownerUserID),
 		ProfileID:   profileID,
+		ThreadID:    threadID,
 		Role:        strings.TrimSpace(role),
 		Content:     content,
 		CreatedAt:   time.Now().UTC(),
 	}
 
 	s.messages = append(s.messages, msg)
+	s.index.Put(searchKey(storage.SearchTypeChatMessage, msg.ID), "", msg.Content)
+	if thread, ok := s.threads[threadID]; ok {
+		thread.UpdatedAt = msg.CreatedAt
+	}
 	return msg, nil
 }
 
@@ -45,6 +56,21 @@ func (s *ChatMemoryStorage) ListMessages(ctx context.Context, ownerUserID string
 	_ = ctx
 
 	ownerUserID = strings.TrimSpace(ownerUserID)
+	return s.listMessages(limit, before, func(msg storage.ChatMessage) bool {
+		return msg.OwnerUserID == ownerUserID && msg.ProfileID == profileID
+	})
+}
+
+func (s *ChatMemoryStorage) ListThreadMessages(ctx context.Context, ownerUserID string, threadID uuid.UUID, limit int, before *time.Time) ([]storage.ChatMessage, *time.Time, error) {
+	_ = ctx
+
+	ownerUserID = strings.TrimSpace(ownerUserID)
+	return s.listMessages(limit, before, func(msg storage.ChatMessage) bool {
+		return msg.OwnerUserID == ownerUserID && msg.ThreadID == threadID
+	})
+}
+
+func (s *ChatMemoryStorage) listMessages(limit int, before *time.Time, match func(storage.ChatMessage) bool) ([]storage.ChatMessage, *time.Time, error) {
 	if limit <= 0 {
 		limit = 50
 	}
@@ -54,7 +80,7 @@ func (s *ChatMemoryStorage) ListMessages(ctx context.Context, ownerUserID string
 
 	filtered := make([]storage.ChatMessage, 0, len(s.messages))
 	for _, msg := range s.messages {
-		if msg.OwnerUserID != ownerUserID || msg.ProfileID != profileID {
+		if !match(msg) {
 			continue
 		}
 		if before != nil && !msg.CreatedAt.Before(*before) {
@@ -78,3 +104,157 @@ func (s *ChatMemoryStorage) ListMessages(ctx context.Context, ownerUserID string
 	cursor := messages[0].CreatedAt.UTC()
 	return messages, &cursor, nil
 }
+
+func (s *ChatMemoryStorage) CreateThread(ctx context.Context, ownerUserID string, profileID uuid.UUID, title string) (storage.ChatThread, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	thread := &storage.ChatThread{
+		ID:          uuid.New(),
+		OwnerUserID: strings.TrimSpace(ownerUserID),
+		ProfileID:   profileID,
+		Title:       title,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	s.threads[thread.ID] = thread
+	return *thread, nil
+}
+
+func (s *ChatMemoryStorage) GetThread(ctx context.Context, ownerUserID string, threadID uuid.UUID) (storage.ChatThread, bool, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	thread, ok := s.threads[threadID]
+	if !ok || thread.OwnerUserID != strings.TrimSpace(ownerUserID) {
+		return storage.ChatThread{}, false, nil
+	}
+	return *thread, true, nil
+}
+
+func (s *ChatMemoryStorage) ListThreads(ctx context.Context, ownerUserID string, profileID uuid.UUID, includeArchived bool) ([]storage.ChatThread, error) {
+	_ = ctx
+
+	ownerUserID = strings.TrimSpace(ownerUserID)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]storage.ChatThread, 0)
+	for _, thread := range s.threads {
+		if thread.OwnerUserID != ownerUserID || thread.ProfileID != profileID {
+			continue
+		}
+		if thread.ArchivedAt != nil && !includeArchived {
+			continue
+		}
+		result = append(result, *thread)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].UpdatedAt.Equal(result[j].UpdatedAt) {
+			return result[i].ID.String() > result[j].ID.String()
+		}
+		return result[i].UpdatedAt.After(result[j].UpdatedAt)
+	})
+	return result, nil
+}
+
+func (s *ChatMemoryStorage) UpdateThread(ctx context.Context, ownerUserID string, threadID uuid.UUID, title *string, archived *bool) (storage.ChatThread, bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	thread, ok := s.threads[threadID]
+	if !ok || thread.OwnerUserID != strings.TrimSpace(ownerUserID) {
+		return storage.ChatThread{}, false, nil
+	}
+
+	now := time.Now().UTC()
+	if title != nil {
+		thread.Title = *title
+	}
+	if archived != nil {
+		switch {
+		case *archived && thread.ArchivedAt == nil:
+			thread.ArchivedAt = &now
+		case !*archived:
+			thread.ArchivedAt = nil
+		}
+	}
+	thread.UpdatedAt = now
+	return *thread, true, nil
+}
+
+func (s *ChatMemoryStorage) SaveThreadSummary(ctx context.Context, ownerUserID string, threadID uuid.UUID, summary string, summarizedUntil time.Time) error {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	thread, ok := s.threads[threadID]
+	if !ok || thread.OwnerUserID != strings.TrimSpace(ownerUserID) {
+		return nil
+	}
+	until := summarizedUntil.UTC()
+	thread.Summary = summary
+	thread.SummarizedUntil = &until
+	return nil
+}
+
+func (s *ChatMemoryStorage) InsertFact(ctx context.Context, ownerUserID string, profileID uuid.UUID, content string) (storage.ChatFact, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fact := storage.ChatFact{
+		ID:          uuid.New(),
+		OwnerUserID: strings.TrimSpace(ownerUserID),
+		ProfileID:   profileID,
+		Content:     content,
+		CreatedAt:   time.Now().UTC(),
+	}
+	s.facts = append(s.facts, fact)
+	return fact, nil
+}
+
+func (s *ChatMemoryStorage) ListFacts(ctx context.Context, ownerUserID string, profileID uuid.UUID) ([]storage.ChatFact, error) {
+	_ = ctx
+
+	ownerUserID = strings.TrimSpace(ownerUserID)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]storage.ChatFact, 0)
+	for _, fact := range s.facts {
+		if fact.OwnerUserID == ownerUserID && fact.ProfileID == profileID {
+			result = append(result, fact)
+		}
+	}
+	return result, nil
+}
+
+func (s *ChatMemoryStorage) DeleteFact(ctx context.Context, ownerUserID string, factID uuid.UUID) (bool, error) {
+	_ = ctx
+
+	ownerUserID = strings.TrimSpace(ownerUserID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, fact := range s.facts {
+		if fact.ID == factID && fact.OwnerUserID == ownerUserID {
+			s.facts = append(s.facts[:i], s.facts[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -2,9 +2,12 @@ package memory
 
 import (
 	"errors"
+	"sort"
 	"sync"
 
 	"github.com/fdg312/health-hub/internal/checkins"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/textsearch"
 	"github.com/google/uuid"
 )
 
@@ -13,6 +16,8 @@ type CheckinsMemoryStorage struct {
 	mu       sync.RWMutex
 	checkins map[uuid.UUID]checkins.Checkin          // by ID
 	byKey    map[string]uuid.UUID                     // key: "profileID:date:type" -> checkin ID
+	templates map[uuid.UUID]checkins.Template
+	index    *textsearch.Index                        // set by New for unified search
 }
 
 // NewCheckinsMemoryStorage creates a new in-memory checkins storage
@@ -20,6 +25,7 @@ func NewCheckinsMemoryStorage() *CheckinsMemoryStorage {
 	return &CheckinsMemoryStorage{
 		checkins: make(map[uuid.UUID]checkins.Checkin),
 		byKey:    make(map[string]uuid.UUID),
+		templates: make(map[uuid.UUID]checkins.Template),
 	}
 }
 
@@ -51,7 +57,8 @@ func (s *CheckinsMemoryStorage) GetCheckin(id uuid.UUID) (*checkins.Checkin, err
 	return &c, nil
 }
 
-// UpsertCheckin creates or updates a check-in (by profile_id, date, type)
+// UpsertCheckin creates or updates a check-in (by profile_id, date, type);
+// adhoc check-ins are always created
 func (s *CheckinsMemoryStorage) UpsertCheckin(checkin *checkins.Checkin) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -65,6 +72,9 @@ func (s *CheckinsMemoryStorage) UpsertCheckin(checkin *checkins.Checkin) error {
 		existing.Score = checkin.Score
 		existing.Tags = checkin.Tags
 		existing.Note = checkin.Note
+		existing.TemplateID = checkin.TemplateID
+		existing.RecordedAt = checkin.RecordedAt
+		existing.Answers = checkin.Answers
 		existing.UpdatedAt = checkin.UpdatedAt
 		s.checkins[existingID] = existing
 
@@ -73,8 +83,11 @@ func (s *CheckinsMemoryStorage) UpsertCheckin(checkin *checkins.Checkin) error {
 	} else {
 		// Create new checkin
 		s.checkins[checkin.ID] = *checkin
-		s.byKey[key] = checkin.ID
+		if checkin.Type != checkins.TypeAdhoc {
+			s.byKey[key] = checkin.ID
+		}
 	}
+	s.index.Put(searchKey(storage.SearchTypeCheckin, checkin.ID), checkinTags(*checkin), checkin.Note)
 
 	return nil
 }
@@ -91,11 +104,142 @@ func (s *CheckinsMemoryStorage) DeleteCheckin(id uuid.UUID) error {
 
 	key := makeKey(c.ProfileID, c.Date, c.Type)
 	delete(s.checkins, id)
-	delete(s.byKey, key)
+	if s.byKey[key] == id {
+		delete(s.byKey, key)
+	}
+	s.index.Remove(searchKey(storage.SearchTypeCheckin, id))
+
+	return nil
+}
+
+// ListTemplates returns the templates of a profile, defaults first
+func (s *CheckinsMemoryStorage) ListTemplates(profileID uuid.UUID) ([]checkins.Template, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]checkins.Template, 0)
+	for _, t := range s.templates {
+		if t.ProfileID == profileID {
+			result = append(result, t)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].IsDefault != result[j].IsDefault {
+			return result[i].IsDefault
+		}
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].Type < result[j].Type
+	})
+
+	return result, nil
+}
+
+// GetTemplate retrieves a template by ID
+func (s *CheckinsMemoryStorage) GetTemplate(id uuid.UUID) (*checkins.Template, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, exists := s.templates[id]
+	if !exists {
+		return nil, errors.New("template not found")
+	}
+
+	return &t, nil
+}
+
+// CreateTemplate stores a new template; an existing default template of the
+// same profile and type is returned instead of a second one
+func (s *CheckinsMemoryStorage) CreateTemplate(template *checkins.Template) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if template.IsDefault {
+		for _, t := range s.templates {
+			if t.IsDefault && t.ProfileID == template.ProfileID && t.Type == template.Type {
+				*template = t
+				return nil
+			}
+		}
+	}
+	s.templates[template.ID] = *template
+
+	return nil
+}
+
+// UpdateTemplate saves the name and questions of a template
+func (s *CheckinsMemoryStorage) UpdateTemplate(template *checkins.Template) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, exists := s.templates[template.ID]
+	if !exists {
+		return errors.New("template not found")
+	}
+	existing.Name = template.Name
+	existing.Questions = template.Questions
+	existing.UpdatedAt = template.UpdatedAt
+	s.templates[template.ID] = existing
+	*template = existing
 
 	return nil
 }
 
+// DeleteTemplate deletes a template; its checkins keep their answers
+func (s *CheckinsMemoryStorage) DeleteTemplate(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.templates[id]; !exists {
+		return errors.New("template not found")
+	}
+	delete(s.templates, id)
+	for checkinID, c := range s.checkins {
+		if c.TemplateID != nil && *c.TemplateID == id {
+			c.TemplateID = nil
+			s.checkins[checkinID] = c
+		}
+	}
+
+	return nil
+}
+
+// ListAnswers returns answers to a question within a date range, oldest first
+func (s *CheckinsMemoryStorage) ListAnswers(profileID uuid.UUID, templateID *uuid.UUID, questionID, from, to string) ([]checkins.AnswerPoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]checkins.AnswerPoint, 0)
+	for _, c := range s.checkins {
+		if c.ProfileID != profileID || c.Date < from || c.Date > to {
+			continue
+		}
+		if templateID != nil && (c.TemplateID == nil || *c.TemplateID != *templateID) {
+			continue
+		}
+		for _, a := range c.Answers {
+			if a.QuestionID == questionID {
+				result = append(result, checkins.AnswerPoint{
+					CheckinID:  c.ID,
+					TemplateID: c.TemplateID,
+					Date:       c.Date,
+					RecordedAt: c.RecordedAt,
+					Answer:     a,
+				})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Date != result[j].Date {
+			return result[i].Date < result[j].Date
+		}
+		return result[i].RecordedAt.Before(result[j].RecordedAt)
+	})
+
+	return result, nil
+}
+
 func makeKey(profileID uuid.UUID, date, ctype string) string {
 	return profileID.String() + ":" + date + ":" + ctype
 }
@@ -0,0 +1,183 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+type coachingStorage struct {
+	mu       sync.RWMutex
+	programs map[uuid.UUID]storage.CoachingProgram
+	checkins map[uuid.UUID][]storage.CoachingCheckin // by program, oldest first
+}
+
+func newCoachingStorage() *coachingStorage {
+	return &coachingStorage{
+		programs: make(map[uuid.UUID]storage.CoachingProgram),
+		checkins: make(map[uuid.UUID][]storage.CoachingCheckin),
+	}
+}
+
+func (s *coachingStorage) CreateCoachingProgram(ctx context.Context, program storage.CoachingProgram) (storage.CoachingProgram, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	if program.ID == uuid.Nil {
+		program.ID = uuid.New()
+	}
+	program.OwnerUserID = strings.TrimSpace(program.OwnerUserID)
+	program.Milestones = copyMilestones(program.Milestones)
+	program.CreatedAt = now
+	program.UpdatedAt = now
+	s.programs[program.ID] = program
+	return copyProgram(program), nil
+}
+
+func (s *coachingStorage) GetCoachingProgram(ctx context.Context, ownerUserID string, programID uuid.UUID) (storage.CoachingProgram, bool, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	program, ok := s.programs[programID]
+	if !ok || program.OwnerUserID != strings.TrimSpace(ownerUserID) {
+		return storage.CoachingProgram{}, false, nil
+	}
+	return copyProgram(program), true, nil
+}
+
+func (s *coachingStorage) ListCoachingPrograms(ctx context.Context, ownerUserID string, profileID uuid.UUID, status string) ([]storage.CoachingProgram, error) {
+	_ = ctx
+	owner := strings.TrimSpace(ownerUserID)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]storage.CoachingProgram, 0)
+	for _, program := range s.programs {
+		if program.OwnerUserID != owner || program.ProfileID != profileID {
+			continue
+		}
+		if status != "" && program.Status != status {
+			continue
+		}
+		out = append(out, copyProgram(program))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+func (s *coachingStorage) UpdateCoachingProgram(ctx context.Context, program storage.CoachingProgram) (storage.CoachingProgram, bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated, ok := s.updateLocked(program)
+	if !ok {
+		return storage.CoachingProgram{}, false, nil
+	}
+	return copyProgram(updated), true, nil
+}
+
+func (s *coachingStorage) DeleteCoachingProgram(ctx context.Context, ownerUserID string, programID uuid.UUID) (bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	program, ok := s.programs[programID]
+	if !ok || program.OwnerUserID != strings.TrimSpace(ownerUserID) {
+		return false, nil
+	}
+	delete(s.programs, programID)
+	delete(s.checkins, programID)
+	return true, nil
+}
+
+func (s *coachingStorage) ListDueCoachingPrograms(ctx context.Context, now time.Time, limit int) ([]storage.CoachingProgram, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]storage.CoachingProgram, 0)
+	for _, program := range s.programs {
+		if program.Status == "active" && !program.NextCheckinAt.After(now) {
+			out = append(out, copyProgram(program))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].NextCheckinAt.Before(out[j].NextCheckinAt)
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *coachingStorage) RecordCoachingCheckin(ctx context.Context, checkin storage.CoachingCheckin, program storage.CoachingProgram) (storage.CoachingCheckin, bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.updateLocked(program); !ok {
+		return storage.CoachingCheckin{}, false, nil
+	}
+	checkin.ID = uuid.New()
+	checkin.ProgramID = program.ID
+	checkin.OwnerUserID = program.OwnerUserID
+	checkin.CreatedAt = time.Now().UTC()
+	s.checkins[program.ID] = append(s.checkins[program.ID], checkin)
+	return checkin, true, nil
+}
+
+func (s *coachingStorage) ListCoachingCheckins(ctx context.Context, ownerUserID string, programID uuid.UUID) ([]storage.CoachingCheckin, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	program, ok := s.programs[programID]
+	if !ok || program.OwnerUserID != strings.TrimSpace(ownerUserID) {
+		return []storage.CoachingCheckin{}, nil
+	}
+	return append([]storage.CoachingCheckin{}, s.checkins[programID]...), nil
+}
+
+// updateLocked stores the mutable fields of program; identity, goal and
+// start date are fixed at creation.
+func (s *coachingStorage) updateLocked(program storage.CoachingProgram) (storage.CoachingProgram, bool) {
+	current, ok := s.programs[program.ID]
+	if !ok || current.OwnerUserID != strings.TrimSpace(program.OwnerUserID) {
+		return storage.CoachingProgram{}, false
+	}
+	current.Status = program.Status
+	current.TargetDate = program.TargetDate
+	current.Milestones = copyMilestones(program.Milestones)
+	current.NextCheckinAt = program.NextCheckinAt
+	current.UpdatedAt = time.Now().UTC()
+	s.programs[program.ID] = current
+	return current, true
+}
+
+func copyProgram(program storage.CoachingProgram) storage.CoachingProgram {
+	program.Milestones = copyMilestones(program.Milestones)
+	return program
+}
+
+func copyMilestones(milestones []storage.CoachingMilestone) []storage.CoachingMilestone {
+	return append([]storage.CoachingMilestone{}, milestones...)
+}
@@ -0,0 +1,207 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+type cycleDayKey struct {
+	profileID uuid.UUID
+	date      string
+}
+
+type cycleStorage struct {
+	mu       sync.RWMutex
+	periods  map[uuid.UUID]storage.CyclePeriod
+	days     map[cycleDayKey]storage.CycleDay
+	settings map[uuid.UUID]storage.CycleSettings
+}
+
+func newCycleStorage() *cycleStorage {
+	return &cycleStorage{
+		periods:  make(map[uuid.UUID]storage.CyclePeriod),
+		days:     make(map[cycleDayKey]storage.CycleDay),
+		settings: make(map[uuid.UUID]storage.CycleSettings),
+	}
+}
+
+func (s *cycleStorage) CreatePeriod(ctx context.Context, period storage.CyclePeriod) (storage.CyclePeriod, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if period.ID == uuid.Nil {
+		period.ID = uuid.New()
+	}
+	period.CreatedAt = time.Now().UTC()
+	period.UpdatedAt = period.CreatedAt
+	s.periods[period.ID] = copyCyclePeriod(period)
+	return copyCyclePeriod(period), nil
+}
+
+func (s *cycleStorage) GetPeriod(ctx context.Context, id uuid.UUID) (storage.CyclePeriod, bool, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	period, ok := s.periods[id]
+	if !ok {
+		return storage.CyclePeriod{}, false, nil
+	}
+	return copyCyclePeriod(period), true, nil
+}
+
+func (s *cycleStorage) UpdatePeriod(ctx context.Context, period storage.CyclePeriod) (storage.CyclePeriod, bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.periods[period.ID]
+	if !ok {
+		return storage.CyclePeriod{}, false, nil
+	}
+	existing.StartDate = period.StartDate
+	existing.EndDate = period.EndDate
+	existing.Note = period.Note
+	existing.UpdatedAt = time.Now().UTC()
+	s.periods[existing.ID] = copyCyclePeriod(existing)
+	return copyCyclePeriod(existing), true, nil
+}
+
+func (s *cycleStorage) DeletePeriod(ctx context.Context, id uuid.UUID) (bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.periods[id]; !ok {
+		return false, nil
+	}
+	delete(s.periods, id)
+	return true, nil
+}
+
+func (s *cycleStorage) ListPeriods(ctx context.Context, profileID uuid.UUID, from, to time.Time) ([]storage.CyclePeriod, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]storage.CyclePeriod, 0)
+	for _, period := range s.periods {
+		if period.ProfileID != profileID || !inDateRange(period.StartDate, from, to) {
+			continue
+		}
+		out = append(out, copyCyclePeriod(period))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].StartDate.Before(out[j].StartDate)
+	})
+	return out, nil
+}
+
+func (s *cycleStorage) UpsertCycleDay(ctx context.Context, day storage.CycleDay) (storage.CycleDay, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := cycleDayKey{profileID: day.ProfileID, date: day.Date.Format("2006-01-02")}
+	now := time.Now().UTC()
+	if existing, ok := s.days[key]; ok {
+		day.ID = existing.ID
+		day.CreatedAt = existing.CreatedAt
+	} else {
+		day.ID = uuid.New()
+		day.CreatedAt = now
+	}
+	day.UpdatedAt = now
+	s.days[key] = copyCycleDay(day)
+	return copyCycleDay(day), nil
+}
+
+func (s *cycleStorage) DeleteCycleDay(ctx context.Context, profileID uuid.UUID, date time.Time) (bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := cycleDayKey{profileID: profileID, date: date.Format("2006-01-02")}
+	if _, ok := s.days[key]; !ok {
+		return false, nil
+	}
+	delete(s.days, key)
+	return true, nil
+}
+
+func (s *cycleStorage) ListCycleDays(ctx context.Context, profileID uuid.UUID, from, to time.Time) ([]storage.CycleDay, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]storage.CycleDay, 0)
+	for key, day := range s.days {
+		if key.profileID != profileID || !inDateRange(day.Date, from, to) {
+			continue
+		}
+		out = append(out, copyCycleDay(day))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Date.Before(out[j].Date)
+	})
+	return out, nil
+}
+
+func (s *cycleStorage) GetCycleSettings(ctx context.Context, profileID uuid.UUID) (storage.CycleSettings, bool, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings, ok := s.settings[profileID]
+	return settings, ok, nil
+}
+
+func (s *cycleStorage) UpsertCycleSettings(ctx context.Context, settings storage.CycleSettings) (storage.CycleSettings, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings.UpdatedAt = time.Now().UTC()
+	s.settings[settings.ProfileID] = settings
+	return settings, nil
+}
+
+// inDateRange reports whether date lies in [from, to]; zero bounds are open.
+func inDateRange(date, from, to time.Time) bool {
+	if !from.IsZero() && date.Before(from) {
+		return false
+	}
+	if !to.IsZero() && date.After(to) {
+		return false
+	}
+	return true
+}
+
+func copyCyclePeriod(period storage.CyclePeriod) storage.CyclePeriod {
+	if period.EndDate != nil {
+		end := *period.EndDate
+		period.EndDate = &end
+	}
+	return period
+}
+
+func copyCycleDay(day storage.CycleDay) storage.CycleDay {
+	day.Symptoms = append([]string(nil), day.Symptoms...)
+	return day
+}
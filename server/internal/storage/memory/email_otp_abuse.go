@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+)
+
+// EmailOTPAbuseMemoryStorage keeps the OTP abuse ledger in memory.
+type EmailOTPAbuseMemoryStorage struct {
+	mu      sync.RWMutex
+	records map[string]storage.EmailOTPAbuseRecord // key: "scope:key"
+	nonces  map[string]time.Time                   // used challenge nonce -> expiry
+}
+
+func NewEmailOTPAbuseMemoryStorage() *EmailOTPAbuseMemoryStorage {
+	return &EmailOTPAbuseMemoryStorage{
+		records: make(map[string]storage.EmailOTPAbuseRecord),
+		nonces:  make(map[string]time.Time),
+	}
+}
+
+func abuseRecordKey(scope, key string) string {
+	return scope + ":" + key
+}
+
+func (s *EmailOTPAbuseMemoryStorage) GetAbuseRecord(ctx context.Context, scope, key string) (*storage.EmailOTPAbuseRecord, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.records[abuseRecordKey(scope, key)]
+	if !ok {
+		return nil, nil
+	}
+	return copyAbuseRecord(rec), nil
+}
+
+func (s *EmailOTPAbuseMemoryStorage) UpsertAbuseRecord(ctx context.Context, rec storage.EmailOTPAbuseRecord) error {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[abuseRecordKey(rec.Scope, rec.Key)] = *copyAbuseRecord(rec)
+	return nil
+}
+
+func (s *EmailOTPAbuseMemoryStorage) DeleteAbuseRecord(ctx context.Context, scope, key string) (bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := abuseRecordKey(scope, key)
+	if _, ok := s.records[k]; !ok {
+		return false, nil
+	}
+	delete(s.records, k)
+	return true, nil
+}
+
+func (s *EmailOTPAbuseMemoryStorage) ListAbuseRecords(ctx context.Context, filter storage.EmailOTPAbuseFilter) ([]storage.EmailOTPAbuseRecord, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]storage.EmailOTPAbuseRecord, 0)
+	for _, rec := range s.records {
+		if filter.Scope != "" && rec.Scope != filter.Scope {
+			continue
+		}
+		if !filter.LockedAt.IsZero() && (rec.LockedUntil == nil || !rec.LockedUntil.After(filter.LockedAt)) {
+			continue
+		}
+		result = append(result, *copyAbuseRecord(rec))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastEventAt.After(result[j].LastEventAt)
+	})
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+func (s *EmailOTPAbuseMemoryStorage) UseChallengeNonce(ctx context.Context, nonce string, expiresAt, now time.Time) (bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for n, expiry := range s.nonces {
+		if !expiry.After(now) {
+			delete(s.nonces, n)
+		}
+	}
+	if _, used := s.nonces[nonce]; used {
+		return false, nil
+	}
+	s.nonces[nonce] = expiresAt
+	return true, nil
+}
+
+func copyAbuseRecord(rec storage.EmailOTPAbuseRecord) *storage.EmailOTPAbuseRecord {
+	if rec.LockedUntil != nil {
+		lockedUntil := *rec.LockedUntil
+		rec.LockedUntil = &lockedUntil
+	}
+	return &rec
+}
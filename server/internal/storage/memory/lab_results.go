@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+type labResultsStorage struct {
+	mu      sync.RWMutex
+	results map[uuid.UUID]storage.LabResult
+}
+
+func newLabResultsStorage() *labResultsStorage {
+	return &labResultsStorage{results: make(map[uuid.UUID]storage.LabResult)}
+}
+
+func (s *labResultsStorage) CreateLabResult(ctx context.Context, result storage.LabResult) (storage.LabResult, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if result.ID == uuid.Nil {
+		result.ID = uuid.New()
+	}
+	result.CreatedAt = time.Now().UTC()
+	result.UpdatedAt = result.CreatedAt
+	s.results[result.ID] = copyLabResult(result)
+	return copyLabResult(result), nil
+}
+
+func (s *labResultsStorage) GetLabResult(ctx context.Context, id uuid.UUID) (storage.LabResult, bool, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result, ok := s.results[id]
+	if !ok {
+		return storage.LabResult{}, false, nil
+	}
+	return copyLabResult(result), true, nil
+}
+
+func (s *labResultsStorage) UpdateLabResult(ctx context.Context, result storage.LabResult) (storage.LabResult, bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.results[result.ID]
+	if !ok {
+		return storage.LabResult{}, false, nil
+	}
+	existing.Analyte = result.Analyte
+	existing.Name = result.Name
+	existing.Value = result.Value
+	existing.Unit = result.Unit
+	existing.RefLow = result.RefLow
+	existing.RefHigh = result.RefHigh
+	existing.Flag = result.Flag
+	existing.TakenOn = result.TakenOn
+	existing.Note = result.Note
+	existing.UpdatedAt = time.Now().UTC()
+	s.results[existing.ID] = copyLabResult(existing)
+	return copyLabResult(existing), true, nil
+}
+
+func (s *labResultsStorage) DeleteLabResult(ctx context.Context, id uuid.UUID) (bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.results[id]; !ok {
+		return false, nil
+	}
+	delete(s.results, id)
+	return true, nil
+}
+
+func (s *labResultsStorage) ReplaceSourceLabResults(ctx context.Context, sourceID uuid.UUID, results []storage.LabResult) ([]storage.LabResult, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, result := range s.results {
+		if result.SourceID != nil && *result.SourceID == sourceID {
+			delete(s.results, id)
+		}
+	}
+
+	now := time.Now().UTC()
+	saved := make([]storage.LabResult, 0, len(results))
+	for _, result := range results {
+		if result.ID == uuid.Nil {
+			result.ID = uuid.New()
+		}
+		id := sourceID
+		result.SourceID = &id
+		result.CreatedAt = now
+		result.UpdatedAt = now
+		s.results[result.ID] = copyLabResult(result)
+		saved = append(saved, copyLabResult(result))
+	}
+	return saved, nil
+}
+
+func (s *labResultsStorage) ListSourceLabResults(ctx context.Context, sourceID uuid.UUID) ([]storage.LabResult, error) {
+	return s.list(func(result storage.LabResult) bool {
+		return result.SourceID != nil && *result.SourceID == sourceID
+	}), nil
+}
+
+func (s *labResultsStorage) ListLabResults(ctx context.Context, profileID uuid.UUID, analyte string, from, to time.Time) ([]storage.LabResult, error) {
+	return s.list(func(result storage.LabResult) bool {
+		if result.ProfileID != profileID || (analyte != "" && result.Analyte != analyte) {
+			return false
+		}
+		if !from.IsZero() && result.TakenOn.Before(from) {
+			return false
+		}
+		return to.IsZero() || !result.TakenOn.After(to)
+	}), nil
+}
+
+// list returns matching results ordered like the postgres queries.
+func (s *labResultsStorage) list(match func(storage.LabResult) bool) []storage.LabResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]storage.LabResult, 0)
+	for _, result := range s.results {
+		if match(result) {
+			out = append(out, copyLabResult(result))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].TakenOn.Equal(out[j].TakenOn) {
+			return out[i].TakenOn.Before(out[j].TakenOn)
+		}
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func copyLabResult(result storage.LabResult) storage.LabResult {
+	if result.SourceID != nil {
+		id := *result.SourceID
+		result.SourceID = &id
+	}
+	if result.RefLow != nil {
+		low := *result.RefLow
+		result.RefLow = &low
+	}
+	if result.RefHigh != nil {
+		high := *result.RefHigh
+		result.RefHigh = &high
+	}
+	return result
+}
@@ -0,0 +1,214 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+type medicationsStorage struct {
+	mu          sync.RWMutex
+	medications map[uuid.UUID]storage.Medication
+	doses       map[uuid.UUID]storage.MedicationDose
+}
+
+func newMedicationsStorage() *medicationsStorage {
+	return &medicationsStorage{
+		medications: make(map[uuid.UUID]storage.Medication),
+		doses:       make(map[uuid.UUID]storage.MedicationDose),
+	}
+}
+
+func (s *medicationsStorage) CreateMedication(ctx context.Context, medication storage.Medication) (storage.Medication, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if medication.ID == uuid.Nil {
+		medication.ID = uuid.New()
+	}
+	medication.CreatedAt = time.Now().UTC()
+	medication.UpdatedAt = medication.CreatedAt
+	s.medications[medication.ID] = copyMedication(medication)
+	return copyMedication(medication), nil
+}
+
+func (s *medicationsStorage) GetMedication(ctx context.Context, id uuid.UUID) (storage.Medication, bool, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	medication, ok := s.medications[id]
+	if !ok {
+		return storage.Medication{}, false, nil
+	}
+	return copyMedication(medication), true, nil
+}
+
+func (s *medicationsStorage) ListMedications(ctx context.Context, profileID uuid.UUID, activeOnly bool) ([]storage.Medication, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]storage.Medication, 0)
+	for _, medication := range s.medications {
+		if medication.ProfileID != profileID || (activeOnly && !medication.Active) {
+			continue
+		}
+		out = append(out, copyMedication(medication))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name)
+	})
+	return out, nil
+}
+
+func (s *medicationsStorage) UpdateMedication(ctx context.Context, medication storage.Medication, setStock bool) (storage.Medication, bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.medications[medication.ID]
+	if !ok {
+		return storage.Medication{}, false, nil
+	}
+	medication.ProfileID = existing.ProfileID
+	if !setStock {
+		medication.StockQuantity = existing.StockQuantity
+	}
+	medication.CreatedAt = existing.CreatedAt
+	medication.UpdatedAt = time.Now().UTC()
+	s.medications[medication.ID] = copyMedication(medication)
+	return copyMedication(medication), true, nil
+}
+
+func (s *medicationsStorage) DeleteMedication(ctx context.Context, id uuid.UUID) (bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.medications[id]; !ok {
+		return false, nil
+	}
+	delete(s.medications, id)
+	for doseID, dose := range s.doses {
+		if dose.MedicationID == id {
+			delete(s.doses, doseID)
+		}
+	}
+	return true, nil
+}
+
+func (s *medicationsStorage) AdjustMedicationStock(ctx context.Context, id uuid.UUID, delta float64) (storage.Medication, bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	medication, ok := s.medications[id]
+	if !ok {
+		return storage.Medication{}, false, nil
+	}
+	s.adjustStockLocked(&medication, delta)
+	return copyMedication(medication), true, nil
+}
+
+func (s *medicationsStorage) CreateMedicationDose(ctx context.Context, dose storage.MedicationDose) (storage.MedicationDose, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if dose.ID == uuid.Nil {
+		dose.ID = uuid.New()
+	}
+	dose.CreatedAt = time.Now().UTC()
+	dose.StockDeducted = 0
+	if medication, ok := s.medications[dose.MedicationID]; ok && dose.Status == "taken" && medication.StockQuantity != nil {
+		dose.StockDeducted = min(dose.Amount, *medication.StockQuantity)
+		s.adjustStockLocked(&medication, -dose.StockDeducted)
+	}
+	s.doses[dose.ID] = dose
+	return dose, nil
+}
+
+func (s *medicationsStorage) GetMedicationDose(ctx context.Context, id uuid.UUID) (storage.MedicationDose, bool, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dose, ok := s.doses[id]
+	return dose, ok, nil
+}
+
+func (s *medicationsStorage) DeleteMedicationDose(ctx context.Context, id uuid.UUID) (bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dose, ok := s.doses[id]
+	if !ok {
+		return false, nil
+	}
+	delete(s.doses, id)
+	if medication, ok := s.medications[dose.MedicationID]; ok && dose.StockDeducted > 0 {
+		s.adjustStockLocked(&medication, dose.StockDeducted)
+	}
+	return true, nil
+}
+
+func (s *medicationsStorage) ListMedicationDoses(ctx context.Context, profileID, medicationID uuid.UUID, from, to time.Time) ([]storage.MedicationDose, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]storage.MedicationDose, 0)
+	for _, dose := range s.doses {
+		if dose.ProfileID != profileID || (medicationID != uuid.Nil && dose.MedicationID != medicationID) {
+			continue
+		}
+		if dose.TakenAt.Before(from) || !dose.TakenAt.Before(to) {
+			continue
+		}
+		out = append(out, dose)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].TakenAt.Before(out[j].TakenAt)
+	})
+	return out, nil
+}
+
+// adjustStockLocked adds delta to a tracked stock, never going below zero.
+// The caller holds the write lock.
+func (s *medicationsStorage) adjustStockLocked(medication *storage.Medication, delta float64) {
+	if medication.StockQuantity == nil {
+		return
+	}
+	stock := max(*medication.StockQuantity+delta, 0)
+	medication.StockQuantity = &stock
+	medication.UpdatedAt = time.Now().UTC()
+	s.medications[medication.ID] = copyMedication(*medication)
+}
+
+func copyMedication(medication storage.Medication) storage.Medication {
+	medication.Ingredients = append([]string(nil), medication.Ingredients...)
+	medication.Schedule = append([]storage.MedicationTime(nil), medication.Schedule...)
+	if medication.StockQuantity != nil {
+		stock := *medication.StockQuantity
+		medication.StockQuantity = &stock
+	}
+	return medication
+}
@@ -27,6 +27,8 @@ type MemoryStorage struct {
 	schedules          *SupplementSchedulesMemoryStorage
 	intakes            *IntakesMemoryStorage
 	emailOTPs          *EmailOTPMemoryStorage
+	emailOTPAbuse      *EmailOTPAbuseMemoryStorage
+	audit              *AuditMemoryStorage
 	settings           *SettingsMemoryStorage
 	chat               *ChatMemoryStorage
 	proposals          *ProposalsMemoryStorage
@@ -36,6 +38,14 @@ type MemoryStorage struct {
 	nutritionTargets   *nutritionTargetsStorage
 	foodPrefs          *foodPrefsStorage
 	mealPlans          *mealPlansStorage
+	aiConsent          *aiConsentStorage
+	aiUsage            *aiUsageStorage
+	coaching           *coachingStorage
+	labResults         *labResultsStorage
+	symptoms           *symptomsStorage
+	cycle              *cycleStorage
+	medications        *medicationsStorage
+	search             *searchStorage
 }
 
 // New создаёт новый MemoryStorage с owner профилем по умолчанию
@@ -50,7 +60,7 @@ func New() *MemoryStorage {
 		UpdatedAt:   time.Now(),
 	}
 
-	return &MemoryStorage{
+	m := &MemoryStorage{
 		profiles: map[uuid.UUID]storage.Profile{
 			ownerID: owner,
 		},
@@ -63,6 +73,8 @@ func New() *MemoryStorage {
 		schedules:          NewSupplementSchedulesMemoryStorage(),
 		intakes:            NewIntakesMemoryStorage(),
 		emailOTPs:          NewEmailOTPMemoryStorage(),
+		emailOTPAbuse:      NewEmailOTPAbuseMemoryStorage(),
+		audit:              NewAuditMemoryStorage(),
 		settings:           NewSettingsMemoryStorage(),
 		chat:               NewChatMemoryStorage(),
 		proposals:          NewProposalsMemoryStorage(),
@@ -72,7 +84,16 @@ func New() *MemoryStorage {
 		nutritionTargets:   newNutritionTargetsStorage(),
 		foodPrefs:          newFoodPrefsStorage(),
 		mealPlans:          newMealPlansStorage(),
+		aiConsent:          newAIConsentStorage(),
+		aiUsage:            newAIUsageStorage(),
+		coaching:           newCoachingStorage(),
+		labResults:         newLabResultsStorage(),
+		symptoms:           newSymptomsStorage(),
+		cycle:              newCycleStorage(),
+		medications:        newMedicationsStorage(),
 	}
+	m.search = newSearchStorage(m.sources, m.checkins, m.chat)
+	return m
 }
 
 func (m *MemoryStorage) ListProfiles(ctx context.Context) ([]storage.Profile, error) {
@@ -313,6 +334,16 @@ func (m *MemoryStorage) UpdateResendMeta(ctx context.Context, id uuid.UUID, last
 	return m.emailOTPs.UpdateResendMeta(ctx, id, lastSentAt, sendCount)
 }
 
+// GetEmailOTPAbuseStorage returns the email OTP abuse ledger.
+func (m *MemoryStorage) GetEmailOTPAbuseStorage() storage.EmailOTPAbuseStorage {
+	return m.emailOTPAbuse
+}
+
+// GetAuditStorage returns audit log storage.
+func (m *MemoryStorage) GetAuditStorage() storage.AuditStorage {
+	return m.audit
+}
+
 // GetSettingsStorage returns settings storage.
 func (m *MemoryStorage) GetSettingsStorage() *SettingsMemoryStorage {
 	return m.settings
@@ -339,14 +370,50 @@ func (m *MemoryStorage) UpsertSettings(ctx context.Context, ownerUserID string,
 }
 
 // ChatStorage methods - delegate to embedded chat storage.
-func (m *MemoryStorage) InsertMessage(ctx context.Context, ownerUserID string, profileID uuid.UUID, role, content string) (storage.ChatMessage, error) {
-	return m.chat.InsertMessage(ctx, ownerUserID, profileID, role, content)
+func (m *MemoryStorage) InsertMessage(ctx context.Context, ownerUserID string, profileID, threadID uuid.UUID, role, content string) (storage.ChatMessage, error) {
+	return m.chat.InsertMessage(ctx, ownerUserID, profileID, threadID, role, content)
 }
 
 func (m *MemoryStorage) ListMessages(ctx context.Context, ownerUserID string, profileID uuid.UUID, limit int, before *time.Time) ([]storage.ChatMessage, *time.Time, error) {
 	return m.chat.ListMessages(ctx, ownerUserID, profileID, limit, before)
 }
 
+func (m *MemoryStorage) ListThreadMessages(ctx context.Context, ownerUserID string, threadID uuid.UUID, limit int, before *time.Time) ([]storage.ChatMessage, *time.Time, error) {
+	return m.chat.ListThreadMessages(ctx, ownerUserID, threadID, limit, before)
+}
+
+func (m *MemoryStorage) CreateThread(ctx context.Context, ownerUserID string, profileID uuid.UUID, title string) (storage.ChatThread, error) {
+	return m.chat.CreateThread(ctx, ownerUserID, profileID, title)
+}
+
+func (m *MemoryStorage) GetThread(ctx context.Context, ownerUserID string, threadID uuid.UUID) (storage.ChatThread, bool, error) {
+	return m.chat.GetThread(ctx, ownerUserID, threadID)
+}
+
+func (m *MemoryStorage) ListThreads(ctx context.Context, ownerUserID string, profileID uuid.UUID, includeArchived bool) ([]storage.ChatThread, error) {
+	return m.chat.ListThreads(ctx, ownerUserID, profileID, includeArchived)
+}
+
+func (m *MemoryStorage) UpdateThread(ctx context.Context, ownerUserID string, threadID uuid.UUID, title *string, archived *bool) (storage.ChatThread, bool, error) {
+	return m.chat.UpdateThread(ctx, ownerUserID, threadID, title, archived)
+}
+
+func (m *MemoryStorage) SaveThreadSummary(ctx context.Context, ownerUserID string, threadID uuid.UUID, summary string, summarizedUntil time.Time) error {
+	return m.chat.SaveThreadSummary(ctx, ownerUserID, threadID, summary, summarizedUntil)
+}
+
+func (m *MemoryStorage) InsertFact(ctx context.Context, ownerUserID string, profileID uuid.UUID, content string) (storage.ChatFact, error) {
+	return m.chat.InsertFact(ctx, ownerUserID, profileID, content)
+}
+
+func (m *MemoryStorage) ListFacts(ctx context.Context, ownerUserID string, profileID uuid.UUID) ([]storage.ChatFact, error) {
+	return m.chat.ListFacts(ctx, ownerUserID, profileID)
+}
+
+func (m *MemoryStorage) DeleteFact(ctx context.Context, ownerUserID string, factID uuid.UUID) (bool, error) {
+	return m.chat.DeleteFact(ctx, ownerUserID, factID)
+}
+
 // ProposalsStorage methods - delegate to embedded proposals storage.
 func (m *MemoryStorage) InsertMany(ctx context.Context, ownerUserID string, profileID uuid.UUID, drafts []storage.ProposalDraft) ([]storage.AIProposal, error) {
 	return m.proposals.InsertMany(ctx, ownerUserID, profileID, drafts)
@@ -356,12 +423,40 @@ func (m *MemoryStorage) Get(ctx context.Context, ownerUserID string, proposalID
 	return m.proposals.Get(ctx, ownerUserID, proposalID)
 }
 
-func (m *MemoryStorage) UpdateStatus(ctx context.Context, ownerUserID string, proposalID uuid.UUID, status string) error {
-	return m.proposals.UpdateStatus(ctx, ownerUserID, proposalID, status)
+func (m *MemoryStorage) UpdateStatus(ctx context.Context, ownerUserID string, proposalID uuid.UUID, from, status string) (bool, error) {
+	return m.proposals.UpdateStatus(ctx, ownerUserID, proposalID, from, status)
+}
+
+func (m *MemoryStorage) ClaimPending(ctx context.Context, ownerUserID string, proposalID uuid.UUID) (bool, error) {
+	return m.proposals.ClaimPending(ctx, ownerUserID, proposalID)
+}
+
+func (m *MemoryStorage) ReleaseClaim(ctx context.Context, ownerUserID string, proposalID uuid.UUID) error {
+	return m.proposals.ReleaseClaim(ctx, ownerUserID, proposalID)
+}
+
+func (m *MemoryStorage) MarkApplied(ctx context.Context, ownerUserID string, proposalID uuid.UUID, snapshot []byte) (storage.AIProposal, bool, error) {
+	return m.proposals.MarkApplied(ctx, ownerUserID, proposalID, snapshot)
+}
+
+func (m *MemoryStorage) List(ctx context.Context, ownerUserID string, profileID uuid.UUID, statuses []string, limit int) ([]storage.AIProposal, error) {
+	return m.proposals.List(ctx, ownerUserID, profileID, statuses, limit)
+}
+
+func (m *MemoryStorage) SupersedePending(ctx context.Context, ownerUserID string, profileID uuid.UUID, kind string, keep []uuid.UUID) (int64, error) {
+	return m.proposals.SupersedePending(ctx, ownerUserID, profileID, kind, keep)
+}
+
+func (m *MemoryStorage) ExpirePending(ctx context.Context, now time.Time) (int64, error) {
+	return m.proposals.ExpirePending(ctx, now)
 }
 
-func (m *MemoryStorage) List(ctx context.Context, ownerUserID string, profileID uuid.UUID, status string, limit int) ([]storage.AIProposal, error) {
-	return m.proposals.List(ctx, ownerUserID, profileID, status, limit)
+func (m *MemoryStorage) MarkViewed(ctx context.Context, ownerUserID string, proposalID uuid.UUID) error {
+	return m.proposals.MarkViewed(ctx, ownerUserID, proposalID)
+}
+
+func (m *MemoryStorage) ListEvents(ctx context.Context, ownerUserID string, proposalID uuid.UUID) ([]storage.AIProposalEvent, error) {
+	return m.proposals.ListEvents(ctx, ownerUserID, proposalID)
 }
 
 // IntakesStorage methods - delegate to embedded intakes storage
@@ -438,6 +533,10 @@ func (m *MemoryStorage) UpsertActivePlan(ownerUserID string, profileID uuid.UUID
 	return m.workoutPlans.UpsertActivePlan(ownerUserID, profileID, title, goal)
 }
 
+func (m *MemoryStorage) DeactivateActivePlan(ownerUserID string, profileID uuid.UUID) error {
+	return m.workoutPlans.DeactivateActivePlan(ownerUserID, profileID)
+}
+
 // WorkoutPlanItemsStorage methods - delegate to embedded workout plan items storage.
 
 func (m *MemoryStorage) ListItems(ownerUserID string, profileID uuid.UUID, planID uuid.UUID) ([]storage.WorkoutPlanItem, error) {
@@ -473,3 +572,43 @@ func (m *MemoryStorage) GetFoodPrefsStorage() storage.FoodPrefsStorage {
 func (m *MemoryStorage) GetMealPlansStorage() storage.MealPlansStorage {
 	return m.mealPlans
 }
+
+// GetAIConsentStorage returns AI consent storage.
+func (m *MemoryStorage) GetAIConsentStorage() storage.AIConsentStorage {
+	return m.aiConsent
+}
+
+// GetAIUsageStorage returns AI token usage storage.
+func (m *MemoryStorage) GetAIUsageStorage() storage.AIUsageStorage {
+	return m.aiUsage
+}
+
+// GetCoachingStorage returns coaching programs storage.
+func (m *MemoryStorage) GetCoachingStorage() storage.CoachingStorage {
+	return m.coaching
+}
+
+// GetLabResultsStorage returns lab results storage.
+func (m *MemoryStorage) GetLabResultsStorage() storage.LabResultsStorage {
+	return m.labResults
+}
+
+// GetSymptomsStorage returns symptom log storage.
+func (m *MemoryStorage) GetSymptomsStorage() storage.SymptomsStorage {
+	return m.symptoms
+}
+
+// GetCycleStorage returns menstrual cycle storage.
+func (m *MemoryStorage) GetCycleStorage() storage.CycleStorage {
+	return m.cycle
+}
+
+// GetMedicationsStorage returns medication and dose log storage.
+func (m *MemoryStorage) GetMedicationsStorage() storage.MedicationsStorage {
+	return m.medications
+}
+
+// GetSearchStorage returns full-text search storage.
+func (m *MemoryStorage) GetSearchStorage() storage.SearchStorage {
+	return m.search
+}
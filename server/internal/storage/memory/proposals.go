@@ -14,11 +14,13 @@ import (
 type ProposalsMemoryStorage struct {
 	mu        sync.RWMutex
 	proposals []storage.AIProposal
+	events    map[uuid.UUID][]storage.AIProposalEvent
 }
 
 func NewProposalsMemoryStorage() *ProposalsMemoryStorage {
 	return &ProposalsMemoryStorage{
 		proposals: make([]storage.AIProposal, 0),
+		events:    make(map[uuid.UUID][]storage.AIProposalEvent),
 	}
 }
 
@@ -42,6 +44,7 @@ func (s *ProposalsMemoryStorage) InsertMany(ctx context.Context, ownerUserID str
 			Title:       strings.TrimSpace(draft.Title),
 			Summary:     strings.TrimSpace(draft.Summary),
 			Payload:     draft.Payload,
+			ExpiresAt:   draft.ExpiresAt,
 		}
 		if proposal.Title == "" {
 			proposal.Title = "Предложение"
@@ -51,6 +54,7 @@ func (s *ProposalsMemoryStorage) InsertMany(ctx context.Context, ownerUserID str
 		}
 
 		s.proposals = append(s.proposals, proposal)
+		s.addEvent(proposal.ID, "created", now)
 		saved = append(saved, proposal)
 	}
 	return saved, nil
@@ -71,28 +75,87 @@ func (s *ProposalsMemoryStorage) Get(ctx context.Context, ownerUserID string, pr
 	return storage.AIProposal{}, false, nil
 }
 
-func (s *ProposalsMemoryStorage) UpdateStatus(ctx context.Context, ownerUserID string, proposalID uuid.UUID, status string) error {
+func (s *ProposalsMemoryStorage) UpdateStatus(ctx context.Context, ownerUserID string, proposalID uuid.UUID, from, status string) (bool, error) {
 	_ = ctx
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	ownerUserID = strings.TrimSpace(ownerUserID)
 	status = normalizeProposalStatus(status)
+	p := s.find(ownerUserID, proposalID, strings.TrimSpace(from))
+	if p == nil {
+		return false, nil
+	}
+	p.Status = status
+	s.addEvent(proposalID, status, time.Now().UTC())
+	return true, nil
+}
+
+func (s *ProposalsMemoryStorage) ClaimPending(ctx context.Context, ownerUserID string, proposalID uuid.UUID) (bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.find(ownerUserID, proposalID, "pending")
+	if p == nil {
+		return false, nil
+	}
+	p.Status = "applying"
+	return true, nil
+}
+
+func (s *ProposalsMemoryStorage) ReleaseClaim(ctx context.Context, ownerUserID string, proposalID uuid.UUID) error {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p := s.find(ownerUserID, proposalID, "applying"); p != nil {
+		p.Status = "pending"
+	}
+	return nil
+}
+
+// find returns the proposal of owner with the given status. Callers hold
+// the lock.
+func (s *ProposalsMemoryStorage) find(ownerUserID string, proposalID uuid.UUID, status string) *storage.AIProposal {
+	ownerUserID = strings.TrimSpace(ownerUserID)
 	for i := range s.proposals {
-		if s.proposals[i].OwnerUserID == ownerUserID && s.proposals[i].ID == proposalID {
-			s.proposals[i].Status = status
-			return nil
+		p := &s.proposals[i]
+		if p.OwnerUserID == ownerUserID && p.ID == proposalID && p.Status == status {
+			return p
 		}
 	}
-	return ErrNotFound
+	return nil
 }
 
-func (s *ProposalsMemoryStorage) List(ctx context.Context, ownerUserID string, profileID uuid.UUID, status string, limit int) ([]storage.AIProposal, error) {
+func (s *ProposalsMemoryStorage) MarkApplied(ctx context.Context, ownerUserID string, proposalID uuid.UUID, snapshot []byte) (storage.AIProposal, bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.find(ownerUserID, proposalID, "applying")
+	if p == nil {
+		return storage.AIProposal{}, false, nil
+	}
+	now := time.Now().UTC()
+	p.Status = "applied"
+	p.AppliedAt = &now
+	p.Snapshot = snapshot
+	s.addEvent(p.ID, "applied", now)
+	return *p, true, nil
+}
+
+func (s *ProposalsMemoryStorage) List(ctx context.Context, ownerUserID string, profileID uuid.UUID, statuses []string, limit int) ([]storage.AIProposal, error) {
 	_ = ctx
 
 	ownerUserID = strings.TrimSpace(ownerUserID)
-	status = strings.TrimSpace(status)
+	wanted := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		wanted[strings.TrimSpace(status)] = true
+	}
 	if limit <= 0 {
 		limit = 50
 	}
@@ -105,7 +168,7 @@ func (s *ProposalsMemoryStorage) List(ctx context.Context, ownerUserID string, p
 		if proposal.OwnerUserID != ownerUserID || proposal.ProfileID != profileID {
 			continue
 		}
-		if status != "" && proposal.Status != status {
+		if len(wanted) > 0 && !wanted[proposal.Status] {
 			continue
 		}
 		filtered = append(filtered, proposal)
@@ -125,9 +188,100 @@ func (s *ProposalsMemoryStorage) List(ctx context.Context, ownerUserID string, p
 	return filtered[:limit], nil
 }
 
+func (s *ProposalsMemoryStorage) SupersedePending(ctx context.Context, ownerUserID string, profileID uuid.UUID, kind string, keep []uuid.UUID) (int64, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ownerUserID = strings.TrimSpace(ownerUserID)
+	kept := make(map[uuid.UUID]bool, len(keep))
+	for _, id := range keep {
+		kept[id] = true
+	}
+
+	now := time.Now().UTC()
+	var superseded int64
+	for i := range s.proposals {
+		p := &s.proposals[i]
+		if p.OwnerUserID != ownerUserID || p.ProfileID != profileID || p.Kind != kind || p.Status != "pending" || kept[p.ID] {
+			continue
+		}
+		p.Status = "superseded"
+		s.addEvent(p.ID, "superseded", now)
+		superseded++
+	}
+	return superseded, nil
+}
+
+func (s *ProposalsMemoryStorage) ExpirePending(ctx context.Context, now time.Time) (int64, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired int64
+	for i := range s.proposals {
+		p := &s.proposals[i]
+		if p.Status != "pending" || p.ExpiresAt == nil || p.ExpiresAt.After(now) {
+			continue
+		}
+		p.Status = "expired"
+		s.addEvent(p.ID, "expired", now.UTC())
+		expired++
+	}
+	return expired, nil
+}
+
+func (s *ProposalsMemoryStorage) MarkViewed(ctx context.Context, ownerUserID string, proposalID uuid.UUID) error {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ownerUserID = strings.TrimSpace(ownerUserID)
+	for _, proposal := range s.proposals {
+		if proposal.OwnerUserID != ownerUserID || proposal.ID != proposalID {
+			continue
+		}
+		for _, event := range s.events[proposalID] {
+			if event.Event == "viewed" {
+				return nil
+			}
+		}
+		s.addEvent(proposalID, "viewed", time.Now().UTC())
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (s *ProposalsMemoryStorage) ListEvents(ctx context.Context, ownerUserID string, proposalID uuid.UUID) ([]storage.AIProposalEvent, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ownerUserID = strings.TrimSpace(ownerUserID)
+	for _, proposal := range s.proposals {
+		if proposal.OwnerUserID == ownerUserID && proposal.ID == proposalID {
+			return append([]storage.AIProposalEvent(nil), s.events[proposalID]...), nil
+		}
+	}
+	return []storage.AIProposalEvent{}, nil
+}
+
+// addEvent appends to a proposal's history. Callers hold s.mu.
+func (s *ProposalsMemoryStorage) addEvent(proposalID uuid.UUID, event string, at time.Time) {
+	s.events[proposalID] = append(s.events[proposalID], storage.AIProposalEvent{
+		ProposalID: proposalID,
+		Event:      event,
+		CreatedAt:  at,
+	})
+}
+
 func normalizeProposalKind(kind string) string {
 	switch strings.TrimSpace(kind) {
-	case "settings_update", "vitamins_schedule", "workout_plan", "nutrition_plan", "generic":
+	case "settings_update", "vitamins_schedule", "workout_plan", "nutrition_plan", "meal_plan", "memory", "coaching_program", "coaching_adjustment", "generic":
 		return strings.TrimSpace(kind)
 	default:
 		return "generic"
@@ -136,7 +290,7 @@ func normalizeProposalKind(kind string) string {
 
 func normalizeProposalStatus(status string) string {
 	switch strings.TrimSpace(status) {
-	case "pending", "applied", "rejected":
+	case "pending", "applied", "rejected", "undone", "expired", "superseded":
 		return strings.TrimSpace(status)
 	default:
 		return "pending"
@@ -0,0 +1,161 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/checkins"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/textsearch"
+	"github.com/google/uuid"
+)
+
+// searchStorage answers unified search from one inverted index that the
+// sources, checkins and chat storages keep up to date on every write.
+type searchStorage struct {
+	index    *textsearch.Index
+	sources  *SourcesMemoryStorage
+	checkins *CheckinsMemoryStorage
+	chat     *ChatMemoryStorage
+}
+
+func newSearchStorage(sources *SourcesMemoryStorage, checkins *CheckinsMemoryStorage, chat *ChatMemoryStorage) *searchStorage {
+	index := textsearch.NewIndex()
+	sources.index = index
+	checkins.index = index
+	chat.index = index
+	return &searchStorage{index: index, sources: sources, checkins: checkins, chat: chat}
+}
+
+func (s *searchStorage) Search(ctx context.Context, query storage.SearchQuery) ([]storage.SearchHit, error) {
+	q := textsearch.ParseQuery(query.Text)
+	var hits []storage.SearchHit
+	for _, match := range s.index.Search(q) {
+		docType, id, ok := parseSearchKey(match.Key)
+		if !ok || (len(query.Types) > 0 && !slices.Contains(query.Types, docType)) {
+			continue
+		}
+		hit, ok := s.hit(docType, id, query, q)
+		if !ok || !inSearchRange(hit.Date, query.From, query.To) {
+			continue
+		}
+		hit.Rank = match.Score
+		hits = append(hits, hit)
+	}
+
+	if query.Offset >= len(hits) {
+		return nil, nil
+	}
+	hits = hits[query.Offset:]
+	if query.Limit > 0 && len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+	return hits, nil
+}
+
+// hit loads an indexed document; false when it is gone or belongs to
+// another profile or, for chat messages, another owner.
+func (s *searchStorage) hit(docType string, id uuid.UUID, query storage.SearchQuery, q textsearch.Query) (storage.SearchHit, bool) {
+	profileID := query.ProfileID
+	hit := storage.SearchHit{Type: docType, ID: id}
+	switch docType {
+	case storage.SearchTypeSource:
+		src, err := s.sources.GetSource(context.Background(), id)
+		if err != nil || src.ProfileID != profileID {
+			return hit, false
+		}
+		hit.Kind = src.Kind
+		hit.Title = src.Title
+		hit.Snippet = textsearch.Highlight(sourceSnippetText(src), q, textsearch.SnippetWords)
+		hit.Date = src.CreatedAt
+	case storage.SearchTypeCheckin:
+		c, err := s.checkins.GetCheckin(id)
+		if err != nil || c.ProfileID != profileID {
+			return hit, false
+		}
+		hit.Kind = c.Type
+		hit.Snippet = textsearch.Highlight(checkinText(*c), q, textsearch.SnippetWords)
+		hit.Date, _ = time.Parse("2006-01-02", c.Date)
+	case storage.SearchTypeChatMessage:
+		msg, ok := s.chat.message(id)
+		if !ok || msg.ProfileID != profileID || msg.OwnerUserID != query.OwnerUserID {
+			return hit, false
+		}
+		threadID := msg.ThreadID
+		hit.Kind = msg.Role
+		hit.ThreadID = &threadID
+		hit.Snippet = textsearch.Highlight(msg.Content, q, textsearch.SnippetWords)
+		hit.Date = msg.CreatedAt
+	default:
+		return hit, false
+	}
+	return hit, true
+}
+
+func (s *ChatMemoryStorage) message(id uuid.UUID) (storage.ChatMessage, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, msg := range s.messages {
+		if msg.ID == id {
+			return msg, true
+		}
+	}
+	return storage.ChatMessage{}, false
+}
+
+func searchKey(docType string, id uuid.UUID) string {
+	return docType + ":" + id.String()
+}
+
+func parseSearchKey(key string) (string, uuid.UUID, bool) {
+	docType, rawID, ok := strings.Cut(key, ":")
+	if !ok {
+		return "", uuid.Nil, false
+	}
+	id, err := uuid.Parse(rawID)
+	return docType, id, err == nil
+}
+
+// inSearchRange checks a document date against the inclusive days [from, to].
+func inSearchRange(date, from, to time.Time) bool {
+	if !from.IsZero() && date.Before(from) {
+		return false
+	}
+	return to.IsZero() || date.Before(to.AddDate(0, 0, 1))
+}
+
+func sourceTitle(src *storage.Source) string {
+	if src.Title == nil {
+		return ""
+	}
+	return *src.Title
+}
+
+func sourceText(src *storage.Source) string {
+	if src.Text == nil {
+		return ""
+	}
+	return *src.Text
+}
+
+// sourceSnippetText is the text a source snippet is cut from: its text, or
+// its title when it has none.
+func sourceSnippetText(src *storage.Source) string {
+	if text := sourceText(src); text != "" {
+		return text
+	}
+	return sourceTitle(src)
+}
+
+// checkinText is the text a checkin snippet is cut from: the note and the
+// tags. Tags are indexed with the weight of a title, as in Postgres.
+func checkinText(c checkins.Checkin) string {
+	return strings.TrimSpace(c.Note + "\n" + checkinTags(c))
+}
+
+func checkinTags(c checkins.Checkin) string {
+	return strings.Join(c.Tags, ", ")
+}
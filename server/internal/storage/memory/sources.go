@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/textsearch"
 	"github.com/google/uuid"
 )
 
@@ -19,9 +20,12 @@ var (
 
 // SourcesMemoryStorage — in-memory реализация SourcesStorage
 type SourcesMemoryStorage struct {
-	mu      sync.RWMutex
-	sources map[uuid.UUID]storage.Source
-	blobs   map[uuid.UUID]blobData // For image sources in memory mode
+	mu       sync.RWMutex
+	sources  map[uuid.UUID]storage.Source
+	blobs    map[uuid.UUID]blobData // For image sources in memory mode
+	uploads  map[uuid.UUID]storage.SourceUpload
+	archives map[uuid.UUID]string // archived text of link pages
+	index    *textsearch.Index    // set by New for unified search
 }
 
 type blobData struct {
@@ -32,8 +36,10 @@ type blobData struct {
 // NewSourcesMemoryStorage создаёт новый SourcesMemoryStorage
 func NewSourcesMemoryStorage() *SourcesMemoryStorage {
 	return &SourcesMemoryStorage{
-		sources: make(map[uuid.UUID]storage.Source),
-		blobs:   make(map[uuid.UUID]blobData),
+		sources:  make(map[uuid.UUID]storage.Source),
+		blobs:    make(map[uuid.UUID]blobData),
+		uploads:  make(map[uuid.UUID]storage.SourceUpload),
+		archives: make(map[uuid.UUID]string),
 	}
 }
 
@@ -50,6 +56,7 @@ func (s *SourcesMemoryStorage) CreateSource(ctx context.Context, source *storage
 	source.UpdatedAt = now
 
 	s.sources[source.ID] = *source
+	s.index.Put(searchKey(storage.SearchTypeSource, source.ID), sourceTitle(source), sourceText(source))
 
 	return nil
 }
@@ -136,6 +143,8 @@ func (s *SourcesMemoryStorage) DeleteSource(ctx context.Context, id uuid.UUID) e
 
 	delete(s.sources, id)
 	delete(s.blobs, id) // Also delete blob if exists
+	delete(s.archives, id)
+	s.index.Remove(searchKey(storage.SearchTypeSource, id))
 
 	return nil
 }
@@ -163,3 +172,201 @@ func (s *SourcesMemoryStorage) PutSourceBlob(ctx context.Context, sourceID uuid.
 
 	return nil
 }
+
+func (s *SourcesMemoryStorage) SaveLinkPreview(ctx context.Context, source *storage.Source, archive *string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, ok := s.sources[source.ID]
+	if !ok {
+		return false, nil
+	}
+	var preview *storage.LinkPreview
+	if source.Preview != nil {
+		copied := *source.Preview
+		copied.Archived = archive != nil
+		preview = &copied
+	}
+	src.Preview = preview
+	src.ObjectKey = source.ObjectKey
+	src.ContentType = source.ContentType
+	src.SizeBytes = source.SizeBytes
+	src.ThumbnailSizes = source.ThumbnailSizes
+	src.UpdatedAt = time.Now()
+	s.sources[src.ID] = src
+
+	if archive != nil {
+		s.archives[src.ID] = *archive
+	} else {
+		delete(s.archives, src.ID)
+	}
+	return true, nil
+}
+
+func (s *SourcesMemoryStorage) SetLinkPreviewStatus(ctx context.Context, id uuid.UUID, status string, errorMessage *string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, ok := s.sources[id]
+	if !ok {
+		return false, nil
+	}
+	preview := storage.LinkPreview{}
+	if src.Preview != nil {
+		preview = *src.Preview
+	}
+	preview.Status = status
+	preview.Error = errorMessage
+	src.Preview = &preview
+	src.UpdatedAt = time.Now()
+	s.sources[id] = src
+	return true, nil
+}
+
+func (s *SourcesMemoryStorage) ListPendingLinkPreviews(ctx context.Context, limit int) ([]storage.Source, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var pending []storage.Source
+	for _, src := range s.sources {
+		if src.Preview != nil && src.Preview.Status == "pending" {
+			pending = append(pending, src)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+	if limit > 0 && len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+func (s *SourcesMemoryStorage) GetSourceArchive(ctx context.Context, id uuid.UUID) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	text, ok := s.archives[id]
+	return text, ok, nil
+}
+
+func (s *SourcesMemoryStorage) SaveTranscript(ctx context.Context, id uuid.UUID, text *string, transcription storage.Transcription) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, ok := s.sources[id]
+	if !ok {
+		return false, nil
+	}
+	src.Text = text
+	src.Transcription = &transcription
+	src.UpdatedAt = time.Now()
+	s.sources[id] = src
+	s.index.Put(searchKey(storage.SearchTypeSource, id), sourceTitle(&src), sourceText(&src))
+	return true, nil
+}
+
+func (s *SourcesMemoryStorage) SetTranscriptionStatus(ctx context.Context, id uuid.UUID, status string, errorMessage *string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, ok := s.sources[id]
+	if !ok {
+		return false, nil
+	}
+	transcription := storage.Transcription{}
+	if src.Transcription != nil {
+		transcription = *src.Transcription
+	}
+	transcription.Status = status
+	transcription.Error = errorMessage
+	src.Transcription = &transcription
+	src.UpdatedAt = time.Now()
+	s.sources[id] = src
+	return true, nil
+}
+
+func (s *SourcesMemoryStorage) ListPendingTranscriptions(ctx context.Context, limit int) ([]storage.Source, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var pending []storage.Source
+	for _, src := range s.sources {
+		if src.Transcription != nil && src.Transcription.Status == "pending" {
+			pending = append(pending, src)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+	if limit > 0 && len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+func (s *SourcesMemoryStorage) CreateSourceUpload(ctx context.Context, upload storage.SourceUpload) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if upload.CreatedAt.IsZero() {
+		upload.CreatedAt = time.Now()
+	}
+	s.uploads[upload.ID] = upload
+
+	return nil
+}
+
+func (s *SourcesMemoryStorage) GetSourceUpload(ctx context.Context, id uuid.UUID) (storage.SourceUpload, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	upload, ok := s.uploads[id]
+	return upload, ok, nil
+}
+
+func (s *SourcesMemoryStorage) CompleteSourceUpload(ctx context.Context, uploadID uuid.UUID, source *storage.Source) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.uploads[uploadID]; !ok {
+		return false, nil
+	}
+	delete(s.uploads, uploadID)
+
+	now := time.Now()
+	source.CreatedAt = now
+	source.UpdatedAt = now
+	s.sources[source.ID] = *source
+	s.index.Put(searchKey(storage.SearchTypeSource, source.ID), sourceTitle(source), sourceText(source))
+
+	return true, nil
+}
+
+func (s *SourcesMemoryStorage) DeleteSourceUpload(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.uploads, id)
+	return nil
+}
+
+func (s *SourcesMemoryStorage) ListExpiredSourceUploads(ctx context.Context, before time.Time, limit int) ([]storage.SourceUpload, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expired := make([]storage.SourceUpload, 0)
+	for _, upload := range s.uploads {
+		if upload.ExpiresAt.Before(before) {
+			expired = append(expired, upload)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].ExpiresAt.Before(expired[j].ExpiresAt)
+	})
+	if limit > 0 && len(expired) > limit {
+		expired = expired[:limit]
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+)
+
+type symptomsStorage struct {
+	mu       sync.RWMutex
+	symptoms map[uuid.UUID]storage.Symptom
+}
+
+func newSymptomsStorage() *symptomsStorage {
+	return &symptomsStorage{symptoms: make(map[uuid.UUID]storage.Symptom)}
+}
+
+func (s *symptomsStorage) CreateSymptom(ctx context.Context, symptom storage.Symptom) (storage.Symptom, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if symptom.ID == uuid.Nil {
+		symptom.ID = uuid.New()
+	}
+	symptom.CreatedAt = time.Now().UTC()
+	symptom.UpdatedAt = symptom.CreatedAt
+	s.symptoms[symptom.ID] = copySymptom(symptom)
+	return copySymptom(symptom), nil
+}
+
+func (s *symptomsStorage) GetSymptom(ctx context.Context, id uuid.UUID) (storage.Symptom, bool, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	symptom, ok := s.symptoms[id]
+	if !ok {
+		return storage.Symptom{}, false, nil
+	}
+	return copySymptom(symptom), true, nil
+}
+
+func (s *symptomsStorage) UpdateSymptom(ctx context.Context, symptom storage.Symptom) (storage.Symptom, bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.symptoms[symptom.ID]
+	if !ok {
+		return storage.Symptom{}, false, nil
+	}
+	existing.Name = symptom.Name
+	existing.Severity = symptom.Severity
+	existing.StartedAt = symptom.StartedAt
+	existing.EndedAt = symptom.EndedAt
+	existing.Note = symptom.Note
+	existing.UpdatedAt = time.Now().UTC()
+	s.symptoms[existing.ID] = copySymptom(existing)
+	return copySymptom(existing), true, nil
+}
+
+func (s *symptomsStorage) DeleteSymptom(ctx context.Context, id uuid.UUID) (bool, error) {
+	_ = ctx
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.symptoms[id]; !ok {
+		return false, nil
+	}
+	delete(s.symptoms, id)
+	return true, nil
+}
+
+func (s *symptomsStorage) ListSymptoms(ctx context.Context, profileID uuid.UUID, name string, from, to time.Time) ([]storage.Symptom, error) {
+	_ = ctx
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]storage.Symptom, 0)
+	for _, symptom := range s.symptoms {
+		if symptom.ProfileID != profileID || (name != "" && !strings.EqualFold(symptom.Name, name)) {
+			continue
+		}
+		if !from.IsZero() && symptom.StartedAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !symptom.StartedAt.Before(to) {
+			continue
+		}
+		out = append(out, copySymptom(symptom))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].StartedAt.Equal(out[j].StartedAt) {
+			return out[i].StartedAt.Before(out[j].StartedAt)
+		}
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+func copySymptom(symptom storage.Symptom) storage.Symptom {
+	if symptom.CheckinID != nil {
+		id := *symptom.CheckinID
+		symptom.CheckinID = &id
+	}
+	if symptom.EndedAt != nil {
+		ended := *symptom.EndedAt
+		symptom.EndedAt = &ended
+	}
+	return symptom
+}
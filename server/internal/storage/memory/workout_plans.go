@@ -79,3 +79,19 @@ func (s *WorkoutPlansStorage) UpsertActivePlan(ownerUserID string, profileID uui
 
 	return plan, nil
 }
+
+func (s *WorkoutPlansStorage) DeactivateActivePlan(ownerUserID string, profileID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := ownerUserID + ":" + profileID.String()
+	if existingID, ok := s.activeIndex[key]; ok {
+		if existing, found := s.plans[existingID]; found {
+			existing.IsActive = false
+			existing.UpdatedAt = time.Now()
+			s.plans[existingID] = existing
+		}
+		delete(s.activeIndex, key)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type aiConsentStorage struct {
+	pool *pgxpool.Pool
+}
+
+func newAIConsentStorage(pool *pgxpool.Pool) *aiConsentStorage {
+	return &aiConsentStorage{pool: pool}
+}
+
+func (s *aiConsentStorage) GetAIConsent(ctx context.Context, ownerUserID string) (storage.AIConsent, bool, error) {
+	const query = `
+		SELECT id, owner_user_id, policy_version, granted_at, revoked_at
+		FROM ai_consents
+		WHERE owner_user_id = $1
+		ORDER BY granted_at DESC
+		LIMIT 1
+	`
+
+	var row storage.AIConsent
+	err := s.pool.QueryRow(ctx, query, strings.TrimSpace(ownerUserID)).Scan(
+		&row.ID,
+		&row.OwnerUserID,
+		&row.PolicyVersion,
+		&row.GrantedAt,
+		&row.RevokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.AIConsent{}, false, nil
+		}
+		return storage.AIConsent{}, false, err
+	}
+	return row, true, nil
+}
+
+func (s *aiConsentStorage) GrantAIConsent(ctx context.Context, ownerUserID, policyVersion string) (storage.AIConsent, error) {
+	ownerUserID = strings.TrimSpace(ownerUserID)
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return storage.AIConsent{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE ai_consents SET revoked_at = NOW()
+		WHERE owner_user_id = $1 AND revoked_at IS NULL
+	`, ownerUserID); err != nil {
+		return storage.AIConsent{}, err
+	}
+
+	row := storage.AIConsent{
+		ID:            uuid.New(),
+		OwnerUserID:   ownerUserID,
+		PolicyVersion: policyVersion,
+	}
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO ai_consents (id, owner_user_id, policy_version, granted_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING granted_at
+	`, row.ID, ownerUserID, policyVersion).Scan(&row.GrantedAt); err != nil {
+		return storage.AIConsent{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return storage.AIConsent{}, err
+	}
+	return row, nil
+}
+
+func (s *aiConsentStorage) RevokeAIConsent(ctx context.Context, ownerUserID string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE ai_consents SET revoked_at = NOW()
+		WHERE owner_user_id = $1 AND revoked_at IS NULL
+	`, strings.TrimSpace(ownerUserID))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type aiUsageStorage struct {
+	pool *pgxpool.Pool
+}
+
+func newAIUsageStorage(pool *pgxpool.Pool) *aiUsageStorage {
+	return &aiUsageStorage{pool: pool}
+}
+
+func (s *aiUsageStorage) AddAIUsage(ctx context.Context, ownerUserID string, day time.Time, provider, model string, inputTokens, outputTokens int) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO ai_usage_daily (owner_user_id, day, provider, model, requests, input_tokens, output_tokens)
+		VALUES ($1, $2, $3, $4, 1, $5, $6)
+		ON CONFLICT (owner_user_id, day, provider, model) DO UPDATE SET
+			requests = ai_usage_daily.requests + 1,
+			input_tokens = ai_usage_daily.input_tokens + EXCLUDED.input_tokens,
+			output_tokens = ai_usage_daily.output_tokens + EXCLUDED.output_tokens
+	`, strings.TrimSpace(ownerUserID), usageDate(day), provider, model, inputTokens, outputTokens)
+	return err
+}
+
+func (s *aiUsageStorage) ListAIUsage(ctx context.Context, ownerUserID string, from, to time.Time) ([]storage.AIUsageDay, error) {
+	const query = `
+		SELECT owner_user_id, day, provider, model, requests, input_tokens, output_tokens
+		FROM ai_usage_daily
+		WHERE owner_user_id = $1 AND day BETWEEN $2 AND $3
+		ORDER BY day, provider, model
+	`
+
+	rows, err := s.pool.Query(ctx, query, strings.TrimSpace(ownerUserID), usageDate(from), usageDate(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]storage.AIUsageDay, 0)
+	for rows.Next() {
+		var row storage.AIUsageDay
+		if err := rows.Scan(
+			&row.OwnerUserID,
+			&row.Day,
+			&row.Provider,
+			&row.Model,
+			&row.Requests,
+			&row.InputTokens,
+			&row.OutputTokens,
+		); err != nil {
+			return nil, err
+		}
+		row.Day = row.Day.UTC()
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+func (s *aiUsageStorage) AggregateAIUsage(ctx context.Context, from, to time.Time) ([]storage.AIUsageTotal, error) {
+	const query = `
+		SELECT provider, model, COUNT(DISTINCT owner_user_id),
+			SUM(requests), SUM(input_tokens), SUM(output_tokens)
+		FROM ai_usage_daily
+		WHERE day BETWEEN $1 AND $2
+		GROUP BY provider, model
+		ORDER BY SUM(input_tokens + output_tokens) DESC, provider, model
+	`
+
+	rows, err := s.pool.Query(ctx, query, usageDate(from), usageDate(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]storage.AIUsageTotal, 0)
+	for rows.Next() {
+		var row storage.AIUsageTotal
+		if err := rows.Scan(
+			&row.Provider,
+			&row.Model,
+			&row.Owners,
+			&row.Requests,
+			&row.InputTokens,
+			&row.OutputTokens,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// usageDate truncates t to the UTC date stored in ai_usage_daily.day.
+func usageDate(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresAuditStorage stores audit events in PostgreSQL.
+type PostgresAuditStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresAuditStorage(pool *pgxpool.Pool) *PostgresAuditStorage {
+	return &PostgresAuditStorage{pool: pool}
+}
+
+func (s *PostgresAuditStorage) InsertAuditEvent(ctx context.Context, event storage.AuditEvent) error {
+	const query = `
+		INSERT INTO audit_events (id, owner_user_id, actor_user_id, profile_id, action, resource_type, resource_id, request_id, ip, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := s.pool.Exec(ctx, query,
+		event.ID,
+		event.OwnerUserID,
+		event.ActorUserID,
+		event.ProfileID,
+		event.Action,
+		event.ResourceType,
+		event.ResourceID,
+		event.RequestID,
+		event.IP,
+		event.CreatedAt,
+	)
+	return err
+}
+
+func (s *PostgresAuditStorage) ListAuditEvents(ctx context.Context, filter storage.AuditFilter) ([]storage.AuditEvent, error) {
+	query := `
+		SELECT id, owner_user_id, actor_user_id, profile_id, action, resource_type, resource_id, request_id, ip, created_at
+		FROM audit_events
+		WHERE owner_user_id = $1
+	`
+	args := []any{filter.OwnerUserID}
+
+	if filter.ProfileID != nil {
+		args = append(args, *filter.ProfileID)
+		query += fmt.Sprintf(" AND profile_id = $%d", len(args))
+	}
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		query += fmt.Sprintf(" AND action = $%d", len(args))
+	}
+	if filter.ResourceType != "" {
+		args = append(args, filter.ResourceType)
+		query += fmt.Sprintf(" AND resource_type = $%d", len(args))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+	if filter.Before != nil {
+		args = append(args, *filter.Before)
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+	query += " ORDER BY created_at DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]storage.AuditEvent, 0)
+	for rows.Next() {
+		var e storage.AuditEvent
+		if err := rows.Scan(
+			&e.ID,
+			&e.OwnerUserID,
+			&e.ActorUserID,
+			&e.ProfileID,
+			&e.Action,
+			&e.ResourceType,
+			&e.ResourceID,
+			&e.RequestID,
+			&e.IP,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (s *PostgresAuditStorage) DeleteAuditEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM audit_events WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -2,44 +2,68 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 
+	"github.com/fdg312/health-hub/internal/fieldcrypt"
 	"github.com/fdg312/health-hub/internal/storage"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type PostgresChatStorage struct {
 	pool *pgxpool.Pool
+	keys *fieldcrypt.Keyring
 }
 
 func NewPostgresChatStorage(pool *pgxpool.Pool) *PostgresChatStorage {
 	return &PostgresChatStorage{pool: pool}
 }
 
-func (s *PostgresChatStorage) InsertMessage(ctx context.Context, ownerUserID string, profileID uuid.UUID, role, content string) (storage.ChatMessage, error) {
+func (s *PostgresChatStorage) InsertMessage(ctx context.Context, ownerUserID string, profileID, threadID uuid.UUID, role, content string) (storage.ChatMessage, error) {
 	msg := storage.ChatMessage{
 		ID:          uuid.New(),
 		OwnerUserID: strings.TrimSpace(ownerUserID),
 		ProfileID:   profileID,
+		ThreadID:    threadID,
 		Role:        strings.TrimSpace(role),
 		Content:     content,
 		CreatedAt:   time.Now().UTC(),
 	}
 
+	dk, err := newRowKey(s.keys)
+	if err != nil {
+		return storage.ChatMessage{}, err
+	}
+	sealedContent, err := sealText(dk, "chat_messages", "content", msg.Content)
+	if err != nil {
+		return storage.ChatMessage{}, err
+	}
+	keyID, wrappedKey := rowKeyColumns(dk)
+
+	// The CTE also bumps the thread, so thread lists order by last activity.
 	const query = `
-		INSERT INTO chat_messages (id, owner_user_id, profile_id, role, content, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		WITH touched AS (
+			UPDATE chat_threads SET updated_at = $7
+			WHERE id = $4 AND owner_user_id = $2
+		)
+		INSERT INTO chat_messages (id, owner_user_id, profile_id, thread_id, role, content, created_at, enc_key_id, enc_data_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 
-	_, err := s.pool.Exec(ctx, query,
+	_, err = s.pool.Exec(ctx, query,
 		msg.ID,
 		msg.OwnerUserID,
 		msg.ProfileID,
+		msg.ThreadID,
 		msg.Role,
-		msg.Content,
+		sealedContent,
 		msg.CreatedAt,
+		keyID,
+		wrappedKey,
 	)
 	if err != nil {
 		return storage.ChatMessage{}, err
@@ -48,27 +72,36 @@ func (s *PostgresChatStorage) InsertMessage(ctx context.Context, ownerUserID str
 }
 
 func (s *PostgresChatStorage) ListMessages(ctx context.Context, ownerUserID string, profileID uuid.UUID, limit int, before *time.Time) ([]storage.ChatMessage, *time.Time, error) {
-	ownerUserID = strings.TrimSpace(ownerUserID)
+	return s.listMessages(ctx, "profile_id", strings.TrimSpace(ownerUserID), profileID, limit, before)
+}
+
+func (s *PostgresChatStorage) ListThreadMessages(ctx context.Context, ownerUserID string, threadID uuid.UUID, limit int, before *time.Time) ([]storage.ChatMessage, *time.Time, error) {
+	return s.listMessages(ctx, "thread_id", strings.TrimSpace(ownerUserID), threadID, limit, before)
+}
+
+// listMessages pages by created_at within owner and either a profile or a
+// thread; scope is the column name and never comes from user input.
+func (s *PostgresChatStorage) listMessages(ctx context.Context, scope, ownerUserID string, scopeID uuid.UUID, limit int, before *time.Time) ([]storage.ChatMessage, *time.Time, error) {
 	if limit <= 0 {
 		limit = 50
 	}
 	queryLimit := limit + 1
 
-	const query = `
-		SELECT id, owner_user_id, profile_id, role, content, created_at
+	query := fmt.Sprintf(`
+		SELECT id, owner_user_id, profile_id, thread_id, role, content, created_at, enc_key_id, enc_data_key
 		FROM (
-			SELECT id, owner_user_id, profile_id, role, content, created_at
+			SELECT id, owner_user_id, profile_id, thread_id, role, content, created_at, enc_key_id, enc_data_key
 			FROM chat_messages
 			WHERE owner_user_id = $1
-			  AND profile_id = $2
+			  AND %s = $2
 			  AND ($3::timestamptz IS NULL OR created_at < $3)
 			ORDER BY created_at DESC, id DESC
 			LIMIT $4
 		) latest
 		ORDER BY created_at ASC, id ASC
-	`
+	`, scope)
 
-	rows, err := s.pool.Query(ctx, query, ownerUserID, profileID, before, queryLimit)
+	rows, err := s.pool.Query(ctx, query, ownerUserID, scopeID, before, queryLimit)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -77,16 +110,28 @@ func (s *PostgresChatStorage) ListMessages(ctx context.Context, ownerUserID stri
 	result := make([]storage.ChatMessage, 0, queryLimit)
 	for rows.Next() {
 		var msg storage.ChatMessage
+		var keyID *string
+		var wrappedKey []byte
 		if err := rows.Scan(
 			&msg.ID,
 			&msg.OwnerUserID,
 			&msg.ProfileID,
+			&msg.ThreadID,
 			&msg.Role,
 			&msg.Content,
 			&msg.CreatedAt,
+			&keyID,
+			&wrappedKey,
 		); err != nil {
 			return nil, nil, err
 		}
+		dk, err := openRowKey(s.keys, keyID, wrappedKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		if msg.Content, err = openText(dk, "chat_messages", "content", msg.Content); err != nil {
+			return nil, nil, err
+		}
 		result = append(result, msg)
 	}
 	if err := rows.Err(); err != nil {
@@ -101,3 +146,259 @@ func (s *PostgresChatStorage) ListMessages(ctx context.Context, ownerUserID stri
 	cursor := result[0].CreatedAt.UTC()
 	return result, &cursor, nil
 }
+
+const threadColumns = `id, owner_user_id, profile_id, title, summary, summarized_until, archived_at, created_at, updated_at, enc_key_id, enc_data_key`
+
+func (s *PostgresChatStorage) CreateThread(ctx context.Context, ownerUserID string, profileID uuid.UUID, title string) (storage.ChatThread, error) {
+	now := time.Now().UTC()
+	thread := storage.ChatThread{
+		ID:          uuid.New(),
+		OwnerUserID: strings.TrimSpace(ownerUserID),
+		ProfileID:   profileID,
+		Title:       title,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	dk, err := newRowKey(s.keys)
+	if err != nil {
+		return storage.ChatThread{}, err
+	}
+	sealedTitle, err := sealText(dk, "chat_threads", "title", thread.Title)
+	if err != nil {
+		return storage.ChatThread{}, err
+	}
+	sealedSummary, err := sealText(dk, "chat_threads", "summary", "")
+	if err != nil {
+		return storage.ChatThread{}, err
+	}
+	keyID, wrappedKey := rowKeyColumns(dk)
+
+	const query = `
+		INSERT INTO chat_threads (id, owner_user_id, profile_id, title, summary, created_at, updated_at, enc_key_id, enc_data_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $6, $7, $8)
+	`
+	if _, err := s.pool.Exec(ctx, query,
+		thread.ID, thread.OwnerUserID, thread.ProfileID, sealedTitle, sealedSummary, now, keyID, wrappedKey,
+	); err != nil {
+		return storage.ChatThread{}, err
+	}
+	return thread, nil
+}
+
+func (s *PostgresChatStorage) GetThread(ctx context.Context, ownerUserID string, threadID uuid.UUID) (storage.ChatThread, bool, error) {
+	query := `SELECT ` + threadColumns + ` FROM chat_threads WHERE id = $1 AND owner_user_id = $2`
+	thread, _, err := s.scanThread(s.pool.QueryRow(ctx, query, threadID, strings.TrimSpace(ownerUserID)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ChatThread{}, false, nil
+	}
+	if err != nil {
+		return storage.ChatThread{}, false, err
+	}
+	return thread, true, nil
+}
+
+func (s *PostgresChatStorage) ListThreads(ctx context.Context, ownerUserID string, profileID uuid.UUID, includeArchived bool) ([]storage.ChatThread, error) {
+	query := `
+		SELECT ` + threadColumns + `
+		FROM chat_threads
+		WHERE owner_user_id = $1
+		  AND profile_id = $2
+		  AND ($3 OR archived_at IS NULL)
+		ORDER BY updated_at DESC, id DESC
+	`
+	rows, err := s.pool.Query(ctx, query, strings.TrimSpace(ownerUserID), profileID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]storage.ChatThread, 0)
+	for rows.Next() {
+		thread, _, err := s.scanThread(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, thread)
+	}
+	return result, rows.Err()
+}
+
+func (s *PostgresChatStorage) UpdateThread(ctx context.Context, ownerUserID string, threadID uuid.UUID, title *string, archived *bool) (storage.ChatThread, bool, error) {
+	ownerUserID = strings.TrimSpace(ownerUserID)
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return storage.ChatThread{}, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `SELECT ` + threadColumns + ` FROM chat_threads WHERE id = $1 AND owner_user_id = $2 FOR UPDATE`
+	thread, dk, err := s.scanThread(tx.QueryRow(ctx, query, threadID, ownerUserID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ChatThread{}, false, nil
+	}
+	if err != nil {
+		return storage.ChatThread{}, false, err
+	}
+
+	now := time.Now().UTC()
+	if title != nil {
+		thread.Title = *title
+	}
+	if archived != nil {
+		switch {
+		case *archived && thread.ArchivedAt == nil:
+			thread.ArchivedAt = &now
+		case !*archived:
+			thread.ArchivedAt = nil
+		}
+	}
+	thread.UpdatedAt = now
+
+	// The row keeps its data key; only the title is re-sealed.
+	sealedTitle, err := sealText(dk, "chat_threads", "title", thread.Title)
+	if err != nil {
+		return storage.ChatThread{}, false, err
+	}
+	const update = `UPDATE chat_threads SET title = $2, archived_at = $3, updated_at = $4 WHERE id = $1`
+	if _, err := tx.Exec(ctx, update, thread.ID, sealedTitle, thread.ArchivedAt, thread.UpdatedAt); err != nil {
+		return storage.ChatThread{}, false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return storage.ChatThread{}, false, err
+	}
+	return thread, true, nil
+}
+
+func (s *PostgresChatStorage) SaveThreadSummary(ctx context.Context, ownerUserID string, threadID uuid.UUID, summary string, summarizedUntil time.Time) error {
+	var keyID *string
+	var wrappedKey []byte
+	const selectKey = `SELECT enc_key_id, enc_data_key FROM chat_threads WHERE id = $1 AND owner_user_id = $2`
+	err := s.pool.QueryRow(ctx, selectKey, threadID, strings.TrimSpace(ownerUserID)).Scan(&keyID, &wrappedKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return err
+	}
+	sealedSummary, err := sealText(dk, "chat_threads", "summary", summary)
+	if err != nil {
+		return err
+	}
+
+	const update = `UPDATE chat_threads SET summary = $2, summarized_until = $3 WHERE id = $1`
+	_, err = s.pool.Exec(ctx, update, threadID, sealedSummary, summarizedUntil.UTC())
+	return err
+}
+
+// scanThread decrypts a chat_threads row and returns its data key for re-sealing.
+func (s *PostgresChatStorage) scanThread(row pgx.Row) (storage.ChatThread, *fieldcrypt.DataKey, error) {
+	var thread storage.ChatThread
+	var keyID *string
+	var wrappedKey []byte
+	if err := row.Scan(
+		&thread.ID,
+		&thread.OwnerUserID,
+		&thread.ProfileID,
+		&thread.Title,
+		&thread.Summary,
+		&thread.SummarizedUntil,
+		&thread.ArchivedAt,
+		&thread.CreatedAt,
+		&thread.UpdatedAt,
+		&keyID,
+		&wrappedKey,
+	); err != nil {
+		return storage.ChatThread{}, nil, err
+	}
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return storage.ChatThread{}, nil, err
+	}
+	if thread.Title, err = openText(dk, "chat_threads", "title", thread.Title); err != nil {
+		return storage.ChatThread{}, nil, err
+	}
+	if thread.Summary, err = openText(dk, "chat_threads", "summary", thread.Summary); err != nil {
+		return storage.ChatThread{}, nil, err
+	}
+	return thread, dk, nil
+}
+
+func (s *PostgresChatStorage) InsertFact(ctx context.Context, ownerUserID string, profileID uuid.UUID, content string) (storage.ChatFact, error) {
+	fact := storage.ChatFact{
+		ID:          uuid.New(),
+		OwnerUserID: strings.TrimSpace(ownerUserID),
+		ProfileID:   profileID,
+		Content:     content,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	dk, err := newRowKey(s.keys)
+	if err != nil {
+		return storage.ChatFact{}, err
+	}
+	sealedContent, err := sealText(dk, "chat_memory_facts", "content", fact.Content)
+	if err != nil {
+		return storage.ChatFact{}, err
+	}
+	keyID, wrappedKey := rowKeyColumns(dk)
+
+	const query = `
+		INSERT INTO chat_memory_facts (id, owner_user_id, profile_id, content, created_at, enc_key_id, enc_data_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := s.pool.Exec(ctx, query,
+		fact.ID, fact.OwnerUserID, fact.ProfileID, sealedContent, fact.CreatedAt, keyID, wrappedKey,
+	); err != nil {
+		return storage.ChatFact{}, err
+	}
+	return fact, nil
+}
+
+func (s *PostgresChatStorage) ListFacts(ctx context.Context, ownerUserID string, profileID uuid.UUID) ([]storage.ChatFact, error) {
+	const query = `
+		SELECT id, owner_user_id, profile_id, content, created_at, enc_key_id, enc_data_key
+		FROM chat_memory_facts
+		WHERE owner_user_id = $1 AND profile_id = $2
+		ORDER BY created_at ASC, id ASC
+	`
+	rows, err := s.pool.Query(ctx, query, strings.TrimSpace(ownerUserID), profileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]storage.ChatFact, 0)
+	for rows.Next() {
+		var fact storage.ChatFact
+		var keyID *string
+		var wrappedKey []byte
+		if err := rows.Scan(&fact.ID, &fact.OwnerUserID, &fact.ProfileID, &fact.Content, &fact.CreatedAt, &keyID, &wrappedKey); err != nil {
+			return nil, err
+		}
+		dk, err := openRowKey(s.keys, keyID, wrappedKey)
+		if err != nil {
+			return nil, err
+		}
+		if fact.Content, err = openText(dk, "chat_memory_facts", "content", fact.Content); err != nil {
+			return nil, err
+		}
+		result = append(result, fact)
+	}
+	return result, rows.Err()
+}
+
+func (s *PostgresChatStorage) DeleteFact(ctx context.Context, ownerUserID string, factID uuid.UUID) (bool, error) {
+	const query = `DELETE FROM chat_memory_facts WHERE id = $1 AND owner_user_id = $2`
+	tag, err := s.pool.Exec(ctx, query, factID, strings.TrimSpace(ownerUserID))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
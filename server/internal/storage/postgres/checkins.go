@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/fdg312/health-hub/internal/checkins"
+	"github.com/fdg312/health-hub/internal/fieldcrypt"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -14,6 +16,7 @@ import (
 // PostgresCheckinsStorage implements checkins.Storage
 type PostgresCheckinsStorage struct {
 	pool *pgxpool.Pool
+	keys *fieldcrypt.Keyring
 }
 
 // NewPostgresCheckinsStorage creates a new Postgres checkins storage
@@ -21,13 +24,21 @@ func NewPostgresCheckinsStorage(pool *pgxpool.Pool) *PostgresCheckinsStorage {
 	return &PostgresCheckinsStorage{pool: pool}
 }
 
+const checkinColumns = `
+	id, profile_id, date, type, score, tags, note, template_id, recorded_at, created_at, updated_at, enc_key_id, enc_data_key
+`
+
+const templateColumns = `
+	id, profile_id, name, type, is_default, questions, created_at, updated_at
+`
+
 // ListCheckins returns all check-ins for a profile within a date range
 func (s *PostgresCheckinsStorage) ListCheckins(profileID uuid.UUID, from, to string) ([]checkins.Checkin, error) {
 	query := `
-		SELECT id, profile_id, date, type, score, tags, note, created_at, updated_at
+		SELECT ` + checkinColumns + `
 		FROM checkins
 		WHERE profile_id = $1 AND date >= $2 AND date <= $3
-		ORDER BY date DESC, type
+		ORDER BY date DESC, type, recorded_at
 	`
 
 	rows, err := s.pool.Query(context.Background(), query, profileID, from, to)
@@ -38,60 +49,31 @@ func (s *PostgresCheckinsStorage) ListCheckins(profileID uuid.UUID, from, to str
 
 	var result []checkins.Checkin
 	for rows.Next() {
-		var c checkins.Checkin
-		var tagsJSON []byte
-
-		err := rows.Scan(
-			&c.ID,
-			&c.ProfileID,
-			&c.Date,
-			&c.Type,
-			&c.Score,
-			&tagsJSON,
-			&c.Note,
-			&c.CreatedAt,
-			&c.UpdatedAt,
-		)
+		c, err := s.scanCheckin(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		// Unmarshal tags
-		if len(tagsJSON) > 0 {
-			if err := json.Unmarshal(tagsJSON, &c.Tags); err != nil {
-				return nil, err
-			}
-		}
-
 		result = append(result, c)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return result, rows.Err()
+	if err := s.loadAnswers(context.Background(), result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // GetCheckin retrieves a check-in by ID
 func (s *PostgresCheckinsStorage) GetCheckin(id uuid.UUID) (*checkins.Checkin, error) {
 	query := `
-		SELECT id, profile_id, date, type, score, tags, note, created_at, updated_at
+		SELECT ` + checkinColumns + `
 		FROM checkins
 		WHERE id = $1
 	`
 
-	var c checkins.Checkin
-	var tagsJSON []byte
-
-	err := s.pool.QueryRow(context.Background(), query, id).Scan(
-		&c.ID,
-		&c.ProfileID,
-		&c.Date,
-		&c.Type,
-		&c.Score,
-		&tagsJSON,
-		&c.Note,
-		&c.CreatedAt,
-		&c.UpdatedAt,
-	)
-
+	c, err := s.scanCheckin(s.pool.QueryRow(context.Background(), query, id))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, errors.New("checkin not found")
@@ -99,50 +81,113 @@ func (s *PostgresCheckinsStorage) GetCheckin(id uuid.UUID) (*checkins.Checkin, e
 		return nil, err
 	}
 
-	// Unmarshal tags
-	if len(tagsJSON) > 0 {
-		if err := json.Unmarshal(tagsJSON, &c.Tags); err != nil {
-			return nil, err
-		}
+	list := []checkins.Checkin{c}
+	if err := s.loadAnswers(context.Background(), list); err != nil {
+		return nil, err
 	}
-
-	return &c, nil
+	return &list[0], nil
 }
 
 // UpsertCheckin creates or updates a check-in (by profile_id, date, type)
+// and replaces its answers; adhoc check-ins are always created
 func (s *PostgresCheckinsStorage) UpsertCheckin(checkin *checkins.Checkin) error {
+	ctx := context.Background()
+
 	tagsJSON, err := json.Marshal(checkin.Tags)
 	if err != nil {
 		return err
 	}
 
+	dk, err := newRowKey(s.keys)
+	if err != nil {
+		return err
+	}
+	note, err := sealText(dk, "checkins", "note", checkin.Note)
+	if err != nil {
+		return err
+	}
+	keyID, wrappedKey := rowKeyColumns(dk)
+
+	var score *int
+	if checkin.Score != 0 {
+		score = &checkin.Score
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// The unique index covers morning and evening only, so adhoc rows never
+	// conflict
 	query := `
-		INSERT INTO checkins (id, profile_id, date, type, score, tags, note, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		ON CONFLICT (profile_id, date, type)
+		INSERT INTO checkins (id, profile_id, date, type, score, tags, note, template_id, recorded_at, created_at, updated_at, enc_key_id, enc_data_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (profile_id, date, type) WHERE type IN ('morning', 'evening')
 		DO UPDATE SET
 			score = EXCLUDED.score,
 			tags = EXCLUDED.tags,
 			note = EXCLUDED.note,
-			updated_at = EXCLUDED.updated_at
+			template_id = EXCLUDED.template_id,
+			recorded_at = EXCLUDED.recorded_at,
+			updated_at = EXCLUDED.updated_at,
+			enc_key_id = EXCLUDED.enc_key_id,
+			enc_data_key = EXCLUDED.enc_data_key
 		RETURNING id, created_at, updated_at
 	`
 
-	err = s.pool.QueryRow(
-		context.Background(),
+	err = tx.QueryRow(
+		ctx,
 		query,
 		checkin.ID,
 		checkin.ProfileID,
 		checkin.Date,
 		checkin.Type,
-		checkin.Score,
+		score,
 		tagsJSON,
-		checkin.Note,
+		note,
+		checkin.TemplateID,
+		checkin.RecordedAt,
 		checkin.CreatedAt,
 		checkin.UpdatedAt,
+		keyID,
+		wrappedKey,
 	).Scan(&checkin.ID, &checkin.CreatedAt, &checkin.UpdatedAt)
+	if err != nil {
+		return err
+	}
 
-	return err
+	if _, err := tx.Exec(ctx, `DELETE FROM checkin_answers WHERE checkin_id = $1`, checkin.ID); err != nil {
+		return err
+	}
+	for i, a := range checkin.Answers {
+		text, err := sealOptionalText(dk, "checkin_answers", "value_text", a.Text)
+		if err != nil {
+			return err
+		}
+		var options []byte
+		if a.Options != nil {
+			if options, err = json.Marshal(a.Options); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO checkin_answers (
+				id, checkin_id, profile_id, template_id, question_id, position, date, recorded_at,
+				value_number, value_bool, value_options, value_text, enc_key_id, enc_data_key
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		`,
+			uuid.New(), checkin.ID, checkin.ProfileID, checkin.TemplateID, a.QuestionID, i, checkin.Date, checkin.RecordedAt,
+			a.Number, a.Bool, options, text, keyID, wrappedKey,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
 }
 
 // DeleteCheckin deletes a check-in by ID
@@ -160,3 +205,309 @@ func (s *PostgresCheckinsStorage) DeleteCheckin(id uuid.UUID) error {
 
 	return nil
 }
+
+// ListTemplates returns the templates of a profile, defaults first
+func (s *PostgresCheckinsStorage) ListTemplates(profileID uuid.UUID) ([]checkins.Template, error) {
+	query := `
+		SELECT ` + templateColumns + `
+		FROM checkin_templates
+		WHERE profile_id = $1
+		ORDER BY is_default DESC, created_at, type
+	`
+
+	rows, err := s.pool.Query(context.Background(), query, profileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]checkins.Template, 0)
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+
+	return result, rows.Err()
+}
+
+// GetTemplate retrieves a template by ID
+func (s *PostgresCheckinsStorage) GetTemplate(id uuid.UUID) (*checkins.Template, error) {
+	query := `
+		SELECT ` + templateColumns + `
+		FROM checkin_templates
+		WHERE id = $1
+	`
+
+	t, err := scanTemplate(s.pool.QueryRow(context.Background(), query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("template not found")
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// CreateTemplate stores a new template; an existing default template of the
+// same profile and type is loaded instead of a second one
+func (s *PostgresCheckinsStorage) CreateTemplate(template *checkins.Template) error {
+	questionsJSON, err := json.Marshal(template.Questions)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO checkin_templates (` + templateColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (profile_id, type) WHERE is_default DO NOTHING
+		RETURNING ` + templateColumns
+
+	created, err := scanTemplate(s.pool.QueryRow(
+		context.Background(),
+		query,
+		template.ID,
+		template.ProfileID,
+		template.Name,
+		template.Type,
+		template.IsDefault,
+		questionsJSON,
+		template.CreatedAt,
+		template.UpdatedAt,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		// A concurrent request created the default template first
+		created, err = scanTemplate(s.pool.QueryRow(context.Background(), `
+			SELECT `+templateColumns+`
+			FROM checkin_templates
+			WHERE profile_id = $1 AND type = $2 AND is_default
+		`, template.ProfileID, template.Type))
+	}
+	if err != nil {
+		return err
+	}
+
+	*template = created
+	return nil
+}
+
+// UpdateTemplate saves the name and questions of a template
+func (s *PostgresCheckinsStorage) UpdateTemplate(template *checkins.Template) error {
+	questionsJSON, err := json.Marshal(template.Questions)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE checkin_templates
+		SET name = $2, questions = $3, updated_at = $4
+		WHERE id = $1
+		RETURNING ` + templateColumns
+
+	updated, err := scanTemplate(s.pool.QueryRow(context.Background(), query, template.ID, template.Name, questionsJSON, template.UpdatedAt))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errors.New("template not found")
+		}
+		return err
+	}
+
+	*template = updated
+	return nil
+}
+
+// DeleteTemplate deletes a template; template_id of its checkins and
+// answers becomes NULL
+func (s *PostgresCheckinsStorage) DeleteTemplate(id uuid.UUID) error {
+	result, err := s.pool.Exec(context.Background(), `DELETE FROM checkin_templates WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return errors.New("template not found")
+	}
+
+	return nil
+}
+
+// ListAnswers returns answers to a question within a date range, oldest first
+func (s *PostgresCheckinsStorage) ListAnswers(profileID uuid.UUID, templateID *uuid.UUID, questionID, from, to string) ([]checkins.AnswerPoint, error) {
+	query := `
+		SELECT checkin_id, template_id, date, recorded_at, question_id,
+			value_number, value_bool, value_options, value_text, enc_key_id, enc_data_key
+		FROM checkin_answers
+		WHERE profile_id = $1 AND question_id = $2 AND date >= $3 AND date <= $4
+	`
+	args := []any{profileID, questionID, from, to}
+	if templateID != nil {
+		args = append(args, *templateID)
+		query += fmt.Sprintf(" AND template_id = $%d", len(args))
+	}
+	query += ` ORDER BY date, recorded_at`
+
+	rows, err := s.pool.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]checkins.AnswerPoint, 0)
+	for rows.Next() {
+		var p checkins.AnswerPoint
+		answer, err := s.scanAnswer(rows, &p.CheckinID, &p.TemplateID, &p.Date, &p.RecordedAt)
+		if err != nil {
+			return nil, err
+		}
+		p.Answer = answer
+		result = append(result, p)
+	}
+
+	return result, rows.Err()
+}
+
+// loadAnswers attaches the answers of each check-in in place
+func (s *PostgresCheckinsStorage) loadAnswers(ctx context.Context, list []checkins.Checkin) error {
+	if len(list) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(list))
+	byID := make(map[uuid.UUID]int, len(list))
+	for i, c := range list {
+		ids[i] = c.ID
+		byID[c.ID] = i
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT checkin_id, question_id, value_number, value_bool, value_options, value_text, enc_key_id, enc_data_key
+		FROM checkin_answers
+		WHERE checkin_id = ANY($1)
+		ORDER BY checkin_id, position
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var checkinID uuid.UUID
+		answer, err := s.scanAnswer(rows, &checkinID)
+		if err != nil {
+			return err
+		}
+		i := byID[checkinID]
+		list[i].Answers = append(list[i].Answers, answer)
+	}
+
+	return rows.Err()
+}
+
+// scanAnswer scans the columns in front of question_id into dest, then
+// the answer itself, and decrypts the text
+func (s *PostgresCheckinsStorage) scanAnswer(row pgx.Row, dest ...any) (checkins.Answer, error) {
+	var a checkins.Answer
+	var optionsJSON []byte
+	var keyID *string
+	var wrappedKey []byte
+
+	dest = append(dest, &a.QuestionID, &a.Number, &a.Bool, &optionsJSON, &a.Text, &keyID, &wrappedKey)
+	if err := row.Scan(dest...); err != nil {
+		return a, err
+	}
+
+	if len(optionsJSON) > 0 {
+		if err := json.Unmarshal(optionsJSON, &a.Options); err != nil {
+			return a, err
+		}
+	}
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return a, err
+	}
+	a.Text, err = openOptionalText(dk, "checkin_answers", "value_text", a.Text)
+	return a, err
+}
+
+// scanCheckin scans a row of checkinColumns and decrypts the note
+func (s *PostgresCheckinsStorage) scanCheckin(row pgx.Row) (checkins.Checkin, error) {
+	var c checkins.Checkin
+	var score *int
+	var tagsJSON []byte
+	var keyID *string
+	var wrappedKey []byte
+
+	err := row.Scan(
+		&c.ID,
+		&c.ProfileID,
+		&c.Date,
+		&c.Type,
+		&score,
+		&tagsJSON,
+		&c.Note,
+		&c.TemplateID,
+		&c.RecordedAt,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+		&keyID,
+		&wrappedKey,
+	)
+	if err != nil {
+		return c, err
+	}
+	if score != nil {
+		c.Score = *score
+	}
+
+	if err := s.openNote(&c, keyID, wrappedKey); err != nil {
+		return c, err
+	}
+
+	// Unmarshal tags
+	if len(tagsJSON) > 0 {
+		if err := json.Unmarshal(tagsJSON, &c.Tags); err != nil {
+			return c, err
+		}
+	}
+
+	return c, nil
+}
+
+// openNote decrypts the note in place when the row is encrypted
+func (s *PostgresCheckinsStorage) openNote(c *checkins.Checkin, keyID *string, wrappedKey []byte) error {
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return err
+	}
+	c.Note, err = openText(dk, "checkins", "note", c.Note)
+	return err
+}
+
+func scanTemplate(row pgx.Row) (checkins.Template, error) {
+	var t checkins.Template
+	var questionsJSON []byte
+
+	err := row.Scan(
+		&t.ID,
+		&t.ProfileID,
+		&t.Name,
+		&t.Type,
+		&t.IsDefault,
+		&questionsJSON,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+	if err != nil {
+		return t, err
+	}
+
+	if len(questionsJSON) > 0 {
+		if err := json.Unmarshal(questionsJSON, &t.Questions); err != nil {
+			return t, err
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,329 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type coachingStorage struct {
+	pool *pgxpool.Pool
+}
+
+func newCoachingStorage(pool *pgxpool.Pool) *coachingStorage {
+	return &coachingStorage{pool: pool}
+}
+
+const coachingProgramColumns = `
+	id, owner_user_id, profile_id, title, metric, baseline_value, target_value,
+	start_date, target_date, status, next_checkin_at, source_proposal_id, created_at, updated_at
+`
+
+func scanCoachingProgram(row pgx.Row) (storage.CoachingProgram, error) {
+	var program storage.CoachingProgram
+	err := row.Scan(
+		&program.ID,
+		&program.OwnerUserID,
+		&program.ProfileID,
+		&program.Title,
+		&program.Metric,
+		&program.BaselineValue,
+		&program.TargetValue,
+		&program.StartDate,
+		&program.TargetDate,
+		&program.Status,
+		&program.NextCheckinAt,
+		&program.SourceProposalID,
+		&program.CreatedAt,
+		&program.UpdatedAt,
+	)
+	return program, err
+}
+
+func (s *coachingStorage) CreateCoachingProgram(ctx context.Context, program storage.CoachingProgram) (storage.CoachingProgram, error) {
+	if program.ID == uuid.Nil {
+		program.ID = uuid.New()
+	}
+	program.OwnerUserID = strings.TrimSpace(program.OwnerUserID)
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return storage.CoachingProgram{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO coaching_programs (
+			id, owner_user_id, profile_id, title, metric, baseline_value, target_value,
+			start_date, target_date, status, next_checkin_at, source_proposal_id, created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW())
+		RETURNING created_at, updated_at
+	`,
+		program.ID, program.OwnerUserID, program.ProfileID, program.Title, program.Metric,
+		program.BaselineValue, program.TargetValue, program.StartDate, program.TargetDate,
+		program.Status, program.NextCheckinAt, program.SourceProposalID,
+	).Scan(&program.CreatedAt, &program.UpdatedAt); err != nil {
+		return storage.CoachingProgram{}, err
+	}
+	if err := replaceMilestones(ctx, tx, program.ID, program.Milestones); err != nil {
+		return storage.CoachingProgram{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return storage.CoachingProgram{}, err
+	}
+	return program, nil
+}
+
+func (s *coachingStorage) GetCoachingProgram(ctx context.Context, ownerUserID string, programID uuid.UUID) (storage.CoachingProgram, bool, error) {
+	program, err := scanCoachingProgram(s.pool.QueryRow(ctx, `
+		SELECT `+coachingProgramColumns+`
+		FROM coaching_programs
+		WHERE id = $1 AND owner_user_id = $2
+	`, programID, strings.TrimSpace(ownerUserID)))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.CoachingProgram{}, false, nil
+		}
+		return storage.CoachingProgram{}, false, err
+	}
+	if err := s.loadMilestones(ctx, []*storage.CoachingProgram{&program}); err != nil {
+		return storage.CoachingProgram{}, false, err
+	}
+	return program, true, nil
+}
+
+func (s *coachingStorage) ListCoachingPrograms(ctx context.Context, ownerUserID string, profileID uuid.UUID, status string) ([]storage.CoachingProgram, error) {
+	query := `
+		SELECT ` + coachingProgramColumns + `
+		FROM coaching_programs
+		WHERE owner_user_id = $1 AND profile_id = $2
+	`
+	args := []any{strings.TrimSpace(ownerUserID), profileID}
+	if status != "" {
+		query += ` AND status = $3`
+		args = append(args, status)
+	}
+	query += ` ORDER BY created_at DESC`
+
+	return s.queryPrograms(ctx, query, args...)
+}
+
+func (s *coachingStorage) UpdateCoachingProgram(ctx context.Context, program storage.CoachingProgram) (storage.CoachingProgram, bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return storage.CoachingProgram{}, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	updated, found, err := updateCoachingProgram(ctx, tx, program)
+	if err != nil || !found {
+		return storage.CoachingProgram{}, found, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return storage.CoachingProgram{}, false, err
+	}
+	return updated, true, nil
+}
+
+func (s *coachingStorage) DeleteCoachingProgram(ctx context.Context, ownerUserID string, programID uuid.UUID) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		DELETE FROM coaching_programs WHERE id = $1 AND owner_user_id = $2
+	`, programID, strings.TrimSpace(ownerUserID))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *coachingStorage) ListDueCoachingPrograms(ctx context.Context, now time.Time, limit int) ([]storage.CoachingProgram, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	return s.queryPrograms(ctx, `
+		SELECT `+coachingProgramColumns+`
+		FROM coaching_programs
+		WHERE status = 'active' AND next_checkin_at <= $1
+		ORDER BY next_checkin_at
+		LIMIT $2
+	`, now, limit)
+}
+
+func (s *coachingStorage) RecordCoachingCheckin(ctx context.Context, checkin storage.CoachingCheckin, program storage.CoachingProgram) (storage.CoachingCheckin, bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return storage.CoachingCheckin{}, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, found, err := updateCoachingProgram(ctx, tx, program); err != nil || !found {
+		return storage.CoachingCheckin{}, found, err
+	}
+
+	checkin.ID = uuid.New()
+	checkin.ProgramID = program.ID
+	checkin.OwnerUserID = strings.TrimSpace(program.OwnerUserID)
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO coaching_checkins (
+			id, program_id, owner_user_id, period_from, period_to, value, expected_value, status, proposal_id, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		RETURNING created_at
+	`,
+		checkin.ID, checkin.ProgramID, checkin.OwnerUserID, checkin.PeriodFrom, checkin.PeriodTo,
+		checkin.Value, checkin.ExpectedValue, checkin.Status, checkin.ProposalID,
+	).Scan(&checkin.CreatedAt); err != nil {
+		return storage.CoachingCheckin{}, false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return storage.CoachingCheckin{}, false, err
+	}
+	return checkin, true, nil
+}
+
+func (s *coachingStorage) ListCoachingCheckins(ctx context.Context, ownerUserID string, programID uuid.UUID) ([]storage.CoachingCheckin, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, program_id, owner_user_id, period_from, period_to, value, expected_value, status, proposal_id, created_at
+		FROM coaching_checkins
+		WHERE program_id = $1 AND owner_user_id = $2
+		ORDER BY period_to, created_at
+	`, programID, strings.TrimSpace(ownerUserID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkins := make([]storage.CoachingCheckin, 0)
+	for rows.Next() {
+		var checkin storage.CoachingCheckin
+		if err := rows.Scan(
+			&checkin.ID,
+			&checkin.ProgramID,
+			&checkin.OwnerUserID,
+			&checkin.PeriodFrom,
+			&checkin.PeriodTo,
+			&checkin.Value,
+			&checkin.ExpectedValue,
+			&checkin.Status,
+			&checkin.ProposalID,
+			&checkin.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		checkins = append(checkins, checkin)
+	}
+	return checkins, rows.Err()
+}
+
+func (s *coachingStorage) queryPrograms(ctx context.Context, query string, args ...any) ([]storage.CoachingProgram, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	programs := make([]storage.CoachingProgram, 0)
+	for rows.Next() {
+		program, err := scanCoachingProgram(rows)
+		if err != nil {
+			return nil, err
+		}
+		programs = append(programs, program)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	refs := make([]*storage.CoachingProgram, len(programs))
+	for i := range programs {
+		refs[i] = &programs[i]
+	}
+	if err := s.loadMilestones(ctx, refs); err != nil {
+		return nil, err
+	}
+	return programs, nil
+}
+
+// loadMilestones fills Milestones of the given programs with one query.
+func (s *coachingStorage) loadMilestones(ctx context.Context, programs []*storage.CoachingProgram) error {
+	if len(programs) == 0 {
+		return nil
+	}
+	byID := make(map[uuid.UUID]*storage.CoachingProgram, len(programs))
+	ids := make([]uuid.UUID, 0, len(programs))
+	for _, program := range programs {
+		program.Milestones = []storage.CoachingMilestone{}
+		byID[program.ID] = program
+		ids = append(ids, program.ID)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT program_id, due_date, target_value, reached_at
+		FROM coaching_milestones
+		WHERE program_id = ANY($1)
+		ORDER BY program_id, position
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var programID uuid.UUID
+		var milestone storage.CoachingMilestone
+		if err := rows.Scan(&programID, &milestone.DueDate, &milestone.TargetValue, &milestone.ReachedAt); err != nil {
+			return err
+		}
+		if program, ok := byID[programID]; ok {
+			program.Milestones = append(program.Milestones, milestone)
+		}
+	}
+	return rows.Err()
+}
+
+// updateCoachingProgram stores the mutable fields of program; identity,
+// goal and start date are fixed at creation.
+func updateCoachingProgram(ctx context.Context, tx pgx.Tx, program storage.CoachingProgram) (storage.CoachingProgram, bool, error) {
+	updated, err := scanCoachingProgram(tx.QueryRow(ctx, `
+		UPDATE coaching_programs
+		SET status = $3, target_date = $4, next_checkin_at = $5, updated_at = NOW()
+		WHERE id = $1 AND owner_user_id = $2
+		RETURNING `+coachingProgramColumns,
+		program.ID, strings.TrimSpace(program.OwnerUserID), program.Status, program.TargetDate, program.NextCheckinAt,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.CoachingProgram{}, false, nil
+		}
+		return storage.CoachingProgram{}, false, err
+	}
+	if err := replaceMilestones(ctx, tx, program.ID, program.Milestones); err != nil {
+		return storage.CoachingProgram{}, false, err
+	}
+	updated.Milestones = append([]storage.CoachingMilestone{}, program.Milestones...)
+	return updated, true, nil
+}
+
+func replaceMilestones(ctx context.Context, tx pgx.Tx, programID uuid.UUID, milestones []storage.CoachingMilestone) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM coaching_milestones WHERE program_id = $1`, programID); err != nil {
+		return err
+	}
+	for i, milestone := range milestones {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO coaching_milestones (program_id, position, due_date, target_value, reached_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, programID, i, milestone.DueDate, milestone.TargetValue, milestone.ReachedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/fieldcrypt"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// cycleStorage keeps cycle data. Notes and day symptoms are encrypted.
+type cycleStorage struct {
+	pool *pgxpool.Pool
+	keys *fieldcrypt.Keyring
+}
+
+func newCycleStorage(pool *pgxpool.Pool) *cycleStorage {
+	return &cycleStorage{pool: pool}
+}
+
+const cyclePeriodColumns = `
+	id, profile_id, start_date, end_date, note, created_at, updated_at, enc_key_id, enc_data_key
+`
+
+const cycleDayColumns = `
+	id, profile_id, date, flow, symptoms, note, created_at, updated_at, enc_key_id, enc_data_key
+`
+
+const cycleSettingsColumns = `
+	profile_id, share_with_ai, reminders_enabled, remind_days_before, remind_fertile_window,
+	cycle_length_days, period_length_days, updated_at
+`
+
+func (s *cycleStorage) CreatePeriod(ctx context.Context, period storage.CyclePeriod) (storage.CyclePeriod, error) {
+	if period.ID == uuid.Nil {
+		period.ID = uuid.New()
+	}
+	dk, err := newRowKey(s.keys)
+	if err != nil {
+		return storage.CyclePeriod{}, err
+	}
+	note, err := sealText(dk, "cycle_periods", "note", period.Note)
+	if err != nil {
+		return storage.CyclePeriod{}, err
+	}
+	keyID, wrappedKey := rowKeyColumns(dk)
+
+	err = s.pool.QueryRow(ctx, `
+		INSERT INTO cycle_periods (id, profile_id, start_date, end_date, note, created_at, updated_at, enc_key_id, enc_data_key)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW(), $6, $7)
+		RETURNING created_at, updated_at
+	`, period.ID, period.ProfileID, period.StartDate, period.EndDate, note, keyID, wrappedKey,
+	).Scan(&period.CreatedAt, &period.UpdatedAt)
+	if err != nil {
+		return storage.CyclePeriod{}, err
+	}
+	return period, nil
+}
+
+func (s *cycleStorage) GetPeriod(ctx context.Context, id uuid.UUID) (storage.CyclePeriod, bool, error) {
+	period, err := s.scanPeriod(s.pool.QueryRow(ctx, `
+		SELECT `+cyclePeriodColumns+`
+		FROM cycle_periods
+		WHERE id = $1
+	`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.CyclePeriod{}, false, nil
+		}
+		return storage.CyclePeriod{}, false, err
+	}
+	return period, true, nil
+}
+
+func (s *cycleStorage) UpdatePeriod(ctx context.Context, period storage.CyclePeriod) (storage.CyclePeriod, bool, error) {
+	dk, err := newRowKey(s.keys)
+	if err != nil {
+		return storage.CyclePeriod{}, false, err
+	}
+	note, err := sealText(dk, "cycle_periods", "note", period.Note)
+	if err != nil {
+		return storage.CyclePeriod{}, false, err
+	}
+	keyID, wrappedKey := rowKeyColumns(dk)
+
+	updated, err := s.scanPeriod(s.pool.QueryRow(ctx, `
+		UPDATE cycle_periods
+		SET start_date = $2, end_date = $3, note = $4, enc_key_id = $5, enc_data_key = $6, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+cyclePeriodColumns,
+		period.ID, period.StartDate, period.EndDate, note, keyID, wrappedKey,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.CyclePeriod{}, false, nil
+		}
+		return storage.CyclePeriod{}, false, err
+	}
+	return updated, true, nil
+}
+
+func (s *cycleStorage) DeletePeriod(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM cycle_periods WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *cycleStorage) ListPeriods(ctx context.Context, profileID uuid.UUID, from, to time.Time) ([]storage.CyclePeriod, error) {
+	query := `
+		SELECT ` + cyclePeriodColumns + `
+		FROM cycle_periods
+		WHERE profile_id = $1
+	`
+	args := []any{profileID}
+	if !from.IsZero() {
+		args = append(args, from)
+		query += fmt.Sprintf(" AND start_date >= $%d", len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to)
+		query += fmt.Sprintf(" AND start_date <= $%d", len(args))
+	}
+	query += ` ORDER BY start_date`
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := make([]storage.CyclePeriod, 0)
+	for rows.Next() {
+		period, err := s.scanPeriod(rows)
+		if err != nil {
+			return nil, err
+		}
+		periods = append(periods, period)
+	}
+	return periods, rows.Err()
+}
+
+func (s *cycleStorage) UpsertCycleDay(ctx context.Context, day storage.CycleDay) (storage.CycleDay, error) {
+	if day.Symptoms == nil {
+		day.Symptoms = []string{}
+	}
+	symptomsJSON, err := json.Marshal(day.Symptoms)
+	if err != nil {
+		return storage.CycleDay{}, err
+	}
+	dk, err := newRowKey(s.keys)
+	if err != nil {
+		return storage.CycleDay{}, err
+	}
+	symptoms, err := sealJSON(dk, "cycle_days", "symptoms", symptomsJSON)
+	if err != nil {
+		return storage.CycleDay{}, err
+	}
+	note, err := sealText(dk, "cycle_days", "note", day.Note)
+	if err != nil {
+		return storage.CycleDay{}, err
+	}
+	keyID, wrappedKey := rowKeyColumns(dk)
+
+	return s.scanDay(s.pool.QueryRow(ctx, `
+		INSERT INTO cycle_days (id, profile_id, date, flow, symptoms, note, created_at, updated_at, enc_key_id, enc_data_key)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW(), $7, $8)
+		ON CONFLICT (profile_id, date) DO UPDATE
+		SET flow = EXCLUDED.flow,
+			symptoms = EXCLUDED.symptoms,
+			note = EXCLUDED.note,
+			enc_key_id = EXCLUDED.enc_key_id,
+			enc_data_key = EXCLUDED.enc_data_key,
+			updated_at = NOW()
+		RETURNING `+cycleDayColumns,
+		uuid.New(), day.ProfileID, day.Date, day.Flow, symptoms, note, keyID, wrappedKey,
+	))
+}
+
+func (s *cycleStorage) DeleteCycleDay(ctx context.Context, profileID uuid.UUID, date time.Time) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM cycle_days WHERE profile_id = $1 AND date = $2`, profileID, date)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *cycleStorage) ListCycleDays(ctx context.Context, profileID uuid.UUID, from, to time.Time) ([]storage.CycleDay, error) {
+	query := `
+		SELECT ` + cycleDayColumns + `
+		FROM cycle_days
+		WHERE profile_id = $1
+	`
+	args := []any{profileID}
+	if !from.IsZero() {
+		args = append(args, from)
+		query += fmt.Sprintf(" AND date >= $%d", len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to)
+		query += fmt.Sprintf(" AND date <= $%d", len(args))
+	}
+	query += ` ORDER BY date`
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := make([]storage.CycleDay, 0)
+	for rows.Next() {
+		day, err := s.scanDay(rows)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+func (s *cycleStorage) GetCycleSettings(ctx context.Context, profileID uuid.UUID) (storage.CycleSettings, bool, error) {
+	settings, err := scanCycleSettings(s.pool.QueryRow(ctx, `
+		SELECT `+cycleSettingsColumns+`
+		FROM cycle_settings
+		WHERE profile_id = $1
+	`, profileID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.CycleSettings{}, false, nil
+		}
+		return storage.CycleSettings{}, false, err
+	}
+	return settings, true, nil
+}
+
+func (s *cycleStorage) UpsertCycleSettings(ctx context.Context, settings storage.CycleSettings) (storage.CycleSettings, error) {
+	return scanCycleSettings(s.pool.QueryRow(ctx, `
+		INSERT INTO cycle_settings (
+			profile_id, share_with_ai, reminders_enabled, remind_days_before, remind_fertile_window,
+			cycle_length_days, period_length_days, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (profile_id) DO UPDATE
+		SET share_with_ai = EXCLUDED.share_with_ai,
+			reminders_enabled = EXCLUDED.reminders_enabled,
+			remind_days_before = EXCLUDED.remind_days_before,
+			remind_fertile_window = EXCLUDED.remind_fertile_window,
+			cycle_length_days = EXCLUDED.cycle_length_days,
+			period_length_days = EXCLUDED.period_length_days,
+			updated_at = NOW()
+		RETURNING `+cycleSettingsColumns,
+		settings.ProfileID, settings.ShareWithAI, settings.RemindersEnabled, settings.RemindDaysBefore,
+		settings.RemindFertileWindow, settings.CycleLengthDays, settings.PeriodLengthDays,
+	))
+}
+
+// scanPeriod scans a row of cyclePeriodColumns and decrypts the note.
+func (s *cycleStorage) scanPeriod(row pgx.Row) (storage.CyclePeriod, error) {
+	var period storage.CyclePeriod
+	var keyID *string
+	var wrappedKey []byte
+	err := row.Scan(
+		&period.ID,
+		&period.ProfileID,
+		&period.StartDate,
+		&period.EndDate,
+		&period.Note,
+		&period.CreatedAt,
+		&period.UpdatedAt,
+		&keyID,
+		&wrappedKey,
+	)
+	if err != nil {
+		return period, err
+	}
+
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return period, err
+	}
+	period.Note, err = openText(dk, "cycle_periods", "note", period.Note)
+	return period, err
+}
+
+// scanDay scans a row of cycleDayColumns and decrypts symptoms and note.
+func (s *cycleStorage) scanDay(row pgx.Row) (storage.CycleDay, error) {
+	var day storage.CycleDay
+	var symptoms []byte
+	var keyID *string
+	var wrappedKey []byte
+	err := row.Scan(
+		&day.ID,
+		&day.ProfileID,
+		&day.Date,
+		&day.Flow,
+		&symptoms,
+		&day.Note,
+		&day.CreatedAt,
+		&day.UpdatedAt,
+		&keyID,
+		&wrappedKey,
+	)
+	if err != nil {
+		return day, err
+	}
+
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return day, err
+	}
+	if symptoms, err = openJSON(dk, "cycle_days", "symptoms", symptoms); err != nil {
+		return day, err
+	}
+	if err := json.Unmarshal(symptoms, &day.Symptoms); err != nil {
+		return day, err
+	}
+	day.Note, err = openText(dk, "cycle_days", "note", day.Note)
+	return day, err
+}
+
+func scanCycleSettings(row pgx.Row) (storage.CycleSettings, error) {
+	var settings storage.CycleSettings
+	err := row.Scan(
+		&settings.ProfileID,
+		&settings.ShareWithAI,
+		&settings.RemindersEnabled,
+		&settings.RemindDaysBefore,
+		&settings.RemindFertileWindow,
+		&settings.CycleLengthDays,
+		&settings.PeriodLengthDays,
+		&settings.UpdatedAt,
+	)
+	return settings, err
+}
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresEmailOTPAbuseStorage stores the OTP abuse ledger in PostgreSQL.
+type PostgresEmailOTPAbuseStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresEmailOTPAbuseStorage(pool *pgxpool.Pool) *PostgresEmailOTPAbuseStorage {
+	return &PostgresEmailOTPAbuseStorage{pool: pool}
+}
+
+const emailOTPAbuseColumns = `scope, key, window_start, requests, failures, lockout_level, locked_until, last_event_at`
+
+func (s *PostgresEmailOTPAbuseStorage) GetAbuseRecord(ctx context.Context, scope, key string) (*storage.EmailOTPAbuseRecord, error) {
+	query := `SELECT ` + emailOTPAbuseColumns + ` FROM email_otp_abuse WHERE scope = $1 AND key = $2`
+
+	rec, err := scanEmailOTPAbuseRecord(s.pool.QueryRow(ctx, query, scope, key))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *PostgresEmailOTPAbuseStorage) UpsertAbuseRecord(ctx context.Context, rec storage.EmailOTPAbuseRecord) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO email_otp_abuse (`+emailOTPAbuseColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			window_start = EXCLUDED.window_start,
+			requests = EXCLUDED.requests,
+			failures = EXCLUDED.failures,
+			lockout_level = EXCLUDED.lockout_level,
+			locked_until = EXCLUDED.locked_until,
+			last_event_at = EXCLUDED.last_event_at
+	`, rec.Scope, rec.Key, rec.WindowStart, rec.Requests, rec.Failures, rec.LockoutLevel, rec.LockedUntil, rec.LastEventAt)
+	return err
+}
+
+func (s *PostgresEmailOTPAbuseStorage) DeleteAbuseRecord(ctx context.Context, scope, key string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM email_otp_abuse WHERE scope = $1 AND key = $2`, scope, key)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *PostgresEmailOTPAbuseStorage) ListAbuseRecords(ctx context.Context, filter storage.EmailOTPAbuseFilter) ([]storage.EmailOTPAbuseRecord, error) {
+	query := `SELECT ` + emailOTPAbuseColumns + ` FROM email_otp_abuse WHERE 1=1`
+	args := make([]any, 0, 3)
+
+	if filter.Scope != "" {
+		args = append(args, filter.Scope)
+		query += fmt.Sprintf(" AND scope = $%d", len(args))
+	}
+	if !filter.LockedAt.IsZero() {
+		args = append(args, filter.LockedAt)
+		query += fmt.Sprintf(" AND locked_until > $%d", len(args))
+	}
+	query += " ORDER BY last_event_at DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]storage.EmailOTPAbuseRecord, 0)
+	for rows.Next() {
+		rec, err := scanEmailOTPAbuseRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rec)
+	}
+	return result, rows.Err()
+}
+
+func (s *PostgresEmailOTPAbuseStorage) UseChallengeNonce(ctx context.Context, nonce string, expiresAt, now time.Time) (bool, error) {
+	if _, err := s.pool.Exec(ctx, `DELETE FROM email_otp_challenge_nonces WHERE expires_at <= $1`, now); err != nil {
+		return false, err
+	}
+	tag, err := s.pool.Exec(ctx, `
+		INSERT INTO email_otp_challenge_nonces (nonce, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (nonce) DO NOTHING
+	`, nonce, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func scanEmailOTPAbuseRecord(row pgx.Row) (storage.EmailOTPAbuseRecord, error) {
+	var rec storage.EmailOTPAbuseRecord
+	err := row.Scan(
+		&rec.Scope,
+		&rec.Key,
+		&rec.WindowStart,
+		&rec.Requests,
+		&rec.Failures,
+		&rec.LockoutLevel,
+		&rec.LockedUntil,
+		&rec.LastEventAt,
+	)
+	return rec, err
+}
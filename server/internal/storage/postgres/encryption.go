@@ -0,0 +1,334 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fdg312/health-hub/internal/fieldcrypt"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrFieldKeysNotConfigured = errors.New("row is encrypted but FIELD_ENCRYPTION_KEYS is not configured")
+)
+
+// encryptedColumn — колонка, которая хранится в зашифрованном виде.
+// JSONB-колонки хранят шифртекст как JSON-строку.
+type encryptedColumn struct {
+	name  string
+	jsonb bool
+}
+
+type encryptedTable struct {
+	name    string
+	columns []encryptedColumn
+}
+
+// encryptedTables lists every column covered by field-level encryption.
+var encryptedTables = []encryptedTable{
+	{name: "checkins", columns: []encryptedColumn{{name: "note"}}},
+	{name: "checkin_answers", columns: []encryptedColumn{{name: "value_text"}}},
+	{name: "symptoms", columns: []encryptedColumn{{name: "note"}}},
+	{name: "cycle_periods", columns: []encryptedColumn{{name: "note"}}},
+	{name: "cycle_days", columns: []encryptedColumn{{name: "symptoms", jsonb: true}, {name: "note"}}},
+	{name: "medications", columns: []encryptedColumn{{name: "prescriber"}, {name: "pharmacy"}, {name: "instructions"}}},
+	{name: "medication_doses", columns: []encryptedColumn{{name: "reason"}}},
+	{name: "sources", columns: []encryptedColumn{
+		{name: "text"}, {name: "url"},
+		{name: "preview_title"}, {name: "preview_description"}, {name: "preview_site_name"}, {name: "archive_text"},
+	}},
+	{name: "chat_messages", columns: []encryptedColumn{{name: "content"}}},
+	{name: "chat_threads", columns: []encryptedColumn{{name: "title"}, {name: "summary"}}},
+	{name: "chat_memory_facts", columns: []encryptedColumn{{name: "content"}}},
+	{name: "ai_proposals", columns: []encryptedColumn{{name: "payload", jsonb: true}, {name: "snapshot", jsonb: true}}},
+}
+
+// WithFieldEncryption включает шифрование чувствительных полей для новых записей.
+// Без ключей записи сохраняются открытым текстом.
+func (p *PostgresStorage) WithFieldEncryption(keys *fieldcrypt.Keyring) *PostgresStorage {
+	p.keys = keys
+	p.checkins.keys = keys
+	p.sources.keys = keys
+	p.chat.keys = keys
+	p.proposals.keys = keys
+	p.search.keys = keys
+	p.symptoms.keys = keys
+	p.cycle.keys = keys
+	p.medications.keys = keys
+	return p
+}
+
+// newRowKey returns a fresh data key for a row being written, or nil when
+// encryption is disabled.
+func newRowKey(keys *fieldcrypt.Keyring) (*fieldcrypt.DataKey, error) {
+	if keys == nil {
+		return nil, nil
+	}
+	return keys.NewDataKey()
+}
+
+// openRowKey unwraps the data key stored with a row. Rows without a key ID
+// are plaintext and yield a nil key.
+func openRowKey(keys *fieldcrypt.Keyring, keyID *string, wrapped []byte) (*fieldcrypt.DataKey, error) {
+	if keyID == nil {
+		return nil, nil
+	}
+	if keys == nil {
+		return nil, ErrFieldKeysNotConfigured
+	}
+	return keys.OpenDataKey(*keyID, wrapped)
+}
+
+// rowKeyColumns returns values for enc_key_id and enc_data_key.
+func rowKeyColumns(dk *fieldcrypt.DataKey) (*string, []byte) {
+	if dk == nil {
+		return nil, nil
+	}
+	keyID := dk.KeyID
+	return &keyID, dk.Wrapped
+}
+
+func fieldAAD(table, column string) string {
+	return table + "." + column
+}
+
+func sealText(dk *fieldcrypt.DataKey, table, column, value string) (string, error) {
+	if dk == nil {
+		return value, nil
+	}
+	return dk.SealString(value, fieldAAD(table, column))
+}
+
+func openText(dk *fieldcrypt.DataKey, table, column, value string) (string, error) {
+	if dk == nil {
+		return value, nil
+	}
+	return dk.OpenString(value, fieldAAD(table, column))
+}
+
+func sealOptionalText(dk *fieldcrypt.DataKey, table, column string, value *string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	sealed, err := sealText(dk, table, column, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &sealed, nil
+}
+
+func openOptionalText(dk *fieldcrypt.DataKey, table, column string, value *string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	plain, err := openText(dk, table, column, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &plain, nil
+}
+
+// sealJSON encrypts a JSONB payload into a JSON string literal.
+func sealJSON(dk *fieldcrypt.DataKey, table, column string, payload []byte) ([]byte, error) {
+	if dk == nil {
+		return payload, nil
+	}
+	sealed, err := dk.SealString(string(payload), fieldAAD(table, column))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealed)
+}
+
+func openJSON(dk *fieldcrypt.DataKey, table, column string, payload []byte) ([]byte, error) {
+	if dk == nil {
+		return payload, nil
+	}
+	var sealed string
+	if err := json.Unmarshal(payload, &sealed); err != nil {
+		return nil, fieldcrypt.ErrDecrypt
+	}
+	plain, err := dk.OpenString(sealed, fieldAAD(table, column))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(plain), nil
+}
+
+// ReencryptStats — результат перешифрования одной таблицы.
+type ReencryptStats struct {
+	Table     string
+	Encrypted int64 // plaintext rows encrypted
+	Rewrapped int64 // data keys re-wrapped with the active master key
+}
+
+// PendingReencryption counts rows per table that are plaintext or use a
+// non-active master key.
+func (p *PostgresStorage) PendingReencryption(ctx context.Context) ([]ReencryptStats, error) {
+	if p.keys == nil {
+		return nil, errors.New("field encryption is not configured")
+	}
+
+	result := make([]ReencryptStats, 0, len(encryptedTables))
+	for _, table := range encryptedTables {
+		stats := ReencryptStats{Table: table.name}
+		query := fmt.Sprintf(`
+			SELECT
+				COUNT(*) FILTER (WHERE enc_key_id IS NULL),
+				COUNT(*) FILTER (WHERE enc_key_id IS NOT NULL AND enc_key_id <> $1)
+			FROM %s
+		`, table.name)
+		if err := p.pool.QueryRow(ctx, query, p.keys.ActiveKeyID()).Scan(&stats.Encrypted, &stats.Rewrapped); err != nil {
+			return nil, fmt.Errorf("%s: %w", table.name, err)
+		}
+		result = append(result, stats)
+	}
+	return result, nil
+}
+
+// ReencryptFields brings every row onto the active master key: plaintext rows
+// are encrypted, rows under an older key get their data key re-wrapped.
+// Work is done in batches, each in its own transaction, so it is safe to run
+// against a live database and to resume after interruption.
+func (p *PostgresStorage) ReencryptFields(ctx context.Context, batchSize int) ([]ReencryptStats, error) {
+	if p.keys == nil {
+		return nil, errors.New("field encryption is not configured")
+	}
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	result := make([]ReencryptStats, 0, len(encryptedTables))
+	for _, table := range encryptedTables {
+		stats := ReencryptStats{Table: table.name}
+		for {
+			encrypted, rewrapped, err := p.reencryptBatch(ctx, table, batchSize)
+			if err != nil {
+				return result, fmt.Errorf("%s: %w", table.name, err)
+			}
+			stats.Encrypted += encrypted
+			stats.Rewrapped += rewrapped
+			if encrypted+rewrapped < int64(batchSize) {
+				break
+			}
+		}
+		result = append(result, stats)
+	}
+	return result, nil
+}
+
+type reencryptRow struct {
+	id      uuid.UUID
+	keyID   *string
+	wrapped []byte
+	values  []*string
+}
+
+func (p *PostgresStorage) reencryptBatch(ctx context.Context, table encryptedTable, batchSize int) (int64, int64, error) {
+	selectCols := make([]string, 0, len(table.columns))
+	for _, col := range table.columns {
+		if col.jsonb {
+			selectCols = append(selectCols, col.name+"::text")
+		} else {
+			selectCols = append(selectCols, col.name)
+		}
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf(`
+		SELECT id, enc_key_id, enc_data_key, %s
+		FROM %s
+		WHERE enc_key_id IS NULL OR enc_key_id <> $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE
+	`, strings.Join(selectCols, ", "), table.name)
+
+	rows, err := tx.Query(ctx, query, p.keys.ActiveKeyID(), batchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	var batch []reencryptRow
+	for rows.Next() {
+		row := reencryptRow{values: make([]*string, len(table.columns))}
+		dest := []any{&row.id, &row.keyID, &row.wrapped}
+		for i := range row.values {
+			dest = append(dest, &row.values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		batch = append(batch, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	var encrypted, rewrapped int64
+	for _, row := range batch {
+		if row.keyID != nil {
+			keyID, wrapped, err := p.keys.Rewrap(*row.keyID, row.wrapped)
+			if err != nil {
+				return 0, 0, fmt.Errorf("row %s: %w", row.id, err)
+			}
+			update := fmt.Sprintf(`UPDATE %s SET enc_key_id = $2, enc_data_key = $3 WHERE id = $1`, table.name)
+			if _, err := tx.Exec(ctx, update, row.id, keyID, wrapped); err != nil {
+				return 0, 0, err
+			}
+			rewrapped++
+			continue
+		}
+
+		dk, err := p.keys.NewDataKey()
+		if err != nil {
+			return 0, 0, err
+		}
+		keyID, wrapped := rowKeyColumns(dk)
+		args := []any{row.id, keyID, wrapped}
+		sets := []string{"enc_key_id = $2", "enc_data_key = $3"}
+		for i, col := range table.columns {
+			var sealed any
+			if row.values[i] != nil {
+				if col.jsonb {
+					b, err := sealJSON(dk, table.name, col.name, []byte(*row.values[i]))
+					if err != nil {
+						return 0, 0, err
+					}
+					sealed = string(b)
+				} else {
+					s, err := sealText(dk, table.name, col.name, *row.values[i])
+					if err != nil {
+						return 0, 0, err
+					}
+					sealed = s
+				}
+			}
+			args = append(args, sealed)
+			placeholder := fmt.Sprintf("$%d", len(args))
+			if col.jsonb {
+				placeholder += "::jsonb"
+			}
+			sets = append(sets, col.name+" = "+placeholder)
+		}
+		update := fmt.Sprintf(`UPDATE %s SET %s WHERE id = $1`, table.name, strings.Join(sets, ", "))
+		if _, err := tx.Exec(ctx, update, args...); err != nil {
+			return 0, 0, err
+		}
+		encrypted++
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, err
+	}
+	return encrypted, rewrapped, nil
+}
@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type labResultsStorage struct {
+	pool *pgxpool.Pool
+}
+
+func newLabResultsStorage(pool *pgxpool.Pool) *labResultsStorage {
+	return &labResultsStorage{pool: pool}
+}
+
+const labResultColumns = `
+	id, profile_id, source_id, analyte, name, value, unit, ref_low, ref_high, flag, taken_on, note, created_at, updated_at
+`
+
+func (s *labResultsStorage) CreateLabResult(ctx context.Context, result storage.LabResult) (storage.LabResult, error) {
+	if err := insertLabResult(ctx, s.pool, &result); err != nil {
+		return storage.LabResult{}, err
+	}
+	return result, nil
+}
+
+func (s *labResultsStorage) GetLabResult(ctx context.Context, id uuid.UUID) (storage.LabResult, bool, error) {
+	result, err := scanLabResult(s.pool.QueryRow(ctx, `
+		SELECT `+labResultColumns+`
+		FROM lab_results
+		WHERE id = $1
+	`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.LabResult{}, false, nil
+		}
+		return storage.LabResult{}, false, err
+	}
+	return result, true, nil
+}
+
+func (s *labResultsStorage) UpdateLabResult(ctx context.Context, result storage.LabResult) (storage.LabResult, bool, error) {
+	updated, err := scanLabResult(s.pool.QueryRow(ctx, `
+		UPDATE lab_results
+		SET analyte = $2, name = $3, value = $4, unit = $5, ref_low = $6, ref_high = $7,
+			flag = $8, taken_on = $9, note = $10, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+labResultColumns,
+		result.ID, result.Analyte, result.Name, result.Value, result.Unit, result.RefLow, result.RefHigh,
+		result.Flag, result.TakenOn, result.Note,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.LabResult{}, false, nil
+		}
+		return storage.LabResult{}, false, err
+	}
+	return updated, true, nil
+}
+
+func (s *labResultsStorage) DeleteLabResult(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM lab_results WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *labResultsStorage) ReplaceSourceLabResults(ctx context.Context, sourceID uuid.UUID, results []storage.LabResult) ([]storage.LabResult, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM lab_results WHERE source_id = $1`, sourceID); err != nil {
+		return nil, err
+	}
+
+	saved := make([]storage.LabResult, 0, len(results))
+	for _, result := range results {
+		result.SourceID = &sourceID
+		if err := insertLabResult(ctx, tx, &result); err != nil {
+			return nil, err
+		}
+		saved = append(saved, result)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func (s *labResultsStorage) ListSourceLabResults(ctx context.Context, sourceID uuid.UUID) ([]storage.LabResult, error) {
+	return s.queryLabResults(ctx, `
+		SELECT `+labResultColumns+`
+		FROM lab_results
+		WHERE source_id = $1
+		ORDER BY taken_on, created_at, name
+	`, sourceID)
+}
+
+func (s *labResultsStorage) ListLabResults(ctx context.Context, profileID uuid.UUID, analyte string, from, to time.Time) ([]storage.LabResult, error) {
+	query := `
+		SELECT ` + labResultColumns + `
+		FROM lab_results
+		WHERE profile_id = $1
+	`
+	args := []any{profileID}
+	if analyte != "" {
+		args = append(args, analyte)
+		query += fmt.Sprintf(" AND analyte = $%d", len(args))
+	}
+	if !from.IsZero() {
+		args = append(args, from)
+		query += fmt.Sprintf(" AND taken_on >= $%d", len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to)
+		query += fmt.Sprintf(" AND taken_on <= $%d", len(args))
+	}
+	query += ` ORDER BY taken_on, created_at, name`
+
+	return s.queryLabResults(ctx, query, args...)
+}
+
+// labResultInserter is a pool or a transaction.
+type labResultInserter interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func insertLabResult(ctx context.Context, db labResultInserter, result *storage.LabResult) error {
+	if result.ID == uuid.Nil {
+		result.ID = uuid.New()
+	}
+	return db.QueryRow(ctx, `
+		INSERT INTO lab_results (
+			id, profile_id, source_id, analyte, name, value, unit, ref_low, ref_high, flag, taken_on, note,
+			created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW())
+		RETURNING created_at, updated_at
+	`,
+		result.ID, result.ProfileID, result.SourceID, result.Analyte, result.Name, result.Value,
+		result.Unit, result.RefLow, result.RefHigh, result.Flag, result.TakenOn, result.Note,
+	).Scan(&result.CreatedAt, &result.UpdatedAt)
+}
+
+func (s *labResultsStorage) queryLabResults(ctx context.Context, query string, args ...any) ([]storage.LabResult, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]storage.LabResult, 0)
+	for rows.Next() {
+		result, err := scanLabResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func scanLabResult(row pgx.Row) (storage.LabResult, error) {
+	var result storage.LabResult
+	err := row.Scan(
+		&result.ID,
+		&result.ProfileID,
+		&result.SourceID,
+		&result.Analyte,
+		&result.Name,
+		&result.Value,
+		&result.Unit,
+		&result.RefLow,
+		&result.RefHigh,
+		&result.Flag,
+		&result.TakenOn,
+		&result.Note,
+		&result.CreatedAt,
+		&result.UpdatedAt,
+	)
+	return result, err
+}
@@ -0,0 +1,421 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/fieldcrypt"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// medicationsStorage keeps medications and their dose log. Prescriber,
+// pharmacy, instructions and dose reasons are encrypted.
+type medicationsStorage struct {
+	pool *pgxpool.Pool
+	keys *fieldcrypt.Keyring
+}
+
+func newMedicationsStorage(pool *pgxpool.Pool) *medicationsStorage {
+	return &medicationsStorage{pool: pool}
+}
+
+const medicationColumns = `
+	id, profile_id, name, form, strength, ingredients, dose_amount, dose_unit, schedule, days_mask,
+	as_needed, max_daily_doses, min_interval_hours, stock_quantity, refill_threshold,
+	prescriber, pharmacy, instructions, active, created_at, updated_at, enc_key_id, enc_data_key
+`
+
+const medicationDoseColumns = `
+	id, profile_id, medication_id, taken_at, amount, unit, status, as_needed, reason, stock_deducted,
+	created_at, enc_key_id, enc_data_key
+`
+
+// adjustStockQuery adds $2 to a tracked stock, never going below zero.
+// Untracked stock (NULL) stays NULL.
+const adjustStockQuery = `
+	UPDATE medications
+	SET stock_quantity = GREATEST(stock_quantity + $2, 0),
+		updated_at = CASE WHEN stock_quantity IS NULL THEN updated_at ELSE NOW() END
+	WHERE id = $1
+`
+
+// medicationTimeJSON is the stored form of a schedule entry.
+type medicationTimeJSON struct {
+	TimeMinutes int     `json:"time_minutes"`
+	Amount      float64 `json:"amount,omitempty"`
+}
+
+func (s *medicationsStorage) CreateMedication(ctx context.Context, medication storage.Medication) (storage.Medication, error) {
+	if medication.ID == uuid.Nil {
+		medication.ID = uuid.New()
+	}
+	args, err := s.medicationArgs(medication)
+	if err != nil {
+		return storage.Medication{}, err
+	}
+
+	return s.scanMedication(s.pool.QueryRow(ctx, `
+		INSERT INTO medications (
+			id, profile_id, name, form, strength, ingredients, dose_amount, dose_unit, schedule, days_mask,
+			as_needed, max_daily_doses, min_interval_hours, stock_quantity, refill_threshold,
+			prescriber, pharmacy, instructions, active, enc_key_id, enc_data_key, created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, NOW(), NOW())
+		RETURNING `+medicationColumns,
+		args...,
+	))
+}
+
+func (s *medicationsStorage) GetMedication(ctx context.Context, id uuid.UUID) (storage.Medication, bool, error) {
+	medication, err := s.scanMedication(s.pool.QueryRow(ctx, `
+		SELECT `+medicationColumns+`
+		FROM medications
+		WHERE id = $1
+	`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Medication{}, false, nil
+		}
+		return storage.Medication{}, false, err
+	}
+	return medication, true, nil
+}
+
+func (s *medicationsStorage) ListMedications(ctx context.Context, profileID uuid.UUID, activeOnly bool) ([]storage.Medication, error) {
+	query := `
+		SELECT ` + medicationColumns + `
+		FROM medications
+		WHERE profile_id = $1
+	`
+	if activeOnly {
+		query += ` AND active`
+	}
+	query += ` ORDER BY lower(name), created_at`
+
+	rows, err := s.pool.Query(ctx, query, profileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	medications := make([]storage.Medication, 0)
+	for rows.Next() {
+		medication, err := s.scanMedication(rows)
+		if err != nil {
+			return nil, err
+		}
+		medications = append(medications, medication)
+	}
+	return medications, rows.Err()
+}
+
+func (s *medicationsStorage) UpdateMedication(ctx context.Context, medication storage.Medication, setStock bool) (storage.Medication, bool, error) {
+	args, err := s.medicationArgs(medication)
+	if err != nil {
+		return storage.Medication{}, false, err
+	}
+	args = append(args, setStock)
+
+	updated, err := s.scanMedication(s.pool.QueryRow(ctx, `
+		UPDATE medications
+		SET name = $3, form = $4, strength = $5, ingredients = $6, dose_amount = $7, dose_unit = $8,
+			schedule = $9, days_mask = $10, as_needed = $11, max_daily_doses = $12, min_interval_hours = $13,
+			stock_quantity = CASE WHEN $22 THEN $14 ELSE stock_quantity END, refill_threshold = $15, prescriber = $16, pharmacy = $17,
+			instructions = $18, active = $19, enc_key_id = $20, enc_data_key = $21, updated_at = NOW()
+		WHERE id = $1 AND profile_id = $2
+		RETURNING `+medicationColumns,
+		args...,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Medication{}, false, nil
+		}
+		return storage.Medication{}, false, err
+	}
+	return updated, true, nil
+}
+
+func (s *medicationsStorage) DeleteMedication(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM medications WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *medicationsStorage) AdjustMedicationStock(ctx context.Context, id uuid.UUID, delta float64) (storage.Medication, bool, error) {
+	medication, err := s.scanMedication(s.pool.QueryRow(ctx, adjustStockQuery+` RETURNING `+medicationColumns, id, delta))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Medication{}, false, nil
+		}
+		return storage.Medication{}, false, err
+	}
+	return medication, true, nil
+}
+
+func (s *medicationsStorage) CreateMedicationDose(ctx context.Context, dose storage.MedicationDose) (storage.MedicationDose, error) {
+	if dose.ID == uuid.Nil {
+		dose.ID = uuid.New()
+	}
+	dk, err := newRowKey(s.keys)
+	if err != nil {
+		return storage.MedicationDose{}, err
+	}
+	reason, err := sealText(dk, "medication_doses", "reason", dose.Reason)
+	if err != nil {
+		return storage.MedicationDose{}, err
+	}
+	keyID, wrappedKey := rowKeyColumns(dk)
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return storage.MedicationDose{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// A taken dose deducts at most the remaining stock, so deleting it
+	// later restores exactly what it took.
+	dose.StockDeducted = 0
+	if dose.Status == "taken" {
+		var stock *float64
+		err := tx.QueryRow(ctx, `SELECT stock_quantity FROM medications WHERE id = $1 FOR UPDATE`, dose.MedicationID).Scan(&stock)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return storage.MedicationDose{}, err
+		}
+		if stock != nil {
+			dose.StockDeducted = min(dose.Amount, *stock)
+		}
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO medication_doses (
+			id, profile_id, medication_id, taken_at, amount, unit, status, as_needed, reason, stock_deducted,
+			enc_key_id, enc_data_key, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW())
+		RETURNING created_at
+	`, dose.ID, dose.ProfileID, dose.MedicationID, dose.TakenAt, dose.Amount, dose.Unit, dose.Status,
+		dose.AsNeeded, reason, dose.StockDeducted, keyID, wrappedKey,
+	).Scan(&dose.CreatedAt)
+	if err != nil {
+		return storage.MedicationDose{}, err
+	}
+	if dose.StockDeducted > 0 {
+		if _, err := tx.Exec(ctx, adjustStockQuery, dose.MedicationID, -dose.StockDeducted); err != nil {
+			return storage.MedicationDose{}, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return storage.MedicationDose{}, err
+	}
+	return dose, nil
+}
+
+func (s *medicationsStorage) GetMedicationDose(ctx context.Context, id uuid.UUID) (storage.MedicationDose, bool, error) {
+	dose, err := s.scanDose(s.pool.QueryRow(ctx, `
+		SELECT `+medicationDoseColumns+`
+		FROM medication_doses
+		WHERE id = $1
+	`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.MedicationDose{}, false, nil
+		}
+		return storage.MedicationDose{}, false, err
+	}
+	return dose, true, nil
+}
+
+func (s *medicationsStorage) DeleteMedicationDose(ctx context.Context, id uuid.UUID) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var medicationID uuid.UUID
+	var deducted float64
+	err = tx.QueryRow(ctx, `
+		DELETE FROM medication_doses
+		WHERE id = $1
+		RETURNING medication_id, stock_deducted
+	`, id).Scan(&medicationID, &deducted)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	if deducted > 0 {
+		if _, err := tx.Exec(ctx, adjustStockQuery, medicationID, deducted); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit(ctx)
+}
+
+func (s *medicationsStorage) ListMedicationDoses(ctx context.Context, profileID, medicationID uuid.UUID, from, to time.Time) ([]storage.MedicationDose, error) {
+	query := `
+		SELECT ` + medicationDoseColumns + `
+		FROM medication_doses
+		WHERE profile_id = $1 AND taken_at >= $2 AND taken_at < $3
+	`
+	args := []any{profileID, from, to}
+	if medicationID != uuid.Nil {
+		args = append(args, medicationID)
+		query += fmt.Sprintf(" AND medication_id = $%d", len(args))
+	}
+	query += ` ORDER BY taken_at`
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	doses := make([]storage.MedicationDose, 0)
+	for rows.Next() {
+		dose, err := s.scanDose(rows)
+		if err != nil {
+			return nil, err
+		}
+		doses = append(doses, dose)
+	}
+	return doses, rows.Err()
+}
+
+// medicationArgs returns the column values of a medication in insert
+// order, sealing the sensitive ones with a fresh row key.
+func (s *medicationsStorage) medicationArgs(medication storage.Medication) ([]any, error) {
+	if medication.Ingredients == nil {
+		medication.Ingredients = []string{}
+	}
+	schedule := make([]medicationTimeJSON, 0, len(medication.Schedule))
+	for _, t := range medication.Schedule {
+		schedule = append(schedule, medicationTimeJSON{TimeMinutes: t.TimeMinutes, Amount: t.Amount})
+	}
+	scheduleJSON, err := json.Marshal(schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	dk, err := newRowKey(s.keys)
+	if err != nil {
+		return nil, err
+	}
+	sealed := make([]string, 3)
+	for i, field := range []struct{ column, value string }{
+		{"prescriber", medication.Prescriber},
+		{"pharmacy", medication.Pharmacy},
+		{"instructions", medication.Instructions},
+	} {
+		if sealed[i], err = sealText(dk, "medications", field.column, field.value); err != nil {
+			return nil, err
+		}
+	}
+	keyID, wrappedKey := rowKeyColumns(dk)
+
+	return []any{
+		medication.ID, medication.ProfileID, medication.Name, medication.Form, medication.Strength,
+		medication.Ingredients, medication.DoseAmount, medication.DoseUnit, scheduleJSON, medication.DaysMask,
+		medication.AsNeeded, medication.MaxDailyDoses, medication.MinIntervalHours, medication.StockQuantity,
+		medication.RefillThreshold, sealed[0], sealed[1], sealed[2], medication.Active, keyID, wrappedKey,
+	}, nil
+}
+
+// scanMedication scans a row of medicationColumns and decrypts the
+// sensitive fields.
+func (s *medicationsStorage) scanMedication(row pgx.Row) (storage.Medication, error) {
+	var medication storage.Medication
+	var schedule []byte
+	var keyID *string
+	var wrappedKey []byte
+	err := row.Scan(
+		&medication.ID,
+		&medication.ProfileID,
+		&medication.Name,
+		&medication.Form,
+		&medication.Strength,
+		&medication.Ingredients,
+		&medication.DoseAmount,
+		&medication.DoseUnit,
+		&schedule,
+		&medication.DaysMask,
+		&medication.AsNeeded,
+		&medication.MaxDailyDoses,
+		&medication.MinIntervalHours,
+		&medication.StockQuantity,
+		&medication.RefillThreshold,
+		&medication.Prescriber,
+		&medication.Pharmacy,
+		&medication.Instructions,
+		&medication.Active,
+		&medication.CreatedAt,
+		&medication.UpdatedAt,
+		&keyID,
+		&wrappedKey,
+	)
+	if err != nil {
+		return medication, err
+	}
+
+	var times []medicationTimeJSON
+	if err := json.Unmarshal(schedule, &times); err != nil {
+		return medication, err
+	}
+	for _, t := range times {
+		medication.Schedule = append(medication.Schedule, storage.MedicationTime{TimeMinutes: t.TimeMinutes, Amount: t.Amount})
+	}
+
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return medication, err
+	}
+	if medication.Prescriber, err = openText(dk, "medications", "prescriber", medication.Prescriber); err != nil {
+		return medication, err
+	}
+	if medication.Pharmacy, err = openText(dk, "medications", "pharmacy", medication.Pharmacy); err != nil {
+		return medication, err
+	}
+	medication.Instructions, err = openText(dk, "medications", "instructions", medication.Instructions)
+	return medication, err
+}
+
+// scanDose scans a row of medicationDoseColumns and decrypts the reason.
+func (s *medicationsStorage) scanDose(row pgx.Row) (storage.MedicationDose, error) {
+	var dose storage.MedicationDose
+	var keyID *string
+	var wrappedKey []byte
+	err := row.Scan(
+		&dose.ID,
+		&dose.ProfileID,
+		&dose.MedicationID,
+		&dose.TakenAt,
+		&dose.Amount,
+		&dose.Unit,
+		&dose.Status,
+		&dose.AsNeeded,
+		&dose.Reason,
+		&dose.StockDeducted,
+		&dose.CreatedAt,
+		&keyID,
+		&wrappedKey,
+	)
+	if err != nil {
+		return dose, err
+	}
+
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return dose, err
+	}
+	dose.Reason, err = openText(dk, "medication_doses", "reason", dose.Reason)
+	return dose, err
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"time"
 
+	"github.com/fdg312/health-hub/internal/fieldcrypt"
 	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/telemetry"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -18,6 +20,7 @@ var (
 // PostgresStorage — Postgres реализация Storage и MetрicsStorage
 type PostgresStorage struct {
 	pool               *pgxpool.Pool
+	keys               *fieldcrypt.Keyring
 	metrics            *PostgresMetricsStorage
 	checkins           *PostgresCheckinsStorage
 	reports            *PostgresReportsStorage
@@ -27,6 +30,8 @@ type PostgresStorage struct {
 	schedules          *PostgresSupplementSchedulesStorage
 	intakes            *PostgresIntakesStorage
 	emailOTPs          *PostgresEmailOTPStorage
+	emailOTPAbuse      *PostgresEmailOTPAbuseStorage
+	audit              *PostgresAuditStorage
 	settings           *PostgresSettingsStorage
 	chat               *PostgresChatStorage
 	proposals          *PostgresProposalsStorage
@@ -36,11 +41,25 @@ type PostgresStorage struct {
 	nutritionTargets   *nutritionTargetsStorage
 	foodPrefs          *foodPrefsStorage
 	mealPlans          *mealPlansStorage
+	aiConsent          *aiConsentStorage
+	aiUsage            *aiUsageStorage
+	coaching           *coachingStorage
+	labResults         *labResultsStorage
+	symptoms           *symptomsStorage
+	cycle              *cycleStorage
+	medications        *medicationsStorage
+	search             *searchStorage
 }
 
 // New создаёт PostgresStorage и обеспечивает owner профиль по умолчанию
 func New(ctx context.Context, databaseURL string) (*PostgresStorage, error) {
-	pool, err := pgxpool.New(ctx, databaseURL)
+	poolConfig, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.ConnConfig.Tracer = telemetry.PgxTracer{}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -60,6 +79,8 @@ func New(ctx context.Context, databaseURL string) (*PostgresStorage, error) {
 		schedules:          NewPostgresSupplementSchedulesStorage(pool),
 		intakes:            NewPostgresIntakesStorage(pool),
 		emailOTPs:          NewPostgresEmailOTPStorage(pool),
+		emailOTPAbuse:      NewPostgresEmailOTPAbuseStorage(pool),
+		audit:              NewPostgresAuditStorage(pool),
 		settings:           NewPostgresSettingsStorage(pool),
 		chat:               NewPostgresChatStorage(pool),
 		proposals:          NewPostgresProposalsStorage(pool),
@@ -69,7 +90,15 @@ func New(ctx context.Context, databaseURL string) (*PostgresStorage, error) {
 		nutritionTargets:   newNutritionTargetsStorage(pool),
 		foodPrefs:          newFoodPrefsStorage(pool),
 		mealPlans:          newMealPlansStorage(pool),
+		aiConsent:          newAIConsentStorage(pool),
+		aiUsage:            newAIUsageStorage(pool),
+		coaching:           newCoachingStorage(pool),
+		labResults:         newLabResultsStorage(pool),
+		symptoms:           newSymptomsStorage(pool),
+		cycle:              newCycleStorage(pool),
+		medications:        newMedicationsStorage(pool),
 	}
+	ps.search = newSearchStorage(pool, ps.sources, ps.checkins)
 
 	// Создаём owner профиль, если его нет
 	if err := ps.ensureOwnerProfile(ctx); err != nil {
@@ -230,6 +259,11 @@ func (p *PostgresStorage) DeleteProfile(ctx context.Context, id uuid.UUID) error
 	return nil
 }
 
+// PoolStat возвращает статистику пула соединений для /metrics.
+func (p *PostgresStorage) PoolStat() *pgxpool.Stat {
+	return p.pool.Stat()
+}
+
 func (p *PostgresStorage) Close() error {
 	p.pool.Close()
 	return nil
@@ -401,6 +435,16 @@ func (p *PostgresStorage) UpdateResendMeta(ctx context.Context, id uuid.UUID, la
 	return p.emailOTPs.UpdateResendMeta(ctx, id, lastSentAt, sendCount)
 }
 
+// GetEmailOTPAbuseStorage returns the email OTP abuse ledger.
+func (p *PostgresStorage) GetEmailOTPAbuseStorage() storage.EmailOTPAbuseStorage {
+	return p.emailOTPAbuse
+}
+
+// GetAuditStorage returns audit log storage.
+func (p *PostgresStorage) GetAuditStorage() storage.AuditStorage {
+	return p.audit
+}
+
 // GetSettingsStorage returns settings storage.
 func (p *PostgresStorage) GetSettingsStorage() *PostgresSettingsStorage {
 	return p.settings
@@ -427,14 +471,50 @@ func (p *PostgresStorage) UpsertSettings(ctx context.Context, ownerUserID string
 }
 
 // ChatStorage methods - delegate to embedded chat storage.
-func (p *PostgresStorage) InsertMessage(ctx context.Context, ownerUserID string, profileID uuid.UUID, role, content string) (storage.ChatMessage, error) {
-	return p.chat.InsertMessage(ctx, ownerUserID, profileID, role, content)
+func (p *PostgresStorage) InsertMessage(ctx context.Context, ownerUserID string, profileID, threadID uuid.UUID, role, content string) (storage.ChatMessage, error) {
+	return p.chat.InsertMessage(ctx, ownerUserID, profileID, threadID, role, content)
 }
 
 func (p *PostgresStorage) ListMessages(ctx context.Context, ownerUserID string, profileID uuid.UUID, limit int, before *time.Time) ([]storage.ChatMessage, *time.Time, error) {
 	return p.chat.ListMessages(ctx, ownerUserID, profileID, limit, before)
 }
 
+func (p *PostgresStorage) ListThreadMessages(ctx context.Context, ownerUserID string, threadID uuid.UUID, limit int, before *time.Time) ([]storage.ChatMessage, *time.Time, error) {
+	return p.chat.ListThreadMessages(ctx, ownerUserID, threadID, limit, before)
+}
+
+func (p *PostgresStorage) CreateThread(ctx context.Context, ownerUserID string, profileID uuid.UUID, title string) (storage.ChatThread, error) {
+	return p.chat.CreateThread(ctx, ownerUserID, profileID, title)
+}
+
+func (p *PostgresStorage) GetThread(ctx context.Context, ownerUserID string, threadID uuid.UUID) (storage.ChatThread, bool, error) {
+	return p.chat.GetThread(ctx, ownerUserID, threadID)
+}
+
+func (p *PostgresStorage) ListThreads(ctx context.Context, ownerUserID string, profileID uuid.UUID, includeArchived bool) ([]storage.ChatThread, error) {
+	return p.chat.ListThreads(ctx, ownerUserID, profileID, includeArchived)
+}
+
+func (p *PostgresStorage) UpdateThread(ctx context.Context, ownerUserID string, threadID uuid.UUID, title *string, archived *bool) (storage.ChatThread, bool, error) {
+	return p.chat.UpdateThread(ctx, ownerUserID, threadID, title, archived)
+}
+
+func (p *PostgresStorage) SaveThreadSummary(ctx context.Context, ownerUserID string, threadID uuid.UUID, summary string, summarizedUntil time.Time) error {
+	return p.chat.SaveThreadSummary(ctx, ownerUserID, threadID, summary, summarizedUntil)
+}
+
+func (p *PostgresStorage) InsertFact(ctx context.Context, ownerUserID string, profileID uuid.UUID, content string) (storage.ChatFact, error) {
+	return p.chat.InsertFact(ctx, ownerUserID, profileID, content)
+}
+
+func (p *PostgresStorage) ListFacts(ctx context.Context, ownerUserID string, profileID uuid.UUID) ([]storage.ChatFact, error) {
+	return p.chat.ListFacts(ctx, ownerUserID, profileID)
+}
+
+func (p *PostgresStorage) DeleteFact(ctx context.Context, ownerUserID string, factID uuid.UUID) (bool, error) {
+	return p.chat.DeleteFact(ctx, ownerUserID, factID)
+}
+
 // ProposalsStorage methods - delegate to embedded proposals storage.
 func (p *PostgresStorage) InsertMany(ctx context.Context, ownerUserID string, profileID uuid.UUID, drafts []storage.ProposalDraft) ([]storage.AIProposal, error) {
 	return p.proposals.InsertMany(ctx, ownerUserID, profileID, drafts)
@@ -444,12 +524,40 @@ func (p *PostgresStorage) Get(ctx context.Context, ownerUserID string, proposalI
 	return p.proposals.Get(ctx, ownerUserID, proposalID)
 }
 
-func (p *PostgresStorage) UpdateStatus(ctx context.Context, ownerUserID string, proposalID uuid.UUID, status string) error {
-	return p.proposals.UpdateStatus(ctx, ownerUserID, proposalID, status)
+func (p *PostgresStorage) UpdateStatus(ctx context.Context, ownerUserID string, proposalID uuid.UUID, from, status string) (bool, error) {
+	return p.proposals.UpdateStatus(ctx, ownerUserID, proposalID, from, status)
+}
+
+func (p *PostgresStorage) ClaimPending(ctx context.Context, ownerUserID string, proposalID uuid.UUID) (bool, error) {
+	return p.proposals.ClaimPending(ctx, ownerUserID, proposalID)
 }
 
-func (p *PostgresStorage) List(ctx context.Context, ownerUserID string, profileID uuid.UUID, status string, limit int) ([]storage.AIProposal, error) {
-	return p.proposals.List(ctx, ownerUserID, profileID, status, limit)
+func (p *PostgresStorage) ReleaseClaim(ctx context.Context, ownerUserID string, proposalID uuid.UUID) error {
+	return p.proposals.ReleaseClaim(ctx, ownerUserID, proposalID)
+}
+
+func (p *PostgresStorage) MarkApplied(ctx context.Context, ownerUserID string, proposalID uuid.UUID, snapshot []byte) (storage.AIProposal, bool, error) {
+	return p.proposals.MarkApplied(ctx, ownerUserID, proposalID, snapshot)
+}
+
+func (p *PostgresStorage) List(ctx context.Context, ownerUserID string, profileID uuid.UUID, statuses []string, limit int) ([]storage.AIProposal, error) {
+	return p.proposals.List(ctx, ownerUserID, profileID, statuses, limit)
+}
+
+func (p *PostgresStorage) SupersedePending(ctx context.Context, ownerUserID string, profileID uuid.UUID, kind string, keep []uuid.UUID) (int64, error) {
+	return p.proposals.SupersedePending(ctx, ownerUserID, profileID, kind, keep)
+}
+
+func (p *PostgresStorage) ExpirePending(ctx context.Context, now time.Time) (int64, error) {
+	return p.proposals.ExpirePending(ctx, now)
+}
+
+func (p *PostgresStorage) MarkViewed(ctx context.Context, ownerUserID string, proposalID uuid.UUID) error {
+	return p.proposals.MarkViewed(ctx, ownerUserID, proposalID)
+}
+
+func (p *PostgresStorage) ListEvents(ctx context.Context, ownerUserID string, proposalID uuid.UUID) ([]storage.AIProposalEvent, error) {
+	return p.proposals.ListEvents(ctx, ownerUserID, proposalID)
 }
 
 // IntakesStorage methods - delegate to embedded intakes storage
@@ -526,6 +634,10 @@ func (p *PostgresStorage) UpsertActivePlan(ownerUserID string, profileID uuid.UU
 	return p.workoutPlans.UpsertActivePlan(ownerUserID, profileID, title, goal)
 }
 
+func (p *PostgresStorage) DeactivateActivePlan(ownerUserID string, profileID uuid.UUID) error {
+	return p.workoutPlans.DeactivateActivePlan(ownerUserID, profileID)
+}
+
 // WorkoutPlanItemsStorage methods - delegate to embedded workout plan items storage.
 
 func (p *PostgresStorage) ListItems(ownerUserID string, profileID uuid.UUID, planID uuid.UUID) ([]storage.WorkoutPlanItem, error) {
@@ -561,3 +673,43 @@ func (p *PostgresStorage) GetFoodPrefsStorage() storage.FoodPrefsStorage {
 func (p *PostgresStorage) GetMealPlansStorage() storage.MealPlansStorage {
 	return p.mealPlans
 }
+
+// GetAIConsentStorage returns AI consent storage.
+func (p *PostgresStorage) GetAIConsentStorage() storage.AIConsentStorage {
+	return p.aiConsent
+}
+
+// GetAIUsageStorage returns AI token usage storage.
+func (p *PostgresStorage) GetAIUsageStorage() storage.AIUsageStorage {
+	return p.aiUsage
+}
+
+// GetCoachingStorage returns coaching programs storage.
+func (p *PostgresStorage) GetCoachingStorage() storage.CoachingStorage {
+	return p.coaching
+}
+
+// GetLabResultsStorage returns lab results storage.
+func (p *PostgresStorage) GetLabResultsStorage() storage.LabResultsStorage {
+	return p.labResults
+}
+
+// GetSymptomsStorage returns symptom log storage.
+func (p *PostgresStorage) GetSymptomsStorage() storage.SymptomsStorage {
+	return p.symptoms
+}
+
+// GetCycleStorage returns menstrual cycle storage.
+func (p *PostgresStorage) GetCycleStorage() storage.CycleStorage {
+	return p.cycle
+}
+
+// GetMedicationsStorage returns medication and dose log storage.
+func (p *PostgresStorage) GetMedicationsStorage() storage.MedicationsStorage {
+	return p.medications
+}
+
+// GetSearchStorage returns full-text search storage.
+func (p *PostgresStorage) GetSearchStorage() storage.SearchStorage {
+	return p.search
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/fdg312/health-hub/internal/fieldcrypt"
 	"github.com/fdg312/health-hub/internal/storage"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -14,6 +15,7 @@ import (
 
 type PostgresProposalsStorage struct {
 	pool *pgxpool.Pool
+	keys *fieldcrypt.Keyring
 }
 
 func NewPostgresProposalsStorage(pool *pgxpool.Pool) *PostgresProposalsStorage {
@@ -28,9 +30,10 @@ func (s *PostgresProposalsStorage) InsertMany(ctx context.Context, ownerUserID s
 
 	const query = `
 		INSERT INTO ai_proposals (
-			id, owner_user_id, profile_id, created_at, status, kind, title, summary, payload
+			id, owner_user_id, profile_id, created_at, status, kind, title, summary, payload,
+			expires_at, enc_key_id, enc_data_key
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 	`
 
 	tx, err := s.pool.Begin(ctx)
@@ -51,6 +54,7 @@ func (s *PostgresProposalsStorage) InsertMany(ctx context.Context, ownerUserID s
 			Title:       strings.TrimSpace(draft.Title),
 			Summary:     strings.TrimSpace(draft.Summary),
 			Payload:     draft.Payload,
+			ExpiresAt:   draft.ExpiresAt,
 		}
 		if proposal.Title == "" {
 			proposal.Title = "Предложение"
@@ -59,6 +63,16 @@ func (s *PostgresProposalsStorage) InsertMany(ctx context.Context, ownerUserID s
 			proposal.Summary = "Ассистент сформировал предложение."
 		}
 
+		dk, err := newRowKey(s.keys)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := sealJSON(dk, "ai_proposals", "payload", proposal.Payload)
+		if err != nil {
+			return nil, err
+		}
+		keyID, wrappedKey := rowKeyColumns(dk)
+
 		if _, err := tx.Exec(ctx, query,
 			proposal.ID,
 			proposal.OwnerUserID,
@@ -68,10 +82,16 @@ func (s *PostgresProposalsStorage) InsertMany(ctx context.Context, ownerUserID s
 			proposal.Kind,
 			proposal.Title,
 			proposal.Summary,
-			proposal.Payload,
+			payload,
+			proposal.ExpiresAt,
+			keyID,
+			wrappedKey,
 		); err != nil {
 			return nil, err
 		}
+		if err := insertProposalEvent(ctx, tx, proposal.ID, proposal.OwnerUserID, "created"); err != nil {
+			return nil, err
+		}
 		saved = append(saved, proposal)
 	}
 
@@ -85,24 +105,14 @@ func (s *PostgresProposalsStorage) Get(ctx context.Context, ownerUserID string,
 	ownerUserID = strings.TrimSpace(ownerUserID)
 
 	const query = `
-		SELECT id, owner_user_id, profile_id, created_at, status, kind, title, summary, payload
+		SELECT id, owner_user_id, profile_id, created_at, status, kind, title, summary, payload,
+		       applied_at, snapshot, expires_at, enc_key_id, enc_data_key
 		FROM ai_proposals
 		WHERE owner_user_id = $1
 		  AND id = $2
 	`
 
-	var proposal storage.AIProposal
-	err := s.pool.QueryRow(ctx, query, ownerUserID, proposalID).Scan(
-		&proposal.ID,
-		&proposal.OwnerUserID,
-		&proposal.ProfileID,
-		&proposal.CreatedAt,
-		&proposal.Status,
-		&proposal.Kind,
-		&proposal.Title,
-		&proposal.Summary,
-		&proposal.Payload,
-	)
+	proposal, err := s.scanProposal(s.pool.QueryRow(ctx, query, ownerUserID, proposalID))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return storage.AIProposal{}, false, nil
@@ -112,7 +122,7 @@ func (s *PostgresProposalsStorage) Get(ctx context.Context, ownerUserID string,
 	return proposal, true, nil
 }
 
-func (s *PostgresProposalsStorage) UpdateStatus(ctx context.Context, ownerUserID string, proposalID uuid.UUID, status string) error {
+func (s *PostgresProposalsStorage) UpdateStatus(ctx context.Context, ownerUserID string, proposalID uuid.UUID, from, status string) (bool, error) {
 	ownerUserID = strings.TrimSpace(ownerUserID)
 	status = normalizeProposalStatus(status)
 
@@ -121,36 +131,123 @@ func (s *PostgresProposalsStorage) UpdateStatus(ctx context.Context, ownerUserID
 		SET status = $3
 		WHERE owner_user_id = $1
 		  AND id = $2
+		  AND status = $4
 	`
 
-	result, err := s.pool.Exec(ctx, query, ownerUserID, proposalID, status)
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, query, ownerUserID, proposalID, status, strings.TrimSpace(from))
 	if err != nil {
-		return err
+		return false, err
 	}
 	if result.RowsAffected() == 0 {
-		return ErrNotFound
+		return false, nil
+	}
+	if err := insertProposalEvent(ctx, tx, proposalID, ownerUserID, status); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func (s *PostgresProposalsStorage) ClaimPending(ctx context.Context, ownerUserID string, proposalID uuid.UUID) (bool, error) {
+	result, err := s.pool.Exec(ctx, `
+		UPDATE ai_proposals
+		SET status = 'applying'
+		WHERE owner_user_id = $1 AND id = $2 AND status = 'pending'
+	`, strings.TrimSpace(ownerUserID), proposalID)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (s *PostgresProposalsStorage) ReleaseClaim(ctx context.Context, ownerUserID string, proposalID uuid.UUID) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE ai_proposals
+		SET status = 'pending'
+		WHERE owner_user_id = $1 AND id = $2 AND status = 'applying'
+	`, strings.TrimSpace(ownerUserID), proposalID)
+	return err
+}
+
+func (s *PostgresProposalsStorage) MarkApplied(ctx context.Context, ownerUserID string, proposalID uuid.UUID, snapshot []byte) (storage.AIProposal, bool, error) {
+	ownerUserID = strings.TrimSpace(ownerUserID)
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return storage.AIProposal{}, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The snapshot is sealed with the row's own data key, like the payload.
+	var keyID *string
+	var wrappedKey []byte
+	err = tx.QueryRow(ctx, `
+		SELECT enc_key_id, enc_data_key
+		FROM ai_proposals
+		WHERE owner_user_id = $1 AND id = $2 AND status = 'applying'
+		FOR UPDATE
+	`, ownerUserID, proposalID).Scan(&keyID, &wrappedKey)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.AIProposal{}, false, nil
+		}
+		return storage.AIProposal{}, false, err
+	}
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return storage.AIProposal{}, false, err
+	}
+	sealed, err := sealJSON(dk, "ai_proposals", "snapshot", snapshot)
+	if err != nil {
+		return storage.AIProposal{}, false, err
+	}
+
+	proposal, err := s.scanProposal(tx.QueryRow(ctx, `
+		UPDATE ai_proposals
+		SET status = 'applied', applied_at = NOW(), snapshot = $3
+		WHERE owner_user_id = $1 AND id = $2
+		RETURNING id, owner_user_id, profile_id, created_at, status, kind, title, summary, payload,
+		          applied_at, snapshot, expires_at, enc_key_id, enc_data_key
+	`, ownerUserID, proposalID, sealed))
+	if err != nil {
+		return storage.AIProposal{}, false, err
+	}
+	if err := insertProposalEvent(ctx, tx, proposalID, ownerUserID, "applied"); err != nil {
+		return storage.AIProposal{}, false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return storage.AIProposal{}, false, err
 	}
-	return nil
+	return proposal, true, nil
 }
 
-func (s *PostgresProposalsStorage) List(ctx context.Context, ownerUserID string, profileID uuid.UUID, status string, limit int) ([]storage.AIProposal, error) {
+func (s *PostgresProposalsStorage) List(ctx context.Context, ownerUserID string, profileID uuid.UUID, statuses []string, limit int) ([]storage.AIProposal, error) {
 	ownerUserID = strings.TrimSpace(ownerUserID)
-	status = strings.TrimSpace(status)
+	wanted := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		wanted = append(wanted, strings.TrimSpace(status))
+	}
 	if limit <= 0 {
 		limit = 50
 	}
 
 	const query = `
-		SELECT id, owner_user_id, profile_id, created_at, status, kind, title, summary, payload
+		SELECT id, owner_user_id, profile_id, created_at, status, kind, title, summary, payload,
+		       applied_at, snapshot, expires_at, enc_key_id, enc_data_key
 		FROM ai_proposals
 		WHERE owner_user_id = $1
 		  AND profile_id = $2
-		  AND ($3 = '' OR status = $3)
+		  AND (cardinality($3::text[]) = 0 OR status = ANY($3))
 		ORDER BY created_at DESC, id DESC
 		LIMIT $4
 	`
 
-	rows, err := s.pool.Query(ctx, query, ownerUserID, profileID, status, limit)
+	rows, err := s.pool.Query(ctx, query, ownerUserID, profileID, wanted, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -158,18 +255,8 @@ func (s *PostgresProposalsStorage) List(ctx context.Context, ownerUserID string,
 
 	result := make([]storage.AIProposal, 0, limit)
 	for rows.Next() {
-		var proposal storage.AIProposal
-		if err := rows.Scan(
-			&proposal.ID,
-			&proposal.OwnerUserID,
-			&proposal.ProfileID,
-			&proposal.CreatedAt,
-			&proposal.Status,
-			&proposal.Kind,
-			&proposal.Title,
-			&proposal.Summary,
-			&proposal.Payload,
-		); err != nil {
+		proposal, err := s.scanProposal(rows)
+		if err != nil {
 			return nil, err
 		}
 		result = append(result, proposal)
@@ -178,9 +265,186 @@ func (s *PostgresProposalsStorage) List(ctx context.Context, ownerUserID string,
 	return result, rows.Err()
 }
 
+func (s *PostgresProposalsStorage) SupersedePending(ctx context.Context, ownerUserID string, profileID uuid.UUID, kind string, keep []uuid.UUID) (int64, error) {
+	ownerUserID = strings.TrimSpace(ownerUserID)
+	if keep == nil {
+		keep = []uuid.UUID{}
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	ids, err := collectProposalIDs(tx.Query(ctx, `
+		UPDATE ai_proposals
+		SET status = 'superseded'
+		WHERE owner_user_id = $1
+		  AND profile_id = $2
+		  AND kind = $3
+		  AND status = 'pending'
+		  AND NOT (id = ANY($4))
+		RETURNING id, owner_user_id
+	`, ownerUserID, profileID, kind, keep))
+	if err != nil {
+		return 0, err
+	}
+	for _, row := range ids {
+		if err := insertProposalEvent(ctx, tx, row.id, row.owner, "superseded"); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+func (s *PostgresProposalsStorage) ExpirePending(ctx context.Context, now time.Time) (int64, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	ids, err := collectProposalIDs(tx.Query(ctx, `
+		UPDATE ai_proposals
+		SET status = 'expired'
+		WHERE status = 'pending'
+		  AND expires_at <= $1
+		RETURNING id, owner_user_id
+	`, now))
+	if err != nil {
+		return 0, err
+	}
+	for _, row := range ids {
+		if err := insertProposalEvent(ctx, tx, row.id, row.owner, "expired"); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+func (s *PostgresProposalsStorage) MarkViewed(ctx context.Context, ownerUserID string, proposalID uuid.UUID) error {
+	ownerUserID = strings.TrimSpace(ownerUserID)
+
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO ai_proposal_events (id, proposal_id, owner_user_id, event)
+		SELECT $3, p.id, p.owner_user_id, 'viewed'
+		FROM ai_proposals p
+		WHERE p.owner_user_id = $1
+		  AND p.id = $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM ai_proposal_events e
+			WHERE e.proposal_id = p.id AND e.event = 'viewed'
+		  )
+	`, ownerUserID, proposalID, uuid.New())
+	return err
+}
+
+func (s *PostgresProposalsStorage) ListEvents(ctx context.Context, ownerUserID string, proposalID uuid.UUID) ([]storage.AIProposalEvent, error) {
+	ownerUserID = strings.TrimSpace(ownerUserID)
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT proposal_id, event, created_at
+		FROM ai_proposal_events
+		WHERE owner_user_id = $1
+		  AND proposal_id = $2
+		ORDER BY created_at, id
+	`, ownerUserID, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]storage.AIProposalEvent, 0)
+	for rows.Next() {
+		var event storage.AIProposalEvent
+		if err := rows.Scan(&event.ProposalID, &event.Event, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+type proposalRef struct {
+	id    uuid.UUID
+	owner string
+}
+
+// collectProposalIDs reads the (id, owner_user_id) rows of an UPDATE ... RETURNING.
+func collectProposalIDs(rows pgx.Rows, err error) ([]proposalRef, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := make([]proposalRef, 0)
+	for rows.Next() {
+		var ref proposalRef
+		if err := rows.Scan(&ref.id, &ref.owner); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// insertProposalEvent appends to a proposal's history within tx.
+func insertProposalEvent(ctx context.Context, tx pgx.Tx, proposalID uuid.UUID, ownerUserID, event string) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO ai_proposal_events (id, proposal_id, owner_user_id, event)
+		VALUES ($1, $2, $3, $4)
+	`, uuid.New(), proposalID, ownerUserID, event)
+	return err
+}
+
+func (s *PostgresProposalsStorage) scanProposal(row pgx.Row) (storage.AIProposal, error) {
+	var proposal storage.AIProposal
+	var keyID *string
+	var wrappedKey []byte
+	if err := row.Scan(
+		&proposal.ID,
+		&proposal.OwnerUserID,
+		&proposal.ProfileID,
+		&proposal.CreatedAt,
+		&proposal.Status,
+		&proposal.Kind,
+		&proposal.Title,
+		&proposal.Summary,
+		&proposal.Payload,
+		&proposal.AppliedAt,
+		&proposal.Snapshot,
+		&proposal.ExpiresAt,
+		&keyID,
+		&wrappedKey,
+	); err != nil {
+		return storage.AIProposal{}, err
+	}
+
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return storage.AIProposal{}, err
+	}
+	if proposal.Payload, err = openJSON(dk, "ai_proposals", "payload", proposal.Payload); err != nil {
+		return storage.AIProposal{}, err
+	}
+	if proposal.Snapshot != nil {
+		if proposal.Snapshot, err = openJSON(dk, "ai_proposals", "snapshot", proposal.Snapshot); err != nil {
+			return storage.AIProposal{}, err
+		}
+	}
+	return proposal, nil
+}
+
 func normalizeProposalKind(kind string) string {
 	switch strings.TrimSpace(kind) {
-	case "settings_update", "vitamins_schedule", "workout_plan", "nutrition_plan", "generic":
+	case "settings_update", "vitamins_schedule", "workout_plan", "nutrition_plan", "meal_plan", "memory", "coaching_program", "coaching_adjustment", "generic":
 		return strings.TrimSpace(kind)
 	default:
 		return "generic"
@@ -189,7 +453,7 @@ func normalizeProposalKind(kind string) string {
 
 func normalizeProposalStatus(status string) string {
 	switch strings.TrimSpace(status) {
-	case "pending", "applied", "rejected":
+	case "pending", "applied", "rejected", "undone", "expired", "superseded":
 		return strings.TrimSpace(status)
 	default:
 		return "pending"
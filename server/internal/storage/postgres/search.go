@@ -0,0 +1,295 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/checkins"
+	"github.com/fdg312/health-hub/internal/fieldcrypt"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/textsearch"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// searchStorage searches the search_vector columns of sources, checkins and
+// chat_messages. With field encryption the vectors leave sealed text out, so
+// the profile's newest rows in the date range are decrypted and searched
+// with textsearch instead.
+type searchStorage struct {
+	pool     *pgxpool.Pool
+	keys     *fieldcrypt.Keyring
+	sources  *PostgresSourcesStorage
+	checkins *PostgresCheckinsStorage
+}
+
+func newSearchStorage(pool *pgxpool.Pool, sources *PostgresSourcesStorage, checkins *PostgresCheckinsStorage) *searchStorage {
+	return &searchStorage{pool: pool, sources: sources, checkins: checkins}
+}
+
+// headlineOptions makes ts_headline mark matches like textsearch.Highlight.
+var headlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=%d, MinWords=%d",
+	textsearch.StartSel, textsearch.StopSel, textsearch.SnippetWords, textsearch.SnippetWords/2)
+
+// maxDecryptedDocs bounds how many of the newest documents of each type are
+// decrypted per search, so its cost does not grow with the whole history.
+const maxDecryptedDocs = 1000
+
+// Search branches per document type, each naming its columns since any of
+// them may come first in the UNION. $1 profile, $2 query text, $3/$4 the
+// optional from/to days, $5 headline options, $8 the chat owner.
+var searchBranches = map[string]string{
+	storage.SearchTypeSource: `
+		SELECT 'source' AS type, id, kind, NULL::uuid AS thread_id, title,
+		       ts_headline('russian', coalesce(nullif(text, ''), title, ''), q.query, $5) AS snippet,
+		       ts_rank(search_vector, q.query) AS rank, created_at AS date
+		FROM sources, q
+		WHERE profile_id = $1 AND search_vector @@ q.query
+		  AND ($3::date IS NULL OR created_at >= $3::date)
+		  AND ($4::date IS NULL OR created_at < $4::date + 1)`,
+	storage.SearchTypeCheckin: `
+		SELECT 'checkin' AS type, id, type AS kind, NULL::uuid AS thread_id, NULL::text AS title,
+		       ts_headline('russian', concat_ws(E'\n', nullif(note, ''),
+		           (SELECT string_agg(tag, ', ') FROM jsonb_array_elements_text(tags) AS tag)), q.query, $5) AS snippet,
+		       ts_rank(search_vector, q.query) AS rank, date::timestamp AT TIME ZONE 'UTC' AS date
+		FROM checkins, q
+		WHERE profile_id = $1 AND search_vector @@ q.query
+		  AND ($3::date IS NULL OR date >= $3::date)
+		  AND ($4::date IS NULL OR date <= $4::date)`,
+	storage.SearchTypeChatMessage: `
+		SELECT 'chat_message' AS type, id, role AS kind, thread_id, NULL::text AS title,
+		       ts_headline('russian', content, q.query, $5) AS snippet,
+		       ts_rank(search_vector, q.query) AS rank, created_at AS date
+		FROM chat_messages, q
+		WHERE profile_id = $1 AND owner_user_id = $8 AND search_vector @@ q.query
+		  AND ($3::date IS NULL OR created_at >= $3::date)
+		  AND ($4::date IS NULL OR created_at < $4::date + 1)`,
+}
+
+var searchTypes = []string{storage.SearchTypeSource, storage.SearchTypeCheckin, storage.SearchTypeChatMessage}
+
+func (s *searchStorage) Search(ctx context.Context, query storage.SearchQuery) ([]storage.SearchHit, error) {
+	if s.keys != nil {
+		return s.searchDecrypted(ctx, query)
+	}
+
+	var branches []string
+	for _, docType := range searchTypes {
+		if len(query.Types) == 0 || slices.Contains(query.Types, docType) {
+			branches = append(branches, searchBranches[docType])
+		}
+	}
+	if len(branches) == 0 {
+		return nil, nil
+	}
+
+	sql := `
+		WITH q AS (
+			SELECT websearch_to_tsquery('russian', $2) || websearch_to_tsquery('english', $2) AS query
+		)` + strings.Join(branches, "\n\t\tUNION ALL") + `
+		ORDER BY rank DESC, date DESC
+		LIMIT $6 OFFSET $7
+	`
+	rows, err := s.pool.Query(ctx, sql,
+		query.ProfileID, query.Text, searchDay(query.From), searchDay(query.To), headlineOptions,
+		query.Limit, query.Offset, query.OwnerUserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := make([]storage.SearchHit, 0)
+	for rows.Next() {
+		var hit storage.SearchHit
+		var rank float32
+		if err := rows.Scan(&hit.Type, &hit.ID, &hit.Kind, &hit.ThreadID, &hit.Title, &hit.Snippet, &rank, &hit.Date); err != nil {
+			return nil, err
+		}
+		hit.Rank = float64(rank)
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// searchDay is a query day parameter; nil for no bound.
+func searchDay(day time.Time) *string {
+	if day.IsZero() {
+		return nil
+	}
+	value := day.Format("2006-01-02")
+	return &value
+}
+
+// searchDecrypted loads up to maxDecryptedDocs of the profile's newest
+// documents of each type in the date range, decrypting sealed fields, and
+// searches them with a throwaway textsearch index.
+func (s *searchStorage) searchDecrypted(ctx context.Context, query storage.SearchQuery) ([]storage.SearchHit, error) {
+	wanted := func(docType string) bool {
+		return len(query.Types) == 0 || slices.Contains(query.Types, docType)
+	}
+	index := textsearch.NewIndex()
+	docs := make(map[string]storage.SearchHit)
+	texts := make(map[string]string)
+	add := func(hit storage.SearchHit, title, body, snippetText string) {
+		key := hit.Type + ":" + hit.ID.String()
+		index.Put(key, title, body)
+		docs[key] = hit
+		texts[key] = snippetText
+	}
+
+	if wanted(storage.SearchTypeSource) {
+		sources, err := s.profileSources(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, src := range sources {
+			title, text := derefString(src.Title), derefString(src.Text)
+			snippetText := text
+			if snippetText == "" {
+				snippetText = title
+			}
+			add(storage.SearchHit{Type: storage.SearchTypeSource, ID: src.ID, Kind: src.Kind, Title: src.Title, Date: src.CreatedAt},
+				title, text, snippetText)
+		}
+	}
+
+	if wanted(storage.SearchTypeCheckin) {
+		list, err := s.profileCheckins(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range list {
+			date, _ := time.Parse("2006-01-02", c.Date)
+			tags := strings.Join(c.Tags, ", ")
+			add(storage.SearchHit{Type: storage.SearchTypeCheckin, ID: c.ID, Kind: c.Type, Date: date},
+				tags, c.Note, strings.TrimSpace(c.Note+"\n"+tags))
+		}
+	}
+
+	if wanted(storage.SearchTypeChatMessage) {
+		messages, err := s.profileMessages(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range messages {
+			threadID := msg.ThreadID
+			add(storage.SearchHit{Type: storage.SearchTypeChatMessage, ID: msg.ID, Kind: msg.Role, ThreadID: &threadID, Date: msg.CreatedAt},
+				"", msg.Content, msg.Content)
+		}
+	}
+
+	q := textsearch.ParseQuery(query.Text)
+	hits := make([]storage.SearchHit, 0)
+	for _, match := range index.Search(q) {
+		hit := docs[match.Key]
+		hit.Rank = match.Score
+		hit.Snippet = textsearch.Highlight(texts[match.Key], q, textsearch.SnippetWords)
+		hits = append(hits, hit)
+	}
+
+	if query.Offset >= len(hits) {
+		return hits[:0], nil
+	}
+	hits = hits[query.Offset:]
+	if query.Limit > 0 && len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+	return hits, nil
+}
+
+func (s *searchStorage) profileSources(ctx context.Context, query storage.SearchQuery) ([]*storage.Source, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+sourceColumns+`
+		FROM sources
+		WHERE profile_id = $1
+		  AND ($2::date IS NULL OR created_at >= $2::date)
+		  AND ($3::date IS NULL OR created_at < $3::date + 1)
+		ORDER BY created_at DESC
+		LIMIT $4
+	`, query.ProfileID, searchDay(query.From), searchDay(query.To), maxDecryptedDocs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []*storage.Source
+	for rows.Next() {
+		src, err := s.sources.scanSource(rows)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	return sources, rows.Err()
+}
+
+func (s *searchStorage) profileCheckins(ctx context.Context, query storage.SearchQuery) ([]checkins.Checkin, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+checkinColumns+`
+		FROM checkins
+		WHERE profile_id = $1
+		  AND ($2::date IS NULL OR date >= $2::date)
+		  AND ($3::date IS NULL OR date <= $3::date)
+		ORDER BY date DESC, recorded_at DESC
+		LIMIT $4
+	`, query.ProfileID, searchDay(query.From), searchDay(query.To), maxDecryptedDocs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []checkins.Checkin
+	for rows.Next() {
+		c, err := s.checkins.scanCheckin(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+func (s *searchStorage) profileMessages(ctx context.Context, query storage.SearchQuery) ([]storage.ChatMessage, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, thread_id, role, content, created_at, enc_key_id, enc_data_key
+		FROM chat_messages
+		WHERE profile_id = $1 AND owner_user_id = $2
+		  AND ($3::date IS NULL OR created_at >= $3::date)
+		  AND ($4::date IS NULL OR created_at < $4::date + 1)
+		ORDER BY created_at DESC
+		LIMIT $5
+	`, query.ProfileID, query.OwnerUserID, searchDay(query.From), searchDay(query.To), maxDecryptedDocs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []storage.ChatMessage
+	for rows.Next() {
+		var msg storage.ChatMessage
+		var keyID *string
+		var wrappedKey []byte
+		if err := rows.Scan(&msg.ID, &msg.ThreadID, &msg.Role, &msg.Content, &msg.CreatedAt, &keyID, &wrappedKey); err != nil {
+			return nil, err
+		}
+		dk, err := openRowKey(s.keys, keyID, wrappedKey)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Content, err = openText(dk, "chat_messages", "content", msg.Content); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/fdg312/health-hub/internal/fieldcrypt"
 	"github.com/fdg312/health-hub/internal/storage"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -19,6 +21,7 @@ var (
 // PostgresSourcesStorage — Postgres реализация SourcesStorage
 type PostgresSourcesStorage struct {
 	pool *pgxpool.Pool
+	keys *fieldcrypt.Keyring
 }
 
 // NewPostgresSourcesStorage создаёт новый PostgresSourcesStorage
@@ -26,16 +29,28 @@ func NewPostgresSourcesStorage(pool *pgxpool.Pool) *PostgresSourcesStorage {
 	return &PostgresSourcesStorage{pool: pool}
 }
 
+const insertSourceQuery = `
+	INSERT INTO sources (
+		id, profile_id, kind, title, text, url, checkin_id,
+		object_key, content_type, size_bytes, thumbnail_sizes, created_at, updated_at,
+		enc_key_id, enc_data_key, preview_status, duration_ms, transcript_status
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18
+	)
+`
+
 func (s *PostgresSourcesStorage) CreateSource(ctx context.Context, source *storage.Source) error {
-	query := `
-		INSERT INTO sources (
-			id, profile_id, kind, title, text, url, checkin_id,
-			object_key, content_type, size_bytes, created_at, updated_at
-		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
-		)
-	`
+	args, err := s.insertSourceArgs(source)
+	if err != nil {
+		return err
+	}
 
+	_, err = s.pool.Exec(ctx, insertSourceQuery, args...)
+	return err
+}
+
+// insertSourceArgs fills in the ID and timestamps and seals text/url for insertSourceQuery
+func (s *PostgresSourcesStorage) insertSourceArgs(source *storage.Source) ([]any, error) {
 	if source.ID == uuid.Nil {
 		source.ID = uuid.New()
 	}
@@ -44,48 +59,62 @@ func (s *PostgresSourcesStorage) CreateSource(ctx context.Context, source *stora
 	source.CreatedAt = now
 	source.UpdatedAt = now
 
-	_, err := s.pool.Exec(ctx, query,
+	dk, err := newRowKey(s.keys)
+	if err != nil {
+		return nil, err
+	}
+	text, err := sealOptionalText(dk, "sources", "text", source.Text)
+	if err != nil {
+		return nil, err
+	}
+	url, err := sealOptionalText(dk, "sources", "url", source.URL)
+	if err != nil {
+		return nil, err
+	}
+	keyID, wrappedKey := rowKeyColumns(dk)
+	var previewStatus *string
+	if source.Preview != nil {
+		previewStatus = &source.Preview.Status
+	}
+	var transcriptStatus *string
+	if source.Transcription != nil {
+		transcriptStatus = &source.Transcription.Status
+	}
+	thumbnailSizes := source.ThumbnailSizes
+	if thumbnailSizes == nil {
+		thumbnailSizes = []int{}
+	}
+
+	return []any{
 		source.ID,
 		source.ProfileID,
 		source.Kind,
 		source.Title,
-		source.Text,
-		source.URL,
+		text,
+		url,
 		source.CheckinID,
 		source.ObjectKey,
 		source.ContentType,
 		source.SizeBytes,
+		thumbnailSizes,
 		source.CreatedAt,
 		source.UpdatedAt,
-	)
-
-	return err
+		keyID,
+		wrappedKey,
+		previewStatus,
+		source.DurationMS,
+		transcriptStatus,
+	}, nil
 }
 
 func (s *PostgresSourcesStorage) GetSource(ctx context.Context, id uuid.UUID) (*storage.Source, error) {
 	query := `
-		SELECT id, profile_id, kind, title, text, url, checkin_id,
-		       object_key, content_type, size_bytes, created_at, updated_at
+		SELECT ` + sourceColumns + `
 		FROM sources
 		WHERE id = $1
 	`
 
-	var src storage.Source
-	err := s.pool.QueryRow(ctx, query, id).Scan(
-		&src.ID,
-		&src.ProfileID,
-		&src.Kind,
-		&src.Title,
-		&src.Text,
-		&src.URL,
-		&src.CheckinID,
-		&src.ObjectKey,
-		&src.ContentType,
-		&src.SizeBytes,
-		&src.CreatedAt,
-		&src.UpdatedAt,
-	)
-
+	src, err := s.scanSource(s.pool.QueryRow(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrSourceNotFound
@@ -93,14 +122,13 @@ func (s *PostgresSourcesStorage) GetSource(ctx context.Context, id uuid.UUID) (*
 		return nil, err
 	}
 
-	return &src, nil
+	return src, nil
 }
 
 func (s *PostgresSourcesStorage) ListSources(ctx context.Context, profileID uuid.UUID, query string, checkinID *uuid.UUID, limit, offset int) ([]storage.Source, error) {
 	// Build dynamic query with optional filters
 	baseQuery := `
-		SELECT id, profile_id, kind, title, text, url, checkin_id,
-		       object_key, content_type, size_bytes, created_at, updated_at
+		SELECT ` + sourceColumns + `
 		FROM sources
 		WHERE profile_id = $1
 	`
@@ -114,7 +142,9 @@ func (s *PostgresSourcesStorage) ListSources(ctx context.Context, profileID uuid
 		args = append(args, *checkinID)
 	}
 
-	if query != "" {
+	// Encrypted text/url cannot be matched in SQL, so search is done after decryption.
+	searchInMemory := query != "" && s.keys != nil
+	if query != "" && !searchInMemory {
 		argCount++
 		baseQuery += fmt.Sprintf(` AND (
 			title ILIKE $%d OR
@@ -125,10 +155,14 @@ func (s *PostgresSourcesStorage) ListSources(ctx context.Context, profileID uuid
 		args = append(args, searchPattern)
 	}
 
-	argCount++
-	argCount++
-	baseQuery += fmt.Sprintf(` ORDER BY created_at DESC LIMIT $%d OFFSET $%d`, argCount-1, argCount)
-	args = append(args, limit, offset)
+	if searchInMemory {
+		baseQuery += ` ORDER BY created_at DESC`
+	} else {
+		argCount++
+		argCount++
+		baseQuery += fmt.Sprintf(` ORDER BY created_at DESC LIMIT $%d OFFSET $%d`, argCount-1, argCount)
+		args = append(args, limit, offset)
+	}
 
 	rows, err := s.pool.Query(ctx, baseQuery, args...)
 	if err != nil {
@@ -138,31 +172,30 @@ func (s *PostgresSourcesStorage) ListSources(ctx context.Context, profileID uuid
 
 	var sources []storage.Source
 	for rows.Next() {
-		var src storage.Source
-		err := rows.Scan(
-			&src.ID,
-			&src.ProfileID,
-			&src.Kind,
-			&src.Title,
-			&src.Text,
-			&src.URL,
-			&src.CheckinID,
-			&src.ObjectKey,
-			&src.ContentType,
-			&src.SizeBytes,
-			&src.CreatedAt,
-			&src.UpdatedAt,
-		)
+		src, err := s.scanSource(rows)
 		if err != nil {
 			return nil, err
 		}
-		sources = append(sources, src)
+		if searchInMemory && !sourceMatches(src, query) {
+			continue
+		}
+		sources = append(sources, *src)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
+	if searchInMemory {
+		if offset >= len(sources) {
+			return nil, nil
+		}
+		sources = sources[offset:]
+		if limit > 0 && len(sources) > limit {
+			sources = sources[:limit]
+		}
+	}
+
 	return sources, nil
 }
 
@@ -193,3 +226,393 @@ func (s *PostgresSourcesStorage) PutSourceBlob(ctx context.Context, sourceID uui
 	// Not implemented for Postgres - blobs are stored in S3 or memory
 	return errors.New("blob storage not available in postgres mode")
 }
+
+// SaveLinkPreview seals the preview with the row's own data key, so rows
+// written before encryption was enabled stay plaintext until re-encrypted.
+func (s *PostgresSourcesStorage) SaveLinkPreview(ctx context.Context, source *storage.Source, archive *string) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var keyID *string
+	var wrappedKey []byte
+	err = tx.QueryRow(ctx, `SELECT enc_key_id, enc_data_key FROM sources WHERE id = $1 FOR UPDATE`, source.ID).
+		Scan(&keyID, &wrappedKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return false, err
+	}
+
+	var status, title, description, siteName, message *string
+	var fetchedAt *time.Time
+	if preview := source.Preview; preview != nil {
+		status, message, fetchedAt = &preview.Status, preview.Error, preview.FetchedAt
+		if title, err = sealOptionalText(dk, "sources", "preview_title", preview.Title); err != nil {
+			return false, err
+		}
+		if description, err = sealOptionalText(dk, "sources", "preview_description", preview.Description); err != nil {
+			return false, err
+		}
+		if siteName, err = sealOptionalText(dk, "sources", "preview_site_name", preview.SiteName); err != nil {
+			return false, err
+		}
+	}
+	archiveText, err := sealOptionalText(dk, "sources", "archive_text", archive)
+	if err != nil {
+		return false, err
+	}
+	thumbnailSizes := source.ThumbnailSizes
+	if thumbnailSizes == nil {
+		thumbnailSizes = []int{}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE sources
+		SET preview_status = $2, preview_title = $3, preview_description = $4, preview_site_name = $5,
+			preview_error = $6, preview_fetched_at = $7, archive_text = $8,
+			object_key = $9, content_type = $10, size_bytes = $11, thumbnail_sizes = $12, updated_at = NOW()
+		WHERE id = $1
+	`, source.ID, status, title, description, siteName, message, fetchedAt, archiveText,
+		source.ObjectKey, source.ContentType, source.SizeBytes, thumbnailSizes)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func (s *PostgresSourcesStorage) SetLinkPreviewStatus(ctx context.Context, id uuid.UUID, status string, errorMessage *string) (bool, error) {
+	result, err := s.pool.Exec(ctx, `
+		UPDATE sources
+		SET preview_status = $2, preview_error = $3, updated_at = NOW()
+		WHERE id = $1
+	`, id, status, errorMessage)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (s *PostgresSourcesStorage) ListPendingLinkPreviews(ctx context.Context, limit int) ([]storage.Source, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+sourceColumns+`
+		FROM sources
+		WHERE preview_status = 'pending'
+		ORDER BY created_at
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []storage.Source
+	for rows.Next() {
+		src, err := s.scanSource(rows)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, *src)
+	}
+	return sources, rows.Err()
+}
+
+func (s *PostgresSourcesStorage) GetSourceArchive(ctx context.Context, id uuid.UUID) (string, bool, error) {
+	var text *string
+	var keyID *string
+	var wrappedKey []byte
+	err := s.pool.QueryRow(ctx, `
+		SELECT archive_text, enc_key_id, enc_data_key FROM sources WHERE id = $1
+	`, id).Scan(&text, &keyID, &wrappedKey)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && text == nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return "", false, err
+	}
+	plain, err := openText(dk, "sources", "archive_text", *text)
+	if err != nil {
+		return "", false, err
+	}
+	return plain, true, nil
+}
+
+// SaveTranscript seals the transcript like the text of a note, with the
+// row's own data key.
+func (s *PostgresSourcesStorage) SaveTranscript(ctx context.Context, id uuid.UUID, text *string, transcription storage.Transcription) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var keyID *string
+	var wrappedKey []byte
+	err = tx.QueryRow(ctx, `SELECT enc_key_id, enc_data_key FROM sources WHERE id = $1 FOR UPDATE`, id).
+		Scan(&keyID, &wrappedKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return false, err
+	}
+	sealed, err := sealOptionalText(dk, "sources", "text", text)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE sources
+		SET text = $2, transcript_status = $3, transcript_language = $4, transcript_error = $5,
+			transcribed_at = $6, updated_at = NOW()
+		WHERE id = $1
+	`, id, sealed, transcription.Status, transcription.Language, transcription.Error, transcription.TranscribedAt)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func (s *PostgresSourcesStorage) SetTranscriptionStatus(ctx context.Context, id uuid.UUID, status string, errorMessage *string) (bool, error) {
+	result, err := s.pool.Exec(ctx, `
+		UPDATE sources
+		SET transcript_status = $2, transcript_error = $3, updated_at = NOW()
+		WHERE id = $1
+	`, id, status, errorMessage)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (s *PostgresSourcesStorage) ListPendingTranscriptions(ctx context.Context, limit int) ([]storage.Source, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+sourceColumns+`
+		FROM sources
+		WHERE transcript_status = 'pending'
+		ORDER BY created_at
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []storage.Source
+	for rows.Next() {
+		src, err := s.scanSource(rows)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, *src)
+	}
+	return sources, rows.Err()
+}
+
+func (s *PostgresSourcesStorage) CreateSourceUpload(ctx context.Context, upload storage.SourceUpload) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO source_uploads (
+			id, profile_id, checkin_id, title, object_key, content_type, size_bytes, expires_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, upload.ID, upload.ProfileID, upload.CheckinID, upload.Title, upload.ObjectKey, upload.ContentType, upload.SizeBytes, upload.ExpiresAt)
+	return err
+}
+
+func (s *PostgresSourcesStorage) GetSourceUpload(ctx context.Context, id uuid.UUID) (storage.SourceUpload, bool, error) {
+	upload, err := scanSourceUpload(s.pool.QueryRow(ctx, `
+		SELECT `+sourceUploadColumns+`
+		FROM source_uploads
+		WHERE id = $1
+	`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.SourceUpload{}, false, nil
+	}
+	if err != nil {
+		return storage.SourceUpload{}, false, err
+	}
+	return upload, true, nil
+}
+
+// CompleteSourceUpload deletes the upload row and inserts the source in one
+// transaction, so a janitor pass or a repeated call cannot create it twice.
+func (s *PostgresSourcesStorage) CompleteSourceUpload(ctx context.Context, uploadID uuid.UUID, source *storage.Source) (bool, error) {
+	args, err := s.insertSourceArgs(source)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `DELETE FROM source_uploads WHERE id = $1`, uploadID)
+	if err != nil {
+		return false, err
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec(ctx, insertSourceQuery, args...); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func (s *PostgresSourcesStorage) DeleteSourceUpload(ctx context.Context, id uuid.UUID) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM source_uploads WHERE id = $1`, id)
+	return err
+}
+
+func (s *PostgresSourcesStorage) ListExpiredSourceUploads(ctx context.Context, before time.Time, limit int) ([]storage.SourceUpload, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+sourceUploadColumns+`
+		FROM source_uploads
+		WHERE expires_at < $1
+		ORDER BY expires_at
+		LIMIT $2
+	`, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	uploads := make([]storage.SourceUpload, 0)
+	for rows.Next() {
+		upload, err := scanSourceUpload(rows)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, upload)
+	}
+	return uploads, rows.Err()
+}
+
+const sourceUploadColumns = `id, profile_id, checkin_id, title, object_key, content_type, size_bytes, expires_at, created_at`
+
+func scanSourceUpload(row pgx.Row) (storage.SourceUpload, error) {
+	var upload storage.SourceUpload
+	err := row.Scan(
+		&upload.ID,
+		&upload.ProfileID,
+		&upload.CheckinID,
+		&upload.Title,
+		&upload.ObjectKey,
+		&upload.ContentType,
+		&upload.SizeBytes,
+		&upload.ExpiresAt,
+		&upload.CreatedAt,
+	)
+	return upload, err
+}
+
+// sourceColumns are the columns scanSource reads; the archived text itself
+// is only read by GetSourceArchive.
+const sourceColumns = `
+	id, profile_id, kind, title, text, url, checkin_id,
+	object_key, content_type, size_bytes, thumbnail_sizes, created_at, updated_at,
+	enc_key_id, enc_data_key,
+	preview_status, preview_title, preview_description, preview_site_name,
+	preview_error, preview_fetched_at, archive_text IS NOT NULL,
+	duration_ms, transcript_status, transcript_language, transcript_error, transcribed_at
+`
+
+// scanSource scans a source row and decrypts text/url when the row is encrypted
+func (s *PostgresSourcesStorage) scanSource(row pgx.Row) (*storage.Source, error) {
+	var src storage.Source
+	var keyID *string
+	var wrappedKey []byte
+	var previewStatus *string
+	var preview storage.LinkPreview
+	var transcriptStatus *string
+	var transcription storage.Transcription
+	err := row.Scan(
+		&src.ID,
+		&src.ProfileID,
+		&src.Kind,
+		&src.Title,
+		&src.Text,
+		&src.URL,
+		&src.CheckinID,
+		&src.ObjectKey,
+		&src.ContentType,
+		&src.SizeBytes,
+		&src.ThumbnailSizes,
+		&src.CreatedAt,
+		&src.UpdatedAt,
+		&keyID,
+		&wrappedKey,
+		&previewStatus,
+		&preview.Title,
+		&preview.Description,
+		&preview.SiteName,
+		&preview.Error,
+		&preview.FetchedAt,
+		&preview.Archived,
+		&src.DurationMS,
+		&transcriptStatus,
+		&transcription.Language,
+		&transcription.Error,
+		&transcription.TranscribedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	if src.Text, err = openOptionalText(dk, "sources", "text", src.Text); err != nil {
+		return nil, err
+	}
+	if src.URL, err = openOptionalText(dk, "sources", "url", src.URL); err != nil {
+		return nil, err
+	}
+	if previewStatus != nil {
+		preview.Status = *previewStatus
+		if preview.Title, err = openOptionalText(dk, "sources", "preview_title", preview.Title); err != nil {
+			return nil, err
+		}
+		if preview.Description, err = openOptionalText(dk, "sources", "preview_description", preview.Description); err != nil {
+			return nil, err
+		}
+		if preview.SiteName, err = openOptionalText(dk, "sources", "preview_site_name", preview.SiteName); err != nil {
+			return nil, err
+		}
+		src.Preview = &preview
+	}
+	if transcriptStatus != nil {
+		transcription.Status = *transcriptStatus
+		src.Transcription = &transcription
+	}
+	return &src, nil
+}
+
+// sourceMatches mirrors the ILIKE search on title, text and url
+func sourceMatches(src *storage.Source, query string) bool {
+	query = strings.ToLower(query)
+	if src.Title != nil && strings.Contains(strings.ToLower(*src.Title), query) {
+		return true
+	}
+	if src.Text != nil && strings.Contains(strings.ToLower(*src.Text), query) {
+		return true
+	}
+	return src.URL != nil && strings.Contains(strings.ToLower(*src.URL), query)
+}
@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/fieldcrypt"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type symptomsStorage struct {
+	pool *pgxpool.Pool
+	keys *fieldcrypt.Keyring
+}
+
+func newSymptomsStorage(pool *pgxpool.Pool) *symptomsStorage {
+	return &symptomsStorage{pool: pool}
+}
+
+const symptomColumns = `
+	id, profile_id, checkin_id, name, severity, started_at, ended_at, note, created_at, updated_at, enc_key_id, enc_data_key
+`
+
+func (s *symptomsStorage) CreateSymptom(ctx context.Context, symptom storage.Symptom) (storage.Symptom, error) {
+	if symptom.ID == uuid.Nil {
+		symptom.ID = uuid.New()
+	}
+	dk, err := newRowKey(s.keys)
+	if err != nil {
+		return storage.Symptom{}, err
+	}
+	note, err := sealText(dk, "symptoms", "note", symptom.Note)
+	if err != nil {
+		return storage.Symptom{}, err
+	}
+	keyID, wrappedKey := rowKeyColumns(dk)
+
+	err = s.pool.QueryRow(ctx, `
+		INSERT INTO symptoms (
+			id, profile_id, checkin_id, name, severity, started_at, ended_at, note,
+			created_at, updated_at, enc_key_id, enc_data_key
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW(), $9, $10)
+		RETURNING created_at, updated_at
+	`,
+		symptom.ID, symptom.ProfileID, symptom.CheckinID, symptom.Name, symptom.Severity,
+		symptom.StartedAt, symptom.EndedAt, note, keyID, wrappedKey,
+	).Scan(&symptom.CreatedAt, &symptom.UpdatedAt)
+	if err != nil {
+		return storage.Symptom{}, err
+	}
+	return symptom, nil
+}
+
+func (s *symptomsStorage) GetSymptom(ctx context.Context, id uuid.UUID) (storage.Symptom, bool, error) {
+	symptom, err := s.scanSymptom(s.pool.QueryRow(ctx, `
+		SELECT `+symptomColumns+`
+		FROM symptoms
+		WHERE id = $1
+	`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Symptom{}, false, nil
+		}
+		return storage.Symptom{}, false, err
+	}
+	return symptom, true, nil
+}
+
+func (s *symptomsStorage) UpdateSymptom(ctx context.Context, symptom storage.Symptom) (storage.Symptom, bool, error) {
+	dk, err := newRowKey(s.keys)
+	if err != nil {
+		return storage.Symptom{}, false, err
+	}
+	note, err := sealText(dk, "symptoms", "note", symptom.Note)
+	if err != nil {
+		return storage.Symptom{}, false, err
+	}
+	keyID, wrappedKey := rowKeyColumns(dk)
+
+	updated, err := s.scanSymptom(s.pool.QueryRow(ctx, `
+		UPDATE symptoms
+		SET name = $2, severity = $3, started_at = $4, ended_at = $5, note = $6,
+			enc_key_id = $7, enc_data_key = $8, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+symptomColumns,
+		symptom.ID, symptom.Name, symptom.Severity, symptom.StartedAt, symptom.EndedAt, note, keyID, wrappedKey,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Symptom{}, false, nil
+		}
+		return storage.Symptom{}, false, err
+	}
+	return updated, true, nil
+}
+
+func (s *symptomsStorage) DeleteSymptom(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM symptoms WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *symptomsStorage) ListSymptoms(ctx context.Context, profileID uuid.UUID, name string, from, to time.Time) ([]storage.Symptom, error) {
+	query := `
+		SELECT ` + symptomColumns + `
+		FROM symptoms
+		WHERE profile_id = $1
+	`
+	args := []any{profileID}
+	if name != "" {
+		args = append(args, name)
+		query += fmt.Sprintf(" AND lower(name) = lower($%d)", len(args))
+	}
+	if !from.IsZero() {
+		args = append(args, from)
+		query += fmt.Sprintf(" AND started_at >= $%d", len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to)
+		query += fmt.Sprintf(" AND started_at < $%d", len(args))
+	}
+	query += ` ORDER BY started_at, created_at`
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	symptoms := make([]storage.Symptom, 0)
+	for rows.Next() {
+		symptom, err := s.scanSymptom(rows)
+		if err != nil {
+			return nil, err
+		}
+		symptoms = append(symptoms, symptom)
+	}
+	return symptoms, rows.Err()
+}
+
+// scanSymptom scans a row of symptomColumns and decrypts the note.
+func (s *symptomsStorage) scanSymptom(row pgx.Row) (storage.Symptom, error) {
+	var symptom storage.Symptom
+	var keyID *string
+	var wrappedKey []byte
+	err := row.Scan(
+		&symptom.ID,
+		&symptom.ProfileID,
+		&symptom.CheckinID,
+		&symptom.Name,
+		&symptom.Severity,
+		&symptom.StartedAt,
+		&symptom.EndedAt,
+		&symptom.Note,
+		&symptom.CreatedAt,
+		&symptom.UpdatedAt,
+		&keyID,
+		&wrappedKey,
+	)
+	if err != nil {
+		return symptom, err
+	}
+
+	dk, err := openRowKey(s.keys, keyID, wrappedKey)
+	if err != nil {
+		return symptom, err
+	}
+	symptom.Note, err = openText(dk, "symptoms", "note", symptom.Note)
+	return symptom, err
+}
@@ -106,3 +106,16 @@ func (s *PostgresWorkoutPlansStorage) UpsertActivePlan(ownerUserID string, profi
 
 	return plan, nil
 }
+
+// DeactivateActivePlan deactivates the active workout plan, if any.
+func (s *PostgresWorkoutPlansStorage) DeactivateActivePlan(ownerUserID string, profileID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.pool.Exec(ctx, `
+		UPDATE workout_plans
+		SET is_active = false, updated_at = now()
+		WHERE owner_user_id = $1 AND profile_id = $2 AND is_active = true
+	`, ownerUserID, profileID)
+	return err
+}
@@ -130,13 +130,71 @@ type SourcesStorage interface {
 
 	// PutSourceBlob сохраняет blob данные для image source (memory mode only)
 	PutSourceBlob(ctx context.Context, sourceID uuid.UUID, data []byte, contentType string) error
+
+	// SaveLinkPreview сохраняет загруженное превью ссылки: source.Preview,
+	// картинку (ObjectKey, ContentType, SizeBytes, ThumbnailSizes) и текст
+	// страницы (nil — без архива). false — source удалён
+	SaveLinkPreview(ctx context.Context, source *Source, archive *string) (bool, error)
+
+	// SetLinkPreviewStatus меняет статус превью и ошибку, не трогая уже
+	// сохранённое превью. false — source удалён
+	SetLinkPreviewStatus(ctx context.Context, id uuid.UUID, status string, errorMessage *string) (bool, error)
+
+	// ListPendingLinkPreviews возвращает ссылки с превью в статусе pending, старые первыми
+	ListPendingLinkPreviews(ctx context.Context, limit int) ([]Source, error)
+
+	// GetSourceArchive возвращает сохранённый текст страницы; false — архива нет
+	GetSourceArchive(ctx context.Context, id uuid.UUID) (string, bool, error)
+
+	// SaveTranscript сохраняет расшифровку голосовой заметки в Text и статус
+	// transcription. false — source удалён
+	SaveTranscript(ctx context.Context, id uuid.UUID, text *string, transcription Transcription) (bool, error)
+
+	// SetTranscriptionStatus меняет статус расшифровки и ошибку, не трогая
+	// уже сохранённый текст. false — source удалён
+	SetTranscriptionStatus(ctx context.Context, id uuid.UUID, status string, errorMessage *string) (bool, error)
+
+	// ListPendingTranscriptions возвращает голосовые заметки с расшифровкой в статусе pending, старые первыми
+	ListPendingTranscriptions(ctx context.Context, limit int) ([]Source, error)
+}
+
+// SourceUploadsStorage — прямые загрузки изображений в S3 по presigned URL
+type SourceUploadsStorage interface {
+	// CreateSourceUpload регистрирует выданную ссылку на загрузку
+	CreateSourceUpload(ctx context.Context, upload SourceUpload) error
+
+	// GetSourceUpload возвращает незавершённую загрузку; false — если её нет
+	GetSourceUpload(ctx context.Context, id uuid.UUID) (SourceUpload, bool, error)
+
+	// CompleteSourceUpload атомарно создаёт source и удаляет загрузку;
+	// false — загрузка уже завершена или удалена
+	CompleteSourceUpload(ctx context.Context, uploadID uuid.UUID, source *Source) (bool, error)
+
+	// DeleteSourceUpload удаляет загрузку (без ошибки, если её нет)
+	DeleteSourceUpload(ctx context.Context, id uuid.UUID) error
+
+	// ListExpiredSourceUploads возвращает загрузки с expires_at раньше before
+	ListExpiredSourceUploads(ctx context.Context, before time.Time, limit int) ([]SourceUpload, error)
+}
+
+// SourceUpload — выданная ссылка на загрузку, по которой ещё не создан source
+type SourceUpload struct {
+	ID          uuid.UUID // becomes the source ID on completion
+	ProfileID   uuid.UUID
+	CheckinID   *uuid.UUID
+	Title       *string
+	ObjectKey   string
+	ContentType string
+	SizeBytes   int64
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
 }
 
 // Source — пользовательский контент (link, note, image)
 type Source struct {
 	ID          uuid.UUID
 	ProfileID   uuid.UUID
-	Kind        string     // "link", "note", "image"
+	Kind        string     // "link", "note", "image", "audio"
 	Title       *string    // optional
 	Text        *string    // for notes
 	URL         *string    // for links
@@ -144,8 +202,37 @@ type Source struct {
 	ObjectKey   *string    // S3 object key (images only, S3 mode)
 	ContentType *string    // MIME type (images only)
 	SizeBytes   int64      // file size (images only)
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	// ThumbnailSizes — стороны сохранённых превью (images only, S3 mode)
+	ThumbnailSizes []int
+	// Preview — превью страницы (links only); nil, если загрузка превью выключена.
+	// У ссылки с картинкой превью ObjectKey/ContentType/SizeBytes описывают картинку
+	Preview *LinkPreview
+	// DurationMS — длительность голосовой заметки (audio only)
+	DurationMS *int64
+	// Transcription — статус расшифровки (audio only); сам текст — в Text.
+	// nil, если распознавание речи выключено
+	Transcription *Transcription
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Transcription — статус расшифровки audio source
+type Transcription struct {
+	Status        string  // pending, ready, failed
+	Language      *string // ISO 639-1, если известен
+	Error         *string // причина последней неудачной расшифровки
+	TranscribedAt *time.Time
+}
+
+// LinkPreview — OpenGraph-превью страницы link source
+type LinkPreview struct {
+	Status      string // pending, ready, failed
+	Title       *string
+	Description *string
+	SiteName    *string
+	Error       *string // причина последней неудачной загрузки
+	FetchedAt   *time.Time
+	Archived    bool // сохранён текст страницы
 }
 
 // NotificationsStorage — интерфейс для работы с notifications/inbox
@@ -331,6 +418,51 @@ type EmailOTP struct {
 	SendCount   int
 }
 
+// Области журнала злоупотреблений email OTP.
+const (
+	OTPAbuseScopeEmail = "email"
+	OTPAbuseScopeIP    = "ip"
+)
+
+// EmailOTPAbuseStorage — журнал злоупотреблений email OTP (по email и по IP).
+type EmailOTPAbuseStorage interface {
+	// GetAbuseRecord возвращает запись журнала; nil, если записи нет.
+	GetAbuseRecord(ctx context.Context, scope, key string) (*EmailOTPAbuseRecord, error)
+
+	// UpsertAbuseRecord создаёт или перезаписывает запись журнала.
+	UpsertAbuseRecord(ctx context.Context, rec EmailOTPAbuseRecord) error
+
+	// DeleteAbuseRecord удаляет запись журнала. bool=false, если записи не было.
+	DeleteAbuseRecord(ctx context.Context, scope, key string) (bool, error)
+
+	// ListAbuseRecords возвращает записи, отсортированные по last_event_at desc.
+	ListAbuseRecords(ctx context.Context, filter EmailOTPAbuseFilter) ([]EmailOTPAbuseRecord, error)
+
+	// UseChallengeNonce отмечает nonce решённого challenge использованным до
+	// expiresAt и удаляет истёкшие на момент now. bool=false, если nonce уже
+	// был использован.
+	UseChallengeNonce(ctx context.Context, nonce string, expiresAt, now time.Time) (bool, error)
+}
+
+// EmailOTPAbuseRecord — счётчики и состояние блокировки для email или IP.
+type EmailOTPAbuseRecord struct {
+	Scope        string
+	Key          string
+	WindowStart  time.Time
+	Requests     int
+	Failures     int
+	LockoutLevel int
+	LockedUntil  *time.Time
+	LastEventAt  time.Time
+}
+
+// EmailOTPAbuseFilter — фильтр для ListAbuseRecords.
+type EmailOTPAbuseFilter struct {
+	Scope    string    // "" = все области
+	LockedAt time.Time // если не zero — только записи, заблокированные на этот момент
+	Limit    int
+}
+
 // SettingsStorage — интерфейс для пользовательских настроек уведомлений/порогов.
 type SettingsStorage interface {
 	// GetSettings returns settings by owner_user_id. bool=false means not found.
@@ -362,29 +494,85 @@ type Settings struct {
 	UpdatedAt time.Time
 }
 
-// ChatStorage — интерфейс для хранения сообщений чата.
+// ChatStorage — интерфейс для хранения сообщений, тредов и памяти чата.
 type ChatStorage interface {
-	// InsertMessage сохраняет сообщение чата.
-	InsertMessage(ctx context.Context, ownerUserID string, profileID uuid.UUID, role, content string) (ChatMessage, error)
+	// InsertMessage сохраняет сообщение в треде и обновляет updated_at треда.
+	InsertMessage(ctx context.Context, ownerUserID string, profileID, threadID uuid.UUID, role, content string) (ChatMessage, error)
 
-	// ListMessages возвращает последние сообщения по owner/profile и nextCursor.
+	// ListMessages возвращает последние сообщения по owner/profile (все треды) и nextCursor.
 	// before используется как курсор по created_at (strictly less than).
 	ListMessages(ctx context.Context, ownerUserID string, profileID uuid.UUID, limit int, before *time.Time) ([]ChatMessage, *time.Time, error)
+
+	// ListThreadMessages — то же, что ListMessages, в рамках одного треда.
+	ListThreadMessages(ctx context.Context, ownerUserID string, threadID uuid.UUID, limit int, before *time.Time) ([]ChatMessage, *time.Time, error)
+
+	// CreateThread создаёт тред.
+	CreateThread(ctx context.Context, ownerUserID string, profileID uuid.UUID, title string) (ChatThread, error)
+
+	// GetThread возвращает тред по id в рамках owner.
+	GetThread(ctx context.Context, ownerUserID string, threadID uuid.UUID) (ChatThread, bool, error)
+
+	// ListThreads возвращает треды профиля, последние активные первыми.
+	ListThreads(ctx context.Context, ownerUserID string, profileID uuid.UUID, includeArchived bool) ([]ChatThread, error)
+
+	// UpdateThread меняет название и/или архивирует тред. nil поля не меняются.
+	UpdateThread(ctx context.Context, ownerUserID string, threadID uuid.UUID, title *string, archived *bool) (ChatThread, bool, error)
+
+	// SaveThreadSummary сохраняет сводку сообщений треда до summarizedUntil включительно.
+	SaveThreadSummary(ctx context.Context, ownerUserID string, threadID uuid.UUID, summary string, summarizedUntil time.Time) error
+
+	// InsertFact добавляет факт о пользователе в память ассистента.
+	InsertFact(ctx context.Context, ownerUserID string, profileID uuid.UUID, content string) (ChatFact, error)
+
+	// ListFacts возвращает факты профиля в порядке добавления.
+	ListFacts(ctx context.Context, ownerUserID string, profileID uuid.UUID) ([]ChatFact, error)
+
+	// DeleteFact удаляет факт. false — не найден.
+	DeleteFact(ctx context.Context, ownerUserID string, factID uuid.UUID) (bool, error)
 }
 
 // ProposalsStorage — интерфейс для хранения AI предложений.
 type ProposalsStorage interface {
 	// InsertMany сохраняет предложения ассистента и возвращает сохранённые записи.
+	// Каждое получает событие created.
 	InsertMany(ctx context.Context, ownerUserID string, profileID uuid.UUID, drafts []ProposalDraft) ([]AIProposal, error)
 
 	// Get возвращает предложение по id в рамках owner.
 	Get(ctx context.Context, ownerUserID string, proposalID uuid.UUID) (AIProposal, bool, error)
 
-	// UpdateStatus обновляет статус предложения в рамках owner.
-	UpdateStatus(ctx context.Context, ownerUserID string, proposalID uuid.UUID, status string) error
+	// UpdateStatus переводит предложение owner из статуса from в status и
+	// пишет событие с именем статуса. false — предложения нет или его статус
+	// уже не from.
+	UpdateStatus(ctx context.Context, ownerUserID string, proposalID uuid.UUID, from, status string) (bool, error)
+
+	// ClaimPending переводит pending-предложение в applying на время
+	// применения, чтобы параллельные Apply, Reject и истечение срока его не
+	// трогали. Событие не пишется. false — нет pending-предложения с таким id.
+	ClaimPending(ctx context.Context, ownerUserID string, proposalID uuid.UUID) (bool, error)
+
+	// ReleaseClaim возвращает applying-предложение в pending, когда
+	// применить его не удалось.
+	ReleaseClaim(ctx context.Context, ownerUserID string, proposalID uuid.UUID) error
+
+	// MarkApplied переводит applying-предложение в applied и сохраняет снимок
+	// состояния до применения (событие applied). false — нет applying-предложения с таким id.
+	MarkApplied(ctx context.Context, ownerUserID string, proposalID uuid.UUID, snapshot []byte) (AIProposal, bool, error)
+
+	// List возвращает предложения по owner/profile; пустой statuses — любые статусы.
+	List(ctx context.Context, ownerUserID string, profileID uuid.UUID, statuses []string, limit int) ([]AIProposal, error)
 
-	// List возвращает предложения по owner/profile с опциональным статусом.
-	List(ctx context.Context, ownerUserID string, profileID uuid.UUID, status string, limit int) ([]AIProposal, error)
+	// SupersedePending переводит pending-предложения вида kind по owner/profile,
+	// кроме keep, в superseded. Возвращает число затронутых.
+	SupersedePending(ctx context.Context, ownerUserID string, profileID uuid.UUID, kind string, keep []uuid.UUID) (int64, error)
+
+	// ExpirePending переводит pending-предложения всех владельцев с expires_at <= now в expired.
+	ExpirePending(ctx context.Context, now time.Time) (int64, error)
+
+	// MarkViewed пишет событие viewed, если его ещё не было.
+	MarkViewed(ctx context.Context, ownerUserID string, proposalID uuid.UUID) error
+
+	// ListEvents возвращает историю статусов предложения, старые первыми.
+	ListEvents(ctx context.Context, ownerUserID string, proposalID uuid.UUID) ([]AIProposalEvent, error)
 }
 
 // ChatMessage — сохранённое сообщение чата.
@@ -392,11 +580,90 @@ type ChatMessage struct {
 	ID          uuid.UUID
 	OwnerUserID string
 	ProfileID   uuid.UUID
+	ThreadID    uuid.UUID
 	Role        string
 	Content     string
 	CreatedAt   time.Time
 }
 
+// ChatThread — отдельный разговор с ассистентом. Summary сжимает сообщения
+// до SummarizedUntil, которые больше не передаются модели целиком.
+type ChatThread struct {
+	ID              uuid.UUID
+	OwnerUserID     string
+	ProfileID       uuid.UUID
+	Title           string
+	Summary         string
+	SummarizedUntil *time.Time
+	ArchivedAt      *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// ChatFact — подтверждённый пользователем факт, который ассистент помнит во всех тредах.
+type ChatFact struct {
+	ID          uuid.UUID
+	OwnerUserID string
+	ProfileID   uuid.UUID
+	Content     string
+	CreatedAt   time.Time
+}
+
+// AIConsentStorage — согласия владельцев на отправку данных AI-провайдерам.
+// Записи не удаляются: отзыв и повторное согласие остаются в истории.
+type AIConsentStorage interface {
+	// GetAIConsent returns the latest consent record. bool=false means never granted.
+	GetAIConsent(ctx context.Context, ownerUserID string) (AIConsent, bool, error)
+
+	// GrantAIConsent records consent to policyVersion, revoking any active one.
+	GrantAIConsent(ctx context.Context, ownerUserID, policyVersion string) (AIConsent, error)
+
+	// RevokeAIConsent revokes the active consent. bool=false means there was none.
+	RevokeAIConsent(ctx context.Context, ownerUserID string) (bool, error)
+}
+
+// AIConsent — согласие владельца с версией политики обработки данных AI.
+type AIConsent struct {
+	ID            uuid.UUID
+	OwnerUserID   string
+	PolicyVersion string
+	GrantedAt     time.Time
+	RevokedAt     *time.Time
+}
+
+// AIUsageStorage — расход токенов AI-провайдеров по владельцам и дням (UTC).
+type AIUsageStorage interface {
+	// AddAIUsage adds one call to the owner's row for day, provider and model.
+	AddAIUsage(ctx context.Context, ownerUserID string, day time.Time, provider, model string, inputTokens, outputTokens int) error
+
+	// ListAIUsage returns the owner's rows with from <= day <= to, oldest first.
+	ListAIUsage(ctx context.Context, ownerUserID string, from, to time.Time) ([]AIUsageDay, error)
+
+	// AggregateAIUsage sums usage of all owners per provider and model for from <= day <= to.
+	AggregateAIUsage(ctx context.Context, from, to time.Time) ([]AIUsageTotal, error)
+}
+
+// AIUsageDay — расход токенов владельца за день по одной модели.
+type AIUsageDay struct {
+	OwnerUserID  string
+	Day          time.Time // UTC midnight
+	Provider     string
+	Model        string
+	Requests     int64
+	InputTokens  int64
+	OutputTokens int64
+}
+
+// AIUsageTotal — суммарный расход по модели за период.
+type AIUsageTotal struct {
+	Provider     string
+	Model        string
+	Owners       int64
+	Requests     int64
+	InputTokens  int64
+	OutputTokens int64
+}
+
 // AIProposal — сохранённое структурированное предложение ассистента.
 type AIProposal struct {
 	ID          uuid.UUID
@@ -408,14 +675,29 @@ type AIProposal struct {
 	Title       string
 	Summary     string
 	Payload     []byte
+	// AppliedAt и Snapshot заполняются при применении; Snapshot — JSON
+	// состояния до применения, из которого восстанавливает отмена.
+	AppliedAt *time.Time
+	Snapshot  []byte
+	// ExpiresAt — когда pending-предложение станет expired; nil — никогда.
+	ExpiresAt *time.Time
 }
 
 // ProposalDraft — draft для сохранения предложений ассистента.
 type ProposalDraft struct {
-	Kind    string
-	Title   string
-	Summary string
-	Payload []byte
+	Kind      string
+	Title     string
+	Summary   string
+	Payload   []byte
+	ExpiresAt *time.Time
+}
+
+// AIProposalEvent — запись истории предложения: created, viewed, applied,
+// rejected, expired, superseded или undone.
+type AIProposalEvent struct {
+	ProposalID uuid.UUID
+	Event      string
+	CreatedAt  time.Time
 }
 
 // ============================================================================
@@ -428,6 +710,8 @@ type WorkoutPlansStorage interface {
 	GetActivePlan(ownerUserID string, profileID uuid.UUID) (WorkoutPlan, bool, error)
 	// UpsertActivePlan creates or updates the active plan (deactivates old ones).
 	UpsertActivePlan(ownerUserID string, profileID uuid.UUID, title string, goal string) (WorkoutPlan, error)
+	// DeactivateActivePlan deactivates the active plan, leaving the profile without one.
+	DeactivateActivePlan(ownerUserID string, profileID uuid.UUID) error
 }
 
 // WorkoutPlanItemsStorage manages items within workout plans.
@@ -617,3 +901,418 @@ type MealPlanItemUpsert struct {
 	ApproxFatG     int
 	ApproxCarbsG   int
 }
+
+// AuditStorage — журнал аудита: кто, когда и что читал или менял.
+type AuditStorage interface {
+	// InsertAuditEvent добавляет событие в журнал.
+	InsertAuditEvent(ctx context.Context, event AuditEvent) error
+
+	// ListAuditEvents возвращает события владельца, новые первыми.
+	ListAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+
+	// DeleteAuditEventsBefore удаляет события старше before (retention).
+	DeleteAuditEventsBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// AuditEvent — запись журнала аудита.
+type AuditEvent struct {
+	ID           uuid.UUID
+	OwnerUserID  string
+	ActorUserID  string
+	ProfileID    *uuid.UUID
+	Action       string
+	ResourceType string
+	ResourceID   string
+	RequestID    string
+	IP           string
+	CreatedAt    time.Time
+}
+
+// AuditFilter — фильтр для ListAuditEvents. OwnerUserID обязателен.
+type AuditFilter struct {
+	OwnerUserID  string
+	ProfileID    *uuid.UUID
+	Action       string
+	ResourceType string
+	From         *time.Time
+	To           *time.Time
+	Before       *time.Time // курсор: created_at < Before
+	Limit        int
+}
+
+// CoachingStorage — программы коучинга: цель по одной метрике, вехи и
+// еженедельные чекины прогресса.
+type CoachingStorage interface {
+	// CreateCoachingProgram сохраняет программу вместе с вехами.
+	CreateCoachingProgram(ctx context.Context, program CoachingProgram) (CoachingProgram, error)
+
+	// GetCoachingProgram возвращает программу по id в рамках owner. false — не найдена.
+	GetCoachingProgram(ctx context.Context, ownerUserID string, programID uuid.UUID) (CoachingProgram, bool, error)
+
+	// ListCoachingPrograms возвращает программы профиля, новые первыми; пустой status — любые.
+	ListCoachingPrograms(ctx context.Context, ownerUserID string, profileID uuid.UUID, status string) ([]CoachingProgram, error)
+
+	// UpdateCoachingProgram сохраняет статус, целевую дату, вехи и время
+	// следующего чекина. false — программа не найдена.
+	UpdateCoachingProgram(ctx context.Context, program CoachingProgram) (CoachingProgram, bool, error)
+
+	// DeleteCoachingProgram удаляет программу с вехами и чекинами. false — не найдена.
+	DeleteCoachingProgram(ctx context.Context, ownerUserID string, programID uuid.UUID) (bool, error)
+
+	// ListDueCoachingPrograms возвращает активные программы всех владельцев
+	// с next_checkin_at <= now, самые просроченные первыми.
+	ListDueCoachingPrograms(ctx context.Context, now time.Time, limit int) ([]CoachingProgram, error)
+
+	// RecordCoachingCheckin сохраняет чекин и обновлённую программу одной
+	// транзакцией. false — программа тем временем удалена.
+	RecordCoachingCheckin(ctx context.Context, checkin CoachingCheckin, program CoachingProgram) (CoachingCheckin, bool, error)
+
+	// ListCoachingCheckins возвращает чекины программы, старые первыми.
+	ListCoachingCheckins(ctx context.Context, ownerUserID string, programID uuid.UUID) ([]CoachingCheckin, error)
+}
+
+// CoachingProgram — цель по метрике (weight_kg, distance_km, sleep_minutes,
+// steps): от BaselineValue на StartDate к TargetValue на TargetDate.
+// Даты — полночь UTC.
+type CoachingProgram struct {
+	ID               uuid.UUID
+	OwnerUserID      string
+	ProfileID        uuid.UUID
+	Title            string
+	Metric           string
+	BaselineValue    float64
+	TargetValue      float64
+	StartDate        time.Time
+	TargetDate       time.Time
+	Milestones       []CoachingMilestone
+	Status           string // active, completed, cancelled
+	NextCheckinAt    time.Time
+	SourceProposalID *uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// CoachingMilestone — промежуточное значение метрики к дате; ReachedAt
+// ставит чекин, на котором оно достигнуто.
+type CoachingMilestone struct {
+	DueDate     time.Time
+	TargetValue float64
+	ReachedAt   *time.Time
+}
+
+// CoachingCheckin — итог недели программы: значение метрики за
+// [PeriodFrom, PeriodTo] против ожидаемого по графику. Value nil — нет данных.
+type CoachingCheckin struct {
+	ID            uuid.UUID
+	ProgramID     uuid.UUID
+	OwnerUserID   string
+	PeriodFrom    time.Time
+	PeriodTo      time.Time
+	Value         *float64
+	ExpectedValue float64
+	Status        string // on_track, ahead, behind, no_data, achieved
+	ProposalID    *uuid.UUID
+	CreatedAt     time.Time
+}
+
+// LabResultsStorage — результаты анализов: введённые вручную и извлечённые
+// из фото бланков (sources). Результаты переживают удаление source:
+// SourceID становится nil.
+type LabResultsStorage interface {
+	// CreateLabResult сохраняет результат, введённый вручную.
+	CreateLabResult(ctx context.Context, result LabResult) (LabResult, error)
+
+	// GetLabResult возвращает результат по id. false — не найден.
+	GetLabResult(ctx context.Context, id uuid.UUID) (LabResult, bool, error)
+
+	// UpdateLabResult сохраняет показатель, значение, единицы, интервал,
+	// флаг, дату и заметку. false — не найден.
+	UpdateLabResult(ctx context.Context, result LabResult) (LabResult, bool, error)
+
+	// DeleteLabResult удаляет результат. false — не найден.
+	DeleteLabResult(ctx context.Context, id uuid.UUID) (bool, error)
+
+	// ReplaceSourceLabResults заменяет результаты source одной транзакцией и
+	// возвращает сохранённые.
+	ReplaceSourceLabResults(ctx context.Context, sourceID uuid.UUID, results []LabResult) ([]LabResult, error)
+
+	// ListSourceLabResults возвращает результаты, извлечённые из source.
+	ListSourceLabResults(ctx context.Context, sourceID uuid.UUID) ([]LabResult, error)
+
+	// ListLabResults возвращает результаты профиля с TakenOn в [from, to],
+	// старые первыми; пустой analyte — любые, нулевые from/to — без границы.
+	ListLabResults(ctx context.Context, profileID uuid.UUID, analyte string, from, to time.Time) ([]LabResult, error)
+}
+
+// LabResult — один показатель анализа. Analyte — ключ каталога (ferritin,
+// vitamin_d, ...) или нормализованное название, Name — как на бланке.
+// RefLow/RefHigh и Flag (low, normal, high, unknown) — в единицах Unit.
+// TakenOn — полночь UTC дня взятия материала.
+type LabResult struct {
+	ID        uuid.UUID
+	ProfileID uuid.UUID
+	SourceID  *uuid.UUID
+	Analyte   string
+	Name      string
+	Value     float64
+	Unit      string
+	RefLow    *float64
+	RefHigh   *float64
+	Flag      string
+	TakenOn   time.Time
+	Note      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SymptomsStorage — журнал симптомов: что беспокоило, насколько сильно и
+// сколько длилось. Симптом с EndedAt nil ещё продолжается.
+type SymptomsStorage interface {
+	// CreateSymptom сохраняет симптом.
+	CreateSymptom(ctx context.Context, symptom Symptom) (Symptom, error)
+
+	// GetSymptom возвращает симптом по id. false — не найден.
+	GetSymptom(ctx context.Context, id uuid.UUID) (Symptom, bool, error)
+
+	// UpdateSymptom сохраняет название, тяжесть, время и заметку.
+	// false — не найден.
+	UpdateSymptom(ctx context.Context, symptom Symptom) (Symptom, bool, error)
+
+	// DeleteSymptom удаляет симптом. false — не найден.
+	DeleteSymptom(ctx context.Context, id uuid.UUID) (bool, error)
+
+	// ListSymptoms возвращает симптомы профиля, начавшиеся в [from, to),
+	// старые первыми; пустой name — любые (сравнение без учёта регистра),
+	// нулевые from/to — без границы.
+	ListSymptoms(ctx context.Context, profileID uuid.UUID, name string, from, to time.Time) ([]Symptom, error)
+}
+
+// Symptom — запись журнала симптомов. Severity — от 1 до 10; CheckinID —
+// чекин, в котором симптом отмечен (nil, если нет или чекин удалён).
+type Symptom struct {
+	ID        uuid.UUID
+	ProfileID uuid.UUID
+	CheckinID *uuid.UUID
+	Name      string
+	Severity  int
+	StartedAt time.Time
+	EndedAt   *time.Time
+	Note      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// CycleStorage — хранилище менструального цикла: месячные, отметки дней
+// и настройки. Все диапазоны дат включительные, нулевые from/to — без
+// границы.
+type CycleStorage interface {
+	// CreatePeriod сохраняет месячные.
+	CreatePeriod(ctx context.Context, period CyclePeriod) (CyclePeriod, error)
+
+	// GetPeriod возвращает месячные по id. false — не найдены.
+	GetPeriod(ctx context.Context, id uuid.UUID) (CyclePeriod, bool, error)
+
+	// UpdatePeriod сохраняет даты и заметку. false — не найдены.
+	UpdatePeriod(ctx context.Context, period CyclePeriod) (CyclePeriod, bool, error)
+
+	// DeletePeriod удаляет месячные. false — не найдены.
+	DeletePeriod(ctx context.Context, id uuid.UUID) (bool, error)
+
+	// ListPeriods возвращает месячные профиля, начавшиеся в [from, to],
+	// старые первыми.
+	ListPeriods(ctx context.Context, profileID uuid.UUID, from, to time.Time) ([]CyclePeriod, error)
+
+	// UpsertCycleDay сохраняет отметку дня (upsert по profile_id, date).
+	UpsertCycleDay(ctx context.Context, day CycleDay) (CycleDay, error)
+
+	// DeleteCycleDay удаляет отметку дня. false — не найдена.
+	DeleteCycleDay(ctx context.Context, profileID uuid.UUID, date time.Time) (bool, error)
+
+	// ListCycleDays возвращает отметки дней профиля в [from, to] по дате.
+	ListCycleDays(ctx context.Context, profileID uuid.UUID, from, to time.Time) ([]CycleDay, error)
+
+	// GetCycleSettings возвращает настройки профиля. false — не заданы.
+	GetCycleSettings(ctx context.Context, profileID uuid.UUID) (CycleSettings, bool, error)
+
+	// UpsertCycleSettings сохраняет настройки профиля.
+	UpsertCycleSettings(ctx context.Context, settings CycleSettings) (CycleSettings, error)
+}
+
+// CyclePeriod — месячные. EndDate — nil, пока не закончились.
+type CyclePeriod struct {
+	ID        uuid.UUID
+	ProfileID uuid.UUID
+	StartDate time.Time
+	EndDate   *time.Time
+	Note      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// CycleDay — отметка дня цикла: выделения (пусто, spotting, light, medium,
+// heavy) и симптомы из каталога.
+type CycleDay struct {
+	ID        uuid.UUID
+	ProfileID uuid.UUID
+	Date      time.Time
+	Flow      string
+	Symptoms  []string
+	Note      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// CycleSettings — настройки цикла профиля. ShareWithAI — можно ли
+// передавать данные цикла ассистенту; CycleLengthDays и PeriodLengthDays —
+// обычная длина цикла и месячных (0 — не указана), нужны, пока отмеченных
+// циклов мало.
+type CycleSettings struct {
+	ProfileID           uuid.UUID
+	ShareWithAI         bool
+	RemindersEnabled    bool
+	RemindDaysBefore    int
+	RemindFertileWindow bool
+	CycleLengthDays     int
+	PeriodLengthDays    int
+	UpdatedAt           time.Time
+}
+
+// MedicationsStorage — хранилище лекарств и журнала их приёмов. Остаток
+// лекарства меняется вместе с записью или удалением приёма.
+type MedicationsStorage interface {
+	// CreateMedication сохраняет лекарство.
+	CreateMedication(ctx context.Context, medication Medication) (Medication, error)
+
+	// GetMedication возвращает лекарство по id. false — не найдено.
+	GetMedication(ctx context.Context, id uuid.UUID) (Medication, bool, error)
+
+	// ListMedications возвращает лекарства профиля по имени; activeOnly —
+	// только принимаемые сейчас.
+	ListMedications(ctx context.Context, profileID uuid.UUID, activeOnly bool) ([]Medication, error)
+
+	// UpdateMedication сохраняет поля лекарства. Остаток (StockQuantity)
+	// перезаписывается только при setStock, иначе остаётся текущим, чтобы
+	// не затереть одновременное списание или пополнение. false — не найдено.
+	UpdateMedication(ctx context.Context, medication Medication, setStock bool) (Medication, bool, error)
+
+	// DeleteMedication удаляет лекарство вместе с приёмами. false — не
+	// найдено.
+	DeleteMedication(ctx context.Context, id uuid.UUID) (bool, error)
+
+	// AdjustMedicationStock прибавляет delta к остатку, не опуская его ниже
+	// нуля. Лекарства без учёта остатка не меняются. false — не найдено.
+	AdjustMedicationStock(ctx context.Context, id uuid.UUID, delta float64) (Medication, bool, error)
+
+	// CreateMedicationDose сохраняет приём; принятая доза (taken)
+	// списывается с остатка лекарства, но не больше остатка. Списанное
+	// возвращается в StockDeducted.
+	CreateMedicationDose(ctx context.Context, dose MedicationDose) (MedicationDose, error)
+
+	// GetMedicationDose возвращает приём по id. false — не найден.
+	GetMedicationDose(ctx context.Context, id uuid.UUID) (MedicationDose, bool, error)
+
+	// DeleteMedicationDose удаляет приём и возвращает в остаток ровно то,
+	// что приём списал (StockDeducted). false — не найден.
+	DeleteMedicationDose(ctx context.Context, id uuid.UUID) (bool, error)
+
+	// ListMedicationDoses возвращает приёмы профиля с taken_at в [from, to),
+	// старые первыми. medicationID == uuid.Nil — по всем лекарствам.
+	ListMedicationDoses(ctx context.Context, profileID, medicationID uuid.UUID, from, to time.Time) ([]MedicationDose, error)
+}
+
+// Medication — лекарство профиля. Дозы по расписанию (Schedule) берут
+// DoseAmount, если у времени не задана своя доза; AsNeeded — приём по
+// необходимости с лимитами MaxDailyDoses и MinIntervalHours (0 — без
+// лимита). StockQuantity считается в DoseUnit, nil — остаток не ведётся.
+type Medication struct {
+	ID               uuid.UUID
+	ProfileID        uuid.UUID
+	Name             string
+	Form             string
+	Strength         string
+	Ingredients      []string
+	DoseAmount       float64
+	DoseUnit         string
+	Schedule         []MedicationTime
+	DaysMask         int
+	AsNeeded         bool
+	MaxDailyDoses    int
+	MinIntervalHours int
+	StockQuantity    *float64
+	RefillThreshold  float64
+	Prescriber       string
+	Pharmacy         string
+	Instructions     string
+	Active           bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// MedicationTime — время приёма по расписанию в минутах от полуночи и доза
+// на это время (0 — обычная доза лекарства).
+type MedicationTime struct {
+	TimeMinutes int
+	Amount      float64
+}
+
+// MedicationDose — запись журнала приёмов: taken или skipped.
+type MedicationDose struct {
+	ID           uuid.UUID
+	ProfileID    uuid.UUID
+	MedicationID uuid.UUID
+	TakenAt      time.Time
+	Amount       float64
+	Unit         string
+	Status       string
+	AsNeeded     bool
+	Reason       string
+	// StockDeducted — сколько приём списал с остатка; 0, если остаток не
+	// учитывался или приём пропущен.
+	StockDeducted float64
+	CreatedAt     time.Time
+}
+
+// Типы документов полнотекстового поиска.
+const (
+	SearchTypeSource      = "source"
+	SearchTypeCheckin     = "checkin"
+	SearchTypeChatMessage = "chat_message"
+)
+
+// SearchStorage — полнотекстовый поиск по источникам (title, text), чекинам
+// (note, tags) и сообщениям чата профиля. Postgres ищет по tsvector с
+// конфигурациями russian и english; зашифрованные строки и memory-хранилище
+// ищутся инвертированным индексом textsearch.
+type SearchStorage interface {
+	// Search возвращает совпадения, лучшие первыми.
+	Search(ctx context.Context, query SearchQuery) ([]SearchHit, error)
+}
+
+// SearchQuery — запрос поиска. Пустой Types — все типы; From/To — даты
+// документа включительно, нулевые — без границы. OwnerUserID — владелец
+// профиля: ищутся только его сообщения чата.
+type SearchQuery struct {
+	ProfileID   uuid.UUID
+	OwnerUserID string
+	Text        string
+	Types       []string
+	From        time.Time
+	To          time.Time
+	Limit       int
+	Offset      int
+}
+
+// SearchHit — найденный документ. Kind — вид источника, тип чекина или
+// роль автора сообщения; ThreadID — только у сообщений чата. Snippet —
+// фрагмент текста с совпадениями в <mark>…</mark>. Date — created_at, для
+// чекина — его день.
+type SearchHit struct {
+	Type     string
+	ID       uuid.UUID
+	Kind     string
+	ThreadID *uuid.UUID
+	Title    *string
+	Snippet  string
+	Rank     float64
+	Date     time.Time
+}
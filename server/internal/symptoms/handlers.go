@@ -0,0 +1,168 @@
+package symptoms
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// HandleList handles GET /v1/symptoms?profile_id=&name=&from=&to=
+func (h *Handler) HandleList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	profileID, ok := parseProfileID(w, query.Get("profile_id"))
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.ListSymptoms(r.Context(), profileID, query.Get("name"),
+		query.Get("from"), query.Get("to"))
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleSummary handles GET /v1/symptoms/summary?profile_id=&from=&to=
+func (h *Handler) HandleSummary(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	profileID, ok := parseProfileID(w, query.Get("profile_id"))
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.Summary(r.Context(), profileID, query.Get("from"), query.Get("to"))
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleCreate handles POST /v1/symptoms
+func (h *Handler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var req CreateSymptomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.CreateSymptom(r.Context(), req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// HandleGet handles GET /v1/symptoms/{id}
+func (h *Handler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	symptomID, ok := parseSymptomID(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.GetSymptom(r.Context(), symptomID)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleUpdate handles PATCH /v1/symptoms/{id}
+func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	symptomID, ok := parseSymptomID(w, r)
+	if !ok {
+		return
+	}
+	var req UpdateSymptomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return
+	}
+
+	resp, err := h.service.UpdateSymptom(r.Context(), symptomID, req)
+	if err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleDelete handles DELETE /v1/symptoms/{id}
+func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	symptomID, ok := parseSymptomID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteSymptom(r.Context(), symptomID); err != nil {
+		h.handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+	case errors.Is(err, ErrProfileNotFound):
+		writeError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
+	case errors.Is(err, ErrSymptomNotFound):
+		writeError(w, http.StatusNotFound, "symptom_not_found", "Symptom not found")
+	case errors.Is(err, ErrCheckinNotFound):
+		writeError(w, http.StatusNotFound, "checkin_not_found", "Checkin not found")
+	default:
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+	}
+}
+
+func parseProfileID(w http.ResponseWriter, value string) (uuid.UUID, bool) {
+	profileID, err := uuid.Parse(strings.TrimSpace(value))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "profile_id is required")
+		return uuid.Nil, false
+	}
+	return profileID, true
+}
+
+func parseSymptomID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	symptomID, err := uuid.Parse(strings.TrimSpace(r.PathValue("id")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid symptom id")
+		return uuid.Nil, false
+	}
+	return symptomID, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{
+		Error: ErrorDetail{
+			Code:      code,
+			Message:   message,
+			RequestID: logging.ResponseRequestID(w),
+		},
+	})
+}
@@ -0,0 +1,207 @@
+package symptoms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/checkins"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/storage/memory"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+func TestSymptomsCRUDAndDuration(t *testing.T) {
+	handler, mem, profileID := setupSymptomsHandler(t)
+	checkin := &checkins.Checkin{ProfileID: profileID, Type: checkins.TypeAdhoc, Date: "2026-03-02", RecordedAt: time.Now().UTC()}
+	if err := mem.GetCheckinsStorage().UpsertCheckin(checkin); err != nil {
+		t.Fatalf("upsert checkin failed: %v", err)
+	}
+
+	w := serveSymptomsBody(handler.HandleCreate, http.MethodPost, "/v1/symptoms", "", "userA", map[string]any{
+		"profile_id":       profileID,
+		"checkin_id":       checkin.ID,
+		"name":             " Мигрень ",
+		"severity":         7,
+		"started_at":       "2026-03-02T08:00:00Z",
+		"duration_minutes": 90,
+		"note":             "после кофе",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d body=%s", w.Code, w.Body.String())
+	}
+	var created SymptomDTO
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if created.Name != "Мигрень" || created.CheckinID == nil || *created.CheckinID != checkin.ID {
+		t.Fatalf("unexpected symptom %+v", created)
+	}
+	if created.EndedAt == nil || !created.EndedAt.Equal(time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC)) ||
+		created.DurationMinutes == nil || *created.DurationMinutes != 90 {
+		t.Fatalf("expected the end derived from the duration, got %+v", created)
+	}
+
+	// Reopening clears the end, a new end time sets it again.
+	id := created.ID.String()
+	w = serveSymptomsBody(handler.HandleUpdate, http.MethodPatch, "/v1/symptoms/"+id, id, "userA", map[string]any{"ongoing": true})
+	var updated SymptomDTO
+	if err := json.NewDecoder(w.Body).Decode(&updated); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if w.Code != http.StatusOK || updated.EndedAt != nil || updated.DurationMinutes != nil {
+		t.Fatalf("expected an ongoing symptom, got %d %+v", w.Code, updated)
+	}
+	w = serveSymptomsBody(handler.HandleUpdate, http.MethodPatch, "/v1/symptoms/"+id, id, "userA", map[string]any{
+		"severity": 4, "ended_at": "2026-03-02T12:00:00Z",
+	})
+	if err := json.NewDecoder(w.Body).Decode(&updated); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if updated.Severity != 4 || updated.DurationMinutes == nil || *updated.DurationMinutes != 240 {
+		t.Fatalf("unexpected update %+v", updated)
+	}
+
+	w = serveSymptoms(handler.HandleGet, http.MethodGet, "/v1/symptoms/"+id, id, "userB")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user, got %d", w.Code)
+	}
+
+	w = serveSymptoms(handler.HandleDelete, http.MethodDelete, "/v1/symptoms/"+id, id, "userA")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d body=%s", w.Code, w.Body.String())
+	}
+	w = serveSymptoms(handler.HandleGet, http.MethodGet, "/v1/symptoms/"+id, id, "userA")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", w.Code)
+	}
+}
+
+func TestSymptomsRejectInvalidInput(t *testing.T) {
+	handler, _, profileID := setupSymptomsHandler(t)
+
+	cases := map[string]map[string]any{
+		"severity":         {"profile_id": profileID, "name": "Головная боль", "severity": 11},
+		"empty name":       {"profile_id": profileID, "name": " ", "severity": 3},
+		"end before start": {"profile_id": profileID, "name": "Тошнота", "severity": 3, "started_at": "2026-03-02T10:00:00Z", "ended_at": "2026-03-02T09:00:00Z"},
+		"end and duration": {"profile_id": profileID, "name": "Тошнота", "severity": 3, "ended_at": "2026-03-02T09:00:00Z", "duration_minutes": 10},
+		"future start":     {"profile_id": profileID, "name": "Тошнота", "severity": 3, "started_at": time.Now().Add(time.Hour).UTC()},
+	}
+	for name, body := range cases {
+		w := serveSymptomsBody(handler.HandleCreate, http.MethodPost, "/v1/symptoms", "", "userA", body)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d body=%s", name, w.Code, w.Body.String())
+		}
+	}
+
+	w := serveSymptomsBody(handler.HandleCreate, http.MethodPost, "/v1/symptoms", "", "userA", map[string]any{
+		"profile_id": profileID, "checkin_id": uuid.New(), "name": "Тошнота", "severity": 3,
+	})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown checkin, got %d", w.Code)
+	}
+	w = serveSymptomsBody(handler.HandleCreate, http.MethodPost, "/v1/symptoms", "", "userB", map[string]any{
+		"profile_id": profileID, "name": "Тошнота", "severity": 3,
+	})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user's profile, got %d", w.Code)
+	}
+}
+
+func TestSymptomsListAndSummary(t *testing.T) {
+	handler, _, profileID := setupSymptomsHandler(t)
+
+	for _, body := range []map[string]any{
+		{"name": "Мигрень", "severity": 6, "started_at": "2026-03-01T07:00:00Z", "duration_minutes": 120},
+		{"name": "мигрень", "severity": 9, "started_at": "2026-03-03T22:00:00Z", "duration_minutes": 60},
+		{"name": "Мигрень", "severity": 3, "started_at": "2026-03-03T23:00:00Z"},
+		{"name": "Тошнота", "severity": 2, "started_at": "2026-03-03T09:00:00Z", "duration_minutes": 15},
+		{"name": "Тошнота", "severity": 2, "started_at": "2026-03-05T09:00:00Z"},
+	} {
+		body["profile_id"] = profileID
+		if w := serveSymptomsBody(handler.HandleCreate, http.MethodPost, "/v1/symptoms", "", "userA", body); w.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d body=%s", w.Code, w.Body.String())
+		}
+	}
+
+	w := serveSymptoms(handler.HandleList, http.MethodGet, "/v1/symptoms?name=МИГРЕНЬ&to=2026-03-03&profile_id="+profileID.String(), "", "userA")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var listed SymptomsResponse
+	if err := json.NewDecoder(w.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(listed.Symptoms) != 3 || listed.Symptoms[0].Severity != 6 {
+		t.Fatalf("expected three migraines through the inclusive to date, oldest first, got %+v", listed.Symptoms)
+	}
+
+	w = serveSymptoms(handler.HandleSummary, http.MethodGet, "/v1/symptoms/summary?from=2026-03-01&to=2026-03-04&profile_id="+profileID.String(), "", "userA")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var summary SummaryResponse
+	if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(summary.Symptoms) != 2 {
+		t.Fatalf("expected two symptoms, got %+v", summary.Symptoms)
+	}
+	migraine := summary.Symptoms[0]
+	if migraine.Name != "Мигрень" || migraine.Count != 3 || migraine.Days != 2 || migraine.AvgSeverity != 6 ||
+		migraine.MaxSeverity != 9 || migraine.TotalMinutes != 180 {
+		t.Fatalf("unexpected migraine summary %+v", migraine)
+	}
+	if nausea := summary.Symptoms[1]; nausea.Count != 1 || nausea.TotalMinutes != 15 {
+		t.Fatalf("expected the nausea after to excluded, got %+v", nausea)
+	}
+
+	w = serveSymptoms(handler.HandleSummary, http.MethodGet, "/v1/symptoms/summary?from=2026-03-04&to=2026-03-01&profile_id="+profileID.String(), "", "userA")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an inverted range, got %d", w.Code)
+	}
+}
+
+func setupSymptomsHandler(t *testing.T) (*Handler, *memory.MemoryStorage, uuid.UUID) {
+	t.Helper()
+
+	mem := memory.New()
+	profileID := uuid.New()
+	for _, profile := range []storage.Profile{
+		{ID: profileID, OwnerUserID: "userA", Type: "owner", Name: "User A"},
+		{ID: uuid.New(), OwnerUserID: "userB", Type: "owner", Name: "User B"},
+	} {
+		if err := mem.CreateProfile(context.Background(), &profile); err != nil {
+			t.Fatalf("create profile failed: %v", err)
+		}
+	}
+
+	service := NewService(mem.GetSymptomsStorage(), mem, mem.GetCheckinsStorage())
+	return NewHandler(service), mem, profileID
+}
+
+func serveSymptoms(handle http.HandlerFunc, method, path, id, userID string) *httptest.ResponseRecorder {
+	return serveSymptomsBody(handle, method, path, id, userID, nil)
+}
+
+func serveSymptomsBody(handle http.HandlerFunc, method, path, id, userID string, body any) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if id != "" {
+		req.SetPathValue("id", id)
+	}
+	req = req.WithContext(userctx.WithUserID(context.Background(), userID))
+	w := httptest.NewRecorder()
+	handle(w, req)
+	return w
+}
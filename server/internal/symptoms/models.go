@@ -0,0 +1,86 @@
+package symptoms
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SymptomDTO is one symptom episode. EndedAt and DurationMinutes are null
+// while the symptom is ongoing; CheckinID is null for symptoms logged on
+// their own and once the checkin is deleted.
+type SymptomDTO struct {
+	ID              uuid.UUID  `json:"id"`
+	ProfileID       uuid.UUID  `json:"profile_id"`
+	CheckinID       *uuid.UUID `json:"checkin_id"`
+	Name            string     `json:"name"`
+	Severity        int        `json:"severity"`
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationMinutes *int       `json:"duration_minutes"`
+	Note            string     `json:"note"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// CreateSymptomRequest logs a symptom. StartedAt defaults to now; the end
+// is given either as EndedAt or as DurationMinutes.
+type CreateSymptomRequest struct {
+	ProfileID       uuid.UUID  `json:"profile_id"`
+	CheckinID       *uuid.UUID `json:"checkin_id,omitempty"`
+	Name            string     `json:"name"`
+	Severity        int        `json:"severity"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"`
+	DurationMinutes *int       `json:"duration_minutes,omitempty"`
+	Note            string     `json:"note,omitempty"`
+}
+
+// UpdateSymptomRequest changes the given fields. Ongoing clears the end of
+// a symptom that turned out not to be over.
+type UpdateSymptomRequest struct {
+	Name            *string    `json:"name,omitempty"`
+	Severity        *int       `json:"severity,omitempty"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"`
+	DurationMinutes *int       `json:"duration_minutes,omitempty"`
+	Ongoing         bool       `json:"ongoing,omitempty"`
+	Note            *string    `json:"note,omitempty"`
+}
+
+// SymptomsResponse lists the symptoms of a profile, oldest first.
+type SymptomsResponse struct {
+	Symptoms []SymptomDTO `json:"symptoms"`
+}
+
+// SummaryItemDTO aggregates the episodes of one symptom. TotalMinutes
+// counts finished episodes only.
+type SummaryItemDTO struct {
+	Name          string    `json:"name"`
+	Count         int       `json:"count"`
+	Days          int       `json:"days"`
+	AvgSeverity   float64   `json:"avg_severity"`
+	MaxSeverity   int       `json:"max_severity"`
+	TotalMinutes  int       `json:"total_minutes"`
+	LastStartedAt time.Time `json:"last_started_at"`
+}
+
+// SummaryResponse lists symptoms by how often they occurred, most frequent
+// first.
+type SummaryResponse struct {
+	ProfileID uuid.UUID        `json:"profile_id"`
+	From      string           `json:"from,omitempty"`
+	To        string           `json:"to,omitempty"`
+	Symptoms  []SummaryItemDTO `json:"symptoms"`
+}
+
+// ErrorResponse — стандартный формат ошибки.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
@@ -0,0 +1,414 @@
+package symptoms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fdg312/health-hub/internal/checkins"
+	"github.com/fdg312/health-hub/internal/storage"
+	"github.com/fdg312/health-hub/internal/userctx"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRequest  = errors.New("invalid request")
+	ErrProfileNotFound = errors.New("profile not found")
+	ErrSymptomNotFound = errors.New("symptom not found")
+	ErrCheckinNotFound = errors.New("checkin not found")
+)
+
+// Limits on one symptom.
+const (
+	minSeverity        = 1
+	maxSeverity        = 10
+	maxNameLength      = 100
+	maxNoteLength      = 1000
+	maxDurationMinutes = 60 * 24 * 31
+)
+
+type profileReader interface {
+	GetProfile(ctx context.Context, id uuid.UUID) (*storage.Profile, error)
+}
+
+// checkinReader looks up the checkin a symptom is reported in.
+type checkinReader interface {
+	GetCheckin(id uuid.UUID) (*checkins.Checkin, error)
+}
+
+type Service struct {
+	storage        storage.SymptomsStorage
+	profileStorage profileReader
+	checkins       checkinReader
+	now            func() time.Time
+}
+
+func NewService(symptomsStorage storage.SymptomsStorage, profileStorage profileReader, checkinReader checkinReader) *Service {
+	return &Service{
+		storage:        symptomsStorage,
+		profileStorage: profileStorage,
+		checkins:       checkinReader,
+		now:            time.Now,
+	}
+}
+
+// ListSymptoms returns the symptoms of a profile started between the from
+// and to dates, both inclusive; name filters by symptom name.
+func (s *Service) ListSymptoms(ctx context.Context, profileID uuid.UUID, name, from, to string) (*SymptomsResponse, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, err
+	}
+	fromTime, toTime, err := parseRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := s.storage.ListSymptoms(ctx, profileID, strings.TrimSpace(name), fromTime, toTime)
+	if err != nil {
+		return nil, err
+	}
+	return &SymptomsResponse{Symptoms: toDTOs(list)}, nil
+}
+
+// Summary aggregates the symptoms of a profile per name.
+func (s *Service) Summary(ctx context.Context, profileID uuid.UUID, from, to string) (*SummaryResponse, error) {
+	if err := s.ensureProfileAccess(ctx, profileID); err != nil {
+		return nil, err
+	}
+	fromTime, toTime, err := parseRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := s.storage.ListSymptoms(ctx, profileID, "", fromTime, toTime)
+	if err != nil {
+		return nil, err
+	}
+	return &SummaryResponse{
+		ProfileID: profileID,
+		From:      strings.TrimSpace(from),
+		To:        strings.TrimSpace(to),
+		Symptoms:  summarize(list),
+	}, nil
+}
+
+// CreateSymptom logs a symptom, optionally as part of a checkin of the
+// same profile.
+func (s *Service) CreateSymptom(ctx context.Context, req CreateSymptomRequest) (*SymptomDTO, error) {
+	if err := s.ensureProfileAccess(ctx, req.ProfileID); err != nil {
+		return nil, err
+	}
+	if req.CheckinID != nil {
+		if err := s.checkCheckin(req.ProfileID, *req.CheckinID); err != nil {
+			return nil, err
+		}
+	}
+
+	symptom := storage.Symptom{
+		ProfileID: req.ProfileID,
+		CheckinID: req.CheckinID,
+		Severity:  req.Severity,
+		StartedAt: s.now().UTC(),
+	}
+	var err error
+	if symptom.Name, err = normalizeName(req.Name); err != nil {
+		return nil, err
+	}
+	if symptom.Note, err = normalizeNote(req.Note); err != nil {
+		return nil, err
+	}
+	if req.StartedAt != nil {
+		symptom.StartedAt = req.StartedAt.UTC()
+	}
+	if req.EndedAt != nil && req.DurationMinutes != nil {
+		return nil, fmt.Errorf("%w: give either ended_at or duration_minutes", ErrInvalidRequest)
+	}
+	symptom.EndedAt, err = endOf(symptom.StartedAt, req.EndedAt, req.DurationMinutes)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validate(symptom); err != nil {
+		return nil, err
+	}
+
+	created, err := s.storage.CreateSymptom(ctx, symptom)
+	if err != nil {
+		return nil, err
+	}
+	dto := toDTO(created)
+	return &dto, nil
+}
+
+// GetSymptom returns a symptom of an accessible profile.
+func (s *Service) GetSymptom(ctx context.Context, id uuid.UUID) (*SymptomDTO, error) {
+	symptom, err := s.getOwnedSymptom(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	dto := toDTO(symptom)
+	return &dto, nil
+}
+
+// UpdateSymptom changes the given fields. A duration is counted from the
+// start after the update, so a symptom can be ended with its length alone.
+func (s *Service) UpdateSymptom(ctx context.Context, id uuid.UUID, req UpdateSymptomRequest) (*SymptomDTO, error) {
+	symptom, err := s.getOwnedSymptom(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		if symptom.Name, err = normalizeName(*req.Name); err != nil {
+			return nil, err
+		}
+	}
+	if req.Severity != nil {
+		symptom.Severity = *req.Severity
+	}
+	if req.Note != nil {
+		if symptom.Note, err = normalizeNote(*req.Note); err != nil {
+			return nil, err
+		}
+	}
+	if req.StartedAt != nil {
+		symptom.StartedAt = req.StartedAt.UTC()
+	}
+	if (req.EndedAt != nil || req.DurationMinutes != nil) && req.Ongoing {
+		return nil, fmt.Errorf("%w: ongoing excludes ended_at and duration_minutes", ErrInvalidRequest)
+	}
+	if req.EndedAt != nil && req.DurationMinutes != nil {
+		return nil, fmt.Errorf("%w: give either ended_at or duration_minutes", ErrInvalidRequest)
+	}
+	switch {
+	case req.Ongoing:
+		symptom.EndedAt = nil
+	case req.EndedAt != nil || req.DurationMinutes != nil:
+		if symptom.EndedAt, err = endOf(symptom.StartedAt, req.EndedAt, req.DurationMinutes); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.validate(symptom); err != nil {
+		return nil, err
+	}
+
+	updated, ok, err := s.storage.UpdateSymptom(ctx, symptom)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSymptomNotFound
+	}
+	dto := toDTO(updated)
+	return &dto, nil
+}
+
+// DeleteSymptom deletes a symptom of an accessible profile.
+func (s *Service) DeleteSymptom(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.getOwnedSymptom(ctx, id); err != nil {
+		return err
+	}
+	deleted, err := s.storage.DeleteSymptom(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrSymptomNotFound
+	}
+	return nil
+}
+
+func (s *Service) getOwnedSymptom(ctx context.Context, id uuid.UUID) (storage.Symptom, error) {
+	symptom, ok, err := s.storage.GetSymptom(ctx, id)
+	if err != nil {
+		return storage.Symptom{}, err
+	}
+	if !ok {
+		return storage.Symptom{}, ErrSymptomNotFound
+	}
+	if err := s.ensureProfileAccess(ctx, symptom.ProfileID); err != nil {
+		return storage.Symptom{}, ErrSymptomNotFound
+	}
+	return symptom, nil
+}
+
+// checkCheckin refuses checkins that do not exist or belong to another
+// profile than the symptom; both look the same to the caller.
+func (s *Service) checkCheckin(profileID, checkinID uuid.UUID) error {
+	checkin, err := s.checkins.GetCheckin(checkinID)
+	if err != nil || checkin.ProfileID != profileID {
+		return ErrCheckinNotFound
+	}
+	return nil
+}
+
+// validate checks what both create and update may leave wrong.
+func (s *Service) validate(symptom storage.Symptom) error {
+	if symptom.Severity < minSeverity || symptom.Severity > maxSeverity {
+		return fmt.Errorf("%w: severity must be %d-%d", ErrInvalidRequest, minSeverity, maxSeverity)
+	}
+	if symptom.StartedAt.After(s.now().Add(time.Minute)) {
+		return fmt.Errorf("%w: started_at is in the future", ErrInvalidRequest)
+	}
+	if symptom.EndedAt != nil && symptom.EndedAt.Before(symptom.StartedAt) {
+		return fmt.Errorf("%w: ended_at is before started_at", ErrInvalidRequest)
+	}
+	return nil
+}
+
+func (s *Service) ensureProfileAccess(ctx context.Context, profileID uuid.UUID) error {
+	profile, err := s.profileStorage.GetProfile(ctx, profileID)
+	if err != nil {
+		return ErrProfileNotFound
+	}
+
+	if userID, ok := userctx.GetUserID(ctx); ok && strings.TrimSpace(userID) != "" && profile.OwnerUserID != userID {
+		return ErrProfileNotFound
+	}
+
+	return nil
+}
+
+// endOf resolves the end of a symptom from an end time or a duration.
+func endOf(startedAt time.Time, endedAt *time.Time, durationMinutes *int) (*time.Time, error) {
+	switch {
+	case endedAt != nil:
+		end := endedAt.UTC()
+		return &end, nil
+	case durationMinutes != nil:
+		if *durationMinutes < 0 || *durationMinutes > maxDurationMinutes {
+			return nil, fmt.Errorf("%w: duration_minutes must be 0-%d", ErrInvalidRequest, maxDurationMinutes)
+		}
+		end := startedAt.Add(time.Duration(*durationMinutes) * time.Minute)
+		return &end, nil
+	default:
+		return nil, nil
+	}
+}
+
+func normalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxNameLength {
+		return "", fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidRequest, maxNameLength)
+	}
+	return name, nil
+}
+
+func normalizeNote(note string) (string, error) {
+	note = strings.TrimSpace(note)
+	if len([]rune(note)) > maxNoteLength {
+		return "", fmt.Errorf("%w: note is too long", ErrInvalidRequest)
+	}
+	return note, nil
+}
+
+// parseRange turns inclusive from/to dates into the [from, to) range of
+// start times the storage filters by.
+func parseRange(from, to string) (time.Time, time.Time, error) {
+	fromDate, err := parseOptionalDate(from, "from")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	toDate, err := parseOptionalDate(to, "to")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !fromDate.IsZero() && !toDate.IsZero() && toDate.Before(fromDate) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to is before from", ErrInvalidRequest)
+	}
+	if !toDate.IsZero() {
+		toDate = toDate.AddDate(0, 0, 1)
+	}
+	return fromDate, toDate, nil
+}
+
+func parseOptionalDate(value, field string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid %s", ErrInvalidRequest, field)
+	}
+	return parsed, nil
+}
+
+// summarize groups symptoms by case-insensitive name, keeping the spelling
+// of the latest episode.
+func summarize(list []storage.Symptom) []SummaryItemDTO {
+	type group struct {
+		item     SummaryItemDTO
+		severity int
+		days     map[string]struct{}
+	}
+	groups := make(map[string]*group)
+	for _, symptom := range list {
+		key := strings.ToLower(symptom.Name)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{days: make(map[string]struct{})}
+			groups[key] = g
+		}
+		g.item.Count++
+		g.severity += symptom.Severity
+		g.item.MaxSeverity = max(g.item.MaxSeverity, symptom.Severity)
+		g.days[symptom.StartedAt.UTC().Format("2006-01-02")] = struct{}{}
+		if minutes := durationMinutes(symptom); minutes != nil {
+			g.item.TotalMinutes += *minutes
+		}
+		if !symptom.StartedAt.Before(g.item.LastStartedAt) {
+			g.item.LastStartedAt = symptom.StartedAt
+			g.item.Name = symptom.Name
+		}
+	}
+
+	items := make([]SummaryItemDTO, 0, len(groups))
+	for _, g := range groups {
+		g.item.Days = len(g.days)
+		g.item.AvgSeverity = math.Round(float64(g.severity)/float64(g.item.Count)*10) / 10
+		items = append(items, g.item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].LastStartedAt.After(items[j].LastStartedAt)
+	})
+	return items
+}
+
+func durationMinutes(symptom storage.Symptom) *int {
+	if symptom.EndedAt == nil {
+		return nil
+	}
+	minutes := int(symptom.EndedAt.Sub(symptom.StartedAt).Round(time.Minute) / time.Minute)
+	return &minutes
+}
+
+func toDTOs(list []storage.Symptom) []SymptomDTO {
+	dtos := make([]SymptomDTO, 0, len(list))
+	for _, symptom := range list {
+		dtos = append(dtos, toDTO(symptom))
+	}
+	return dtos
+}
+
+func toDTO(symptom storage.Symptom) SymptomDTO {
+	return SymptomDTO{
+		ID:              symptom.ID,
+		ProfileID:       symptom.ProfileID,
+		CheckinID:       symptom.CheckinID,
+		Name:            symptom.Name,
+		Severity:        symptom.Severity,
+		StartedAt:       symptom.StartedAt,
+		EndedAt:         symptom.EndedAt,
+		DurationMinutes: durationMinutes(symptom),
+		Note:            symptom.Note,
+		CreatedAt:       symptom.CreatedAt,
+		UpdatedAt:       symptom.UpdatedAt,
+	}
+}
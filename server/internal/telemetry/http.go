@@ -0,0 +1,49 @@
+package telemetry
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/fdg312/health-hub/internal/logging"
+)
+
+// Middleware opens a server span per request (continuing an incoming
+// traceparent) and records latency per route. It must run inside
+// logging.Middleware: the route is read from the request info that
+// logging.RouteMiddleware fills in after the mux has matched.
+func Middleware(m *Metrics, next http.Handler) http.Handler {
+	tracer := otel.Tracer(instrumentationName)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, "HTTP "+r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("http.request_id", logging.RequestID(r.Context())),
+			),
+		)
+		defer span.End()
+
+		rec := logging.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		route := logging.Route(ctx)
+		if route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.Status()))
+		if rec.Status() >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.Status()))
+		}
+		m.ObserveHTTP(r.Method, route, rec.Status(), time.Since(start))
+	})
+}
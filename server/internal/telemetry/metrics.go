@@ -0,0 +1,136 @@
+// Package telemetry provides Prometheus metrics and OpenTelemetry tracing.
+package telemetry
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "health_hub"
+
+// Metrics holds the application's Prometheus collectors on a private
+// registry. A nil *Metrics is valid and records nothing, so services and
+// tests work without telemetry.
+type Metrics struct {
+	registry       *prometheus.Registry
+	httpDuration   *prometheus.HistogramVec
+	aiDuration     *prometheus.HistogramVec
+	aiErrors       *prometheus.CounterVec
+	reportDuration *prometheus.HistogramVec
+	syncBatchSize  *prometheus.HistogramVec
+}
+
+// NewMetrics creates and registers all collectors, including Go runtime and
+// process metrics.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		aiDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "ai_request_duration_seconds",
+			Help:      "AI provider call latency.",
+			Buckets:   []float64{0.25, 0.5, 1, 2, 4, 8, 16, 32, 64},
+		}, []string{"provider", "outcome"}),
+		aiErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ai_request_errors_total",
+			Help:      "AI provider calls that returned an error.",
+		}, []string{"provider"}),
+		reportDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "report_generation_duration_seconds",
+			Help:      "Report generation time including upload.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"format", "outcome"}),
+		syncBatchSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "sync_batch_size",
+			Help:      "Items per metrics sync batch by kind.",
+			Buckets:   []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000},
+		}, []string{"kind"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpDuration,
+		m.aiDuration,
+		m.aiErrors,
+		m.reportDuration,
+		m.syncBatchSize,
+	)
+	return m
+}
+
+// Registry exposes the registry for tests and extra collectors.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the Prometheus text exposition.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterPgxPool exports connection pool statistics.
+func (m *Metrics) RegisterPgxPool(stat func() *pgxpool.Stat) {
+	if m == nil || stat == nil {
+		return
+	}
+	m.registry.MustRegister(newPgxPoolCollector(stat))
+}
+
+func (m *Metrics) ObserveHTTP(method, route string, status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	if route == "" {
+		route = "unmatched"
+	}
+	m.httpDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(d.Seconds())
+}
+
+func (m *Metrics) ObserveAIRequest(provider string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.aiDuration.WithLabelValues(provider, outcome(err)).Observe(d.Seconds())
+	if err != nil {
+		m.aiErrors.WithLabelValues(provider).Inc()
+	}
+}
+
+func (m *Metrics) ObserveReport(format string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.reportDuration.WithLabelValues(format, outcome(err)).Observe(d.Seconds())
+}
+
+// ObserveSyncBatch records how many items of one kind (daily, hourly,
+// sleep_segments, workouts) a sync batch carried.
+func (m *Metrics) ObserveSyncBatch(kind string, size int) {
+	if m == nil {
+		return
+	}
+	m.syncBatchSize.WithLabelValues(kind).Observe(float64(size))
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,46 @@
+package telemetry
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PgxTracer is a pgx.QueryTracer that wraps every query in a client span.
+// Query arguments are never recorded: they may carry health data.
+type PgxTracer struct{}
+
+type pgxSpanKey struct{}
+
+func (PgxTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, "db "+sqlOperation(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.statement", data.SQL),
+		),
+	)
+	return context.WithValue(ctx, pgxSpanKey{}, span)
+}
+
+func (PgxTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span, ok := ctx.Value(pgxSpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	EndSpan(span, data.Err)
+}
+
+// sqlOperation returns the leading SQL keyword (SELECT, INSERT, ...).
+func sqlOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "query"
+	}
+	return strings.ToUpper(fields[0])
+}
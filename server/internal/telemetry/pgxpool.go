@@ -0,0 +1,81 @@
+package telemetry
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pgxPoolCollector reads pgxpool.Stat at scrape time.
+type pgxPoolCollector struct {
+	stat func() *pgxpool.Stat
+
+	acquiredConns   *prometheus.Desc
+	idleConns       *prometheus.Desc
+	totalConns      *prometheus.Desc
+	maxConns        *prometheus.Desc
+	acquireCount    *prometheus.Desc
+	acquireDuration *prometheus.Desc
+	emptyAcquire    *prometheus.Desc
+	canceledAcquire *prometheus.Desc
+	newConns        *prometheus.Desc
+	destroyedByIdle *prometheus.Desc
+	destroyedByLife *prometheus.Desc
+}
+
+func newPgxPoolCollector(stat func() *pgxpool.Stat) *pgxPoolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return &pgxPoolCollector{
+		stat:            stat,
+		acquiredConns:   desc("acquired_connections", "Connections currently in use."),
+		idleConns:       desc("idle_connections", "Idle connections in the pool."),
+		totalConns:      desc("total_connections", "All connections in the pool."),
+		maxConns:        desc("max_connections", "Configured pool size."),
+		acquireCount:    desc("acquires_total", "Successful connection acquires."),
+		acquireDuration: desc("acquire_duration_seconds_total", "Time spent waiting for connections."),
+		emptyAcquire:    desc("empty_acquires_total", "Acquires that had to wait for a connection."),
+		canceledAcquire: desc("canceled_acquires_total", "Acquires canceled by context."),
+		newConns:        desc("new_connections_total", "Connections opened."),
+		destroyedByIdle: desc("idle_destroyed_total", "Connections closed for exceeding idle time."),
+		destroyedByLife: desc("lifetime_destroyed_total", "Connections closed for exceeding max lifetime."),
+	}
+}
+
+func (c *pgxPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.emptyAcquire
+	ch <- c.canceledAcquire
+	ch <- c.newConns
+	ch <- c.destroyedByIdle
+	ch <- c.destroyedByLife
+}
+
+func (c *pgxPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stat()
+	if s == nil {
+		return
+	}
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge(c.acquiredConns, float64(s.AcquiredConns()))
+	gauge(c.idleConns, float64(s.IdleConns()))
+	gauge(c.totalConns, float64(s.TotalConns()))
+	gauge(c.maxConns, float64(s.MaxConns()))
+	counter(c.acquireCount, float64(s.AcquireCount()))
+	counter(c.acquireDuration, s.AcquireDuration().Seconds())
+	counter(c.emptyAcquire, float64(s.EmptyAcquireCount()))
+	counter(c.canceledAcquire, float64(s.CanceledAcquireCount()))
+	counter(c.newConns, float64(s.NewConnsCount()))
+	counter(c.destroyedByIdle, float64(s.MaxIdleDestroyCount()))
+	counter(c.destroyedByLife, float64(s.MaxLifetimeDestroyCount()))
+}
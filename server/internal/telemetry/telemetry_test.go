@@ -0,0 +1,137 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/fdg312/health-hub/internal/logging"
+)
+
+// useInMemoryTracer installs a synchronous in-memory tracer provider for
+// the duration of the test.
+func useInMemoryTracer(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	return exporter
+}
+
+func testHandler(m *Metrics, mux *http.ServeMux) http.Handler {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	ip := func(*http.Request) string { return "127.0.0.1" }
+	return logging.Middleware(logger, ip, Middleware(m, logging.RouteMiddleware(mux)))
+}
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	return string(body)
+}
+
+func TestMiddlewareRecordsSpansAndRouteLatency(t *testing.T) {
+	exporter := useInMemoryTracer(t)
+	m := NewMetrics()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := StartSpan(r.Context(), "things.Get")
+		EndSpan(span, errors.New("lookup failed"))
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/things/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	testHandler(m, mux).ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	child, server := spans[0], spans[1]
+	if server.Name != "GET /v1/things/{id}" {
+		t.Fatalf("expected server span named after the route, got %q", server.Name)
+	}
+	if server.Status.Code != codes.Error {
+		t.Fatalf("expected 5xx to mark server span as error, got %v", server.Status.Code)
+	}
+	if got := server.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected incoming trace to be continued, got trace %s", got)
+	}
+	if child.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Fatalf("expected %q to be a child of the server span", child.Name)
+	}
+	if child.Status.Code != codes.Error || len(child.Events) == 0 {
+		t.Fatalf("expected child span to record the error")
+	}
+
+	out := scrape(t, m)
+	if !strings.Contains(out, `health_hub_http_request_duration_seconds_count{method="GET",route="/v1/things/{id}",status="500"} 1`) {
+		t.Fatalf("expected route latency sample, got:\n%s", out)
+	}
+}
+
+func TestUnmatchedRoutesShareOneLabel(t *testing.T) {
+	useInMemoryTracer(t)
+	m := NewMetrics()
+	handler := testHandler(m, http.NewServeMux())
+
+	for _, path := range []string{"/nope/1", "/nope/2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	out := scrape(t, m)
+	if !strings.Contains(out, `health_hub_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 2`) {
+		t.Fatalf("expected unmatched paths to share a label, got:\n%s", out)
+	}
+}
+
+func TestObserveHelpers(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveAIRequest("openai", 2*time.Second, nil)
+	m.ObserveAIRequest("openai", time.Second, errors.New("timeout"))
+	m.ObserveReport("pdf", 300*time.Millisecond, nil)
+	m.ObserveSyncBatch("daily", 7)
+
+	out := scrape(t, m)
+	for _, want := range []string{
+		`health_hub_ai_request_errors_total{provider="openai"} 1`,
+		`health_hub_ai_request_duration_seconds_count{outcome="ok",provider="openai"} 1`,
+		`health_hub_report_generation_duration_seconds_count{format="pdf",outcome="ok"} 1`,
+		`health_hub_sync_batch_size_sum{kind="daily"} 7`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %s in metrics output", want)
+		}
+	}
+
+	// A nil *Metrics must be usable by services without telemetry.
+	var nilMetrics *Metrics
+	nilMetrics.ObserveHTTP("GET", "/x", 200, time.Millisecond)
+	nilMetrics.ObserveAIRequest("mock", time.Millisecond, nil)
+	nilMetrics.ObserveReport("csv", time.Millisecond, nil)
+	nilMetrics.ObserveSyncBatch("hourly", 1)
+	nilMetrics.RegisterPgxPool(nil)
+}
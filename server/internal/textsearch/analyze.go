@@ -0,0 +1,139 @@
+// Package textsearch is the full-text search used where Postgres tsvector
+// cannot be: the memory store and encrypted rows. It approximates the
+// russian and english text search configurations: words are lowercased,
+// stop words dropped and endings stripped by a light stemmer.
+package textsearch
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Highlight markers and snippet length, shared with ts_headline so both
+// stores mark matches the same way.
+const (
+	StartSel     = "<mark>"
+	StopSel      = "</mark>"
+	SnippetWords = 20
+)
+
+// Query is a parsed search: every term must match and no excluded term may.
+// "-word" excludes a word, as in websearch_to_tsquery.
+type Query struct {
+	terms    []string
+	excluded []string
+}
+
+// ParseQuery parses a user query.
+func ParseQuery(text string) Query {
+	var q Query
+	for _, field := range strings.Fields(text) {
+		exclude := strings.HasPrefix(field, "-")
+		for _, word := range words(field) {
+			term, ok := Term(word)
+			if !ok {
+				continue
+			}
+			if exclude {
+				q.excluded = appendUnique(q.excluded, term)
+			} else {
+				q.terms = appendUnique(q.terms, term)
+			}
+		}
+	}
+	return q
+}
+
+// Empty reports whether the query has no term to match.
+func (q Query) Empty() bool {
+	return len(q.terms) == 0
+}
+
+// Terms returns the indexed terms of a text, stop words left out.
+func Terms(text string) []string {
+	var terms []string
+	for _, word := range words(text) {
+		if term, ok := Term(word); ok {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Term normalizes a single word; false for stop words.
+func Term(word string) (string, bool) {
+	word = strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+	if word == "" || stopWords[word] {
+		return "", false
+	}
+	return stem(word), true
+}
+
+// words splits text into runs of letters and digits.
+func words(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Endings stripped by stem, longest first. A stem keeps at least
+// minStemLength letters.
+var (
+	russianEndings = []string{
+		"иями", "ями", "ами", "ого", "его", "ому", "ему", "ыми", "ими", "ией", "ость", "ости",
+		"ий", "ый", "ой", "ая", "яя", "ое", "ее", "ие", "ые", "ов", "ев", "ей", "ам", "ям",
+		"ах", "ях", "ом", "ем", "ую", "юю", "ть", "ла", "ло", "ли", "ет", "ют", "ит", "ат", "ят",
+		"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+	}
+	englishEndings = []string{"ing", "ed", "es", "ly", "s", "e"}
+)
+
+const minStemLength = 3
+
+func stem(word string) string {
+	endings := englishEndings
+	for _, r := range word {
+		if unicode.Is(unicode.Cyrillic, r) {
+			endings = russianEndings
+			break
+		}
+	}
+
+	length := len([]rune(word))
+	if strings.HasSuffix(word, "ies") && length > 4 {
+		return strings.TrimSuffix(word, "ies") + "y"
+	}
+	for _, ending := range endings {
+		if strings.HasSuffix(word, ending) && length-len([]rune(ending)) >= minStemLength {
+			return strings.TrimSuffix(word, ending)
+		}
+	}
+	return word
+}
+
+// stopWords are the most frequent words of the Postgres russian and english
+// stop lists.
+var stopWords = map[string]bool{
+	"и": true, "в": true, "во": true, "не": true, "что": true, "он": true, "на": true, "я": true,
+	"с": true, "со": true, "как": true, "а": true, "то": true, "все": true, "она": true, "так": true,
+	"его": true, "но": true, "да": true, "ты": true, "к": true, "у": true, "же": true, "вы": true,
+	"за": true, "бы": true, "по": true, "только": true, "ее": true, "мне": true, "было": true,
+	"вот": true, "от": true, "меня": true, "еще": true, "нет": true, "о": true, "из": true,
+	"ему": true, "когда": true, "ну": true, "ли": true, "если": true, "или": true, "до": true,
+	"после": true, "при": true, "для": true, "это": true, "мы": true, "их": true, "без": true,
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true,
+	"by": true, "for": true, "if": true, "in": true, "into": true, "is": true, "it": true,
+	"no": true, "not": true, "of": true, "on": true, "or": true, "such": true, "that": true,
+	"the": true, "their": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "to": true, "was": true, "will": true, "with": true, "after": true,
+	"before": true, "i": true, "my": true, "me": true,
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, existing := range list {
+		if existing == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
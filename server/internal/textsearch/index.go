@@ -0,0 +1,211 @@
+package textsearch
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// titleWeight counts a title term as this many body terms, like setweight
+// 'A' against 'D' in ts_rank.
+const titleWeight = 2.5
+
+// Index is an inverted index of documents identified by key. A nil Index
+// ignores writes, so stores can hold one optionally.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]map[string]float64 // key -> term -> weighted frequency
+	postings map[string]map[string]bool    // term -> keys
+}
+
+// Match is a document matching a query; higher scores rank first.
+type Match struct {
+	Key   string
+	Score float64
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]map[string]float64),
+		postings: make(map[string]map[string]bool),
+	}
+}
+
+// Put indexes a document, replacing an earlier version with the same key.
+func (ix *Index) Put(key, title, body string) {
+	if ix == nil {
+		return
+	}
+	frequencies := make(map[string]float64)
+	for _, term := range Terms(title) {
+		frequencies[term] += titleWeight
+	}
+	for _, term := range Terms(body) {
+		frequencies[term]++
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(key)
+	if len(frequencies) == 0 {
+		return
+	}
+	ix.docs[key] = frequencies
+	for term := range frequencies {
+		if ix.postings[term] == nil {
+			ix.postings[term] = make(map[string]bool)
+		}
+		ix.postings[term][key] = true
+	}
+}
+
+// Remove drops a document.
+func (ix *Index) Remove(key string) {
+	if ix == nil {
+		return
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(key)
+}
+
+func (ix *Index) remove(key string) {
+	for term := range ix.docs[key] {
+		delete(ix.postings[term], key)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	delete(ix.docs, key)
+}
+
+// Search returns the documents containing every query term and no excluded
+// one, best first. Scores follow BM25 without length normalization.
+func (ix *Index) Search(q Query) []Match {
+	if ix == nil || q.Empty() {
+		return nil
+	}
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Candidates are the keys of the rarest term.
+	rarest := q.terms[0]
+	for _, term := range q.terms[1:] {
+		if len(ix.postings[term]) < len(ix.postings[rarest]) {
+			rarest = term
+		}
+	}
+
+	total := float64(len(ix.docs))
+	var matches []Match
+	for key := range ix.postings[rarest] {
+		frequencies := ix.docs[key]
+		score := 0.0
+		matched := true
+		for _, term := range q.terms {
+			frequency, ok := frequencies[term]
+			if !ok {
+				matched = false
+				break
+			}
+			df := float64(len(ix.postings[term]))
+			idf := math.Log(1 + (total-df+0.5)/(df+0.5))
+			score += idf * frequency / (frequency + 1.2)
+		}
+		for _, term := range q.excluded {
+			if _, ok := frequencies[term]; ok {
+				matched = false
+			}
+		}
+		if matched {
+			matches = append(matches, Match{Key: key, Score: math.Round(score*10000) / 10000})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Key < matches[j].Key
+	})
+	return matches
+}
+
+// Highlight returns up to maxWords words of text around the first match,
+// query words wrapped in StartSel and StopSel and cut ends marked with "…".
+func Highlight(text string, q Query, maxWords int) string {
+	type span struct {
+		start, end int
+		match      bool
+	}
+
+	matchTerms := make(map[string]bool, len(q.terms))
+	for _, term := range q.terms {
+		matchTerms[term] = true
+	}
+
+	var spans []span
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		term, ok := Term(text[start:end])
+		spans = append(spans, span{start: start, end: end, match: ok && matchTerms[term]})
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+	if len(spans) == 0 {
+		return strings.TrimSpace(text)
+	}
+
+	first, last := 0, len(spans)-1
+	if len(spans) > maxWords {
+		first = 0
+		for i, s := range spans {
+			if s.match {
+				first = max(0, i-maxWords/4)
+				break
+			}
+		}
+		first = min(first, len(spans)-maxWords)
+		last = first + maxWords - 1
+	}
+
+	var b strings.Builder
+	from := 0
+	if first > 0 {
+		b.WriteString("… ")
+		from = spans[first].start
+	}
+	to := len(text)
+	if last < len(spans)-1 {
+		to = spans[last].end
+	}
+	for _, s := range spans[first : last+1] {
+		if !s.match {
+			continue
+		}
+		b.WriteString(text[from:s.start])
+		b.WriteString(StartSel)
+		b.WriteString(text[s.start:s.end])
+		b.WriteString(StopSel)
+		from = s.end
+	}
+	b.WriteString(text[from:to])
+	if to < len(text) {
+		b.WriteString(" …")
+	}
+	return strings.TrimSpace(b.String())
+}
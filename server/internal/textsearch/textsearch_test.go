@@ -0,0 +1,61 @@
+package textsearch
+
+import "testing"
+
+func TestIndexSearch(t *testing.T) {
+	ix := NewIndex()
+	ix.Put("note", "", "Headache after coffee again, skipped lunch")
+	ix.Put("checkin", "", "Голова болела после кофе; кофе, сон")
+	ix.Put("title", "Coffee", "tried decaf")
+	ix.Put("chat", "", "How much coffee is fine?")
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"headaches coffee", []string{"note"}},
+		{"кофе", []string{"checkin"}},
+		{"coffee -decaf", []string{"chat", "note"}},
+		{"the", nil},
+	} {
+		matches := ix.Search(ParseQuery(tc.query))
+		var got []string
+		for _, m := range matches {
+			got = append(got, m.Key)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("%q: expected %v, got %v", tc.query, tc.want, got)
+		}
+		seen := make(map[string]bool)
+		for _, key := range got {
+			seen[key] = true
+		}
+		for _, key := range tc.want {
+			if !seen[key] {
+				t.Fatalf("%q: expected %v, got %v", tc.query, tc.want, got)
+			}
+		}
+	}
+
+	// A title match outranks body matches.
+	if matches := ix.Search(ParseQuery("coffee")); len(matches) != 3 || matches[0].Key != "title" {
+		t.Fatalf("expected the titled document first, got %+v", matches)
+	}
+
+	ix.Remove("note")
+	if matches := ix.Search(ParseQuery("headache")); len(matches) != 0 {
+		t.Fatalf("expected removed document gone, got %+v", matches)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	q := ParseQuery("кофе головная")
+	if got := Highlight("Головная боль после кофе.", q, 20); got != "<mark>Головная</mark> боль после <mark>кофе</mark>." {
+		t.Fatalf("unexpected highlight %q", got)
+	}
+
+	text := "one two three four five six seven eight nine ten coffee twelve thirteen fourteen fifteen"
+	if got := Highlight(text, ParseQuery("coffee"), 8); got != "… eight nine ten <mark>coffee</mark> twelve thirteen fourteen fifteen" {
+		t.Fatalf("unexpected window %q", got)
+	}
+}
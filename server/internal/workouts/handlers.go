@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+
+	"github.com/fdg312/health-hub/internal/logging"
 )
 
 type Handlers struct {
@@ -33,7 +35,7 @@ func (h *Handlers) HandleGetPlan(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.service.GetActivePlan(r.Context(), profileID)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -50,7 +52,7 @@ func (h *Handlers) HandleReplacePlan(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.service.ReplacePlanAndItems(r.Context(), &req)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -67,7 +69,7 @@ func (h *Handlers) HandleUpsertCompletion(w http.ResponseWriter, r *http.Request
 
 	resp, err := h.service.UpsertCompletion(r.Context(), &req)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -95,7 +97,7 @@ func (h *Handlers) HandleGetToday(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.service.GetToday(r.Context(), profileID, date)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -124,7 +126,7 @@ func (h *Handlers) HandleListCompletions(w http.ResponseWriter, r *http.Request)
 
 	resp, err := h.service.ListCompletions(r.Context(), profileID, from, to)
 	if err != nil {
-		h.handleError(w, err)
+		h.handleError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -134,7 +136,7 @@ func (h *Handlers) HandleListCompletions(w http.ResponseWriter, r *http.Request)
 // Error handling
 // ============================================================================
 
-func (h *Handlers) handleError(w http.ResponseWriter, err error) {
+func (h *Handlers) handleError(w http.ResponseWriter, r *http.Request, err error) {
 	switch {
 	case errors.Is(err, ErrUnauthorized):
 		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
@@ -147,6 +149,7 @@ func (h *Handlers) handleError(w http.ResponseWriter, err error) {
 	case errors.Is(err, ErrInvalidRequest):
 		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
 	default:
+		logging.FromContext(r.Context()).Error("request failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error")
 	}
 }
@@ -164,10 +167,14 @@ func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 func writeError(w http.ResponseWriter, status int, code, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
+	detail := map[string]string{
+		"code":    code,
+		"message": message,
+	}
+	if requestID := logging.ResponseRequestID(w); requestID != "" {
+		detail["request_id"] = requestID
+	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": map[string]string{
-			"code":    code,
-			"message": message,
-		},
+		"error": detail,
 	})
 }
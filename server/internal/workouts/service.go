@@ -152,6 +152,47 @@ func (s *Service) ReplacePlanAndItems(ctx context.Context, req *ReplaceItemsRequ
 	}, nil
 }
 
+// RestorePlan puts back a plan previously returned by GetActivePlan. A nil
+// plan deactivates the current one, leaving the profile without a plan.
+func (s *Service) RestorePlan(ctx context.Context, profileID uuid.UUID, snapshot *GetPlanResponse) error {
+	userID := normalizeOwner(userIDFromContext(ctx))
+	if userID == "" {
+		return ErrUnauthorized
+	}
+	if profileID == uuid.Nil || snapshot == nil {
+		return ErrInvalidRequest
+	}
+
+	if err := s.ensureProfileOwned(ctx, userID, profileID); err != nil {
+		return err
+	}
+
+	if snapshot.Plan == nil {
+		return s.plansStorage.DeactivateActivePlan(userID, profileID)
+	}
+
+	plan, err := s.plansStorage.UpsertActivePlan(userID, profileID, snapshot.Plan.Title, snapshot.Plan.Goal)
+	if err != nil {
+		return err
+	}
+
+	storageItems := make([]storage.WorkoutItemUpsert, 0, len(snapshot.Items))
+	for _, item := range snapshot.Items {
+		storageItems = append(storageItems, storage.WorkoutItemUpsert{
+			Kind:        item.Kind,
+			TimeMinutes: item.TimeMinutes,
+			DaysMask:    item.DaysMask,
+			DurationMin: item.DurationMin,
+			Intensity:   item.Intensity,
+			Note:        item.Note,
+			Details:     []byte(item.Details),
+		})
+	}
+
+	_, err = s.itemsStorage.ReplaceAllItems(userID, profileID, plan.ID, storageItems)
+	return err
+}
+
 // UpsertCompletion creates or updates a workout completion record.
 func (s *Service) UpsertCompletion(ctx context.Context, req *UpsertCompletionRequest) (*CompletionDTO, error) {
 	userID := normalizeOwner(userIDFromContext(ctx))
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS email_otp_abuse (
+    scope TEXT NOT NULL CHECK (scope IN ('email', 'ip')),
+    key TEXT NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    requests INT NOT NULL DEFAULT 0,
+    failures INT NOT NULL DEFAULT 0,
+    lockout_level INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    last_challenge_nonce TEXT NOT NULL DEFAULT '',
+    last_event_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_otp_abuse_locked_until
+    ON email_otp_abuse(locked_until)
+    WHERE locked_until IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_email_otp_abuse_last_event
+    ON email_otp_abuse(last_event_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS email_otp_abuse;
@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS audit_events (
+    id UUID PRIMARY KEY,
+    owner_user_id TEXT NOT NULL,
+    actor_user_id TEXT NOT NULL,
+    profile_id UUID,
+    action TEXT NOT NULL,
+    resource_type TEXT NOT NULL,
+    resource_id TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Audit rows are intentionally not FK-linked to profiles: history must
+-- survive profile deletion until retention removes it.
+CREATE INDEX IF NOT EXISTS idx_audit_events_owner_created
+    ON audit_events(owner_user_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_profile_created
+    ON audit_events(profile_id, created_at DESC)
+    WHERE profile_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created
+    ON audit_events(created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_events;
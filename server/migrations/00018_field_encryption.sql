@@ -0,0 +1,25 @@
+-- +goose Up
+-- Envelope encryption: rows written with FIELD_ENCRYPTION_KEYS set carry the
+-- master key ID and the wrapped per-row data key. Rows with a NULL key ID are
+-- plaintext (written before encryption was enabled) until cmd/reencrypt runs.
+ALTER TABLE checkins
+    ADD COLUMN IF NOT EXISTS enc_key_id TEXT,
+    ADD COLUMN IF NOT EXISTS enc_data_key BYTEA;
+
+ALTER TABLE sources
+    ADD COLUMN IF NOT EXISTS enc_key_id TEXT,
+    ADD COLUMN IF NOT EXISTS enc_data_key BYTEA;
+
+ALTER TABLE chat_messages
+    ADD COLUMN IF NOT EXISTS enc_key_id TEXT,
+    ADD COLUMN IF NOT EXISTS enc_data_key BYTEA;
+
+ALTER TABLE ai_proposals
+    ADD COLUMN IF NOT EXISTS enc_key_id TEXT,
+    ADD COLUMN IF NOT EXISTS enc_data_key BYTEA;
+
+-- +goose Down
+ALTER TABLE ai_proposals DROP COLUMN IF EXISTS enc_data_key, DROP COLUMN IF EXISTS enc_key_id;
+ALTER TABLE chat_messages DROP COLUMN IF EXISTS enc_data_key, DROP COLUMN IF EXISTS enc_key_id;
+ALTER TABLE sources DROP COLUMN IF EXISTS enc_data_key, DROP COLUMN IF EXISTS enc_key_id;
+ALTER TABLE checkins DROP COLUMN IF EXISTS enc_data_key, DROP COLUMN IF EXISTS enc_key_id;
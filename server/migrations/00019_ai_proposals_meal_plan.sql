@@ -0,0 +1,11 @@
+-- +goose Up
+-- meal_plan proposals were stored as 'generic' and could not be applied.
+ALTER TABLE ai_proposals DROP CONSTRAINT IF EXISTS ai_proposals_kind_check;
+ALTER TABLE ai_proposals ADD CONSTRAINT ai_proposals_kind_check
+    CHECK (kind IN ('settings_update', 'vitamins_schedule', 'workout_plan', 'nutrition_plan', 'meal_plan', 'generic'));
+
+-- +goose Down
+UPDATE ai_proposals SET kind = 'generic' WHERE kind = 'meal_plan';
+ALTER TABLE ai_proposals DROP CONSTRAINT IF EXISTS ai_proposals_kind_check;
+ALTER TABLE ai_proposals ADD CONSTRAINT ai_proposals_kind_check
+    CHECK (kind IN ('settings_update', 'vitamins_schedule', 'workout_plan', 'nutrition_plan', 'generic'));
@@ -0,0 +1,68 @@
+-- +goose Up
+-- Chat threads: each conversation has its own history and a rolling summary
+-- of messages up to summarized_until. title and summary are encrypted like
+-- chat_messages.content when FIELD_ENCRYPTION_KEYS is set.
+CREATE TABLE IF NOT EXISTS chat_threads (
+    id UUID PRIMARY KEY,
+    owner_user_id TEXT NOT NULL,
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    summary TEXT NOT NULL DEFAULT '',
+    summarized_until TIMESTAMPTZ,
+    archived_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    enc_key_id TEXT,
+    enc_data_key BYTEA
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_threads_owner_profile_updated
+    ON chat_threads(owner_user_id, profile_id, updated_at DESC);
+
+-- Existing messages move into one thread per owner/profile.
+INSERT INTO chat_threads (id, owner_user_id, profile_id, title, created_at, updated_at)
+SELECT gen_random_uuid(), owner_user_id, profile_id, 'Чат', MIN(created_at), MAX(created_at)
+FROM chat_messages
+GROUP BY owner_user_id, profile_id;
+
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS thread_id UUID REFERENCES chat_threads(id) ON DELETE CASCADE;
+
+UPDATE chat_messages m
+SET thread_id = t.id
+FROM chat_threads t
+WHERE t.owner_user_id = m.owner_user_id AND t.profile_id = m.profile_id AND m.thread_id IS NULL;
+
+ALTER TABLE chat_messages ALTER COLUMN thread_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_chat_messages_thread_created
+    ON chat_messages(thread_id, created_at DESC);
+
+-- Facts about the user the assistant remembers across threads. Added only
+-- when the user applies a 'memory' proposal.
+CREATE TABLE IF NOT EXISTS chat_memory_facts (
+    id UUID PRIMARY KEY,
+    owner_user_id TEXT NOT NULL,
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    enc_key_id TEXT,
+    enc_data_key BYTEA
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_memory_facts_owner_profile
+    ON chat_memory_facts(owner_user_id, profile_id, created_at);
+
+ALTER TABLE ai_proposals DROP CONSTRAINT IF EXISTS ai_proposals_kind_check;
+ALTER TABLE ai_proposals ADD CONSTRAINT ai_proposals_kind_check
+    CHECK (kind IN ('settings_update', 'vitamins_schedule', 'workout_plan', 'nutrition_plan', 'meal_plan', 'memory', 'generic'));
+
+-- +goose Down
+UPDATE ai_proposals SET kind = 'generic' WHERE kind = 'memory';
+ALTER TABLE ai_proposals DROP CONSTRAINT IF EXISTS ai_proposals_kind_check;
+ALTER TABLE ai_proposals ADD CONSTRAINT ai_proposals_kind_check
+    CHECK (kind IN ('settings_update', 'vitamins_schedule', 'workout_plan', 'nutrition_plan', 'meal_plan', 'generic'));
+
+DROP TABLE IF EXISTS chat_memory_facts;
+DROP INDEX IF EXISTS idx_chat_messages_thread_created;
+ALTER TABLE chat_messages DROP COLUMN IF EXISTS thread_id;
+DROP TABLE IF EXISTS chat_threads;
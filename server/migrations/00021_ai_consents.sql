@@ -0,0 +1,20 @@
+-- +goose Up
+-- Owners' consent to sending their data to AI providers. Rows are never
+-- deleted: revoking sets revoked_at, granting again adds a new row.
+CREATE TABLE IF NOT EXISTS ai_consents (
+    id UUID PRIMARY KEY,
+    owner_user_id TEXT NOT NULL,
+    policy_version TEXT NOT NULL,
+    granted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_consents_owner_granted
+    ON ai_consents(owner_user_id, granted_at DESC);
+
+-- At most one active consent per owner.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ai_consents_owner_active
+    ON ai_consents(owner_user_id) WHERE revoked_at IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS ai_consents;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Token usage of AI providers per owner, UTC day and model. Each provider
+-- call increments its row, so quotas are checked against cheap sums.
+CREATE TABLE IF NOT EXISTS ai_usage_daily (
+    owner_user_id TEXT NOT NULL,
+    day DATE NOT NULL,
+    provider TEXT NOT NULL,
+    model TEXT NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    input_tokens BIGINT NOT NULL DEFAULT 0,
+    output_tokens BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (owner_user_id, day, provider, model)
+);
+
+-- Admin aggregates scan by day across owners.
+CREATE INDEX IF NOT EXISTS idx_ai_usage_daily_day
+    ON ai_usage_daily(day);
+
+-- +goose Down
+DROP TABLE IF EXISTS ai_usage_daily;
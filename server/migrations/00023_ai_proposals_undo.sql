@@ -0,0 +1,20 @@
+-- +goose Up
+-- Applying a proposal stores the state it replaced, so it can be undone
+-- for PROPOSAL_UNDO_DAYS. The snapshot is sealed like the payload.
+ALTER TABLE ai_proposals
+    ADD COLUMN IF NOT EXISTS applied_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS snapshot JSONB;
+
+ALTER TABLE ai_proposals DROP CONSTRAINT IF EXISTS ai_proposals_status_check;
+ALTER TABLE ai_proposals ADD CONSTRAINT ai_proposals_status_check
+    CHECK (status IN ('pending', 'applied', 'rejected', 'undone'));
+
+-- +goose Down
+UPDATE ai_proposals SET status = 'applied' WHERE status = 'undone';
+ALTER TABLE ai_proposals DROP CONSTRAINT IF EXISTS ai_proposals_status_check;
+ALTER TABLE ai_proposals ADD CONSTRAINT ai_proposals_status_check
+    CHECK (status IN ('pending', 'applied', 'rejected'));
+
+ALTER TABLE ai_proposals
+    DROP COLUMN IF EXISTS snapshot,
+    DROP COLUMN IF EXISTS applied_at;
@@ -0,0 +1,41 @@
+-- +goose Up
+-- Pending proposals expire after PROPOSAL_TTL_DAYS and are superseded by a
+-- newer proposal of the same kind. Rows from before this migration get the
+-- default two weeks from creation.
+ALTER TABLE ai_proposals
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+UPDATE ai_proposals
+SET expires_at = created_at + INTERVAL '14 days'
+WHERE status = 'pending' AND expires_at IS NULL;
+
+ALTER TABLE ai_proposals DROP CONSTRAINT IF EXISTS ai_proposals_status_check;
+ALTER TABLE ai_proposals ADD CONSTRAINT ai_proposals_status_check
+    CHECK (status IN ('pending', 'applied', 'rejected', 'undone', 'expired', 'superseded'));
+
+-- The expiry sweep looks only at pending rows.
+CREATE INDEX IF NOT EXISTS idx_ai_proposals_pending_expires
+    ON ai_proposals(expires_at) WHERE status = 'pending';
+
+-- Status history of each proposal, oldest first.
+CREATE TABLE IF NOT EXISTS ai_proposal_events (
+    id UUID PRIMARY KEY,
+    proposal_id UUID NOT NULL REFERENCES ai_proposals(id) ON DELETE CASCADE,
+    owner_user_id TEXT NOT NULL,
+    event TEXT NOT NULL CHECK (event IN ('created', 'viewed', 'applied', 'rejected', 'expired', 'superseded', 'undone')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_proposal_events_proposal
+    ON ai_proposal_events(proposal_id, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS ai_proposal_events;
+DROP INDEX IF EXISTS idx_ai_proposals_pending_expires;
+
+UPDATE ai_proposals SET status = 'rejected' WHERE status IN ('expired', 'superseded');
+ALTER TABLE ai_proposals DROP CONSTRAINT IF EXISTS ai_proposals_status_check;
+ALTER TABLE ai_proposals ADD CONSTRAINT ai_proposals_status_check
+    CHECK (status IN ('pending', 'applied', 'rejected', 'undone'));
+
+ALTER TABLE ai_proposals DROP COLUMN IF EXISTS expires_at;
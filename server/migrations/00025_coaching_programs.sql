@@ -0,0 +1,68 @@
+-- +goose Up
+-- Goal-driven coaching programs: one tracked metric moving from a baseline
+-- to a target by target_date, with intermediate milestones. A weekly
+-- check-in compares daily_metrics against the schedule.
+CREATE TABLE IF NOT EXISTS coaching_programs (
+    id UUID PRIMARY KEY,
+    owner_user_id TEXT NOT NULL,
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    metric TEXT NOT NULL CHECK (metric IN ('weight_kg', 'distance_km', 'sleep_minutes', 'steps')),
+    baseline_value DOUBLE PRECISION NOT NULL,
+    target_value DOUBLE PRECISION NOT NULL,
+    start_date DATE NOT NULL,
+    target_date DATE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'cancelled')),
+    next_checkin_at TIMESTAMPTZ NOT NULL,
+    source_proposal_id UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (target_date > start_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_coaching_programs_owner_profile
+    ON coaching_programs(owner_user_id, profile_id, created_at DESC);
+
+-- The check-in job scans due active programs across owners.
+CREATE INDEX IF NOT EXISTS idx_coaching_programs_due
+    ON coaching_programs(next_checkin_at)
+    WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS coaching_milestones (
+    program_id UUID NOT NULL REFERENCES coaching_programs(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    due_date DATE NOT NULL,
+    target_value DOUBLE PRECISION NOT NULL,
+    reached_at TIMESTAMPTZ,
+    PRIMARY KEY (program_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS coaching_checkins (
+    id UUID PRIMARY KEY,
+    program_id UUID NOT NULL REFERENCES coaching_programs(id) ON DELETE CASCADE,
+    owner_user_id TEXT NOT NULL,
+    period_from DATE NOT NULL,
+    period_to DATE NOT NULL,
+    value DOUBLE PRECISION,
+    expected_value DOUBLE PRECISION NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('on_track', 'ahead', 'behind', 'no_data', 'achieved')),
+    proposal_id UUID REFERENCES ai_proposals(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_coaching_checkins_program
+    ON coaching_checkins(program_id, period_to);
+
+ALTER TABLE ai_proposals DROP CONSTRAINT IF EXISTS ai_proposals_kind_check;
+ALTER TABLE ai_proposals ADD CONSTRAINT ai_proposals_kind_check
+    CHECK (kind IN ('settings_update', 'vitamins_schedule', 'workout_plan', 'nutrition_plan', 'meal_plan', 'memory', 'coaching_program', 'coaching_adjustment', 'generic'));
+
+-- +goose Down
+UPDATE ai_proposals SET kind = 'generic' WHERE kind IN ('coaching_program', 'coaching_adjustment');
+ALTER TABLE ai_proposals DROP CONSTRAINT IF EXISTS ai_proposals_kind_check;
+ALTER TABLE ai_proposals ADD CONSTRAINT ai_proposals_kind_check
+    CHECK (kind IN ('settings_update', 'vitamins_schedule', 'workout_plan', 'nutrition_plan', 'meal_plan', 'memory', 'generic'));
+
+DROP TABLE IF EXISTS coaching_checkins;
+DROP TABLE IF EXISTS coaching_milestones;
+DROP TABLE IF EXISTS coaching_programs;
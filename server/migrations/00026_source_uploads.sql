@@ -0,0 +1,20 @@
+-- +goose Up
+-- Direct uploads in flight: a presigned PUT was issued for object_key and
+-- the source is created once the client reports completion. Rows left
+-- past expires_at are removed together with their objects by a janitor.
+CREATE TABLE IF NOT EXISTS source_uploads (
+    id UUID PRIMARY KEY,
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    checkin_id UUID REFERENCES checkins(id) ON DELETE CASCADE,
+    title TEXT,
+    object_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_source_uploads_expires ON source_uploads(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS source_uploads;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Longest sides of the JPEG thumbnails stored next to an image source's
+-- object, at <object_key>_thumb_<size>.jpg. Empty for sources created
+-- before thumbnails existed; those are rendered on request.
+ALTER TABLE sources ADD COLUMN IF NOT EXISTS thumbnail_sizes INTEGER[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE sources DROP COLUMN IF EXISTS thumbnail_sizes;
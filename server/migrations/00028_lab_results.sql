@@ -0,0 +1,26 @@
+-- +goose Up
+-- Lab values read from photos of lab reports. A result keeps its source
+-- for reference; deleting the photo keeps the values for charts.
+CREATE TABLE IF NOT EXISTS lab_results (
+    id UUID PRIMARY KEY,
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    source_id UUID REFERENCES sources(id) ON DELETE SET NULL,
+    analyte TEXT NOT NULL,
+    name TEXT NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    unit TEXT NOT NULL DEFAULT '',
+    ref_low DOUBLE PRECISION,
+    ref_high DOUBLE PRECISION,
+    taken_on DATE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_lab_results_profile_analyte
+    ON lab_results(profile_id, analyte, taken_on);
+
+CREATE INDEX IF NOT EXISTS idx_lab_results_source
+    ON lab_results(source_id)
+    WHERE source_id IS NOT NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS lab_results;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Lab results become editable and can be entered by hand. The flag compares
+-- the value with the result's reference range, or with the analyte's
+-- default range when the report printed none.
+ALTER TABLE lab_results ADD COLUMN IF NOT EXISTS flag TEXT NOT NULL DEFAULT 'unknown'
+    CHECK (flag IN ('low', 'normal', 'high', 'unknown'));
+ALTER TABLE lab_results ADD COLUMN IF NOT EXISTS note TEXT NOT NULL DEFAULT '';
+ALTER TABLE lab_results ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+UPDATE lab_results SET flag = CASE
+    WHEN ref_low IS NULL AND ref_high IS NULL THEN 'unknown'
+    WHEN ref_low IS NOT NULL AND value < ref_low THEN 'low'
+    WHEN ref_high IS NOT NULL AND value > ref_high THEN 'high'
+    ELSE 'normal'
+END;
+UPDATE lab_results SET updated_at = created_at;
+
+-- +goose Down
+ALTER TABLE lab_results DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE lab_results DROP COLUMN IF EXISTS note;
+ALTER TABLE lab_results DROP COLUMN IF EXISTS flag;
@@ -0,0 +1,44 @@
+-- +goose Up
+-- Unified search (GET /v1/search) over sources, checkins and chat messages.
+-- Text is indexed in both the russian and english configurations. Encrypted
+-- columns (enc_key_id set) are left out of the vectors: with
+-- FIELD_ENCRYPTION_KEYS the server searches decrypted rows instead.
+ALTER TABLE sources ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian'::regconfig, coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english'::regconfig, coalesce(title, '')), 'A') ||
+        CASE WHEN enc_key_id IS NULL THEN
+            to_tsvector('russian'::regconfig, coalesce(text, '')) ||
+            to_tsvector('english'::regconfig, coalesce(text, ''))
+        ELSE ''::tsvector END
+    ) STORED;
+
+ALTER TABLE checkins ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian'::regconfig, tags), 'B') ||
+        setweight(to_tsvector('english'::regconfig, tags), 'B') ||
+        CASE WHEN enc_key_id IS NULL THEN
+            to_tsvector('russian'::regconfig, note) ||
+            to_tsvector('english'::regconfig, note)
+        ELSE ''::tsvector END
+    ) STORED;
+
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        CASE WHEN enc_key_id IS NULL THEN
+            to_tsvector('russian'::regconfig, content) ||
+            to_tsvector('english'::regconfig, content)
+        ELSE ''::tsvector END
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_sources_search ON sources USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_checkins_search ON checkins USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_chat_messages_search ON chat_messages USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_chat_messages_search;
+DROP INDEX IF EXISTS idx_checkins_search;
+DROP INDEX IF EXISTS idx_sources_search;
+ALTER TABLE chat_messages DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE checkins DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE sources DROP COLUMN IF EXISTS search_vector;
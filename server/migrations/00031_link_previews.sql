@@ -0,0 +1,28 @@
+-- +goose Up
+-- OpenGraph previews and archived text of link sources. The preview image
+-- reuses object_key, content_type, size_bytes and thumbnail_sizes.
+-- preview_title, preview_description, preview_site_name and archive_text are
+-- sealed with the row key when FIELD_ENCRYPTION_KEYS is set.
+ALTER TABLE sources
+    ADD COLUMN IF NOT EXISTS preview_status TEXT
+        CHECK (preview_status IN ('pending', 'ready', 'failed')),
+    ADD COLUMN IF NOT EXISTS preview_title TEXT,
+    ADD COLUMN IF NOT EXISTS preview_description TEXT,
+    ADD COLUMN IF NOT EXISTS preview_site_name TEXT,
+    ADD COLUMN IF NOT EXISTS preview_error TEXT,
+    ADD COLUMN IF NOT EXISTS preview_fetched_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS archive_text TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_sources_preview_pending
+    ON sources (created_at) WHERE preview_status = 'pending';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_sources_preview_pending;
+ALTER TABLE sources
+    DROP COLUMN IF EXISTS archive_text,
+    DROP COLUMN IF EXISTS preview_fetched_at,
+    DROP COLUMN IF EXISTS preview_error,
+    DROP COLUMN IF EXISTS preview_site_name,
+    DROP COLUMN IF EXISTS preview_description,
+    DROP COLUMN IF EXISTS preview_title,
+    DROP COLUMN IF EXISTS preview_status;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- Voice notes (kind = 'audio'). The recording reuses object_key,
+-- content_type and size_bytes; the transcript is stored in text, so it is
+-- sealed and searched like the text of a note.
+ALTER TABLE sources DROP CONSTRAINT IF EXISTS sources_kind_check;
+ALTER TABLE sources ADD CONSTRAINT sources_kind_check
+    CHECK (kind IN ('link', 'note', 'image', 'audio'));
+
+ALTER TABLE sources
+    ADD COLUMN IF NOT EXISTS duration_ms BIGINT,
+    ADD COLUMN IF NOT EXISTS transcript_status TEXT
+        CHECK (transcript_status IN ('pending', 'ready', 'failed')),
+    ADD COLUMN IF NOT EXISTS transcript_language TEXT,
+    ADD COLUMN IF NOT EXISTS transcript_error TEXT,
+    ADD COLUMN IF NOT EXISTS transcribed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_sources_transcript_pending
+    ON sources (created_at) WHERE transcript_status = 'pending';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_sources_transcript_pending;
+ALTER TABLE sources
+    DROP COLUMN IF EXISTS transcribed_at,
+    DROP COLUMN IF EXISTS transcript_error,
+    DROP COLUMN IF EXISTS transcript_language,
+    DROP COLUMN IF EXISTS transcript_status,
+    DROP COLUMN IF EXISTS duration_ms;
+
+DELETE FROM sources WHERE kind = 'audio';
+ALTER TABLE sources DROP CONSTRAINT IF EXISTS sources_kind_check;
+ALTER TABLE sources ADD CONSTRAINT sources_kind_check
+    CHECK (kind IN ('link', 'note', 'image'));
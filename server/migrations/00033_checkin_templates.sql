@@ -0,0 +1,119 @@
+-- +goose Up
+-- Checkin templates: user-defined questions a checkin is filled with. Every
+-- profile has a default morning and evening template asking for the score.
+CREATE TABLE IF NOT EXISTS checkin_templates (
+    id UUID PRIMARY KEY,
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('morning', 'evening', 'adhoc')),
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    questions JSONB NOT NULL DEFAULT '[]'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_checkin_templates_profile ON checkin_templates(profile_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_checkin_templates_default
+    ON checkin_templates(profile_id, type) WHERE is_default;
+
+-- Adhoc checkins: any number per day, score optional. Morning and evening
+-- stay one per day.
+ALTER TABLE checkins DROP CONSTRAINT IF EXISTS checkins_type_check;
+ALTER TABLE checkins ADD CONSTRAINT checkins_type_check
+    CHECK (type IN ('morning', 'evening', 'adhoc'));
+ALTER TABLE checkins ALTER COLUMN score DROP NOT NULL;
+ALTER TABLE checkins ADD CONSTRAINT checkins_score_required
+    CHECK (type = 'adhoc' OR score IS NOT NULL);
+ALTER TABLE checkins DROP CONSTRAINT IF EXISTS checkins_profile_id_date_type_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_checkins_daily
+    ON checkins(profile_id, date, type) WHERE type IN ('morning', 'evening');
+
+ALTER TABLE checkins
+    ADD COLUMN IF NOT EXISTS template_id UUID REFERENCES checkin_templates(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS recorded_at TIMESTAMPTZ;
+UPDATE checkins SET recorded_at = created_at WHERE recorded_at IS NULL;
+ALTER TABLE checkins
+    ALTER COLUMN recorded_at SET NOT NULL,
+    ALTER COLUMN recorded_at SET DEFAULT NOW();
+
+-- One row per answered question so answers can be charted. Text answers
+-- are encrypted with the data key of the checkin they were given in.
+CREATE TABLE IF NOT EXISTS checkin_answers (
+    id UUID PRIMARY KEY,
+    checkin_id UUID NOT NULL REFERENCES checkins(id) ON DELETE CASCADE,
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    template_id UUID REFERENCES checkin_templates(id) ON DELETE SET NULL,
+    question_id TEXT NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    date DATE NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL,
+    value_number DOUBLE PRECISION,
+    value_bool BOOLEAN,
+    value_options JSONB,
+    value_text TEXT,
+    enc_key_id TEXT,
+    enc_data_key BYTEA,
+    UNIQUE (checkin_id, question_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_checkin_answers_question
+    ON checkin_answers(profile_id, question_id, date);
+
+-- Existing checkins move into the default templates: the score becomes the
+-- answer to the "score" question.
+INSERT INTO checkin_templates (id, profile_id, name, type, is_default, questions)
+SELECT gen_random_uuid(), p.id, t.name, t.type, TRUE,
+    '[{"id":"score","label":"Самочувствие","type":"scale","min":1,"max":5,"required":true}]'::jsonb
+FROM profiles p
+CROSS JOIN (VALUES ('morning', 'Утро'), ('evening', 'Вечер')) AS t(type, name)
+WHERE EXISTS (SELECT 1 FROM checkins c WHERE c.profile_id = p.id)
+ON CONFLICT DO NOTHING;
+
+UPDATE checkins c
+SET template_id = t.id
+FROM checkin_templates t
+WHERE t.profile_id = c.profile_id AND t.type = c.type AND t.is_default AND c.template_id IS NULL;
+
+INSERT INTO checkin_answers (id, checkin_id, profile_id, template_id, question_id, position, date, recorded_at, value_number)
+SELECT gen_random_uuid(), c.id, c.profile_id, c.template_id, 'score', 0, c.date, c.recorded_at, c.score
+FROM checkins c
+WHERE c.score IS NOT NULL
+ON CONFLICT (checkin_id, question_id) DO NOTHING;
+
+-- Symptom log: what, how bad (1-10) and for how long. A symptom may be
+-- attached to the checkin it was reported in.
+CREATE TABLE IF NOT EXISTS symptoms (
+    id UUID PRIMARY KEY,
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    checkin_id UUID REFERENCES checkins(id) ON DELETE SET NULL,
+    name TEXT NOT NULL,
+    severity INT NOT NULL CHECK (severity BETWEEN 1 AND 10),
+    started_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ,
+    note TEXT NOT NULL DEFAULT '',
+    enc_key_id TEXT,
+    enc_data_key BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ended_at IS NULL OR ended_at >= started_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_symptoms_profile_started ON symptoms(profile_id, started_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS symptoms;
+DROP TABLE IF EXISTS checkin_answers;
+
+DELETE FROM checkins WHERE type = 'adhoc';
+DROP INDEX IF EXISTS uq_checkins_daily;
+ALTER TABLE checkins ADD CONSTRAINT checkins_profile_id_date_type_key UNIQUE (profile_id, date, type);
+ALTER TABLE checkins DROP CONSTRAINT IF EXISTS checkins_score_required;
+ALTER TABLE checkins ALTER COLUMN score SET NOT NULL;
+ALTER TABLE checkins DROP CONSTRAINT IF EXISTS checkins_type_check;
+ALTER TABLE checkins ADD CONSTRAINT checkins_type_check
+    CHECK (type IN ('morning', 'evening'));
+ALTER TABLE checkins
+    DROP COLUMN IF EXISTS recorded_at,
+    DROP COLUMN IF EXISTS template_id;
+
+DROP TABLE IF EXISTS checkin_templates;
@@ -0,0 +1,48 @@
+-- +goose Up
+-- Menstrual cycle tracking. Periods are start/end dates; cycle days hold
+-- the per-day flow and symptoms. Notes and symptoms are encrypted.
+CREATE TABLE IF NOT EXISTS cycle_periods (
+    id UUID PRIMARY KEY,
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    end_date DATE,
+    note TEXT NOT NULL DEFAULT '',
+    enc_key_id TEXT,
+    enc_data_key BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (profile_id, start_date),
+    CHECK (end_date IS NULL OR end_date >= start_date)
+);
+
+CREATE TABLE IF NOT EXISTS cycle_days (
+    id UUID PRIMARY KEY,
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    flow TEXT NOT NULL DEFAULT '' CHECK (flow IN ('', 'spotting', 'light', 'medium', 'heavy')),
+    symptoms JSONB NOT NULL DEFAULT '[]'::jsonb,
+    note TEXT NOT NULL DEFAULT '',
+    enc_key_id TEXT,
+    enc_data_key BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (profile_id, date)
+);
+
+-- Cycle data is private: it reaches the assistant only with share_with_ai.
+-- Zero lengths mean "learn from the logged periods".
+CREATE TABLE IF NOT EXISTS cycle_settings (
+    profile_id UUID PRIMARY KEY REFERENCES profiles(id) ON DELETE CASCADE,
+    share_with_ai BOOLEAN NOT NULL DEFAULT FALSE,
+    reminders_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    remind_days_before INT NOT NULL DEFAULT 2 CHECK (remind_days_before BETWEEN 0 AND 7),
+    remind_fertile_window BOOLEAN NOT NULL DEFAULT FALSE,
+    cycle_length_days INT NOT NULL DEFAULT 0,
+    period_length_days INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS cycle_settings;
+DROP TABLE IF EXISTS cycle_days;
+DROP TABLE IF EXISTS cycle_periods;
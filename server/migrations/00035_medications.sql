@@ -0,0 +1,56 @@
+-- +goose Up
+-- Medications: dose form and strength, scheduled (possibly varying) doses
+-- or as-needed use, pill stock and refill threshold. Prescriber, pharmacy,
+-- instructions and dose reasons are encrypted.
+CREATE TABLE IF NOT EXISTS medications (
+    id UUID PRIMARY KEY,
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    form TEXT NOT NULL DEFAULT 'tablet'
+        CHECK (form IN ('tablet', 'capsule', 'liquid', 'injection', 'inhaler', 'drops', 'patch', 'topical', 'other')),
+    strength TEXT NOT NULL DEFAULT '',
+    ingredients TEXT[] NOT NULL DEFAULT '{}',
+    dose_amount DOUBLE PRECISION NOT NULL CHECK (dose_amount > 0),
+    dose_unit TEXT NOT NULL,
+    -- [{"time_minutes": 480, "amount": 1}, ...]; amount overrides dose_amount.
+    schedule JSONB NOT NULL DEFAULT '[]'::jsonb,
+    days_mask INT NOT NULL DEFAULT 127 CHECK (days_mask BETWEEN 1 AND 127),
+    as_needed BOOLEAN NOT NULL DEFAULT FALSE,
+    max_daily_doses INT NOT NULL DEFAULT 0 CHECK (max_daily_doses >= 0),
+    min_interval_hours INT NOT NULL DEFAULT 0 CHECK (min_interval_hours >= 0),
+    -- Stock is counted in dose units; NULL means it is not tracked.
+    stock_quantity DOUBLE PRECISION CHECK (stock_quantity >= 0),
+    refill_threshold DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (refill_threshold >= 0),
+    prescriber TEXT NOT NULL DEFAULT '',
+    pharmacy TEXT NOT NULL DEFAULT '',
+    instructions TEXT NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    enc_key_id TEXT,
+    enc_data_key BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_medications_profile ON medications(profile_id, name);
+
+CREATE TABLE IF NOT EXISTS medication_doses (
+    id UUID PRIMARY KEY,
+    profile_id UUID NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    medication_id UUID NOT NULL REFERENCES medications(id) ON DELETE CASCADE,
+    taken_at TIMESTAMPTZ NOT NULL,
+    amount DOUBLE PRECISION NOT NULL CHECK (amount >= 0),
+    unit TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('taken', 'skipped')),
+    as_needed BOOLEAN NOT NULL DEFAULT FALSE,
+    reason TEXT NOT NULL DEFAULT '',
+    enc_key_id TEXT,
+    enc_data_key BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_medication_doses_profile_taken ON medication_doses(profile_id, taken_at);
+CREATE INDEX IF NOT EXISTS idx_medication_doses_medication_taken ON medication_doses(medication_id, taken_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS medication_doses;
+DROP TABLE IF EXISTS medications;
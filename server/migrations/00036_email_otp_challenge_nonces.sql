@@ -0,0 +1,17 @@
+-- +goose Up
+-- Solved challenges are single-use: every nonce is kept until the challenge
+-- expires instead of only the last one per email.
+CREATE TABLE IF NOT EXISTS email_otp_challenge_nonces (
+    nonce TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_otp_challenge_nonces_expires_at
+    ON email_otp_challenge_nonces(expires_at);
+
+ALTER TABLE email_otp_abuse DROP COLUMN IF EXISTS last_challenge_nonce;
+
+-- +goose Down
+ALTER TABLE email_otp_abuse ADD COLUMN IF NOT EXISTS last_challenge_nonce TEXT NOT NULL DEFAULT '';
+
+DROP TABLE IF EXISTS email_otp_challenge_nonces;
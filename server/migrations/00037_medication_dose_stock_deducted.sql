@@ -0,0 +1,14 @@
+-- +goose Up
+-- A dose restores on deletion only what it took from the stock: a dose larger
+-- than the remaining stock empties it, and doses logged while the stock was
+-- not tracked took nothing.
+ALTER TABLE medication_doses
+    ADD COLUMN IF NOT EXISTS stock_deducted DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (stock_deducted >= 0);
+
+UPDATE medication_doses d
+SET stock_deducted = d.amount
+FROM medications m
+WHERE m.id = d.medication_id AND d.status = 'taken' AND m.stock_quantity IS NOT NULL;
+
+-- +goose Down
+ALTER TABLE medication_doses DROP COLUMN IF EXISTS stock_deducted;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Apply moves a proposal to applying before it changes anything, so a
+-- concurrent apply, reject or expiry cannot act on it meanwhile.
+ALTER TABLE ai_proposals DROP CONSTRAINT IF EXISTS ai_proposals_status_check;
+ALTER TABLE ai_proposals ADD CONSTRAINT ai_proposals_status_check
+    CHECK (status IN ('pending', 'applying', 'applied', 'rejected', 'undone', 'expired', 'superseded'));
+
+-- +goose Down
+UPDATE ai_proposals SET status = 'pending' WHERE status = 'applying';
+ALTER TABLE ai_proposals DROP CONSTRAINT IF EXISTS ai_proposals_status_check;
+ALTER TABLE ai_proposals ADD CONSTRAINT ai_proposals_status_check
+    CHECK (status IN ('pending', 'applied', 'rejected', 'undone', 'expired', 'superseded'));